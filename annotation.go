@@ -0,0 +1,129 @@
+package packemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ANNOTATIONS_FILE_SUFFIX is appended to the path of an exported capture to name its annotation sidecar file
+// 書き出したキャプチャのパスに付けて、注釈のサイドカーファイルの名前にする接尾辞です
+const ANNOTATIONS_FILE_SUFFIX = ".notes.json"
+
+// Annotation is a note on a packet, e.g. "this is where it broke".
+// Packet is the packet number: its position in the capture starting at 1, like Wireshark's frame number
+// パケットへの注釈です(例: 「ここで壊れた」)。
+// Packetはパケット番号で、WiresharkのフレームNo.と同様にキャプチャ内の1から始まる位置です
+type Annotation struct {
+	Packet uint64 `json:"packet"`
+	Note   string `json:"note"`
+}
+
+// Annotations holds notes on packets keyed by packet number.
+// It is kept apart from the captured packets, so capturing and decoding don't pay for it
+// パケット番号ごとにパケットへの注釈を保持します。
+// キャプチャしたパケットとは別に保持するため、キャプチャや解析の処理には影響しません
+type Annotations struct {
+	mu    sync.RWMutex
+	notes map[uint64]string
+}
+
+// NewAnnotations creates an empty annotation store
+// 空の注釈の保存先を作成します
+func NewAnnotations() *Annotations {
+	return &Annotations{
+		notes: map[uint64]string{},
+	}
+}
+
+// Annotate sets the note of the packet. An empty note removes it
+// パケットの注釈を設定します。空の注釈は削除します
+func (a *Annotations) Annotate(packet uint64, note string) {
+	note = strings.TrimSpace(note)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if note == "" {
+		delete(a.notes, packet)
+		return
+	}
+	a.notes[packet] = note
+}
+
+// Note returns the note of the packet
+// パケットの注釈を返します
+func (a *Annotations) Note(packet uint64) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	note, ok := a.notes[packet]
+	return note, ok
+}
+
+// Len returns the number of annotated packets
+// 注釈の付いたパケット数を返します
+func (a *Annotations) Len() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.notes)
+}
+
+// List returns the annotations in packet number order
+// パケット番号順に注釈を返します
+func (a *Annotations) List() []Annotation {
+	if a == nil {
+		return nil
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	annotations := make([]Annotation, 0, len(a.notes))
+	for _, packet := range slices.Sorted(maps.Keys(a.notes)) {
+		annotations = append(annotations, Annotation{Packet: packet, Note: a.notes[packet]})
+	}
+	return annotations
+}
+
+// サイドカーファイルの形式
+type annotationsFile struct {
+	Annotations []Annotation `json:"annotations"`
+}
+
+// Save writes the annotations to w as the JSON of a sidecar file
+// 注釈をサイドカーファイルのJSONとしてwに書き込みます
+func (a *Annotations) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(annotationsFile{Annotations: a.List()})
+}
+
+// LoadAnnotations reads the annotations of a sidecar file written by Save or RingCapture.WritePcapWithAnnotations
+// SaveまたはRingCapture.WritePcapWithAnnotationsで書き込んだサイドカーファイルの注釈を読み込みます
+func LoadAnnotations(r io.Reader) (*Annotations, error) {
+	file := annotationsFile{}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse annotations: %w", err)
+	}
+	a := NewAnnotations()
+	for _, annotation := range file.Annotations {
+		if annotation.Packet == 0 {
+			return nil, fmt.Errorf("annotation %q has no packet number", annotation.Note)
+		}
+		a.Annotate(annotation.Packet, annotation.Note)
+	}
+	return a, nil
+}
+
+// AnnotationsPath returns the path of the annotation sidecar file of a capture file
+// キャプチャファイルの注釈のサイドカーファイルのパスを返します
+func AnnotationsPath(capturePath string) string {
+	return capturePath + ANNOTATIONS_FILE_SUFFIX
+}
@@ -0,0 +1,79 @@
+package packemon
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRingCaptureAnnotate tests annotating packets by number, retrieving the notes, dropping them with their packets,
+// and exporting them renumbered in a sidecar file
+// 番号でパケットに注釈を付けて取得すること、パケットと一緒に捨てられること、
+// および番号を振り直してサイドカーファイルに書き出すことをテストします
+func TestRingCaptureAnnotate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ring := NewRingCapture(3, 0)
+	frames := [][]byte{
+		parseDepthTestFrame(),
+		decodeStatsTestUDPFrame(40000, 9999, []byte("udp")),
+		parseDepthTestFrame(),
+		decodeStatsTestUDPFrame(40000, 9999, []byte("udp")),
+	}
+	for i, frame := range frames[:3] {
+		ring.Add(ringCaptureTestPassive(t, frame, base.Add(time.Duration(i)*time.Second)))
+	}
+
+	if err := ring.Annotate(1, "handshake starts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Annotate(3, "  this is where it broke  "); err != nil {
+		t.Fatal(err)
+	}
+	if note, ok := ring.Note(3); !ok || note != "this is where it broke" {
+		t.Errorf("Note(3) = %q, %v", note, ok)
+	}
+	if passive, ok := ring.Packet(3); !ok || !passive.Timestamp.Equal(base.Add(2*time.Second)) {
+		t.Errorf("Packet(3) = %v, %v", passive, ok)
+	}
+	if err := ring.Annotate(4, "not captured yet"); err == nil {
+		t.Error("Annotate(4) succeeded before the packet was added")
+	}
+
+	// 4 つ目を追加すると 1 つ目とその注釈が捨てられる
+	ring.Add(ringCaptureTestPassive(t, frames[3], base.Add(3*time.Second)))
+	if _, ok := ring.Note(1); ok || ring.FirstNumber() != 2 {
+		t.Errorf("the note of the dropped packet 1 is still there, FirstNumber() = %d", ring.FirstNumber())
+	}
+	if err := ring.Annotate(4, "retransmission"); err != nil {
+		t.Fatal(err)
+	}
+	want := []Annotation{{Packet: 3, Note: "this is where it broke"}, {Packet: 4, Note: "retransmission"}}
+	if got := ring.Annotations(); !slices.Equal(got, want) {
+		t.Errorf("Annotations() = %+v, want %+v", got, want)
+	}
+
+	// UDP だけを書き出すと、4 番のパケットは書き出したファイルの 2 番目になる
+	pcap, sidecar := &bytes.Buffer{}, &bytes.Buffer{}
+	n, err := ring.WritePcapWithAnnotations(pcap, sidecar, "udp")
+	if err != nil || n != 2 {
+		t.Fatalf("WritePcapWithAnnotations() = %d, %v", n, err)
+	}
+	loaded, err := LoadAnnotations(sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.List(); !slices.Equal(got, []Annotation{{Packet: 2, Note: "retransmission"}}) {
+		t.Errorf("sidecar annotations = %+v", got)
+	}
+
+	if err := ring.Annotate(4, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ring.Note(4); ok {
+		t.Error("an empty note should remove the annotation")
+	}
+	if got := AnnotationsPath("capture.pcap"); got != "capture.pcap.notes.json" {
+		t.Errorf("AnnotationsPath() = %s", got)
+	}
+}
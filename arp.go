@@ -89,3 +89,11 @@ func NewARPReply(sMACAdder HardwareAddr, sIPAddr uint32, tMACAddr HardwareAddr,
 		TargetIPAddr:       tIPAddr,
 	}
 }
+
+// NewGratuitousARP returns a gratuitous ARP announcement (RFC 5227): a request whose sender and target IP are both ip, with an unknown target hardware address.
+// Hosts on the segment update their ARP cache for ip to sMACAddr, e.g. after an IP takeover.
+// Gratuitous ARPのアナウンス(RFC 5227)を返します。送信元と宛先のIPが共にipで、宛先のハードウェアアドレスが不明のリクエストです。
+// セグメント内のホストはipのARPキャッシュをsMACAddrに更新します(IPの引き継ぎ後など)
+func NewGratuitousARP(sMACAddr HardwareAddr, ip uint32) *ARP {
+	return NewARPRequest(sMACAddr, ip, HardwareAddr{}, ip)
+}
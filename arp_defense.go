@@ -0,0 +1,130 @@
+package packemon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ARP_DEFENSE_HOLDOFF is the minimum interval between corrective ARPs for the same IP address,
+// so that a spoofer repeating its claims does not turn the defense into an ARP storm
+// 同じIPアドレスに対して訂正のARPを送る最小の間隔です。主張を繰り返すスプーファーに対して防御がARPストームにならないようにします
+const ARP_DEFENSE_HOLDOFF = time.Second
+
+// ARPDefenseEntry is a protected IPv4 address and the MAC address it legitimately belongs to
+// 保護するIPv4アドレスと、それが正当に属するMACアドレスです
+type ARPDefenseEntry struct {
+	IP  string `json:"ip"`
+	MAC string `json:"mac"`
+}
+
+// ARPDefender answers ARP spoofing of protected addresses: when an ARP packet claims a protected IP address for a MAC address
+// other than its legitimate one, it broadcasts a corrective gratuitous ARP reasserting the legitimate MAC address.
+// The protected addresses are pinned in a NeighborTable, so a spoofed claim is one of its conflicts.
+// The ARP sender is the legitimate MAC address, while the Ethernet source is the interface's own, so that switches do not
+// move the legitimate host's MAC address to the defender's port.
+// Sending ARP on behalf of another host is intrusive, so it only acts on the addresses it is explicitly given
+// 保護するアドレスのARPスプーフィングに対処します。保護するIPアドレスを正当なものと異なるMACアドレスが名乗るARPパケットを観測すると、
+// 正当なMACアドレスを主張し直す訂正のGratuitous ARPをブロードキャストします。
+// 保護するアドレスはNeighborTableに固定するため、偽装した主張はその競合になります。
+// スイッチが正当なホストのMACアドレスを防御側のポートで学習し直さないよう、ARPの送信元は正当なMACアドレス、
+// Ethernetの送信元はインターフェース自身のMACアドレスにします。
+// 他のホストに代わってARPを送るのは影響が大きいため、明示的に指定されたアドレスに対してのみ動作します
+type ARPDefender struct {
+	mac       net.HardwareAddr            // 送信するインターフェースの MAC アドレス
+	table     *NeighborTable              // 保護するアドレスを Pin した近隣テーブル
+	protected map[string]net.HardwareAddr // IP アドレスごとの正当な MAC アドレス
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+
+	send func(ctx context.Context, frame []byte) error
+}
+
+// NewARPDefender creates a defender of the entries that sends from nwif. It fails without entries or with an invalid one
+// nwifから送信する、entriesを保護するARPDefenderを作成します。entriesが無いか不正なものがある場合はエラーを返します
+func NewARPDefender(nwif *NetworkInterface, entries []ARPDefenseEntry) (*ARPDefender, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("no addresses to protect from arp spoofing")
+	}
+	mac, _, _ := nwif.GetNetworkInfo()
+	d := &ARPDefender{
+		mac:       mac,
+		table:     NewNeighborTable(),
+		protected: make(map[string]net.HardwareAddr, len(entries)),
+		lastSent:  make(map[string]time.Time),
+		send:      nwif.SendEthernetFrame,
+	}
+	for _, entry := range entries {
+		ip := net.ParseIP(entry.IP).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("arp defense: not an IPv4 address: %q", entry.IP)
+		}
+		mac, err := net.ParseMAC(entry.MAC)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("arp defense: invalid MAC address for %s: %q", ip, entry.MAC)
+		}
+		d.protected[ip.String()] = mac
+		d.table.Pin(ip, mac)
+	}
+	return d, nil
+}
+
+// Neighbors returns the neighbor table the defender learns from the packets it handles, with the protected addresses pinned
+// 処理したパケットから学習した近隣テーブルを返します。保護するアドレスは固定されています
+func (d *ARPDefender) Neighbors() *NeighborTable {
+	return d.table
+}
+
+// Handle learns the mapping announced by p and, if it conflicts with a protected IP address, sends the corrective gratuitous ARP.
+// It reports whether one was sent; claims within ARP_DEFENSE_HOLDOFF of the last correction are only learned
+// pが通知する対応を学習し、保護するIPアドレスと競合していれば訂正のGratuitous ARPを送信します。
+// 送信したかどうかを返します。前回の訂正からARP_DEFENSE_HOLDOFF以内の主張は学習のみします
+func (d *ARPDefender) Handle(ctx context.Context, p *Passive) (bool, error) {
+	// 正当な MAC アドレスの主張(自身が送った訂正を含む)は固定した対応と一致するので競合しない
+	conflict := d.table.Update(p)
+	if conflict == nil || p.ARP == nil {
+		return false, nil
+	}
+	ip := conflict.IP.To4()
+	if ip == nil {
+		return false, nil
+	}
+	legitimate, ok := d.protected[ip.String()]
+	if !ok {
+		return false, nil
+	}
+
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	d.mu.Lock()
+	if last, ok := d.lastSent[ip.String()]; ok && now.Sub(last) < ARP_DEFENSE_HOLDOFF {
+		d.mu.Unlock()
+		return false, nil
+	}
+	d.lastSent[ip.String()] = now
+	d.mu.Unlock()
+
+	logARPDefense(ip, conflict.NewMAC, legitimate)
+	if err := d.send(ctx, correctiveARPFrame(d.mac, legitimate, ip)); err != nil {
+		return false, fmt.Errorf("failed to send corrective arp for %s: %w", ip, err)
+	}
+	return true, nil
+}
+
+// ARP の送信元を正当な MAC アドレス、Ethernet の送信元を自身の MAC アドレスにした Gratuitous ARP
+func correctiveARPFrame(own net.HardwareAddr, legitimate net.HardwareAddr, ip4 net.IP) []byte {
+	// ループバックなど MAC アドレスの無いインターフェースではゼロのまま
+	var src, sender HardwareAddr
+	copy(src[:], own)
+	copy(sender[:], legitimate)
+	arp := NewGratuitousARP(sender, binary.BigEndian.Uint32(ip4))
+	dst := HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	return NewEthernetFrame(dst, src, ETHER_TYPE_ARP, arp.Bytes()).Bytes()
+}
@@ -0,0 +1,109 @@
+package packemon
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestARPDefender tests that a claim of a protected IP address by another MAC address is answered with a gratuitous ARP
+// reasserting the legitimate MAC address, and that the legitimate claims, other addresses and repeated claims are not
+// 保護するIPアドレスを別のMACアドレスが名乗ると正当なMACアドレスを主張し直すGratuitous ARPを送り、
+// 正当な主張、他のアドレス、繰り返しの主張には送らないことをテストします
+func TestARPDefender(t *testing.T) {
+	legitimate := net.HardwareAddr{0x00, 0x15, 0x5d, 0x00, 0x00, 0x01}
+	spoofer := net.HardwareAddr{0x02, 0x00, 0x00, 0xba, 0xd0, 0x01}
+	gateway := net.IPv4(192, 168, 0, 1).To4()
+
+	nwif := NewOfflineNetworkInterface("lo")
+	defender, err := NewARPDefender(nwif, []ARPDefenseEntry{{IP: "192.168.0.1", MAC: legitimate.String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ethernet の送信元はインターフェース自身の MAC アドレス. ループバックには無いのでゼロ
+	own := make(net.HardwareAddr, 6)
+	if mac, _, _ := nwif.GetNetworkInfo(); len(mac) != 0 {
+		copy(own, mac)
+	}
+	var sent [][]byte
+	defender.send = func(_ context.Context, frame []byte) error {
+		sent = append(sent, frame)
+		return nil
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	handle := func(frame []byte, at time.Duration) bool {
+		t.Helper()
+		passive, err := DecodeFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		passive.Timestamp = start.Add(at)
+		ok, err := defender.Handle(context.Background(), passive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if handle(gratuitousARPFrame(legitimate, gateway), 0) {
+		t.Error("corrective ARP sent for the legitimate MAC address")
+	}
+	if handle(gratuitousARPFrame(spoofer, net.IPv4(192, 168, 0, 50).To4()), 0) {
+		t.Error("corrective ARP sent for an address that is not protected")
+	}
+	if !handle(gratuitousARPFrame(spoofer, gateway), 100*time.Millisecond) {
+		t.Fatal("no corrective ARP sent for the spoofed gateway")
+	}
+	if got := defender.Neighbors().Conflicts(); len(got) != 1 || !bytes.Equal(got[0].OldMAC, legitimate) || !bytes.Equal(got[0].NewMAC, spoofer) {
+		t.Errorf("Conflicts() = %v, want the spoofer's claim", got)
+	}
+	// 保護するアドレスは偽装した主張で置き換わらない
+	if entries := defender.Neighbors().Snapshot(); len(entries) != 2 || !bytes.Equal(entries[0].MAC, legitimate) || !entries[0].Static {
+		t.Errorf("Snapshot() = %v, want the gateway pinned at %s", entries, legitimate)
+	}
+
+	if len(sent) != 1 {
+		t.Fatalf("sent %d frames, want 1", len(sent))
+	}
+	passive, err := DecodeFrame(sent[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.ARP == nil || !bytes.Equal(passive.ARP.SenderMAC, legitimate) ||
+		!bytes.Equal(passive.ARP.SenderIP, gateway) || !bytes.Equal(passive.ARP.TargetIP, gateway) {
+		t.Errorf("corrective frame = %s, want a gratuitous ARP for %s at %s", passive, gateway, legitimate)
+	}
+	if !bytes.Equal(passive.EthernetFrame.SrcAddr, own) {
+		t.Errorf("corrective frame from %s, want the interface's own %s", net.HardwareAddr(passive.EthernetFrame.SrcAddr), own)
+	}
+
+	// 自身が送った訂正には応答しない
+	if handle(sent[0], 110*time.Millisecond) {
+		t.Error("corrective ARP sent for the defender's own correction")
+	}
+
+	// 繰り返しの主張には ARP_DEFENSE_HOLDOFF を空けて送る
+	if handle(gratuitousARPFrame(spoofer, gateway), 500*time.Millisecond) {
+		t.Error("corrective ARP sent within the holdoff")
+	}
+	if !handle(gratuitousARPFrame(spoofer, gateway), 100*time.Millisecond+ARP_DEFENSE_HOLDOFF) {
+		t.Error("no corrective ARP sent after the holdoff")
+	}
+}
+
+func TestNewARPDefenderInvalid(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("lo")
+	for _, entries := range [][]ARPDefenseEntry{
+		nil,
+		{{IP: "2001:db8::1", MAC: "00:15:5d:00:00:01"}},
+		{{IP: "192.168.0.1", MAC: "00:15:5d:00:00:01:02:03"}},
+		{{IP: "192.168.0.1", MAC: "gateway"}},
+	} {
+		if _, err := NewARPDefender(nwif, entries); err == nil {
+			t.Errorf("NewARPDefender(%v) succeeded, want an error", entries)
+		}
+	}
+}
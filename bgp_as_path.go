@@ -0,0 +1,256 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BGP path attribute types and flags as defined in RFC 4271 and RFC 6793
+// RFC 4271とRFC 6793で定義されているBGPパス属性のタイプとフラグ
+const (
+	BGP_ATTR_TYPE_ORIGIN   = 1
+	BGP_ATTR_TYPE_AS_PATH  = 2
+	BGP_ATTR_TYPE_NEXT_HOP = 3
+	BGP_ATTR_TYPE_AS4_PATH = 17
+
+	BGP_ATTR_FLAG_OPTIONAL        = 0x80
+	BGP_ATTR_FLAG_TRANSITIVE      = 0x40
+	BGP_ATTR_FLAG_PARTIAL         = 0x20
+	BGP_ATTR_FLAG_EXTENDED_LENGTH = 0x10
+)
+
+// AS_PATH segment types
+// AS_PATHのセグメントタイプ
+const (
+	BGP_AS_PATH_SEGMENT_AS_SET      = 1 // 順序の無いASの集合(経路集約で生じる)
+	BGP_AS_PATH_SEGMENT_AS_SEQUENCE = 2 // 経路が通過した順のAS
+)
+
+// BGP_CAPABILITY_FOUR_OCTET_AS is the OPEN capability announcing support for 4-octet AS numbers (RFC 6793)
+// 4オクテットのAS番号に対応していることを示すOPENのケーパビリティ(RFC 6793)です
+const BGP_CAPABILITY_FOUR_OCTET_AS = 65
+
+// BGP_OPEN_PARAM_CAPABILITIES is the OPEN optional parameter type carrying capabilities (RFC 5492)
+// ケーパビリティを運ぶOPENのオプションパラメータのタイプ(RFC 5492)です
+const BGP_OPEN_PARAM_CAPABILITIES = 2
+
+// BGPPathAttribute is a path attribute of an UPDATE message
+// UPDATEメッセージのパス属性です
+type BGPPathAttribute struct {
+	Flags uint8
+	Type  uint8
+	Value []byte
+}
+
+// BGPASPathSegment is one segment of an AS_PATH: the AS numbers of an AS_SEQUENCE in order, or of an AS_SET
+// AS_PATHの1つのセグメントです。AS_SEQUENCEでは順序通りのAS番号、AS_SETでは集合のAS番号です
+type BGPASPathSegment struct {
+	Type uint8
+	ASNs []uint32
+}
+
+// BGPASPath is the decoded AS_PATH attribute, as an ordered list of segments
+// 解析したAS_PATH属性で、セグメントを順に並べたものです
+type BGPASPath struct {
+	Segments []BGPASPathSegment
+}
+
+// ParseBGPPathAttributes parses the path attributes of an UPDATE message. A truncated attribute is an error
+// UPDATEメッセージのパス属性を解析します。途中で切れた属性はエラーになります
+func ParseBGPPathAttributes(data []byte) ([]BGPPathAttribute, error) {
+	attrs := []BGPPathAttribute{}
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 3 {
+			return attrs, fmt.Errorf("bgp path attribute at %d: truncated header", offset)
+		}
+		flags, typ := data[offset], data[offset+1]
+		headerLen, length := 3, int(data[offset+2])
+		if flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+			if len(data)-offset < 4 {
+				return attrs, fmt.Errorf("bgp path attribute at %d: truncated header", offset)
+			}
+			headerLen, length = 4, int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+		}
+		if len(data)-offset-headerLen < length {
+			return attrs, fmt.Errorf("bgp path attribute %d: length %d exceeds the remaining %d bytes", typ, length, len(data)-offset-headerLen)
+		}
+		start := offset + headerLen
+		attrs = append(attrs, BGPPathAttribute{Flags: flags, Type: typ, Value: data[start : start+length]})
+		offset = start + length
+	}
+	return attrs, nil
+}
+
+// ASPath decodes the AS_PATH attribute of the UPDATE, nil if it has none. fourOctet is whether the session negotiated 4-octet AS numbers
+// (see BGPOpen.FourOctetAS). Between 2-octet speakers, AS numbers above 65535 are carried in AS4_PATH, which is merged with AS_PATH as in RFC 6793 4.2.3:
+// the leading AS numbers that AS_PATH has beyond the length of AS4_PATH are kept, and an AS4_PATH longer than AS_PATH is ignored.
+// UPDATEのAS_PATH属性を解析します。無ければnilです。fourOctetはセッションで4オクテットのAS番号がネゴシエートされたかどうかです(BGPOpen.FourOctetAS参照)。
+// 2オクテットのスピーカー間では65535より大きいAS番号はAS4_PATHで運ばれるため、RFC 6793 4.2.3の通りAS_PATHとまとめます。
+// AS_PATHがAS4_PATHの長さより多く持つ先頭のAS番号は残し、AS_PATHより長いAS4_PATHは無視します
+func (u *BGPUpdate) ASPath(fourOctet bool) (*BGPASPath, error) {
+	attrs, err := ParseBGPPathAttributes(u.PathAttributes)
+	if err != nil {
+		return nil, err
+	}
+	var asPath, as4Path *BGPPathAttribute
+	for i := range attrs {
+		switch attrs[i].Type {
+		case BGP_ATTR_TYPE_AS_PATH:
+			asPath = &attrs[i]
+		case BGP_ATTR_TYPE_AS4_PATH:
+			as4Path = &attrs[i]
+		}
+	}
+	if asPath == nil {
+		return nil, nil
+	}
+	path, err := ParseBGPASPath(asPath.Value, fourOctet)
+	if err != nil || as4Path == nil || fourOctet {
+		return path, err
+	}
+	// AS_PATH 側の大きな AS 番号は AS_TRANS(23456) に置き換えられているので、AS4_PATH で置き換える
+	path4, err := ParseBGPASPath(as4Path.Value, true)
+	if err != nil {
+		return nil, err
+	}
+	return mergeBGPAS4Path(path, path4), nil
+}
+
+// AS4_PATH を知らないスピーカーが前に付けた AS_PATH の先頭の AS 番号を残し、残りを AS4_PATH にする (RFC 6793 4.2.3)
+func mergeBGPAS4Path(path, path4 *BGPASPath) *BGPASPath {
+	if path4.Len() > path.Len() {
+		return path
+	}
+	merged := &BGPASPath{Segments: []BGPASPathSegment{}}
+	for lead, i := path.Len()-path4.Len(), 0; lead > 0; i++ {
+		segment := path.Segments[i]
+		if segment.Type == BGP_AS_PATH_SEGMENT_AS_SET {
+			merged.Segments = append(merged.Segments, segment)
+			lead--
+			continue
+		}
+		n := min(lead, len(segment.ASNs))
+		merged.Segments = append(merged.Segments, BGPASPathSegment{Type: segment.Type, ASNs: append([]uint32{}, segment.ASNs[:n]...)})
+		lead -= n
+	}
+	for _, segment := range path4.Segments {
+		// 続く AS_SEQUENCE は 1 つのセグメントにまとめる
+		if last := len(merged.Segments) - 1; last >= 0 && segment.Type == BGP_AS_PATH_SEGMENT_AS_SEQUENCE &&
+			merged.Segments[last].Type == BGP_AS_PATH_SEGMENT_AS_SEQUENCE && len(merged.Segments[last].ASNs)+len(segment.ASNs) <= 255 {
+			merged.Segments[last].ASNs = append(merged.Segments[last].ASNs, segment.ASNs...)
+			continue
+		}
+		merged.Segments = append(merged.Segments, segment)
+	}
+	return merged
+}
+
+// Len returns the path length used in route selection (RFC 4271 9.1.2.2): each AS number of an AS_SEQUENCE counts one, an AS_SET counts one as a whole
+// 経路選択で使うパスの長さを返します(RFC 4271 9.1.2.2)。AS_SEQUENCEのAS番号はそれぞれ1、AS_SETは全体で1と数えます
+func (p *BGPASPath) Len() int {
+	n := 0
+	for _, segment := range p.Segments {
+		if segment.Type == BGP_AS_PATH_SEGMENT_AS_SET {
+			n++
+		} else {
+			n += len(segment.ASNs)
+		}
+	}
+	return n
+}
+
+// ParseBGPASPath parses the value of an AS_PATH attribute with 2-octet or, if fourOctet, 4-octet AS numbers
+// AS_PATH属性の値を、2オクテット(fourOctetの場合は4オクテット)のAS番号として解析します
+func ParseBGPASPath(data []byte, fourOctet bool) (*BGPASPath, error) {
+	asSize := 2
+	if fourOctet {
+		asSize = 4
+	}
+	path := &BGPASPath{Segments: []BGPASPathSegment{}}
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 2 {
+			return nil, fmt.Errorf("as_path segment at %d: truncated header", offset)
+		}
+		typ, count := data[offset], int(data[offset+1])
+		if typ != BGP_AS_PATH_SEGMENT_AS_SET && typ != BGP_AS_PATH_SEGMENT_AS_SEQUENCE {
+			return nil, fmt.Errorf("as_path segment at %d: unknown type %d", offset, typ)
+		}
+		offset += 2
+		if len(data)-offset < count*asSize {
+			return nil, fmt.Errorf("as_path segment of %d AS numbers exceeds the remaining %d bytes", count, len(data)-offset)
+		}
+		segment := BGPASPathSegment{Type: typ, ASNs: make([]uint32, count)}
+		for i := range segment.ASNs {
+			if fourOctet {
+				segment.ASNs[i] = binary.BigEndian.Uint32(data[offset:])
+			} else {
+				segment.ASNs[i] = uint32(binary.BigEndian.Uint16(data[offset:]))
+			}
+			offset += asSize
+		}
+		path.Segments = append(path.Segments, segment)
+	}
+	return path, nil
+}
+
+// Bytes serializes the AS_PATH into an attribute value with 2-octet or, if fourOctet, 4-octet AS numbers
+// AS_PATHを2オクテット(fourOctetの場合は4オクテット)のAS番号の属性値にシリアル化します
+func (p *BGPASPath) Bytes(fourOctet bool) []byte {
+	buf := []byte{}
+	for _, segment := range p.Segments {
+		buf = append(buf, segment.Type, byte(len(segment.ASNs)))
+		for _, asn := range segment.ASNs {
+			if fourOctet {
+				buf = binary.BigEndian.AppendUint32(buf, asn)
+			} else {
+				buf = binary.BigEndian.AppendUint16(buf, uint16(asn))
+			}
+		}
+	}
+	return buf
+}
+
+// String renders the path as usually shown by routers, e.g. "65001 65002 {65100 65200}"
+// ルーターの一般的な表示形式で返します。例: "65001 65002 {65100 65200}"
+func (p *BGPASPath) String() string {
+	segments := make([]string, 0, len(p.Segments))
+	for _, segment := range p.Segments {
+		segments = append(segments, segment.String())
+	}
+	return strings.Join(segments, " ")
+}
+
+func (s BGPASPathSegment) String() string {
+	asns := make([]string, len(s.ASNs))
+	for i, asn := range s.ASNs {
+		asns[i] = strconv.FormatUint(uint64(asn), 10)
+	}
+	if s.Type == BGP_AS_PATH_SEGMENT_AS_SET {
+		return "{" + strings.Join(asns, " ") + "}"
+	}
+	return strings.Join(asns, " ")
+}
+
+// FourOctetAS reports whether the OPEN advertises the 4-octet AS number capability. The session uses 4-octet AS numbers when both OPENs do
+// OPENが4オクテットのAS番号のケーパビリティを広告しているかを返します。両方のOPENが広告していれば、セッションは4オクテットのAS番号を使います
+func (o *BGPOpen) FourOctetAS() bool {
+	params := o.OptionalParameters
+	for len(params) >= 2 {
+		typ, length := params[0], int(params[1])
+		if len(params) < 2+length {
+			return false
+		}
+		if typ == BGP_OPEN_PARAM_CAPABILITIES {
+			// 1 つのパラメータに複数のケーパビリティが入ることがある
+			for caps := params[2 : 2+length]; len(caps) >= 2 && len(caps) >= 2+int(caps[1]); caps = caps[2+int(caps[1]):] {
+				if caps[0] == BGP_CAPABILITY_FOUR_OCTET_AS {
+					return true
+				}
+			}
+		}
+		params = params[2+length:]
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package packemon
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestParseBGPASPath tests decoding an AS_SEQUENCE followed by an AS_SET with 2-octet and 4-octet AS numbers
+// AS_SEQUENCEとそれに続くAS_SETを、2オクテットと4オクテットのAS番号で解析することをテストします
+func TestParseBGPASPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		fourOctet bool
+		want      string
+	}{
+		{
+			name: "2-octet",
+			data: []byte{
+				BGP_AS_PATH_SEGMENT_AS_SEQUENCE, 2, 0xfd, 0xe9, 0xfd, 0xea, // 65001 65002
+				BGP_AS_PATH_SEGMENT_AS_SET, 2, 0xfe, 0x4c, 0xfe, 0xb0, // {65100 65200}
+			},
+			want: "65001 65002 {65100 65200}",
+		},
+		{
+			name: "4-octet",
+			data: []byte{
+				BGP_AS_PATH_SEGMENT_AS_SEQUENCE, 2, 0x00, 0x00, 0xfd, 0xe9, 0x00, 0x03, 0x0d, 0x40, // 65001 200000
+				BGP_AS_PATH_SEGMENT_AS_SET, 1, 0xfa, 0x56, 0xea, 0x00, // {4200000000}
+			},
+			fourOctet: true,
+			want:      "65001 200000 {4200000000}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := ParseBGPASPath(tt.data, tt.fourOctet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := path.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if len(path.Segments) != 2 || path.Segments[0].Type != BGP_AS_PATH_SEGMENT_AS_SEQUENCE || path.Segments[1].Type != BGP_AS_PATH_SEGMENT_AS_SET {
+				t.Errorf("Segments = %+v, want a sequence and a set", path.Segments)
+			}
+			if got := path.Bytes(tt.fourOctet); !bytes.Equal(got, tt.data) {
+				t.Errorf("Bytes() = %x, want %x", got, tt.data)
+			}
+		})
+	}
+
+	// 4 オクテットのつもりで 2 オクテットの AS_PATH を読むと長さが合わない
+	if _, err := ParseBGPASPath(tests[0].data, true); err == nil {
+		t.Error("a 2-octet AS_PATH decoded as 4-octet should be an error")
+	}
+	if _, err := ParseBGPASPath([]byte{3, 1, 0xfd, 0xe9}, false); err == nil {
+		t.Error("an unknown segment type should be an error")
+	}
+}
+
+// TestBGPUpdateASPath tests finding the AS_PATH among the path attributes of an UPDATE, and the 4-octet AS capability of an OPEN
+// UPDATEのパス属性からAS_PATHを見つけること、OPENの4オクテットASのケーパビリティをテストします
+func TestBGPUpdateASPath(t *testing.T) {
+	asPath := (&BGPASPath{Segments: []BGPASPathSegment{
+		{Type: BGP_AS_PATH_SEGMENT_AS_SEQUENCE, ASNs: []uint32{65001, 23456}},
+	}}).Bytes(false)
+	as4Path := (&BGPASPath{Segments: []BGPASPathSegment{
+		{Type: BGP_AS_PATH_SEGMENT_AS_SEQUENCE, ASNs: []uint32{65001, 200000}},
+	}}).Bytes(true)
+
+	attrs := []byte{BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_TYPE_ORIGIN, 1, 0x00}
+	// AS_PATH は拡張長で入れる
+	attrs = append(attrs, BGP_ATTR_FLAG_TRANSITIVE|BGP_ATTR_FLAG_EXTENDED_LENGTH, BGP_ATTR_TYPE_AS_PATH, 0x00, byte(len(asPath)))
+	attrs = append(attrs, asPath...)
+	attrs = append(attrs, BGP_ATTR_FLAG_OPTIONAL|BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_TYPE_AS4_PATH, byte(len(as4Path)))
+	attrs = append(attrs, as4Path...)
+
+	update := ParsedBGPUpdate(ParsedBGP(NewBGPUpdate(nil, attrs, []byte{24, 192, 0, 2}).Bytes()))
+	if update == nil {
+		t.Fatal("ParsedBGPUpdate = nil")
+	}
+	path, err := update.ASPath(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := path.String(); got != "65001 200000" {
+		t.Errorf("ASPath = %q, want the AS4_PATH 65001 200000", got)
+	}
+
+	update.PathAttributes = attrs[:len(attrs)-len(as4Path)-3]
+	if path, err := update.ASPath(false); err != nil || path.String() != "65001 23456" {
+		t.Errorf("ASPath without AS4_PATH = %v, %v", path, err)
+	}
+	update.PathAttributes = attrs[:4]
+	if path, err := update.ASPath(false); err != nil || path != nil {
+		t.Errorf("ASPath without AS_PATH = %v, %v, want nil", path, err)
+	}
+	update.PathAttributes = attrs[:len(attrs)-1]
+	if _, err := update.ASPath(false); err == nil {
+		t.Error("a truncated attribute should be an error")
+	}
+
+	// multiprotocol (1) と 4 オクテット AS (65) のケーパビリティ
+	params := []byte{BGP_OPEN_PARAM_CAPABILITIES, 12, 0x01, 0x04, 0x00, 0x01, 0x00, 0x01, BGP_CAPABILITY_FOUR_OCTET_AS, 0x04, 0x00, 0x03, 0x0d, 0x40}
+	if open := ParsedBGPOpen(ParsedBGP(NewBGPOpen(23456, 180, 0xc0a80101, params).Bytes())); open == nil || !open.FourOctetAS() {
+		t.Error("FourOctetAS() = false, want true")
+	}
+	if open := ParsedBGPOpen(ParsedBGP(NewBGPOpen(65001, 180, 0xc0a80101, params[:8]).Bytes())); open == nil || open.FourOctetAS() {
+		t.Error("FourOctetAS() = true without the capability")
+	}
+}
+
+// TestBGPUpdateAS4PathMerge tests merging AS4_PATH into an AS_PATH longer or shorter than it (RFC 6793 4.2.3)
+// AS4_PATHより長い、または短いAS_PATHとのマージをテストします(RFC 6793 4.2.3)
+func TestBGPUpdateAS4PathMerge(t *testing.T) {
+	sequence := func(asns ...uint32) BGPASPathSegment {
+		return BGPASPathSegment{Type: BGP_AS_PATH_SEGMENT_AS_SEQUENCE, ASNs: asns}
+	}
+	tests := []struct {
+		name    string
+		asPath  []BGPASPathSegment
+		as4Path []BGPASPathSegment
+		want    string
+	}{
+		{
+			// AS4_PATH を知らない 65010 が先頭に付けた分は AS_PATH から残す
+			name:    "longer AS_PATH",
+			asPath:  []BGPASPathSegment{sequence(65010, 65020, 23456, 65001)},
+			as4Path: []BGPASPathSegment{sequence(200000, 65001)},
+			want:    "65010 65020 200000 65001",
+		},
+		{
+			name:    "longer AS_PATH with an AS_SET",
+			asPath:  []BGPASPathSegment{sequence(65010), {Type: BGP_AS_PATH_SEGMENT_AS_SET, ASNs: []uint32{65100, 65200}}, sequence(23456)},
+			as4Path: []BGPASPathSegment{sequence(200000)},
+			want:    "65010 {65100 65200} 200000",
+		},
+		{
+			// AS_PATH より長い AS4_PATH は不正なので無視する
+			name:    "shorter AS_PATH",
+			asPath:  []BGPASPathSegment{sequence(65001, 23456)},
+			as4Path: []BGPASPathSegment{sequence(65010, 65001, 200000)},
+			want:    "65001 23456",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asPath := (&BGPASPath{Segments: tt.asPath}).Bytes(false)
+			as4Path := (&BGPASPath{Segments: tt.as4Path}).Bytes(true)
+			attrs := append([]byte{BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_TYPE_AS_PATH, byte(len(asPath))}, asPath...)
+			attrs = append(attrs, BGP_ATTR_FLAG_OPTIONAL|BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_TYPE_AS4_PATH, byte(len(as4Path)))
+			attrs = append(attrs, as4Path...)
+
+			path, err := (&BGPUpdate{PathAttributes: attrs}).ASPath(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := path.String(); got != tt.want {
+				t.Errorf("ASPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
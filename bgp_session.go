@@ -0,0 +1,453 @@
+package packemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// BGP_PORT is the TCP port BGP speakers listen on
+	// BGPスピーカーが待ち受けるTCPポートです
+	BGP_PORT = 179
+
+	// BGP_DEFAULT_HOLD_TIME is the hold time BGPSession proposes by default
+	// BGPSessionがデフォルトで提案するホールドタイムです
+	BGP_DEFAULT_HOLD_TIME = 90 * time.Second
+
+	// BGP_OPEN_HOLD_TIME is how long to wait for the peer's OPEN, before a hold time is negotiated (RFC 4271 8.2.2)
+	// ホールドタイムがネゴシエートされる前に、相手のOPENを待つ時間です(RFC 4271 8.2.2)
+	BGP_OPEN_HOLD_TIME = 4 * time.Minute
+
+	// BGP_MAX_MESSAGE_LENGTH is the largest BGP message
+	// BGPメッセージの最大長です
+	BGP_MAX_MESSAGE_LENGTH = 4096
+
+	// BGP_SESSION_UPDATE_BUFFER is the capacity of BGPSession.Updates
+	// BGPSession.Updatesの容量です
+	BGP_SESSION_UPDATE_BUFFER = 64
+
+	bgpWriteTimeout = 5 * time.Second
+)
+
+// BGP NOTIFICATION error codes and the subcodes BGPSession sends (RFC 4271 4.5)
+// BGP NOTIFICATIONのエラーコードと、BGPSessionが送るサブコード(RFC 4271 4.5)
+const (
+	BGP_ERROR_MESSAGE_HEADER     = 1
+	BGP_ERROR_OPEN_MESSAGE       = 2
+	BGP_ERROR_UPDATE_MESSAGE     = 3
+	BGP_ERROR_HOLD_TIMER_EXPIRED = 4
+	BGP_ERROR_FSM                = 5
+	BGP_ERROR_CEASE              = 6
+
+	BGP_ERROR_HEADER_NOT_SYNCHRONIZED  = 1
+	BGP_ERROR_HEADER_BAD_LENGTH        = 2
+	BGP_ERROR_OPEN_UNSUPPORTED_VERSION = 1
+	BGP_ERROR_OPEN_BAD_PEER_AS         = 2
+	BGP_ERROR_OPEN_BAD_HOLD_TIME       = 6
+	BGP_ERROR_UPDATE_INVALID_NETWORK   = 10
+)
+
+// ErrBGPHoldTimerExpired is returned when nothing was received from the peer for the hold time
+// ホールドタイムの間、相手から何も受信しなかった場合に返されます
+var ErrBGPHoldTimerExpired = errors.New("bgp hold timer expired")
+
+// BGPState is a state of the BGP finite state machine (RFC 4271 8.2.2). Active is not used, as BGPSession only connects actively
+// BGPの有限状態機械(RFC 4271 8.2.2)の状態です。BGPSessionは自分から接続するだけなのでActiveは使いません
+type BGPState int32
+
+const (
+	BGP_STATE_IDLE BGPState = iota
+	BGP_STATE_CONNECT
+	BGP_STATE_OPEN_SENT
+	BGP_STATE_OPEN_CONFIRM
+	BGP_STATE_ESTABLISHED
+)
+
+var bgpStateNames = map[BGPState]string{
+	BGP_STATE_IDLE:         "Idle",
+	BGP_STATE_CONNECT:      "Connect",
+	BGP_STATE_OPEN_SENT:    "OpenSent",
+	BGP_STATE_OPEN_CONFIRM: "OpenConfirm",
+	BGP_STATE_ESTABLISHED:  "Established",
+}
+
+func (s BGPState) String() string {
+	if name, ok := bgpStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("BGPState(%d)", int32(s))
+}
+
+// BGPNotificationError is a NOTIFICATION received from the peer, which closes the session
+// 相手から受信したNOTIFICATIONです。セッションは閉じられます
+type BGPNotificationError struct {
+	Code    uint8
+	Subcode uint8
+	Data    []byte
+}
+
+func (e *BGPNotificationError) Error() string {
+	return fmt.Sprintf("bgp notification from peer: code %d, subcode %d", e.Code, e.Subcode)
+}
+
+// BGPRoutes is a received UPDATE with its withdrawn routes and NLRI decoded as prefixes
+// 受信したUPDATEと、その撤回されたルートとNLRIをプレフィックスとして解析したものです
+type BGPRoutes struct {
+	Update    *BGPUpdate
+	Withdrawn []*net.IPNet
+	NLRI      []*net.IPNet
+}
+
+// BGPSession is a minimal BGP speaker for lab testing. It connects to a peer, exchanges OPEN and KEEPALIVE messages up to
+// Established, keeps the session up with KEEPALIVEs and the hold timer, and sends the UPDATEs it receives to Updates.
+// It does not advertise routes or keep a routing table. A session runs once: create another one to reconnect.
+// 検証環境向けの最小限のBGPスピーカーです。相手に接続してEstablishedまでOPENとKEEPALIVEを交換し、KEEPALIVEとホールドタイマーで
+// セッションを維持して、受信したUPDATEをUpdatesに送ります。経路の広告やルーティングテーブルの管理は行いません。
+// セッションは1度だけ動きます。再接続する場合は新しく作成してください
+type BGPSession struct {
+	LocalAS  uint16
+	RouterID uint32
+	// PeerAS is the AS the peer must have in its OPEN. 0 accepts any AS
+	// 相手がOPENで名乗るべきAS。0の場合はどのASも受け入れる
+	PeerAS uint16
+	// HoldTime is the hold time proposed in the OPEN, in whole seconds. 0 disables KEEPALIVEs and the hold timer if the peer agrees.
+	// Otherwise it must be 3 to 65535 seconds (RFC 4271 4.2), or Run fails
+	// OPENで提案するホールドタイム(秒単位)。0の場合は相手も同意すればKEEPALIVEとホールドタイマーを使わない。
+	// それ以外は3から65535秒でなければならず(RFC 4271 4.2)、範囲外の場合Runはエラーになる
+	HoldTime time.Duration
+
+	// Updates receives the UPDATEs from the peer. It is closed when the session ends.
+	// Keep reading it: the session waits for room before reading more from the peer
+	// 相手からのUPDATEを受け取ります。セッションが終わると閉じられます。
+	// 読み続けてください。空きができるまでセッションは相手からの読み込みを待ちます
+	Updates <-chan *BGPRoutes
+
+	updates  chan *BGPRoutes
+	state    atomic.Int32 // BGPState
+	holdTime atomic.Int64 // ネゴシエートしたホールドタイム(time.Duration)
+
+	mu       sync.Mutex // 読み込みのデッドラインとpeerOpen
+	writeMu  sync.Mutex
+	peerOpen *BGPOpen
+}
+
+// NewBGPSession creates a session for localAS with routerID as the BGP identifier
+// routerIDをBGP識別子とする、localASのセッションを作成します
+func NewBGPSession(localAS uint16, routerID net.IP) (*BGPSession, error) {
+	id := routerID.To4()
+	if id == nil {
+		return nil, fmt.Errorf("bgp router id must be an IPv4 address: %s", routerID)
+	}
+	updates := make(chan *BGPRoutes, BGP_SESSION_UPDATE_BUFFER)
+	return &BGPSession{
+		LocalAS:  localAS,
+		RouterID: uint32(id[0])<<24 | uint32(id[1])<<16 | uint32(id[2])<<8 | uint32(id[3]),
+		HoldTime: BGP_DEFAULT_HOLD_TIME,
+		Updates:  updates,
+		updates:  updates,
+	}, nil
+}
+
+// State returns the current state
+// 現在の状態を返します
+func (s *BGPSession) State() BGPState {
+	return BGPState(s.state.Load())
+}
+
+// NegotiatedHoldTime returns the smaller of both hold times, known once the peer's OPEN is received
+// 双方のホールドタイムの小さい方を返します。相手のOPENを受信した後に決まります
+func (s *BGPSession) NegotiatedHoldTime() time.Duration {
+	return time.Duration(s.holdTime.Load())
+}
+
+// PeerOpen returns the OPEN received from the peer, nil before it is received
+// 相手から受信したOPENを返します。受信前はnilです
+func (s *BGPSession) PeerOpen() *BGPOpen {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peerOpen
+}
+
+func (s *BGPSession) setState(state BGPState) {
+	if old := BGPState(s.state.Swap(int32(state))); old != state {
+		logBGPState(old, state)
+	}
+}
+
+// Dial connects to the peer at addr (e.g. "192.0.2.1:179") and runs the session over the connection. See Run
+// addr("192.0.2.1:179"など)の相手に接続し、その接続でセッションを動かします。Runを参照してください
+func (s *BGPSession) Dial(ctx context.Context, addr string) error {
+	s.setState(BGP_STATE_CONNECT)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		s.setState(BGP_STATE_IDLE)
+		close(s.updates)
+		return err
+	}
+	return s.Run(ctx, conn)
+}
+
+// Run runs the session over an established TCP connection until ctx is done, the peer sends a NOTIFICATION
+// (a *BGPNotificationError), the hold timer expires (ErrBGPHoldTimerExpired) or the connection fails.
+// Protocol errors, the hold timer and ctx are reported to the peer with a NOTIFICATION. conn is closed when it returns
+// 確立済みのTCP接続上で、ctxの終了、相手からのNOTIFICATION(*BGPNotificationError)、ホールドタイマーの満了(ErrBGPHoldTimerExpired)、
+// 接続の失敗のいずれかまでセッションを動かします。プロトコルエラー、ホールドタイマー、ctxの終了はNOTIFICATIONで相手に伝えます。終了時にconnを閉じます
+func (s *BGPSession) Run(ctx context.Context, conn net.Conn) error {
+	defer close(s.updates)
+	defer s.setState(BGP_STATE_IDLE)
+	defer conn.Close()
+
+	// ctx の終了で読み込みを止める. デッドラインの設定と競合しないよう mu を取る
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	err := s.run(ctx, conn)
+	if ctx.Err() != nil {
+		s.notify(conn, BGP_ERROR_CEASE, 0)
+		return ctx.Err()
+	}
+	return err
+}
+
+func (s *BGPSession) run(ctx context.Context, conn net.Conn) error {
+	// OPEN のホールドタイムは 16 ビットの秒数で、1 秒と 2 秒は使えない
+	if s.HoldTime != 0 && (s.HoldTime < 3*time.Second || s.HoldTime/time.Second > math.MaxUint16) {
+		return fmt.Errorf("invalid bgp hold time %s: must be 0 or 3 to 65535 seconds", s.HoldTime)
+	}
+	s.setState(BGP_STATE_CONNECT)
+	holdSeconds := uint16(s.HoldTime / time.Second)
+	if err := s.write(conn, NewBGPOpen(s.LocalAS, holdSeconds, s.RouterID, nil)); err != nil {
+		return err
+	}
+	s.setState(BGP_STATE_OPEN_SENT)
+
+	msg, err := s.read(ctx, conn, BGP_OPEN_HOLD_TIME)
+	if err != nil {
+		return err
+	}
+	open := ParsedBGPOpen(msg)
+	if open == nil {
+		return s.unexpected(conn, msg)
+	}
+	if err := s.acceptOpen(conn, open, holdSeconds); err != nil {
+		return err
+	}
+	if err := s.write(conn, NewBGPKeepalive()); err != nil {
+		return err
+	}
+	s.setState(BGP_STATE_OPEN_CONFIRM)
+
+	hold := s.NegotiatedHoldTime()
+	if msg, err = s.read(ctx, conn, hold); err != nil {
+		return err
+	}
+	if msg.Type != BGP_TYPE_KEEPALIVE {
+		return s.unexpected(conn, msg)
+	}
+	s.setState(BGP_STATE_ESTABLISHED)
+
+	if hold > 0 {
+		keepaliveCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go s.sendKeepalives(keepaliveCtx, conn, hold/3)
+	}
+
+	for {
+		msg, err := s.read(ctx, conn, hold)
+		if err != nil {
+			return err
+		}
+		switch msg.Type {
+		case BGP_TYPE_KEEPALIVE:
+			// 受信したことでホールドタイマーは read で再設定される
+		case BGP_TYPE_UPDATE:
+			routes, err := parseBGPRoutes(msg)
+			if err != nil {
+				s.notify(conn, BGP_ERROR_UPDATE_MESSAGE, BGP_ERROR_UPDATE_INVALID_NETWORK)
+				return err
+			}
+			select {
+			case s.updates <- routes:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			return s.unexpected(conn, msg)
+		}
+	}
+}
+
+// 相手の OPEN を確認してホールドタイムを決める
+func (s *BGPSession) acceptOpen(conn net.Conn, open *BGPOpen, holdSeconds uint16) error {
+	s.mu.Lock()
+	s.peerOpen = open
+	s.mu.Unlock()
+
+	if open.Version != 4 {
+		s.notify(conn, BGP_ERROR_OPEN_MESSAGE, BGP_ERROR_OPEN_UNSUPPORTED_VERSION)
+		return fmt.Errorf("unsupported bgp version: %d", open.Version)
+	}
+	if s.PeerAS != 0 && open.MyAutonomousSystem != s.PeerAS {
+		s.notify(conn, BGP_ERROR_OPEN_MESSAGE, BGP_ERROR_OPEN_BAD_PEER_AS)
+		return fmt.Errorf("bgp peer AS is %d, want %d", open.MyAutonomousSystem, s.PeerAS)
+	}
+	// 0 以外で 3 秒未満のホールドタイムは受け入れない
+	if open.HoldTime == 1 || open.HoldTime == 2 {
+		s.notify(conn, BGP_ERROR_OPEN_MESSAGE, BGP_ERROR_OPEN_BAD_HOLD_TIME)
+		return fmt.Errorf("unacceptable bgp hold time: %d seconds", open.HoldTime)
+	}
+	s.holdTime.Store(int64(time.Duration(min(open.HoldTime, holdSeconds)) * time.Second))
+	return nil
+}
+
+func (s *BGPSession) sendKeepalives(ctx context.Context, conn net.Conn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.write(conn, NewBGPKeepalive()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// unexpected は相手からの NOTIFICATION をエラーとして返し、それ以外の状態に合わないメッセージには FSM エラーを通知する
+func (s *BGPSession) unexpected(conn net.Conn, msg *BGP) error {
+	if notification := ParsedBGPNotification(msg); notification != nil {
+		return &BGPNotificationError{Code: notification.ErrorCode, Subcode: notification.ErrorSubcode, Data: notification.Data}
+	}
+	s.notify(conn, BGP_ERROR_FSM, 0)
+	return fmt.Errorf("unexpected bgp message type %d in state %s", msg.Type, s.State())
+}
+
+// read は timeout をホールドタイマーとして次のメッセージを読む. timeout が 0 の場合は待ち続ける
+func (s *BGPSession) read(ctx context.Context, conn net.Conn, timeout time.Duration) (*BGP, error) {
+	s.mu.Lock()
+	if err := ctx.Err(); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetReadDeadline(deadline)
+	s.mu.Unlock()
+
+	msg, err := readBGPMessage(conn)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			s.notify(conn, BGP_ERROR_HOLD_TIMER_EXPIRED, 0)
+			return nil, ErrBGPHoldTimerExpired
+		}
+		var headerErr *bgpHeaderError
+		if errors.As(err, &headerErr) {
+			s.notify(conn, BGP_ERROR_MESSAGE_HEADER, headerErr.subcode)
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *BGPSession) write(conn net.Conn, msg *BGP) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(bgpWriteTimeout))
+	_, err := conn.Write(msg.Bytes())
+	return err
+}
+
+// notify は NOTIFICATION を送る. セッションを閉じる直前なので送信の失敗は無視する
+func (s *BGPSession) notify(conn net.Conn, code uint8, subcode uint8) {
+	s.write(conn, NewBGPNotification(code, subcode, nil))
+}
+
+type bgpHeaderError struct {
+	subcode uint8
+	err     error
+}
+
+func (e *bgpHeaderError) Error() string { return e.err.Error() }
+
+// readBGPMessage reads one message from a BGP byte stream
+// BGPのバイトストリームからメッセージを1つ読み込みます
+func readBGPMessage(r io.Reader) (*BGP, error) {
+	message := make([]byte, 19, BGP_MAX_MESSAGE_LENGTH)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(message[:16], BGP_DEFAULT_MARKER) {
+		return nil, &bgpHeaderError{BGP_ERROR_HEADER_NOT_SYNCHRONIZED, errors.New("bgp marker is not all ones")}
+	}
+	length := int(message[16])<<8 | int(message[17])
+	if length < 19 || length > BGP_MAX_MESSAGE_LENGTH {
+		return nil, &bgpHeaderError{BGP_ERROR_HEADER_BAD_LENGTH, fmt.Errorf("bad bgp message length: %d", length)}
+	}
+	message = message[:length]
+	if _, err := io.ReadFull(r, message[19:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return ParsedBGP(message), nil
+}
+
+func parseBGPRoutes(msg *BGP) (*BGPRoutes, error) {
+	update := ParsedBGPUpdate(msg)
+	if update == nil {
+		return nil, errors.New("malformed bgp update")
+	}
+	withdrawn, err := ParseBGPPrefixes(update.WithdrawnRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("withdrawn routes: %w", err)
+	}
+	nlri, err := ParseBGPPrefixes(update.NetworkLayerReachabilityInfo)
+	if err != nil {
+		return nil, fmt.Errorf("nlri: %w", err)
+	}
+	return &BGPRoutes{Update: update, Withdrawn: withdrawn, NLRI: nlri}, nil
+}
+
+// ParseBGPPrefixes parses IPv4 prefixes encoded as in the NLRI and withdrawn routes of an UPDATE: a length in bits and just enough bytes for it
+// UPDATEのNLRIや撤回されたルートの形式(ビット単位の長さと、それに必要なだけのバイト)のIPv4プレフィックスを解析します
+func ParseBGPPrefixes(data []byte) ([]*net.IPNet, error) {
+	prefixes := []*net.IPNet{}
+	for offset := 0; offset < len(data); {
+		bits := int(data[offset])
+		if bits > 32 {
+			return nil, fmt.Errorf("bgp prefix length %d exceeds 32", bits)
+		}
+		size := (bits + 7) / 8
+		if len(data)-offset-1 < size {
+			return nil, fmt.Errorf("bgp prefix /%d is truncated", bits)
+		}
+		ip := make(net.IP, net.IPv4len)
+		copy(ip, data[offset+1:offset+1+size])
+		mask := net.CIDRMask(bits, 32)
+		prefixes = append(prefixes, &net.IPNet{IP: ip.Mask(mask), Mask: mask})
+		offset += 1 + size
+	}
+	return prefixes, nil
+}
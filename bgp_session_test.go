@@ -0,0 +1,213 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// bgpMockPeer is the other end of a BGPSession over net.Pipe. Messages from the session are read in the background
+// net.Pipeを介したBGPSessionの相手側です。セッションからのメッセージはバックグラウンドで読み込みます
+type bgpMockPeer struct {
+	t        *testing.T
+	conn     net.Conn
+	received chan *BGP
+}
+
+func newBGPMockPeer(t *testing.T, conn net.Conn) *bgpMockPeer {
+	p := &bgpMockPeer{t: t, conn: conn, received: make(chan *BGP, 16)}
+	go func() {
+		defer close(p.received)
+		for {
+			msg, err := readBGPMessage(conn)
+			if err != nil {
+				return
+			}
+			p.received <- msg
+		}
+	}()
+	return p
+}
+
+func (p *bgpMockPeer) send(msg *BGP) {
+	p.t.Helper()
+	if _, err := p.conn.Write(msg.Bytes()); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// expect は KEEPALIVE を読み飛ばさずに次のメッセージが typ であることを確認する
+func (p *bgpMockPeer) expect(typ uint8) *BGP {
+	p.t.Helper()
+	select {
+	case msg, ok := <-p.received:
+		if !ok {
+			p.t.Fatalf("connection closed, want message type %d", typ)
+		}
+		if msg.Type != typ {
+			p.t.Fatalf("message type = %d, want %d", msg.Type, typ)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		p.t.Fatalf("timed out waiting for message type %d", typ)
+	}
+	return nil
+}
+
+func bgpSessionTestStart(t *testing.T, ctx context.Context, session *BGPSession, peerHoldTime uint16) (*bgpMockPeer, chan error) {
+	t.Helper()
+	local, remote := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- session.Run(ctx, local) }()
+
+	peer := newBGPMockPeer(t, remote)
+	open := ParsedBGPOpen(peer.expect(BGP_TYPE_OPEN))
+	if open.MyAutonomousSystem != session.LocalAS || open.BGPIdentifier != 0xc0000201 {
+		t.Errorf("OPEN = %+v", open)
+	}
+	peer.send(NewBGPOpen(65002, peerHoldTime, 0xc0000202, nil))
+	peer.expect(BGP_TYPE_KEEPALIVE)
+	peer.send(NewBGPKeepalive())
+	return peer, done
+}
+
+func waitBGPState(t *testing.T, session *BGPSession, want BGPState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for session.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("State() = %s, want %s", session.State(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestBGPSessionEstablished tests establishing a session with a mock peer, receiving an UPDATE and closing it with a Cease
+// モックの相手とセッションを確立し、UPDATEを受信して、Ceaseで閉じることをテストします
+func TestBGPSessionEstablished(t *testing.T) {
+	session, err := NewBGPSession(65001, net.IPv4(192, 0, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.PeerAS = 65002
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	peer, done := bgpSessionTestStart(t, ctx, session, 30)
+	waitBGPState(t, session, BGP_STATE_ESTABLISHED)
+	if got := session.NegotiatedHoldTime(); got != 30*time.Second {
+		t.Errorf("NegotiatedHoldTime() = %s, want the peer's 30s", got)
+	}
+	if open := session.PeerOpen(); open == nil || open.BGPIdentifier != 0xc0000202 {
+		t.Errorf("PeerOpen() = %+v", open)
+	}
+
+	// 198.51.100.0/24 を撤回し、192.0.2.0/24 と 10.0.0.0/8 を広告する
+	peer.send(NewBGPUpdate([]byte{24, 198, 51, 100}, nil, []byte{24, 192, 0, 2, 8, 10}))
+	select {
+	case routes := <-session.Updates:
+		if len(routes.Withdrawn) != 1 || routes.Withdrawn[0].String() != "198.51.100.0/24" {
+			t.Errorf("Withdrawn = %v", routes.Withdrawn)
+		}
+		if len(routes.NLRI) != 2 || routes.NLRI[0].String() != "192.0.2.0/24" || routes.NLRI[1].String() != "10.0.0.0/8" {
+			t.Errorf("NLRI = %v", routes.NLRI)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no UPDATE received")
+	}
+
+	cancel()
+	notification := ParsedBGPNotification(peer.expect(BGP_TYPE_NOTIFICATION))
+	if notification.ErrorCode != BGP_ERROR_CEASE {
+		t.Errorf("NOTIFICATION code = %d, want Cease", notification.ErrorCode)
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+	if session.State() != BGP_STATE_IDLE {
+		t.Errorf("State() = %s after Run, want Idle", session.State())
+	}
+	if _, ok := <-session.Updates; ok {
+		t.Error("Updates should be closed after Run")
+	}
+}
+
+// TestBGPSessionHoldTimer tests that KEEPALIVEs are sent every third of the hold time and that the session ends when the peer goes silent
+// ホールドタイムの3分の1ごとにKEEPALIVEが送られ、相手が黙るとセッションが終わることをテストします
+func TestBGPSessionHoldTimer(t *testing.T) {
+	session, err := NewBGPSession(65001, net.IPv4(192, 0, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.HoldTime = 3 * time.Second
+
+	peer, done := bgpSessionTestStart(t, context.Background(), session, 90)
+	start := time.Now()
+	peer.expect(BGP_TYPE_KEEPALIVE)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("first KEEPALIVE after %s, want about 1s", elapsed)
+	}
+
+	// 以降は何も送らない
+	for msg := range peer.received {
+		if msg.Type == BGP_TYPE_NOTIFICATION {
+			if code := ParsedBGPNotification(msg).ErrorCode; code != BGP_ERROR_HOLD_TIMER_EXPIRED {
+				t.Errorf("NOTIFICATION code = %d, want Hold Timer Expired", code)
+			}
+			break
+		}
+	}
+	if err := <-done; !errors.Is(err, ErrBGPHoldTimerExpired) {
+		t.Errorf("Run() = %v, want ErrBGPHoldTimerExpired", err)
+	}
+}
+
+// TestBGPSessionNotification tests that a NOTIFICATION from the peer, here rejecting the OPEN, ends the session with a BGPNotificationError
+// 相手からのNOTIFICATION(ここではOPENの拒否)でセッションがBGPNotificationErrorで終わることをテストします
+func TestBGPSessionNotification(t *testing.T) {
+	session, err := NewBGPSession(65001, net.IPv4(192, 0, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, remote := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- session.Run(context.Background(), local) }()
+
+	peer := newBGPMockPeer(t, remote)
+	peer.expect(BGP_TYPE_OPEN)
+	peer.send(NewBGPNotification(BGP_ERROR_OPEN_MESSAGE, BGP_ERROR_OPEN_BAD_PEER_AS, nil))
+
+	var notification *BGPNotificationError
+	if err := <-done; !errors.As(err, &notification) || notification.Code != BGP_ERROR_OPEN_MESSAGE || notification.Subcode != BGP_ERROR_OPEN_BAD_PEER_AS {
+		t.Errorf("Run() = %v, want the peer's NOTIFICATION", err)
+	}
+
+	if _, err := ParseBGPPrefixes([]byte{33, 10, 0, 0, 0, 0}); err == nil {
+		t.Error("a prefix longer than 32 bits should be an error")
+	}
+	if _, err := NewBGPSession(65001, net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("an IPv6 router ID should be an error")
+	}
+}
+
+// TestBGPSessionInvalidHoldTime tests that a hold time the OPEN cannot carry is an error instead of being truncated
+// OPENで運べないホールドタイムが切り詰められずにエラーになることをテストします
+func TestBGPSessionInvalidHoldTime(t *testing.T) {
+	for _, holdTime := range []time.Duration{time.Second, 2 * time.Second, 2999 * time.Millisecond, 65536 * time.Second, 24 * time.Hour} {
+		session, err := NewBGPSession(65001, net.IPv4(192, 0, 2, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		session.HoldTime = holdTime
+		local, remote := net.Pipe()
+		defer remote.Close()
+		if err := session.Run(context.Background(), local); err == nil {
+			t.Errorf("Run() with a hold time of %s returned no error", holdTime)
+		}
+		if _, ok := <-session.Updates; ok {
+			t.Errorf("Updates is not closed after Run() with a hold time of %s", holdTime)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package packemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CaptureDirection selects whether frames received by, sent from, or both are captured.
+// Raw sockets see the frames this host sends as well, so egress includes locally-sent frames such as those from the Generator.
+// 受信したフレーム、送信したフレーム、またはその両方のどれをキャプチャするかを表します。
+// raw socket には自身が送信したフレームも届くため、egressにはGeneratorなどからローカルで送信したフレームも含まれます
+type CaptureDirection int32
+
+const (
+	CAPTURE_DIRECTION_BOTH    CaptureDirection = iota // 両方(デフォルト)
+	CAPTURE_DIRECTION_INGRESS                         // 受信したフレームのみ
+	CAPTURE_DIRECTION_EGRESS                          // 送信したフレームのみ
+)
+
+var captureDirectionNames = map[CaptureDirection]string{
+	CAPTURE_DIRECTION_BOTH:    "both",
+	CAPTURE_DIRECTION_INGRESS: "ingress",
+	CAPTURE_DIRECTION_EGRESS:  "egress",
+}
+
+func (d CaptureDirection) String() string {
+	if name, ok := captureDirectionNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("CaptureDirection(%d)", int32(d))
+}
+
+// allows reports whether a frame of direction should be captured
+func (d CaptureDirection) allows(direction CaptureDirection) bool {
+	return d == CAPTURE_DIRECTION_BOTH || d == direction
+}
+
+// ParseCaptureDirection parses "both", "ingress" or "egress". An empty name is CAPTURE_DIRECTION_BOTH.
+// "both"、"ingress"、"egress"を解析します。空文字はCAPTURE_DIRECTION_BOTHになります
+func ParseCaptureDirection(name string) (CaptureDirection, error) {
+	if name == "" {
+		return CAPTURE_DIRECTION_BOTH, nil
+	}
+	for direction, directionName := range captureDirectionNames {
+		if strings.EqualFold(name, directionName) {
+			return direction, nil
+		}
+	}
+	return CAPTURE_DIRECTION_BOTH, fmt.Errorf("unsupported capture direction: %s", name)
+}
+
+// SetCaptureDirection captures only frames received (CAPTURE_DIRECTION_INGRESS) or sent (CAPTURE_DIRECTION_EGRESS) on the interface,
+// so the other direction is not decoded at all. Only Linux supports a direction other than CAPTURE_DIRECTION_BOTH.
+// インターフェースで受信したフレームのみ、または送信したフレームのみをキャプチャします。もう一方の方向は解析されません。
+// CAPTURE_DIRECTION_BOTH以外はLinuxのみ対応しています
+func (nwif *NetworkInterface) SetCaptureDirection(direction CaptureDirection) error {
+	if _, ok := captureDirectionNames[direction]; !ok {
+		return fmt.Errorf("unsupported capture direction: %s", direction)
+	}
+	if !nwif.offline {
+		if err := nwif.setCaptureDirectionPlatform(direction); err != nil {
+			return err
+		}
+	}
+	nwif.captureDirection.Store(int32(direction))
+	return nil
+}
+
+// CaptureDirection returns the current capture direction
+// 現在のキャプチャの方向を返します
+func (nwif *NetworkInterface) CaptureDirection() CaptureDirection {
+	return CaptureDirection(nwif.captureDirection.Load())
+}
@@ -0,0 +1,185 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// CaptureFilter drops received frames by MAC or IP address before they are decoded, for platforms or setups without BPF.
+// A frame is dropped if its source or destination matches the denylist, or if an allowlist is set and neither address matches it.
+// MAC and IP allowlists apply independently. The zero value lets everything through.
+// BPFを使えない環境向けに、受信したフレームをデコード前にMACアドレスやIPアドレスで破棄します。
+// 送信元か宛先がdenylistに一致する場合、またはallowlistが設定されていてどちらも一致しない場合に破棄します
+type CaptureFilter struct {
+	mu    sync.RWMutex
+	allow captureFilterList
+	deny  captureFilterList
+}
+
+type captureFilterList struct {
+	macs     map[[6]byte]struct{}
+	prefixes []netip.Prefix
+	hosts    map[string][]netip.Addr // ホスト名 -> 解決したアドレス. AllowHost / DenyHost で追加する
+}
+
+// Allow adds entries to the allowlist. An entry is a MAC address ("00:11:22:33:44:55"), an IP address or a CIDR prefix ("10.0.0.0/8").
+// allowlistに追加します。MACアドレス、IPアドレス、CIDR表記のプレフィックスを指定できます
+func (f *CaptureFilter) Allow(entries ...string) error {
+	return f.add(&f.allow, entries)
+}
+
+// Deny adds entries to the denylist. Entries are the same as Allow.
+// denylistに追加します。指定方法はAllowと同じです
+func (f *CaptureFilter) Deny(entries ...string) error {
+	return f.add(&f.deny, entries)
+}
+
+// Reset clears both lists, letting every frame through
+// 両方のリストをクリアし、すべてのフレームを通します
+func (f *CaptureFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allow = captureFilterList{}
+	f.deny = captureFilterList{}
+}
+
+func (f *CaptureFilter) add(list *captureFilterList, entries []string) error {
+	// 途中で失敗した場合に一部だけ反映されないよう、先に全部解析する
+	macs := [][6]byte{}
+	prefixes := []netip.Prefix{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if mac, err := net.ParseMAC(entry); err == nil && len(mac) == 6 {
+			macs = append(macs, [6]byte(mac))
+			continue
+		}
+		prefix, err := parseCaptureFilterPrefix(entry)
+		if err != nil {
+			return fmt.Errorf("invalid capture filter entry: %s", entry)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if list.macs == nil && len(macs) > 0 {
+		list.macs = make(map[[6]byte]struct{}, len(macs))
+	}
+	for _, mac := range macs {
+		list.macs[mac] = struct{}{}
+	}
+	list.prefixes = append(list.prefixes, prefixes...)
+	return nil
+}
+
+func parseCaptureFilterPrefix(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// Allows reports whether a received Ethernet frame should be kept. Only the Ethernet header and the addresses
+// of ARP, IPv4 and IPv6 are looked at, so it is cheap enough to run before decoding.
+// 受信したEthernetフレームを残すかどうかを返します。EthernetヘッダーとARP/IPv4/IPv6のアドレスだけを見るため、デコード前に実行できます
+func (f *CaptureFilter) Allows(frame []byte) bool {
+	if f == nil || len(frame) < 14 {
+		return true
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.allow.empty() && f.deny.empty() {
+		return true
+	}
+
+	dstMAC, srcMAC := [6]byte(frame[0:6]), [6]byte(frame[6:12])
+	if f.deny.hasMAC(srcMAC) || f.deny.hasMAC(dstMAC) {
+		return false
+	}
+	if len(f.allow.macs) > 0 && !f.allow.hasMAC(srcMAC) && !f.allow.hasMAC(dstMAC) {
+		return false
+	}
+
+	if !f.allow.hasIPEntries() && !f.deny.hasIPEntries() {
+		return true
+	}
+	srcIP, dstIP, ok := frameIPAddrs(frame)
+	if !ok {
+		// IP アドレスを持たないフレームは allowlist に一致しようがない
+		return !f.allow.hasIPEntries()
+	}
+	if f.deny.hasIP(srcIP) || f.deny.hasIP(dstIP) {
+		return false
+	}
+	if f.allow.hasIPEntries() && !f.allow.hasIP(srcIP) && !f.allow.hasIP(dstIP) {
+		return false
+	}
+	return true
+}
+
+func (l *captureFilterList) empty() bool {
+	return len(l.macs) == 0 && !l.hasIPEntries()
+}
+
+// ホスト名はまだ解決できていなくても IP アドレスの指定として扱う
+func (l *captureFilterList) hasIPEntries() bool {
+	return len(l.prefixes) > 0 || len(l.hosts) > 0
+}
+
+func (l *captureFilterList) hasMAC(mac [6]byte) bool {
+	_, ok := l.macs[mac]
+	return ok
+}
+
+func (l *captureFilterList) hasIP(addr netip.Addr) bool {
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, addrs := range l.hosts {
+		for _, a := range addrs {
+			if a == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Ethernet ヘッダーの直後にある ARP / IPv4 / IPv6 の送信元・宛先アドレスを返す
+func frameIPAddrs(frame []byte) (src, dst netip.Addr, ok bool) {
+	payload := frame[14:]
+	switch binary.BigEndian.Uint16(frame[12:14]) {
+	case ETHER_TYPE_ARP:
+		// sender protocol address / target protocol address
+		if len(payload) < 28 {
+			return src, dst, false
+		}
+		return netip.AddrFrom4([4]byte(payload[14:18])), netip.AddrFrom4([4]byte(payload[24:28])), true
+	case ETHER_TYPE_IPv4:
+		if len(payload) < 20 {
+			return src, dst, false
+		}
+		return netip.AddrFrom4([4]byte(payload[12:16])), netip.AddrFrom4([4]byte(payload[16:20])), true
+	case ETHER_TYPE_IPv6:
+		if len(payload) < 40 {
+			return src, dst, false
+		}
+		return netip.AddrFrom16([16]byte(payload[8:24])), netip.AddrFrom16([16]byte(payload[24:40])), true
+	}
+	return src, dst, false
+}
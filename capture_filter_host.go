@@ -0,0 +1,128 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DEFAULT_CAPTURE_FILTER_HOST_TTL is how often StartHostResolver resolves the host names again, as their addresses change over time
+// StartHostResolverがホスト名を解決し直す間隔です。アドレスは時間とともに変わるためです
+const DEFAULT_CAPTURE_FILTER_HOST_TTL = time.Minute
+
+// HostResolver looks up the IP addresses of a host name. *net.Resolver satisfies it
+// ホスト名のIPアドレスを問い合わせます。*net.Resolverが満たします
+type HostResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// AllowHost adds host names to the allowlist, matching the IP addresses they resolve to, e.g. to keep only traffic to example.com.
+// Nothing matches a name until ResolveHosts or StartHostResolver resolves it
+// ホスト名をallowlistに追加し、解決したIPアドレスに一致させます。例えばexample.comとの通信だけを残せます。
+// ResolveHostsまたはStartHostResolverで解決するまでは何にも一致しません
+func (f *CaptureFilter) AllowHost(hosts ...string) error {
+	return f.addHosts(&f.allow, hosts)
+}
+
+// DenyHost adds host names to the denylist, matching the IP addresses they resolve to
+// ホスト名をdenylistに追加し、解決したIPアドレスに一致させます
+func (f *CaptureFilter) DenyHost(hosts ...string) error {
+	return f.addHosts(&f.deny, hosts)
+}
+
+func (f *CaptureFilter) addHosts(list *captureFilterList, hosts []string) error {
+	names := []string{}
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+		if host == "" {
+			continue
+		}
+		if strings.ContainsAny(host, " /") {
+			return fmt.Errorf("invalid capture filter host: %s", host)
+		}
+		names = append(names, host)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if list.hosts == nil && len(names) > 0 {
+		list.hosts = make(map[string][]netip.Addr, len(names))
+	}
+	for _, name := range names {
+		if _, ok := list.hosts[name]; !ok {
+			list.hosts[name] = nil
+		}
+	}
+	return nil
+}
+
+// ResolveHosts resolves the host names of both lists and replaces their addresses. If a name fails to resolve,
+// its previous addresses are kept and the error is returned after the other names are resolved
+// 両方のリストのホスト名を解決し、アドレスを置き換えます。解決に失敗した名前は以前のアドレスのままにし、
+// 他の名前を解決した後でエラーを返します
+func (f *CaptureFilter) ResolveHosts(ctx context.Context, resolver HostResolver) error {
+	// 問い合わせの間はロックしない
+	f.mu.RLock()
+	names := map[string]struct{}{}
+	for _, list := range []*captureFilterList{&f.allow, &f.deny} {
+		for name := range list.hosts {
+			names[name] = struct{}{}
+		}
+	}
+	f.mu.RUnlock()
+
+	resolved := map[string][]netip.Addr{}
+	errs := []error{}
+	for name := range names {
+		addrs, err := resolver.LookupNetIP(ctx, "ip", name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to resolve capture filter host %s: %w", name, err))
+			continue
+		}
+		for i := range addrs {
+			// IPv4 アドレスが IPv4-mapped IPv6 アドレスで返ることがある
+			addrs[i] = addrs[i].Unmap()
+		}
+		resolved[name] = addrs
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, list := range []*captureFilterList{&f.allow, &f.deny} {
+		for name, addrs := range resolved {
+			// 問い合わせ中に Reset された名前は戻さない
+			if _, ok := list.hosts[name]; ok {
+				list.hosts[name] = addrs
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StartHostResolver resolves the host names again every ttl until ctx is done, so the filter follows their address changes.
+// Call ResolveHosts first to resolve them right away. Resolution errors are passed to onError, which may be nil
+// ctxが終了するまでttlごとにホスト名を解決し直します。これによりフィルターがアドレスの変化に追従します。
+// すぐに解決するには先にResolveHostsを呼んでください。解決のエラーはonErrorに渡します。onErrorはnilでも構いません
+func (f *CaptureFilter) StartHostResolver(ctx context.Context, resolver HostResolver, ttl time.Duration, onError func(error)) {
+	if ttl <= 0 {
+		ttl = DEFAULT_CAPTURE_FILTER_HOST_TTL
+	}
+
+	go func() {
+		ticker := time.NewTicker(ttl)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := f.ResolveHosts(ctx, resolver); err != nil && onError != nil && ctx.Err() == nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
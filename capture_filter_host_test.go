@@ -0,0 +1,121 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 名前ごとのアドレスを返すスタブ. 登録されていない名前はエラー
+type stubHostResolver struct {
+	mu    sync.Mutex
+	addrs map[string][]netip.Addr
+}
+
+func (r *stubHostResolver) set(host string, addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if addrs == nil {
+		delete(r.addrs, host)
+		return
+	}
+	r.addrs[host] = nil
+	for _, addr := range addrs {
+		r.addrs[host] = append(r.addrs[host], netip.MustParseAddr(addr))
+	}
+}
+
+func (r *stubHostResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return append([]netip.Addr{}, addrs...), nil
+}
+
+// TestCaptureFilterHost tests keeping and dropping frames by the addresses host names resolve to, and following their changes
+// ホスト名を解決したアドレスでフレームを残したり捨てたりすること、およびアドレスの変化に追従することをテストします
+func TestCaptureFilterHost(t *testing.T) {
+	// 192.168.10.1 -> 192.168.10.2
+	toExample := decodeStatsTestUDPFrame(0xd4c0, PORT_HTTP, []byte{0x00})
+	// 192.168.10.1 -> 192.168.10.3
+	toOther := decodeStatsTestUDPFrame(0xd4c0, PORT_HTTP, []byte{0x00})
+	toOther[33] = 3
+
+	resolver := &stubHostResolver{addrs: map[string][]netip.Addr{}}
+	resolver.set("example.com", "::ffff:192.168.10.2", "2001:db8::1") // IPv4-mapped で返す resolver もある
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := &CaptureFilter{}
+	if err := f.AllowHost("Example.com."); err != nil {
+		t.Fatal(err)
+	}
+	if f.Allows(toExample) {
+		t.Error("a host not resolved yet should match nothing")
+	}
+
+	if err := f.ResolveHosts(ctx, resolver); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Allows(toExample) {
+		t.Error("traffic to example.com should be kept")
+	}
+	if f.Allows(toOther) {
+		t.Error("traffic to another host should be dropped")
+	}
+
+	// アドレスが変わったら解決し直した後は新しいアドレスに一致する
+	resolver.set("example.com", "192.168.10.3")
+	if err := f.ResolveHosts(ctx, resolver); err != nil {
+		t.Fatal(err)
+	}
+	if f.Allows(toExample) || !f.Allows(toOther) {
+		t.Error("the filter should follow the new address of example.com")
+	}
+
+	// 解決に失敗した場合は以前のアドレスのまま
+	resolver.set("example.com")
+	if err := f.ResolveHosts(ctx, resolver); err == nil {
+		t.Error("a failed resolution should be an error")
+	}
+	if !f.Allows(toOther) {
+		t.Error("the previous addresses should be kept when resolving fails")
+	}
+
+	// denylist との組み合わせ
+	resolver.set("blocked.example", "192.168.10.3")
+	f.Reset()
+	if err := f.Allow("192.168.10.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DenyHost("blocked.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ResolveHosts(ctx, resolver); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Allows(toExample) || f.Allows(toOther) {
+		t.Error("traffic to the denylisted host should be dropped and the rest of the allowlist kept")
+	}
+
+	// StartHostResolver は TTL ごとに解決し直す
+	f.StartHostResolver(ctx, resolver, 10*time.Millisecond, func(err error) { t.Error(err) })
+	resolver.set("blocked.example", "192.168.10.2")
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Allows(toExample) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f.Allows(toExample) || !f.Allows(toOther) {
+		t.Error("the denylisted host should be resolved again after the TTL")
+	}
+
+	if err := f.AllowHost("bad host"); err == nil {
+		t.Error("an invalid host should be an error")
+	}
+}
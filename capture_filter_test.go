@@ -0,0 +1,77 @@
+package packemon
+
+import "testing"
+
+// TestCaptureFilter tests which frames are kept by the allowlist and denylist
+// allowlistとdenylistによってどのフレームが残るかをテストします
+func TestCaptureFilter(t *testing.T) {
+	// src 00:15:5d:fb:bf:3b 192.168.10.1 -> dst 00:15:5d:fb:bf:3a 192.168.10.2
+	ipv4Frame := decodeStatsTestUDPFrame(0xd4c0, PORT_DNS, []byte{0x00})
+	// ARP request from 00:15:5d:fb:bf:3b 192.168.10.1 for 192.168.10.2
+	arpFrame := frameReaderTestARP()
+
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		frame []byte
+		want  bool
+	}{
+		{name: "no lists", frame: ipv4Frame, want: true},
+		{name: "denylisted source MAC", deny: []string{"00:15:5d:fb:bf:3b"}, frame: ipv4Frame, want: false},
+		{name: "denylisted destination MAC", deny: []string{"00:15:5d:fb:bf:3a"}, frame: ipv4Frame, want: false},
+		{name: "other MAC denylisted", deny: []string{"02:00:00:00:00:01"}, frame: ipv4Frame, want: true},
+		{name: "denylisted source IP", deny: []string{"192.168.10.1"}, frame: ipv4Frame, want: false},
+		{name: "denylisted prefix", deny: []string{"192.168.0.0/16"}, frame: ipv4Frame, want: false},
+		{name: "allowlisted IP", allow: []string{"192.168.10.2"}, frame: ipv4Frame, want: true},
+		{name: "not in IP allowlist", allow: []string{"10.0.0.0/8"}, frame: ipv4Frame, want: false},
+		{name: "not in MAC allowlist", allow: []string{"02:00:00:00:00:01"}, frame: ipv4Frame, want: false},
+		{name: "denylist wins over allowlist", allow: []string{"192.168.10.0/24"}, deny: []string{"192.168.10.1"}, frame: ipv4Frame, want: false},
+		{name: "ARP sender IP denylisted", deny: []string{"192.168.10.1"}, frame: arpFrame, want: false},
+		{name: "ARP target IP allowlisted", allow: []string{"192.168.10.2"}, frame: arpFrame, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &CaptureFilter{}
+			if err := f.Allow(tt.allow...); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Deny(tt.deny...); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Allows(tt.frame); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCaptureFilterUpdate tests that the lists can be changed after the filter is in use
+// 使用中のフィルターのリストを変更できることをテストします
+func TestCaptureFilterUpdate(t *testing.T) {
+	frame := decodeStatsTestUDPFrame(0xd4c0, PORT_DNS, []byte{0x00})
+	f := &CaptureFilter{}
+
+	if !f.Allows(frame) {
+		t.Fatal("frame should be allowed before denying")
+	}
+	if err := f.Deny("00:15:5d:fb:bf:3b"); err != nil {
+		t.Fatal(err)
+	}
+	if f.Allows(frame) {
+		t.Error("frame from a denylisted MAC should be dropped")
+	}
+	f.Reset()
+	if !f.Allows(frame) {
+		t.Error("frame should be allowed after reset")
+	}
+
+	// 不正な指定があれば何も追加しない
+	if err := f.Deny("00:15:5d:fb:bf:3b", "not-an-address"); err == nil {
+		t.Error("invalid entry should be an error")
+	}
+	if !f.Allows(frame) {
+		t.Error("no entry should be added when one is invalid")
+	}
+}
@@ -0,0 +1,162 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// ChecksumResult is the checksum check of one layer
+// 1つのレイヤのチェックサムの検証結果です
+type ChecksumResult struct {
+	Layer    string // "IPv4", "TCP", "UDP", "ICMP", "ICMPv6"
+	Stored   uint16 // パケットに入っている値
+	Computed uint16 // 再計算した正しい値
+	// Unused reports a UDP over IPv4 checksum of 0, which means the sender did not compute one
+	// IPv4上のUDPでチェックサムが0の場合、送信元が計算していないことを表します
+	Unused bool
+}
+
+// Valid reports whether the stored checksum is correct (or unused)
+// 入っているチェックサムが正しい(または未使用)かどうかを返します
+func (r ChecksumResult) Valid() bool {
+	return r.Unused || r.Stored == r.Computed
+}
+
+// ChecksumReport is the checksum check of every layer of a packet, from the lowest layer up
+// パケットの全レイヤのチェックサムの検証結果です。下位のレイヤから順に並びます
+type ChecksumReport []ChecksumResult
+
+// Valid reports whether every checksum in the report is correct
+// 全てのチェックサムが正しいかどうかを返します
+func (r ChecksumReport) Valid() bool {
+	for _, result := range r {
+		if !result.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ChecksumReport) String() string {
+	b := &strings.Builder{}
+	for _, result := range r {
+		fmt.Fprintln(b, result)
+	}
+	return b.String()
+}
+
+// String returns the layer, the stored checksum and whether it is correct, e.g. "TCP     0x1a2b NG (correct: 0x3c4d)"
+// レイヤ、入っているチェックサムとそれが正しいかを返します(例: "TCP     0x1a2b NG (correct: 0x3c4d)")
+func (r ChecksumResult) String() string {
+	switch {
+	case r.Unused:
+		return fmt.Sprintf("%-7s 0x%04x (unused)", r.Layer, r.Stored)
+	case r.Valid():
+		return fmt.Sprintf("%-7s 0x%04x OK", r.Layer, r.Stored)
+	default:
+		return fmt.Sprintf("%-7s 0x%04x NG (correct: 0x%04x)", r.Layer, r.Stored, r.Computed)
+	}
+}
+
+// ValidatePacket recomputes the IPv4 header, TCP/UDP and ICMP/ICMPv6 checksums of a decoded packet and reports whether the stored values match.
+// Layers that cannot be checked, such as fragments or truncated segments, are left out of the report.
+// 解析済みパケットのIPv4ヘッダ、TCP/UDP、ICMP/ICMPv6のチェックサムを再計算し、入っている値と一致するかを返します。
+// フラグメントや途中で切れたセグメントなど検証できないレイヤはレポートに含めません
+func ValidatePacket(passive *Passive) ChecksumReport {
+	report := ChecksumReport{}
+	if passive == nil || passive.EthernetFrame == nil {
+		return report
+	}
+	packet := passive.EthernetFrame.Payload
+
+	switch {
+	case passive.IPv4 != nil:
+		ipv4 := passive.IPv4
+		if len(packet) < int(ipv4.IHL) {
+			return report
+		}
+		report = append(report, layerChecksum("IPv4", nil, packet[:ipv4.IHL], 10))
+
+		// フラグメントは上位層のデータが揃わないので検証できない
+		if ipv4.Flags&0x01 != 0 || ipv4.FragOffset != 0 {
+			return report
+		}
+		payload, ok := upperLayerPayload(ipv4.Payload, int(ipv4.TotalLength)-int(ipv4.IHL))
+		if !ok {
+			return report
+		}
+		pseudoHeader := func(protocol uint8) []byte {
+			b := make([]byte, 0, 12)
+			b = append(b, ipv4.SrcIP...)
+			b = append(b, ipv4.DstIP...)
+			b = append(b, 0x00, protocol)
+			return binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+		}
+
+		switch {
+		case passive.TCP != nil && len(payload) >= 20:
+			report = append(report, layerChecksum("TCP", pseudoHeader(IPv4_PROTO_TCP), payload, 16))
+		case passive.UDP != nil && len(payload) >= 8:
+			result := udpChecksum(pseudoHeader(IPv4_PROTO_UDP), payload)
+			// IPv4 では 0 はチェックサムを使わないことを表す
+			result.Unused = result.Stored == 0
+			report = append(report, result)
+		case passive.ICMP != nil && len(payload) >= 4:
+			report = append(report, layerChecksum("ICMP", nil, payload, 2))
+		}
+
+	case passive.IPv6 != nil:
+		ipv6 := passive.IPv6
+		payload, ok := upperLayerPayload(ipv6.Payload, int(ipv6.PayloadLen))
+		if !ok {
+			return report
+		}
+		pseudoHeader := func(nextHeader uint8) []byte {
+			return BuildIPv6PseudoHeader(ipv6.SrcIP, ipv6.DstIP, uint32(len(payload)), nextHeader)
+		}
+
+		// IPv6 にはヘッダチェックサムがなく、UDP のチェックサムも省略できない
+		switch {
+		case passive.TCP != nil && len(payload) >= 20:
+			report = append(report, layerChecksum("TCP", pseudoHeader(IPv6_NEXT_HEADER_TCP), payload, 16))
+		case passive.UDP != nil && len(payload) >= 8:
+			report = append(report, udpChecksum(pseudoHeader(IPv6_NEXT_HEADER_UDP), payload))
+		case passive.ICMPv6 != nil && len(payload) >= 4:
+			report = append(report, layerChecksum("ICMPv6", pseudoHeader(IPv6_NEXT_HEADER_ICMPv6), payload, 2))
+		}
+	}
+	return report
+}
+
+// IP ヘッダの長さまで切り詰めた上位層のデータを返す(Ethernet のパディングを除く). 途中で切れていれば検証できない
+func upperLayerPayload(payload []byte, length int) ([]byte, bool) {
+	if length < 0 || len(payload) < length {
+		return nil, false
+	}
+	return payload[:length], true
+}
+
+// offset の位置のチェックサムを 0 にして、疑似ヘッダと合わせて計算し直す
+func layerChecksum(layer string, pseudoHeader, data []byte, offset int) ChecksumResult {
+	b := make([]byte, 0, len(pseudoHeader)+len(data))
+	b = append(b, pseudoHeader...)
+	b = append(b, data...)
+	b[len(pseudoHeader)+offset] = 0x00
+	b[len(pseudoHeader)+offset+1] = 0x00
+
+	return ChecksumResult{
+		Layer:    layer,
+		Stored:   binary.BigEndian.Uint16(data[offset : offset+2]),
+		Computed: calculateInternetChecksum(b),
+	}
+}
+
+func udpChecksum(pseudoHeader, data []byte) ChecksumResult {
+	result := layerChecksum("UDP", pseudoHeader, data, 6)
+	// 計算結果が 0 の場合は 0xffff を送る(RFC 768)
+	if result.Computed == 0x0000 {
+		result.Computed = 0xffff
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// IPv4 ヘッダと TCP のチェックサムを正しい値で埋めたフレーム
+func checksumReportTestTCPFrame() []byte {
+	frame := parseDepthTestFrame()
+	ipv4 := frame[14:]
+	tcp := ipv4[20:]
+
+	pseudoHeader := append(append([]byte{}, ipv4[12:20]...), 0x00, IPv4_PROTO_TCP, 0x00, byte(len(tcp)))
+	binary.BigEndian.PutUint16(tcp[16:18], calculateInternetChecksum(append(pseudoHeader, tcp...)))
+	return frame
+}
+
+// ICMPv6 のチェックサムを正しい値で埋めた Echo Request のフレーム
+func checksumReportTestICMPv6Frame() []byte {
+	icmpv6 := []byte{ICMPv6_TYPE_ECHO_REQUEST, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x01, 'p', 'i', 'n', 'g', '!'}
+	src := []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0x02, 0x15, 0x5d, 0xff, 0xfe, 0xfb, 0xbf, 0x3b}
+	dst := []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0x02, 0x15, 0x5d, 0xff, 0xfe, 0xfb, 0xbf, 0x3a}
+
+	pseudoHeader := append(append([]byte{}, src...), dst...)
+	pseudoHeader = append(pseudoHeader, 0x00, 0x00, 0x00, byte(len(icmpv6)), 0x00, 0x00, 0x00, IPv6_NEXT_HEADER_ICMPv6)
+	binary.BigEndian.PutUint16(icmpv6[2:4], calculateInternetChecksum(append(pseudoHeader, icmpv6...)))
+
+	ipv6 := []byte{0x60, 0x00, 0x00, 0x00, 0x00, byte(len(icmpv6)), IPv6_NEXT_HEADER_ICMPv6, 0xff}
+	ipv6 = append(append(append(ipv6, src...), dst...), icmpv6...)
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x86, 0xdd}
+	return append(frame, ipv6...)
+}
+
+// TestValidatePacket tests the per-layer checksum report, including packets with one wrong checksum
+// 1つだけチェックサムが誤ったパケットを含め、レイヤごとのチェックサムの検証結果をテストします
+func TestValidatePacket(t *testing.T) {
+	tcpFrame := checksumReportTestTCPFrame()
+	ipv4Checksum := binary.BigEndian.Uint16(tcpFrame[24:26])
+	tcpChecksum := binary.BigEndian.Uint16(tcpFrame[50:52])
+	icmpv6Frame := checksumReportTestICMPv6Frame()
+	icmpv6Checksum := binary.BigEndian.Uint16(icmpv6Frame[56:58])
+
+	corrupt := func(frame []byte, offset int) []byte {
+		b := append([]byte{}, frame...)
+		b[offset+1] ^= 0xff
+		return b
+	}
+
+	tests := []struct {
+		name      string
+		frame     []byte
+		want      ChecksumReport
+		wantValid bool
+	}{
+		{
+			name:  "all correct",
+			frame: tcpFrame,
+			want: ChecksumReport{
+				{Layer: "IPv4", Stored: ipv4Checksum, Computed: ipv4Checksum},
+				{Layer: "TCP", Stored: tcpChecksum, Computed: tcpChecksum},
+			},
+			wantValid: true,
+		},
+		{
+			name:  "wrong tcp checksum",
+			frame: corrupt(tcpFrame, 50),
+			want: ChecksumReport{
+				{Layer: "IPv4", Stored: ipv4Checksum, Computed: ipv4Checksum},
+				{Layer: "TCP", Stored: tcpChecksum ^ 0x00ff, Computed: tcpChecksum},
+			},
+		},
+		{
+			name:  "wrong ipv4 header checksum",
+			frame: corrupt(tcpFrame, 24),
+			want: ChecksumReport{
+				{Layer: "IPv4", Stored: ipv4Checksum ^ 0x00ff, Computed: ipv4Checksum},
+				{Layer: "TCP", Stored: tcpChecksum, Computed: tcpChecksum},
+			},
+		},
+		{
+			// Ethernet の最小長に満たすためのパディングは計算に含めない
+			name:  "ethernet padding",
+			frame: append(append([]byte{}, tcpFrame...), 0x00, 0x00, 0x00, 0x00),
+			want: ChecksumReport{
+				{Layer: "IPv4", Stored: ipv4Checksum, Computed: ipv4Checksum},
+				{Layer: "TCP", Stored: tcpChecksum, Computed: tcpChecksum},
+			},
+			wantValid: true,
+		},
+		{
+			name:  "icmpv6",
+			frame: icmpv6Frame,
+			want: ChecksumReport{
+				{Layer: "ICMPv6", Stored: icmpv6Checksum, Computed: icmpv6Checksum},
+			},
+			wantValid: true,
+		},
+		{
+			name:  "wrong icmpv6 checksum",
+			frame: corrupt(icmpv6Frame, 56),
+			want: ChecksumReport{
+				{Layer: "ICMPv6", Stored: icmpv6Checksum ^ 0x00ff, Computed: icmpv6Checksum},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passive, err := DecodeFrame(tt.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ValidatePacket(passive)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ValidatePacket() = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("ValidatePacket()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			if got.Valid() != tt.wantValid {
+				t.Errorf("Valid() = %v, want %v\n%s", got.Valid(), tt.wantValid, got)
+			}
+		})
+	}
+}
+
+// TestValidatePacketUnusedUDPChecksum tests that a zero UDP checksum over IPv4 is reported as unused
+// IPv4上のUDPのチェックサム0が未使用として扱われることをテストします
+func TestValidatePacketUnusedUDPChecksum(t *testing.T) {
+	passive, err := DecodeFrame(decodeStatsTestUDPFrame(0xd4c0, 9999, []byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := ValidatePacket(passive)
+	if len(report) != 2 || report[1].Layer != "UDP" || !report[1].Unused {
+		t.Fatalf("ValidatePacket() = %+v, want an unused UDP checksum", report)
+	}
+	// IPv4 ヘッダのチェックサムは 0 のままなので誤り
+	if report.Valid() {
+		t.Errorf("Valid() = true, want false for a zero IPv4 header checksum\n%s", report)
+	}
+}
+
+// TestChecksumReportString tests the line of each result
+// 各結果の行をテストします
+func TestChecksumReportString(t *testing.T) {
+	report := ChecksumReport{
+		{Layer: "IPv4", Stored: 0xb861, Computed: 0xb861},
+		{Layer: "TCP", Stored: 0x1a2b, Computed: 0x3c4d},
+		{Layer: "UDP", Stored: 0x0000, Computed: 0x1234, Unused: true},
+	}
+	want := "IPv4    0xb861 OK\n" +
+		"TCP     0x1a2b NG (correct: 0x3c4d)\n" +
+		"UDP     0x0000 (unused)\n"
+	if got := report.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
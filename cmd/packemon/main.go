@@ -1,12 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/ddddddO/packemon"
@@ -14,6 +23,7 @@ import (
 	"github.com/ddddddO/packemon/internal/tui"
 	"github.com/ddddddO/packemon/internal/tui/generator"
 	"github.com/ddddddO/packemon/internal/tui/monitor"
+	"github.com/ddddddO/packemon/internal/tui/statistics"
 	tc "github.com/ddddddO/packemon/tc_program"
 )
 
@@ -58,12 +68,117 @@ func main() {
 	var debug bool
 	flag.BoolVar(&debug, "debug", false, "Debugging mode.")
 	var protocol string
-	flag.StringVar(&protocol, "proto", "", "Specify either 'arp', 'icmp', 'tcp', 'dns' or 'http'.")
+	flag.StringVar(&protocol, "proto", "", "Specify either 'arp', 'icmp', 'tcp', 'dns', 'dhcp', 'mdns' or 'http'.")
+	var decodeAs string
+	flag.StringVar(&decodeAs, "decode-as", "", "Decode traffic on the given ports as the given protocol, e.g. '8443:tls,5353:dns,5004:rtp'.")
+	var parseDepth string
+	flag.StringVar(&parseDepth, "parse-depth", "", "Decode received packets only down to 'ethernet', 'network', 'transport' or 'application'. Default is full depth.")
+	var direction string
+	flag.StringVar(&direction, "direction", "", "Capture only 'ingress' (received) or 'egress' (sent, including frames sent by packemon) frames. Linux only. Default is both.")
+	var sendMethod string
+	flag.StringVar(&sendMethod, "send-method", "", "Send frames by writing to /dev/bpf directly with 'bpf', if pcap does not inject them at layer 2. macOS only. Default is pcap on macOS and a raw socket on Linux.")
+	var fcs string
+	flag.StringVar(&fcs, "fcs", "", "Captured Ethernet frames end with the FCS: 'strip' it before decoding or 'validate' it as well. Also applies to -stdin. Default is 'none'.")
+	var parseWorkers int
+	flag.IntVar(&parseWorkers, "parse-workers", 1, "Decode received frames on the given number of goroutines, keeping the order of packets within each flow. Use more than 1 at high capture rates.")
+	var dedup bool
+	flag.BoolVar(&dedup, "dedup", false, "Drop received frames captured twice, e.g. on a SPAN or mirror port, before they are shown or counted.")
+	var dedupWindow time.Duration
+	flag.DurationVar(&dedupWindow, "dedup-window", packemon.DEFAULT_DEDUP_WINDOW, "How long a received frame is remembered to drop its copies with -dedup.")
+	var snapLen int
+	flag.IntVar(&snapLen, "snaplen", 0, fmt.Sprintf("Keep only the first given bytes of each received frame. Default is %d.", packemon.DEFAULT_SNAPLEN))
+	var allow string
+	flag.StringVar(&allow, "allow", "", "Keep only received frames from or to the given MAC addresses, IP addresses or CIDR prefixes, e.g. '00:15:5d:fb:bf:3a,192.168.10.0/24'.")
+	var deny string
+	flag.StringVar(&deny, "deny", "", "Drop received frames from or to the given MAC addresses, IP addresses or CIDR prefixes.")
+	var allowHost string
+	flag.StringVar(&allowHost, "allow-host", "", fmt.Sprintf("Keep only received frames from or to the addresses of the given host names, e.g. 'example.com'. They are resolved again every %s.", packemon.DEFAULT_CAPTURE_FILTER_HOST_TTL))
+	var denyHost string
+	flag.StringVar(&denyHost, "deny-host", "", "Drop received frames from or to the addresses of the given host names.")
+	var listProtocols bool
+	flag.BoolVar(&listProtocols, "protocols", false, "List supported protocols and exit.")
+	var readStdin bool
+	flag.BoolVar(&readStdin, "stdin", false, "Read length-prefixed frames from stdin, print them and exit. Each frame is a 4 byte big-endian length followed by the frame. A pcap file, e.g. from 'tcpdump -w -', is read as well, with the link type of its header.")
+	var linkType int
+	flag.IntVar(&linkType, "linktype", packemon.PCAP_LINKTYPE_ETHERNET, fmt.Sprintf("Link type of the frames captured or read with -stdin: %d (Ethernet), %d (802.11) or %d (802.11 with radiotap, e.g. a Wi-Fi interface in monitor mode). -stdin also reads %d (raw IP).", packemon.PCAP_LINKTYPE_ETHERNET, packemon.PCAP_LINKTYPE_IEEE802_11, packemon.PCAP_LINKTYPE_IEEE802_11_RADIOTAP, packemon.PCAP_LINKTYPE_RAW))
+	var offline bool
+	flag.BoolVar(&offline, "offline", false, "Run without capturing, e.g. without root. Packets can be built in Generator mode but not sent.")
+	var asJSON bool
+	flag.BoolVar(&asJSON, "json", false, fmt.Sprintf("Print the frames read with -stdin as JSON lines (schema version %d).", packemon.PASSIVE_JSON_SCHEMA_VERSION))
 
+	var asCBOR bool
+	flag.BoolVar(&asCBOR, "cbor", false, "Print the frames read with -stdin as a CBOR sequence with the same fields as -json.")
+	var redact string
+	flag.StringVar(&redact, "redact", "", "Leave layers or fields out of -json and -cbor, e.g. 'payload,tls,http.headers'. 'payload' drops the payloads of every layer and keeps the headers.")
+	var recoverDecode bool
+	flag.BoolVar(&recoverDecode, "recover", false, "Keep decoding past a layer that fails to parse, guessing its header. The failure is shown as an error of the packet.")
+
+	var dnsTCPReassembly bool
+	flag.BoolVar(&dnsTCPReassembly, "dns-tcp-reassembly", false, "Complete DNS over TCP messages split across segments, keeping their start per connection until the next segment.")
+
+	var selfTest bool
+	flag.BoolVar(&selfTest, "selftest", false, "Send an ICMP echo from the interface to itself, check that it is captured and decoded, and exit. Exits with 1 on failure.")
+
+	var statsInterval time.Duration
+	flag.DurationVar(&statsInterval, "stats-interval", 0, "Run headless without the TUI, writing a summary of the captured traffic to stdout at the given interval, e.g. '10s'.")
+	var statsFormat string
+	flag.StringVar(&statsFormat, "stats-format", statistics.REPORT_FORMAT_TEXT, "Format of the -stats-interval summaries: 'text' or 'json' (one JSON object per line).")
+	var rateThreshold float64
+	flag.Float64Var(&rateThreshold, "rate-threshold", statistics.DEFAULT_SOURCE_RATE_THRESHOLD, "Packets per second over a 10s window above which a source IP is flagged in the -stats-interval summaries.")
+
+	var flowExport string
+	flag.StringVar(&flowExport, "flow-export", "", "Run headless without the TUI, aggregating the captured traffic into flows and sending them as IPFIX over UDP to the collector, e.g. 'collector:4739'.")
+	var flowActiveTimeout time.Duration
+	flag.DurationVar(&flowActiveTimeout, "flow-active-timeout", packemon.DEFAULT_FLOW_ACTIVE_TIMEOUT, "How often the record of a long flow is sent with -flow-export, even while it is active.")
+
+	var arpDefense bool
+	flag.BoolVar(&arpDefense, "arp-defense", false, "Run headless without the TUI, broadcasting a corrective gratuitous ARP when another MAC address claims an IP address listed in the 'arpDefense' of the config. Sends ARP on behalf of the protected hosts.")
+
+	var sessionName string
+	flag.StringVar(&sessionName, "session", "", "Re-open an analysis session saved under ~/.packemon/sessions with Ctrl-S in the Monitor: its interface (unless -interface is given), display filter, Decode As overrides, coloring rules, and the packets and notes of its capture.")
 	flag.Parse()
 
+	packemon.SetDecodeRecovery(recoverDecode)
+	packemon.SetDNSOverTCPReassembly(dnsTCPReassembly)
+
+	if listProtocols {
+		printSupportedProtocols(os.Stdout)
+		return
+	}
+
+	if readStdin {
+		if err := printFrames(os.Stdin, os.Stdout, linkType, fcs, asJSON, asCBOR, redact); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var session *packemon.Session
+	if len(sessionName) != 0 {
+		var err error
+		if session, err = loadSession(sessionName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(session.Interface) != 0 && !flagPassed("interface") {
+			nwInterface = session.Interface
+		}
+	}
+
+	if selfTest {
+		passed, err := runSelfTest(os.Stdout, nwInterface)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+
 	var ingressMap, egressMap *ebpf.Map
-	if wantSend {
+	if wantSend && !offline {
 		ebpfObjs, err := tc.InitializeTCProgram()
 		if err != nil {
 			// error出力するが、処理は進める
@@ -108,35 +223,138 @@ func main() {
 		}
 	}
 
-	if err := run(ctx, columns, nwInterface, wantSend, debug, protocol, ingressMap, egressMap); err != nil {
+	if !dedup {
+		dedupWindow = 0
+	}
+
+	if err := run(ctx, columns, nwInterface, wantSend, offline, debug, protocol, decodeAs, parseDepth, direction, sendMethod, fcs, linkType, snapLen, parseWorkers, dedupWindow, allow, deny, allowHost, denyHost, statsInterval, statsFormat, rateThreshold, flowExport, flowActiveTimeout, arpDefense, session, ingressMap, egressMap); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, packemon.ErrCapturePermission) {
+			fmt.Fprintln(os.Stderr, "Use --offline to build packets without sending them, or --stdin to decode captured frames.")
+		}
 		return
 	}
 }
 
-func run(ctx context.Context, columns string, nwInterface string, wantSend bool, debug bool, protocol string, ingressMap *ebpf.Map, egressMap *ebpf.Map) error {
-	netIf, err := packemon.NewNetworkInterface(nwInterface)
+func run(ctx context.Context, columns string, nwInterface string, wantSend bool, offline bool, debug bool, protocol string, decodeAs string, parseDepth string, direction string, sendMethod string, fcs string, linkType int, snapLen int, parseWorkers int, dedupWindow time.Duration, allow string, deny string, allowHost string, denyHost string, statsInterval time.Duration, statsFormat string, rateThreshold float64, flowExport string, flowActiveTimeout time.Duration, arpDefense bool, session *packemon.Session, ingressMap *ebpf.Map, egressMap *ebpf.Map) error {
+	var netIf *packemon.NetworkInterface
+	if offline {
+		netIf = packemon.NewOfflineNetworkInterface(nwInterface)
+	} else {
+		var err error
+		if netIf, err = packemon.NewNetworkInterface(nwInterface); err != nil {
+			return err
+		}
+	}
+	defer netIf.Close()
+
+	cfg, err := packemon.LoadConfig()
 	if err != nil {
+		// error出力するが、処理は進める
+		fmt.Fprintln(os.Stderr, err)
+		cfg = packemon.DefaultConfig()
+	}
+
+	// 設定ファイル、セッションの順に保存された Decode As を適用し、--decode-as の指定で上書きする
+	if err := cfg.ApplyDecodeAs(netIf); err != nil {
 		return err
 	}
-	defer netIf.Close()
+	if session != nil {
+		if err := session.ApplyDecodeAs(netIf); err != nil {
+			return err
+		}
+	}
+	overrides, err := packemon.ParseDecodeAs(decodeAs)
+	if err != nil {
+		return err
+	}
+	for port, proto := range overrides {
+		if err := netIf.DecodeAs(port, proto); err != nil {
+			return err
+		}
+	}
+
+	depth, err := packemon.ParseParseDepth(parseDepth)
+	if err != nil {
+		return err
+	}
+	netIf.SetParseDepth(depth)
+	netIf.SetParseWorkers(parseWorkers)
+	netIf.SetDeduplication(dedupWindow)
+	captureDirection, err := packemon.ParseCaptureDirection(direction)
+	if err != nil {
+		return err
+	}
+	if err := netIf.SetCaptureDirection(captureDirection); err != nil {
+		return err
+	}
+	method, err := packemon.ParseSendMethod(sendMethod)
+	if err != nil {
+		return err
+	}
+	if err := netIf.SetSendMethod(method); err != nil {
+		return err
+	}
+	fcsMode, err := packemon.ParseFCSMode(fcs)
+	if err != nil {
+		return err
+	}
+	if err := netIf.SetFCSMode(fcsMode); err != nil {
+		return err
+	}
+	if err := netIf.SetLinkType(linkType); err != nil {
+		return err
+	}
+	if err := netIf.SetSnapLen(snapLen); err != nil {
+		return err
+	}
+
+	if err := netIf.CaptureFilter().Allow(strings.Split(allow, ",")...); err != nil {
+		return err
+	}
+	if err := netIf.CaptureFilter().Deny(strings.Split(deny, ",")...); err != nil {
+		return err
+	}
+	if err := netIf.CaptureFilter().AllowHost(strings.Split(allowHost, ",")...); err != nil {
+		return err
+	}
+	if err := netIf.CaptureFilter().DenyHost(strings.Split(denyHost, ",")...); err != nil {
+		return err
+	}
+	if len(allowHost) != 0 || len(denyHost) != 0 {
+		// 起動時に解決できない名前はエラーにする. その後の失敗は以前のアドレスのまま続ける
+		if err := netIf.CaptureFilter().ResolveHosts(ctx, net.DefaultResolver); err != nil {
+			return err
+		}
+		netIf.CaptureFilter().StartHostResolver(ctx, net.DefaultResolver, packemon.DEFAULT_CAPTURE_FILTER_HOST_TTL, nil)
+	}
 
 	if len(nwInterface) != 0 {
 		generator.DEFAULT_NW_INTERFACE = nwInterface
 	}
-	generator.DEFAULT_MAC_SOURCE = fmt.Sprintf("0x%s", strings.ReplaceAll(netIf.Intf.HardwareAddr.String(), ":", ""))
-	generator.DEFAULT_ARP_SENDER_MAC = generator.DEFAULT_MAC_SOURCE
 
-	ipAddr, err := netIf.Intf.Addrs()
-	if err != nil {
+	// 設定ファイルの値を先に反映し、"auto" の送信元だけインターフェースから決める
+	defaultPackets := cfg.DefaultPackets
+	if err := generator.ApplyDefaultPackets(defaultPackets); err != nil {
 		return err
 	}
-	if len(ipAddr) > 0 {
-		generator.DEFAULT_IP_SOURCE = strings.Split(ipAddr[0].String(), "/")[0]
-		generator.DEFAULT_ARP_SENDER_IP = generator.DEFAULT_IP_SOURCE
+
+	if defaultPackets.IsAuto(defaultPackets.SourceMAC) {
+		generator.DEFAULT_MAC_SOURCE = fmt.Sprintf("0x%s", strings.ReplaceAll(netIf.Intf.HardwareAddr.String(), ":", ""))
+		generator.DEFAULT_ARP_SENDER_MAC = generator.DEFAULT_MAC_SOURCE
+	}
+
+	// 宛先に合わせて送信元アドレスを選ぶ
+	if defaultPackets.IsAuto(defaultPackets.SourceIP) {
+		if srcIP, err := netIf.SelectSourceAddress(net.ParseIP(generator.DEFAULT_IP_DESTINATION)); err == nil {
+			generator.DEFAULT_IP_SOURCE = srcIP.String()
+			generator.DEFAULT_ARP_SENDER_IP = generator.DEFAULT_IP_SOURCE
+		}
 	}
-	if len(ipAddr) > 1 {
-		generator.DEFAULT_IPv6_SOURCE = strings.Split(ipAddr[1].String(), "/")[0]
+	if defaultPackets.IsAuto(defaultPackets.SourceIPv6) {
+		if srcIPv6, err := netIf.SelectSourceAddress(net.ParseIP(generator.DEFAULT_IPv6_DESTINATION)); err == nil {
+			generator.DEFAULT_IPv6_SOURCE = srcIPv6.String()
+		}
 	}
 
 	if debug {
@@ -169,13 +387,180 @@ func run(ctx context.Context, columns string, nwInterface string, wantSend bool,
 		return debugPrint(ctx, netIf.PassiveCh)
 	}
 
-	var packemonTUI tui.TUI = monitor.New(netIf, columns)
+	if arpDefense {
+		return defendARP(ctx, netIf, cfg.ARPDefense)
+	}
+
+	if len(flowExport) != 0 {
+		return exportFlows(ctx, netIf, flowExport, flowActiveTimeout)
+	}
+
+	if statsInterval > 0 {
+		classifier, err := cfg.GetTrafficClassifier()
+		if err != nil {
+			return err
+		}
+		return reportStatistics(ctx, netIf, classifier, statsInterval, statsFormat, rateThreshold)
+	}
+
+	var coloringRules *packemon.ColoringRules
+	if session != nil {
+		coloringRules, err = session.GetColoringRules(cfg)
+	} else {
+		coloringRules, err = cfg.GetColoringRules()
+	}
+	if err != nil {
+		return err
+	}
+	var displayFilter *packemon.DisplayFilter
+	if session != nil && len(session.Filter) != 0 {
+		if displayFilter, err = packemon.CompileDisplayFilter(session.Filter); err != nil {
+			return err
+		}
+	}
+	theme, err := cfg.GetTheme()
+	if err != nil {
+		return err
+	}
+	// 各ビューを作る前にテーマを tview のデフォルトのスタイルへ反映する
+	tui.ApplyTheme(theme)
+	m := monitor.New(netIf, columns)
+	m.SetColoringRules(coloringRules)
+	m.SetDisplayFilter(displayFilter)
+	m.SetTheme(theme)
+	m.SetSession(cfg, session)
+	if session != nil {
+		packets, annotations, err := session.OpenCapture()
+		if err != nil {
+			return err
+		}
+		m.LoadPackets(packets, annotations)
+	}
+
+	var packemonTUI tui.TUI = m
 	if wantSend {
 		packemonTUI = generator.New(netIf, ingressMap, egressMap)
 	}
 	return packemonTUI.Run(ctx)
 }
 
+// 保存したセッションを読み込む. 設定ファイルの読み込みに失敗しても、エラーは run で出力するのでここでは無視する
+func loadSession(name string) (*packemon.Session, error) {
+	cfg, err := packemon.LoadConfig()
+	if err != nil {
+		cfg = packemon.DefaultConfig()
+	}
+	return cfg.LoadSession(name)
+}
+
+// コマンドラインで明示的に指定されたフラグか
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// 端末なしで受信したパケットを集計し、統計の要約を定期的に標準出力へ書き込む. SIGINT/SIGTERM で最後の要約を書いて終わる
+func reportStatistics(ctx context.Context, netIf *packemon.NetworkInterface, classifier *packemon.TrafficClassifier, interval time.Duration, format string, rateThreshold float64) error {
+	stats := statistics.NewStatistics()
+	stats.SetTrafficClassifier(classifier)
+	stats.SetSourceRateThreshold(rateThreshold, 0)
+	reporter, err := statistics.NewStatisticsReporter(stats, interval, format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reportErr := make(chan error, 1)
+	go func() {
+		reportErr <- reporter.Run(ctx)
+	}()
+	err = netIf.Capture(ctx, 0, 0, func(passive *packemon.Passive) error {
+		stats.ProcessPacket(passive)
+		return nil
+	})
+	// キャプチャが止まったらレポートも止める
+	cancel()
+	if rErr := <-reportErr; err == nil || errors.Is(err, context.Canceled) {
+		err = rErr
+	}
+	return err
+}
+
+// 端末なしで受信したパケットをフローに集計し、IPFIX で collector へ送る. SIGINT/SIGTERM で残りのフローを送って終わる
+func exportFlows(ctx context.Context, netIf *packemon.NetworkInterface, collector string, activeTimeout time.Duration) error {
+	conn, err := net.Dial("udp", collector)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	enc := packemon.NewIPFIXEncoder(conn, 0)
+	exporter := packemon.NewFlowExporter(func(records []packemon.FlowRecord) {
+		// collector が落ちていても集計は続ける
+		if err := enc.Encode(records); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	exporter.SetTimeouts(packemon.DEFAULT_FLOW_IDLE_TIMEOUT, activeTimeout)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx, time.Second)
+		close(done)
+	}()
+	err = netIf.Capture(ctx, 0, 0, func(passive *packemon.Passive) error {
+		exporter.Update(passive)
+		return nil
+	})
+	// キャプチャが止まったら残りのフローを送る
+	cancel()
+	<-done
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return err
+}
+
+// 端末なしで ARP を監視し、保護するアドレスを別の MAC アドレスが名乗ったら訂正の Gratuitous ARP を送る. SIGINT/SIGTERM で終わる
+func defendARP(ctx context.Context, netIf *packemon.NetworkInterface, entries []packemon.ARPDefenseEntry) error {
+	defender, err := packemon.NewARPDefender(netIf, entries)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = netIf.Capture(ctx, 0, 0, func(passive *packemon.Passive) error {
+		sent, err := defender.Handle(ctx, passive)
+		if err != nil {
+			// 送信に失敗しても監視は続ける
+			fmt.Fprintln(os.Stderr, err)
+		} else if sent {
+			fmt.Printf("%s: sent corrective ARP for %s\n", passive.Timestamp.Format(time.RFC3339), net.IP(passive.ARP.SenderIP))
+		}
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return err
+}
+
 func debugPrint(ctx context.Context, passive <-chan *packemon.Passive) error {
 	for {
 		select {
@@ -210,6 +595,31 @@ func debugMode(wantSend bool, protocol string, netIf *packemon.NetworkInterface,
 			return debugNetIf.SendTCP3wayAndTLShandshake(dstMacAddr)
 		case "https-get":
 			return debugNetIf.SendHTTPSGetAfterTCP3wayAndTLShandshake(dstMacAddr)
+		case "dhcp":
+			lease, err := packemon.NewDHCPClient(netIf).Lease(context.Background())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Leased %s/%s from %s for %s\nRouters: %v\nDNS: %v\n", lease.Address, net.IP(lease.SubnetMask), lease.ServerID, lease.LeaseTime, lease.Routers, lease.DNSServers)
+			return nil
+		case "mdns":
+			// 応答が揃うまで数秒ブラウズして、見つけたサービスを表にする
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			browser := packemon.NewServiceBrowser(netIf)
+			if err := browser.Browse(ctx); err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tTYPE\tHOST\tPORT\tADDRESSES\tTXT")
+			for _, service := range browser.Services() {
+				txt := make([]string, 0, len(service.TXT))
+				for _, key := range slices.Sorted(maps.Keys(service.TXT)) {
+					txt = append(txt, key+"="+service.TXT[key])
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%v\t%s\n", service.Name(), service.Type, service.Host, service.Port, service.Addresses, strings.Join(txt, " "))
+			}
+			return tw.Flush()
 		case "http":
 			var srcPort uint16 = 0x9e98
 			var dstPort uint16 = 0x0050       // 80
@@ -225,3 +635,135 @@ func debugMode(wantSend bool, protocol string, netIf *packemon.NetworkInterface,
 
 	return debugNetIf.Recieve()
 }
+
+// 生成・解析できるプロトコルの一覧を表形式で出力する
+func printSupportedProtocols(w io.Writer) {
+	mark := func(b bool) string {
+		if b {
+			return "x"
+		}
+		return ""
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tLABEL\tCATEGORY\tLAYER\tETHER TYPE\tIP PROTO\tPORTS\tDECODE AS\tPARSE\tGENERATE")
+	for _, p := range packemon.SupportedProtocols() {
+		etherType, ipProto := "", ""
+		if p.EtherType != 0 {
+			etherType = fmt.Sprintf("0x%04x", p.EtherType)
+		}
+		if p.IPProtocol != 0 {
+			ipProto = fmt.Sprintf("%d", p.IPProtocol)
+		}
+		ports := make([]string, len(p.Ports))
+		for i, port := range p.Ports {
+			ports[i] = fmt.Sprintf("%d", port)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Name, p.Label, p.Category, p.Layer, etherType, ipProto, strings.Join(ports, ","), p.DecodeAs, mark(p.Parse), mark(p.Generate))
+	}
+	tw.Flush()
+}
+
+// インターフェースから自身宛にエコーを送り、キャプチャして解析できるかを確認する
+func runSelfTest(w io.Writer, nwInterface string) (bool, error) {
+	netIf, err := packemon.NewNetworkInterface(nwInterface)
+	if err != nil {
+		return false, err
+	}
+	defer netIf.Close()
+
+	result := netIf.SelfTest(context.Background())
+	fmt.Fprintln(w, result)
+	return result.Passed, nil
+}
+
+// 標準入力などから長さ付きのフレームまたは pcap ファイルを読み、1行ずつ最上位のレイヤを出力する.
+// pcap のマジックナンバーは長さとしては大きすぎるので、先頭の 4 バイトで区別できる
+func printFrames(r io.Reader, w io.Writer, linkType int, fcs string, asJSON bool, asCBOR bool, redact string) error {
+	fcsMode, err := packemon.ParseFCSMode(fcs)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReader(r)
+	var fr packemon.PacketReader
+	if magic, _ := br.Peek(4); packemon.IsPcapMagic(magic) {
+		pr, err := packemon.NewPcapReader(br)
+		if err != nil {
+			return err
+		}
+		if !packemon.LinkTypeSupported(pr.LinkType()) {
+			fmt.Fprintf(os.Stderr, "link type %d is not supported, packets are not decoded\n", pr.LinkType())
+		}
+		pr.SetFCSMode(fcsMode)
+		fr = pr
+	} else {
+		frameReader, err := packemon.OpenReader(br, linkType)
+		if err != nil {
+			return err
+		}
+		frameReader.SetFCSMode(fcsMode)
+		fr = frameReader
+	}
+	redaction, err := packemon.ParseRedaction(redact)
+	if err != nil {
+		return err
+	}
+	enc := packemon.NewJSONEncoder(w)
+	enc.SetRedaction(redaction)
+	cborEnc := packemon.NewCBOREncoder(w)
+	cborEnc.SetRedaction(redaction)
+	for i := 1; ; i++ {
+		p, err := fr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		if asJSON {
+			if err := enc.Encode(p); err != nil {
+				return fmt.Errorf("frame %d: %w", i, err)
+			}
+			continue
+		}
+		if asCBOR {
+			if err := cborEnc.Encode(p); err != nil {
+				return fmt.Errorf("frame %d: %w", i, err)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\n", i, highestLayer(p))
+	}
+}
+
+func highestLayer(p *packemon.Passive) fmt.Stringer {
+	// 上位レイヤから順に見る
+	switch {
+	case p.HTTP != nil:
+		return p.HTTP
+	case p.HTTPRes != nil:
+		return p.HTTPRes
+	case p.DNS != nil:
+		return p.DNS
+	case p.SMB != nil:
+		return p.SMB
+	case p.TLS != nil:
+		return p.TLS
+	case p.TCP != nil:
+		return p.TCP
+	case p.UDP != nil:
+		return p.UDP
+	case p.ICMP != nil:
+		return p.ICMP
+	case p.ICMPv6 != nil:
+		return p.ICMPv6
+	case p.IPv4 != nil:
+		return p.IPv4
+	case p.IPv6 != nil:
+		return p.IPv6
+	case p.ARP != nil:
+		return p.ARP
+	}
+	return p.EthernetFrame
+}
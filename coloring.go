@@ -0,0 +1,87 @@
+package packemon
+
+import "fmt"
+
+// ColoringRule maps packets matching a display filter to a color.
+// Color is a color name such as "red" or a hex code such as "#ff0000", which TUIs resolve themselves.
+// ディスプレイフィルタに一致するパケットを色に対応付けます
+type ColoringRule struct {
+	Name   string `json:"name"`   // Rule name / ルール名
+	Filter string `json:"filter"` // Display filter, e.g. "tcp.flags.reset == 1" / ディスプレイフィルタ
+	Color  string `json:"color"`  // Color name or hex code / 色名または16進コード
+}
+
+// ColoringRules is an ordered list of compiled coloring rules. The first matching rule wins, like Wireshark.
+// コンパイル済みの色付けルールの一覧です。Wiresharkと同様に最初に一致したルールが使われます
+type ColoringRules struct {
+	rules   []ColoringRule
+	filters []*DisplayFilter
+}
+
+// DefaultColoringRules returns the built-in rules, loosely following Wireshark's defaults
+// 組み込みの色付けルールを返します(Wiresharkのデフォルトを参考にしています)
+func DefaultColoringRules() []ColoringRule {
+	return []ColoringRule{
+		{Name: "TCP RST", Filter: "tcp.flags.reset == 1", Color: "darkred"},
+		{Name: "TCP SYN/FIN", Filter: "tcp.flags.syn == 1 || tcp.flags.fin == 1", Color: "dimgray"},
+		{Name: "ICMP errors", Filter: "icmp.type == 3 || icmp.type == 11 || icmpv6.type < 128", Color: "maroon"},
+		{Name: "ARP", Filter: "arp", Color: "olive"},
+		{Name: "ICMP", Filter: "icmp || icmpv6", Color: "purple"},
+		{Name: "DNS", Filter: "dns", Color: "navy"},
+		{Name: "TLS", Filter: "tls", Color: "darkslateblue"},
+		{Name: "HTTP", Filter: "http", Color: "darkgreen"},
+	}
+}
+
+// NewColoringRules compiles the rules in order
+// ルールを順番にコンパイルします
+func NewColoringRules(rules []ColoringRule) (*ColoringRules, error) {
+	c := &ColoringRules{
+		rules:   make([]ColoringRule, 0, len(rules)),
+		filters: make([]*DisplayFilter, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		if rule.Color == "" {
+			return nil, fmt.Errorf("coloring rule %q has no color", rule.Name)
+		}
+		filter, err := CompileDisplayFilter(rule.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("coloring rule %q: %w", rule.Name, err)
+		}
+		c.rules = append(c.rules, rule)
+		c.filters = append(c.filters, filter)
+	}
+	return c, nil
+}
+
+// Match returns the first rule matching the packet
+// パケットに最初に一致したルールを返します
+func (c *ColoringRules) Match(passive *Passive) (ColoringRule, bool) {
+	if c == nil {
+		return ColoringRule{}, false
+	}
+	for i, filter := range c.filters {
+		if filter.Match(passive) {
+			return c.rules[i], true
+		}
+	}
+	return ColoringRule{}, false
+}
+
+// Color returns the color of the first rule matching the packet, or "" if none matches
+// パケットに最初に一致したルールの色を返します。一致しなければ空文字を返します
+func (c *ColoringRules) Color(passive *Passive) string {
+	rule, _ := c.Match(passive)
+	return rule.Color
+}
+
+// Rules returns the rules in evaluation order
+// 評価順のルールを返します
+func (c *ColoringRules) Rules() []ColoringRule {
+	if c == nil {
+		return nil
+	}
+	rules := make([]ColoringRule, len(c.rules))
+	copy(rules, c.rules)
+	return rules
+}
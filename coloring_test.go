@@ -0,0 +1,79 @@
+package packemon
+
+import "testing"
+
+// TestColoringRulesDefault tests that SYN and RST packets get their colors with the default rules
+// デフォルトのルールでSYNとRSTのパケットにそれぞれの色が付くことをテストします
+func TestColoringRulesDefault(t *testing.T) {
+	rules, err := NewColoringRules(DefaultColoringRules())
+	if err != nil {
+		t.Fatalf("NewColoringRules returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		passive *Passive
+		want    string
+	}{
+		{
+			name:    "SYN",
+			passive: &Passive{IPv4: &IPv4Packet{}, TCP: &TCPPacket{SrcPort: 50000, DstPort: 80, Flags: TCP_FLAGS_SYN}},
+			want:    "dimgray",
+		},
+		{
+			name:    "RST",
+			passive: &Passive{IPv4: &IPv4Packet{}, TCP: &TCPPacket{SrcPort: 80, DstPort: 50000, Flags: 0x14}}, // RST/ACK
+			want:    "darkred",
+		},
+		{
+			name:    "DNS",
+			passive: &Passive{IPv4: &IPv4Packet{}, UDP: &UDPPacket{SrcPort: 50000, DstPort: PORT_DNS}, DNS: &DNSPacket{}},
+			want:    "navy",
+		},
+		{
+			name:    "plain ACK",
+			passive: &Passive{IPv4: &IPv4Packet{}, TCP: &TCPPacket{SrcPort: 50000, DstPort: 80, Flags: TCP_FLAGS_ACK}},
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Color(tt.passive); got != tt.want {
+			t.Errorf("%s: Color() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestColoringRulesOrder tests that the first matching rule wins
+// 最初に一致したルールが使われることをテストします
+func TestColoringRulesOrder(t *testing.T) {
+	rules, err := NewColoringRules([]ColoringRule{
+		{Name: "web", Filter: "tcp.port == 80", Color: "green"},
+		{Name: "tcp", Filter: "tcp", Color: "blue"},
+	})
+	if err != nil {
+		t.Fatalf("NewColoringRules returned error: %v", err)
+	}
+
+	rule, ok := rules.Match(&Passive{TCP: &TCPPacket{SrcPort: 80, DstPort: 50000}})
+	if !ok || rule.Name != "web" {
+		t.Errorf("Match() = %+v, %v, want web", rule, ok)
+	}
+	if got := rules.Color(&Passive{TCP: &TCPPacket{SrcPort: 443, DstPort: 50000}}); got != "blue" {
+		t.Errorf("Color() = %q, want blue", got)
+	}
+}
+
+// TestNewColoringRulesInvalid tests that invalid rules are rejected
+// 不正なルールがエラーになることをテストします
+func TestNewColoringRulesInvalid(t *testing.T) {
+	for _, rule := range []ColoringRule{
+		{Name: "no color", Filter: "tcp"},
+		{Name: "unknown field", Filter: "tcp.window_size == 0", Color: "red"},
+		{Name: "empty filter", Filter: "", Color: "red"},
+	} {
+		if _, err := NewColoringRules([]ColoringRule{rule}); err == nil {
+			t.Errorf("%s: NewColoringRules should fail", rule.Name)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Config represents the configuration for Packemon
@@ -26,6 +27,26 @@ type Config struct {
 	// Keyboard shortcuts
 	// キーボードショートカット
 	KeyboardShortcuts KeyboardShortcutConfig `json:"keyboardShortcuts"` // Keyboard shortcut configuration / キーボードショートカット設定
+
+	// Default values of the generator's packets
+	// ジェネレーターのパケットのデフォルト値
+	DefaultPackets DefaultPacketsConfig `json:"defaultPackets"` // Pre-filled layer values / 事前入力されるレイヤーの値
+
+	// Decode As overrides (port -> protocol)
+	// Decode Asの設定(ポート -> プロトコル)
+	DecodeAs map[uint16]string `json:"decodeAs,omitempty"` // e.g. {"8443": "tls"} / 例: {"8443": "tls"}
+
+	// Monitor coloring rules, evaluated in order
+	// モニターの色付けルール(上から順に評価)
+	ColoringRules []ColoringRule `json:"coloringRules,omitempty"` // Empty uses DefaultColoringRules / 空の場合はDefaultColoringRules
+
+	// Traffic class profiles for statistics, evaluated in order
+	// 統計のトラフィッククラスのプロファイル(上から順に評価)
+	TrafficClasses []TrafficClassProfile `json:"trafficClasses,omitempty"` // Empty uses DefaultTrafficClassProfiles / 空の場合はDefaultTrafficClassProfiles
+
+	// Addresses defended from ARP spoofing with --arp-defense
+	// --arp-defenseでARPスプーフィングから防御するアドレス
+	ARPDefense []ARPDefenseEntry `json:"arpDefense,omitempty"` // Protected IPv4 -> legitimate MAC / 保護するIPv4 -> 正当なMAC
 }
 
 // PacketTemplate represents a template for a packet
@@ -35,12 +56,44 @@ type PacketTemplate struct {
 	Layers      map[string]interface{} `json:"layers"`      // Layer configurations / レイヤー設定
 }
 
+// DefaultPacketsConfig represents the values the generator starts with. Zero values fall back to the compiled-in defaults.
+// DefaultPacketsConfigはジェネレーターの初期値を表します。ゼロ値の場合は組み込みのデフォルト値が使われます
+type DefaultPacketsConfig struct {
+	SourceMAC       string `json:"sourceMAC,omitempty"`       // "auto" uses the interface's MAC address / "auto"の場合はインターフェースのMACアドレス
+	DestinationMAC  string `json:"destinationMAC,omitempty"`  // Destination MAC address / 宛先MACアドレス
+	SourceIP        string `json:"sourceIP,omitempty"`        // "auto" selects the address by destination / "auto"の場合は宛先から選択
+	DestinationIP   string `json:"destinationIP,omitempty"`   // Destination IPv4 address / 宛先IPv4アドレス
+	SourceIPv6      string `json:"sourceIPv6,omitempty"`      // "auto" selects the address by destination / "auto"の場合は宛先から選択
+	DestinationIPv6 string `json:"destinationIPv6,omitempty"` // Destination IPv6 address / 宛先IPv6アドレス
+	TTL             uint8  `json:"ttl,omitempty"`             // IPv4 TTL / IPv4のTTL
+	HopLimit        uint8  `json:"hopLimit,omitempty"`        // IPv6 hop limit / IPv6のホップリミット
+
+	TCPSourcePort      uint16 `json:"tcpSourcePort,omitempty"`      // TCP source port / TCP送信元ポート
+	TCPDestinationPort uint16 `json:"tcpDestinationPort,omitempty"` // TCP destination port / TCP宛先ポート
+	UDPSourcePort      uint16 `json:"udpSourcePort,omitempty"`      // UDP source port / UDP送信元ポート
+	UDPDestinationPort uint16 `json:"udpDestinationPort,omitempty"` // UDP destination port / UDP宛先ポート
+	DNSDomain          string `json:"dnsDomain,omitempty"`          // Domain to query / 問い合わせるドメイン
+	HTTPHost           string `json:"httpHost,omitempty"`           // HTTP Host header / HTTPのHostヘッダー
+	HTTPUserAgent      string `json:"httpUserAgent,omitempty"`      // HTTP User-Agent header / HTTPのUser-Agentヘッダー
+}
+
+// DEFAULT_PACKETS_AUTO lets packemon derive a source address from the network interface
+// DEFAULT_PACKETS_AUTOはネットワークインターフェースから送信元アドレスを決定させます
+const DEFAULT_PACKETS_AUTO = "auto"
+
+// IsAuto reports whether a source value is left to packemon
+// 送信元の値をpackemonに任せるかどうかを返します
+func (d DefaultPacketsConfig) IsAuto(value string) bool {
+	return value == "" || strings.EqualFold(value, DEFAULT_PACKETS_AUTO)
+}
+
 // UIConfig represents the UI configuration
 // UIConfigはUI設定を表します
 type UIConfig struct {
-	Theme            string `json:"theme"`            // UI theme / UIテーマ
-	ShowStatistics   bool   `json:"showStatistics"`   // Whether to show statistics / 統計情報を表示するかどうか
-	MaxPacketHistory int    `json:"maxPacketHistory"` // Maximum number of packets to keep in history / 履歴に保持するパケットの最大数
+	Theme            string  `json:"theme"`            // UI theme: dark, light, high-contrast or the name of a custom theme / UIテーマ: dark、light、high-contrastまたは独自のテーマの名前
+	ShowStatistics   bool    `json:"showStatistics"`   // Whether to show statistics / 統計情報を表示するかどうか
+	MaxPacketHistory int     `json:"maxPacketHistory"` // Maximum number of packets to keep in history / 履歴に保持するパケットの最大数
+	Themes           []Theme `json:"themes,omitempty"` // Custom themes / 独自のテーマ
 }
 
 // KeyboardShortcutConfig represents the keyboard shortcut configuration
@@ -60,7 +113,7 @@ func DefaultConfig() *Config {
 		DefaultInterface: "eth0",
 		Templates: make(map[string]PacketTemplate),
 		UI: UIConfig{
-			Theme:            "dark",
+			Theme:            THEME_DARK,
 			ShowStatistics:   true,
 			MaxPacketHistory: 1000,
 		},
@@ -81,6 +134,13 @@ func DefaultConfig() *Config {
 			SaveTemplate: "Ctrl+T",
 			LoadTemplate: "Ctrl+O",
 		},
+		DefaultPackets: DefaultPacketsConfig{
+			SourceMAC:  DEFAULT_PACKETS_AUTO,
+			SourceIP:   DEFAULT_PACKETS_AUTO,
+			SourceIPv6: DEFAULT_PACKETS_AUTO,
+		},
+		ColoringRules:  DefaultColoringRules(),
+		TrafficClasses: DefaultTrafficClassProfiles(),
 	}
 }
 
@@ -234,3 +294,61 @@ func (c *Config) GetShortcutHelp() string {
 	
 	return help
 }
+
+// SetDecodeAs persists a Decode As override. An empty proto removes it.
+// Decode Asの設定を保存します。protoが空の場合は削除します
+func (c *Config) SetDecodeAs(port uint16, proto string) error {
+	// 対応しているプロトコルかどうかの検証
+	if err := (&DecodeAsTable{}).Set(port, proto); err != nil {
+		return err
+	}
+	if c.DecodeAs == nil {
+		c.DecodeAs = make(map[uint16]string)
+	}
+	if proto == "" {
+		delete(c.DecodeAs, port)
+	} else {
+		c.DecodeAs[port] = strings.ToLower(proto)
+	}
+	return c.Save()
+}
+
+// ApplyDecodeAs applies the persisted Decode As overrides to a capture
+// 保存されたDecode Asの設定をキャプチャに適用します
+func (c *Config) ApplyDecodeAs(nwif *NetworkInterface) error {
+	for port, proto := range c.DecodeAs {
+		if err := nwif.DecodeAs(port, proto); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetColoringRules compiles the configured coloring rules, falling back to DefaultColoringRules when none are set
+// 設定された色付けルールをコンパイルします。未設定の場合はDefaultColoringRulesを使います
+func (c *Config) GetColoringRules() (*ColoringRules, error) {
+	if len(c.ColoringRules) == 0 {
+		return NewColoringRules(DefaultColoringRules())
+	}
+	return NewColoringRules(c.ColoringRules)
+}
+
+// GetTheme resolves the configured theme. A custom theme of the name wins over a built-in one, and an unknown name falls back to dark
+// 設定されたテーマを解決します。同じ名前の独自のテーマは組み込みのテーマより優先され、不明な名前の場合はdarkを使います
+func (c *Config) GetTheme() (*Theme, error) {
+	for _, theme := range c.UI.Themes {
+		if strings.EqualFold(theme.Name, c.UI.Theme) {
+			return NewTheme(theme)
+		}
+	}
+	return BuiltinTheme(c.UI.Theme), nil
+}
+
+// GetTrafficClassifier builds the classifier of the configured profiles, falling back to DefaultTrafficClassProfiles when none are set
+// 設定されたプロファイルの分類器を作成します。未設定の場合はDefaultTrafficClassProfilesを使います
+func (c *Config) GetTrafficClassifier() (*TrafficClassifier, error) {
+	if len(c.TrafficClasses) == 0 {
+		return NewTrafficClassifier(DefaultTrafficClassProfiles())
+	}
+	return NewTrafficClassifier(c.TrafficClasses)
+}
@@ -0,0 +1,140 @@
+package packemon
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Protocols that can be selected with DecodeAs
+// DecodeAsで指定できるプロトコル
+const (
+	DECODE_AS_HTTP = "http"
+	DECODE_AS_TLS  = "tls"
+	DECODE_AS_DNS  = "dns"
+	DECODE_AS_RTP  = "rtp" // RTP には決まったポートがないため Decode As でのみ解析する
+)
+
+// DecodeAsTable holds port to protocol overrides consulted before the default port map, like Wireshark's "Decode As".
+// The zero value is ready to use.
+// デフォルトのポート判定より優先される、ポートとプロトコルの対応表です(Wireshark の "Decode As" 相当)
+type DecodeAsTable struct {
+	mu        sync.RWMutex
+	overrides map[uint16]string
+}
+
+// Set decodes traffic on port as proto. An empty proto removes the override.
+// 指定ポートの通信をprotoとして解析するよう設定します。protoが空の場合は設定を削除します
+func (t *DecodeAsTable) Set(port uint16, proto string) error {
+	proto = strings.ToLower(proto)
+	switch proto {
+	case DECODE_AS_HTTP, DECODE_AS_TLS, DECODE_AS_DNS, DECODE_AS_RTP, "":
+	default:
+		return fmt.Errorf("unsupported protocol for decode as: %s", proto)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if proto == "" {
+		delete(t.overrides, port)
+		return nil
+	}
+	if t.overrides == nil {
+		t.overrides = make(map[uint16]string)
+	}
+	t.overrides[port] = proto
+	return nil
+}
+
+// Lookup returns the overridden protocol for a packet. The destination port takes precedence over the source port.
+// toPort reports whether the destination port matched.
+// パケットに対する上書きプロトコルを返します。宛先ポートを送信元ポートより優先します
+func (t *DecodeAsTable) Lookup(srcPort uint16, dstPort uint16) (proto string, toPort bool, ok bool) {
+	if t == nil {
+		return "", false, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if proto, ok := t.overrides[dstPort]; ok {
+		return proto, true, true
+	}
+	if proto, ok := t.overrides[srcPort]; ok {
+		return proto, false, true
+	}
+	return "", false, false
+}
+
+// Overrides returns a copy of the current overrides, e.g. to persist them in Config
+// 現在の設定のコピーを返します(Configへの保存などに使用)
+func (t *DecodeAsTable) Overrides() map[uint16]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	overrides := make(map[uint16]string, len(t.overrides))
+	for port, proto := range t.overrides {
+		overrides[port] = proto
+	}
+	return overrides
+}
+
+// ParseDecodeAs parses a comma separated list such as "8443:tls,5353:dns"
+// "8443:tls,5353:dns" のようなカンマ区切りの指定を解析します
+func ParseDecodeAs(s string) (map[uint16]string, error) {
+	overrides := map[uint16]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		port, proto, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid decode as entry: %s", entry)
+		}
+		var p uint16
+		if _, err := fmt.Sscanf(port, "%d", &p); err != nil || p == 0 {
+			return nil, fmt.Errorf("invalid port in decode as entry: %s", entry)
+		}
+		overrides[p] = strings.ToLower(proto)
+	}
+	return overrides, nil
+}
+
+func decodeTCPPayloadAs(passive *Passive, tcp *TCPPacket, proto string, toPort bool) {
+	switch proto {
+	case DECODE_AS_HTTP:
+		if toPort {
+			http := ParseHTTPRequest(tcp.Payload)
+			if http != nil {
+				passive.HTTP = http
+			}
+			recordDecode("HTTP", http != nil)
+		} else {
+			httpRes := ParseHTTPResponse(tcp.Payload)
+			if httpRes != nil {
+				passive.HTTPRes = httpRes
+			}
+			recordDecode("HTTP", httpRes != nil)
+		}
+	case DECODE_AS_TLS:
+		ParseTLSData(tcp.Payload, passive)
+		recordDecode("TLS", passive.TLS != nil)
+	case DECODE_AS_DNS:
+		parseDNSOverTCP(passive, tcp)
+	}
+}
+
+// UDP 上では今のところ DNS と RTP のみ対応
+func decodeUDPPayloadAs(passive *Passive, udp *UDPPacket, proto string) {
+	switch proto {
+	case DECODE_AS_DNS:
+		parseDNSData(udp.Payload, passive)
+	case DECODE_AS_RTP:
+		rtp, err := ParsedRTP(udp.Payload)
+		recordDecode("RTP", err == nil)
+		if err != nil {
+			logParseFailure("RTP", udp.Payload)
+			return
+		}
+		passive.RTP = rtp
+	}
+}
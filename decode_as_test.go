@@ -0,0 +1,109 @@
+package packemon
+
+import (
+	"testing"
+)
+
+// TestDecodeAsTLSOnCustomPort tests that TCP/8443 is decoded as TLS only when overridden
+// TCP/8443 が上書き設定時のみ TLS として解析されることをテストします
+func TestDecodeAsTLSOnCustomPort(t *testing.T) {
+	// ClientHello の TLS レコードヘッダー + 先頭数バイト
+	tlsRecord := []byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00}
+
+	tcp := make([]byte, 20)
+	tcp[0], tcp[1] = 0xc3, 0x50 // src 50000
+	tcp[2], tcp[3] = 0x20, 0xfb // dst 8443
+	tcp[12] = 0x50              // data offset 5
+	tcp[13] = 0x18              // PSH/ACK
+	tcp = append(tcp, tlsRecord...)
+
+	ipv4 := make([]byte, 20)
+	ipv4[0] = 0x45
+	ipv4[2], ipv4[3] = 0x00, byte(20+len(tcp))
+	ipv4[8] = 0x40
+	ipv4[9] = IPv4_PROTO_TCP
+	ipv4 = append(ipv4, tcp...)
+
+	newPassive := func() *Passive {
+		return &Passive{
+			EthernetFrame: &EthernetFrame{
+				Type:    ETHER_TYPE_IPv4,
+				Payload: ipv4,
+			},
+		}
+	}
+
+	passive := newPassive()
+	parseEthernetPayload(passive, nil, PARSE_DEPTH_FULL)
+	if passive.TCP == nil {
+		t.Fatalf("TCP should be parsed")
+	}
+	if passive.TLS != nil {
+		t.Fatalf("TLS should not be parsed without override")
+	}
+
+	decodeAs := &DecodeAsTable{}
+	if err := decodeAs.Set(8443, "TLS"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	passive = newPassive()
+	parseEthernetPayload(passive, decodeAs, PARSE_DEPTH_FULL)
+	if passive.TLS == nil {
+		t.Fatalf("TLS should be parsed with override")
+	}
+	if passive.TLS.Type != 0x16 || passive.TLS.Version != 0x0301 || passive.TLS.Length != 4 {
+		t.Errorf("TLS = %+v, want handshake record of TLS 1.0 with length 4", passive.TLS)
+	}
+
+	// 上書きを削除すると元に戻る
+	if err := decodeAs.Set(8443, ""); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	passive = newPassive()
+	parseEthernetPayload(passive, decodeAs, PARSE_DEPTH_FULL)
+	if passive.TLS != nil {
+		t.Errorf("TLS should not be parsed after removing override")
+	}
+}
+
+// TestParseDecodeAs tests parsing of the decode as flag value
+// decode as の指定文字列の解析をテストします
+func TestParseDecodeAs(t *testing.T) {
+	got, err := ParseDecodeAs("8443:tls, 5353:DNS")
+	if err != nil {
+		t.Fatalf("ParseDecodeAs returned error: %v", err)
+	}
+	if len(got) != 2 || got[8443] != DECODE_AS_TLS || got[5353] != DECODE_AS_DNS {
+		t.Errorf("ParseDecodeAs = %v", got)
+	}
+
+	for _, s := range []string{"8443", "port:tls", "0:tls"} {
+		if _, err := ParseDecodeAs(s); err == nil {
+			t.Errorf("ParseDecodeAs(%q) should fail", s)
+		}
+	}
+
+	if err := (&DecodeAsTable{}).Set(8443, "quic"); err == nil {
+		t.Errorf("Set with unsupported protocol should fail")
+	}
+}
+
+// TestConfigApplyDecodeAs tests that the Decode As overrides saved in the config are applied to a capture
+// 設定に保存された Decode As の上書きがキャプチャに適用されることをテストします
+func TestConfigApplyDecodeAs(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("lo")
+	cfg := &Config{DecodeAs: map[uint16]string{8443: "tls", 5353: "dns"}}
+	if err := cfg.ApplyDecodeAs(nwif); err != nil {
+		t.Fatalf("ApplyDecodeAs returned error: %v", err)
+	}
+	got := nwif.DecodeAsOverrides()
+	if len(got) != 2 || got[8443] != DECODE_AS_TLS || got[5353] != DECODE_AS_DNS {
+		t.Errorf("DecodeAsOverrides() = %v, want 8443:tls and 5353:dns", got)
+	}
+
+	// 対応していないプロトコルはエラー
+	cfg = &Config{DecodeAs: map[uint16]string{8443: "gopher"}}
+	if err := cfg.ApplyDecodeAs(NewOfflineNetworkInterface("lo")); err == nil {
+		t.Errorf("ApplyDecodeAs with an unknown protocol returned no error")
+	}
+}
@@ -0,0 +1,105 @@
+package packemon
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+)
+
+var decodeRecovery atomic.Bool
+
+// SetDecodeRecovery switches between strict decoding (the default), which stops at a layer that fails to parse,
+// and recovery mode, which records the failure in Passive.Errors and keeps decoding deeper layers from a best guess of the broken header.
+// This gets the most out of imperfect captures, but the layers after an error are guesses.
+// 解析に失敗したレイヤで止まる厳密な解析(デフォルト)と、失敗をPassive.Errorsに記録し、壊れたヘッダーを推測して上位レイヤの解析を続ける
+// 復旧モードを切り替えます。不完全なキャプチャから最大限の情報を得られますが、エラーより上位のレイヤは推測です
+func SetDecodeRecovery(enabled bool) {
+	decodeRecovery.Store(enabled)
+}
+
+// DecodeRecovery reports whether recovery mode is enabled
+// 復旧モードが有効かどうかを返します
+func DecodeRecovery() bool {
+	return decodeRecovery.Load()
+}
+
+// LayerError is a layer that failed to parse, recorded in recovery mode
+// 復旧モードで記録される、解析に失敗したレイヤです
+type LayerError struct {
+	Layer string
+	Err   error
+}
+
+func (e LayerError) Error() string {
+	return e.Layer + ": " + e.Err.Error()
+}
+
+func (e LayerError) Unwrap() error {
+	return e.Err
+}
+
+func (p *Passive) recordLayerError(layer string, err error) {
+	p.Errors = append(p.Errors, LayerError{Layer: layer, Err: err})
+}
+
+// recoverIPv4Packet decodes an IPv4 header whose IHL is below the minimum as a header without options, in recovery mode.
+// It returns nil in strict mode or if data is too short for even that
+// 復旧モードでは、IHLが最小値より小さいIPv4ヘッダーをオプション無しのヘッダーとして解析します。
+// 厳密な解析の場合やそれにも足りない場合はnilを返します
+func (p *Passive) recoverIPv4Packet(data []byte) *IPv4Packet {
+	if !DecodeRecovery() || len(data) < 20 {
+		return nil
+	}
+	p.recordLayerError("IPv4", fmt.Errorf("header length %d is shorter than 20 bytes; decoded assuming no options", (data[0]&0x0f)*4))
+
+	// IHL を最小値に直して解析する. 元のバイト列は変更しない
+	header := append([]byte{data[0]&0xf0 | 5}, data[1:20]...)
+	ipv4 := ParseIPv4Packet(header)
+	ipv4.SrcIP, ipv4.DstIP, ipv4.Payload = data[12:16], data[16:20], data[20:]
+	return ipv4
+}
+
+// recoverTCPPacket decodes a TCP header whose data offset is below the minimum as a header without options, in recovery mode.
+// It returns nil in strict mode or if data is too short for even that
+// 復旧モードでは、データオフセットが最小値より小さいTCPヘッダーをオプション無しのヘッダーとして解析します。
+// 厳密な解析の場合やそれにも足りない場合はnilを返します
+func (p *Passive) recoverTCPPacket(data []byte) *TCPPacket {
+	if !DecodeRecovery() || len(data) < 20 {
+		return nil
+	}
+	p.recordLayerError("TCP", fmt.Errorf("data offset %d is shorter than 20 bytes; decoded assuming no options", (data[12]>>4)*4))
+
+	header := append(append([]byte{}, data[:12]...), 5<<4)
+	header = append(header, data[13:20]...)
+	tcp := ParseTCPPacket(header)
+	tcp.Payload = data[20:]
+	return tcp
+}
+
+var httpRequestPrefixes = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("DELETE "), []byte("HEAD "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+// sniffTCPPayload detects HTTP and TLS from the content of a payload that port-based detection did not decode.
+// A recovered header may carry wrong ports, so the content is the better clue
+// ポートによる判定で解析できなかったペイロードから、内容でHTTPとTLSを検出します。
+// 推測したヘッダーのポートは誤っている可能性があるため、内容の方が手がかりになります
+func sniffTCPPayload(passive *Passive, payload []byte) {
+	if passive.HTTP != nil || passive.HTTPRes != nil || passive.TLS != nil || passive.DNS != nil || passive.SMB != nil {
+		return
+	}
+
+	if bytes.HasPrefix(payload, []byte("HTTP/1.")) {
+		passive.HTTPRes = ParseHTTPResponse(payload)
+		return
+	}
+	for _, prefix := range httpRequestPrefixes {
+		if bytes.HasPrefix(payload, prefix) {
+			passive.HTTP = ParseHTTPRequest(payload)
+			return
+		}
+	}
+	// ParseTLSData はレコードタイプとバージョンを確認する
+	ParseTLSData(payload, passive)
+}
@@ -0,0 +1,62 @@
+package packemon
+
+import (
+	"testing"
+)
+
+// TestDecodeRecovery tests that a corrupted TCP header over a valid IPv4 header stops decoding in strict mode,
+// and is recorded in Passive.Errors in recovery mode while the HTTP request above it is still decoded
+// 正しいIPv4ヘッダー上の壊れたTCPヘッダーが、厳密な解析では解析を止め、復旧モードではPassive.Errorsに記録されつつ上位のHTTPリクエストも解析されることをテストします
+func TestDecodeRecovery(t *testing.T) {
+	frame := parseDepthTestFrame()
+	frame[14+20+12] = 0x20 // データオフセット 8 バイト
+
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.IPv4 == nil || passive.TCP != nil || passive.HTTP != nil || len(passive.Errors) != 0 {
+		t.Errorf("strict mode: IPv4 %v, TCP %v, HTTP %v, Errors %v", passive.IPv4 != nil, passive.TCP, passive.HTTP, passive.Errors)
+	}
+
+	SetDecodeRecovery(true)
+	defer SetDecodeRecovery(false)
+
+	passive, err = DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passive.Errors) != 1 || passive.Errors[0].Layer != "TCP" {
+		t.Fatalf("Errors = %v, want one TCP error", passive.Errors)
+	}
+	if passive.TCP == nil || passive.TCP.DstPort != 80 {
+		t.Fatalf("TCP = %+v, want a recovered header to port 80", passive.TCP)
+	}
+	if passive.HTTP == nil || passive.HTTP.Method != "GET" {
+		t.Errorf("HTTP = %+v, want the GET request", passive.HTTP)
+	}
+	if pj := NewPassiveJSON(passive); len(pj.Errors) != 1 || pj.Errors[0] != passive.Errors[0].Error() {
+		t.Errorf("PassiveJSON.Errors = %v", pj.Errors)
+	}
+
+	// ポートも壊れていれば内容から HTTP を見つける
+	frame[14+20+2], frame[14+20+3] = 0x12, 0x34
+	passive, err = DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.HTTP == nil {
+		t.Error("HTTP should be detected from the payload when the ports are wrong too")
+	}
+
+	// IPv4 ヘッダー長が壊れていても TCP 以降を解析する
+	frame = parseDepthTestFrame()
+	frame[14] = 0x43
+	passive, err = DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passive.Errors) != 1 || passive.Errors[0].Layer != "IPv4" || passive.TCP == nil || passive.HTTP == nil {
+		t.Errorf("corrupted IHL: Errors %v, TCP %v, HTTP %v", passive.Errors, passive.TCP != nil, passive.HTTP != nil)
+	}
+}
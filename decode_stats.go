@@ -0,0 +1,71 @@
+package packemon
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DecodeStat is how many times a protocol parser was tried and how many times it failed.
+// A high failure rate means the traffic is not what its port suggests, e.g. non TLS traffic on 443.
+// プロトコルのパーサーを試した回数と失敗した回数です。失敗率が高い場合、ポートから想定されるプロトコルではない通信が流れています
+type DecodeStat struct {
+	Protocol  string
+	Attempts  uint64
+	Successes uint64
+	Failures  uint64
+}
+
+// プロトコル名ごとの *decodeCounter. パケットごとに呼ばれるのでロックを取らずに数える
+var decodeCounters sync.Map
+
+type decodeCounter struct {
+	attempts atomic.Uint64
+	failures atomic.Uint64
+}
+
+// recordDecode counts an attempt to parse protocol. ok is false if the parser failed
+// protocolの解析を試みたことを記録します。パーサーが失敗した場合はokがfalseです
+func recordDecode(protocol string, ok bool) {
+	value, loaded := decodeCounters.Load(protocol)
+	if !loaded {
+		value, _ = decodeCounters.LoadOrStore(protocol, &decodeCounter{})
+	}
+	counter := value.(*decodeCounter)
+	counter.attempts.Add(1)
+	if !ok {
+		counter.failures.Add(1)
+	}
+}
+
+// DecodeStats returns the decode counts of every protocol tried since start up or ResetDecodeStats, sorted by protocol
+// 起動時またはResetDecodeStats以降に試したプロトコルごとの解析回数を、プロトコル名順に返します
+func DecodeStats() []DecodeStat {
+	stats := []DecodeStat{}
+	decodeCounters.Range(func(key, value any) bool {
+		counter := value.(*decodeCounter)
+		// attempts を先に読むと、その後に数えられた failures が attempts を超えることがあるため failures から読む
+		failures := counter.failures.Load()
+		attempts := counter.attempts.Load()
+		stats = append(stats, DecodeStat{
+			Protocol:  key.(string),
+			Attempts:  attempts,
+			Successes: attempts - failures,
+			Failures:  failures,
+		})
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Protocol < stats[j].Protocol
+	})
+	return stats
+}
+
+// ResetDecodeStats clears the decode counts
+// 解析回数をクリアします
+func ResetDecodeStats() {
+	decodeCounters.Range(func(key, _ any) bool {
+		decodeCounters.Delete(key)
+		return true
+	})
+}
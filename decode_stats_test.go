@@ -0,0 +1,78 @@
+package packemon
+
+import "testing"
+
+// IPv4 / UDP のフレーム
+func decodeStatsTestUDPFrame(srcPort, dstPort uint16, payload []byte) []byte {
+	udp := []byte{byte(srcPort >> 8), byte(srcPort), byte(dstPort >> 8), byte(dstPort), 0x00, byte(8 + len(payload)), 0x00, 0x00}
+	udp = append(udp, payload...)
+	ipv4 := []byte{
+		0x45, 0x00, 0x00, byte(20 + len(udp)), 0x00, 0x00, 0x00, 0x00, 0x40, IPv4_PROTO_UDP, 0x00, 0x00,
+		192, 168, 10, 1, 192, 168, 10, 2,
+	}
+	ipv4 = append(ipv4, udp...)
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x08, 0x00}
+	return append(frame, ipv4...)
+}
+
+func decodeStat(protocol string) DecodeStat {
+	for _, stat := range DecodeStats() {
+		if stat.Protocol == protocol {
+			return stat
+		}
+	}
+	return DecodeStat{Protocol: protocol}
+}
+
+// TestDecodeStatsMalformedDNS tests that a DNS message shorter than its header counts as a failure
+// ヘッダーより短いDNSメッセージが失敗として数えられることをテストします
+func TestDecodeStatsMalformedDNS(t *testing.T) {
+	ResetDecodeStats()
+
+	// 正常なクエリ1つと、ヘッダーの途中で終わるもの2つ
+	query := dnsTestMessage(0x1234, false, "example.com", nil, nil)
+	frames := [][]byte{
+		decodeStatsTestUDPFrame(0xd4c0, PORT_DNS, query),
+		decodeStatsTestUDPFrame(0xd4c0, PORT_DNS, query[:5]),
+		decodeStatsTestUDPFrame(PORT_DNS, 0xd4c0, []byte{0x12, 0x34, 0x81}),
+	}
+	for _, frame := range frames {
+		if _, err := DecodeFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := decodeStat("DNS")
+	want := DecodeStat{Protocol: "DNS", Attempts: 3, Successes: 1, Failures: 2}
+	if got != want {
+		t.Errorf("DNS = %+v, want %+v", got, want)
+	}
+	if udp := decodeStat("UDP"); udp.Attempts != 3 || udp.Failures != 0 {
+		t.Errorf("UDP = %+v, want 3 attempts without failures", udp)
+	}
+
+	ResetDecodeStats()
+	if stats := DecodeStats(); len(stats) != 0 {
+		t.Errorf("DecodeStats() after reset = %+v", stats)
+	}
+}
+
+// TestDecodeStatsNonTLS tests that non TLS traffic on 443 counts as a TLS failure
+// 443番ポートのTLSではない通信がTLSの失敗として数えられることをテストします
+func TestDecodeStatsNonTLS(t *testing.T) {
+	ResetDecodeStats()
+
+	// 平文の HTTP が 443 に流れている
+	frame := parseDepthTestFrame()
+	frame[14+20+2], frame[14+20+3] = 0x01, 0xbb // dst 443
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.TLS != nil {
+		t.Errorf("TLS = %+v, want nil", passive.TLS)
+	}
+	if got := decodeStat("TLS"); got.Attempts != 1 || got.Failures != 1 {
+		t.Errorf("TLS = %+v, want 1 failed attempt", got)
+	}
+}
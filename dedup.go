@@ -0,0 +1,173 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DEFAULT_DEDUP_WINDOW is how long a captured frame is remembered to drop its copies, long enough for a SPAN port to deliver both
+// 捨てる複製のためにキャプチャしたフレームを覚えておく時間です。SPANポートが両方を届けるのに十分な長さです
+const DEFAULT_DEDUP_WINDOW = 10 * time.Millisecond
+
+// DEDUP_PAYLOAD_PREFIX is how many bytes after the IP header are hashed to tell packets apart
+// パケットを区別するためにハッシュするIPヘッダ以降のバイト数です
+const DEDUP_PAYLOAD_PREFIX = 64
+
+// Deduplicator drops frames captured twice within a window, as happens with SPAN and mirror ports that copy a packet on ingress and egress.
+// IP packets are told apart by their addresses, protocol, IP ID, fragment offset and the first bytes after the IP header, so a copy
+// with a rewritten MAC address or TTL is still a duplicate. Other frames are compared by their first bytes.
+// On a capture of another link type than Ethernet, NetworkInterface compares whole frames instead
+// SPANやミラーポートが受信時と送信時にパケットを複製した場合のように、ウィンドウ内に2度キャプチャしたフレームを捨てます。
+// IPパケットはアドレス、プロトコル、IP ID、フラグメントオフセットおよびIPヘッダ以降の先頭バイトで区別するため、
+// MACアドレスやTTLが書き換わった複製も重複になります。その他のフレームは先頭バイトで比較します。
+// Ethernet以外のリンクタイプのキャプチャでは、NetworkInterfaceはフレーム全体で比較します
+type Deduplicator struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[uint64]time.Time // キー -> 最初にキャプチャした時刻
+	lastSweep time.Time
+
+	dropped atomic.Uint64
+}
+
+// NewDeduplicator creates a Deduplicator remembering frames for window. Attach it with NetworkInterface.SetDeduplication
+// フレームをwindowの間覚えておくDeduplicatorを作成します。NetworkInterface.SetDeduplicationで設定します
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		window: window,
+		seen:   map[uint64]time.Time{},
+	}
+}
+
+// Window returns how long frames are remembered
+// フレームを覚えておく時間を返します
+func (d *Deduplicator) Window() time.Duration {
+	return d.window
+}
+
+// Duplicate reports whether the Ethernet frame data captured at t copies a frame captured within the window before it, and remembers it otherwise
+// tにキャプチャしたEthernetフレームdataが、それ以前のウィンドウ内にキャプチャしたフレームの複製かどうかを返します。複製でなければ覚えておきます
+func (d *Deduplicator) Duplicate(data []byte, t time.Time) bool {
+	return d.duplicate(dedupKey(data), t)
+}
+
+func (d *Deduplicator) duplicate(key uint64, t time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// 期限切れのキーはウィンドウごとにまとめて消す
+	if t.Sub(d.lastSweep) > d.window {
+		for k, first := range d.seen {
+			if t.Sub(first) > d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = t
+	}
+
+	if first, ok := d.seen[key]; ok && t.Sub(first) <= d.window {
+		d.dropped.Add(1)
+		return true
+	}
+	d.seen[key] = t
+	return false
+}
+
+// Dropped returns how many duplicates were dropped
+// 捨てた重複の数を返します
+func (d *Deduplicator) Dropped() uint64 {
+	return d.dropped.Load()
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// dedupKey hashes the parts of a raw Ethernet frame that stay the same in copies of it.
+// The offsets are those of Ethernet, so frames of other link types are hashed with rawDedupKey
+// 生のEthernetフレームのうち、複製でも変わらない部分のハッシュを返します。
+// オフセットはEthernetのものなので、他のリンクタイプのフレームはrawDedupKeyでハッシュします
+func dedupKey(data []byte) uint64 {
+	h := uint64(fnvOffset64)
+	add := func(b []byte) {
+		for _, c := range b {
+			h ^= uint64(c)
+			h *= fnvPrime64
+		}
+	}
+	prefix := func(b []byte) []byte {
+		return b[:min(len(b), DEDUP_PAYLOAD_PREFIX)]
+	}
+
+	if len(data) < 14 {
+		add(data)
+		return h
+	}
+	// ミラーの片側だけにタグが付くことがあるため、802.1Q タグは見ない
+	typ, l3 := binary.BigEndian.Uint16(data[12:14]), 14
+	if typ == ETHER_TYPE_VLAN && len(data) >= 18 {
+		typ, l3 = binary.BigEndian.Uint16(data[16:18]), 18
+	}
+	header := data[l3:]
+
+	switch {
+	case typ == ETHER_TYPE_IPv4 && len(header) >= 20:
+		ihl := min(max(int(header[0]&0x0f)*4, 20), len(header))
+		add([]byte{4, header[9]}) // Protocol
+		add(header[12:20])        // Src, Dst
+		add(header[4:8])          // Identification, Flags, Fragment Offset
+		add(prefix(header[ihl:])) // ポートを含む上位レイヤの先頭
+	case typ == ETHER_TYPE_IPv6 && len(header) >= 40:
+		add([]byte{6, header[6]}) // Next Header
+		add(header[8:40])         // Src, Dst
+		add(header[4:6])          // Payload Length
+		add(prefix(header[40:]))  // IPv6 には ID が無いため、拡張ヘッダを含む先頭で区別する
+	default:
+		add(prefix(data))
+	}
+	return h
+}
+
+// rawDedupKey hashes a whole frame, so only exact copies are duplicates
+// フレーム全体のハッシュを返します。完全に同じ複製だけが重複になります
+func rawDedupKey(data []byte) uint64 {
+	h := uint64(fnvOffset64)
+	for _, c := range data {
+		h ^= uint64(c)
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// SetDeduplication drops received frames that copy a frame received within window before them, before they reach PassiveCh
+// or statistics. 0 disables it, which is the default. Takes effect when receiving starts
+// window以内に受信したフレームの複製を、PassiveChや統計に届く前に捨てるよう設定します。
+// 0で無効になり、これがデフォルトです。受信開始時に反映されます
+func (nwif *NetworkInterface) SetDeduplication(window time.Duration) {
+	if window <= 0 {
+		nwif.deduplicator.Store(nil)
+		return
+	}
+	nwif.deduplicator.Store(NewDeduplicator(window))
+}
+
+// Deduplication returns the window set with SetDeduplication, or 0 if it is disabled
+// SetDeduplicationで設定したウィンドウを返します。無効な場合は0です
+func (nwif *NetworkInterface) Deduplication() time.Duration {
+	if d := nwif.deduplicator.Load(); d != nil {
+		return d.Window()
+	}
+	return 0
+}
+
+// DuplicatesDropped returns how many received frames were dropped as duplicates
+// 重複として捨てた受信フレームの数を返します
+func (nwif *NetworkInterface) DuplicatesDropped() uint64 {
+	if d := nwif.deduplicator.Load(); d != nil {
+		return d.Dropped()
+	}
+	return 0
+}
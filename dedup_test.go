@@ -0,0 +1,91 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+// TestDeduplication tests that a copy of a frame received within the window is dropped before PassiveCh,
+// even with a rewritten MAC address and TTL, while different packets and copies after the window are kept
+// ウィンドウ内に受信したフレームの複製が、MACアドレスとTTLが書き換わっていてもPassiveChの前に捨てられること、
+// および異なるパケットとウィンドウ後の複製は残ることをテストします
+func TestDeduplication(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("packemon-test0")
+	if nwif.Deduplication() != 0 {
+		t.Fatalf("Deduplication() = %s, want disabled by default", nwif.Deduplication())
+	}
+	nwif.SetDeduplication(DEFAULT_DEDUP_WINDOW)
+	handle, stop := nwif.captureHandler()
+
+	original := decodeStatsTestUDPFrame(40000, 9999, []byte("ping"))
+	// ルーターを通った後にミラーされた複製は、MAC アドレスと TTL が異なる
+	copied := append([]byte{}, original...)
+	copy(copied[0:6], []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01})
+	copied[22]--
+	other := decodeStatsTestUDPFrame(40000, 9999, []byte("pong"))
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, frame := range []struct {
+		data []byte
+		at   time.Duration
+	}{
+		{data: original, at: 0},
+		{data: copied, at: time.Millisecond},
+		{data: other, at: 2 * time.Millisecond},
+		{data: original, at: DEFAULT_DEDUP_WINDOW + time.Millisecond},
+	} {
+		handle(capturedFrame{data: frame.data, wireLength: len(frame.data), timestamp: base.Add(frame.at)})
+	}
+	stop()
+
+	if got := len(nwif.PassiveCh); got != 3 {
+		t.Fatalf("%d packets delivered, want 3", got)
+	}
+	if got := nwif.DuplicatesDropped(); got != 1 {
+		t.Errorf("DuplicatesDropped() = %d, want 1", got)
+	}
+	for _, want := range []string{"ping", "pong", "ping"} {
+		if passive := <-nwif.PassiveCh; string(passive.UDP.Payload) != want {
+			t.Errorf("payload = %q, want %q", passive.UDP.Payload, want)
+		}
+	}
+
+	nwif.SetDeduplication(0)
+	if nwif.Deduplication() != 0 {
+		t.Errorf("Deduplication() = %s after disabling it", nwif.Deduplication())
+	}
+}
+
+// TestDeduplicationLinkType tests that frames of a link type other than Ethernet are compared as a whole,
+// so beacons differing only after the first bytes are both kept while an exact copy is dropped
+// Ethernet以外のリンクタイプのフレームは全体で比較され、先頭より後ろだけが異なるビーコンは両方残り、
+// 完全な複製は捨てられることをテストします
+func TestDeduplicationLinkType(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("packemon-test0")
+	if err := nwif.SetLinkType(PCAP_LINKTYPE_IEEE802_11_RADIOTAP); err != nil {
+		t.Fatal(err)
+	}
+	nwif.SetDeduplication(DEFAULT_DEDUP_WINDOW)
+	handle, stop := nwif.captureHandler()
+
+	first := ieee80211TestBeacon()
+	// 先頭 DEDUP_PAYLOAD_PREFIX バイトより後ろの Supported Rates と FCS だけが異なる
+	second := ieee80211TestBeacon()
+	second[DEDUP_PAYLOAD_PREFIX] = 0x84
+	binary.LittleEndian.PutUint32(second[len(second)-4:], crc32.ChecksumIEEE(second[16:len(second)-4]))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, frame := range [][]byte{first, second, first} {
+		handle(capturedFrame{data: frame, wireLength: len(frame), timestamp: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+	stop()
+
+	if got := len(nwif.PassiveCh); got != 2 {
+		t.Errorf("%d packets delivered, want 2", got)
+	}
+	if got := nwif.DuplicatesDropped(); got != 1 {
+		t.Errorf("DuplicatesDropped() = %d, want 1", got)
+	}
+}
@@ -0,0 +1,248 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	PORT_DHCP_SERVER = 67
+	PORT_DHCP_CLIENT = 68
+)
+
+const (
+	DHCP_OP_REQUEST = 1
+	DHCP_OP_REPLY   = 2
+)
+
+// DHCP message types (option 53)
+// ref: https://datatracker.ietf.org/doc/html/rfc2132#section-9.6
+// DHCPメッセージタイプ(オプション53)
+const (
+	DHCP_MESSAGE_DISCOVER = 1
+	DHCP_MESSAGE_OFFER    = 2
+	DHCP_MESSAGE_REQUEST  = 3
+	DHCP_MESSAGE_DECLINE  = 4
+	DHCP_MESSAGE_ACK      = 5
+	DHCP_MESSAGE_NAK      = 6
+	DHCP_MESSAGE_RELEASE  = 7
+	DHCP_MESSAGE_INFORM   = 8
+)
+
+var DHCPMessageTypes = map[uint8]string{
+	DHCP_MESSAGE_DISCOVER: "DISCOVER",
+	DHCP_MESSAGE_OFFER:    "OFFER",
+	DHCP_MESSAGE_REQUEST:  "REQUEST",
+	DHCP_MESSAGE_DECLINE:  "DECLINE",
+	DHCP_MESSAGE_ACK:      "ACK",
+	DHCP_MESSAGE_NAK:      "NAK",
+	DHCP_MESSAGE_RELEASE:  "RELEASE",
+	DHCP_MESSAGE_INFORM:   "INFORM",
+}
+
+// DHCP option codes
+// ref: https://datatracker.ietf.org/doc/html/rfc2132
+// DHCPオプションのコード
+const (
+	DHCP_OPTION_PAD                    = 0
+	DHCP_OPTION_SUBNET_MASK            = 1
+	DHCP_OPTION_ROUTER                 = 3
+	DHCP_OPTION_DNS                    = 6
+	DHCP_OPTION_REQUESTED_IP           = 50
+	DHCP_OPTION_LEASE_TIME             = 51
+	DHCP_OPTION_MESSAGE_TYPE           = 53
+	DHCP_OPTION_SERVER_ID              = 54
+	DHCP_OPTION_PARAMETER_REQUEST_LIST = 55
+	DHCP_OPTION_END                    = 255
+)
+
+// DHCP_FLAGS_BROADCAST asks the server to broadcast its reply, for clients that cannot receive unicast before they have an address
+// アドレス取得前でユニキャストを受信できないクライアントのために、サーバーに応答をブロードキャストするよう求めます
+const DHCP_FLAGS_BROADCAST = 0x8000
+
+var dhcpMagicCookie = []byte{0x63, 0x82, 0x53, 0x63}
+
+// DHCP represents a DHCP message. Addresses are 4 byte IPv4 addresses
+// ref: https://datatracker.ietf.org/doc/html/rfc2131#section-2
+// DHCPメッセージを表します
+type DHCP struct {
+	Op     uint8
+	HType  uint8
+	HLen   uint8
+	Hops   uint8
+	XID    uint32
+	Secs   uint16
+	Flags  uint16
+	CIAddr net.IP // クライアントの現在のアドレス
+	YIAddr net.IP // クライアントに割り当てるアドレス
+	SIAddr net.IP // 次に使うサーバーのアドレス
+	GIAddr net.IP // リレーエージェントのアドレス
+	CHAddr net.HardwareAddr
+
+	// Options excludes the pad and end options
+	// パディングと終端のオプションは含まない
+	Options []DHCPOption
+}
+
+// DHCPOption is a DHCP option. Data excludes the code and length
+// DHCPオプションです。Dataにはコードと長さを含みません
+type DHCPOption struct {
+	Code uint8
+	Data []byte
+}
+
+// NewDHCPRequest creates a client message of messageType from mac, e.g. DHCP_MESSAGE_DISCOVER. It asks for a broadcast reply
+// macからのmessageTypeのクライアントメッセージを作成します。応答はブロードキャストで求めます
+func NewDHCPRequest(messageType uint8, xid uint32, mac net.HardwareAddr, options ...DHCPOption) *DHCP {
+	return &DHCP{
+		Op:      DHCP_OP_REQUEST,
+		HType:   0x01, // Ethernet
+		HLen:    uint8(len(mac)),
+		XID:     xid,
+		Flags:   DHCP_FLAGS_BROADCAST,
+		CIAddr:  net.IPv4zero.To4(),
+		YIAddr:  net.IPv4zero.To4(),
+		SIAddr:  net.IPv4zero.To4(),
+		GIAddr:  net.IPv4zero.To4(),
+		CHAddr:  mac,
+		Options: append([]DHCPOption{{Code: DHCP_OPTION_MESSAGE_TYPE, Data: []byte{messageType}}}, options...),
+	}
+}
+
+// ParsedDHCP parses a DHCP message carried in a UDP payload
+// UDPペイロードに含まれるDHCPメッセージを解析します
+func ParsedDHCP(payload []byte) (*DHCP, error) {
+	r := NewFieldReader(payload, binary.BigEndian)
+	fixed, err := r.ReadBytes(236)
+	if err != nil {
+		return nil, fmt.Errorf("dhcp message too short: %w", err)
+	}
+	dhcp := &DHCP{
+		Op:     fixed[0],
+		HType:  fixed[1],
+		HLen:   fixed[2],
+		Hops:   fixed[3],
+		XID:    binary.BigEndian.Uint32(fixed[4:8]),
+		Secs:   binary.BigEndian.Uint16(fixed[8:10]),
+		Flags:  binary.BigEndian.Uint16(fixed[10:12]),
+		CIAddr: net.IP(fixed[12:16]),
+		YIAddr: net.IP(fixed[16:20]),
+		SIAddr: net.IP(fixed[20:24]),
+		GIAddr: net.IP(fixed[24:28]),
+	}
+	if dhcp.HLen > 16 {
+		return nil, fmt.Errorf("invalid dhcp hardware address length: %d", dhcp.HLen)
+	}
+	dhcp.CHAddr = net.HardwareAddr(fixed[28 : 28+int(dhcp.HLen)])
+
+	// sname と file (BOOTP) の後にマジッククッキー
+	cookie, err := r.ReadBytes(4)
+	if err != nil || !bytes.Equal(cookie, dhcpMagicCookie) {
+		return nil, errors.New("dhcp magic cookie not found")
+	}
+
+	for r.Remaining() > 0 {
+		code, _ := r.Read8()
+		if code == DHCP_OPTION_END {
+			break
+		}
+		if code == DHCP_OPTION_PAD {
+			continue
+		}
+		length, err := r.Read8()
+		if err != nil {
+			return nil, fmt.Errorf("dhcp option %d too short: %w", code, err)
+		}
+		data, err := r.ReadBytes(int(length))
+		if err != nil {
+			return nil, fmt.Errorf("dhcp option %d too short: %w", code, err)
+		}
+		dhcp.Options = append(dhcp.Options, DHCPOption{Code: code, Data: data})
+	}
+	return dhcp, nil
+}
+
+// Bytes returns the DHCP message, terminated by the end option
+// 終端オプションを付けたDHCPメッセージを返します
+func (d *DHCP) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{d.Op, d.HType, d.HLen, d.Hops})
+	WriteUint32(buf, d.XID)
+	WriteUint16(buf, d.Secs)
+	WriteUint16(buf, d.Flags)
+	for _, addr := range []net.IP{d.CIAddr, d.YIAddr, d.SIAddr, d.GIAddr} {
+		buf.Write(dhcpAddr(addr))
+	}
+	chaddr := make([]byte, 16)
+	copy(chaddr, d.CHAddr)
+	buf.Write(chaddr)
+	buf.Write(make([]byte, 64+128)) // sname, file
+	buf.Write(dhcpMagicCookie)
+	for _, option := range d.Options {
+		buf.WriteByte(option.Code)
+		buf.WriteByte(uint8(len(option.Data)))
+		buf.Write(option.Data)
+	}
+	buf.WriteByte(DHCP_OPTION_END)
+	return buf.Bytes()
+}
+
+func dhcpAddr(addr net.IP) []byte {
+	if addr4 := addr.To4(); addr4 != nil {
+		return addr4
+	}
+	return make([]byte, 4)
+}
+
+// Option returns the data of the first option with code, or nil
+// codeのオプションのデータを返します。無い場合はnil
+func (d *DHCP) Option(code uint8) []byte {
+	for _, option := range d.Options {
+		if option.Code == code {
+			return option.Data
+		}
+	}
+	return nil
+}
+
+// MessageType returns the DHCP message type (option 53), or 0 for a plain BOOTP message
+// DHCPメッセージタイプ(オプション53)を返します。BOOTPのメッセージの場合は0
+func (d *DHCP) MessageType() uint8 {
+	if data := d.Option(DHCP_OPTION_MESSAGE_TYPE); len(data) == 1 {
+		return data[0]
+	}
+	return 0
+}
+
+// ServerID returns the server identifier (option 54)
+// サーバー識別子(オプション54)を返します
+func (d *DHCP) ServerID() net.IP {
+	if data := d.Option(DHCP_OPTION_SERVER_ID); len(data) == net.IPv4len {
+		return net.IP(data)
+	}
+	return nil
+}
+
+// LeaseTime returns the lease time (option 51), or 0 if not present
+// リース時間(オプション51)を返します。無い場合は0
+func (d *DHCP) LeaseTime() time.Duration {
+	if data := d.Option(DHCP_OPTION_LEASE_TIME); len(data) == 4 {
+		return time.Duration(binary.BigEndian.Uint32(data)) * time.Second
+	}
+	return 0
+}
+
+// dhcpAddrs splits an option holding a list of IPv4 addresses, such as routers and DNS servers
+// ルーターやDNSサーバーなど、IPv4アドレスのリストのオプションを分割します
+func dhcpAddrs(data []byte) []net.IP {
+	addrs := []net.IP{}
+	for len(data) >= net.IPv4len {
+		addrs = append(addrs, net.IP(data[:net.IPv4len]))
+		data = data[net.IPv4len:]
+	}
+	return addrs
+}
@@ -0,0 +1,227 @@
+package packemon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// DHCP_CLIENT_TIMEOUT is how long DHCPClient waits for the first reply before retransmitting. It doubles on each retransmission
+// DHCPClientが再送するまでに最初の応答を待つ時間です。再送ごとに2倍になります
+const DHCP_CLIENT_TIMEOUT = 2 * time.Second
+
+// DHCP_CLIENT_RETRIES is how many times DHCPClient retransmits a DISCOVER or REQUEST
+// DHCPClientがDISCOVERやREQUESTを再送する回数です
+const DHCP_CLIENT_RETRIES = 3
+
+// ErrDHCPNak is returned when the server refuses the requested address
+// サーバーが要求したアドレスを拒否した場合に返されます
+var ErrDHCPNak = errors.New("dhcp server sent NAK")
+
+var errDHCPNoReply = errors.New("no dhcp reply")
+
+// DHCPLease is an address leased by a DHCP server and the options that came with it
+// DHCPサーバーから貸し出されたアドレスと、一緒に通知されたオプションです
+type DHCPLease struct {
+	Address    net.IP
+	ServerID   net.IP
+	SubnetMask net.IPMask
+	Routers    []net.IP
+	DNSServers []net.IP
+	LeaseTime  time.Duration
+
+	// Options are all options of the ACK
+	// ACKの全てのオプション
+	Options []DHCPOption
+}
+
+// DHCPClient obtains an address with the DISCOVER, OFFER, REQUEST and ACK exchange over an interface, for lab testing.
+// It does not configure the address on the interface.
+// インターフェース上でDISCOVER、OFFER、REQUEST、ACKのやり取りを行い、アドレスを取得します。検証環境での利用向けです。
+// 取得したアドレスをインターフェースに設定することはしません
+type DHCPClient struct {
+	MAC     net.HardwareAddr
+	Timeout time.Duration
+	Retries int
+
+	send      func(ctx context.Context, frame []byte) error
+	passiveCh <-chan *Passive
+	// receive runs the receive loop until ctx is done. nil if someone else runs it
+	receive func(ctx context.Context)
+}
+
+// NewDHCPClient creates a DHCP client that sends from the MAC address of nwif.
+// Lease runs the receive loop itself, so don't run it together with another receiver such as the Monitor.
+// nwifのMACアドレスから送信するDHCPクライアントを作成します。
+// Leaseは自身で受信ループを動かすため、Monitorなど他の受信処理と同時に動かさないでください
+func NewDHCPClient(nwif *NetworkInterface) *DHCPClient {
+	mac, _, _ := nwif.GetNetworkInfo()
+	return &DHCPClient{
+		MAC:       mac,
+		Timeout:   DHCP_CLIENT_TIMEOUT,
+		Retries:   DHCP_CLIENT_RETRIES,
+		send:      nwif.SendEthernetFrame,
+		passiveCh: nwif.PassiveCh,
+		receive:   nwif.ReceiveEthernetFrame,
+	}
+}
+
+// Lease obtains an address. Replies are matched to the exchange by the transaction ID (xid) and the client MAC address
+// アドレスを取得します。応答はトランザクションID(xid)とクライアントのMACアドレスでやり取りに対応付けます
+func (c *DHCPClient) Lease(ctx context.Context) (*DHCPLease, error) {
+	if len(c.MAC) != 6 {
+		return nil, fmt.Errorf("invalid client MAC address: %s", c.MAC)
+	}
+	if c.receive != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			c.receive(ctx)
+			close(done)
+		}()
+		// 受信ループが止まってから返す
+		defer func() {
+			cancel()
+			<-done
+		}()
+	}
+
+	xid := rand.Uint32()
+	parameters := DHCPOption{
+		Code: DHCP_OPTION_PARAMETER_REQUEST_LIST,
+		Data: []byte{DHCP_OPTION_SUBNET_MASK, DHCP_OPTION_ROUTER, DHCP_OPTION_DNS, DHCP_OPTION_LEASE_TIME, DHCP_OPTION_SERVER_ID},
+	}
+
+	discover := NewDHCPRequest(DHCP_MESSAGE_DISCOVER, xid, c.MAC, parameters)
+	offer, err := c.exchange(ctx, discover, DHCP_MESSAGE_OFFER)
+	if err != nil {
+		return nil, fmt.Errorf("no offer: %w", err)
+	}
+	serverID := offer.ServerID()
+	if serverID == nil {
+		return nil, errors.New("dhcp offer has no server identifier")
+	}
+
+	request := NewDHCPRequest(DHCP_MESSAGE_REQUEST, xid, c.MAC,
+		DHCPOption{Code: DHCP_OPTION_REQUESTED_IP, Data: dhcpAddr(offer.YIAddr)},
+		DHCPOption{Code: DHCP_OPTION_SERVER_ID, Data: dhcpAddr(serverID)},
+		parameters,
+	)
+	ack, err := c.exchange(ctx, request, DHCP_MESSAGE_ACK)
+	if err != nil {
+		return nil, fmt.Errorf("no ack: %w", err)
+	}
+
+	lease := &DHCPLease{
+		Address:    ack.YIAddr,
+		ServerID:   ack.ServerID(),
+		Routers:    dhcpAddrs(ack.Option(DHCP_OPTION_ROUTER)),
+		DNSServers: dhcpAddrs(ack.Option(DHCP_OPTION_DNS)),
+		LeaseTime:  ack.LeaseTime(),
+		Options:    ack.Options,
+	}
+	if mask := ack.Option(DHCP_OPTION_SUBNET_MASK); len(mask) == net.IPv4len {
+		lease.SubnetMask = net.IPMask(mask)
+	}
+	if lease.ServerID == nil {
+		lease.ServerID = serverID
+	}
+	return lease, nil
+}
+
+// exchange sends message and waits for a reply of replyType, retransmitting with exponential backoff.
+// A NAK ends the exchange with ErrDHCPNak
+// messageを送信し、replyTypeの応答を待ちます。応答が無ければ待ち時間を倍にしながら再送します
+func (c *DHCPClient) exchange(ctx context.Context, message *DHCP, replyType uint8) (*DHCP, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DHCP_CLIENT_TIMEOUT
+	}
+	start := time.Now()
+
+	for attempt := 0; attempt <= max(c.Retries, 0); attempt++ {
+		message.Secs = uint16(time.Since(start).Seconds())
+		if err := c.send(ctx, dhcpClientFrame(c.MAC, message)); err != nil {
+			return nil, err
+		}
+
+		reply, err := c.waitReply(ctx, message.XID, replyType, timeout)
+		if !errors.Is(err, errDHCPNoReply) {
+			return reply, err
+		}
+		timeout *= 2
+	}
+	return nil, fmt.Errorf("%w after %d retransmissions", errDHCPNoReply, max(c.Retries, 0))
+}
+
+func (c *DHCPClient) waitReply(ctx context.Context, xid uint32, replyType uint8, timeout time.Duration) (*DHCP, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, errDHCPNoReply
+		case passive := <-c.passiveCh:
+			if passive.UDP == nil || passive.UDP.SrcPort != PORT_DHCP_SERVER || passive.UDP.DstPort != PORT_DHCP_CLIENT {
+				continue
+			}
+			reply, err := ParsedDHCP(passive.UDP.Payload)
+			// 他のクライアントや前のやり取りへの応答は無視する
+			if err != nil || reply.Op != DHCP_OP_REPLY || reply.XID != xid || reply.CHAddr.String() != c.MAC.String() {
+				continue
+			}
+			switch reply.MessageType() {
+			case replyType:
+				return reply, nil
+			case DHCP_MESSAGE_NAK:
+				return nil, ErrDHCPNak
+			}
+		}
+	}
+}
+
+// dhcpClientFrame broadcasts message from 0.0.0.0:68 to 255.255.255.255:67, as a client without an address does
+// アドレスを持たないクライアントとして、messageを0.0.0.0:68から255.255.255.255:67へブロードキャストするフレームを作成します
+func dhcpClientFrame(mac net.HardwareAddr, message *DHCP) []byte {
+	return dhcpFrame(mac, net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		net.IPv4zero, net.IPv4bcast, PORT_DHCP_CLIENT, PORT_DHCP_SERVER, message)
+}
+
+// dhcpFrame builds the Ethernet frame carrying message over IPv4/UDP
+// messageをIPv4/UDPで運ぶEthernetフレームを作成します
+func dhcpFrame(srcMAC, dstMAC net.HardwareAddr, srcIP, dstIP net.IP, srcPort, dstPort uint16, message *DHCP) []byte {
+	return udpIPv4Frame(srcMAC, dstMAC, srcIP, dstIP, srcPort, dstPort, 0x40, message.Bytes())
+}
+
+// udpIPv4Frame builds the Ethernet frame carrying payload over IPv4/UDP. The UDP checksum is left 0, which IPv4 allows
+// payloadをIPv4/UDPで運ぶEthernetフレームを作成します。IPv4ではUDPのチェックサムは省略(0)できます
+func udpIPv4Frame(srcMAC, dstMAC net.HardwareAddr, srcIP, dstIP net.IP, srcPort, dstPort uint16, ttl uint8, payload []byte) []byte {
+	udp := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], srcPort)
+	binary.BigEndian.PutUint16(udp[2:4], dstPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	udp = append(udp, payload...)
+
+	ipv4 := make([]byte, 20, 20+len(udp))
+	ipv4[0] = 0x45
+	binary.BigEndian.PutUint16(ipv4[2:4], uint16(20+len(udp)))
+	ipv4[8] = ttl
+	ipv4[9] = IPv4_PROTO_UDP
+	copy(ipv4[12:16], srcIP.To4())
+	copy(ipv4[16:20], dstIP.To4())
+	binary.BigEndian.PutUint16(ipv4[10:12], calculateInternetChecksum(ipv4))
+	ipv4 = append(ipv4, udp...)
+
+	frame := make([]byte, 14, 14+len(ipv4))
+	copy(frame[0:6], dstMAC)
+	copy(frame[6:12], srcMAC)
+	binary.BigEndian.PutUint16(frame[12:14], ETHER_TYPE_IPv4)
+	return append(frame, ipv4...)
+}
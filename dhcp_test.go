@@ -0,0 +1,183 @@
+package packemon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestParsedDHCP tests that a DHCP message reads back from its bytes with the options
+// DHCPメッセージがオプションとともにバイト列から読み戻せることをテストします
+func TestParsedDHCP(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b}
+	message := NewDHCPRequest(DHCP_MESSAGE_DISCOVER, 0x12345678, mac,
+		DHCPOption{Code: DHCP_OPTION_PARAMETER_REQUEST_LIST, Data: []byte{DHCP_OPTION_SUBNET_MASK, DHCP_OPTION_ROUTER}},
+	)
+
+	passive, err := DecodeFrame(dhcpClientFrame(mac, message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.UDP == nil || passive.UDP.SrcPort != PORT_DHCP_CLIENT || passive.UDP.DstPort != PORT_DHCP_SERVER {
+		t.Fatalf("UDP = %+v, want 68 -> 67", passive.UDP)
+	}
+	got, err := ParsedDHCP(passive.UDP.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Op != DHCP_OP_REQUEST || got.XID != 0x12345678 || got.Flags != DHCP_FLAGS_BROADCAST || got.CHAddr.String() != mac.String() {
+		t.Errorf("ParsedDHCP() = %+v", got)
+	}
+	if got.MessageType() != DHCP_MESSAGE_DISCOVER || len(got.Options) != 2 || len(got.Option(DHCP_OPTION_PARAMETER_REQUEST_LIST)) != 2 {
+		t.Errorf("Options = %+v, want the message type and the parameter request list", got.Options)
+	}
+
+	// オプションの途中で切れている、マジッククッキーが無い
+	b := message.Bytes()
+	for name, payload := range map[string][]byte{
+		"truncated option": b[:len(b)-3],
+		"bootp":            b[:236],
+		"short":            b[:100],
+	} {
+		if _, err := ParsedDHCP(payload); err == nil {
+			t.Errorf("%s: ParsedDHCP() should fail", name)
+		}
+	}
+}
+
+// DHCP サーバーのモック. 受け取ったフレームに対する応答フレームを返す
+type dhcpTestResponder struct {
+	t        *testing.T
+	replyCh  chan *Passive
+	received []uint8
+	// drop は最初に届いた DISCOVER を無視して再送させる
+	drop bool
+	nak  bool
+}
+
+func (r *dhcpTestResponder) send(ctx context.Context, frame []byte) error {
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		return err
+	}
+	if passive.IPv4 == nil || passive.UDP == nil || passive.UDP.DstPort != PORT_DHCP_SERVER {
+		r.t.Errorf("client sent %+v, want a DHCP message to port 67", passive)
+		return nil
+	}
+	request, err := ParsedDHCP(passive.UDP.Payload)
+	if err != nil {
+		return err
+	}
+	r.received = append(r.received, request.MessageType())
+
+	server := net.IPv4(192, 168, 10, 1).To4()
+	reply := &DHCP{
+		Op:     DHCP_OP_REPLY,
+		HType:  request.HType,
+		HLen:   request.HLen,
+		XID:    request.XID,
+		YIAddr: net.IPv4(192, 168, 10, 50).To4(),
+		SIAddr: server,
+		CHAddr: request.CHAddr,
+		Options: []DHCPOption{
+			{Code: DHCP_OPTION_SERVER_ID, Data: server},
+			{Code: DHCP_OPTION_SUBNET_MASK, Data: []byte{255, 255, 255, 0}},
+			{Code: DHCP_OPTION_ROUTER, Data: server},
+			{Code: DHCP_OPTION_DNS, Data: []byte{192, 168, 10, 1, 8, 8, 8, 8}},
+			{Code: DHCP_OPTION_LEASE_TIME, Data: binary.BigEndian.AppendUint32(nil, 3600)},
+		},
+	}
+
+	switch request.MessageType() {
+	case DHCP_MESSAGE_DISCOVER:
+		if r.drop {
+			r.drop = false
+			return nil
+		}
+		// 別のトランザクションへの応答は無視される
+		stale := *reply
+		stale.XID++
+		stale.Options = append([]DHCPOption{{Code: DHCP_OPTION_MESSAGE_TYPE, Data: []byte{DHCP_MESSAGE_OFFER}}}, reply.Options...)
+		stale.YIAddr = net.IPv4(192, 168, 10, 99).To4()
+		r.reply(&stale)
+		reply.Options = append([]DHCPOption{{Code: DHCP_OPTION_MESSAGE_TYPE, Data: []byte{DHCP_MESSAGE_OFFER}}}, reply.Options...)
+	case DHCP_MESSAGE_REQUEST:
+		if got := net.IP(request.Option(DHCP_OPTION_REQUESTED_IP)); !got.Equal(reply.YIAddr) {
+			r.t.Errorf("requested IP = %s, want the offered %s", got, reply.YIAddr)
+		}
+		messageType := uint8(DHCP_MESSAGE_ACK)
+		if r.nak {
+			messageType = DHCP_MESSAGE_NAK
+		}
+		reply.Options = append([]DHCPOption{{Code: DHCP_OPTION_MESSAGE_TYPE, Data: []byte{messageType}}}, reply.Options...)
+	}
+	r.reply(reply)
+	return nil
+}
+
+func (r *dhcpTestResponder) reply(message *DHCP) {
+	frame := dhcpFrame(net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}, net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		message.SIAddr, net.IPv4bcast, PORT_DHCP_SERVER, PORT_DHCP_CLIENT, message)
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.replyCh <- passive
+}
+
+func dhcpTestClient(responder *dhcpTestResponder) *DHCPClient {
+	responder.replyCh = make(chan *Passive, 10)
+	return &DHCPClient{
+		MAC:       net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b},
+		Timeout:   20 * time.Millisecond,
+		Retries:   DHCP_CLIENT_RETRIES,
+		send:      responder.send,
+		passiveCh: responder.replyCh,
+	}
+}
+
+// TestDHCPClientLease tests the DISCOVER, OFFER, REQUEST and ACK exchange against a mock server that drops the first DISCOVER
+// 最初のDISCOVERを無視するモックのサーバーを相手に、DISCOVER、OFFER、REQUEST、ACKのやり取りをテストします
+func TestDHCPClientLease(t *testing.T) {
+	responder := &dhcpTestResponder{t: t, drop: true}
+	client := dhcpTestClient(responder)
+
+	lease, err := client.Lease(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint8{DHCP_MESSAGE_DISCOVER, DHCP_MESSAGE_DISCOVER, DHCP_MESSAGE_REQUEST}
+	if len(responder.received) != len(want) || responder.received[1] != want[1] || responder.received[2] != want[2] {
+		t.Errorf("server received %v, want %v (DISCOVER retransmitted)", responder.received, want)
+	}
+
+	if !lease.Address.Equal(net.IPv4(192, 168, 10, 50)) || !lease.ServerID.Equal(net.IPv4(192, 168, 10, 1)) {
+		t.Errorf("lease = %s from %s, want 192.168.10.50 from 192.168.10.1", lease.Address, lease.ServerID)
+	}
+	if lease.SubnetMask.String() != "ffffff00" || len(lease.Routers) != 1 || len(lease.DNSServers) != 2 || lease.LeaseTime != time.Hour {
+		t.Errorf("lease options = mask %s, routers %v, dns %v, lease time %s", lease.SubnetMask, lease.Routers, lease.DNSServers, lease.LeaseTime)
+	}
+}
+
+// TestDHCPClientLeaseFailure tests a NAK from the server and a server that never replies
+// サーバーからのNAKと、応答しないサーバーをテストします
+func TestDHCPClientLeaseFailure(t *testing.T) {
+	responder := &dhcpTestResponder{t: t, nak: true}
+	if _, err := dhcpTestClient(responder).Lease(context.Background()); !errors.Is(err, ErrDHCPNak) {
+		t.Errorf("Lease() = %v, want ErrDHCPNak", err)
+	}
+
+	silent := &DHCPClient{
+		MAC:       net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b},
+		Timeout:   time.Millisecond,
+		Retries:   2,
+		send:      func(ctx context.Context, frame []byte) error { return nil },
+		passiveCh: make(chan *Passive),
+	}
+	if _, err := silent.Lease(context.Background()); !errors.Is(err, errDHCPNoReply) {
+		t.Errorf("Lease() with no server = %v, want no reply", err)
+	}
+}
@@ -0,0 +1,85 @@
+package packemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Names of the diagnostic helpers, used as DiagnosticResult.Helper
+// 診断ヘルパーの名前で、DiagnosticResult.Helperに使います
+const (
+	DIAGNOSTIC_PING       = "ping"
+	DIAGNOSTIC_TRACEROUTE = "traceroute"
+	DIAGNOSTIC_DNS_QUERY  = "dns"
+	DIAGNOSTIC_RESOLVE    = "resolve"
+	DIAGNOSTIC_SELF_TEST  = "self-test"
+)
+
+// DiagnosticResult is the common outcome of the diagnostic helpers such as ping, traceroute and DNS queries,
+// so that the TUI and the CLI can render any of them the same way
+// ping、traceroute、DNS問い合わせなどの診断ヘルパーに共通の結果です。TUIやCLIがどれも同じように表示できます
+type DiagnosticResult struct {
+	// Helper is the name of the helper, e.g. DIAGNOSTIC_PING
+	// ヘルパーの名前。例えばDIAGNOSTIC_PING
+	Helper string
+	// Target is what was diagnosed, e.g. an address, a domain or an interface
+	// 診断した対象。例えばアドレス、ドメイン、インターフェース
+	Target  string
+	Success bool
+	// Latency is the round-trip time on success, or how long the helper waited on failure. 0 if unknown
+	// 成功した場合は往復時間、失敗した場合は待った時間。不明な場合は0
+	Latency time.Duration
+	// Response is the raw response, e.g. the echo reply or the DNS message. nil if none was received
+	// 生の応答。例えばエコー応答やDNSメッセージ。受信しなかった場合はnil
+	Response []byte
+	// Err is why the helper failed. nil on success
+	// ヘルパーが失敗した理由。成功した場合はnil
+	Err error
+}
+
+// NewDiagnosticResult creates the result of a helper, which succeeded if err is nil
+// ヘルパーの結果を作成します。errがnilの場合は成功です
+func NewDiagnosticResult(helper, target string, latency time.Duration, response []byte, err error) DiagnosticResult {
+	return DiagnosticResult{
+		Helper:   helper,
+		Target:   target,
+		Success:  err == nil,
+		Latency:  latency,
+		Response: response,
+		Err:      err,
+	}
+}
+
+func (r DiagnosticResult) String() string {
+	s := fmt.Sprintf("%s %s: ", r.Helper, r.Target)
+	if r.Success {
+		s += "OK"
+		if r.Latency > 0 {
+			s += fmt.Sprintf(" in %s", r.Latency.Round(time.Microsecond))
+		}
+		if len(r.Response) > 0 {
+			s += fmt.Sprintf(" (%d bytes)", len(r.Response))
+		}
+		return s
+	}
+
+	s += "FAIL"
+	if r.Latency > 0 {
+		s += fmt.Sprintf(" after %s", r.Latency.Round(time.Microsecond))
+	}
+	if r.Err != nil {
+		s += ": " + r.Err.Error()
+	}
+	return s
+}
+
+// DiagnosticResult returns the self-test as a DiagnosticResult, with the diagnostic as the error on failure
+// セルフテストをDiagnosticResultとして返します。失敗した場合は診断内容がエラーになります
+func (r SelfTestResult) DiagnosticResult() DiagnosticResult {
+	var err error
+	if !r.Passed {
+		err = errors.New(r.Diagnostic)
+	}
+	return NewDiagnosticResult(DIAGNOSTIC_SELF_TEST, r.Interface, r.Elapsed, nil, err)
+}
@@ -0,0 +1,65 @@
+package packemon
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestDiagnosticResult tests constructing the results of diagnostic helpers and rendering them
+// 診断ヘルパーの結果を作成し、表示することをテストします
+func TestDiagnosticResult(t *testing.T) {
+	timeout := errors.New("no reply within 3s")
+	tests := []struct {
+		name    string
+		result  DiagnosticResult
+		success bool
+		want    string
+	}{
+		{
+			name:    "ping reply",
+			result:  NewDiagnosticResult(DIAGNOSTIC_PING, "192.168.10.1", 1234567*time.Nanosecond, make([]byte, 64), nil),
+			success: true,
+			want:    "ping 192.168.10.1: OK in 1.235ms (64 bytes)",
+		},
+		{
+			name:    "ping timeout",
+			result:  NewDiagnosticResult(DIAGNOSTIC_PING, "192.168.10.1", 3*time.Second, nil, timeout),
+			success: false,
+			want:    "ping 192.168.10.1: FAIL after 3s: no reply within 3s",
+		},
+		{
+			name:    "resolve without latency",
+			result:  NewDiagnosticResult(DIAGNOSTIC_RESOLVE, "go.dev", 0, nil, nil),
+			success: true,
+			want:    "resolve go.dev: OK",
+		},
+		{
+			name:    "self-test failure",
+			result:  SelfTestResult{Interface: "eth0", Diagnostic: "the interface is offline, so nothing can be sent"}.DiagnosticResult(),
+			success: false,
+			want:    "self-test eth0: FAIL: the interface is offline, so nothing can be sent",
+		},
+		{
+			name:    "self-test pass",
+			result:  SelfTestResult{Passed: true, Interface: "lo", Elapsed: 2 * time.Millisecond}.DiagnosticResult(),
+			success: true,
+			want:    "self-test lo: OK in 2ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result.Success != tt.success || (tt.result.Err == nil) != tt.success {
+				t.Errorf("Success = %v, Err = %v, want success %v", tt.result.Success, tt.result.Err, tt.success)
+			}
+			if got := tt.result.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if result := NewDiagnosticResult(DIAGNOSTIC_PING, "192.168.10.1", 0, nil, timeout); !errors.Is(result.Err, timeout) {
+		t.Errorf("Err = %v, want %v", result.Err, timeout)
+	}
+}
@@ -0,0 +1,517 @@
+package packemon
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DisplayFilter is a compiled Wireshark style display filter such as "tcp.flags.syn == 1 && ip.addr == 192.168.10.110".
+// A bare field (e.g. "dns") matches when the field is present. Supported operators are ==, !=, <, <=, >, >=, &&, ||, ! and parentheses.
+// Wiresharkのようなディスプレイフィルタをコンパイルしたものです
+type DisplayFilter struct {
+	expr string
+	root filterNode
+}
+
+// CompileDisplayFilter compiles a display filter expression
+// ディスプレイフィルタの式をコンパイルします
+func CompileDisplayFilter(expr string) (*DisplayFilter, error) {
+	tokens, err := tokenizeDisplayFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty display filter")
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid display filter %q: %w", expr, err)
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("invalid display filter %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return &DisplayFilter{expr: expr, root: root}, nil
+}
+
+// Match reports whether the packet matches the filter
+// パケットがフィルタに一致するかを返します
+func (f *DisplayFilter) Match(passive *Passive) bool {
+	if f == nil || passive == nil {
+		return false
+	}
+	return f.root.match(passive)
+}
+
+func (f *DisplayFilter) String() string {
+	return f.expr
+}
+
+// フィールドの値。数値かアドレスのどちらか
+type filterValue struct {
+	num uint64
+	ip  net.IP
+}
+
+type filterField struct {
+	isAddr bool
+	// レイヤが無ければ nil を返す
+	values func(p *Passive) []filterValue
+}
+
+func numField(get func(p *Passive) ([]uint64, bool)) filterField {
+	return filterField{
+		values: func(p *Passive) []filterValue {
+			nums, ok := get(p)
+			if !ok {
+				return nil
+			}
+			values := make([]filterValue, len(nums))
+			for i := range nums {
+				values[i].num = nums[i]
+			}
+			return values
+		},
+	}
+}
+
+func addrField(get func(p *Passive) ([][]byte, bool)) filterField {
+	return filterField{
+		isAddr: true,
+		values: func(p *Passive) []filterValue {
+			addrs, ok := get(p)
+			if !ok {
+				return nil
+			}
+			values := make([]filterValue, len(addrs))
+			for i := range addrs {
+				values[i].ip = net.IP(addrs[i])
+			}
+			return values
+		},
+	}
+}
+
+func tcpFlagField(flag uint8) filterField {
+	return numField(func(p *Passive) ([]uint64, bool) {
+		if p.TCP == nil {
+			return nil, false
+		}
+		if p.TCP.Flags&flag != 0 {
+			return []uint64{1}, true
+		}
+		return []uint64{0}, true
+	})
+}
+
+// 存在するかどうかだけを見るプロトコル名
+var displayFilterProtocols = map[string]func(p *Passive) bool{
+	"eth":      func(p *Passive) bool { return p.EthernetFrame != nil },
+	"arp":      func(p *Passive) bool { return p.ARP != nil },
+	"ip":       func(p *Passive) bool { return p.IPv4 != nil },
+	"ipv6":     func(p *Passive) bool { return p.IPv6 != nil },
+	"icmp":     func(p *Passive) bool { return p.ICMP != nil },
+	"icmpv6":   func(p *Passive) bool { return p.ICMPv6 != nil },
+	"tcp":      func(p *Passive) bool { return p.TCP != nil },
+	"udp":      func(p *Passive) bool { return p.UDP != nil },
+	"tls":      func(p *Passive) bool { return p.TLS != nil },
+	"dns":      func(p *Passive) bool { return p.DNS != nil },
+	"http":     func(p *Passive) bool { return p.HTTP != nil || p.HTTPRes != nil },
+	"rtp":      func(p *Passive) bool { return p.RTP != nil },
+	"geneve":   func(p *Passive) bool { return p.GENEVE != nil },
+	"nbss":     func(p *Passive) bool { return p.SMB != nil },
+	"smb":      func(p *Passive) bool { return p.SMB != nil && p.SMB.Version == 1 },
+	"smb2":     func(p *Passive) bool { return p.SMB != nil && p.SMB.Version == 2 },
+	"wlan":     func(p *Passive) bool { return p.IEEE80211 != nil },
+	"radiotap": func(p *Passive) bool { return p.IEEE80211 != nil && p.IEEE80211.Radiotap != nil },
+}
+
+var displayFilterFields = map[string]filterField{
+	"frame.len": numField(func(p *Passive) ([]uint64, bool) {
+		return []uint64{uint64(p.RawLength)}, true
+	}),
+	"eth.type": numField(func(p *Passive) ([]uint64, bool) {
+		if p.EthernetFrame == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.EthernetFrame.Type)}, true
+	}),
+	"arp.opcode": numField(func(p *Passive) ([]uint64, bool) {
+		if p.ARP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.ARP.Operation)}, true
+	}),
+
+	"ip.src": addrField(func(p *Passive) ([][]byte, bool) {
+		if p.IPv4 == nil {
+			return nil, false
+		}
+		return [][]byte{p.IPv4.SrcIP}, true
+	}),
+	"ip.dst": addrField(func(p *Passive) ([][]byte, bool) {
+		if p.IPv4 == nil {
+			return nil, false
+		}
+		return [][]byte{p.IPv4.DstIP}, true
+	}),
+	"ip.addr": addrField(func(p *Passive) ([][]byte, bool) {
+		if p.IPv4 == nil {
+			return nil, false
+		}
+		return [][]byte{p.IPv4.SrcIP, p.IPv4.DstIP}, true
+	}),
+	"ip.ttl": numField(func(p *Passive) ([]uint64, bool) {
+		if p.IPv4 == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.IPv4.TTL)}, true
+	}),
+	"ip.proto": numField(func(p *Passive) ([]uint64, bool) {
+		if p.IPv4 == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.IPv4.Protocol)}, true
+	}),
+
+	"ipv6.src": addrField(func(p *Passive) ([][]byte, bool) {
+		if p.IPv6 == nil {
+			return nil, false
+		}
+		return [][]byte{p.IPv6.SrcIP}, true
+	}),
+	"ipv6.dst": addrField(func(p *Passive) ([][]byte, bool) {
+		if p.IPv6 == nil {
+			return nil, false
+		}
+		return [][]byte{p.IPv6.DstIP}, true
+	}),
+	"ipv6.addr": addrField(func(p *Passive) ([][]byte, bool) {
+		if p.IPv6 == nil {
+			return nil, false
+		}
+		return [][]byte{p.IPv6.SrcIP, p.IPv6.DstIP}, true
+	}),
+	"ipv6.hlim": numField(func(p *Passive) ([]uint64, bool) {
+		if p.IPv6 == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.IPv6.HopLimit)}, true
+	}),
+	"ipv6.nxt": numField(func(p *Passive) ([]uint64, bool) {
+		if p.IPv6 == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.IPv6.NextHeader)}, true
+	}),
+
+	"icmp.type": numField(func(p *Passive) ([]uint64, bool) {
+		if p.ICMP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.ICMP.Type)}, true
+	}),
+	"icmp.code": numField(func(p *Passive) ([]uint64, bool) {
+		if p.ICMP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.ICMP.Code)}, true
+	}),
+	"icmpv6.type": numField(func(p *Passive) ([]uint64, bool) {
+		if p.ICMPv6 == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.ICMPv6.Type)}, true
+	}),
+	"icmpv6.code": numField(func(p *Passive) ([]uint64, bool) {
+		if p.ICMPv6 == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.ICMPv6.Code)}, true
+	}),
+
+	"tcp.srcport": numField(func(p *Passive) ([]uint64, bool) {
+		if p.TCP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.TCP.SrcPort)}, true
+	}),
+	"tcp.dstport": numField(func(p *Passive) ([]uint64, bool) {
+		if p.TCP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.TCP.DstPort)}, true
+	}),
+	"tcp.port": numField(func(p *Passive) ([]uint64, bool) {
+		if p.TCP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.TCP.SrcPort), uint64(p.TCP.DstPort)}, true
+	}),
+	"tcp.flags": numField(func(p *Passive) ([]uint64, bool) {
+		if p.TCP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.TCP.Flags)}, true
+	}),
+	"tcp.flags.fin":   tcpFlagField(0x01),
+	"tcp.flags.syn":   tcpFlagField(0x02),
+	"tcp.flags.reset": tcpFlagField(0x04),
+	"tcp.flags.push":  tcpFlagField(0x08),
+	"tcp.flags.ack":   tcpFlagField(0x10),
+	"tcp.flags.urg":   tcpFlagField(0x20),
+
+	"geneve.vni": numField(func(p *Passive) ([]uint64, bool) {
+		if p.GENEVE == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.GENEVE.VNI)}, true
+	}),
+
+	"smb2.cmd": numField(func(p *Passive) ([]uint64, bool) {
+		if p.SMB == nil || p.SMB.Version != 2 || p.SMB.Encrypted {
+			return nil, false
+		}
+		return []uint64{uint64(p.SMB.Command)}, true
+	}),
+	"smb2.tid": numField(func(p *Passive) ([]uint64, bool) {
+		if p.SMB == nil || p.SMB.Version != 2 || p.SMB.Encrypted {
+			return nil, false
+		}
+		return []uint64{uint64(p.SMB.TreeID)}, true
+	}),
+
+	"udp.srcport": numField(func(p *Passive) ([]uint64, bool) {
+		if p.UDP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.UDP.SrcPort)}, true
+	}),
+	"udp.dstport": numField(func(p *Passive) ([]uint64, bool) {
+		if p.UDP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.UDP.DstPort)}, true
+	}),
+	"udp.port": numField(func(p *Passive) ([]uint64, bool) {
+		if p.UDP == nil {
+			return nil, false
+		}
+		return []uint64{uint64(p.UDP.SrcPort), uint64(p.UDP.DstPort)}, true
+	}),
+}
+
+type filterNode interface {
+	match(p *Passive) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+
+func (n filterAnd) match(p *Passive) bool { return n.left.match(p) && n.right.match(p) }
+
+type filterOr struct{ left, right filterNode }
+
+func (n filterOr) match(p *Passive) bool { return n.left.match(p) || n.right.match(p) }
+
+type filterNot struct{ node filterNode }
+
+func (n filterNot) match(p *Passive) bool { return !n.node.match(p) }
+
+type filterProtocol struct{ present func(p *Passive) bool }
+
+func (n filterProtocol) match(p *Passive) bool { return n.present(p) }
+
+type filterExists struct{ field filterField }
+
+func (n filterExists) match(p *Passive) bool { return n.field.values(p) != nil }
+
+// 複数の値を持つフィールド(ip.addr など)は、いずれかが条件を満たせば一致とする(Wireshark と同じ)
+type filterCompare struct {
+	field filterField
+	op    string
+	value filterValue
+}
+
+func (n filterCompare) match(p *Passive) bool {
+	for _, v := range n.field.values(p) {
+		if n.field.isAddr {
+			equal := v.ip.Equal(n.value.ip)
+			if (n.op == "==") == equal {
+				return true
+			}
+			continue
+		}
+
+		var ok bool
+		switch n.op {
+		case "==":
+			ok = v.num == n.value.num
+		case "!=":
+			ok = v.num != n.value.num
+		case "<":
+			ok = v.num < n.value.num
+		case "<=":
+			ok = v.num <= n.value.num
+		case ">":
+			ok = v.num > n.value.num
+		case ">=":
+			ok = v.num >= n.value.num
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenizeDisplayFilter(expr string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||") ||
+			strings.HasPrefix(expr[i:], "==") || strings.HasPrefix(expr[i:], "!=") ||
+			strings.HasPrefix(expr[i:], "<=") || strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case c == '!' || c == '<' || c == '>':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '&' || c == '|' || c == '=':
+			return nil, fmt.Errorf("invalid display filter %q: unexpected %q at %d", expr, c, i)
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t()&|=!<>", rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, expr[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := strings.ToLower(p.peek()); t == "||" || t == "or"; t = strings.ToLower(p.peek()) {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := strings.ToLower(p.peek()); t == "&&" || t == "and"; t = strings.ToLower(p.peek()) {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if t := strings.ToLower(p.peek()); t == "!" || t == "not" {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	t := p.next()
+	switch t {
+	case "":
+		return nil, fmt.Errorf("unexpected end of filter")
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return node, nil
+	case ")", "&&", "||", "==", "!=", "<", "<=", ">", ">=":
+		return nil, fmt.Errorf("unexpected %q", t)
+	}
+
+	name := strings.ToLower(t)
+	if present, ok := displayFilterProtocols[name]; ok {
+		return filterProtocol{present}, nil
+	}
+	field, ok := displayFilterFields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", t)
+	}
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+	default:
+		return filterExists{field}, nil
+	}
+
+	literal := p.next()
+	if literal == "" {
+		return nil, fmt.Errorf("missing value for %s", name)
+	}
+	if field.isAddr {
+		if op != "==" && op != "!=" {
+			return nil, fmt.Errorf("operator %s is not supported for %s", op, name)
+		}
+		ip := net.ParseIP(literal)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q for %s", literal, name)
+		}
+		return filterCompare{field: field, op: op, value: filterValue{ip: ip}}, nil
+	}
+	num, err := strconv.ParseUint(literal, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q for %s", literal, name)
+	}
+	return filterCompare{field: field, op: op, value: filterValue{num: num}}, nil
+}
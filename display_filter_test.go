@@ -0,0 +1,79 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+)
+
+// TestDisplayFilter tests matching of display filter expressions
+// ディスプレイフィルタの式の評価をテストします
+func TestDisplayFilter(t *testing.T) {
+	passive := &Passive{
+		EthernetFrame: &EthernetFrame{Type: ETHER_TYPE_IPv4},
+		IPv4: &IPv4Packet{
+			TTL:      64,
+			Protocol: IPv4_PROTO_TCP,
+			SrcIP:    net.ParseIP("192.168.10.110").To4(),
+			DstIP:    net.ParseIP("192.168.10.1").To4(),
+		},
+		TCP:       &TCPPacket{SrcPort: 50000, DstPort: 443, Flags: TCP_FLAGS_SYN},
+		RawLength: 74,
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"tcp", true},
+		{"udp", false},
+		{"!udp", true},
+		{"not udp and tcp", true},
+		{"tcp.flags.syn == 1", true},
+		{"tcp.flags.reset == 1", false},
+		{"tcp.flags == 0x02", true},
+		{"tcp.port == 443", true},
+		{"tcp.dstport == 50000", false},
+		{"tcp.srcport >= 1024 && tcp.dstport < 1024", true},
+		{"ip.addr == 192.168.10.1", true},
+		{"ip.src == 192.168.10.1", false},
+		{"ip.dst != 192.168.10.1", false},
+		{"ip.ttl > 64", false},
+		{"eth.type == 0x0800", true},
+		{"frame.len == 74", true},
+		{"udp.port == 53 || (tcp && ip.ttl == 64)", true},
+		{"(udp || arp) && tcp", false},
+		{"ipv6.addr == 2001:db8::1", false},
+	}
+
+	for _, tt := range tests {
+		filter, err := CompileDisplayFilter(tt.expr)
+		if err != nil {
+			t.Errorf("CompileDisplayFilter(%q) returned error: %v", tt.expr, err)
+			continue
+		}
+		if got := filter.Match(passive); got != tt.want {
+			t.Errorf("%q: Match() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestCompileDisplayFilterInvalid tests that malformed expressions are rejected
+// 不正な式がエラーになることをテストします
+func TestCompileDisplayFilterInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"tcp &&",
+		"(tcp",
+		"tcp)",
+		"tcp.port ==",
+		"tcp.port == http",
+		"ip.addr == 999.1.1.1",
+		"ip.addr > 192.168.10.1",
+		"foo.bar == 1",
+		"tcp & udp",
+	} {
+		if _, err := CompileDisplayFilter(expr); err == nil {
+			t.Errorf("CompileDisplayFilter(%q) should fail", expr)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package packemon
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"strings"
 )
 
@@ -23,6 +24,41 @@ func IsDNSResponse(flags uint16) bool {
 	return flags&DNS_QR_RESPONSE == DNS_QR_RESPONSE
 }
 
+// https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml#dns-parameters-6 の「RCODE」
+// flags の下位4ビット
+const (
+	DNS_RCODE_NOERROR  = 0
+	DNS_RCODE_FORMERR  = 1
+	DNS_RCODE_SERVFAIL = 2
+	DNS_RCODE_NXDOMAIN = 3
+	DNS_RCODE_NOTIMP   = 4
+	DNS_RCODE_REFUSED  = 5
+)
+
+var dnsRcodeNames = map[uint8]string{
+	DNS_RCODE_NOERROR:  "NOERROR",
+	DNS_RCODE_FORMERR:  "FORMERR",
+	DNS_RCODE_SERVFAIL: "SERVFAIL",
+	DNS_RCODE_NXDOMAIN: "NXDOMAIN",
+	DNS_RCODE_NOTIMP:   "NOTIMP",
+	DNS_RCODE_REFUSED:  "REFUSED",
+}
+
+// DNSRcode returns the response code in the low 4 bits of the DNS flags
+// DNSのフラグの下位4ビットの応答コードを返します
+func DNSRcode(flags uint16) uint8 {
+	return uint8(flags & 0x000f)
+}
+
+// DNSRcodeName returns the name of the response code, e.g. "NXDOMAIN", or "RCODE<n>" for the other codes
+// 応答コードの名前("NXDOMAIN"など)を返します。それ以外のコードは"RCODE<n>"を返します
+func DNSRcodeName(rcode uint8) string {
+	if name, ok := dnsRcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
 const (
 	DNS_QUERY_TYPE_A    = 0x0001
 	DNS_QUERY_TYPE_AAAA = 0x001c
@@ -43,6 +79,10 @@ type DNS struct {
 	AdditionalRRs uint16
 	Queries       *Queries
 	Answers       []*Answer
+
+	// Answers と違い、全セクションの RR をタイプに関わらず保持する
+	Records []*DNSResourceRecord
+	EDNS0   *DNSOPT
 }
 
 // TODO: 個別にQueryで定義してスライスで持つようにする
@@ -78,7 +118,7 @@ func ParsedDNSRequest(payload []byte) *DNS {
 		Class:  binary.BigEndian.Uint16(payload[offset+2 : offset+4]),
 	}
 
-	return &DNS{
+	dns := &DNS{
 		TransactionID: binary.BigEndian.Uint16(payload[0:2]),
 		Flags:         flags,
 		Questions:     qCnt,
@@ -87,6 +127,8 @@ func ParsedDNSRequest(payload []byte) *DNS {
 		AdditionalRRs: adCnt,
 		Queries:       q,
 	}
+	dns.parseRecords(payload)
+	return dns
 }
 
 func ParsedDNSResponse(payload []byte) *DNS {
@@ -119,7 +161,7 @@ func ParsedDNSResponse(payload []byte) *DNS {
 		answers = append(answers, a)
 	}
 
-	return &DNS{
+	dns := &DNS{
 		TransactionID: binary.BigEndian.Uint16(payload[0:2]),
 		Flags:         flags,
 		Questions:     qCnt,
@@ -129,6 +171,38 @@ func ParsedDNSResponse(payload []byte) *DNS {
 		Queries:       q, // TODO: スライスで持つ
 		Answers:       answers,
 	}
+	dns.parseRecords(payload)
+	return dns
+}
+
+// 途中で壊れていても、解析できたところまでの RR は保持する
+func (d *DNS) parseRecords(payload []byte) {
+	records, err := ParsedDNSResourceRecords(payload)
+	if err != nil {
+		logParseFailure("DNS", payload)
+	}
+	d.Records = records
+	for _, rr := range records {
+		if rr.Typ == DNS_QUERY_TYPE_OPT {
+			d.EDNS0 = ParsedDNSOPT(rr)
+		}
+	}
+}
+
+// NewDNSQuery creates a standard query for domain with the query type qtype (e.g. DNS_QUERY_TYPE_A), taking its transaction ID from DNSTransactionIDs
+// DNSTransactionIDsからトランザクションIDを取得して、domainに対するqtype(DNS_QUERY_TYPE_Aなど)の標準クエリを作成します
+func NewDNSQuery(domain string, qtype uint16) *DNS {
+	dns := &DNS{
+		TransactionID: DNSTransactionIDs.Next(),
+		Flags:         0x0100, // standard query
+		Questions:     0x0001,
+		Queries: &Queries{
+			Typ:   qtype,
+			Class: DNS_QUERY_CLASS_IN,
+		},
+	}
+	dns.Domain(domain)
+	return dns
 }
 
 func (d *DNS) Domain(domain string) {
@@ -0,0 +1,338 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DNS_MISMATCH_DEFAULT_TIMEOUT is how long a query is remembered. Duplicate responses are only detected within it
+	// クエリを覚えておく時間です。重複レスポンスはこの時間内のものだけ検出します
+	DNS_MISMATCH_DEFAULT_TIMEOUT = 5 * time.Second
+	// DNS_MISMATCH_DEFAULT_MAX_PENDING is the number of queries remembered at once. The oldest is dropped beyond it
+	// 同時に覚えておくクエリ数です。超えた場合は古いものから破棄します
+	DNS_MISMATCH_DEFAULT_MAX_PENDING = 4096
+)
+
+// DNSAlertKind is the reason a DNS response was flagged
+// DNSレスポンスを検出した理由です
+type DNSAlertKind uint8
+
+const (
+	DNS_ALERT_QUESTION_MISMATCH  DNSAlertKind = iota + 1 // 問い合わせと異なる質問
+	DNS_ALERT_UNEXPECTED_SOURCE                          // 問い合わせ先以外からの応答
+	DNS_ALERT_OUT_OF_BAILIWICK                           // 問い合わせたゾーン外のレコード
+	DNS_ALERT_DUPLICATE_MISMATCH                         // 内容の異なる重複レスポンス
+)
+
+var dnsAlertKindNames = map[DNSAlertKind]string{
+	DNS_ALERT_QUESTION_MISMATCH:  "question mismatch",
+	DNS_ALERT_UNEXPECTED_SOURCE:  "unexpected source",
+	DNS_ALERT_OUT_OF_BAILIWICK:   "out of bailiwick",
+	DNS_ALERT_DUPLICATE_MISMATCH: "duplicate mismatch",
+}
+
+func (k DNSAlertKind) String() string {
+	if name, ok := dnsAlertKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("DNSAlertKind(%d)", uint8(k))
+}
+
+// DNSAlert is a DNS response that does not match the query it answers
+// 問い合わせと一致しないDNSレスポンスです
+type DNSAlert struct {
+	Kind          DNSAlertKind
+	TransactionID uint16
+	Query         string // 問い合わせたドメイン名
+	Client        string // 問い合わせ元のアドレス
+	Resolver      string // 問い合わせ先のアドレス
+	Source        string // レスポンスの送信元アドレス
+	Detail        string
+}
+
+func (a DNSAlert) String() string {
+	return fmt.Sprintf("DNS %s: id=0x%04x query=%s client=%s resolver=%s source=%s %s",
+		a.Kind, a.TransactionID, a.Query, a.Client, a.Resolver, a.Source, a.Detail)
+}
+
+// DNSMismatchDetector correlates DNS queries and responses seen on the wire and flags responses that look spoofed:
+// a different question, a source other than the queried resolver, records outside the queried zone,
+// and duplicate responses whose answers differ.
+// 観測したDNSのクエリとレスポンスを対応付け、偽装の疑いがあるレスポンスを検出します
+type DNSMismatchDetector struct {
+	timeout    time.Duration
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[dnsQueryKey]*dnsPendingQuery
+	// 登録順(=期限順)のキー. 期限切れと上限超過の破棄に使う
+	order []dnsQueryKey
+}
+
+// レスポンスの宛先から引けるように、問い合わせ元と Transaction ID で対応付ける
+type dnsQueryKey struct {
+	client string
+	port   uint16
+	id     uint16
+}
+
+type dnsPendingQuery struct {
+	resolver string
+	qname    string
+	qtype    uint16
+	deadline time.Time
+
+	answered bool
+	answer   string // 最初のレスポンスの内容. 重複レスポンスとの比較に使う
+}
+
+// NewDNSMismatchDetector creates a detector. Zero or less uses the defaults.
+// 検出器を作成します。0以下の場合はデフォルト値を使います
+func NewDNSMismatchDetector(timeout time.Duration, maxPending int) *DNSMismatchDetector {
+	if timeout <= 0 {
+		timeout = DNS_MISMATCH_DEFAULT_TIMEOUT
+	}
+	if maxPending <= 0 {
+		maxPending = DNS_MISMATCH_DEFAULT_MAX_PENDING
+	}
+	return &DNSMismatchDetector{
+		timeout:    timeout,
+		maxPending: maxPending,
+		pending:    map[dnsQueryKey]*dnsPendingQuery{},
+	}
+}
+
+// Observe feeds a received packet to the detector and returns the alerts for it. Non DNS packets are ignored.
+// 受信したパケットを検出器に渡し、そのパケットに対するアラートを返します。DNS以外のパケットは無視します
+func (d *DNSMismatchDetector) Observe(p *Passive, now time.Time) []DNSAlert {
+	if p == nil || p.DNS == nil {
+		return nil
+	}
+	src, dst, srcPort, dstPort, ok := dnsEndpoints(p)
+	if !ok {
+		return nil
+	}
+	msg := p.DNS.message()
+	qname, qtype, err := dnsQuestion(msg)
+	if err != nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(now)
+
+	if IsDNSRequest(p.DNS.Flags) {
+		key := dnsQueryKey{client: src, port: srcPort, id: p.DNS.ID}
+		// 再送は最初のクエリのまま扱う
+		if _, ok := d.pending[key]; ok {
+			return nil
+		}
+		for len(d.order) >= d.maxPending {
+			d.evictOldest()
+		}
+		d.pending[key] = &dnsPendingQuery{
+			resolver: dst,
+			qname:    qname,
+			qtype:    qtype,
+			deadline: now.Add(d.timeout),
+		}
+		d.order = append(d.order, key)
+		return nil
+	}
+
+	// 対応するクエリを見ていないレスポンスは判断できないので無視する
+	query, ok := d.pending[dnsQueryKey{client: dst, port: dstPort, id: p.DNS.ID}]
+	if !ok {
+		return nil
+	}
+	newAlert := func(kind DNSAlertKind, detail string) DNSAlert {
+		return DNSAlert{
+			Kind:          kind,
+			TransactionID: p.DNS.ID,
+			Query:         query.qname,
+			Client:        dst,
+			Resolver:      query.resolver,
+			Source:        src,
+			Detail:        detail,
+		}
+	}
+
+	alerts := []DNSAlert{}
+	if src != query.resolver {
+		alerts = append(alerts, newAlert(DNS_ALERT_UNEXPECTED_SOURCE, fmt.Sprintf("response from %s", src)))
+	}
+	if !strings.EqualFold(qname, query.qname) || qtype != query.qtype {
+		alerts = append(alerts, newAlert(DNS_ALERT_QUESTION_MISMATCH, fmt.Sprintf("answered %s %s", qname, dnsTypeName(qtype))))
+	}
+
+	records, _ := ParsedDNSResourceRecords(msg)
+	for _, name := range outOfBailiwickNames(msg, query.qname, records) {
+		alerts = append(alerts, newAlert(DNS_ALERT_OUT_OF_BAILIWICK, fmt.Sprintf("record for %s", name)))
+	}
+
+	answer := dnsAnswerSignature(p.DNS.Flags, records)
+	if query.answered {
+		if answer != query.answer {
+			alerts = append(alerts, newAlert(DNS_ALERT_DUPLICATE_MISMATCH, "answers differ from the first response"))
+		}
+	} else {
+		query.answered = true
+		query.answer = answer
+	}
+
+	for _, alert := range alerts {
+		logDNSAlert(alert)
+	}
+	return alerts
+}
+
+// Pending returns the number of queries currently remembered
+// 現在覚えているクエリ数を返します
+func (d *DNSMismatchDetector) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+// タイムアウトは一律なので、登録順に見て期限切れのものを捨てる
+func (d *DNSMismatchDetector) expire(now time.Time) {
+	for len(d.order) > 0 {
+		if query := d.pending[d.order[0]]; query != nil && now.Before(query.deadline) {
+			return
+		}
+		d.evictOldest()
+	}
+}
+
+func (d *DNSMismatchDetector) evictOldest() {
+	delete(d.pending, d.order[0])
+	d.order = d.order[1:]
+}
+
+// DNS メッセージ全体を返す. 圧縮ポインタはメッセージ先頭からのオフセットのため、ヘッダーを復元して付ける
+func (d *DNSPacket) message() []byte {
+	msg := make([]byte, 12, 12+len(d.Payload))
+	binary.BigEndian.PutUint16(msg[0:2], d.ID)
+	binary.BigEndian.PutUint16(msg[2:4], d.Flags)
+	binary.BigEndian.PutUint16(msg[4:6], d.Questions)
+	binary.BigEndian.PutUint16(msg[6:8], d.AnswerRRs)
+	binary.BigEndian.PutUint16(msg[8:10], d.AuthorityRRs)
+	binary.BigEndian.PutUint16(msg[10:12], d.AdditionalRRs)
+	return append(msg, d.Payload...)
+}
+
+func dnsEndpoints(p *Passive) (src, dst string, srcPort, dstPort uint16, ok bool) {
+	switch {
+	case p.IPv4 != nil:
+		src, dst = net.IP(p.IPv4.SrcIP).String(), net.IP(p.IPv4.DstIP).String()
+	case p.IPv6 != nil:
+		src, dst = net.IP(p.IPv6.SrcIP).String(), net.IP(p.IPv6.DstIP).String()
+	default:
+		return "", "", 0, 0, false
+	}
+	switch {
+	case p.UDP != nil:
+		return src, dst, p.UDP.SrcPort, p.UDP.DstPort, true
+	case p.TCP != nil:
+		return src, dst, p.TCP.SrcPort, p.TCP.DstPort, true
+	}
+	return "", "", 0, 0, false
+}
+
+// 最初の質問のドメイン名とタイプを返す
+func dnsQuestion(msg []byte) (string, uint16, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", 0, errDNSMessageTooShort
+	}
+	name, next, err := parseDNSName(msg, 12)
+	if err != nil {
+		return "", 0, err
+	}
+	if next+4 > len(msg) {
+		return "", 0, errDNSMessageTooShort
+	}
+	return name, binary.BigEndian.Uint16(msg[next : next+2]), nil
+}
+
+func dnsTypeName(typ uint16) string {
+	if name, ok := DNSQueryTypes[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", typ)
+}
+
+// outOfBailiwickNames returns the names of the records a resolver would not accept for qname:
+// answers that are neither qname nor reached from it through a CNAME, and NS/SOA authority records
+// for a zone that does not contain the queried names.
+// qnameの問い合わせに対して受け入れられないレコードの名前を返します
+func outOfBailiwickNames(msg []byte, qname string, records []*DNSResourceRecord) []string {
+	// CNAME を辿った先の名前も問い合わせた名前として扱う
+	queried := map[string]bool{dnsCanonicalName(qname): true}
+	for added := true; added; {
+		added = false
+		for _, rr := range records {
+			if rr.Section != DNS_SECTION_ANSWER || rr.Typ != DNS_QUERY_TYPE_CNAME || !queried[dnsCanonicalName(rr.Name)] {
+				continue
+			}
+			target, _, err := parseDNSName(msg, rr.dataOffset)
+			if err != nil || queried[dnsCanonicalName(target)] {
+				continue
+			}
+			queried[dnsCanonicalName(target)] = true
+			added = true
+		}
+	}
+	withinZone := func(zone string) bool {
+		for name := range queried {
+			if inBailiwick(name, zone) {
+				return true
+			}
+		}
+		return false
+	}
+
+	names := []string{}
+	for _, rr := range records {
+		switch rr.Section {
+		case DNS_SECTION_ANSWER:
+			if !queried[dnsCanonicalName(rr.Name)] {
+				names = append(names, rr.Name)
+			}
+		case DNS_SECTION_AUTHORITY:
+			if (rr.Typ == DNS_QUERY_TYPE_NS || rr.Typ == DNS_QUERY_TYPE_SOA) && !withinZone(rr.Name) {
+				names = append(names, rr.Name)
+			}
+		}
+	}
+	return names
+}
+
+// name が zone と同じか zone のサブドメインかどうか
+func inBailiwick(name, zone string) bool {
+	name, zone = dnsCanonicalName(name), dnsCanonicalName(zone)
+	return zone == "" || name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+func dnsCanonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// 重複レスポンスの比較用に、RCODE と Answer セクションを順序に依らない文字列にする
+func dnsAnswerSignature(flags uint16, records []*DNSResourceRecord) string {
+	answers := []string{}
+	for _, rr := range records {
+		if rr.Section == DNS_SECTION_ANSWER {
+			answers = append(answers, fmt.Sprintf("%s/%d/%s", dnsCanonicalName(rr.Name), rr.Typ, hex.EncodeToString(rr.Data)))
+		}
+	}
+	sort.Strings(answers)
+	return fmt.Sprintf("%d:%s", flags&0x000f, strings.Join(answers, ","))
+}
@@ -0,0 +1,217 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dnsTestRR struct {
+	name string
+	typ  uint16
+	data []byte
+}
+
+func dnsTestName(name string) []byte {
+	buf := &bytes.Buffer{}
+	for _, label := range strings.Split(name, ".") {
+		buf.WriteByte(uint8(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0x00)
+	return buf.Bytes()
+}
+
+// 圧縮なしのDNSメッセージを組み立てる
+func dnsTestMessage(id uint16, response bool, qname string, answers []dnsTestRR, authorities []dnsTestRR) []byte {
+	buf := &bytes.Buffer{}
+	flags := uint16(0x0100)
+	if response {
+		flags |= DNS_QR_RESPONSE | 0x0080
+	}
+	WriteUint16(buf, id)
+	WriteUint16(buf, flags)
+	WriteUint16(buf, 1)
+	WriteUint16(buf, uint16(len(answers)))
+	WriteUint16(buf, uint16(len(authorities)))
+	WriteUint16(buf, 0)
+	buf.Write(dnsTestName(qname))
+	WriteUint16(buf, DNS_QUERY_TYPE_A)
+	WriteUint16(buf, DNS_QUERY_CLASS_IN)
+	for _, rr := range append(answers, authorities...) {
+		buf.Write(dnsTestName(rr.name))
+		WriteUint16(buf, rr.typ)
+		WriteUint16(buf, DNS_QUERY_CLASS_IN)
+		WriteUint32(buf, 300)
+		WriteUint16(buf, uint16(len(rr.data)))
+		buf.Write(rr.data)
+	}
+	return buf.Bytes()
+}
+
+func dnsTestPassive(src, dst string, srcPort, dstPort uint16, msg []byte) *Passive {
+	return &Passive{
+		IPv4: &IPv4Packet{SrcIP: net.ParseIP(src).To4(), DstIP: net.ParseIP(dst).To4()},
+		UDP:  &UDPPacket{SrcPort: srcPort, DstPort: dstPort, Payload: msg},
+		DNS:  ParseDNSRequest(msg),
+	}
+}
+
+const (
+	dnsTestClient   = "192.168.0.10"
+	dnsTestResolver = "192.168.0.1"
+)
+
+func dnsTestQuery(id uint16, qname string) *Passive {
+	return dnsTestPassive(dnsTestClient, dnsTestResolver, 40000, PORT_DNS, dnsTestMessage(id, false, qname, nil, nil))
+}
+
+func dnsTestResponse(src string, id uint16, qname string, answers []dnsTestRR, authorities []dnsTestRR) *Passive {
+	return dnsTestPassive(src, dnsTestClient, PORT_DNS, 40000, dnsTestMessage(id, true, qname, answers, authorities))
+}
+
+func dnsAlertKinds(alerts []DNSAlert) []DNSAlertKind {
+	kinds := []DNSAlertKind{}
+	for _, alert := range alerts {
+		kinds = append(kinds, alert.Kind)
+	}
+	return kinds
+}
+
+func TestDNSMismatchDetector(t *testing.T) {
+	a := func(name string, addr ...byte) dnsTestRR {
+		return dnsTestRR{name: name, typ: DNS_QUERY_TYPE_A, data: addr}
+	}
+
+	tests := []struct {
+		name     string
+		response *Passive
+		want     []DNSAlertKind
+	}{
+		{
+			name:     "matching answer",
+			response: dnsTestResponse(dnsTestResolver, 0x1234, "www.example.com", []dnsTestRR{a("www.example.com", 93, 184, 216, 34)}, nil),
+			want:     []DNSAlertKind{},
+		},
+		{
+			name: "answer through cname",
+			response: dnsTestResponse(dnsTestResolver, 0x1234, "www.example.com", []dnsTestRR{
+				{name: "www.example.com", typ: DNS_QUERY_TYPE_CNAME, data: dnsTestName("cdn.example.net")},
+				a("cdn.example.net", 93, 184, 216, 34),
+			}, []dnsTestRR{{name: "example.net", typ: DNS_QUERY_TYPE_NS, data: dnsTestName("ns.example.net")}}),
+			want: []DNSAlertKind{},
+		},
+		{
+			// 問い合わせと関係ないドメインのレコードを紛れ込ませる
+			name: "answer outside the queried zone",
+			response: dnsTestResponse(dnsTestResolver, 0x1234, "www.example.com", []dnsTestRR{
+				a("www.example.com", 93, 184, 216, 34),
+				a("www.bank.test", 6, 6, 6, 6),
+			}, nil),
+			want: []DNSAlertKind{DNS_ALERT_OUT_OF_BAILIWICK},
+		},
+		{
+			name: "authority for another zone",
+			response: dnsTestResponse(dnsTestResolver, 0x1234, "www.example.com", []dnsTestRR{a("www.example.com", 93, 184, 216, 34)},
+				[]dnsTestRR{{name: "bank.test", typ: DNS_QUERY_TYPE_NS, data: dnsTestName("ns.attacker.test")}}),
+			want: []DNSAlertKind{DNS_ALERT_OUT_OF_BAILIWICK},
+		},
+		{
+			name:     "response from another host",
+			response: dnsTestResponse("203.0.113.66", 0x1234, "www.example.com", []dnsTestRR{a("www.example.com", 6, 6, 6, 6)}, nil),
+			want:     []DNSAlertKind{DNS_ALERT_UNEXPECTED_SOURCE},
+		},
+		{
+			name:     "different question",
+			response: dnsTestResponse(dnsTestResolver, 0x1234, "www.bank.test", []dnsTestRR{a("www.bank.test", 6, 6, 6, 6)}, nil),
+			want:     []DNSAlertKind{DNS_ALERT_QUESTION_MISMATCH, DNS_ALERT_OUT_OF_BAILIWICK},
+		},
+		{
+			// Transaction ID が異なるレスポンスは対応するクエリがないので判断しない
+			name:     "unknown transaction id",
+			response: dnsTestResponse("203.0.113.66", 0x9999, "www.example.com", []dnsTestRR{a("www.example.com", 6, 6, 6, 6)}, nil),
+			want:     []DNSAlertKind{},
+		},
+	}
+
+	now := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDNSMismatchDetector(0, 0)
+			if alerts := d.Observe(dnsTestQuery(0x1234, "www.example.com"), now); len(alerts) != 0 {
+				t.Fatalf("query alerts = %v", alerts)
+			}
+			got := dnsAlertKinds(d.Observe(tt.response, now.Add(10*time.Millisecond)))
+			if len(got) != len(tt.want) {
+				t.Fatalf("alerts = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("alerts[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDNSMismatchDetector_DuplicateResponses(t *testing.T) {
+	answer := func(addr ...byte) *Passive {
+		return dnsTestResponse(dnsTestResolver, 0x1234, "www.example.com", []dnsTestRR{{name: "www.example.com", typ: DNS_QUERY_TYPE_A, data: addr}}, nil)
+	}
+
+	now := time.Now()
+	d := NewDNSMismatchDetector(time.Second, 0)
+	d.Observe(dnsTestQuery(0x1234, "www.example.com"), now)
+
+	if alerts := d.Observe(answer(93, 184, 216, 34), now); len(alerts) != 0 {
+		t.Fatalf("first response alerts = %v", alerts)
+	}
+	// 同じ内容の重複は問題なし
+	if alerts := d.Observe(answer(93, 184, 216, 34), now); len(alerts) != 0 {
+		t.Errorf("identical duplicate alerts = %v", alerts)
+	}
+	alerts := d.Observe(answer(6, 6, 6, 6), now)
+	if len(alerts) != 1 || alerts[0].Kind != DNS_ALERT_DUPLICATE_MISMATCH {
+		t.Fatalf("differing duplicate alerts = %v", alerts)
+	}
+	if alerts[0].TransactionID != 0x1234 || alerts[0].Query != "www.example.com" || alerts[0].Resolver != dnsTestResolver {
+		t.Errorf("alert = %+v", alerts[0])
+	}
+
+	// タイムアウト後はクエリを忘れる
+	if alerts := d.Observe(answer(7, 7, 7, 7), now.Add(2*time.Second)); len(alerts) != 0 {
+		t.Errorf("alerts after timeout = %v", alerts)
+	}
+	if d.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", d.Pending())
+	}
+}
+
+func TestDNSMismatchDetector_MaxPending(t *testing.T) {
+	now := time.Now()
+	d := NewDNSMismatchDetector(time.Minute, 2)
+	for id := uint16(1); id <= 3; id++ {
+		d.Observe(dnsTestQuery(id, "www.example.com"), now)
+	}
+	if d.Pending() != 2 {
+		t.Fatalf("Pending() = %d, want 2", d.Pending())
+	}
+
+	// 最も古いクエリが破棄されている
+	spoofed := []dnsTestRR{{name: "www.example.com", typ: DNS_QUERY_TYPE_A, data: []byte{6, 6, 6, 6}}}
+	if alerts := d.Observe(dnsTestResponse("203.0.113.66", 1, "www.example.com", spoofed, nil), now); len(alerts) != 0 {
+		t.Errorf("alerts for evicted query = %v", alerts)
+	}
+	if alerts := d.Observe(dnsTestResponse("203.0.113.66", 3, "www.example.com", spoofed, nil), now); len(alerts) != 1 {
+		t.Errorf("alerts for pending query = %v", alerts)
+	}
+}
+
+func TestDNSPacket_message(t *testing.T) {
+	msg := dnsTestMessage(0xbeef, true, "example.com", nil, nil)
+	if got := ParseDNSResponse(msg).message(); !bytes.Equal(got, msg) {
+		t.Errorf("message() = %x, want %x", got, msg)
+	}
+}
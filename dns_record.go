@@ -0,0 +1,316 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+const (
+	DNS_QUERY_TYPE_NS     = 0x0002
+	DNS_QUERY_TYPE_CNAME  = 0x0005
+	DNS_QUERY_TYPE_SOA    = 0x0006
+	DNS_QUERY_TYPE_NULL   = 0x000a
+	DNS_QUERY_TYPE_PTR    = 0x000c
+	DNS_QUERY_TYPE_MX     = 0x000f
+	DNS_QUERY_TYPE_TXT    = 0x0010
+	DNS_QUERY_TYPE_SRV    = 0x0021 // https://datatracker.ietf.org/doc/html/rfc2782
+	DNS_QUERY_TYPE_OPT    = 0x0029 // EDNS0. https://datatracker.ietf.org/doc/html/rfc6891
+	DNS_QUERY_TYPE_DS     = 0x002b // https://datatracker.ietf.org/doc/html/rfc4034
+	DNS_QUERY_TYPE_RRSIG  = 0x002e
+	DNS_QUERY_TYPE_NSEC   = 0x002f
+	DNS_QUERY_TYPE_DNSKEY = 0x0030
+)
+
+var DNSQueryTypes = map[uint16]string{
+	DNS_QUERY_TYPE_A:      "A",
+	DNS_QUERY_TYPE_NS:     "NS",
+	DNS_QUERY_TYPE_CNAME:  "CNAME",
+	DNS_QUERY_TYPE_SOA:    "SOA",
+	DNS_QUERY_TYPE_NULL:   "NULL",
+	DNS_QUERY_TYPE_PTR:    "PTR",
+	DNS_QUERY_TYPE_MX:     "MX",
+	DNS_QUERY_TYPE_TXT:    "TXT",
+	DNS_QUERY_TYPE_AAAA:   "AAAA",
+	DNS_QUERY_TYPE_SRV:    "SRV",
+	DNS_QUERY_TYPE_OPT:    "OPT",
+	DNS_QUERY_TYPE_DS:     "DS",
+	DNS_QUERY_TYPE_RRSIG:  "RRSIG",
+	DNS_QUERY_TYPE_NSEC:   "NSEC",
+	DNS_QUERY_TYPE_DNSKEY: "DNSKEY",
+}
+
+// RR がどのセクションに含まれていたか
+const (
+	DNS_SECTION_ANSWER uint8 = iota + 1
+	DNS_SECTION_AUTHORITY
+	DNS_SECTION_ADDITIONAL
+)
+
+// https://datatracker.ietf.org/doc/html/rfc1035#section-4.1.3
+type DNSResourceRecord struct {
+	Section    uint8
+	Name       string
+	Typ        uint16
+	Class      uint16
+	Ttl        uint32
+	DataLength uint16
+	Data       []byte
+
+	// メッセージ内での Data の位置. CNAME などの圧縮された名前を展開するのに使う
+	dataOffset int
+}
+
+var errDNSMessageTooShort = errors.New("dns message too short")
+
+// ParsedDNSResourceRecords walks the answer/authority/additional sections of a DNS message
+// 既存の Answers は A レコード前提の固定長で解析しているため、こちらは圧縮ポインタも含めてちゃんと辿る
+func ParsedDNSResourceRecords(payload []byte) ([]*DNSResourceRecord, error) {
+	if len(payload) < 12 {
+		return nil, errDNSMessageTooShort
+	}
+	qdCnt := int(binary.BigEndian.Uint16(payload[4:6]))
+	counts := []struct {
+		section uint8
+		cnt     int
+	}{
+		{DNS_SECTION_ANSWER, int(binary.BigEndian.Uint16(payload[6:8]))},
+		{DNS_SECTION_AUTHORITY, int(binary.BigEndian.Uint16(payload[8:10]))},
+		{DNS_SECTION_ADDITIONAL, int(binary.BigEndian.Uint16(payload[10:12]))},
+	}
+
+	offset := 12
+	for i := 0; i < qdCnt; i++ {
+		_, next, err := parseDNSName(payload, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4 // type + class
+	}
+
+	records := []*DNSResourceRecord{}
+	for _, c := range counts {
+		for i := 0; i < c.cnt; i++ {
+			name, next, err := parseDNSName(payload, offset)
+			if err != nil {
+				return records, err
+			}
+			if next+10 > len(payload) {
+				return records, errDNSMessageTooShort
+			}
+			rr := &DNSResourceRecord{
+				Section:    c.section,
+				Name:       name,
+				Typ:        binary.BigEndian.Uint16(payload[next : next+2]),
+				Class:      binary.BigEndian.Uint16(payload[next+2 : next+4]),
+				Ttl:        binary.BigEndian.Uint32(payload[next+4 : next+8]),
+				DataLength: binary.BigEndian.Uint16(payload[next+8 : next+10]),
+			}
+			end := next + 10 + int(rr.DataLength)
+			if end > len(payload) {
+				return records, errDNSMessageTooShort
+			}
+			rr.Data = payload[next+10 : end]
+			rr.dataOffset = next + 10
+			records = append(records, rr)
+			offset = end
+		}
+	}
+	return records, nil
+}
+
+// 圧縮(https://datatracker.ietf.org/doc/html/rfc1035#section-4.1.4)に対応したドメイン名の展開.
+// 戻り値の offset は、ドメイン名の直後の位置(ポインタを辿った先ではない)
+func parseDNSName(msg []byte, offset int) (string, int, error) {
+	labels := []string{}
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errDNSMessageTooShort
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0x00:
+			if next == -1 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(msg) {
+				return "", 0, errDNSMessageTooShort
+			}
+			if next == -1 {
+				next = offset + 2
+			}
+			jumps++
+			if jumps > 16 {
+				return "", 0, errors.New("too many dns compression pointers")
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3fff)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errDNSMessageTooShort
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// EDNS0 の OPT 疑似レコード
+// CLASS に UDP payload size、TTL に extended RCODE/version/flags が入る
+// https://datatracker.ietf.org/doc/html/rfc6891#section-6.1.2
+type DNSOPT struct {
+	UDPPayloadSize uint16
+	ExtendedRCode  uint8
+	Version        uint8
+	Flags          uint16 // DO bit + Z
+	Options        []*DNSOPTOption
+}
+
+type DNSOPTOption struct {
+	Code   uint16
+	Length uint16
+	Data   []byte
+}
+
+// DNSSEC OK
+const DNS_EDNS0_FLAG_DO = 0x8000
+
+func (o *DNSOPT) DNSSECOK() bool {
+	return o.Flags&DNS_EDNS0_FLAG_DO == DNS_EDNS0_FLAG_DO
+}
+
+func ParsedDNSOPT(rr *DNSResourceRecord) *DNSOPT {
+	if rr == nil || rr.Typ != DNS_QUERY_TYPE_OPT {
+		return nil
+	}
+
+	opt := &DNSOPT{
+		UDPPayloadSize: rr.Class,
+		ExtendedRCode:  uint8(rr.Ttl >> 24),
+		Version:        uint8(rr.Ttl >> 16),
+		Flags:          uint16(rr.Ttl),
+	}
+	for i := 0; i+4 <= len(rr.Data); {
+		o := &DNSOPTOption{
+			Code:   binary.BigEndian.Uint16(rr.Data[i : i+2]),
+			Length: binary.BigEndian.Uint16(rr.Data[i+2 : i+4]),
+		}
+		end := i + 4 + int(o.Length)
+		if end > len(rr.Data) {
+			break
+		}
+		o.Data = rr.Data[i+4 : end]
+		opt.Options = append(opt.Options, o)
+		i = end
+	}
+	return opt
+}
+
+// DNSSEC の RR (RRSIG/DNSKEY/DS/NSEC) の主要なフィールド
+// https://datatracker.ietf.org/doc/html/rfc4034
+type DNSSECRecord struct {
+	Typ         uint16
+	KeyTag      uint16   // RRSIG, DS. DNSKEY は RDATA から計算
+	Algorithm   uint8    // RRSIG, DNSKEY, DS
+	TypeCovered uint16   // RRSIG
+	SignerName  string   // RRSIG
+	Flags       uint16   // DNSKEY
+	DigestType  uint8    // DS
+	NextDomain  string   // NSEC
+	Types       []uint16 // NSEC
+}
+
+// RDATA 内のドメイン名は圧縮されない(RFC 4034 6.2)ので、RR 単体で解析できる
+func ParsedDNSSECRecord(rr *DNSResourceRecord) *DNSSECRecord {
+	if rr == nil {
+		return nil
+	}
+	d := rr.Data
+
+	switch rr.Typ {
+	case DNS_QUERY_TYPE_RRSIG:
+		if len(d) < 18 {
+			return nil
+		}
+		signer, _, err := parseDNSName(d, 18)
+		if err != nil {
+			return nil
+		}
+		return &DNSSECRecord{
+			Typ:         rr.Typ,
+			TypeCovered: binary.BigEndian.Uint16(d[0:2]),
+			Algorithm:   d[2],
+			KeyTag:      binary.BigEndian.Uint16(d[16:18]),
+			SignerName:  signer,
+		}
+	case DNS_QUERY_TYPE_DNSKEY:
+		if len(d) < 4 {
+			return nil
+		}
+		return &DNSSECRecord{
+			Typ:       rr.Typ,
+			Flags:     binary.BigEndian.Uint16(d[0:2]),
+			Algorithm: d[3],
+			KeyTag:    dnsKeyTag(d),
+		}
+	case DNS_QUERY_TYPE_DS:
+		if len(d) < 4 {
+			return nil
+		}
+		return &DNSSECRecord{
+			Typ:        rr.Typ,
+			KeyTag:     binary.BigEndian.Uint16(d[0:2]),
+			Algorithm:  d[2],
+			DigestType: d[3],
+		}
+	case DNS_QUERY_TYPE_NSEC:
+		next, end, err := parseDNSName(d, 0)
+		if err != nil {
+			return nil
+		}
+		return &DNSSECRecord{
+			Typ:        rr.Typ,
+			NextDomain: next,
+			Types:      parseNSECTypeBitmaps(d[end:]),
+		}
+	}
+	return nil
+}
+
+// https://datatracker.ietf.org/doc/html/rfc4034#appendix-B
+// NOTE: algorithm 1 (RSA/MD5) の場合の計算は未対応
+func dnsKeyTag(rdata []byte) uint16 {
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 1 {
+			ac += uint32(b)
+		} else {
+			ac += uint32(b) << 8
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac & 0xffff)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc4034#section-4.1.2
+func parseNSECTypeBitmaps(b []byte) []uint16 {
+	types := []uint16{}
+	for i := 0; i+2 <= len(b); {
+		window := uint16(b[i])
+		length := int(b[i+1])
+		if i+2+length > len(b) {
+			break
+		}
+		for j, octet := range b[i+2 : i+2+length] {
+			for bit := 0; bit < 8; bit++ {
+				if octet&(0x80>>bit) != 0 {
+					types = append(types, window<<8|uint16(j*8+bit))
+				}
+			}
+		}
+		i += 2 + length
+	}
+	return types
+}
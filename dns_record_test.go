@@ -0,0 +1,103 @@
+package packemon
+
+import (
+	"bytes"
+	"testing"
+)
+
+// example.com A のレスポンス. Additional に DO bit 付きの OPT レコード
+var dnsResponseWithOPT = []byte{
+	0x12, 0x34, // Transaction ID
+	0x81, 0x80, // Flags
+	0x00, 0x01, // Questions
+	0x00, 0x01, // Answer RRs
+	0x00, 0x00, // Authority RRs
+	0x00, 0x01, // Additional RRs
+	// Query: example.com A IN
+	0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00,
+	0x00, 0x01, 0x00, 0x01,
+	// Answer: ptr to query name, A, IN, TTL 300, 93.184.216.34
+	0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x04, 0x5d, 0xb8, 0xd8, 0x22,
+	// Additional: OPT, UDP payload size 1232, extended rcode 0, version 0, DO bit, 1 option (cookie)
+	0x00, 0x00, 0x29, 0x04, 0xd0, 0x00, 0x00, 0x80, 0x00, 0x00, 0x0c,
+	0x00, 0x0a, 0x00, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+}
+
+func TestParsedDNSResponseWithOPT(t *testing.T) {
+	dns := ParsedDNSResponse(dnsResponseWithOPT)
+
+	if len(dns.Records) != 2 {
+		t.Fatalf("len(Records) = %d, want 2", len(dns.Records))
+	}
+	answer := dns.Records[0]
+	if answer.Section != DNS_SECTION_ANSWER || answer.Name != "example.com" || answer.Typ != DNS_QUERY_TYPE_A {
+		t.Errorf("answer = %+v", answer)
+	}
+	if !bytes.Equal(answer.Data, []byte{0x5d, 0xb8, 0xd8, 0x22}) {
+		t.Errorf("answer data = %x", answer.Data)
+	}
+
+	if dns.EDNS0 == nil {
+		t.Fatal("EDNS0 should be parsed")
+	}
+	if dns.EDNS0.UDPPayloadSize != 1232 {
+		t.Errorf("UDPPayloadSize = %d, want 1232", dns.EDNS0.UDPPayloadSize)
+	}
+	if dns.EDNS0.ExtendedRCode != 0 || dns.EDNS0.Version != 0 {
+		t.Errorf("ExtendedRCode/Version = %d/%d, want 0/0", dns.EDNS0.ExtendedRCode, dns.EDNS0.Version)
+	}
+	if !dns.EDNS0.DNSSECOK() {
+		t.Errorf("DNSSECOK() = false, want true")
+	}
+	if len(dns.EDNS0.Options) != 1 || dns.EDNS0.Options[0].Code != 0x000a || len(dns.EDNS0.Options[0].Data) != 8 {
+		t.Errorf("Options = %+v", dns.EDNS0.Options)
+	}
+}
+
+func TestParsedDNSSECRecord(t *testing.T) {
+	msg := []byte{
+		0x00, 0x01, 0x81, 0x80, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00,
+		// DNSKEY: root, flags 257, protocol 3, algorithm 8, key 0xaabb
+		0x00, 0x00, 0x30, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x06,
+		0x01, 0x01, 0x03, 0x08, 0xaa, 0xbb,
+		// DS: key tag 20326, algorithm 8, digest type 2, digest 0x01
+		0x00, 0x00, 0x2b, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x05,
+		0x4f, 0x66, 0x08, 0x02, 0x01,
+		// RRSIG: covers DNSKEY, algorithm 8, key tag 20326, signer "."
+		0x00, 0x00, 0x2e, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x14,
+		0x00, 0x30, 0x08, 0x00, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x4f, 0x66, 0x00, 0xff,
+		// NSEC: next "a", types A(1) and RRSIG(46)
+		0x00, 0x00, 0x2f, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x0b,
+		0x01, 'a', 0x00, 0x00, 0x06, 0x40, 0x00, 0x00, 0x00, 0x00, 0x02,
+	}
+
+	records, err := ParsedDNSResourceRecords(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("len(records) = %d, want 4", len(records))
+	}
+
+	dnskey := ParsedDNSSECRecord(records[0])
+	// 0x0101 + 0x0308 + 0xaabb = 0xaec4
+	if dnskey.Flags != 257 || dnskey.Algorithm != 8 || dnskey.KeyTag != 0xaec4 {
+		t.Errorf("DNSKEY = %+v", dnskey)
+	}
+
+	ds := ParsedDNSSECRecord(records[1])
+	if ds.KeyTag != 20326 || ds.Algorithm != 8 || ds.DigestType != 2 {
+		t.Errorf("DS = %+v", ds)
+	}
+
+	rrsig := ParsedDNSSECRecord(records[2])
+	if rrsig.TypeCovered != DNS_QUERY_TYPE_DNSKEY || rrsig.KeyTag != 20326 || rrsig.SignerName != "" {
+		t.Errorf("RRSIG = %+v", rrsig)
+	}
+
+	nsec := ParsedDNSSECRecord(records[3])
+	if nsec.NextDomain != "a" || len(nsec.Types) != 2 || nsec.Types[0] != DNS_QUERY_TYPE_A || nsec.Types[1] != DNS_QUERY_TYPE_RRSIG {
+		t.Errorf("NSEC = %+v", nsec)
+	}
+}
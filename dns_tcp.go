@@ -0,0 +1,179 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// DNS_TCP_MAX_PENDING is the number of TCP directions whose incomplete DNS message is kept at once with SetDNSOverTCPReassembly.
+// The oldest one is dropped beyond it
+// SetDNSOverTCPReassemblyで途中のDNSメッセージを同時に保持するTCPの方向の数です。超えた場合は最も古いものを捨てます
+const DNS_TCP_MAX_PENDING = 256
+
+// SplitDNSOverTCP splits a TCP payload into the DNS messages it carries, each prefixed by its 2 byte length (RFC 1035 section 4.2.2).
+// rest is the start of a message continuing in the next segments, nil if the payload ends with a message.
+// A length shorter than a DNS header is an error, returned with the messages before it
+// TCPのペイロードを、それぞれ2バイトの長さが前に付いたDNSメッセージ(RFC 1035 4.2.2章)に分割します。
+// restは次のセグメントに続くメッセージの先頭で、ペイロードがメッセージの終わりで終わっている場合はnilです。
+// DNSヘッダーより短い長さはエラーで、それより前のメッセージとともに返します
+func SplitDNSOverTCP(payload []byte) (messages [][]byte, rest []byte, err error) {
+	for len(payload) > 0 {
+		if len(payload) < 2 {
+			return messages, payload, nil
+		}
+		length := int(binary.BigEndian.Uint16(payload[0:2]))
+		if length < 12 {
+			return messages, nil, fmt.Errorf("DNS over TCP message of %d bytes is shorter than the header", length)
+		}
+		if 2+length > len(payload) {
+			return messages, payload, nil
+		}
+		messages = append(messages, payload[2:2+length])
+		payload = payload[2+length:]
+	}
+	return messages, nil, nil
+}
+
+var dnsOverTCPReassembly atomic.Bool
+
+// SetDNSOverTCPReassembly enables completing a DNS over TCP message split across segments (disabled by default).
+// The start of the message is kept per TCP direction and joined with the next segment, if that follows it without a gap.
+// Otherwise, such a segment is only marked with Passive.DNSIncomplete
+// 複数のセグメントに分かれたDNS over TCPのメッセージを組み立てるかどうかを設定します(デフォルトは無効)。
+// メッセージの先頭をTCPの方向ごとに保持し、隙間なく続く次のセグメントと結合します。
+// 無効の場合、そのようなセグメントはPassive.DNSIncompleteが設定されるだけです
+func SetDNSOverTCPReassembly(enabled bool) {
+	dnsOverTCPReassembly.Store(enabled)
+	if !enabled {
+		dnsTCPPendings.reset()
+	}
+}
+
+// DNSOverTCPReassembly reports whether DNS over TCP messages split across segments are completed
+// 複数のセグメントに分かれたDNS over TCPのメッセージを組み立てるかどうかを返します
+func DNSOverTCPReassembly() bool {
+	return dnsOverTCPReassembly.Load()
+}
+
+// 送信元から宛先への方向
+type dnsTCPKey struct {
+	src netip.AddrPort
+	dst netip.AddrPort
+}
+
+type dnsTCPPending struct {
+	data    []byte
+	nextSeq uint32 // 続きのセグメントのシーケンス番号
+	order   uint64 // 古いものから捨てるための追加順
+}
+
+type dnsTCPPendingTable struct {
+	mu      sync.Mutex
+	pending map[dnsTCPKey]*dnsTCPPending
+	added   uint64
+}
+
+var dnsTCPPendings = &dnsTCPPendingTable{}
+
+func (t *dnsTCPPendingTable) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = nil
+}
+
+// 保持していたメッセージの先頭に続くセグメントなら結合して返す. 続かない場合は捨てる
+func (t *dnsTCPPendingTable) take(key dnsTCPKey, seq uint32, payload []byte) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending, ok := t.pending[key]
+	if !ok {
+		return payload
+	}
+	delete(t.pending, key)
+	if pending.nextSeq != seq {
+		return payload
+	}
+	return append(pending.data, payload...)
+}
+
+func (t *dnsTCPPendingTable) store(key dnsTCPKey, nextSeq uint32, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == nil {
+		t.pending = make(map[dnsTCPKey]*dnsTCPPending)
+	}
+	if _, ok := t.pending[key]; !ok && len(t.pending) >= DNS_TCP_MAX_PENDING {
+		var oldest dnsTCPKey
+		var oldestOrder uint64
+		first := true
+		for k, p := range t.pending {
+			if first || p.order < oldestOrder {
+				oldest, oldestOrder, first = k, p.order, false
+			}
+		}
+		delete(t.pending, oldest)
+	}
+	t.added++
+	// 受信バッファを参照し続けないようコピーする
+	t.pending[key] = &dnsTCPPending{data: append([]byte(nil), data...), nextSeq: nextSeq, order: t.added}
+}
+
+func dnsTCPKeyOf(passive *Passive, tcp *TCPPacket) (dnsTCPKey, bool) {
+	var src, dst netip.Addr
+	switch {
+	case passive.IPv4 != nil:
+		src, _ = netip.AddrFromSlice(passive.IPv4.SrcIP)
+		dst, _ = netip.AddrFromSlice(passive.IPv4.DstIP)
+	case passive.IPv6 != nil:
+		src, _ = netip.AddrFromSlice(passive.IPv6.SrcIP)
+		dst, _ = netip.AddrFromSlice(passive.IPv6.DstIP)
+	}
+	if !src.IsValid() || !dst.IsValid() {
+		return dnsTCPKey{}, false
+	}
+	return dnsTCPKey{
+		src: netip.AddrPortFrom(src, tcp.SrcPort),
+		dst: netip.AddrPortFrom(dst, tcp.DstPort),
+	}, true
+}
+
+// Parse the DNS messages of a DNS over TCP segment
+func parseDNSOverTCP(passive *Passive, tcp *TCPPacket) {
+	// ACK のみのセグメントなど、ペイロードがなければ解析しない
+	if len(tcp.Payload) == 0 {
+		return
+	}
+	payload := tcp.Payload
+	key, reassemble := dnsTCPKeyOf(passive, tcp)
+	reassemble = reassemble && DNSOverTCPReassembly()
+	if reassemble {
+		payload = dnsTCPPendings.take(key, tcp.SeqNum, payload)
+	}
+
+	messages, rest, err := SplitDNSOverTCP(payload)
+	for _, message := range messages {
+		if dns := parsedDNSMessage(message); dns != nil {
+			passive.DNSMessages = append(passive.DNSMessages, dns)
+		}
+	}
+	if len(passive.DNSMessages) > 0 {
+		passive.DNS = passive.DNSMessages[0]
+	}
+	if len(passive.DNSMessages) < 2 {
+		passive.DNSMessages = nil
+	}
+	if err != nil {
+		logParseFailure("DNS", payload)
+		recordDecode("DNS", false)
+	}
+
+	if rest != nil {
+		passive.DNSIncomplete = true
+		if reassemble {
+			dnsTCPPendings.store(key, tcp.SeqNum+uint32(len(tcp.Payload)), rest)
+		}
+	}
+}
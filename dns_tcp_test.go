@@ -0,0 +1,69 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+)
+
+func dnsTCPTestPassive(seq uint32, payload []byte) *Passive {
+	passive := &Passive{
+		IPv4: &IPv4Packet{SrcIP: net.ParseIP(dnsTestResolver).To4(), DstIP: net.ParseIP(dnsTestClient).To4()},
+		TCP:  &TCPPacket{SrcPort: PORT_DNS, DstPort: 40000, SeqNum: seq, Flags: TCP_FLAGS_PSH_ACK, Payload: payload},
+	}
+	parseTCPPayload(passive, passive.TCP, &DecodeAsTable{})
+	return passive
+}
+
+// 2 バイトの長さを前に付ける
+func dnsTCPTestPrefixed(msg []byte) []byte {
+	return append([]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+// TestDNSOverTCP tests parsing two DNS messages in one TCP segment, a message split across segments with and without reassembly,
+// and rejecting a length shorter than the DNS header
+// 1つのTCPセグメントの2つのDNSメッセージの解析、組み立ての有無それぞれでのセグメントに分かれたメッセージ、
+// およびDNSヘッダーより短い長さを拒否することをテストします
+func TestDNSOverTCP(t *testing.T) {
+	first := dnsTestMessage(0x1111, true, "example.com", nil, nil)
+	second := dnsTestMessage(0x2222, true, "example.org", nil, nil)
+
+	passive := dnsTCPTestPassive(1000, append(dnsTCPTestPrefixed(first), dnsTCPTestPrefixed(second)...))
+	if passive.DNS == nil || passive.DNS.ID != 0x1111 || len(passive.DNSMessages) != 2 || passive.DNSMessages[1].ID != 0x2222 || passive.DNSIncomplete {
+		t.Fatalf("DNS = %+v, DNSMessages = %+v, DNSIncomplete = %v, want both messages", passive.DNS, passive.DNSMessages, passive.DNSIncomplete)
+	}
+	if passive := dnsTCPTestPassive(1000, dnsTCPTestPrefixed(first)); passive.DNS == nil || passive.DNS.ID != 0x1111 || passive.DNSMessages != nil {
+		t.Errorf("single message: DNS = %+v, DNSMessages = %+v", passive.DNS, passive.DNSMessages)
+	}
+
+	// 2 つ目のメッセージがセグメントをまたぐ
+	stream := append(dnsTCPTestPrefixed(first), dnsTCPTestPrefixed(second)...)
+	split := len(dnsTCPTestPrefixed(first)) + 10
+	passive = dnsTCPTestPassive(2000, stream[:split])
+	if passive.DNS == nil || passive.DNS.ID != 0x1111 || passive.DNSMessages != nil || !passive.DNSIncomplete {
+		t.Fatalf("DNS = %+v, DNSIncomplete = %v, want the first message and the second incomplete", passive.DNS, passive.DNSIncomplete)
+	}
+	// 組み立てなしでは続きのセグメントを解析できない
+	if passive := dnsTCPTestPassive(2000+uint32(split), stream[split:]); passive.DNS != nil && passive.DNS.ID == 0x2222 {
+		t.Errorf("the rest of a split message should not be parsed without reassembly: %+v", passive.DNS)
+	}
+
+	SetDNSOverTCPReassembly(true)
+	defer SetDNSOverTCPReassembly(false)
+	if passive := dnsTCPTestPassive(3000, stream[:split]); !passive.DNSIncomplete {
+		t.Fatal("the first segment should end inside the second message")
+	}
+	passive = dnsTCPTestPassive(3000+uint32(split), stream[split:])
+	if passive.DNS == nil || passive.DNS.ID != 0x2222 || passive.DNSIncomplete {
+		t.Errorf("DNS = %+v, DNSIncomplete = %v, want the reassembled second message", passive.DNS, passive.DNSIncomplete)
+	}
+	// 隙間のあるセグメントとは結合しない
+	dnsTCPTestPassive(4000, stream[:split])
+	if passive := dnsTCPTestPassive(4000+uint32(split)+1, stream[split+1:]); passive.DNS != nil && passive.DNS.ID == 0x2222 {
+		t.Errorf("a segment after a gap should not be joined: %+v", passive.DNS)
+	}
+
+	messages, rest, err := SplitDNSOverTCP(append(dnsTCPTestPrefixed(first), 0x00, 0x04, 0x00, 0x00, 0x00, 0x00))
+	if err == nil || len(messages) != 1 || rest != nil {
+		t.Errorf("SplitDNSOverTCP() = %d messages, rest %x, %v, want the first message and an error", len(messages), rest, err)
+	}
+}
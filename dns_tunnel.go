@@ -0,0 +1,277 @@
+package packemon
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DNS_TUNNEL_DEFAULT_WINDOW is the period over which TXT/NULL queries to a domain are counted
+	// ドメインへのTXT/NULLクエリ数を数える期間です
+	DNS_TUNNEL_DEFAULT_WINDOW = time.Minute
+	// DNS_TUNNEL_DEFAULT_MAX_DOMAINS is the number of domains tracked at once. The least recently queried is dropped beyond it
+	// 同時に追跡するドメイン数です。超えた場合は最も長く問い合わせのないものから破棄します
+	DNS_TUNNEL_DEFAULT_MAX_DOMAINS = 1024
+
+	// DNS_TUNNEL_LONG_QNAME_LENGTH is the QNAME length above which a query is abnormally long
+	// これより長いQNAMEを異常に長いとみなします
+	DNS_TUNNEL_LONG_QNAME_LENGTH = 64
+	// DNS_TUNNEL_HIGH_ENTROPY is the Shannon entropy in bits per character from which a label looks like encoded data
+	// ラベルがエンコードされたデータに見える、1文字あたりのシャノンエントロピー(ビット)です
+	DNS_TUNNEL_HIGH_ENTROPY = 4.0
+	// DNS_TUNNEL_ENTROPY_MIN_LABEL_LENGTH is the shortest label whose entropy is measured. Short labels are too noisy
+	// エントロピーを測る最短のラベル長です。短いラベルでは値がばらつくため測りません
+	DNS_TUNNEL_ENTROPY_MIN_LABEL_LENGTH = 16
+	// DNS_TUNNEL_RECORD_TYPE_VOLUME is the number of TXT/NULL queries to a domain within the window above which the volume is unusual
+	// 期間内のドメインへのTXT/NULLクエリ数がこれを超えると異常な量とみなします
+	DNS_TUNNEL_RECORD_TYPE_VOLUME = 20
+	// DNS_TUNNEL_SUSPECT_SCORE is the number of distinct signals from which a domain is a suspect
+	// ドメインを疑わしいとみなすシグナルの種類数です
+	DNS_TUNNEL_SUSPECT_SCORE = 2
+)
+
+// DNSTunnelSignal is a set of signs of DNS tunneling seen for a domain
+// ドメインで観測したDNSトンネリングの兆候の集合です
+type DNSTunnelSignal uint8
+
+const (
+	DNS_TUNNEL_SIGNAL_LONG_QNAME         DNSTunnelSignal = 1 << iota // 異常に長いQNAME
+	DNS_TUNNEL_SIGNAL_HIGH_ENTROPY                                   // エントロピーの高いラベル
+	DNS_TUNNEL_SIGNAL_RECORD_TYPE_VOLUME                             // 大量のTXT/NULLクエリ
+)
+
+var dnsTunnelSignalNames = []struct {
+	signal DNSTunnelSignal
+	name   string
+}{
+	{DNS_TUNNEL_SIGNAL_LONG_QNAME, "long qname"},
+	{DNS_TUNNEL_SIGNAL_HIGH_ENTROPY, "high entropy label"},
+	{DNS_TUNNEL_SIGNAL_RECORD_TYPE_VOLUME, "txt/null volume"},
+}
+
+func (s DNSTunnelSignal) String() string {
+	names := []string{}
+	for _, n := range dnsTunnelSignalNames {
+		if s&n.signal != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// DNSTunnelDomain is the state of a base domain, such as example.com for a.b.example.com
+// ベースドメイン(a.b.example.comに対するexample.comなど)の状態です
+type DNSTunnelDomain struct {
+	Domain  string
+	Queries int
+	// TXT/NULL queries within the current window
+	// 現在の期間内のTXT/NULLクエリ数
+	RecordTypeQueries int
+	LongestQName      int
+	MaxEntropy        float64
+	Signals           DNSTunnelSignal
+	LastSeen          time.Time
+
+	windowStart time.Time
+	flagged     bool // 疑わしいとしてログ出力済みか
+}
+
+// Score returns the number of distinct signals seen for the domain
+// ドメインで観測したシグナルの種類数を返します
+func (d DNSTunnelDomain) Score() int {
+	return bits.OnesCount8(uint8(d.Signals))
+}
+
+// Suspect reports whether the domain is likely used for DNS tunneling
+// ドメインがDNSトンネリングに使われている疑いがあるかどうかを返します
+func (d DNSTunnelDomain) Suspect() bool {
+	return d.Score() >= DNS_TUNNEL_SUSPECT_SCORE
+}
+
+// DNSTunnelAlert is a query to a domain suspected of DNS tunneling
+// DNSトンネリングの疑いがあるドメインへのクエリです
+type DNSTunnelAlert struct {
+	Domain DNSTunnelDomain
+	QName  string
+	Client string // 問い合わせ元のアドレス
+}
+
+func (a DNSTunnelAlert) String() string {
+	return fmt.Sprintf("DNS tunnel suspect: domain=%s score=%d signals=%s qname=%s client=%s",
+		a.Domain.Domain, a.Domain.Score(), a.Domain.Signals, a.QName, a.Client)
+}
+
+// DNSTunnelDetector scores the DNS queries seen on the wire per base domain for signs of DNS tunneling:
+// abnormally long QNAMEs, high entropy labels and a high volume of TXT/NULL queries.
+// A domain showing DNS_TUNNEL_SUSPECT_SCORE or more kinds of signals is a suspect.
+// 観測したDNSクエリをベースドメインごとに評価し、DNSトンネリングの疑いがあるドメインを検出します
+type DNSTunnelDetector struct {
+	window     time.Duration
+	maxDomains int
+
+	mu      sync.Mutex
+	domains map[string]*DNSTunnelDomain
+}
+
+// NewDNSTunnelDetector creates a detector. Zero or less uses the defaults.
+// 検出器を作成します。0以下の場合はデフォルト値を使います
+func NewDNSTunnelDetector(window time.Duration, maxDomains int) *DNSTunnelDetector {
+	if window <= 0 {
+		window = DNS_TUNNEL_DEFAULT_WINDOW
+	}
+	if maxDomains <= 0 {
+		maxDomains = DNS_TUNNEL_DEFAULT_MAX_DOMAINS
+	}
+	return &DNSTunnelDetector{
+		window:     window,
+		maxDomains: maxDomains,
+		domains:    map[string]*DNSTunnelDomain{},
+	}
+}
+
+// Observe feeds a received packet to the detector and returns an alert if it queries a suspected domain.
+// Responses and non DNS packets are ignored.
+// 受信したパケットを検出器に渡し、疑わしいドメインへのクエリであればアラートを返します。レスポンスとDNS以外のパケットは無視します
+func (d *DNSTunnelDetector) Observe(p *Passive, now time.Time) *DNSTunnelAlert {
+	if p == nil || p.DNS == nil || !IsDNSRequest(p.DNS.Flags) {
+		return nil
+	}
+	client, _, _, _, ok := dnsEndpoints(p)
+	if !ok {
+		return nil
+	}
+	qname, qtype, err := dnsQuestion(p.DNS.message())
+	if err != nil {
+		return nil
+	}
+	qname = dnsCanonicalName(qname)
+	base, labels := dnsBaseDomain(qname)
+	if base == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	domain, ok := d.domains[base]
+	if !ok {
+		if len(d.domains) >= d.maxDomains {
+			d.evictLeastRecent()
+		}
+		domain = &DNSTunnelDomain{Domain: base, windowStart: now}
+		d.domains[base] = domain
+	}
+	domain.Queries++
+	domain.LastSeen = now
+
+	if len(qname) > domain.LongestQName {
+		domain.LongestQName = len(qname)
+	}
+	if len(qname) > DNS_TUNNEL_LONG_QNAME_LENGTH {
+		domain.Signals |= DNS_TUNNEL_SIGNAL_LONG_QNAME
+	}
+	for _, label := range labels {
+		if len(label) < DNS_TUNNEL_ENTROPY_MIN_LABEL_LENGTH {
+			continue
+		}
+		entropy := shannonEntropy(label)
+		domain.MaxEntropy = max(domain.MaxEntropy, entropy)
+		if entropy >= DNS_TUNNEL_HIGH_ENTROPY {
+			domain.Signals |= DNS_TUNNEL_SIGNAL_HIGH_ENTROPY
+		}
+	}
+	if qtype == DNS_QUERY_TYPE_TXT || qtype == DNS_QUERY_TYPE_NULL {
+		if now.Sub(domain.windowStart) >= d.window {
+			domain.windowStart = now
+			domain.RecordTypeQueries = 0
+		}
+		domain.RecordTypeQueries++
+		if domain.RecordTypeQueries > DNS_TUNNEL_RECORD_TYPE_VOLUME {
+			domain.Signals |= DNS_TUNNEL_SIGNAL_RECORD_TYPE_VOLUME
+		}
+	}
+
+	if !domain.Suspect() {
+		return nil
+	}
+	alert := &DNSTunnelAlert{Domain: *domain, QName: qname, Client: client}
+	// トンネルは大量のクエリを伴うので、ログはドメインごとに最初の 1 回だけにする
+	if !domain.flagged {
+		domain.flagged = true
+		logDNSTunnel(*alert)
+	}
+	return alert
+}
+
+// Suspects returns the tracked domains suspected of DNS tunneling, highest score first
+// DNSトンネリングの疑いがある追跡中のドメインを、スコアの高い順に返します
+func (d *DNSTunnelDetector) Suspects() []DNSTunnelDomain {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	suspects := []DNSTunnelDomain{}
+	for _, domain := range d.domains {
+		if domain.Suspect() {
+			suspects = append(suspects, *domain)
+		}
+	}
+	sort.Slice(suspects, func(i, j int) bool {
+		if suspects[i].Score() != suspects[j].Score() {
+			return suspects[i].Score() > suspects[j].Score()
+		}
+		return suspects[i].Domain < suspects[j].Domain
+	})
+	return suspects
+}
+
+// Tracked returns the number of domains currently tracked
+// 現在追跡しているドメイン数を返します
+func (d *DNSTunnelDetector) Tracked() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.domains)
+}
+
+// 上限に達した時だけなので、全体を走査して最も長く問い合わせのないドメインを捨てる
+func (d *DNSTunnelDetector) evictLeastRecent() {
+	var oldest *DNSTunnelDomain
+	for _, domain := range d.domains {
+		if oldest == nil || domain.LastSeen.Before(oldest.LastSeen) {
+			oldest = domain
+		}
+	}
+	if oldest != nil {
+		delete(d.domains, oldest.Domain)
+	}
+}
+
+// dnsBaseDomain splits qname into its base domain, the last two labels, and the labels below it.
+// Public suffixes such as co.jp are not considered, so tunnels under them are grouped by the suffix.
+// qnameをベースドメイン(最後の2ラベル)とその下のラベルに分けます。co.jpなどのパブリックサフィックスは考慮しません
+func dnsBaseDomain(qname string) (string, []string) {
+	labels := strings.Split(qname, ".")
+	if len(labels) <= 2 {
+		return qname, nil
+	}
+	return strings.Join(labels[len(labels)-2:], "."), labels[:len(labels)-2]
+}
+
+// 1 文字あたりのシャノンエントロピー(ビット). DNS は大文字小文字を区別しないので揃えてから数える
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range strings.ToLower(s) {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,93 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dnsTunnelTestQuery(qname string, qtype uint16) *Passive {
+	msg := dnsTestMessage(0x1234, false, qname, nil, nil)
+	binary.BigEndian.PutUint16(msg[12+len(dnsTestName(qname)):], qtype)
+	return dnsTestPassive(dnsTestClient, dnsTestResolver, 40000, PORT_DNS, msg)
+}
+
+func TestDNSTunnelDetector(t *testing.T) {
+	now := time.Now()
+
+	t.Run("benign query", func(t *testing.T) {
+		d := NewDNSTunnelDetector(0, 0)
+		if alert := d.Observe(dnsTunnelTestQuery("www.example.com", DNS_QUERY_TYPE_A), now); alert != nil {
+			t.Errorf("alert = %v, want none", alert)
+		}
+		// レスポンスは質問を繰り返すだけなので数えない
+		d.Observe(dnsTestResponse(dnsTestResolver, 0x1234, "www.example.com", nil, nil), now)
+		if d.Tracked() != 1 || len(d.Suspects()) != 0 {
+			t.Errorf("tracked %d, suspects %v", d.Tracked(), d.Suspects())
+		}
+	})
+
+	t.Run("high entropy long label", func(t *testing.T) {
+		d := NewDNSTunnelDetector(0, 0)
+		// base32 でエンコードしたデータの 63 文字のラベル
+		qname := "mzxw6ytboi4tqnbvgy3dqmjsgm2dknrxhaytcmrtgq7tmnzyhe2dcnbrgu3tsnj.t.tunnel.test"
+		alert := d.Observe(dnsTunnelTestQuery(qname, DNS_QUERY_TYPE_A), now)
+		if alert == nil {
+			t.Fatal("a long high entropy label should be flagged")
+		}
+		if alert.Domain.Domain != "tunnel.test" || alert.QName != qname || alert.Client != dnsTestClient {
+			t.Errorf("alert = %+v", alert)
+		}
+		want := DNS_TUNNEL_SIGNAL_LONG_QNAME | DNS_TUNNEL_SIGNAL_HIGH_ENTROPY
+		if alert.Domain.Signals != want || alert.Domain.MaxEntropy < DNS_TUNNEL_HIGH_ENTROPY {
+			t.Errorf("signals %s, entropy %.2f, want %s", alert.Domain.Signals, alert.Domain.MaxEntropy, want)
+		}
+		// 疑わしいドメインへのクエリは短くても検出する
+		if d.Observe(dnsTunnelTestQuery("a.tunnel.test", DNS_QUERY_TYPE_A), now) == nil {
+			t.Error("a later query to the suspected domain should be flagged")
+		}
+		if suspects := d.Suspects(); len(suspects) != 1 || suspects[0].Queries != 2 {
+			t.Errorf("suspects = %+v", suspects)
+		}
+	})
+
+	t.Run("txt volume", func(t *testing.T) {
+		d := NewDNSTunnelDetector(time.Minute, 0)
+		query := func(i int, at time.Time) *DNSTunnelAlert {
+			return d.Observe(dnsTunnelTestQuery(strings.Repeat("a", i%10+1)+".txt.test", DNS_QUERY_TYPE_TXT), at)
+		}
+		// 期間が切り替われば数え直す
+		for i := 0; i < DNS_TUNNEL_RECORD_TYPE_VOLUME; i++ {
+			query(i, now)
+		}
+		query(0, now.Add(time.Minute))
+		if s := d.domains["txt.test"].Signals; s != 0 {
+			t.Errorf("signals after the window = %s, want none", s)
+		}
+
+		// 量だけでは疑わしいとしない
+		for i := 0; i < DNS_TUNNEL_RECORD_TYPE_VOLUME; i++ {
+			if alert := query(i, now.Add(time.Minute)); alert != nil {
+				t.Fatalf("volume alone should not be flagged: %v", alert)
+			}
+		}
+		if s := d.domains["txt.test"].Signals; s != DNS_TUNNEL_SIGNAL_RECORD_TYPE_VOLUME {
+			t.Fatalf("signals = %s, want %s", s, DNS_TUNNEL_SIGNAL_RECORD_TYPE_VOLUME)
+		}
+		if d.Observe(dnsTunnelTestQuery(strings.Repeat("x", DNS_TUNNEL_LONG_QNAME_LENGTH)+".txt.test", DNS_QUERY_TYPE_NULL), now.Add(time.Minute)) == nil {
+			t.Error("volume with a long qname should be flagged")
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		d := NewDNSTunnelDetector(0, 2)
+		d.Observe(dnsTunnelTestQuery("www.a.test", DNS_QUERY_TYPE_A), now)
+		d.Observe(dnsTunnelTestQuery("www.b.test", DNS_QUERY_TYPE_A), now.Add(2*time.Second))
+		d.Observe(dnsTunnelTestQuery("www.a.test", DNS_QUERY_TYPE_A), now.Add(3*time.Second))
+		d.Observe(dnsTunnelTestQuery("www.c.test", DNS_QUERY_TYPE_A), now.Add(4*time.Second))
+		if _, ok := d.domains["b.test"]; d.Tracked() != 2 || ok {
+			t.Errorf("tracked %d, b.test kept %v, want the least recently queried b.test dropped", d.Tracked(), ok)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package packemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DSCP is the 6-bit Differentiated Services Code Point in the upper bits of the IPv4 TOS and IPv6 Traffic Class (RFC 2474)
+// IPv4のTOSとIPv6のTraffic Classの上位6ビットのDSCP(RFC 2474)です
+type DSCP uint8
+
+// Common DSCP values
+// よく使われるDSCPの値
+const (
+	DSCP_CS0         DSCP = 0 // Default / Best Effort
+	DSCP_LE          DSCP = 1 // Lower Effort (RFC 8622)
+	DSCP_CS1         DSCP = 8
+	DSCP_AF11        DSCP = 10
+	DSCP_AF12        DSCP = 12
+	DSCP_AF13        DSCP = 14
+	DSCP_CS2         DSCP = 16
+	DSCP_AF21        DSCP = 18
+	DSCP_AF22        DSCP = 20
+	DSCP_AF23        DSCP = 22
+	DSCP_CS3         DSCP = 24
+	DSCP_AF31        DSCP = 26
+	DSCP_AF32        DSCP = 28
+	DSCP_AF33        DSCP = 30
+	DSCP_CS4         DSCP = 32
+	DSCP_AF41        DSCP = 34
+	DSCP_AF42        DSCP = 36
+	DSCP_AF43        DSCP = 38
+	DSCP_CS5         DSCP = 40
+	DSCP_VOICE_ADMIT DSCP = 44 // RFC 5865
+	DSCP_EF          DSCP = 46 // Expedited Forwarding (RFC 3246)
+	DSCP_CS6         DSCP = 48
+	DSCP_CS7         DSCP = 56
+)
+
+var dscpNames = map[DSCP]string{
+	DSCP_CS0:         "CS0",
+	DSCP_LE:          "LE",
+	DSCP_CS1:         "CS1",
+	DSCP_AF11:        "AF11",
+	DSCP_AF12:        "AF12",
+	DSCP_AF13:        "AF13",
+	DSCP_CS2:         "CS2",
+	DSCP_AF21:        "AF21",
+	DSCP_AF22:        "AF22",
+	DSCP_AF23:        "AF23",
+	DSCP_CS3:         "CS3",
+	DSCP_AF31:        "AF31",
+	DSCP_AF32:        "AF32",
+	DSCP_AF33:        "AF33",
+	DSCP_CS4:         "CS4",
+	DSCP_AF41:        "AF41",
+	DSCP_AF42:        "AF42",
+	DSCP_AF43:        "AF43",
+	DSCP_CS5:         "CS5",
+	DSCP_VOICE_ADMIT: "VOICE-ADMIT",
+	DSCP_EF:          "EF",
+	DSCP_CS6:         "CS6",
+	DSCP_CS7:         "CS7",
+}
+
+// String returns the name of a common DSCP value such as "EF" or "AF41", or the number for others
+// "EF"や"AF41"のようなよく使われるDSCPの名前を返します。それ以外は数値を返します
+func (d DSCP) String() string {
+	if name, ok := dscpNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", uint8(d))
+}
+
+// MarshalText encodes the DSCP as its String, so that JSON such as a config file reads "EF" instead of 46
+// DSCPをStringの値で表します。設定ファイルなどのJSONで46ではなく"EF"となります
+func (d DSCP) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText decodes the name of a common DSCP value such as "EF" or a number from 0 to 63
+// "EF"のようなよく使われるDSCPの名前、または0から63の数値を解析します
+func (d *DSCP) UnmarshalText(text []byte) error {
+	for dscp, name := range dscpNames {
+		if strings.EqualFold(name, string(text)) {
+			*d = dscp
+			return nil
+		}
+	}
+	n, err := strconv.ParseUint(string(text), 10, 6)
+	if err != nil {
+		return fmt.Errorf("invalid DSCP %q", text)
+	}
+	*d = DSCP(n)
+	return nil
+}
+
+// ECN is the 2-bit Explicit Congestion Notification field in the lower bits of the IPv4 TOS and IPv6 Traffic Class (RFC 3168)
+// IPv4のTOSとIPv6のTraffic Classの下位2ビットのECN(RFC 3168)です
+type ECN uint8
+
+const (
+	ECN_NOT_ECT ECN = 0b00 // ECNに対応していない
+	ECN_ECT1    ECN = 0b01 // ECN対応(ECT(1))
+	ECN_ECT0    ECN = 0b10 // ECN対応(ECT(0))
+	ECN_CE      ECN = 0b11 // 輻輳を経験した(Congestion Experienced)
+)
+
+var ecnNames = map[ECN]string{
+	ECN_NOT_ECT: "Not-ECT",
+	ECN_ECT1:    "ECT(1)",
+	ECN_ECT0:    "ECT(0)",
+	ECN_CE:      "CE",
+}
+
+func (e ECN) String() string {
+	if name, ok := ecnNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("ECN(%d)", uint8(e))
+}
+
+// DSCP returns the DSCP of the TOS byte
+// TOSのDSCPを返します
+func (p *IPv4Packet) DSCP() DSCP {
+	return DSCP(p.TOS >> 2)
+}
+
+// ECN returns the ECN state of the TOS byte
+// TOSのECNの状態を返します
+func (p *IPv4Packet) ECN() ECN {
+	return ECN(p.TOS & 0b11)
+}
+
+// DSCP returns the DSCP of the Traffic Class
+// Traffic ClassのDSCPを返します
+func (p *IPv6Packet) DSCP() DSCP {
+	return DSCP(p.TrafficClass >> 2)
+}
+
+// ECN returns the ECN state of the Traffic Class
+// Traffic ClassのECNの状態を返します
+func (p *IPv6Packet) ECN() ECN {
+	return ECN(p.TrafficClass & 0b11)
+}
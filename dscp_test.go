@@ -0,0 +1,42 @@
+package packemon
+
+import "testing"
+
+// TestDSCPAndECN tests decoding the DSCP and ECN of a few TOS bytes, and the same byte as an IPv6 Traffic Class
+// いくつかのTOSのDSCPとECNの解析と、同じ値をIPv6のTraffic Classとして解析することをテストします
+func TestDSCPAndECN(t *testing.T) {
+	tests := []struct {
+		tos      uint8
+		wantDSCP DSCP
+		wantName string
+		wantECN  ECN
+	}{
+		{tos: 0x00, wantDSCP: DSCP_CS0, wantName: "CS0", wantECN: ECN_NOT_ECT},
+		{tos: 0xb8, wantDSCP: DSCP_EF, wantName: "EF", wantECN: ECN_NOT_ECT},
+		{tos: 0x8a, wantDSCP: DSCP_AF41, wantName: "AF41", wantECN: ECN_ECT0},
+		{tos: 0xb9, wantDSCP: DSCP_EF, wantName: "EF", wantECN: ECN_ECT1},
+		{tos: 0xc3, wantDSCP: DSCP_CS6, wantName: "CS6", wantECN: ECN_CE},
+		{tos: 0x0c, wantDSCP: 3, wantName: "3", wantECN: ECN_NOT_ECT},
+	}
+
+	for _, tt := range tests {
+		ipv4 := &IPv4Packet{TOS: tt.tos}
+		if got := ipv4.DSCP(); got != tt.wantDSCP || got.String() != tt.wantName {
+			t.Errorf("TOS 0x%02x: DSCP() = %d (%s), want %d (%s)", tt.tos, got, got, tt.wantDSCP, tt.wantName)
+		}
+		if got := ipv4.ECN(); got != tt.wantECN {
+			t.Errorf("TOS 0x%02x: ECN() = %s, want %s", tt.tos, got, tt.wantECN)
+		}
+
+		ipv6 := &IPv6Packet{TrafficClass: tt.tos}
+		if ipv6.DSCP() != tt.wantDSCP || ipv6.ECN() != tt.wantECN {
+			t.Errorf("Traffic Class 0x%02x: DSCP() = %s, ECN() = %s, want %s, %s", tt.tos, ipv6.DSCP(), ipv6.ECN(), tt.wantDSCP, tt.wantECN)
+		}
+	}
+
+	for ecn, want := range map[ECN]string{ECN_NOT_ECT: "Not-ECT", ECN_ECT0: "ECT(0)", ECN_ECT1: "ECT(1)", ECN_CE: "CE"} {
+		if ecn.String() != want {
+			t.Errorf("ECN(%d).String() = %s, want %s", uint8(ecn), ecn, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package packemon
+
+import (
+	"fmt"
+)
+
+// https://www.iana.org/assignments/ieee-802-numbers/ieee-802-numbers.xhtml#ieee-802-numbers-1
+var etherTypeNames = map[uint16]string{
+	ETHER_TYPE_IPv4:            "IPv4",
+	ETHER_TYPE_ARP:             "ARP",
+	ETHER_TYPE_WOL:             "Wake-on-LAN",
+	0x8035:                     "RARP",
+	ETHER_TYPE_VLAN:            "VLAN",
+	ETHER_TYPE_IPv6:            "IPv6",
+	0x8808:                     "Ethernet Flow Control",
+	ETHER_TYPE_MPLS:            "MPLS",
+	0x8848:                     "MPLS Multicast",
+	ETHER_TYPE_PPPOE_DISCOVERY: "PPPoE Discovery",
+	ETHER_TYPE_PPPOE_SESSION:   "PPPoE Session",
+	0x888e:                     "EAPOL",
+	ETHER_TYPE_QINQ:            "QinQ",
+	ETHER_TYPE_LLDP:            "LLDP",
+	0x88e5:                     "MACsec",
+	0x88f7:                     "PTP",
+}
+
+// EtherTypeName returns the name of an EtherType, e.g. "IPv4" for 0x0800, or "Unknown (0x....)" for the others
+// EtherTypeの名前(0x0800なら"IPv4"など)を返します。それ以外は"Unknown (0x....)"を返します
+func EtherTypeName(t uint16) string {
+	if name, ok := etherTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (0x%04x)", t)
+}
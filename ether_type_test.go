@@ -0,0 +1,28 @@
+package packemon
+
+import (
+	"testing"
+)
+
+func TestEtherTypeName(t *testing.T) {
+	tests := []struct {
+		typ  uint16
+		want string
+	}{
+		{typ: ETHER_TYPE_IPv4, want: "IPv4"},
+		{typ: ETHER_TYPE_IPv6, want: "IPv6"},
+		{typ: ETHER_TYPE_ARP, want: "ARP"},
+		{typ: 0x8100, want: "VLAN"},
+		{typ: 0x88a8, want: "QinQ"},
+		{typ: 0x8847, want: "MPLS"},
+		{typ: 0x8864, want: "PPPoE Session"},
+		{typ: 0x88cc, want: "LLDP"},
+		{typ: 0x0842, want: "Wake-on-LAN"},
+		{typ: 0x1234, want: "Unknown (0x1234)"},
+	}
+	for _, tt := range tests {
+		if got := EtherTypeName(tt.typ); got != tt.want {
+			t.Errorf("EtherTypeName(0x%04x) = %s, want %s", tt.typ, got, tt.want)
+		}
+	}
+}
@@ -57,3 +57,10 @@ func (h *HardwareAddr) String() string {
 const ETHER_TYPE_IPv4 uint16 = 0x0800
 const ETHER_TYPE_IPv6 uint16 = 0x86dd
 const ETHER_TYPE_ARP uint16 = 0x0806
+const ETHER_TYPE_WOL uint16 = 0x0842  // Wake-on-LAN
+const ETHER_TYPE_VLAN uint16 = 0x8100 // IEEE 802.1Q
+const ETHER_TYPE_QINQ uint16 = 0x88a8 // IEEE 802.1ad
+const ETHER_TYPE_MPLS uint16 = 0x8847
+const ETHER_TYPE_PPPOE_DISCOVERY uint16 = 0x8863
+const ETHER_TYPE_PPPOE_SESSION uint16 = 0x8864
+const ETHER_TYPE_LLDP uint16 = 0x88cc
@@ -0,0 +1,134 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// ETHERNET_FCS_LENGTH is the length of the Frame Check Sequence at the end of an Ethernet frame
+// Ethernetフレーム末尾のFCS(Frame Check Sequence)の長さです
+const ETHERNET_FCS_LENGTH = 4
+
+// FCSMode tells whether captured frames end with the Ethernet FCS. Most captures don't include it,
+// but some NICs and capture setups keep it, and the 4 bytes would otherwise be parsed as trailing payload.
+// キャプチャしたフレームの末尾にEthernetのFCSが含まれるかを表します。多くのキャプチャには含まれませんが、
+// NICやキャプチャの設定によっては残っており、そのままでは4バイトが末尾のペイロードとして解析されてしまいます
+type FCSMode int32
+
+const (
+	FCS_MODE_NONE     FCSMode = iota // FCSは含まれない(デフォルト)
+	FCS_MODE_STRIP                   // 末尾のFCSを取り除いてから解析する
+	FCS_MODE_VALIDATE                // 末尾のFCSを取り除き、CRC-32を検証する
+)
+
+var fcsModeNames = map[FCSMode]string{
+	FCS_MODE_NONE:     "none",
+	FCS_MODE_STRIP:    "strip",
+	FCS_MODE_VALIDATE: "validate",
+}
+
+func (m FCSMode) String() string {
+	if name, ok := fcsModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("FCSMode(%d)", int32(m))
+}
+
+// ParseFCSMode parses "none", "strip" or "validate". An empty name is FCS_MODE_NONE.
+// "none"、"strip"、"validate"を解析します。空文字はFCS_MODE_NONEになります
+func ParseFCSMode(name string) (FCSMode, error) {
+	if name == "" {
+		return FCS_MODE_NONE, nil
+	}
+	for mode, modeName := range fcsModeNames {
+		if strings.EqualFold(name, modeName) {
+			return mode, nil
+		}
+	}
+	return FCS_MODE_NONE, fmt.Errorf("unsupported fcs mode: %s", name)
+}
+
+// FCSStatus is what is known about the FCS of a decoded frame
+// 解析したフレームのFCSの状態を表します
+type FCSStatus int8
+
+const (
+	FCS_STATUS_NONE      FCSStatus = iota // FCSを含まない(FCS_MODE_NONE)
+	FCS_STATUS_UNCHECKED                  // 取り除いたが検証していない
+	FCS_STATUS_VALID                      // 検証して正しかった
+	FCS_STATUS_INVALID                    // 検証して誤っていた
+	FCS_STATUS_MISSING                    // スナップ長で切り詰められていてFCSが無い
+)
+
+var fcsStatusNames = map[FCSStatus]string{
+	FCS_STATUS_NONE:      "",
+	FCS_STATUS_UNCHECKED: "unchecked",
+	FCS_STATUS_VALID:     "valid",
+	FCS_STATUS_INVALID:   "invalid",
+	FCS_STATUS_MISSING:   "missing",
+}
+
+func (s FCSStatus) String() string {
+	if name, ok := fcsStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("FCSStatus(%d)", int8(s))
+}
+
+// DecodeFrameWithFCS decodes a raw Ethernet frame ending with the FCS. The FCS is stripped before parsing and checked if mode is FCS_MODE_VALIDATE
+// FCSで終わる生のEthernetフレームをデコードします。FCSは解析前に取り除かれ、modeがFCS_MODE_VALIDATEの場合は検証されます
+func DecodeFrameWithFCS(data []byte, mode FCSMode) (*Passive, error) {
+	data, wireLength, fcs, status := stripFCS(data, len(data), mode)
+	passive, err := decodeFrame(data, wireLength, nil, PARSE_DEPTH_FULL)
+	if err != nil {
+		return nil, err
+	}
+	passive.FCS, passive.FCSStatus = fcs, status
+	return passive, nil
+}
+
+// stripFCS は末尾の FCS をフレームから取り除き、mode に応じて検証する.
+// 回線上の長さにも FCS が含まれるので同じだけ引く. スナップ長で切り詰められたフレームの末尾は FCS ではないので取り除かない
+func stripFCS(data []byte, wireLength int, mode FCSMode) ([]byte, int, []byte, FCSStatus) {
+	if mode == FCS_MODE_NONE {
+		return data, wireLength, nil, FCS_STATUS_NONE
+	}
+	if wireLength > len(data) || len(data) < ETHERNET_FCS_LENGTH {
+		if wireLength >= ETHERNET_FCS_LENGTH {
+			wireLength -= ETHERNET_FCS_LENGTH
+		}
+		return data, wireLength, nil, FCS_STATUS_MISSING
+	}
+
+	frameLen := len(data) - ETHERNET_FCS_LENGTH
+	frame, fcs := data[:frameLen:frameLen], data[frameLen:]
+	status := FCS_STATUS_UNCHECKED
+	if mode == FCS_MODE_VALIDATE {
+		status = FCS_STATUS_INVALID
+		// FCS は最下位バイトから送られる
+		if crc32.ChecksumIEEE(frame) == binary.LittleEndian.Uint32(fcs) {
+			status = FCS_STATUS_VALID
+		}
+	}
+	return frame, wireLength - ETHERNET_FCS_LENGTH, fcs, status
+}
+
+// SetFCSMode tells whether frames captured on the interface end with the FCS, so that it is stripped (and checked) before parsing.
+// The default is FCS_MODE_NONE.
+// インターフェースでキャプチャしたフレームの末尾にFCSが含まれるかを設定します。含まれる場合は解析前に取り除き、検証します。
+// デフォルトはFCS_MODE_NONEです
+func (nwif *NetworkInterface) SetFCSMode(mode FCSMode) error {
+	if _, ok := fcsModeNames[mode]; !ok {
+		return fmt.Errorf("unsupported fcs mode: %s", mode)
+	}
+	nwif.fcsMode.Store(int32(mode))
+	return nil
+}
+
+// FCSMode returns the current FCS mode
+// 現在のFCSの扱いを返します
+func (nwif *NetworkInterface) FCSMode() FCSMode {
+	return FCSMode(nwif.fcsMode.Load())
+}
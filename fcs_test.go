@@ -0,0 +1,95 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+func fcsTestFrame() ([]byte, []byte) {
+	frame := decodeStatsTestUDPFrame(40000, 9999, []byte("payload"))
+	fcs := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(frame))
+	return frame, append(append([]byte{}, frame...), fcs...)
+}
+
+// TestDecodeFrameWithFCS tests that the FCS at the end of a frame is stripped before parsing and validated
+// フレーム末尾のFCSが解析前に取り除かれ、検証されることをテストします
+func TestDecodeFrameWithFCS(t *testing.T) {
+	frame, withFCS := fcsTestFrame()
+	corrupted := append([]byte{}, withFCS...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	tests := []struct {
+		name       string
+		data       []byte
+		mode       FCSMode
+		wantStatus FCSStatus
+		wantLength int
+	}{
+		{name: "not stripped", data: withFCS, mode: FCS_MODE_NONE, wantStatus: FCS_STATUS_NONE, wantLength: len(withFCS)},
+		{name: "stripped", data: withFCS, mode: FCS_MODE_STRIP, wantStatus: FCS_STATUS_UNCHECKED, wantLength: len(frame)},
+		{name: "valid", data: withFCS, mode: FCS_MODE_VALIDATE, wantStatus: FCS_STATUS_VALID, wantLength: len(frame)},
+		{name: "invalid", data: corrupted, mode: FCS_MODE_VALIDATE, wantStatus: FCS_STATUS_INVALID, wantLength: len(frame)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passive, err := DecodeFrameWithFCS(tt.data, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if passive.FCSStatus != tt.wantStatus {
+				t.Errorf("FCSStatus = %s, want %s", passive.FCSStatus, tt.wantStatus)
+			}
+			if passive.RawLength != tt.wantLength || passive.WireLength != tt.wantLength || len(passive.EthernetFrame.Payload) != tt.wantLength-14 {
+				t.Errorf("RawLength = %d, WireLength = %d, Ethernet payload = %d bytes, want a %d byte frame",
+					passive.RawLength, passive.WireLength, len(passive.EthernetFrame.Payload), tt.wantLength)
+			}
+			if tt.mode == FCS_MODE_NONE {
+				return
+			}
+			if !bytes.Equal(passive.FCS, tt.data[len(frame):]) {
+				t.Errorf("FCS = %x, want %x", passive.FCS, tt.data[len(frame):])
+			}
+			if passive.UDP == nil || string(passive.UDP.Payload) != "payload" {
+				t.Errorf("UDP = %+v, want the payload without the FCS", passive.UDP)
+			}
+		})
+	}
+}
+
+// TestFCSTruncatedAndReader tests a frame cut by the snap length, which has no FCS to strip, and reading frames with the FCS from a stream
+// スナップ長で切り詰められ取り除くFCSの無いフレームと、FCS付きのフレームをストリームから読み込むことをテストします
+func TestFCSTruncatedAndReader(t *testing.T) {
+	_, withFCS := fcsTestFrame()
+
+	data, wireLength, fcs, status := stripFCS(withFCS[:30], len(withFCS), FCS_MODE_VALIDATE)
+	if len(data) != 30 || wireLength != len(withFCS)-ETHERNET_FCS_LENGTH || fcs != nil || status != FCS_STATUS_MISSING {
+		t.Errorf("stripFCS of a truncated frame = %d bytes of %d, FCS %x, %s", len(data), wireLength, fcs, status)
+	}
+
+	fr, err := OpenReader(bytes.NewReader(framedStream(withFCS)), PCAP_LINKTYPE_ETHERNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.SetFCSMode(FCS_MODE_VALIDATE)
+	passive, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.FCSStatus != FCS_STATUS_VALID || NewPassiveJSON(passive).FCS != "valid" {
+		t.Errorf("FCSStatus = %s, want valid", passive.FCSStatus)
+	}
+	if _, err := fr.Next(); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+
+	if mode, err := ParseFCSMode("Validate"); err != nil || mode != FCS_MODE_VALIDATE {
+		t.Errorf("ParseFCSMode(Validate) = %s, %v", mode, err)
+	}
+	if _, err := ParseFCSMode("keep"); err == nil {
+		t.Error("an unsupported mode should be an error")
+	}
+}
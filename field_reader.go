@@ -0,0 +1,107 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrShortRead is returned by FieldReader when fewer bytes remain than a field needs
+// FieldReaderで、フィールドに必要なバイト数が残っていない場合に返されます
+var ErrShortRead = errors.New("short read")
+
+// FieldReader reads header fields from a byte slice in order, checking bounds on every read.
+// A read past the end returns an error wrapping ErrShortRead instead of panicking, and does not advance the reader.
+// バイト列からヘッダーのフィールドを順に読み込みます。読み込みごとに範囲を確認し、
+// 末尾を超える読み込みはパニックせずErrShortReadを含むエラーを返します。その場合、読み込み位置は進みません
+type FieldReader struct {
+	data   []byte
+	offset int
+	order  binary.ByteOrder
+}
+
+// NewFieldReader creates a FieldReader that reads multi-byte fields in order. A nil order means network byte order (big-endian).
+// 複数バイトのフィールドをorderで読み込むFieldReaderを作成します。orderがnilの場合はネットワークバイトオーダー(ビッグエンディアン)です
+func NewFieldReader(data []byte, order binary.ByteOrder) *FieldReader {
+	r := &FieldReader{data: data}
+	r.SetByteOrder(order)
+	return r
+}
+
+// SetByteOrder changes the byte order of the following reads, e.g. for a little-endian header inside a big-endian one
+// 以降の読み込みのバイトオーダーを変更します。ビッグエンディアンのヘッダー内にリトルエンディアンのヘッダーがある場合などに使用します
+func (r *FieldReader) SetByteOrder(order binary.ByteOrder) {
+	if order == nil {
+		order = binary.BigEndian
+	}
+	r.order = order
+}
+
+// Read8 reads one byte
+// 1バイト読み込みます
+func (r *FieldReader) Read8() (uint8, error) {
+	b, err := r.ReadBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// Read16 reads a 2 byte field
+// 2バイトのフィールドを読み込みます
+func (r *FieldReader) Read16() (uint16, error) {
+	b, err := r.ReadBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint16(b), nil
+}
+
+// Read32 reads a 4 byte field
+// 4バイトのフィールドを読み込みます
+func (r *FieldReader) Read32() (uint32, error) {
+	b, err := r.ReadBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint32(b), nil
+}
+
+// ReadBytes reads n bytes. The returned slice shares the underlying array with the input, like the payloads of the parsers.
+// nバイト読み込みます。各パーサーのペイロードと同様に、返すスライスは入力と同じ配列を参照します
+func (r *FieldReader) ReadBytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("negative length %d at offset %d", n, r.offset)
+	}
+	if r.Remaining() < n {
+		return nil, fmt.Errorf("%w: need %d bytes at offset %d, %d left", ErrShortRead, n, r.offset, r.Remaining())
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b, nil
+}
+
+// Skip advances the reader by n bytes
+// nバイト読み飛ばします
+func (r *FieldReader) Skip(n int) error {
+	_, err := r.ReadBytes(n)
+	return err
+}
+
+// Offset returns the number of bytes read so far
+// これまでに読み込んだバイト数を返します
+func (r *FieldReader) Offset() int {
+	return r.offset
+}
+
+// Remaining returns the number of bytes left
+// 残りのバイト数を返します
+func (r *FieldReader) Remaining() int {
+	return len(r.data) - r.offset
+}
+
+// Rest returns the bytes left without advancing the reader
+// 読み込み位置を進めずに残りのバイト列を返します
+func (r *FieldReader) Rest() []byte {
+	return r.data[r.offset:]
+}
@@ -0,0 +1,98 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// TestFieldReader tests reading fields in both byte orders
+// 両方のバイトオーダーでのフィールドの読み込みをテストします
+func TestFieldReader(t *testing.T) {
+	// NetBIOS セッションヘッダー (ビッグエンディアン) の後に SMB2 の StructureSize と CreditCharge (リトルエンディアン)
+	r := NewFieldReader([]byte{0x00, 0x00, 0x00, 0x44, 0x40, 0x00, 0x01, 0x00, 0xaa}, nil)
+	if typ, err := r.Read8(); err != nil || typ != 0x00 {
+		t.Fatalf("Read8() = 0x%02x, %v, want 0x00", typ, err)
+	}
+	if err := r.Skip(1); err != nil {
+		t.Fatal(err)
+	}
+	if length, err := r.Read16(); err != nil || length != 0x0044 {
+		t.Fatalf("Read16() = 0x%04x, %v, want 0x0044 in big-endian", length, err)
+	}
+	r.SetByteOrder(binary.LittleEndian)
+	if fields, err := r.Read32(); err != nil || fields != 0x00010040 {
+		t.Fatalf("Read32() = 0x%08x, %v, want 0x00010040 in little-endian", fields, err)
+	}
+	if r.Offset() != 8 || r.Remaining() != 1 || len(r.Rest()) != 1 || r.Rest()[0] != 0xaa {
+		t.Errorf("Offset() = %d, Remaining() = %d, Rest() = %x, want 8, 1, aa", r.Offset(), r.Remaining(), r.Rest())
+	}
+}
+
+// TestFieldReaderShortRead tests that reads past the end return ErrShortRead without advancing the reader
+// 末尾を超える読み込みが、読み込み位置を進めずにErrShortReadを返すことをテストします
+func TestFieldReaderShortRead(t *testing.T) {
+	reads := map[string]func(r *FieldReader) error{
+		"Read16":    func(r *FieldReader) error { _, err := r.Read16(); return err },
+		"Read32":    func(r *FieldReader) error { _, err := r.Read32(); return err },
+		"ReadBytes": func(r *FieldReader) error { _, err := r.ReadBytes(2); return err },
+		"Skip":      func(r *FieldReader) error { return r.Skip(2) },
+	}
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			r := NewFieldReader([]byte{0x01, 0x02, 0x03}, binary.BigEndian)
+			if err := r.Skip(2); err != nil {
+				t.Fatal(err)
+			}
+			if err := read(r); !errors.Is(err, ErrShortRead) {
+				t.Errorf("%s() with 1 byte left = %v, want ErrShortRead", name, err)
+			}
+			// 失敗した読み込みでは位置は進まない
+			if b, err := r.Read8(); err != nil || b != 0x03 {
+				t.Errorf("Read8() after the short read = 0x%02x, %v, want 0x03", b, err)
+			}
+			if _, err := r.Read8(); !errors.Is(err, ErrShortRead) {
+				t.Errorf("Read8() at the end = %v, want ErrShortRead", err)
+			}
+		})
+	}
+
+	if _, err := NewFieldReader([]byte{0x01}, nil).ReadBytes(-1); err == nil {
+		t.Error("ReadBytes(-1) should return an error")
+	}
+}
+
+// TestFieldReaderParsers tests that the parsers built on FieldReader return an error for every truncated input instead of panicking
+// FieldReaderを使うパーサーが、途中で切れた入力に対してパニックせずエラーを返すことをテストします
+func TestFieldReaderParsers(t *testing.T) {
+	rtp := newTestRTPPacket(100, 16000, 0x11223344)
+	rtp[0] |= 0x10 // 拡張ヘッダー (profile 0xbede, length 1)
+	rtp = append(rtp[:16], append([]byte{0xbe, 0xde, 0x00, 0x01, 0x10, 0xaa, 0x00, 0x00}, rtp[16:]...)...)
+	geneve := []byte{
+		0x02, 0x00, 0x65, 0x58, 0x00, 0x00, 0x64, 0x00, // opt len 2 (8 bytes), Ethernet, VNI 100
+		0x01, 0x02, 0x80, 0x01, 0xde, 0xad, 0xbe, 0xef, // class 0x0102, critical type 0x80, 4 bytes of data
+	}
+
+	parsers := []struct {
+		name  string
+		input []byte
+		parse func([]byte) error
+	}{
+		{"rtp", rtp, func(b []byte) error { _, err := ParsedRTP(b); return err }},
+		{"geneve", geneve, func(b []byte) error { _, err := ParsedGENEVE(b); return err }},
+	}
+	for _, p := range parsers {
+		t.Run(p.name, func(t *testing.T) {
+			if err := p.parse(p.input); err != nil {
+				t.Fatalf("parse of the full input failed: %v", err)
+			}
+			// ヘッダーの途中で切れた入力は全てエラー
+			headerLen := 16
+			for n := 0; n < headerLen; n++ {
+				if err := p.parse(p.input[:n]); err == nil {
+					t.Errorf("parse of the first %d bytes should fail", n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package packemon
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	// DEFAULT_FLOW_ACTIVE_TIMEOUT is how long a flow is accounted before its record is exported, even while packets keep coming
+	// パケットが続いていてもフローのレコードをエクスポートするまでの集計期間です
+	DEFAULT_FLOW_ACTIVE_TIMEOUT = time.Minute
+	// DEFAULT_FLOW_IDLE_TIMEOUT is how long a flow without packets is kept before its record is exported
+	// パケットの無いフローのレコードをエクスポートするまで保持する期間です
+	DEFAULT_FLOW_IDLE_TIMEOUT = 15 * time.Second
+	// DEFAULT_FLOW_MAX_ACTIVE is the number of flows accounted at once. The least recently seen one is exported beyond it
+	// 同時に集計するフローの数です。超えた場合は最も長くパケットの無いフローをエクスポートします
+	DEFAULT_FLOW_MAX_ACTIVE = 65536
+)
+
+// Reasons a flow record is exported, the values of IPFIX's flowEndReason (RFC 5102)
+// フローのレコードをエクスポートする理由。IPFIXのflowEndReason(RFC 5102)の値です
+const (
+	FLOW_END_IDLE_TIMEOUT      uint8 = 0x01
+	FLOW_END_ACTIVE_TIMEOUT    uint8 = 0x02
+	FLOW_END_OF_FLOW           uint8 = 0x03 // TCP の FIN または RST
+	FLOW_END_FORCED            uint8 = 0x04 // Flush
+	FLOW_END_LACK_OF_RESOURCES uint8 = 0x05 // 同時に集計するフローの上限を超えた
+)
+
+// FlowKey identifies a unidirectional flow by its 5-tuple. ICMP and ICMPv6 flows carry the type and code in DstPort
+// (type * 256 + code) like NetFlow
+// 5タプルで片方向のフローを識別します。ICMPとICMPv6のフローはNetFlowと同様にDstPortにタイプとコード(タイプ * 256 + コード)を持ちます
+type FlowKey struct {
+	SrcAddr  netip.Addr
+	DstAddr  netip.Addr
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+}
+
+// FlowRecord is the traffic of a flow between Start and End, the times of its first and last packets.
+// Bytes counts the IP packets including their headers
+// StartからEnd(最初と最後のパケットの時刻)までのフローの通信量です。Bytesはヘッダーを含むIPパケットのバイト数です
+type FlowRecord struct {
+	FlowKey
+	Packets   uint64
+	Bytes     uint64
+	Start     time.Time
+	End       time.Time
+	TCPFlags  uint8 // 観測した TCP フラグの論理和
+	EndReason uint8 // FLOW_END_*
+}
+
+// FlowExporter aggregates captured packets into flow records, like a NetFlow or IPFIX exporter, and passes the records to export
+// when their flows end: after the idle or active timeout, on a TCP FIN or RST, or when too many flows are active.
+// Timeouts follow the timestamps of the packets, so an offline capture is aggregated as if it were live
+// NetFlowやIPFIXのエクスポーターと同様に、キャプチャしたパケットをフローのレコードに集計し、フローが終わった時にexportに渡します。
+// フローが終わるのは、アイドルタイムアウトやアクティブタイムアウトの後、TCPのFINまたはRST、同時に集計するフローが多すぎる場合です。
+// タイムアウトはパケットのタイムスタンプに従うため、オフラインのキャプチャもライブと同様に集計されます
+type FlowExporter struct {
+	mu            sync.Mutex
+	flows         map[FlowKey]*FlowRecord
+	idleTimeout   time.Duration
+	activeTimeout time.Duration
+	maxActive     int
+	export        func([]FlowRecord)
+
+	// 最後に受信したパケットの時刻と、それを受信した実際の時刻. Run の時計に使う
+	lastPacket   time.Time
+	lastReceived time.Time
+	lastExpire   time.Time
+}
+
+// NewFlowExporter creates a flow exporter with the default timeouts. export is called without the exporter locked
+// デフォルトのタイムアウトでフローのエクスポーターを作成します。exportはエクスポーターをロックせずに呼び出します
+func NewFlowExporter(export func([]FlowRecord)) *FlowExporter {
+	return &FlowExporter{
+		flows:         make(map[FlowKey]*FlowRecord),
+		idleTimeout:   DEFAULT_FLOW_IDLE_TIMEOUT,
+		activeTimeout: DEFAULT_FLOW_ACTIVE_TIMEOUT,
+		maxActive:     DEFAULT_FLOW_MAX_ACTIVE,
+		export:        export,
+	}
+}
+
+// SetTimeouts sets the idle and active timeouts. Values of 0 or less keep the current ones
+// アイドルタイムアウトとアクティブタイムアウトを設定します。0以下の値は現在の値のままにします
+func (e *FlowExporter) SetTimeouts(idle, active time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if idle > 0 {
+		e.idleTimeout = idle
+	}
+	if active > 0 {
+		e.activeTimeout = active
+	}
+}
+
+// SetMaxActive sets the number of flows accounted at once. Values of 0 or less keep the current one
+// 同時に集計するフローの数を設定します。0以下の値は現在の値のままにします
+func (e *FlowExporter) SetMaxActive(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if n > 0 {
+		e.maxActive = n
+	}
+}
+
+// Update accounts a received packet. Packets without IP are ignored
+// 受信したパケットを集計します。IPを含まないパケットは無視します
+func (e *FlowExporter) Update(p *Passive) {
+	key, length, ok := flowKeyOf(p)
+	if !ok {
+		return
+	}
+	t := p.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	e.mu.Lock()
+	e.lastPacket, e.lastReceived = t, time.Now()
+	expired := []FlowRecord{}
+	// 毎パケット全フローを調べないよう、パケットの時刻で 1 秒ごとにタイムアウトを調べる
+	if t.Sub(e.lastExpire) >= time.Second {
+		expired = e.expire(t)
+	}
+
+	flow, ok := e.flows[key]
+	if !ok {
+		if len(e.flows) >= e.maxActive {
+			expired = append(expired, e.evictIdlest())
+		}
+		flow = &FlowRecord{FlowKey: key, Start: t}
+		e.flows[key] = flow
+	}
+	flow.Packets++
+	flow.Bytes += uint64(length)
+	if t.After(flow.End) {
+		flow.End = t
+	}
+	if p.TCP != nil {
+		flow.TCPFlags |= p.TCP.Flags
+		if p.TCP.Flags&(TCP_FLAGS_FIN|TCP_FLAGS_RST) != 0 {
+			flow.EndReason = FLOW_END_OF_FLOW
+			expired = append(expired, *flow)
+			delete(e.flows, key)
+		}
+	}
+	e.mu.Unlock()
+
+	e.exportRecords(expired)
+}
+
+// Expire exports the flows idle for the idle timeout or accounted for the active timeout at now
+// nowの時点でアイドルタイムアウトの間パケットが無いフローと、アクティブタイムアウトの間集計したフローをエクスポートします
+func (e *FlowExporter) Expire(now time.Time) {
+	e.mu.Lock()
+	expired := e.expire(now)
+	e.mu.Unlock()
+
+	e.exportRecords(expired)
+}
+
+func (e *FlowExporter) expire(now time.Time) []FlowRecord {
+	e.lastExpire = now
+	expired := []FlowRecord{}
+	for key, flow := range e.flows {
+		switch {
+		case now.Sub(flow.End) >= e.idleTimeout:
+			flow.EndReason = FLOW_END_IDLE_TIMEOUT
+		case now.Sub(flow.Start) >= e.activeTimeout:
+			// 続くパケットは新しいレコードとして集計する
+			flow.EndReason = FLOW_END_ACTIVE_TIMEOUT
+		default:
+			continue
+		}
+		expired = append(expired, *flow)
+		delete(e.flows, key)
+	}
+	return expired
+}
+
+func (e *FlowExporter) evictIdlest() FlowRecord {
+	var idlest *FlowRecord
+	for _, flow := range e.flows {
+		if idlest == nil || flow.End.Before(idlest.End) {
+			idlest = flow
+		}
+	}
+	delete(e.flows, idlest.FlowKey)
+	idlest.EndReason = FLOW_END_LACK_OF_RESOURCES
+	return *idlest
+}
+
+// Flush exports all the active flows, e.g. when the capture stops
+// 集計中のすべてのフローをエクスポートします。例えばキャプチャを止める時に使います
+func (e *FlowExporter) Flush() {
+	e.mu.Lock()
+	flushed := make([]FlowRecord, 0, len(e.flows))
+	for key, flow := range e.flows {
+		flow.EndReason = FLOW_END_FORCED
+		flushed = append(flushed, *flow)
+		delete(e.flows, key)
+	}
+	e.mu.Unlock()
+
+	e.exportRecords(flushed)
+}
+
+func (e *FlowExporter) exportRecords(records []FlowRecord) {
+	if len(records) > 0 && e.export != nil {
+		e.export(records)
+	}
+}
+
+// Active returns the number of flows being accounted
+// 集計中のフローの数を返します
+func (e *FlowExporter) Active() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.flows)
+}
+
+// Run checks the timeouts every interval until ctx is done, then flushes the remaining flows.
+// Time passes from the timestamp of the last packet, so that flows of a quiet link expire too
+// ctxが終了するまでintervalごとにタイムアウトを調べ、終了したら残りのフローをエクスポートします。
+// 通信の無いリンクのフローもタイムアウトするよう、時刻は最後のパケットのタイムスタンプから進めます
+func (e *FlowExporter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.Flush()
+			return
+		case <-ticker.C:
+			e.mu.Lock()
+			now := time.Now()
+			if !e.lastPacket.IsZero() {
+				now = e.lastPacket.Add(time.Since(e.lastReceived))
+			}
+			e.mu.Unlock()
+			e.Expire(now)
+		}
+	}
+}
+
+// フローのキーと、ヘッダーを含む IP パケットの長さ
+func flowKeyOf(p *Passive) (FlowKey, int, bool) {
+	if p == nil {
+		return FlowKey{}, 0, false
+	}
+	key := FlowKey{}
+	length := 0
+	switch {
+	case p.IPv4 != nil:
+		key.SrcAddr, _ = netip.AddrFromSlice(p.IPv4.SrcIP)
+		key.DstAddr, _ = netip.AddrFromSlice(p.IPv4.DstIP)
+		key.Protocol = p.IPv4.Protocol
+		length = int(p.IPv4.TotalLength)
+	case p.IPv6 != nil:
+		key.SrcAddr, _ = netip.AddrFromSlice(p.IPv6.SrcIP)
+		key.DstAddr, _ = netip.AddrFromSlice(p.IPv6.DstIP)
+		key.Protocol = p.IPv6.NextHeader
+		length = 40 + int(p.IPv6.PayloadLen) // 固定ヘッダ + ペイロード
+	default:
+		return FlowKey{}, 0, false
+	}
+	if !key.SrcAddr.IsValid() || !key.DstAddr.IsValid() {
+		return FlowKey{}, 0, false
+	}
+
+	switch {
+	case p.TCP != nil:
+		key.SrcPort, key.DstPort = p.TCP.SrcPort, p.TCP.DstPort
+	case p.UDP != nil:
+		key.SrcPort, key.DstPort = p.UDP.SrcPort, p.UDP.DstPort
+	case p.ICMP != nil:
+		key.DstPort = uint16(p.ICMP.Type)<<8 | uint16(p.ICMP.Code)
+	case p.ICMPv6 != nil:
+		key.DstPort = uint16(p.ICMPv6.Type)<<8 | uint16(p.ICMPv6.Code)
+	}
+	return key, length, true
+}
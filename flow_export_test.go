@@ -0,0 +1,102 @@
+package packemon
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestFlowExporter tests the counts of a flow record after several packets, and the records exported on a TCP FIN,
+// after the idle and active timeouts and beyond the number of active flows
+// 複数のパケットの後のフローのレコードの集計値、およびTCPのFIN、アイドルタイムアウトとアクティブタイムアウトの後、
+// 集計中のフローの上限を超えた場合にエクスポートされるレコードをテストします
+func TestFlowExporter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	decode := func(frame []byte, at time.Duration) *Passive {
+		t.Helper()
+		passive, err := DecodeFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		passive.Timestamp = start.Add(at)
+		return passive
+	}
+
+	exported := []FlowRecord{}
+	exporter := NewFlowExporter(func(records []FlowRecord) { exported = append(exported, records...) })
+	exporter.SetTimeouts(10*time.Second, time.Minute)
+
+	exporter.Update(decode(decodeStatsTestUDPFrame(50000, PORT_DNS, make([]byte, 12)), 0))
+	exporter.Update(decode(decodeStatsTestUDPFrame(50000, PORT_DNS, make([]byte, 32)), 100*time.Millisecond))
+	exporter.Update(decode(decodeStatsTestUDPFrame(50000, PORT_DNS, make([]byte, 2)), 500*time.Millisecond))
+	// 逆方向は別のフロー
+	exporter.Update(decode(decodeStatsTestUDPFrame(PORT_DNS, 50000, make([]byte, 12)), 200*time.Millisecond))
+	if len(exported) != 0 || exporter.Active() != 2 {
+		t.Fatalf("exported = %+v, active = %d, want 2 active flows", exported, exporter.Active())
+	}
+
+	// アイドルタイムアウト
+	exporter.Expire(start.Add(10*time.Second + 300*time.Millisecond))
+	if len(exported) != 1 {
+		t.Fatalf("exported = %+v, want the reverse flow", exported)
+	}
+	exporter.Expire(start.Add(10*time.Second + 500*time.Millisecond))
+	if len(exported) != 2 {
+		t.Fatalf("exported = %+v, want both flows", exported)
+	}
+	got := exported[1]
+	want := FlowRecord{
+		FlowKey: FlowKey{
+			SrcAddr:  netip.MustParseAddr("192.168.10.1"),
+			DstAddr:  netip.MustParseAddr("192.168.10.2"),
+			SrcPort:  50000,
+			DstPort:  PORT_DNS,
+			Protocol: IPv4_PROTO_UDP,
+		},
+		Packets:   3,
+		Bytes:     3*(20+8) + 12 + 32 + 2,
+		Start:     start,
+		End:       start.Add(500 * time.Millisecond),
+		EndReason: FLOW_END_IDLE_TIMEOUT,
+	}
+	if got != want {
+		t.Errorf("record = %+v\nwant %+v", got, want)
+	}
+
+	// TCP の FIN でフローが終わり、フラグが集計される
+	exported = exported[:0]
+	frame := parseDepthTestFrame()
+	exporter.Update(decode(frame, time.Minute))
+	frame[14+20+13] = TCP_FLAGS_FIN_ACK
+	exporter.Update(decode(frame, time.Minute+time.Second))
+	if len(exported) != 1 || exported[0].Packets != 2 || exported[0].TCPFlags != TCP_FLAGS_PSH_ACK|TCP_FLAGS_FIN_ACK ||
+		exported[0].EndReason != FLOW_END_OF_FLOW || exporter.Active() != 0 {
+		t.Fatalf("exported = %+v, want the TCP flow ended by its FIN", exported)
+	}
+
+	// アクティブタイムアウト
+	exported = exported[:0]
+	for i := range 13 {
+		exporter.Update(decode(decodeStatsTestUDPFrame(50000, PORT_DNS, nil), 2*time.Minute+time.Duration(i)*5*time.Second))
+	}
+	// 1 分後のパケットは新しいレコードとして集計する
+	if len(exported) != 1 || exported[0].Packets != 12 || exported[0].EndReason != FLOW_END_ACTIVE_TIMEOUT || exporter.Active() != 1 {
+		t.Fatalf("exported = %+v, want the flow ended by the active timeout", exported)
+	}
+	exporter.Flush()
+
+	// 上限を超えたら最も長くパケットの無いフローを出す
+	exported = exported[:0]
+	exporter.SetMaxActive(2)
+	exporter.Update(decode(decodeStatsTestUDPFrame(1, PORT_DNS, nil), 4*time.Minute))
+	exporter.Update(decode(decodeStatsTestUDPFrame(2, PORT_DNS, nil), 4*time.Minute+100*time.Millisecond))
+	exporter.Update(decode(decodeStatsTestUDPFrame(3, PORT_DNS, nil), 4*time.Minute+200*time.Millisecond))
+	if len(exported) != 1 || exported[0].SrcPort != 1 || exported[0].EndReason != FLOW_END_LACK_OF_RESOURCES || exporter.Active() != 2 {
+		t.Fatalf("exported = %+v, want the idlest flow evicted", exported)
+	}
+
+	exporter.Flush()
+	if len(exported) != 3 || exported[1].EndReason != FLOW_END_FORCED || exporter.Active() != 0 {
+		t.Errorf("exported = %+v, want the remaining flows flushed", exported)
+	}
+}
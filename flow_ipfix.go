@@ -0,0 +1,186 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// IPFIX_PORT is the port IPFIX collectors listen on (RFC 7011)
+	// IPFIXのコレクターが待ち受けるポートです(RFC 7011)
+	IPFIX_PORT = 4739
+	// IPFIX_MAX_MESSAGE_SIZE keeps each message in one unfragmented UDP datagram on an Ethernet path
+	// Ethernetの経路で各メッセージがフラグメント化されない1つのUDPデータグラムに収まるようにします
+	IPFIX_MAX_MESSAGE_SIZE = 1400
+)
+
+const (
+	ipfixVersion          = 10
+	ipfixMessageHeaderLen = 16
+	ipfixSetHeaderLen     = 4
+	ipfixTemplateSetID    = 2
+	ipfixTemplateIPv4     = 256
+	ipfixTemplateIPv6     = 257
+)
+
+// Information Element の ID と長さ (RFC 7012)
+type ipfixField struct {
+	id     uint16
+	length uint16
+}
+
+var (
+	ipfixFieldsIPv4 = []ipfixField{
+		{8, 4},  // sourceIPv4Address
+		{12, 4}, // destinationIPv4Address
+	}
+	ipfixFieldsIPv6 = []ipfixField{
+		{27, 16}, // sourceIPv6Address
+		{28, 16}, // destinationIPv6Address
+	}
+	ipfixFieldsCommon = []ipfixField{
+		{7, 2},   // sourceTransportPort
+		{11, 2},  // destinationTransportPort
+		{4, 1},   // protocolIdentifier
+		{6, 2},   // tcpControlBits
+		{2, 8},   // packetDeltaCount
+		{1, 8},   // octetDeltaCount
+		{152, 8}, // flowStartMilliseconds
+		{153, 8}, // flowEndMilliseconds
+		{136, 1}, // flowEndReason
+	}
+)
+
+// IPFIXEncoder writes flow records as IPFIX messages (RFC 7011), one Write per message, so it can write to a UDP connection
+// to a collector. Every message carries the templates, as collectors over UDP may miss or forget them. It is safe for concurrent use
+// フローのレコードをIPFIXのメッセージ(RFC 7011)として書き込みます。1メッセージを1回のWriteで書き込むため、
+// コレクターへのUDPのコネクションに書き込めます。UDPのコレクターはテンプレートを取りこぼしたり忘れたりするため、
+// すべてのメッセージにテンプレートを含めます。並行に使えます
+type IPFIXEncoder struct {
+	mu                sync.Mutex
+	w                 io.Writer
+	observationDomain uint32
+	sequence          uint32 // これまでに送ったデータレコードの数
+
+	now func() time.Time
+}
+
+// NewIPFIXEncoder returns an encoder writing to w with the observation domain ID
+// 観測ドメインIDを付けてwに書き込むエンコーダーを返します
+func NewIPFIXEncoder(w io.Writer, observationDomain uint32) *IPFIXEncoder {
+	return &IPFIXEncoder{w: w, observationDomain: observationDomain, now: time.Now}
+}
+
+// Encode writes the records in as many messages as needed to keep each within IPFIX_MAX_MESSAGE_SIZE
+// 各メッセージがIPFIX_MAX_MESSAGE_SIZEに収まるよう、必要なだけのメッセージでレコードを書き込みます
+func (enc *IPFIXEncoder) Encode(records []FlowRecord) error {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+
+	templates := ipfixTemplateSet()
+	var ipv4Set, ipv6Set []byte
+	count := 0
+	flush := func() error {
+		if count == 0 {
+			return nil
+		}
+		if err := enc.writeMessage(templates, ipv4Set, ipv6Set, count); err != nil {
+			return err
+		}
+		ipv4Set, ipv6Set, count = nil, nil, 0
+		return nil
+	}
+
+	for _, record := range records {
+		if !record.SrcAddr.IsValid() || !record.DstAddr.IsValid() {
+			continue
+		}
+		data := ipfixDataRecord(record)
+		size := ipfixMessageHeaderLen + len(templates) + len(ipv4Set) + len(ipv6Set) + len(data)
+		if (record.SrcAddr.Is4() && ipv4Set == nil) || (!record.SrcAddr.Is4() && ipv6Set == nil) {
+			size += ipfixSetHeaderLen
+		}
+		if size > IPFIX_MAX_MESSAGE_SIZE {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if record.SrcAddr.Is4() {
+			ipv4Set = append(ipv4Set, data...)
+		} else {
+			ipv6Set = append(ipv6Set, data...)
+		}
+		count++
+	}
+	return flush()
+}
+
+func (enc *IPFIXEncoder) writeMessage(templates, ipv4Set, ipv6Set []byte, count int) error {
+	msg := make([]byte, ipfixMessageHeaderLen, IPFIX_MAX_MESSAGE_SIZE)
+	msg = append(msg, templates...)
+	msg = appendIPFIXSet(msg, ipfixTemplateIPv4, ipv4Set)
+	msg = appendIPFIXSet(msg, ipfixTemplateIPv6, ipv6Set)
+
+	binary.BigEndian.PutUint16(msg[0:2], ipfixVersion)
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(msg)))
+	binary.BigEndian.PutUint32(msg[4:8], uint32(enc.now().Unix()))
+	binary.BigEndian.PutUint32(msg[8:12], enc.sequence)
+	binary.BigEndian.PutUint32(msg[12:16], enc.observationDomain)
+	if _, err := enc.w.Write(msg); err != nil {
+		return err
+	}
+	enc.sequence += uint32(count)
+	return nil
+}
+
+func appendIPFIXSet(msg []byte, setID uint16, records []byte) []byte {
+	if len(records) == 0 {
+		return msg
+	}
+	msg = binary.BigEndian.AppendUint16(msg, setID)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(ipfixSetHeaderLen+len(records)))
+	return append(msg, records...)
+}
+
+func ipfixTemplateSet() []byte {
+	records := []byte{}
+	for _, t := range []struct {
+		id     uint16
+		fields []ipfixField
+	}{
+		{ipfixTemplateIPv4, ipfixFieldsIPv4},
+		{ipfixTemplateIPv6, ipfixFieldsIPv6},
+	} {
+		fields := append(append([]ipfixField{}, t.fields...), ipfixFieldsCommon...)
+		records = binary.BigEndian.AppendUint16(records, t.id)
+		records = binary.BigEndian.AppendUint16(records, uint16(len(fields)))
+		for _, f := range fields {
+			records = binary.BigEndian.AppendUint16(records, f.id)
+			records = binary.BigEndian.AppendUint16(records, f.length)
+		}
+	}
+	return appendIPFIXSet(nil, ipfixTemplateSetID, records)
+}
+
+// テンプレートのフィールドの順に並べたデータレコード
+func ipfixDataRecord(r FlowRecord) []byte {
+	data := []byte{}
+	if r.SrcAddr.Is4() {
+		src, dst := r.SrcAddr.As4(), r.DstAddr.As4()
+		data = append(append(data, src[:]...), dst[:]...)
+	} else {
+		src, dst := r.SrcAddr.As16(), r.DstAddr.As16()
+		data = append(append(data, src[:]...), dst[:]...)
+	}
+	data = binary.BigEndian.AppendUint16(data, r.SrcPort)
+	data = binary.BigEndian.AppendUint16(data, r.DstPort)
+	data = append(data, r.Protocol)
+	data = binary.BigEndian.AppendUint16(data, uint16(r.TCPFlags))
+	data = binary.BigEndian.AppendUint64(data, r.Packets)
+	data = binary.BigEndian.AppendUint64(data, r.Bytes)
+	data = binary.BigEndian.AppendUint64(data, uint64(r.Start.UnixMilli()))
+	data = binary.BigEndian.AppendUint64(data, uint64(r.End.UnixMilli()))
+	return append(data, r.EndReason)
+}
@@ -0,0 +1,111 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestIPFIXEncoder tests the message, template and data record layouts of RFC 7011, and splitting records across messages
+// RFC 7011のメッセージ、テンプレート、データレコードのレイアウト、およびレコードを複数のメッセージに分けることをテストします
+func TestIPFIXEncoder(t *testing.T) {
+	exportTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := FlowRecord{
+		FlowKey: FlowKey{
+			SrcAddr:  netip.MustParseAddr("192.168.10.1"),
+			DstAddr:  netip.MustParseAddr("192.168.10.2"),
+			SrcPort:  50000,
+			DstPort:  PORT_HTTP,
+			Protocol: IPv4_PROTO_TCP,
+		},
+		Packets:   3,
+		Bytes:     180,
+		Start:     exportTime.Add(-time.Second),
+		End:       exportTime.Add(-500 * time.Millisecond),
+		TCPFlags:  TCP_FLAGS_SYN | TCP_FLAGS_ACK,
+		EndReason: FLOW_END_IDLE_TIMEOUT,
+	}
+
+	out := &bytes.Buffer{}
+	enc := NewIPFIXEncoder(out, 7)
+	enc.now = func() time.Time { return exportTime }
+	if err := enc.Encode([]FlowRecord{record}); err != nil {
+		t.Fatal(err)
+	}
+	msg := out.Bytes()
+
+	if v := binary.BigEndian.Uint16(msg[0:2]); v != 10 {
+		t.Errorf("version = %d, want 10", v)
+	}
+	if l := binary.BigEndian.Uint16(msg[2:4]); int(l) != len(msg) {
+		t.Errorf("length = %d, want %d", l, len(msg))
+	}
+	if ts, seq, domain := binary.BigEndian.Uint32(msg[4:8]), binary.BigEndian.Uint32(msg[8:12]), binary.BigEndian.Uint32(msg[12:16]); int64(ts) != exportTime.Unix() || seq != 0 || domain != 7 {
+		t.Errorf("export time = %d, sequence = %d, domain = %d", ts, seq, domain)
+	}
+
+	// テンプレートセット: IPv4 と IPv6 の 11 フィールドずつ
+	templateSet := msg[16:]
+	if id, l := binary.BigEndian.Uint16(templateSet[0:2]), binary.BigEndian.Uint16(templateSet[2:4]); id != 2 || l != 4+2*(4+11*4) {
+		t.Fatalf("template set = id %d, length %d", id, l)
+	}
+	if id, fields := binary.BigEndian.Uint16(templateSet[4:6]), binary.BigEndian.Uint16(templateSet[6:8]); id != 256 || fields != 11 {
+		t.Errorf("IPv4 template = id %d, %d fields", id, fields)
+	}
+
+	dataSet := templateSet[binary.BigEndian.Uint16(templateSet[2:4]):]
+	if id, l := binary.BigEndian.Uint16(dataSet[0:2]), binary.BigEndian.Uint16(dataSet[2:4]); id != 256 || int(l) != len(dataSet) || l != 4+48 {
+		t.Fatalf("data set = id %d, length %d, want 256, 52", id, l)
+	}
+	want := []byte{
+		192, 168, 10, 1, 192, 168, 10, 2, // addresses
+		0xc3, 0x50, 0x00, 0x50, // ports
+		IPv4_PROTO_TCP,
+		0x00, TCP_FLAGS_SYN | TCP_FLAGS_ACK,
+	}
+	want = binary.BigEndian.AppendUint64(want, 3)
+	want = binary.BigEndian.AppendUint64(want, 180)
+	want = binary.BigEndian.AppendUint64(want, uint64(record.Start.UnixMilli()))
+	want = binary.BigEndian.AppendUint64(want, uint64(record.End.UnixMilli()))
+	want = append(want, FLOW_END_IDLE_TIMEOUT)
+	if !bytes.Equal(dataSet[4:], want) {
+		t.Errorf("data record = % x\nwant % x", dataSet[4:], want)
+	}
+
+	// メッセージの上限を超えるレコードは次のメッセージに分け、シーケンス番号を進める
+	out.Reset()
+	ipv6 := record
+	ipv6.SrcAddr, ipv6.DstAddr = netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")
+	records := []FlowRecord{}
+	for range 20 {
+		records = append(records, record, ipv6)
+	}
+	if err := enc.Encode(records); err != nil {
+		t.Fatal(err)
+	}
+	messages, sent := 0, uint32(1)
+	for rest := out.Bytes(); len(rest) > 0; messages++ {
+		l := int(binary.BigEndian.Uint16(rest[2:4]))
+		if l > IPFIX_MAX_MESSAGE_SIZE {
+			t.Errorf("message %d is %d bytes", messages, l)
+		}
+		if seq := binary.BigEndian.Uint32(rest[8:12]); seq != sent {
+			t.Errorf("message %d: sequence = %d, want %d", messages, seq, sent)
+		}
+		// データセットのレコード数を数える
+		for set := rest[16:l]; len(set) > 0; set = set[binary.BigEndian.Uint16(set[2:4]):] {
+			switch id, setLen := binary.BigEndian.Uint16(set[0:2]), int(binary.BigEndian.Uint16(set[2:4])); id {
+			case 256:
+				sent += uint32((setLen - 4) / 48)
+			case 257:
+				sent += uint32((setLen - 4) / 72)
+			}
+		}
+		rest = rest[l:]
+	}
+	if messages < 2 || sent != 41 {
+		t.Errorf("%d messages with %d records, want the 40 records split", messages, sent-1)
+	}
+}
@@ -0,0 +1,122 @@
+package packemon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PCAP_LINKTYPE_RAW is raw IPv4/IPv6 packets without a link layer header
+// リンク層ヘッダーの無いIPv4/IPv6パケットです
+const PCAP_LINKTYPE_RAW = 101
+
+// FRAME_READER_MAX_LENGTH is the largest frame FrameReader accepts. Longer length prefixes are treated as a broken stream
+// FrameReaderが受け付ける最大のフレーム長です。これを超える長さは壊れたストリームとみなします
+const FRAME_READER_MAX_LENGTH = PCAP_DEFAULT_SNAPLEN
+
+// PacketReader reads decoded packets one at a time until io.EOF. FrameReader and PcapReader implement it
+// io.EOFまで解析済みのパケットを1つずつ読み込みます。FrameReaderとPcapReaderが実装しています
+type PacketReader interface {
+	Next() (*Passive, error)
+}
+
+// FrameReader decodes a stream of length-prefixed frames, e.g. from stdin at the end of a shell pipeline.
+// Each frame is a 4 byte big-endian length followed by that many bytes of the frame:
+//
+//	+----------------+---------------------+----------------+-----
+//	| length (4byte) | frame (length byte) | length (4byte) | ...
+//	+----------------+---------------------+----------------+-----
+//
+// The frames are Ethernet frames (PCAP_LINKTYPE_ETHERNET), IP packets (PCAP_LINKTYPE_RAW), Linux cooked captures
+// (PCAP_LINKTYPE_LINUX_SLL, PCAP_LINKTYPE_LINUX_SLL2) or 802.11 frames with or without a radiotap header
+// (PCAP_LINKTYPE_IEEE802_11_RADIOTAP, PCAP_LINKTYPE_IEEE802_11), as accepted by LinkTypeSupported.
+// 長さ付きフレームのストリームをデコードします。各フレームは4バイトのビッグエンディアンの長さと、その長さ分のフレームです
+type FrameReader struct {
+	r        *bufio.Reader
+	linkType int
+	fcsMode  FCSMode
+	length   [4]byte
+}
+
+// OpenReader returns a FrameReader reading frames of linkType from r
+// rからlinkTypeのフレームを読み込むFrameReaderを返します
+func OpenReader(r io.Reader, linkType int) (*FrameReader, error) {
+	if !LinkTypeSupported(linkType) {
+		return nil, fmt.Errorf("unsupported link type: %d", linkType)
+	}
+	return &FrameReader{
+		r:        bufio.NewReader(r),
+		linkType: linkType,
+	}, nil
+}
+
+// ReadFrame returns the next frame as is. It returns io.EOF at the end of the stream and
+// io.ErrUnexpectedEOF if the stream ends in the middle of a frame.
+// 次のフレームをそのまま返します。ストリームの終わりではio.EOF、フレームの途中で終わった場合はio.ErrUnexpectedEOFを返します
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	// io.ReadFull は途中までしか読めなかった場合に io.ErrUnexpectedEOF を返す
+	if _, err := io.ReadFull(fr.r, fr.length[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(fr.length[:])
+	if length > FRAME_READER_MAX_LENGTH {
+		return nil, fmt.Errorf("frame length %d exceeds %d", length, FRAME_READER_MAX_LENGTH)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(fr.r, frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+// Next reads and decodes the next frame. Errors are the same as ReadFrame, or a decode error for a malformed frame.
+// 次のフレームを読み込んでデコードします
+func (fr *FrameReader) Next() (*Passive, error) {
+	frame, err := fr.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return decodeLinkLayer(frame, len(frame), fr.linkType, fr.fcsMode, nil)
+}
+
+// SetFCSMode tells whether the Ethernet frames in the stream end with the FCS, so that Next strips (and checks) it. It has no effect on other link types
+// ストリームのEthernetフレームの末尾にFCSが含まれるかを設定します。含まれる場合はNextが取り除き、検証します。他のリンクタイプには影響しません
+func (fr *FrameReader) SetFCSMode(mode FCSMode) {
+	fr.fcsMode = mode
+}
+
+// Ethernet ヘッダーが無いので、IP のバージョンから EtherType を決めてアドレスの無い EthernetFrame に入れて解析する
+func decodeRawIPPacket(data []byte, decodeAs *DecodeAsTable) (*Passive, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty ip packet")
+	}
+	var etherType uint16
+	switch data[0] >> 4 {
+	case 4:
+		etherType = ETHER_TYPE_IPv4
+	case 6:
+		etherType = ETHER_TYPE_IPv6
+	default:
+		return nil, fmt.Errorf("unsupported ip version: %d", data[0]>>4)
+	}
+	return decodeLinklessPacket(data, etherType, decodeAs), nil
+}
+
+func decodeLinklessPacket(data []byte, etherType uint16, decodeAs *DecodeAsTable) *Passive {
+	passive := &Passive{
+		EthernetFrame: &EthernetFrame{
+			Type:    etherType,
+			Payload: data,
+		},
+		Raw:       data,
+		RawLength: len(data),
+	}
+	parseEthernetPayload(passive, decodeAs, PARSE_DEPTH_FULL)
+	return passive
+}
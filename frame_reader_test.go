@@ -0,0 +1,120 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// 長さを付けてフレームを並べる
+func framedStream(frames ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	for _, frame := range frames {
+		WriteUint32(buf, uint32(len(frame)))
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func frameReaderTestARP() []byte {
+	arp := []byte{
+		0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01,
+		0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 192, 168, 10, 1,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 192, 168, 10, 2,
+	}
+	frame := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x08, 0x06}
+	return append(frame, arp...)
+}
+
+// TestFrameReader tests that framed packets are read one by one until EOF, even when the reader returns a byte at a time
+// 1バイトずつしか読めない場合も含め、長さ付きのフレームをEOFまで1つずつ読めることをテストします
+func TestFrameReader(t *testing.T) {
+	stream := framedStream(parseDepthTestFrame(), frameReaderTestARP())
+
+	tests := []struct {
+		name string
+		r    io.Reader
+	}{
+		{name: "bytes.Reader", r: bytes.NewReader(stream)},
+		{name: "partial reads", r: iotest.OneByteReader(bytes.NewReader(stream))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr, err := OpenReader(tt.r, PCAP_LINKTYPE_ETHERNET)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first, err := fr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.TCP == nil || first.TCP.DstPort != PORT_HTTP || first.HTTP == nil {
+				t.Errorf("first packet should be an HTTP request: %+v", first)
+			}
+
+			second, err := fr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second.ARP == nil || second.ARP.Operation != 1 {
+				t.Errorf("second packet should be an ARP request: %+v", second)
+			}
+
+			if _, err := fr.Next(); err != io.EOF {
+				t.Errorf("err = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderRawIP(t *testing.T) {
+	ipv4 := parseDepthTestFrame()[14:]
+	fr, err := OpenReader(bytes.NewReader(framedStream(ipv4)), PCAP_LINKTYPE_RAW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passive, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.IPv4 == nil || passive.TCP == nil || passive.HTTP == nil {
+		t.Errorf("raw ip packet should be decoded up to HTTP: %+v", passive)
+	}
+}
+
+func TestFrameReaderInvalid(t *testing.T) {
+	if _, err := OpenReader(bytes.NewReader(nil), 147); err == nil {
+		t.Error("unsupported link type should be an error")
+	}
+
+	stream := framedStream(frameReaderTestARP())
+	tooLong := make([]byte, 4)
+	binary.BigEndian.PutUint32(tooLong, FRAME_READER_MAX_LENGTH+1)
+
+	tests := []struct {
+		name   string
+		stream []byte
+	}{
+		{name: "cut in the length", stream: stream[:2]},
+		{name: "cut in the frame", stream: stream[:len(stream)-1]},
+		{name: "too long", stream: tooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr, err := OpenReader(bytes.NewReader(tt.stream), PCAP_LINKTYPE_ETHERNET)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = fr.Next()
+			if err == nil || errors.Is(err, io.EOF) {
+				t.Errorf("err = %v, want an error other than io.EOF", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const PORT_GENEVE = 0x17c1 // 6081
+
+// GENEVE protocol types of the inner packet
+// GENEVEの内側のパケットのプロトコルタイプ
+const (
+	GENEVE_PROTOCOL_TYPE_ETHERNET = 0x6558 // Transparent Ethernet Bridging
+	GENEVE_PROTOCOL_TYPE_IPv4     = ETHER_TYPE_IPv4
+	GENEVE_PROTOCOL_TYPE_IPv6     = ETHER_TYPE_IPv6
+)
+
+// GENEVE represents a GENEVE header and the packet it encapsulates
+// ref: https://datatracker.ietf.org/doc/html/rfc8926#section-3.4
+// GENEVEヘッダーとカプセル化されたパケットを表します
+type GENEVE struct {
+	Version       uint8
+	OptionsLength uint8 // bytes / バイト単位
+	OAM           bool
+	Critical      bool // 重要なオプションを含む
+	ProtocolType  uint16
+	VNI           uint32
+	Options       []GENEVEOption
+	Payload       []byte
+
+	// Inner is the decoded inner packet. nil if the protocol type is not supported
+	// デコードした内側のパケット。未対応のプロトコルタイプの場合はnil
+	Inner *Passive
+}
+
+// GENEVEOption is a variable length GENEVE option. Data excludes the 4 byte option header.
+// GENEVEの可変長オプションです。Dataには4バイトのオプションヘッダーを含みません
+type GENEVEOption struct {
+	Class    uint16
+	Type     uint8
+	Critical bool
+	Data     []byte
+}
+
+// ParsedGENEVE parses a GENEVE packet carried in a UDP payload and decodes the inner packet
+// UDPペイロードに含まれるGENEVEパケットを解析し、内側のパケットをデコードします
+func ParsedGENEVE(payload []byte) (*GENEVE, error) {
+	return parsedGENEVE(payload, nil, &Passive{})
+}
+
+// outer is the packet carrying the GENEVE packet. The inner packet is decoded one tunnel nesting level deeper than it
+// outerはGENEVEパケットを運ぶパケットです。内側のパケットはそれより1段深い入れ子としてデコードします
+func parsedGENEVE(payload []byte, decodeAs *DecodeAsTable, outer *Passive) (*GENEVE, error) {
+	r := NewFieldReader(payload, binary.BigEndian)
+	flags, err := r.ReadBytes(2)
+	if err != nil {
+		return nil, fmt.Errorf("geneve header too short: %w", err)
+	}
+	geneve := &GENEVE{
+		Version:       flags[0] >> 6,
+		OptionsLength: (flags[0] & 0x3f) * 4,
+		OAM:           flags[1]&0x80 != 0,
+		Critical:      flags[1]&0x40 != 0,
+	}
+	if geneve.ProtocolType, err = r.Read16(); err != nil {
+		return nil, fmt.Errorf("geneve header too short: %w", err)
+	}
+	// VNI 24bit + Reserved 8bit
+	if geneve.VNI, err = r.Read32(); err != nil {
+		return nil, fmt.Errorf("geneve header too short: %w", err)
+	}
+	geneve.VNI >>= 8
+	if geneve.Version != 0 {
+		return nil, fmt.Errorf("unsupported geneve version: %d", geneve.Version)
+	}
+	options, err := r.ReadBytes(int(geneve.OptionsLength))
+	if err != nil {
+		return nil, fmt.Errorf("geneve options too short: %w", err)
+	}
+
+	// オプションは種類に関わらず長さで読み進める
+	for optionReader := NewFieldReader(options, binary.BigEndian); optionReader.Remaining() > 0; {
+		option := GENEVEOption{}
+		if option.Class, err = optionReader.Read16(); err != nil {
+			return nil, fmt.Errorf("geneve option header too short: %w", err)
+		}
+		if option.Type, err = optionReader.Read8(); err != nil {
+			return nil, fmt.Errorf("geneve option header too short: %w", err)
+		}
+		option.Critical = option.Type&0x80 != 0
+		length, err := optionReader.Read8()
+		if err != nil {
+			return nil, fmt.Errorf("geneve option header too short: %w", err)
+		}
+		if option.Data, err = optionReader.ReadBytes(int(length&0x1f) * 4); err != nil {
+			return nil, fmt.Errorf("geneve option exceeds options length: %w", err)
+		}
+		geneve.Options = append(geneve.Options, option)
+	}
+	geneve.Payload = r.Rest()
+
+	switch geneve.ProtocolType {
+	case GENEVE_PROTOCOL_TYPE_ETHERNET:
+		geneve.Inner = outer.decodeTunnel("GENEVE", geneve.Payload, 0, decodeAs, PARSE_DEPTH_FULL)
+	case GENEVE_PROTOCOL_TYPE_IPv4, GENEVE_PROTOCOL_TYPE_IPv6:
+		geneve.Inner = outer.decodeTunnel("GENEVE", geneve.Payload, geneve.ProtocolType, decodeAs, PARSE_DEPTH_FULL)
+	}
+	return geneve, nil
+}
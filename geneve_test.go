@@ -0,0 +1,91 @@
+package packemon
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestParsedGENEVE tests decoding of a GENEVE frame carrying an inner Ethernet/IPv4/ICMP packet
+// 内側にEthernet/IPv4/ICMPパケットを持つGENEVEフレームのデコードをテストします
+func TestParsedGENEVE(t *testing.T) {
+	icmp := []byte{ICMP_TYPE_REQUEST, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x01}
+	innerIPv4 := []byte{
+		0x45, 0x00, 0x00, byte(20 + len(icmp)), 0x00, 0x00, 0x00, 0x00, 0x40, IPv4_PROTO_ICMP, 0x00, 0x00,
+		10, 0, 0, 1, 10, 0, 0, 2,
+	}
+	innerIPv4 = append(innerIPv4, icmp...)
+	innerFrame := append([]byte{
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x02, // dst
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, // src
+		0x08, 0x00,
+	}, innerIPv4...)
+
+	geneve := []byte{
+		0x03,       // version 0, options length 3 (12 bytes)
+		0x40,       // critical options present
+		0x65, 0x58, // Transparent Ethernet Bridging
+		0x00, 0x30, 0x39, 0x00, // VNI 12345
+		// class 0x0104, type 0x80 (critical), length 1 (4 bytes)
+		0x01, 0x04, 0x80, 0x01, 0xde, 0xad, 0xbe, 0xef,
+		// 未知のオプション class 0xffff, type 0x01, length 0
+		0xff, 0xff, 0x01, 0x00,
+	}
+	geneve = append(geneve, innerFrame...)
+
+	udp := []byte{0xc3, 0x50, 0x17, 0xc1, 0x00, byte(8 + len(geneve)), 0x00, 0x00}
+	udp = append(udp, geneve...)
+	outerIPv4 := []byte{
+		0x45, 0x00, 0x00, byte(20 + len(udp)), 0x00, 0x00, 0x00, 0x00, 0x40, IPv4_PROTO_UDP, 0x00, 0x00,
+		192, 168, 10, 1, 192, 168, 10, 2,
+	}
+	outerIPv4 = append(outerIPv4, udp...)
+	frame := append([]byte{
+		0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a,
+		0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b,
+		0x08, 0x00,
+	}, outerIPv4...)
+
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatalf("DecodeFrame returned error: %v", err)
+	}
+	g := passive.GENEVE
+	if g == nil {
+		t.Fatalf("GENEVE should be decoded")
+	}
+	if g.Version != 0 || g.OptionsLength != 12 || !g.Critical || g.OAM || g.ProtocolType != GENEVE_PROTOCOL_TYPE_ETHERNET || g.VNI != 12345 {
+		t.Errorf("GENEVE = %+v", g)
+	}
+	if len(g.Options) != 2 {
+		t.Fatalf("len(Options) = %d, want 2", len(g.Options))
+	}
+	if o := g.Options[0]; o.Class != 0x0104 || o.Type != 0x80 || !o.Critical || !bytes.Equal(o.Data, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("Options[0] = %+v", o)
+	}
+	if o := g.Options[1]; o.Class != 0xffff || len(o.Data) != 0 {
+		t.Errorf("Options[1] = %+v", o)
+	}
+
+	inner := g.Inner
+	if inner == nil || inner.EthernetFrame == nil || inner.IPv4 == nil || inner.ICMP == nil {
+		t.Fatalf("inner Ethernet/IPv4/ICMP should be decoded: %+v", inner)
+	}
+	if !bytes.Equal(inner.IPv4.DstIP, []byte{10, 0, 0, 2}) || inner.ICMP.ID != 0x1234 {
+		t.Errorf("inner IPv4 dst = %v, ICMP id = 0x%04x", inner.IPv4.DstIP, inner.ICMP.ID)
+	}
+}
+
+// TestParsedGENEVEInvalid tests that malformed GENEVE headers are rejected
+// 不正なGENEVEヘッダーがエラーになることをテストします
+func TestParsedGENEVEInvalid(t *testing.T) {
+	for name, payload := range map[string][]byte{
+		"too short":         {0x00, 0x00, 0x65, 0x58},
+		"version 1":         {0x40, 0x00, 0x65, 0x58, 0x00, 0x00, 0x01, 0x00},
+		"options truncated": {0x02, 0x00, 0x65, 0x58, 0x00, 0x00, 0x01, 0x00, 0x01, 0x04},
+		"option overflows":  {0x01, 0x00, 0x65, 0x58, 0x00, 0x00, 0x01, 0x00, 0x01, 0x04, 0x00, 0x02},
+	} {
+		if _, err := ParsedGENEVE(payload); err == nil {
+			t.Errorf("%s: ParsedGENEVE should fail", name)
+		}
+	}
+}
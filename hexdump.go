@@ -0,0 +1,188 @@
+package packemon
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 1行あたりのバイト数. Wireshark / tcpdump -xx / xxd / hexdump -C はいずれも16
+const hexDumpBytesPerLine = 16
+
+// ParseHexDump converts a pasted hex dump into raw bytes.
+// It accepts plain hex ("0011aa bb"), "0x.." bytes ("0x00, 0x11"), Wireshark-style offset dumps with an ASCII column and tcpdump -xx output.
+// 貼り付けられた16進ダンプを生のバイト列に変換します
+func ParseHexDump(s string) ([]byte, error) {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if hasOffsetColumn(lines) {
+		return parseOffsetHexDump(lines)
+	}
+	return parsePlainHex(s)
+}
+
+// ImportHexDump parses a pasted hex dump of an Ethernet frame and decodes its upper layers
+// 貼り付けられたEthernetフレームの16進ダンプを解析し、上位レイヤーまでデコードします
+func ImportHexDump(s string) (*Passive, error) {
+	data, err := ParseHexDump(s)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeFrame(data)
+}
+
+// オフセット 0 から始まる行があればオフセット付きのダンプとみなす
+func hasOffsetColumn(lines []string) bool {
+	for _, line := range lines {
+		if offset, rest, ok := splitHexDumpOffset(line); ok && offset == 0 && strings.TrimSpace(rest) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// 行頭のオフセットと残りを分ける. "0000 1122" のような16進の並びと区別するため、
+// オフセットの後ろには ":" か2つ以上の空白(タブを含む)が必要
+func splitHexDumpOffset(line string) (int, string, bool) {
+	line = strings.TrimLeft(line, " \t")
+	end := strings.IndexAny(line, " \t")
+	if end < 0 {
+		return 0, "", false
+	}
+	token, rest := line[:end], line[end:]
+	if !strings.HasSuffix(token, ":") && !strings.HasPrefix(rest, "  ") && !strings.HasPrefix(rest, "\t") {
+		return 0, "", false
+	}
+	offset, ok := parseHexDumpOffset(token)
+	if !ok {
+		return 0, "", false
+	}
+	return offset, rest, true
+}
+
+// "0000", "0x0000:", "00000000" のようなオフセット表記
+func parseHexDumpOffset(token string) (int, bool) {
+	token = strings.TrimSuffix(token, ":")
+	token = strings.TrimPrefix(strings.TrimPrefix(token, "0x"), "0X")
+	if len(token) < 4 || len(token) > 8 || !isHexString(token) {
+		return 0, false
+	}
+	var offset int
+	if _, err := fmt.Sscanf(token, "%x", &offset); err != nil {
+		return 0, false
+	}
+	return offset, true
+}
+
+// オフセットの後ろの16進のグループ
+type hexDumpGroup struct {
+	column int // オフセットの直後からの文字位置
+	data   []byte
+}
+
+// 16進のグループを先頭から読む. 16進でないトークン(ASCII列など)が来たら終わり
+func hexDumpGroups(rest string) []hexDumpGroup {
+	groups := []hexDumpGroup{}
+	for column := 0; column < len(rest); {
+		if rest[column] == ' ' || rest[column] == '\t' {
+			column++
+			continue
+		}
+		end := column
+		for end < len(rest) && rest[end] != ' ' && rest[end] != '\t' {
+			end++
+		}
+		field := rest[column:end]
+		if len(field)%2 != 0 || !isHexString(field) {
+			break
+		}
+		b, err := hex.DecodeString(field)
+		if err != nil {
+			break
+		}
+		groups = append(groups, hexDumpGroup{column: column, data: b})
+		column = end
+	}
+	return groups
+}
+
+func parseOffsetHexDump(lines []string) ([]byte, error) {
+	type dumpLine struct {
+		offset int
+		groups []hexDumpGroup
+	}
+	// tcpdump のヘッダー行などオフセットで始まらない行は読み飛ばす
+	dumpLines := []dumpLine{}
+	for _, line := range lines {
+		offset, rest, ok := splitHexDumpOffset(line)
+		if !ok {
+			continue
+		}
+		dumpLines = append(dumpLines, dumpLine{offset: offset, groups: hexDumpGroups(rest)})
+	}
+
+	// 各行で読むバイト数は次の行のオフセットまで. 最後の行はオフセットの差が分からないので、
+	// 1行分のバイト数に加えて、1行分読み切った行の16進列の幅を超えるグループ(ASCII列)は読まない
+	hexColumnEnd := -1
+	for i := 0; i+1 < len(dumpLines); i++ {
+		want, n := dumpLines[i+1].offset-dumpLines[i].offset, 0
+		for _, group := range dumpLines[i].groups {
+			if n += len(group.data); n == want {
+				hexColumnEnd = max(hexColumnEnd, group.column+len(group.data)*2)
+				break
+			}
+		}
+	}
+
+	data := []byte{}
+	for i, line := range dumpLines {
+		if line.offset != len(data) {
+			return nil, fmt.Errorf("unexpected offset 0x%04x in hex dump, want 0x%04x", line.offset, len(data))
+		}
+		want := hexDumpBytesPerLine
+		if i+1 < len(dumpLines) {
+			want = dumpLines[i+1].offset - line.offset
+		}
+
+		lineData := []byte{}
+		for _, group := range line.groups {
+			if hexColumnEnd >= 0 && group.column+len(group.data)*2 > hexColumnEnd {
+				break
+			}
+			if len(lineData)+len(group.data) > want {
+				break
+			}
+			lineData = append(lineData, group.data...)
+		}
+		data = append(data, lineData...)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("no bytes found in hex dump")
+	}
+	return data, nil
+}
+
+func parsePlainHex(s string) ([]byte, error) {
+	replacer := strings.NewReplacer("0x", "", "0X", "", ",", "", ":", "", "\\x", "")
+	hexStr := strings.Join(strings.Fields(replacer.Replace(s)), "")
+	if len(hexStr) == 0 {
+		return nil, errors.New("no bytes found in hex dump")
+	}
+	if len(hexStr)%2 != 0 {
+		return nil, errors.New("hex dump has an odd number of digits")
+	}
+	data, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex dump: %v", err)
+	}
+	return data, nil
+}
+
+func isHexString(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return len(s) > 0
+}
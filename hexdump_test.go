@@ -0,0 +1,133 @@
+package packemon
+
+import (
+	"bytes"
+	"testing"
+)
+
+// ICMP echo request (Ethernet + IPv4 + ICMP, 42 bytes)
+var hexDumpTestFrame = []byte{
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0x08, 0x00,
+	0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x01, 0xf9, 0x5d, 0xc0, 0xa8, 0x0a, 0x6f,
+	0xc0, 0xa8, 0x0a, 0x6e, 0x08, 0x00, 0xf7, 0xfe, 0x00, 0x01, 0x00, 0x00,
+}
+
+// TestParseHexDump tests each accepted hex dump format
+// 受け付ける各16進ダンプ形式をテストします
+func TestParseHexDump(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{
+			name: "plain hex",
+			dump: "00112233445566778899aabb0800450000 1c000100004001f95dc0a80a6fc0a80a6e0800f7fe00010000\n",
+		},
+		{
+			name: "0x bytes",
+			dump: `0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0x08, 0x00,
+0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x01, 0xf9, 0x5d, 0xc0, 0xa8, 0x0a, 0x6f,
+0xc0, 0xa8, 0x0a, 0x6e, 0x08, 0x00, 0xf7, 0xfe, 0x00, 0x01, 0x00, 0x00`,
+		},
+		{
+			name: "wireshark offset dump",
+			dump: `0000   00 11 22 33 44 55 66 77 88 99 aa bb 08 00 45 00   .."3DUfw......E.
+0010   00 1c 00 01 00 00 40 01 f9 5d c0 a8 0a 6f c0 a8   ......@..]...o..
+0020   0a 6e 08 00 f7 fe 00 01 00 00                     .n........`,
+		},
+		{
+			name: "tcpdump -xx",
+			dump: `12:34:56.789012 IP 192.168.10.111 > 192.168.10.110: ICMP echo request, id 1, seq 0, length 8
+	0x0000:  0011 2233 4455 6677 8899 aabb 0800 4500
+	0x0010:  001c 0001 0000 4001 f95d c0a8 0a6f c0a8
+	0x0020:  0a6e 0800 f7fe 0001 0000
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHexDump(tt.dump)
+		if err != nil {
+			t.Errorf("%s: ParseHexDump returned error: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, hexDumpTestFrame) {
+			t.Errorf("%s: ParseHexDump = %x, want %x", tt.name, got, hexDumpTestFrame)
+		}
+	}
+}
+
+// TestParseHexDumpAmbiguous tests dumps whose ASCII column or plain hex could be taken for bytes or an offset
+// ASCII列や16進の並びがバイトやオフセットと紛らわしいダンプをテストします
+func TestParseHexDumpAmbiguous(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want []byte
+	}{
+		{
+			// 最後の行の ASCII 列 "abcd" も16進として読める
+			name: "hex-like ascii column on a partial last line",
+			dump: `0000   00 11 22 33 44 55 66 77 88 99 aa bb 08 00 45 00   .."3DUfw......E.
+0010   61 62 63 64                                       abcd`,
+			want: append(append([]byte{}, hexDumpTestFrame[:16]...), 0x61, 0x62, 0x63, 0x64),
+		},
+		{
+			name: "hex-like ascii column on a partial last line of xxd",
+			dump: `00000000: 0011 2233 4455 6677 8899 aabb 0800 4500  .."3DUfw......E.
+00000010: 6162 6364                                abcd`,
+			want: append(append([]byte{}, hexDumpTestFrame[:16]...), 0x61, 0x62, 0x63, 0x64),
+		},
+		{
+			name: "plain hex starting with 0000",
+			dump: "0000 0800 4500\n001c 0001",
+			want: []byte{0x00, 0x00, 0x08, 0x00, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01},
+		},
+		{
+			name: "plain hex bytes starting with 0000",
+			dump: "0000 11 22 33",
+			want: []byte{0x00, 0x00, 0x11, 0x22, 0x33},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHexDump(tt.dump)
+		if err != nil {
+			t.Errorf("%s: ParseHexDump returned error: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: ParseHexDump = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestParseHexDumpInvalid tests that malformed dumps are rejected
+// 不正なダンプがエラーになることをテストします
+func TestParseHexDumpInvalid(t *testing.T) {
+	for _, dump := range []string{"", "abc", "zz", "0000   00 11\n0020   22 33"} {
+		if _, err := ParseHexDump(dump); err == nil {
+			t.Errorf("ParseHexDump(%q) should fail", dump)
+		}
+	}
+}
+
+// TestImportHexDump tests that an imported frame is decoded up to ICMP
+// インポートしたフレームがICMPまでデコードされることをテストします
+func TestImportHexDump(t *testing.T) {
+	passive, err := ImportHexDump("0000   00 11 22 33 44 55 66 77 88 99 aa bb 08 00 45 00\n" +
+		"0010   00 1c 00 01 00 00 40 01 f9 5d c0 a8 0a 6f c0 a8\n" +
+		"0020   0a 6e 08 00 f7 fe 00 01 00 00\n")
+	if err != nil {
+		t.Fatalf("ImportHexDump returned error: %v", err)
+	}
+	if passive.RawLength != len(hexDumpTestFrame) {
+		t.Errorf("RawLength = %d, want %d", passive.RawLength, len(hexDumpTestFrame))
+	}
+	if passive.IPv4 == nil || passive.ICMP == nil {
+		t.Fatalf("IPv4 and ICMP should be decoded: %+v", passive)
+	}
+	if passive.ICMP.Type != 0x08 {
+		t.Errorf("ICMP type = %d, want 8", passive.ICMP.Type)
+	}
+}
@@ -148,8 +148,9 @@ func ParsedHTTPResponse(payload []byte) *HTTPResponse {
 		// log.Printf("not suported header: %s, len: %d\n", string(s), len(bytes.TrimSpace(s)))
 	}
 	b := bytes.SplitAfter(payload, append(sep, sep...))
-	body := b[len(b)-1][0:header.ContentLength]
-	length += header.ContentLength
+	// Content-Length が受信したデータより大きくても panic しないよう、受信した分だけ切り出す
+	body, _ := contentLengthHTTPBody(b[len(b)-1], int64(max(header.ContentLength, 0)), len(b[len(b)-1]))
+	length += len(body)
 
 	return &HTTPResponse{
 		StatusLine: statusLine,
@@ -0,0 +1,150 @@
+package packemon
+
+import (
+	"bytes"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DEFAULT_MAX_HTTP_BODY_SIZE is the default limit of an HTTP body kept when decoding, so that a crafted Content-Length or an endless chunked body cannot exhaust the memory of a long-running monitor
+// 解析時に保持するHTTPボディのデフォルトの上限です。細工したContent-Lengthや終わらないchunkedのボディで、長時間動くモニターのメモリを使い果たさないようにします
+const DEFAULT_MAX_HTTP_BODY_SIZE = 1 << 20 // 1 MiB
+
+// 0 は DEFAULT_MAX_HTTP_BODY_SIZE
+var maxHTTPBodySize atomic.Int64
+
+// SetMaxHTTPBodySize sets how many bytes of an HTTP body are kept. Zero or less restores DEFAULT_MAX_HTTP_BODY_SIZE.
+// A longer body is cut at the limit and its layer marked Truncated.
+// 保持するHTTPボディのバイト数を設定します。0以下の場合はDEFAULT_MAX_HTTP_BODY_SIZEに戻します。
+// それより長いボディは上限で切り詰め、そのレイヤーをTruncatedとして記録します
+func SetMaxHTTPBodySize(n int) {
+	if n <= 0 {
+		n = DEFAULT_MAX_HTTP_BODY_SIZE
+	}
+	maxHTTPBodySize.Store(int64(n))
+}
+
+// MaxHTTPBodySize returns the current limit of an HTTP body
+// 現在のHTTPボディの上限を返します
+func MaxHTTPBodySize() int {
+	if n := maxHTTPBodySize.Load(); n > 0 {
+		return int(n)
+	}
+	return DEFAULT_MAX_HTTP_BODY_SIZE
+}
+
+// splitHTTPMessage splits an HTTP/1.x message into its start line, headers and the data after the headers.
+// Header names are canonicalized (e.g. "content-length" to "Content-Length") and repeated headers joined with ", ".
+// When the headers continue in a later segment, the complete header lines are returned and rest is nil.
+// ok is false when data doesn't start with a complete start line
+// HTTP/1.xのメッセージを開始行、ヘッダー、ヘッダーより後ろのデータに分けます。
+// ヘッダー名は正規化し("content-length"を"Content-Length"など)、繰り返されたヘッダーは", "で連結します。
+// ヘッダーが後のセグメントに続く場合は、完全なヘッダー行を返しrestはnilです。
+// dataが完全な開始行で始まらない場合、okはfalseです
+func splitHTTPMessage(data []byte) (startLine string, headers map[string]string, rest []byte, ok bool) {
+	crlf := []byte("\r\n")
+	start, data, found := bytes.Cut(data, crlf)
+	if !found || len(start) == 0 {
+		return "", nil, nil, false
+	}
+
+	headers = make(map[string]string)
+	for {
+		line, next, found := bytes.Cut(data, crlf)
+		if !found {
+			// ヘッダーが途中で切れている
+			return string(start), headers, nil, true
+		}
+		data = next
+		if len(line) == 0 {
+			return string(start), headers, data, true
+		}
+		name, value, found := bytes.Cut(line, []byte(":"))
+		if !found {
+			continue
+		}
+		key := textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(name)))
+		if v, ok := headers[key]; ok {
+			headers[key] = v + ", " + string(bytes.TrimSpace(value))
+		} else {
+			headers[key] = string(bytes.TrimSpace(value))
+		}
+	}
+}
+
+// decodeHTTPBody decodes the body in data, the part of a message after its headers, per Transfer-Encoding: chunked or Content-Length.
+// A response with neither runs until the connection closes, while a request has no body.
+// At most MaxHTTPBodySize bytes are kept. truncated reports that the body was cut at the limit or that data ends before the body does
+// メッセージのヘッダーより後ろのdataにあるボディを、Transfer-Encoding: chunkedまたはContent-Lengthに従って解析します。
+// どちらも無いレスポンスは接続が閉じるまで続き、リクエストにはボディがありません。
+// 保持するのは最大MaxHTTPBodySizeバイトです。truncatedはボディを上限で切り詰めたこと、またはボディの途中でdataが終わっていることを表します
+func decodeHTTPBody(headers map[string]string, data []byte, response bool) (body []byte, truncated bool) {
+	limit := MaxHTTPBodySize()
+
+	if strings.Contains(strings.ToLower(headers["Transfer-Encoding"]), "chunked") {
+		return decodeChunkedHTTPBody(data, limit)
+	}
+	if v, ok := headers["Content-Length"]; ok {
+		length, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || length < 0 {
+			return nil, false
+		}
+		return contentLengthHTTPBody(data, length, limit)
+	}
+	if !response {
+		return nil, false
+	}
+	if len(data) > limit {
+		return data[:limit], true
+	}
+	return data, false
+}
+
+// contentLengthHTTPBody returns the body of length bytes at the start of data, up to limit bytes.
+// It slices data instead of allocating length bytes, which the sender controls
+// dataの先頭のlengthバイトのボディを、最大limitバイトまで返します。
+// 送信側が決めるlengthバイトを確保せず、dataを切り出します
+func contentLengthHTTPBody(data []byte, length int64, limit int) (body []byte, truncated bool) {
+	n := min(length, int64(len(data)), int64(limit))
+	return data[:n], n < length
+}
+
+// decodeChunkedHTTPBody decodes a chunked body (RFC 9112 7.1) chunk by chunk, stopping once limit bytes are kept.
+// The body is complete only when the last chunk (size 0) is reached. Chunk extensions and trailers are ignored
+// chunkedのボディ(RFC 9112 7.1)をチャンクごとに解析し、limitバイトを保持したところで止めます。
+// 最後のチャンク(サイズ0)に達した場合のみボディは完全です。チャンク拡張とトレーラーは無視します
+func decodeChunkedHTTPBody(data []byte, limit int) (body []byte, truncated bool) {
+	crlf := []byte("\r\n")
+	body = []byte{}
+	for {
+		line, rest, found := bytes.Cut(data, crlf)
+		if !found {
+			// チャンクのサイズ行が後のセグメントに続く
+			return body, true
+		}
+		sizeField, _, _ := bytes.Cut(line, []byte(";"))
+		size, err := strconv.ParseUint(string(bytes.TrimSpace(sizeField)), 16, 63)
+		if err != nil {
+			return body, true
+		}
+		if size == 0 {
+			return body, false
+		}
+
+		chunk := rest
+		if uint64(len(chunk)) > size {
+			chunk = chunk[:size]
+		}
+		if room := limit - len(body); len(chunk) > room {
+			return append(body, chunk[:room]...), true
+		}
+		body = append(body, chunk...)
+		if uint64(len(rest)) < size+uint64(len(crlf)) {
+			// チャンクが後のセグメントに続く
+			return body, true
+		}
+		data = rest[size+uint64(len(crlf)):]
+	}
+}
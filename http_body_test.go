@@ -0,0 +1,50 @@
+package packemon
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestHTTPBodyLimit tests that an oversized Content-Length and an unterminated chunked body keep at most MaxHTTPBodySize bytes and are marked truncated
+// 過大なContent-Lengthと終わらないchunkedのボディが最大MaxHTTPBodySizeバイトだけ保持され、切り詰めたものとして記録されることをテストします
+func TestHTTPBodyLimit(t *testing.T) {
+	SetMaxHTTPBodySize(16)
+	t.Cleanup(func() { SetMaxHTTPBodySize(0) })
+
+	body := strings.Repeat("a", 64)
+
+	// 受信したデータより大きい Content-Length を確保しない
+	res := ParseHTTPResponse([]byte("HTTP/1.1 200 OK\r\nContent-Length: 1099511627776\r\n\r\n" + body))
+	if res == nil || res.StatusCode != 200 || res.Status != "OK" {
+		t.Fatalf("ParseHTTPResponse() = %+v", res)
+	}
+	if string(res.Body) != body[:16] || !res.Truncated {
+		t.Errorf("oversized Content-Length: Body = %q, Truncated = %v, want 16 bytes truncated", res.Body, res.Truncated)
+	}
+
+	// 最後のチャンク(サイズ0)が無いまま続く
+	var chunked bytes.Buffer
+	chunked.WriteString("HTTP/1.1 200 OK\r\ntransfer-encoding: chunked\r\n\r\n")
+	for i := 0; i < 8; i++ {
+		chunked.WriteString("a\r\n0123456789\r\n")
+	}
+	res = ParseHTTPResponse(chunked.Bytes())
+	if res == nil || string(res.Body) != "0123456789012345" || !res.Truncated {
+		t.Errorf("unterminated chunked: %+v, want 16 bytes truncated", res)
+	}
+
+	// 上限に収まるボディは完全に解析される
+	res = ParseHTTPResponse([]byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n4;ext=1\r\nWiki\r\n6\r\npedia \r\n0\r\n\r\n"))
+	if res == nil || string(res.Body) != "Wikipedia " || res.Truncated {
+		t.Errorf("complete chunked: %+v", res)
+	}
+	req := ParseHTTPRequest([]byte("POST /login HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nuser=alice"))
+	if req == nil || req.Method != "POST" || req.URI != "/login" || req.Headers["Host"] != "example.com" || string(req.Body) != "user=" || req.Truncated {
+		t.Errorf("ParseHTTPRequest() = %+v", req)
+	}
+
+	if req := ParseHTTPRequest([]byte{}); req != nil {
+		t.Errorf("empty payload: ParseHTTPRequest() = %+v, want nil", req)
+	}
+}
@@ -19,6 +19,7 @@ type ICMP struct {
 
 const (
 	ICMP_TYPE_REQUEST = 0x08
+	ICMP_TYPE_REPLY   = 0x00
 )
 
 func ParsedICMP(payload []byte) *ICMP {
@@ -37,25 +38,14 @@ func NewICMP() *ICMP {
 	icmp := &ICMP{
 		Typ:        ICMP_TYPE_REQUEST,
 		Code:       0,
-		Identifier: 0x34a1,
+		Identifier: ICMPIdentifiers.Next(),
 		Sequence:   0x0001,
 	}
 
 	// pingのecho requestのpacketを観察すると以下で良さそう
-	timestamp := func() []byte {
-		now := time.Now().Unix()
-		b := make([]byte, 4)
-		binary.LittleEndian.PutUint32(b, uint32(now))
-		return binary.LittleEndian.AppendUint32(b, 0x00000000)
-	}()
+	icmp.Data = EchoTimestamp(time.Now())
 
-	icmp.Data = timestamp
-
-	icmp.Checksum = func() uint16 {
-		b := make([]byte, 2)
-		binary.LittleEndian.PutUint16(b, icmp.CalculateChecksum())
-		return binary.BigEndian.Uint16(b)
-	}()
+	icmp.setChecksum()
 
 	return icmp
 }
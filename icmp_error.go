@@ -0,0 +1,188 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ICMP error message types and codes
+// ref: https://datatracker.ietf.org/doc/html/rfc792
+// ICMPのエラーメッセージタイプとコード
+const (
+	ICMP_TYPE_DESTINATION_UNREACHABLE = 0x03
+	ICMP_TYPE_TIME_EXCEEDED           = 0x0b
+)
+
+const (
+	ICMP_CODE_NET_UNREACHABLE      = 0x00
+	ICMP_CODE_HOST_UNREACHABLE     = 0x01
+	ICMP_CODE_PROTOCOL_UNREACHABLE = 0x02
+	ICMP_CODE_PORT_UNREACHABLE     = 0x03
+	ICMP_CODE_FRAGMENTATION_NEEDED = 0x04
+	ICMP_CODE_ADMIN_PROHIBITED     = 0x0d
+
+	ICMP_CODE_TTL_EXCEEDED        = 0x00
+	ICMP_CODE_REASSEMBLY_EXCEEDED = 0x01
+)
+
+// ICMPv6 Destination Unreachable / Time Exceeded codes
+// ref: https://datatracker.ietf.org/doc/html/rfc4443#section-3.1
+// ICMPv6 の到達不能/時間超過のコード
+const (
+	ICMPv6_CODE_NO_ROUTE            = 0x00
+	ICMPv6_CODE_ADMIN_PROHIBITED    = 0x01
+	ICMPv6_CODE_BEYOND_SCOPE        = 0x02
+	ICMPv6_CODE_ADDRESS_UNREACHABLE = 0x03
+	ICMPv6_CODE_PORT_UNREACHABLE    = 0x04
+
+	ICMPv6_CODE_HOP_LIMIT_EXCEEDED  = 0x00
+	ICMPv6_CODE_REASSEMBLY_EXCEEDED = 0x01
+)
+
+// IPv6 minimum MTU. ICMPv6 error messages must not exceed it
+// IPv6の最小MTU。ICMPv6エラーメッセージはこれを超えてはならない
+const IPv6_MIN_MTU = 1280
+
+// 8 = ICMPv6 header(type/code/checksum) + unused(or MTU/pointer) field
+const icmpv6ErrorMaxQuote = IPv6_MIN_MTU - 40 - 8
+
+// NewICMPDestUnreachable creates an ICMP Destination Unreachable message quoting origPacket (an IPv4 packet)
+// 元のIPv4パケットを引用したICMP到達不能メッセージを作成します
+func NewICMPDestUnreachable(origPacket []byte, code uint8) *ICMP {
+	return newICMPError(ICMP_TYPE_DESTINATION_UNREACHABLE, code, origPacket)
+}
+
+// NewICMPTimeExceeded creates an ICMP Time Exceeded message quoting origPacket (an IPv4 packet)
+// 元のIPv4パケットを引用したICMP時間超過メッセージを作成します
+func NewICMPTimeExceeded(origPacket []byte, code uint8) *ICMP {
+	return newICMPError(ICMP_TYPE_TIME_EXCEEDED, code, origPacket)
+}
+
+func newICMPError(typ uint8, code uint8, origPacket []byte) *ICMP {
+	// Identifier/Sequence の位置は unused として0埋め
+	icmp := &ICMP{
+		Typ:  typ,
+		Code: code,
+		Data: quoteIPv4Packet(origPacket),
+	}
+	icmp.setChecksum()
+	return icmp
+}
+
+// RFC 792: Internet Header + 64 bits of Original Data Datagram
+func quoteIPv4Packet(origPacket []byte) []byte {
+	if len(origPacket) == 0 {
+		return nil
+	}
+
+	headerLength := int(origPacket[0]&0x0f) * 4
+	quoteLength := headerLength + 8
+	if quoteLength > len(origPacket) {
+		quoteLength = len(origPacket)
+	}
+
+	quoted := make([]byte, quoteLength)
+	copy(quoted, origPacket)
+	return quoted
+}
+
+// NewICMPv6DestUnreachable creates an ICMPv6 Destination Unreachable message quoting origPacket (an IPv6 packet).
+// As with NewICMPv6EchoRequest, the checksum is left zero and should be set with CalculateChecksum.
+// 元のIPv6パケットを引用したICMPv6到達不能メッセージを作成します。チェックサムは CalculateChecksum で設定してください
+func NewICMPv6DestUnreachable(origPacket []byte, code uint8) *ICMPv6 {
+	return newICMPv6Error(ICMPv6_TYPE_DESTINATION_UNREACHABLE, code, 0, origPacket)
+}
+
+// NewICMPv6PacketTooBig creates an ICMPv6 Packet Too Big message advertising mtu
+// MTUを通知するICMPv6 Packet Too Bigメッセージを作成します
+func NewICMPv6PacketTooBig(origPacket []byte, mtu uint32) *ICMPv6 {
+	return newICMPv6Error(ICMPv6_TYPE_PACKET_TOO_BIG, 0, mtu, origPacket)
+}
+
+// NewICMPv6TimeExceeded creates an ICMPv6 Time Exceeded message quoting origPacket
+// 元のIPv6パケットを引用したICMPv6時間超過メッセージを作成します
+func NewICMPv6TimeExceeded(origPacket []byte, code uint8) *ICMPv6 {
+	return newICMPv6Error(ICMPv6_TYPE_TIME_EXCEEDED, code, 0, origPacket)
+}
+
+// RFC 4443 2.4 (c): As much of invoking packet as possible without the ICMPv6 packet exceeding the minimum IPv6 MTU
+func newICMPv6Error(typ uint8, code uint8, field uint32, origPacket []byte) *ICMPv6 {
+	quoteLength := len(origPacket)
+	if quoteLength > icmpv6ErrorMaxQuote {
+		quoteLength = icmpv6ErrorMaxQuote
+	}
+
+	body := &bytes.Buffer{}
+	WriteUint32(body, field)
+	body.Write(origPacket[:quoteLength])
+
+	return &ICMPv6{
+		Type:        typ,
+		Code:        code,
+		Checksum:    0,
+		MessageBody: body.Bytes(),
+	}
+}
+
+// setChecksum sets the checksum in network byte order
+// ネットワークバイトオーダーでチェックサムを設定します
+func (i *ICMP) setChecksum() {
+	i.Checksum = 0
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, i.CalculateChecksum())
+	i.Checksum = binary.BigEndian.Uint16(b)
+}
+
+// ICMPv6Error is the body of an ICMPv6 error message (RFC 4443 section 3): a 32-bit field and as much of the invoking packet as fit
+// ICMPv6エラーメッセージ(RFC 4443 3章)の本体です。32ビットのフィールドと、収まる限りの元のパケットからなります
+type ICMPv6Error struct {
+	// Field is unused (zero) in Destination Unreachable and Time Exceeded, the MTU in Packet Too Big and the pointer in Parameter Problem
+	// 到達不能と時間超過では未使用(0)、Packet Too BigではMTU、Parameter Problemではポインタ
+	Field uint32
+	// Invoking is the quoted invoking packet, possibly cut short
+	// 引用された元のパケット(途中までの場合がある)
+	Invoking []byte
+	// InvokingIPv6 is the invoking packet's IPv6 header, nil if less than a header was quoted. Its Payload is the quoted upper layer
+	// 元のパケットのIPv6ヘッダー。ヘッダー分も引用されていなければnil。Payloadは引用された上位レイヤ
+	InvokingIPv6 *IPv6Packet
+}
+
+// ParsedICMPv6Error parses the body of an ICMPv6 error message. It returns nil for informational messages and bodies shorter than the field
+// ICMPv6エラーメッセージの本体を解析します。情報メッセージやフィールドより短い本体の場合はnilを返します
+func ParsedICMPv6Error(icmpv6 *ICMPv6) *ICMPv6Error {
+	// タイプの最上位ビットが 0 のものがエラーメッセージ
+	if icmpv6 == nil || icmpv6.Type >= 128 || len(icmpv6.MessageBody) < 4 {
+		return nil
+	}
+	invoking := icmpv6.MessageBody[4:]
+	return &ICMPv6Error{
+		Field:        binary.BigEndian.Uint32(icmpv6.MessageBody[0:4]),
+		Invoking:     invoking,
+		InvokingIPv6: ParseIPv6Packet(invoking),
+	}
+}
+
+// ICMPv6PacketTooBig is a Packet Too Big message (RFC 4443 section 3.2), sent by a router whose next link's MTU is smaller than a packet.
+// Path MTU Discovery lowers the path MTU to MTU; a sender that never sees these messages, e.g. behind a firewall dropping them, ends up in a PMTUD black hole.
+// Packet Too Bigメッセージ(RFC 4443 3.2章)です。次のリンクのMTUよりパケットが大きい場合にルーターが送信します。
+// Path MTU DiscoveryはパスMTUをMTUに下げます。ファイアウォールが落とすなどしてこのメッセージが届かないと、PMTUDブラックホールになります
+type ICMPv6PacketTooBig struct {
+	MTU uint32
+	ICMPv6Error
+}
+
+// ParsedICMPv6PacketTooBig parses a Packet Too Big message. It returns nil for other types
+// Packet Too Bigメッセージを解析します。その他のタイプの場合はnilを返します
+func ParsedICMPv6PacketTooBig(icmpv6 *ICMPv6) *ICMPv6PacketTooBig {
+	if icmpv6 == nil || icmpv6.Type != ICMPv6_TYPE_PACKET_TOO_BIG {
+		return nil
+	}
+	icmpv6Error := ParsedICMPv6Error(icmpv6)
+	if icmpv6Error == nil {
+		return nil
+	}
+	return &ICMPv6PacketTooBig{
+		MTU:         icmpv6Error.Field,
+		ICMPv6Error: *icmpv6Error,
+	}
+}
@@ -0,0 +1,147 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// TestNewICMPDestUnreachable tests that the IPv4 header and the first 8 bytes are quoted
+// IPv4ヘッダーと先頭8バイトが引用されることをテストします
+func TestNewICMPDestUnreachable(t *testing.T) {
+	ipv4 := NewIPv4(IPv4_PROTO_UDP, 0xc0a80a6e, 0xc0a80a01)
+	udp := &UDP{
+		SrcPort: 0xd4c0,
+		DstPort: PORT_DNS,
+		Data:    bytes.Repeat([]byte{0xaa}, 32),
+	}
+	udp.Len()
+	ipv4.Data = udp.Bytes()
+	ipv4.CalculateTotalLength()
+	origPacket := ipv4.Bytes()
+
+	icmp := NewICMPDestUnreachable(origPacket, ICMP_CODE_PORT_UNREACHABLE)
+
+	if icmp.Typ != ICMP_TYPE_DESTINATION_UNREACHABLE || icmp.Code != ICMP_CODE_PORT_UNREACHABLE {
+		t.Errorf("type/code = %d/%d, want %d/%d", icmp.Typ, icmp.Code, ICMP_TYPE_DESTINATION_UNREACHABLE, ICMP_CODE_PORT_UNREACHABLE)
+	}
+	if len(icmp.Data) != 20+8 {
+		t.Fatalf("quoted length = %d, want %d", len(icmp.Data), 20+8)
+	}
+	if !bytes.Equal(icmp.Data, origPacket[:28]) {
+		t.Errorf("quoted bytes = %x, want %x", icmp.Data, origPacket[:28])
+	}
+	if icmp.Identifier != 0 || icmp.Sequence != 0 {
+		t.Errorf("unused field should be zero, got %x %x", icmp.Identifier, icmp.Sequence)
+	}
+	if got := calculateInternetChecksum(icmp.Bytes()); got != 0 {
+		t.Errorf("checksum verification = %x, want 0", got)
+	}
+}
+
+// TestNewICMPTimeExceededWithOptions tests quoting when the IPv4 header has options
+// IPv4ヘッダーにオプションがある場合の引用をテストします
+func TestNewICMPTimeExceededWithOptions(t *testing.T) {
+	origPacket := make([]byte, 24+16)
+	origPacket[0] = 0x46 // version 4, IHL 6 (24 bytes)
+	for i := range origPacket[1:] {
+		origPacket[i+1] = byte(i)
+	}
+
+	icmp := NewICMPTimeExceeded(origPacket, ICMP_CODE_TTL_EXCEEDED)
+	if !bytes.Equal(icmp.Data, origPacket[:24+8]) {
+		t.Errorf("quoted bytes = %x, want %x", icmp.Data, origPacket[:24+8])
+	}
+
+	short := origPacket[:26]
+	icmp = NewICMPTimeExceeded(short, ICMP_CODE_TTL_EXCEEDED)
+	if !bytes.Equal(icmp.Data, short) {
+		t.Errorf("short packet should be quoted entirely, got %x", icmp.Data)
+	}
+}
+
+// TestNewICMPv6DestUnreachable tests quoting and the minimum MTU limit of ICMPv6 errors
+// ICMPv6エラーの引用と最小MTUの制限をテストします
+func TestNewICMPv6DestUnreachable(t *testing.T) {
+	src := net.ParseIP("2001:db8::1")
+	dst := net.ParseIP("2001:db8::2")
+
+	small := NewIPv6(IPv6_NEXT_HEADER_UDP, src, dst)
+	small.Data = []byte{0x01, 0x02, 0x03, 0x04}
+	origPacket := small.Bytes()
+
+	icmpv6 := NewICMPv6DestUnreachable(origPacket, ICMPv6_CODE_PORT_UNREACHABLE)
+	if !bytes.Equal(icmpv6.MessageBody[:4], []byte{0, 0, 0, 0}) {
+		t.Errorf("unused field = %x, want 00000000", icmpv6.MessageBody[:4])
+	}
+	if !bytes.Equal(icmpv6.MessageBody[4:], origPacket) {
+		t.Errorf("quoted bytes = %x, want %x", icmpv6.MessageBody[4:], origPacket)
+	}
+
+	large := NewIPv6(IPv6_NEXT_HEADER_UDP, src, dst)
+	large.Data = bytes.Repeat([]byte{0xbb}, 1500)
+	icmpv6 = NewICMPv6DestUnreachable(large.Bytes(), ICMPv6_CODE_PORT_UNREACHABLE)
+	if got := 40 + len(icmpv6.Bytes()); got != IPv6_MIN_MTU {
+		t.Errorf("IPv6 packet length = %d, want %d", got, IPv6_MIN_MTU)
+	}
+
+	icmpv6.Checksum = icmpv6.CalculateChecksum(dst, src)
+	verify := calculateInternetChecksum(append(BuildIPv6PseudoHeader(dst, src, uint32(len(icmpv6.Bytes())), IPv6_NEXT_HEADER_ICMPv6), icmpv6.Bytes()...))
+	if verify != 0 {
+		t.Errorf("checksum verification = %x, want 0", verify)
+	}
+}
+
+// TestNewICMPv6PacketTooBig tests the MTU field of Packet Too Big
+// Packet Too BigのMTUフィールドをテストします
+func TestNewICMPv6PacketTooBig(t *testing.T) {
+	icmpv6 := NewICMPv6PacketTooBig([]byte{0x60, 0x00, 0x00, 0x00}, 1400)
+	if icmpv6.Type != ICMPv6_TYPE_PACKET_TOO_BIG {
+		t.Errorf("type = %d, want %d", icmpv6.Type, ICMPv6_TYPE_PACKET_TOO_BIG)
+	}
+	if !bytes.Equal(icmpv6.MessageBody[:4], []byte{0x00, 0x00, 0x05, 0x78}) {
+		t.Errorf("mtu field = %x, want 00000578", icmpv6.MessageBody[:4])
+	}
+}
+
+// TestParsedICMPv6PacketTooBig tests that the MTU and the quoted header of the invoking packet are parsed from a Packet Too Big message
+// Packet Too BigメッセージからMTUと引用された元のパケットのヘッダーが解析されることをテストします
+func TestParsedICMPv6PacketTooBig(t *testing.T) {
+	src := net.ParseIP("2001:db8::1")
+	dst := net.ParseIP("2001:db8::2")
+	orig := NewIPv6(IPv6_NEXT_HEADER_UDP, src, dst)
+	orig.Data = bytes.Repeat([]byte{0xaa}, 1452)
+	orig.PayloadLength = uint16(len(orig.Data))
+
+	icmpv6 := ParsedICMPv6(NewICMPv6PacketTooBig(orig.Bytes(), 1400).Bytes())
+	tooBig := ParsedICMPv6PacketTooBig(icmpv6)
+	if tooBig == nil {
+		t.Fatal("ParsedICMPv6PacketTooBig() = nil")
+	}
+	if tooBig.MTU != 1400 {
+		t.Errorf("MTU = %d, want 1400", tooBig.MTU)
+	}
+	invoking := tooBig.InvokingIPv6
+	if invoking == nil {
+		t.Fatal("the invoking packet's IPv6 header should be parsed")
+	}
+	if !net.IP(invoking.SrcIP).Equal(src) || !net.IP(invoking.DstIP).Equal(dst) || invoking.NextHeader != IPv6_NEXT_HEADER_UDP {
+		t.Errorf("invoking header = %+v, want UDP from %s to %s", invoking, src, dst)
+	}
+	// 最小 MTU に収まるよう切り詰めて引用される
+	if len(tooBig.Invoking) != icmpv6ErrorMaxQuote || int(invoking.PayloadLen) != len(orig.Data) {
+		t.Errorf("quoted %d bytes with payload length %d, want %d bytes of a %d byte payload", len(tooBig.Invoking), invoking.PayloadLen, icmpv6ErrorMaxQuote, len(orig.Data))
+	}
+
+	// 他のエラーは ParsedICMPv6Error でのみ、情報メッセージはどちらでも解析しない
+	unreachable := ParsedICMPv6(NewICMPv6DestUnreachable(orig.Bytes()[:40], ICMPv6_CODE_PORT_UNREACHABLE).Bytes())
+	if ParsedICMPv6PacketTooBig(unreachable) != nil {
+		t.Error("Destination Unreachable should not be parsed as Packet Too Big")
+	}
+	if e := ParsedICMPv6Error(unreachable); e == nil || e.Field != 0 || e.InvokingIPv6 == nil || len(e.InvokingIPv6.Payload) != 0 {
+		t.Errorf("ParsedICMPv6Error(Destination Unreachable) = %+v", e)
+	}
+	if ParsedICMPv6Error(NewICMPv6EchoRequest()) != nil {
+		t.Error("Echo Request is not an error message")
+	}
+}
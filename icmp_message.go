@@ -0,0 +1,95 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// ICMP message types whose body is not laid out like an echo message
+// エコーメッセージとは本体の形式が異なるICMPメッセージタイプ
+const (
+	ICMP_TYPE_REDIRECT             = 0x05 // https://datatracker.ietf.org/doc/html/rfc792
+	ICMP_TYPE_ROUTER_ADVERTISEMENT = 0x09 // https://datatracker.ietf.org/doc/html/rfc1256
+)
+
+// Redirect codes
+// リダイレクトのコード
+const (
+	ICMP_CODE_REDIRECT_NETWORK     = 0x00
+	ICMP_CODE_REDIRECT_HOST        = 0x01
+	ICMP_CODE_REDIRECT_TOS_NETWORK = 0x02
+	ICMP_CODE_REDIRECT_TOS_HOST    = 0x03
+)
+
+// ICMPRedirect is the body of a Redirect message (RFC 792): the gateway to send the quoted packet's destination to instead
+// Redirectメッセージ(RFC 792)の本体です。引用されたパケットの宛先への通信を今後送るべきゲートウェイを示します
+type ICMPRedirect struct {
+	Gateway net.IP
+	// Original is the quoted IP header and the first 64 bits of the original datagram
+	// 引用された元のデータグラムのIPヘッダーと先頭64ビット
+	Original []byte
+	// OriginalIPv4 is the quoted IPv4 header, nil if less than a header was quoted. Its Payload is the quoted data
+	// 引用されたIPv4ヘッダー。ヘッダー分も引用されていなければnil。Payloadは引用されたデータ
+	OriginalIPv4 *IPv4Packet
+}
+
+// ICMPRouterAdvertisement is the body of a Router Advertisement message (RFC 1256)
+// Router Advertisementメッセージ(RFC 1256)の本体です
+type ICMPRouterAdvertisement struct {
+	// Lifetime is how many seconds the addresses may be considered valid
+	// アドレスを有効とみなしてよい秒数
+	Lifetime uint16
+	Entries  []ICMPRouterEntry
+}
+
+// ICMPRouterEntry is a router address and its preference as a default router. Higher is preferred
+// ルーターのアドレスと、デフォルトルーターとしての優先度です。大きいほど優先されます
+type ICMPRouterEntry struct {
+	Address    net.IP
+	Preference int32
+}
+
+// parseICMPMessage parses the body of the message types with their own layout into icmp
+// 独自の形式を持つメッセージタイプの本体を解析してicmpに設定します
+func parseICMPMessage(icmp *ICMPPacket, data []byte) {
+	switch icmp.Type {
+	case ICMP_TYPE_REDIRECT:
+		icmp.Redirect = parseICMPRedirect(data)
+	case ICMP_TYPE_ROUTER_ADVERTISEMENT:
+		icmp.RouterAdvertisement = parseICMPRouterAdvertisement(data)
+	}
+}
+
+func parseICMPRedirect(data []byte) *ICMPRedirect {
+	if len(data) < 8 {
+		return nil
+	}
+	original := data[8:]
+	return &ICMPRedirect{
+		Gateway:      net.IP(data[4:8]),
+		Original:     original,
+		OriginalIPv4: ParseIPv4Packet(original),
+	}
+}
+
+func parseICMPRouterAdvertisement(data []byte) *ICMPRouterAdvertisement {
+	if len(data) < 8 {
+		return nil
+	}
+	numAddrs, entrySize := int(data[4]), int(data[5])*4
+	// 各エントリはアドレスと優先度の 8 バイト以上. 将来の拡張分は読み飛ばす
+	if entrySize < 8 {
+		return nil
+	}
+	ra := &ICMPRouterAdvertisement{
+		Lifetime: binary.BigEndian.Uint16(data[6:8]),
+		Entries:  make([]ICMPRouterEntry, 0, numAddrs),
+	}
+	for offset := 8; len(ra.Entries) < numAddrs && offset+8 <= len(data); offset += entrySize {
+		ra.Entries = append(ra.Entries, ICMPRouterEntry{
+			Address:    net.IP(data[offset : offset+4]),
+			Preference: int32(binary.BigEndian.Uint32(data[offset+4 : offset+8])),
+		})
+	}
+	return ra
+}
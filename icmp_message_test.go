@@ -0,0 +1,80 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// TestParseICMPRedirect tests that the gateway and the quoted packet are parsed from a host Redirect message
+// ホストのRedirectメッセージからゲートウェイと引用されたパケットが解析されることをテストします
+func TestParseICMPRedirect(t *testing.T) {
+	// 10.0.0.5 から 192.0.2.80 への UDP パケットのヘッダーと先頭 8 バイト
+	original := []byte{
+		0x45, 0x00, 0x00, 0x40, 0x12, 0x34, 0x00, 0x00, 0x40, IPv4_PROTO_UDP, 0x00, 0x00,
+		10, 0, 0, 5, 192, 0, 2, 80,
+		0xd4, 0x31, 0x00, 0x35, 0x00, 0x2c, 0x00, 0x00,
+	}
+	icmp := append([]byte{ICMP_TYPE_REDIRECT, ICMP_CODE_REDIRECT_HOST, 0x00, 0x00, 10, 0, 0, 254}, original...)
+
+	passive, err := DecodeFrame(ipTunnelTestFrame(IPv4_PROTO_ICMP, icmp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.ICMP == nil {
+		t.Fatal("ICMP = nil")
+	}
+	redirect := passive.ICMP.Redirect
+	if redirect == nil {
+		t.Fatal("Redirect = nil")
+	}
+	if !redirect.Gateway.Equal(net.IPv4(10, 0, 0, 254)) {
+		t.Errorf("Gateway = %s, want 10.0.0.254", redirect.Gateway)
+	}
+	if !bytes.Equal(redirect.Original, original) {
+		t.Errorf("Original = %x, want %x", redirect.Original, original)
+	}
+	quoted := redirect.OriginalIPv4
+	if quoted == nil || !net.IP(quoted.DstIP).Equal(net.IPv4(192, 0, 2, 80)) || quoted.Protocol != IPv4_PROTO_UDP || len(quoted.Payload) != 8 {
+		t.Errorf("OriginalIPv4 = %+v, want the UDP packet to 192.0.2.80 with 8 bytes of data", quoted)
+	}
+	if passive.ICMP.RouterAdvertisement != nil {
+		t.Error("a Redirect should not be parsed as a Router Advertisement")
+	}
+
+	// ゲートウェイまでしかない場合は引用なし
+	if redirect := ParseICMPPacket(icmp[:8]).Redirect; redirect == nil || redirect.OriginalIPv4 != nil {
+		t.Errorf("Redirect without the quoted packet = %+v", redirect)
+	}
+	if ParseICMPPacket([]byte{ICMP_TYPE_REQUEST, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x01}).Redirect != nil {
+		t.Error("an Echo Request should not be parsed as a Redirect")
+	}
+}
+
+// TestParseICMPRouterAdvertisement tests the router entries of a Router Advertisement, including a truncated one
+// 途中で切れたものを含め、Router Advertisementのルーターのエントリをテストします
+func TestParseICMPRouterAdvertisement(t *testing.T) {
+	icmp := []byte{
+		ICMP_TYPE_ROUTER_ADVERTISEMENT, 0x00, 0x00, 0x00,
+		2, 2, 0x07, 0x08, // 2 エントリ, 2 ワードずつ, 1800 秒
+		192, 168, 0, 1, 0x00, 0x00, 0x00, 0x0a,
+		192, 168, 0, 2, 0xff, 0xff, 0xff, 0xff,
+	}
+	ra := ParseICMPPacket(icmp).RouterAdvertisement
+	if ra == nil {
+		t.Fatal("RouterAdvertisement = nil")
+	}
+	if ra.Lifetime != 1800 || len(ra.Entries) != 2 {
+		t.Fatalf("RouterAdvertisement = %+v, want 2 entries valid for 1800s", ra)
+	}
+	if e := ra.Entries[0]; !e.Address.Equal(net.IPv4(192, 168, 0, 1)) || e.Preference != 10 {
+		t.Errorf("Entries[0] = %+v", e)
+	}
+	if e := ra.Entries[1]; !e.Address.Equal(net.IPv4(192, 168, 0, 2)) || e.Preference != -1 {
+		t.Errorf("Entries[1] = %+v", e)
+	}
+
+	if ra := ParseICMPPacket(icmp[:20]).RouterAdvertisement; ra == nil || len(ra.Entries) != 1 {
+		t.Errorf("truncated RouterAdvertisement = %+v, want the complete entry only", ra)
+	}
+}
@@ -73,18 +73,11 @@ func ParsedICMPv6Echo(icmpv6 *ICMPv6) *ICMPv6Echo {
 // NewICMPv6EchoRequest creates a new ICMPv6 Echo Request packet
 func NewICMPv6EchoRequest() *ICMPv6 {
 	// Create echo data with timestamp similar to ping
-	timestamp := func() []byte {
-		now := time.Now().Unix()
-		b := make([]byte, 4)
-		binary.LittleEndian.PutUint32(b, uint32(now))
-		return binary.LittleEndian.AppendUint32(b, 0x00000000)
-	}()
-	
 	// Create echo body
 	echo := &ICMPv6Echo{
-		Identifier:    0x1234, // Arbitrary identifier
+		Identifier:    ICMPIdentifiers.Next(),
 		SequenceNumber: 0x0001,
-		Data:          timestamp,
+		Data:          EchoTimestamp(time.Now()),
 	}
 	
 	// Convert echo to bytes
@@ -121,27 +114,9 @@ func (i *ICMPv6) CalculateChecksum(srcIP, dstIP net.IP) uint16 {
 	// Prepare the ICMPv6 data with zero checksum
 	icmpData := i.bytesWithZeroChecksum()
 	
-	// Create the pseudo-header
-	pseudoHeader := &bytes.Buffer{}
-	
-	// Source IP (16 bytes for IPv6)
-	pseudoHeader.Write(srcIP.To16())
-	
-	// Destination IP (16 bytes for IPv6)
-	pseudoHeader.Write(dstIP.To16())
-	
-	// Upper-layer packet length (32 bits)
-	var packetLength uint32 = uint32(len(icmpData))
-	binary.Write(pseudoHeader, binary.BigEndian, packetLength)
-	
-	// Zero padding (24 bits)
-	pseudoHeader.Write([]byte{0, 0, 0})
-	
-	// Next header (8 bits) - 58 for ICMPv6
-	pseudoHeader.WriteByte(IPv6_NEXT_HEADER_ICMPv6)
-	
 	// Combine pseudo-header and ICMPv6 data
-	checksumData := append(pseudoHeader.Bytes(), icmpData...)
+	pseudoHeader := BuildIPv6PseudoHeader(srcIP, dstIP, uint32(len(icmpData)), IPv6_NEXT_HEADER_ICMPv6)
+	checksumData := append(pseudoHeader, icmpData...)
 	
 	// Calculate checksum
 	return calculateInternetChecksum(checksumData)
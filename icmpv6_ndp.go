@@ -0,0 +1,230 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Neighbor Discovery option types
+// ref: https://datatracker.ietf.org/doc/html/rfc4861#section-4.6
+// 近隣探索のオプションタイプ
+const (
+	NDP_OPTION_SOURCE_LINK_LAYER_ADDRESS = 1
+	NDP_OPTION_TARGET_LINK_LAYER_ADDRESS = 2
+	NDP_OPTION_PREFIX_INFORMATION        = 3
+	NDP_OPTION_REDIRECTED_HEADER         = 4
+	NDP_OPTION_MTU                       = 5
+)
+
+// Router Advertisement flags
+// Router Advertisementのフラグ
+const (
+	ICMPv6_RA_FLAG_MANAGED = 0x80 // M: アドレスは DHCPv6 で取得
+	ICMPv6_RA_FLAG_OTHER   = 0x40 // O: アドレス以外の情報は DHCPv6 で取得
+)
+
+// Prefix Information option flags
+// Prefix Informationオプションのフラグ
+const (
+	NDP_PREFIX_FLAG_ON_LINK    = 0x80 // L
+	NDP_PREFIX_FLAG_AUTONOMOUS = 0x40 // A: SLAAC に使ってよい
+)
+
+// NDPOption is a Neighbor Discovery option. Data excludes the type and length fields.
+// NDPOptionは近隣探索のオプションです。Dataにはタイプと長さのフィールドを含みません
+type NDPOption struct {
+	Type uint8
+	Data []byte
+}
+
+// Bytes serializes the option, padding it to a multiple of 8 octets
+// オプションを8オクテットの倍数にパディングしてシリアライズします
+func (o NDPOption) Bytes() []byte {
+	// Length は type と length を含む 8 オクテット単位
+	length := (2 + len(o.Data) + 7) / 8
+	b := make([]byte, length*8)
+	b[0] = o.Type
+	b[1] = uint8(length)
+	copy(b[2:], o.Data)
+	return b
+}
+
+// NewNDPSourceLinkLayerAddressOption creates a Source Link-Layer Address option
+// Source Link-Layer Addressオプションを作成します
+func NewNDPSourceLinkLayerAddressOption(mac net.HardwareAddr) NDPOption {
+	return NDPOption{
+		Type: NDP_OPTION_SOURCE_LINK_LAYER_ADDRESS,
+		Data: mac,
+	}
+}
+
+// NewNDPMTUOption creates an MTU option
+// MTUオプションを作成します
+func NewNDPMTUOption(mtu uint32) NDPOption {
+	data := make([]byte, 6) // reserved(2) + MTU(4)
+	binary.BigEndian.PutUint32(data[2:6], mtu)
+	return NDPOption{
+		Type: NDP_OPTION_MTU,
+		Data: data,
+	}
+}
+
+// NewNDPPrefixInformationOption creates a Prefix Information option.
+// Lifetimes are in seconds, 0xffffffff means infinity.
+// Prefix Informationオプションを作成します。ライフタイムは秒で、0xffffffffは無限を表します
+func NewNDPPrefixInformationOption(prefix net.IP, prefixLength uint8, flags uint8, validLifetime uint32, preferredLifetime uint32) NDPOption {
+	data := make([]byte, 30)
+	data[0] = prefixLength
+	data[1] = flags
+	binary.BigEndian.PutUint32(data[2:6], validLifetime)
+	binary.BigEndian.PutUint32(data[6:10], preferredLifetime)
+	// data[10:14] は Reserved2
+	copy(data[14:30], prefix.To16())
+	return NDPOption{
+		Type: NDP_OPTION_PREFIX_INFORMATION,
+		Data: data,
+	}
+}
+
+// NewICMPv6RouterAdvertisement creates a Router Advertisement with the given options.
+// reachableTime and retransTimer are in milliseconds. As with NewICMPv6EchoRequest, the checksum is left zero.
+// Router Advertisementを作成します。チェックサムは CalculateChecksum で設定してください
+func NewICMPv6RouterAdvertisement(curHopLimit uint8, flags uint8, routerLifetime uint16, reachableTime uint32, retransTimer uint32, opts []NDPOption) (*ICMPv6, error) {
+	for _, opt := range opts {
+		if err := validateNDPOption(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc4861#section-4.2
+	body := &bytes.Buffer{}
+	body.WriteByte(curHopLimit)
+	body.WriteByte(flags)
+	WriteUint16(body, routerLifetime)
+	WriteUint32(body, reachableTime)
+	WriteUint32(body, retransTimer)
+	for _, opt := range opts {
+		body.Write(opt.Bytes())
+	}
+
+	return &ICMPv6{
+		Type:        ICMPv6_TYPE_ROUTER_ADVERTISEMENT,
+		Code:        0,
+		Checksum:    0,
+		MessageBody: body.Bytes(),
+	}, nil
+}
+
+// ICMPv6Redirect is a Redirect message (RFC 4861 section 4.5), sent by a router to tell a host of a better first hop for Destination.
+// Target is that first hop, or Destination itself when the destination is on-link
+// Redirectメッセージ(RFC 4861 4.5章)です。Destinationへのより良い最初の転送先をルーターがホストに通知します。
+// Targetがその転送先で、宛先がリンク上にある場合はDestination自身です
+type ICMPv6Redirect struct {
+	Target      net.IP
+	Destination net.IP
+	// TargetLinkLayerAddress is the MAC address of Target from the Target Link-Layer Address option, nil without it
+	// Target Link-Layer AddressオプションのTargetのMACアドレス。オプションが無い場合はnil
+	TargetLinkLayerAddress net.HardwareAddr
+	// RedirectedHeader is the quoted start of the redirected packet from the Redirected Header option, nil without it.
+	// RedirectedIPv6 is its IPv6 header, nil if less than a header was quoted
+	// Redirected Headerオプションで引用された、リダイレクトされたパケットの先頭。オプションが無い場合はnil。
+	// RedirectedIPv6はそのIPv6ヘッダーで、ヘッダー分も引用されていなければnil
+	RedirectedHeader []byte
+	RedirectedIPv6   *IPv6Packet
+	// Options are all the options, including unknown ones
+	// 未知のものを含むすべてのオプション
+	Options []NDPOption
+}
+
+// OnLink reports whether the destination is on-link, i.e. the host should send to it directly
+// 宛先がリンク上にある、つまりホストが直接送信すべきかどうかを返します
+func (r *ICMPv6Redirect) OnLink() bool {
+	return r.Target.Equal(r.Destination)
+}
+
+// ParsedICMPv6Redirect parses a Redirect message. It returns nil for other types. Options after a malformed one are ignored
+// Redirectメッセージを解析します。その他のタイプの場合はnilを返します。不正なオプション以降のオプションは無視します
+func ParsedICMPv6Redirect(icmpv6 *ICMPv6) *ICMPv6Redirect {
+	// Reserved 4 バイト、Target Address 16 バイト、Destination Address 16 バイトの後にオプション
+	if icmpv6 == nil || icmpv6.Type != ICMPv6_TYPE_REDIRECT || len(icmpv6.MessageBody) < 36 {
+		return nil
+	}
+	body := icmpv6.MessageBody
+	redirect := &ICMPv6Redirect{
+		Target:      net.IP(body[4:20]),
+		Destination: net.IP(body[20:36]),
+	}
+	redirect.Options, _ = parseNDPOptions(body[36:])
+	for _, opt := range redirect.Options {
+		switch {
+		case opt.Type == NDP_OPTION_TARGET_LINK_LAYER_ADDRESS && redirect.TargetLinkLayerAddress == nil && len(opt.Data) >= 6:
+			redirect.TargetLinkLayerAddress = net.HardwareAddr(opt.Data[:6])
+		case opt.Type == NDP_OPTION_REDIRECTED_HEADER && redirect.RedirectedHeader == nil && len(opt.Data) >= 6:
+			// Reserved 6 バイトの後に引用されたパケット
+			redirect.RedirectedHeader = opt.Data[6:]
+			redirect.RedirectedIPv6 = ParseIPv6Packet(redirect.RedirectedHeader)
+		}
+	}
+	return redirect
+}
+
+// parseNDPOptions walks the Neighbor Discovery options. Data of each option includes its padding.
+// On a malformed option, it returns the options before it with an error
+// 近隣探索のオプションを順に解析します。各オプションのDataはパディングを含みます。
+// 不正なオプションがあった場合は、それより前のオプションとエラーを返します
+func parseNDPOptions(options []byte) ([]NDPOption, error) {
+	opts := []NDPOption{}
+	for len(options) > 0 {
+		if len(options) < 8 {
+			return opts, fmt.Errorf("truncated NDP option: %d bytes", len(options))
+		}
+		// Length は type と length を含む 8 オクテット単位
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			return opts, fmt.Errorf("invalid NDP option length: %d", options[1])
+		}
+		opts = append(opts, NDPOption{Type: options[0], Data: options[2:length]})
+		options = options[length:]
+	}
+	return opts, nil
+}
+
+func validateNDPOption(opt NDPOption) error {
+	switch opt.Type {
+	case NDP_OPTION_SOURCE_LINK_LAYER_ADDRESS, NDP_OPTION_TARGET_LINK_LAYER_ADDRESS:
+		if len(opt.Data) == 0 {
+			return errors.New("link-layer address option is empty")
+		}
+	case NDP_OPTION_MTU:
+		if len(opt.Data) != 6 {
+			return errors.New("invalid MTU option")
+		}
+		if mtu := binary.BigEndian.Uint32(opt.Data[2:6]); mtu < IPv6_MIN_MTU {
+			return fmt.Errorf("MTU %d is smaller than the IPv6 minimum MTU", mtu)
+		}
+	case NDP_OPTION_PREFIX_INFORMATION:
+		if len(opt.Data) != 30 {
+			return errors.New("invalid prefix information option")
+		}
+		prefixLength := int(opt.Data[0])
+		if prefixLength > 128 {
+			return fmt.Errorf("invalid prefix length: %d", prefixLength)
+		}
+		// プレフィックス長より後ろのビットは0でなければならない
+		prefix := net.IP(opt.Data[14:30])
+		if !prefix.Mask(net.CIDRMask(prefixLength, 128)).Equal(prefix) {
+			return fmt.Errorf("prefix %s has bits set beyond /%d", prefix, prefixLength)
+		}
+		// SLAAC は /64 でないとインターフェースIDを作れない (RFC 4862 5.5.3 d)
+		if opt.Data[1]&NDP_PREFIX_FLAG_AUTONOMOUS != 0 && prefixLength != 64 {
+			return fmt.Errorf("autonomous prefix must be /64, got /%d", prefixLength)
+		}
+		if binary.BigEndian.Uint32(opt.Data[6:10]) > binary.BigEndian.Uint32(opt.Data[2:6]) {
+			return errors.New("preferred lifetime must not exceed valid lifetime")
+		}
+	}
+	return nil
+}
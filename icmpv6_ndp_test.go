@@ -0,0 +1,145 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// TestNewICMPv6RouterAdvertisement tests the RA header fields and option encoding
+// RAのヘッダーフィールドとオプションのエンコードをテストします
+func TestNewICMPv6RouterAdvertisement(t *testing.T) {
+	mac, _ := net.ParseMAC("00:15:5d:fb:bf:3a")
+	opts := []NDPOption{
+		NewNDPSourceLinkLayerAddressOption(mac),
+		NewNDPMTUOption(1500),
+		NewNDPPrefixInformationOption(net.ParseIP("2001:db8:1::"), 64, NDP_PREFIX_FLAG_ON_LINK|NDP_PREFIX_FLAG_AUTONOMOUS, 86400, 14400),
+	}
+	ra, err := NewICMPv6RouterAdvertisement(64, ICMPv6_RA_FLAG_OTHER, 1800, 30000, 1000, opts)
+	if err != nil {
+		t.Fatalf("NewICMPv6RouterAdvertisement returned error: %v", err)
+	}
+
+	if ra.Type != ICMPv6_TYPE_ROUTER_ADVERTISEMENT || ra.Code != 0 {
+		t.Errorf("Type/Code = %d/%d, want %d/0", ra.Type, ra.Code, ICMPv6_TYPE_ROUTER_ADVERTISEMENT)
+	}
+	body := ra.MessageBody
+	// 12 (RA header) + 8 (SLLA) + 8 (MTU) + 32 (Prefix Information)
+	if len(body) != 60 {
+		t.Fatalf("len(MessageBody) = %d, want 60", len(body))
+	}
+	if body[0] != 64 || body[1] != ICMPv6_RA_FLAG_OTHER || binary.BigEndian.Uint16(body[2:4]) != 1800 ||
+		binary.BigEndian.Uint32(body[4:8]) != 30000 || binary.BigEndian.Uint32(body[8:12]) != 1000 {
+		t.Errorf("RA header = %x", body[:12])
+	}
+
+	wantSLLA := []byte{0x01, 0x01, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}
+	if !bytes.Equal(body[12:20], wantSLLA) {
+		t.Errorf("Source Link-Layer Address option = %x, want %x", body[12:20], wantSLLA)
+	}
+	wantMTU := []byte{0x05, 0x01, 0x00, 0x00, 0x00, 0x00, 0x05, 0xdc}
+	if !bytes.Equal(body[20:28], wantMTU) {
+		t.Errorf("MTU option = %x, want %x", body[20:28], wantMTU)
+	}
+	wantPrefix := []byte{
+		0x03, 0x04, 0x40, 0xc0,
+		0x00, 0x01, 0x51, 0x80, // valid lifetime 86400
+		0x00, 0x00, 0x38, 0x40, // preferred lifetime 14400
+		0x00, 0x00, 0x00, 0x00, // reserved
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if !bytes.Equal(body[28:60], wantPrefix) {
+		t.Errorf("Prefix Information option = %x, want %x", body[28:60], wantPrefix)
+	}
+
+	// チェックサムを計算すれば検証が通ること
+	src, dst := net.ParseIP("fe80::215:5dff:fefb:bf3a"), net.ParseIP("ff02::1")
+	ra.Checksum = ra.CalculateChecksum(src, dst)
+	if got := calculateInternetChecksum(append(BuildIPv6PseudoHeader(src, dst, uint32(len(ra.Bytes())), IPv6_NEXT_HEADER_ICMPv6), ra.Bytes()...)); got != 0 {
+		t.Errorf("checksum verification = 0x%04x, want 0", got)
+	}
+}
+
+// TestNewICMPv6RouterAdvertisementInvalidPrefix tests that invalid prefix options are rejected
+// 不正なプレフィックスオプションがエラーになることをテストします
+func TestNewICMPv6RouterAdvertisementInvalidPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  NDPOption
+	}{
+		{"prefix length over 128", NewNDPPrefixInformationOption(net.ParseIP("2001:db8::"), 129, NDP_PREFIX_FLAG_ON_LINK, 86400, 14400)},
+		{"host bits set", NewNDPPrefixInformationOption(net.ParseIP("2001:db8::1"), 64, NDP_PREFIX_FLAG_ON_LINK, 86400, 14400)},
+		{"autonomous non /64", NewNDPPrefixInformationOption(net.ParseIP("2001:db8::"), 48, NDP_PREFIX_FLAG_AUTONOMOUS, 86400, 14400)},
+		{"preferred exceeds valid", NewNDPPrefixInformationOption(net.ParseIP("2001:db8::"), 64, NDP_PREFIX_FLAG_ON_LINK, 100, 200)},
+		{"MTU below minimum", NewNDPMTUOption(1000)},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewICMPv6RouterAdvertisement(64, 0, 1800, 0, 0, []NDPOption{tt.opt}); err == nil {
+			t.Errorf("%s: NewICMPv6RouterAdvertisement should fail", tt.name)
+		}
+	}
+
+	// on-link のみなら /48 でもよい
+	opt := NewNDPPrefixInformationOption(net.ParseIP("2001:db8::"), 48, NDP_PREFIX_FLAG_ON_LINK, 86400, 14400)
+	if _, err := NewICMPv6RouterAdvertisement(64, 0, 1800, 0, 0, []NDPOption{opt}); err != nil {
+		t.Errorf("NewICMPv6RouterAdvertisement returned error: %v", err)
+	}
+}
+
+// TestParsedICMPv6Redirect tests that the addresses and the Target Link-Layer Address and Redirected Header options
+// are parsed from a Redirect message
+// RedirectメッセージからアドレスとTarget Link-Layer Address、Redirected Headerオプションが解析されることをテストします
+func TestParsedICMPv6Redirect(t *testing.T) {
+	target, destination := net.ParseIP("fe80::2"), net.ParseIP("2001:db8::99")
+	mac, _ := net.ParseMAC("00:15:5d:fb:bf:3b")
+	orig := NewIPv6(IPv6_NEXT_HEADER_UDP, net.ParseIP("2001:db8::1"), destination)
+	orig.Data = bytes.Repeat([]byte{0xaa}, 8)
+	orig.PayloadLength = uint16(len(orig.Data))
+	// Reserved 6 バイトの後に元のパケット
+	redirectedHeader := NDPOption{Type: NDP_OPTION_REDIRECTED_HEADER, Data: append(make([]byte, 6), orig.Bytes()...)}
+
+	body := append(make([]byte, 4), target.To16()...)
+	body = append(body, destination.To16()...)
+	body = append(body, NDPOption{Type: NDP_OPTION_TARGET_LINK_LAYER_ADDRESS, Data: mac}.Bytes()...)
+	body = append(body, redirectedHeader.Bytes()...)
+	icmpv6 := ParsedICMPv6((&ICMPv6{Type: ICMPv6_TYPE_REDIRECT, MessageBody: body}).Bytes())
+
+	redirect := ParsedICMPv6Redirect(icmpv6)
+	if redirect == nil {
+		t.Fatal("ParsedICMPv6Redirect() = nil")
+	}
+	if !redirect.Target.Equal(target) || !redirect.Destination.Equal(destination) || redirect.OnLink() {
+		t.Errorf("redirect = %s via %s, want %s via %s off-link", redirect.Destination, redirect.Target, destination, target)
+	}
+	if !bytes.Equal(redirect.TargetLinkLayerAddress, mac) {
+		t.Errorf("TargetLinkLayerAddress = %s, want %s", redirect.TargetLinkLayerAddress, mac)
+	}
+	if len(redirect.Options) != 2 {
+		t.Errorf("Options = %+v, want 2 options", redirect.Options)
+	}
+	if original := redirect.RedirectedIPv6; original == nil || !net.IP(original.DstIP).Equal(destination) || original.NextHeader != IPv6_NEXT_HEADER_UDP {
+		t.Errorf("RedirectedIPv6 = %+v, want the UDP packet to %s", original, destination)
+	}
+	// パディングを含めて引用される
+	if !bytes.HasPrefix(redirect.RedirectedHeader, orig.Bytes()) {
+		t.Errorf("RedirectedHeader = % x", redirect.RedirectedHeader)
+	}
+
+	// 宛先がリンク上にある場合は Target と Destination が同じ. 不正なオプション以降は無視する
+	body = append(make([]byte, 4), destination.To16()...)
+	body = append(body, destination.To16()...)
+	body = append(body, NDP_OPTION_TARGET_LINK_LAYER_ADDRESS, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	redirect = ParsedICMPv6Redirect(ParsedICMPv6((&ICMPv6{Type: ICMPv6_TYPE_REDIRECT, MessageBody: body}).Bytes()))
+	if redirect == nil || !redirect.OnLink() || redirect.TargetLinkLayerAddress != nil || redirect.RedirectedHeader != nil || len(redirect.Options) != 0 {
+		t.Errorf("on-link redirect = %+v", redirect)
+	}
+
+	if ParsedICMPv6Redirect(NewICMPv6EchoRequest()) != nil {
+		t.Error("Echo Request should not be parsed as Redirect")
+	}
+	if ParsedICMPv6Redirect(&ICMPv6{Type: ICMPv6_TYPE_REDIRECT, MessageBody: body[:20]}) != nil {
+		t.Error("a Redirect without the destination address should not be parsed")
+	}
+}
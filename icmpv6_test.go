@@ -117,7 +117,7 @@ func TestICMPv6_CalculateChecksum(t *testing.T) {
 	// This should result in a checksum of 0, which is the correct value for a valid packet
 	icmpv6.Checksum = checksum
 	verifyChecksum := calculateInternetChecksum(append(
-		createIPv6PseudoHeader(srcIP, dstIP, uint32(len(icmpv6.bytesWithZeroChecksum()))),
+		BuildIPv6PseudoHeader(srcIP, dstIP, uint32(len(icmpv6.bytesWithZeroChecksum())), IPv6_NEXT_HEADER_ICMPv6),
 		icmpv6.bytesWithZeroChecksum()...
 	))
 
@@ -127,25 +127,6 @@ func TestICMPv6_CalculateChecksum(t *testing.T) {
 	}
 }
 
-// Helper function to create an IPv6 pseudo-header for testing
-func createIPv6PseudoHeader(srcIP, dstIP net.IP, length uint32) []byte {
-	pseudoHeader := &bytes.Buffer{}
-	pseudoHeader.Write(srcIP.To16())
-	pseudoHeader.Write(dstIP.To16())
-	
-	lengthBytes := make([]byte, 4)
-	lengthBytes[0] = byte(length >> 24)
-	lengthBytes[1] = byte(length >> 16)
-	lengthBytes[2] = byte(length >> 8)
-	lengthBytes[3] = byte(length)
-	pseudoHeader.Write(lengthBytes)
-	
-	pseudoHeader.Write([]byte{0, 0, 0})
-	pseudoHeader.WriteByte(IPv6_NEXT_HEADER_ICMPv6)
-	
-	return pseudoHeader.Bytes()
-}
-
 func TestNewICMPv6EchoRequest(t *testing.T) {
 	// Create a new ICMPv6 Echo Request
 	icmpv6 := NewICMPv6EchoRequest()
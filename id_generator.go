@@ -0,0 +1,50 @@
+package packemon
+
+import (
+	"sync/atomic"
+)
+
+// IDGenerator hands out 16-bit IDs that go up by one on each call, wrapping around from 0xffff to 0, so that successive generated packets don't collide.
+// It is safe for concurrent use, and the same seed always gives the same sequence.
+// 呼び出すたびに1ずつ増える16ビットのIDを払い出します(0xffffの次は0)。続けて作成したパケットのIDが重複しないようにします。
+// 並行に使うことができ、同じシードからは常に同じ並びになります
+type IDGenerator struct {
+	// 上位ビットは捨てるので、uint32 があふれても 16 ビットとしては連続する
+	next atomic.Uint32
+}
+
+// NewIDGenerator creates an IDGenerator whose first ID is seed
+// 最初のIDがseedのIDGeneratorを作成します
+func NewIDGenerator(seed uint16) *IDGenerator {
+	g := &IDGenerator{}
+	g.Seed(seed)
+	return g
+}
+
+// Next returns the next ID
+// 次のIDを返します
+func (g *IDGenerator) Next() uint16 {
+	return uint16(g.next.Add(1) - 1)
+}
+
+// Seed restarts the sequence from seed, e.g. to reproduce the packets of an earlier run
+// 以前の実行と同じパケットを再現するなど、seedから並びをやり直します
+func (g *IDGenerator) Seed(seed uint16) {
+	g.next.Store(uint32(seed))
+}
+
+// Generators the builders take their IDs from. Seed them to make generated packets reproducible.
+// The first IDs are the values the builders used before they were generated.
+// ビルダーがIDを取得するジェネレーターです。シードを設定すると作成されるパケットを再現できます。
+// 最初のIDは、生成するようになる前にビルダーが使っていた値です
+var (
+	// IPv4IDs gives the Identification of NewIPv4
+	// NewIPv4のIdentification
+	IPv4IDs = NewIDGenerator(0x0d94)
+	// ICMPIdentifiers gives the Identifier of NewICMP and NewICMPv6EchoRequest
+	// NewICMPとNewICMPv6EchoRequestのIdentifier
+	ICMPIdentifiers = NewIDGenerator(0x34a1)
+	// DNSTransactionIDs gives the Transaction ID of NewDNSQuery
+	// NewDNSQueryのTransaction ID
+	DNSTransactionIDs = NewIDGenerator(0x1234)
+)
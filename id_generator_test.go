@@ -0,0 +1,73 @@
+package packemon
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestIDGenerator tests that IDs are unique across concurrent calls until they wrap around, and that the same seed repeats the sequence
+// 並行に呼び出しても一周するまでIDが重複せず、同じシードからは同じ並びになることをテストします
+func TestIDGenerator(t *testing.T) {
+	g := NewIDGenerator(0xfff0)
+
+	var mu sync.Mutex
+	seen := make(map[uint16]bool, 1<<16)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1 << 13 {
+				id := g.Next()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("ID 0x%04x was returned twice", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 1<<16 {
+		t.Errorf("%d unique IDs, want %d", len(seen), 1<<16)
+	}
+
+	// 一周したら seed に戻る
+	if got := g.Next(); got != 0xfff0 {
+		t.Errorf("Next() after a full cycle = 0x%04x, want 0xfff0", got)
+	}
+
+	g.Seed(0xfffe)
+	for _, want := range []uint16{0xfffe, 0xffff, 0x0000, 0x0001} {
+		if got := g.Next(); got != want {
+			t.Errorf("Next() = 0x%04x, want 0x%04x", got, want)
+		}
+	}
+}
+
+// TestBuildersUseIDGenerators tests that successive packets from the builders get successive IDs, reproducibly after seeding
+// ビルダーで続けて作成したパケットが連続したIDになり、シードを設定すると再現できることをテストします
+func TestBuildersUseIDGenerators(t *testing.T) {
+	defer IPv4IDs.Seed(0x0d94)
+	defer ICMPIdentifiers.Seed(0x34a1)
+	defer DNSTransactionIDs.Seed(0x1234)
+
+	IPv4IDs.Seed(100)
+	ICMPIdentifiers.Seed(200)
+	DNSTransactionIDs.Seed(300)
+
+	first, second := NewIPv4(IPv4_PROTO_UDP, 0, 0), NewIPv4(IPv4_PROTO_UDP, 0, 0)
+	if first.Identification != 100 || second.Identification != 101 {
+		t.Errorf("IPv4 Identification = %d, %d, want 100, 101", first.Identification, second.Identification)
+	}
+	if icmp := NewICMP(); icmp.Identifier != 200 {
+		t.Errorf("ICMP Identifier = %d, want 200", icmp.Identifier)
+	}
+	if echo := ParsedICMPv6Echo(NewICMPv6EchoRequest()); echo.Identifier != 201 {
+		t.Errorf("ICMPv6 Identifier = %d, want 201", echo.Identifier)
+	}
+	if dns := NewDNSQuery("go.dev", DNS_QUERY_TYPE_A); dns.TransactionID != 300 {
+		t.Errorf("DNS Transaction ID = %d, want 300", dns.TransactionID)
+	}
+}
@@ -0,0 +1,453 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+)
+
+// Link types of 802.11 captures, e.g. from a Wi-Fi interface in monitor mode
+// 802.11のキャプチャのリンクタイプです(モニターモードのWi-Fiインターフェースなど)
+const (
+	PCAP_LINKTYPE_IEEE802_11          = 105 // 802.11 フレームのみ
+	PCAP_LINKTYPE_IEEE802_11_RADIOTAP = 127 // radiotap ヘッダー + 802.11 フレーム
+)
+
+// 802.11 frame types
+// 802.11のフレームの種別
+const (
+	IEEE80211_TYPE_MANAGEMENT uint8 = 0
+	IEEE80211_TYPE_CONTROL    uint8 = 1
+	IEEE80211_TYPE_DATA       uint8 = 2
+)
+
+// 802.11 management subtypes carrying an SSID
+// SSIDを含む802.11の管理フレームのサブタイプ
+const (
+	IEEE80211_SUBTYPE_PROBE_REQUEST  uint8 = 4
+	IEEE80211_SUBTYPE_PROBE_RESPONSE uint8 = 5
+	IEEE80211_SUBTYPE_BEACON         uint8 = 8
+)
+
+// 802.11 frame control flags
+// 802.11のフレーム制御のフラグ
+const (
+	IEEE80211_FLAG_TO_DS     uint8 = 0x01
+	IEEE80211_FLAG_FROM_DS   uint8 = 0x02
+	IEEE80211_FLAG_MORE_FRAG uint8 = 0x04
+	IEEE80211_FLAG_RETRY     uint8 = 0x08
+	IEEE80211_FLAG_PROTECTED uint8 = 0x40 // ペイロードは暗号化されている
+	IEEE80211_FLAG_ORDER     uint8 = 0x80 // QoS データと管理フレームでは HT Control フィールドがある
+)
+
+// Radiotap fields decoded by ParseRadiotap, as bits of the first present word
+// ParseRadiotapが解析するradiotapのフィールド(最初のpresentワードのビット)
+const (
+	RADIOTAP_TSFT          = 0
+	RADIOTAP_FLAGS         = 1
+	RADIOTAP_RATE          = 2
+	RADIOTAP_CHANNEL       = 3
+	RADIOTAP_FHSS          = 4
+	RADIOTAP_DBM_ANTSIGNAL = 5
+)
+
+// RADIOTAP_FLAG_FCS in Radiotap.Flags tells that the 802.11 frame ends with the FCS
+// Radiotap.FlagsのRADIOTAP_FLAG_FCSは802.11フレームの末尾にFCSが含まれることを表します
+const RADIOTAP_FLAG_FCS uint8 = 0x10
+
+var ieee80211Names = map[[2]uint8]string{
+	{IEEE80211_TYPE_MANAGEMENT, 0}:                                "Association Request",
+	{IEEE80211_TYPE_MANAGEMENT, 1}:                                "Association Response",
+	{IEEE80211_TYPE_MANAGEMENT, 2}:                                "Reassociation Request",
+	{IEEE80211_TYPE_MANAGEMENT, 3}:                                "Reassociation Response",
+	{IEEE80211_TYPE_MANAGEMENT, IEEE80211_SUBTYPE_PROBE_REQUEST}:  "Probe Request",
+	{IEEE80211_TYPE_MANAGEMENT, IEEE80211_SUBTYPE_PROBE_RESPONSE}: "Probe Response",
+	{IEEE80211_TYPE_MANAGEMENT, IEEE80211_SUBTYPE_BEACON}:         "Beacon",
+	{IEEE80211_TYPE_MANAGEMENT, 10}:                               "Disassociation",
+	{IEEE80211_TYPE_MANAGEMENT, 11}:                               "Authentication",
+	{IEEE80211_TYPE_MANAGEMENT, 12}:                               "Deauthentication",
+	{IEEE80211_TYPE_MANAGEMENT, 13}:                               "Action",
+	{IEEE80211_TYPE_CONTROL, 8}:                                   "Block Ack Request",
+	{IEEE80211_TYPE_CONTROL, 9}:                                   "Block Ack",
+	{IEEE80211_TYPE_CONTROL, 10}:                                  "PS-Poll",
+	{IEEE80211_TYPE_CONTROL, 11}:                                  "RTS",
+	{IEEE80211_TYPE_CONTROL, 12}:                                  "CTS",
+	{IEEE80211_TYPE_CONTROL, 13}:                                  "ACK",
+	{IEEE80211_TYPE_DATA, 0}:                                      "Data",
+	{IEEE80211_TYPE_DATA, 4}:                                      "Null",
+	{IEEE80211_TYPE_DATA, 8}:                                      "QoS Data",
+	{IEEE80211_TYPE_DATA, 12}:                                     "QoS Null",
+}
+
+// Radiotap represents the radiotap header a capture driver prepends to 802.11 frames with the radio information
+// ref: https://www.radiotap.org
+// キャプチャのドライバーが無線の情報とともに802.11フレームの前に付けるradiotapヘッダーを表します
+type Radiotap struct {
+	Version uint8
+	Length  uint16   // ヘッダー全体のバイト数
+	Present []uint32 // 含まれるフィールドのビットマップ(拡張されたものを含む)
+
+	// Fields of the first present word decoded. Zero when not present, see HasField
+	// 解析した最初のpresentワードのフィールド。含まれない場合はゼロ値(HasField参照)
+	Flags     uint8
+	Rate      uint8  // 500 kbps 単位
+	Frequency uint16 // MHz
+	Signal    int8   // dBm
+}
+
+// HasField reports whether the field of bit in the first present word, e.g. RADIOTAP_DBM_ANTSIGNAL, is in the header
+// 最初のpresentワードのbitのフィールド(RADIOTAP_DBM_ANTSIGNALなど)がヘッダーに含まれるかどうかを返します
+func (r *Radiotap) HasField(bit uint) bool {
+	return len(r.Present) > 0 && r.Present[0]&(1<<bit) != 0
+}
+
+// radiotap のフィールドのビット、アラインメント、サイズ. フィールドはヘッダーの先頭からアラインメントの倍数の位置に置かれる
+var radiotapFields = []struct {
+	bit         uint
+	align, size int
+}{
+	{bit: RADIOTAP_TSFT, align: 8, size: 8},
+	{bit: RADIOTAP_FLAGS, align: 1, size: 1},
+	{bit: RADIOTAP_RATE, align: 1, size: 1},
+	{bit: RADIOTAP_CHANNEL, align: 2, size: 4},
+	{bit: RADIOTAP_FHSS, align: 1, size: 2},
+	{bit: RADIOTAP_DBM_ANTSIGNAL, align: 1, size: 1},
+}
+
+// ParseRadiotap parses the radiotap header at the start of data. The 802.11 frame starts Length bytes into data
+// dataの先頭のradiotapヘッダーを解析します。802.11フレームはdataのLengthバイト目から始まります
+func ParseRadiotap(data []byte) (*Radiotap, error) {
+	r := NewFieldReader(data, binary.LittleEndian)
+	radiotap := &Radiotap{}
+	var err error
+	if radiotap.Version, err = r.Read8(); err != nil {
+		return nil, fmt.Errorf("radiotap header too short: %w", err)
+	}
+	if err := r.Skip(1); err != nil {
+		return nil, fmt.Errorf("radiotap header too short: %w", err)
+	}
+	if radiotap.Length, err = r.Read16(); err != nil {
+		return nil, fmt.Errorf("radiotap header too short: %w", err)
+	}
+	if radiotap.Version != 0 {
+		return nil, fmt.Errorf("unsupported radiotap version: %d", radiotap.Version)
+	}
+	if int(radiotap.Length) > len(data) {
+		return nil, fmt.Errorf("radiotap header length %d exceeds %d bytes", radiotap.Length, len(data))
+	}
+
+	// bit 31 が立っている間、present ワードが続く
+	for {
+		present, err := r.Read32()
+		if err != nil || r.Offset() > int(radiotap.Length) {
+			return nil, fmt.Errorf("radiotap present words exceed the header length %d", radiotap.Length)
+		}
+		radiotap.Present = append(radiotap.Present, present)
+		if present&(1<<31) == 0 {
+			break
+		}
+	}
+
+	offset := r.Offset()
+	for _, field := range radiotapFields {
+		if !radiotap.HasField(field.bit) {
+			continue
+		}
+		offset = (offset + field.align - 1) / field.align * field.align
+		if offset+field.size > int(radiotap.Length) {
+			return nil, fmt.Errorf("radiotap field %d exceeds the header length %d", field.bit, radiotap.Length)
+		}
+		value := data[offset : offset+field.size]
+		switch field.bit {
+		case RADIOTAP_FLAGS:
+			radiotap.Flags = value[0]
+		case RADIOTAP_RATE:
+			radiotap.Rate = value[0]
+		case RADIOTAP_CHANNEL:
+			radiotap.Frequency = binary.LittleEndian.Uint16(value[0:2])
+		case RADIOTAP_DBM_ANTSIGNAL:
+			radiotap.Signal = int8(value[0])
+		}
+		offset += field.size
+	}
+	return radiotap, nil
+}
+
+// IEEE80211Frame represents an 802.11 (Wi-Fi) frame
+// ref: IEEE 802.11-2020 9.2
+// 802.11(Wi-Fi)フレームを表します
+type IEEE80211Frame struct {
+	// Radiotap is the radiotap header of the capture. nil for PCAP_LINKTYPE_IEEE802_11
+	// キャプチャのradiotapヘッダー。PCAP_LINKTYPE_IEEE802_11の場合はnil
+	Radiotap *Radiotap
+
+	FrameControl uint16 // Wireshark と同じく 1 バイト目(種別)を上位に置いた値
+	Type         uint8
+	Subtype      uint8
+	Flags        uint8
+	Duration     uint16
+
+	// Addresses of the frame. Which is the source, the destination or the BSSID depends on the type and the DS flags, see Source and Destination.
+	// Control frames have only Addr1 (CTS, ACK) or Addr1 and Addr2, and Addr4 is only in frames between access points
+	// フレームのアドレス。どれが送信元、宛先、BSSIDかは種別とDSフラグによる(SourceとDestination参照)。
+	// 制御フレームにはAddr1(CTS、ACK)またはAddr1とAddr2のみがあり、Addr4はアクセスポイント間のフレームにのみある
+	Addr1 []byte
+	Addr2 []byte
+	Addr3 []byte
+	Addr4 []byte
+
+	SequenceNumber uint16
+	FragmentNumber uint8
+
+	// SSID is the network name in a beacon, probe request or probe response. Empty for a hidden network or a wildcard probe
+	// ビーコン、プローブ要求、プローブ応答のネットワーク名。隠されたネットワークやワイルドカードのプローブでは空
+	SSID string
+
+	Payload []byte // ヘッダーより後ろ(FCS を除く)
+}
+
+// Name returns the name of the type and subtype, e.g. "Beacon" or "QoS Data"
+// 種別とサブタイプの名前("Beacon"や"QoS Data"など)を返します
+func (f *IEEE80211Frame) Name() string {
+	if name, ok := ieee80211Names[[2]uint8{f.Type, f.Subtype}]; ok {
+		return name
+	}
+	return fmt.Sprintf("Type %d Subtype %d", f.Type, f.Subtype)
+}
+
+// String returns a string representation of the 802.11 frame
+func (f *IEEE80211Frame) String() string {
+	s := fmt.Sprintf("IEEE 802.11 %s: Addr1=%s, Addr2=%s, Addr3=%s, Len=%d",
+		f.Name(),
+		net.HardwareAddr(f.Addr1),
+		net.HardwareAddr(f.Addr2),
+		net.HardwareAddr(f.Addr3),
+		len(f.Payload))
+	if f.SSID != "" {
+		s += fmt.Sprintf(", SSID=%q", f.SSID)
+	}
+	return s
+}
+
+// Destination returns the address of the final recipient (DA)
+// 最終的な受信者のアドレス(DA)を返します
+func (f *IEEE80211Frame) Destination() []byte {
+	if f.Flags&IEEE80211_FLAG_TO_DS != 0 {
+		return f.Addr3
+	}
+	return f.Addr1
+}
+
+// Source returns the address of the original sender (SA)
+// 元の送信者のアドレス(SA)を返します
+func (f *IEEE80211Frame) Source() []byte {
+	switch f.Flags & (IEEE80211_FLAG_TO_DS | IEEE80211_FLAG_FROM_DS) {
+	case IEEE80211_FLAG_TO_DS | IEEE80211_FLAG_FROM_DS:
+		return f.Addr4
+	case IEEE80211_FLAG_FROM_DS:
+		return f.Addr3
+	}
+	return f.Addr2
+}
+
+// ParseIEEE80211Frame parses an 802.11 frame without the FCS
+// FCSを含まない802.11フレームを解析します
+func ParseIEEE80211Frame(data []byte) (*IEEE80211Frame, error) {
+	if len(data) < 10 {
+		return nil, fmt.Errorf("802.11 frame too short: %d bytes", len(data))
+	}
+	if version := data[0] & 0x03; version != 0 {
+		return nil, fmt.Errorf("unsupported 802.11 protocol version: %d", version)
+	}
+	frame := &IEEE80211Frame{
+		FrameControl: binary.BigEndian.Uint16(data[0:2]),
+		Type:         data[0] >> 2 & 0x03,
+		Subtype:      data[0] >> 4,
+		Flags:        data[1],
+		Duration:     binary.LittleEndian.Uint16(data[2:4]),
+		Addr1:        data[4:10],
+	}
+
+	headerLength := 10
+	switch frame.Type {
+	case IEEE80211_TYPE_CONTROL:
+		// CTS と ACK は受信者のアドレスのみ
+		if frame.Subtype != 12 && frame.Subtype != 13 && len(data) >= 16 {
+			frame.Addr2 = data[10:16]
+			headerLength = 16
+		}
+	case IEEE80211_TYPE_MANAGEMENT, IEEE80211_TYPE_DATA:
+		if len(data) < 24 {
+			return nil, fmt.Errorf("802.11 header too short: %d bytes", len(data))
+		}
+		frame.Addr2 = data[10:16]
+		frame.Addr3 = data[16:22]
+		sequence := binary.LittleEndian.Uint16(data[22:24])
+		frame.FragmentNumber = uint8(sequence & 0x0f)
+		frame.SequenceNumber = sequence >> 4
+		headerLength = 24
+
+		// アクセスポイント間(WDS)のデータフレームには 4 つ目のアドレスがある
+		wds := frame.Type == IEEE80211_TYPE_DATA && frame.Flags&(IEEE80211_FLAG_TO_DS|IEEE80211_FLAG_FROM_DS) == IEEE80211_FLAG_TO_DS|IEEE80211_FLAG_FROM_DS
+		qos := frame.Type == IEEE80211_TYPE_DATA && frame.Subtype&0x08 != 0
+		if wds {
+			headerLength += 6
+		}
+		if qos {
+			headerLength += 2
+		}
+		if frame.Flags&IEEE80211_FLAG_ORDER != 0 && (qos || frame.Type == IEEE80211_TYPE_MANAGEMENT) {
+			headerLength += 4
+		}
+		if len(data) < headerLength {
+			return nil, fmt.Errorf("802.11 header too short: %d bytes, want %d", len(data), headerLength)
+		}
+		if wds {
+			frame.Addr4 = data[24:30]
+		}
+	}
+	frame.Payload = data[headerLength:]
+
+	if frame.Type == IEEE80211_TYPE_MANAGEMENT && frame.Flags&IEEE80211_FLAG_PROTECTED == 0 {
+		frame.SSID = ieee80211SSID(frame.Subtype, frame.Payload)
+	}
+	return frame, nil
+}
+
+// ieee80211SSID は管理フレームの本体の情報要素から SSID を探す
+func ieee80211SSID(subtype uint8, body []byte) string {
+	var fixed int // 情報要素より前の固定長のフィールド
+	switch subtype {
+	case IEEE80211_SUBTYPE_BEACON, IEEE80211_SUBTYPE_PROBE_RESPONSE:
+		fixed = 12 // Timestamp 8byte + Beacon Interval 2byte + Capability 2byte
+	case IEEE80211_SUBTYPE_PROBE_REQUEST:
+		fixed = 0
+	default:
+		return ""
+	}
+	if len(body) < fixed {
+		return ""
+	}
+	elements := body[fixed:]
+	for len(elements) >= 2 {
+		id, length := elements[0], int(elements[1])
+		if len(elements) < 2+length {
+			return ""
+		}
+		if id == 0 {
+			return string(elements[2 : 2+length])
+		}
+		elements = elements[2+length:]
+	}
+	return ""
+}
+
+// llcSNAP returns the EtherType and the packet of a data frame whose payload is wrapped in LLC/SNAP (RFC 1042, 802.1H).
+// Null frames carry no data, and the payload of a protected frame is encrypted
+// ペイロードがLLC/SNAP(RFC 1042、802.1H)で包まれたデータフレームのEtherTypeとパケットを返します。
+// Nullフレームはデータを運ばず、保護されたフレームのペイロードは暗号化されています
+func (f *IEEE80211Frame) llcSNAP() (uint16, []byte, bool) {
+	if f.Type != IEEE80211_TYPE_DATA || f.Subtype&0x04 != 0 || f.Flags&IEEE80211_FLAG_PROTECTED != 0 {
+		return 0, nil, false
+	}
+	p := f.Payload
+	if len(p) < 8 || p[0] != 0xaa || p[1] != 0xaa || p[2] != 0x03 {
+		return 0, nil, false
+	}
+	if p[3] != 0x00 || p[4] != 0x00 || (p[5] != 0x00 && p[5] != 0xf8) {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint16(p[6:8]), p[8:], true
+}
+
+// DecodeIEEE80211Frame decodes a captured 802.11 frame of linkType, PCAP_LINKTYPE_IEEE802_11 or PCAP_LINKTYPE_IEEE802_11_RADIOTAP.
+// The packet in an LLC/SNAP-wrapped data frame is decoded as if it were carried in an Ethernet frame from Source to Destination,
+// so that its upper layers are the same as in a wired capture
+// linkType(PCAP_LINKTYPE_IEEE802_11またはPCAP_LINKTYPE_IEEE802_11_RADIOTAP)のキャプチャした802.11フレームをデコードします。
+// LLC/SNAPで包まれたデータフレームのパケットは、SourceからDestinationへのEthernetフレームで運ばれたものとして解析し、
+// 上位のレイヤは有線のキャプチャと同じになります
+func DecodeIEEE80211Frame(data []byte, linkType int) (*Passive, error) {
+	return decodeIEEE80211Frame(data, len(data), linkType, nil, PARSE_DEPTH_FULL)
+}
+
+func decodeIEEE80211Frame(data []byte, wireLength int, linkType int, decodeAs *DecodeAsTable, depth ParseDepth) (*Passive, error) {
+	if wireLength < len(data) {
+		wireLength = len(data)
+	}
+	passive := &Passive{
+		Raw:        data,
+		RawLength:  len(data),
+		WireLength: wireLength,
+		Truncated:  wireLength > len(data),
+	}
+
+	frame := data
+	var radiotap *Radiotap
+	if linkType == PCAP_LINKTYPE_IEEE802_11_RADIOTAP {
+		var err error
+		radiotap, err = ParseRadiotap(data)
+		recordDecode("Radiotap", err == nil)
+		if err != nil {
+			return nil, err
+		}
+		frame = data[radiotap.Length:]
+
+		if radiotap.Flags&RADIOTAP_FLAG_FCS != 0 {
+			// スナップ長で切り詰められたフレームの末尾は FCS ではない
+			if passive.Truncated || len(frame) < ETHERNET_FCS_LENGTH {
+				passive.FCSStatus = FCS_STATUS_MISSING
+			} else {
+				frame, passive.FCS = frame[:len(frame)-ETHERNET_FCS_LENGTH], frame[len(frame)-ETHERNET_FCS_LENGTH:]
+				passive.FCSStatus = FCS_STATUS_INVALID
+				if crc32.ChecksumIEEE(frame) == binary.LittleEndian.Uint32(passive.FCS) {
+					passive.FCSStatus = FCS_STATUS_VALID
+				}
+			}
+		}
+	}
+
+	wlan, err := ParseIEEE80211Frame(frame)
+	recordDecode("802.11", err == nil)
+	if err != nil {
+		return nil, err
+	}
+	wlan.Radiotap = radiotap
+	passive.IEEE80211 = wlan
+
+	if etherType, payload, ok := wlan.llcSNAP(); ok {
+		passive.EthernetFrame = &EthernetFrame{
+			DstAddr: wlan.Destination(),
+			SrcAddr: wlan.Source(),
+			Type:    etherType,
+			Payload: payload,
+		}
+		parseEthernetPayload(passive, decodeAs, depth)
+	}
+	return passive, nil
+}
+
+// SetLinkType sets the link type of the frames captured on the interface: PCAP_LINKTYPE_ETHERNET (the default),
+// or PCAP_LINKTYPE_IEEE802_11 or PCAP_LINKTYPE_IEEE802_11_RADIOTAP for a Wi-Fi interface in monitor mode.
+// The FCS mode applies to Ethernet only, as the radiotap header tells whether an 802.11 frame ends with the FCS
+// インターフェースでキャプチャするフレームのリンクタイプを設定します。PCAP_LINKTYPE_ETHERNET(デフォルト)、
+// またはモニターモードのWi-FiインターフェースのPCAP_LINKTYPE_IEEE802_11かPCAP_LINKTYPE_IEEE802_11_RADIOTAPです。
+// 802.11フレームの末尾にFCSが含まれるかはradiotapヘッダーで分かるため、FCSの扱いはEthernetにのみ適用されます
+func (nwif *NetworkInterface) SetLinkType(linkType int) error {
+	switch linkType {
+	case PCAP_LINKTYPE_ETHERNET, PCAP_LINKTYPE_IEEE802_11, PCAP_LINKTYPE_IEEE802_11_RADIOTAP:
+	default:
+		return fmt.Errorf("unsupported capture link type: %d", linkType)
+	}
+	nwif.linkType.Store(int32(linkType))
+	return nil
+}
+
+// LinkType returns the link type of the captured frames
+// キャプチャするフレームのリンクタイプを返します
+func (nwif *NetworkInterface) LinkType() int {
+	if linkType := nwif.linkType.Load(); linkType != 0 {
+		return int(linkType)
+	}
+	return PCAP_LINKTYPE_ETHERNET
+}
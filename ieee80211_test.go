@@ -0,0 +1,96 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// radiotap ヘッダー(Flags、Rate、Channel、dBm Antenna Signal)と FCS 付きのビーコン
+func ieee80211TestBeacon() []byte {
+	radiotap := []byte{
+		0x00, 0x00, 0x10, 0x00, // version, pad, length 16
+		0x2e, 0x00, 0x00, 0x00, // present: Flags, Rate, Channel, dBm Antenna Signal
+		RADIOTAP_FLAG_FCS,      // Flags
+		0x02,                   // Rate 1Mbps
+		0x85, 0x09, 0xa0, 0x00, // Channel 2437MHz (ch 6), 2GHz CCK
+		0xd8, // -40dBm
+		0x00, // padding
+	}
+	beacon := []byte{
+		0x80, 0x00, 0x00, 0x00, // Frame Control (Beacon), Duration
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // Addr1 (broadcast)
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, // Addr2 (BSSID)
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, // Addr3 (BSSID)
+		0x10, 0x00, // Sequence 1
+		0, 0, 0, 0, 0, 0, 0, 0, // Timestamp
+		0x64, 0x00, 0x11, 0x04, // Beacon Interval, Capability
+		0x00, 0x08, 'p', 'a', 'c', 'k', 'e', 'm', 'o', 'n', // SSID
+		0x01, 0x01, 0x82, // Supported Rates
+	}
+	beacon = binary.LittleEndian.AppendUint32(beacon, crc32.ChecksumIEEE(beacon))
+	return append(radiotap, beacon...)
+}
+
+// TestDecodeIEEE80211Frame tests that a radiotap-prefixed beacon is decoded with its SSID and radio information,
+// and that the packet in a data frame is decoded like a wired one
+// radiotapヘッダー付きのビーコンがSSIDと無線の情報とともにデコードされること、
+// およびデータフレームのパケットが有線と同様にデコードされることをテストします
+func TestDecodeIEEE80211Frame(t *testing.T) {
+	beacon := ieee80211TestBeacon()
+	passive, err := DecodeIEEE80211Frame(beacon, PCAP_LINKTYPE_IEEE802_11_RADIOTAP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wlan := passive.IEEE80211
+	if wlan == nil || wlan.Name() != "Beacon" || wlan.SSID != "packemon" || wlan.SequenceNumber != 1 {
+		t.Fatalf("IEEE80211 = %+v", wlan)
+	}
+	if !bytes.Equal(wlan.Addr2, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}) {
+		t.Errorf("Addr2 = %x", wlan.Addr2)
+	}
+	radiotap := wlan.Radiotap
+	if radiotap == nil || radiotap.Length != 16 || radiotap.Frequency != 2437 || radiotap.Rate != 2 || !radiotap.HasField(RADIOTAP_DBM_ANTSIGNAL) || radiotap.Signal != -40 {
+		t.Errorf("Radiotap = %+v", radiotap)
+	}
+	if passive.FCSStatus != FCS_STATUS_VALID || passive.EthernetFrame != nil || passive.RawLength != len(beacon) {
+		t.Errorf("FCSStatus = %s, EthernetFrame = %v, RawLength = %d", passive.FCSStatus, passive.EthernetFrame, passive.RawLength)
+	}
+
+	beacon[len(beacon)-1] ^= 0xff
+	if passive, err := DecodeIEEE80211Frame(beacon, PCAP_LINKTYPE_IEEE802_11_RADIOTAP); err != nil || passive.FCSStatus != FCS_STATUS_INVALID {
+		t.Errorf("corrupted FCS: %v, %v", passive, err)
+	}
+
+	// ステーションからアクセスポイントへの(ToDS)データフレームで運ばれる HTTP リクエスト
+	station := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b}
+	destination := []byte{0x00, 0x15, 0x5d, 0x00, 0x00, 0x01}
+	wired := parseDepthTestFrame()
+	data := []byte{0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00} // radiotap (フィールド無し)
+	data = append(data, 0x08, IEEE80211_FLAG_TO_DS, 0x00, 0x00)
+	data = append(data, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55) // Addr1 (BSSID)
+	data = append(data, station...)                         // Addr2 (SA)
+	data = append(data, destination...)                     // Addr3 (DA)
+	data = append(data, 0x20, 0x00)
+	data = append(data, 0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00)
+	data = append(data, wired[12:]...) // EtherType + IPv4
+
+	fr, err := OpenReader(bytes.NewReader(framedStream(data)), PCAP_LINKTYPE_IEEE802_11_RADIOTAP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passive, err = fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.IEEE80211 == nil || passive.IEEE80211.Name() != "Data" || passive.EthernetFrame == nil {
+		t.Fatalf("data frame: %+v", passive)
+	}
+	if !bytes.Equal(passive.EthernetFrame.SrcAddr, station) || !bytes.Equal(passive.EthernetFrame.DstAddr, destination) || passive.EthernetFrame.Type != ETHER_TYPE_IPv4 {
+		t.Errorf("EthernetFrame = %v", passive.EthernetFrame)
+	}
+	if passive.IPv4 == nil || passive.TCP == nil || passive.HTTP == nil {
+		t.Errorf("upper layers of the data frame: IPv4 = %v, TCP = %v, HTTP = %v", passive.IPv4, passive.TCP, passive.HTTP)
+	}
+}
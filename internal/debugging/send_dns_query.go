@@ -3,20 +3,8 @@ package debugging
 import p "github.com/ddddddO/packemon"
 
 func (dnw *debugNetworkInterface) SendDNSquery(firsthopMACAddr [6]byte) error {
-	dns := &p.DNS{
-		TransactionID: 0x1234,
-		Flags:         0x0100, // standard query
-		Questions:     0x0001,
-		AnswerRRs:     0x0000,
-		AuthorityRRs:  0x0000,
-		AdditionalRRs: 0x0000,
-		Queries: &p.Queries{
-			Typ:   0x0001, // A
-			Class: 0x0001, // IN
-		},
-	}
-	// dns.domain("github.com")
-	dns.Domain("go.dev")
+	// dns := p.NewDNSQuery("github.com", p.DNS_QUERY_TYPE_A)
+	dns := p.NewDNSQuery("go.dev", p.DNS_QUERY_TYPE_A)
 	udp := &p.UDP{
 		SrcPort:  0x0401, // 1025
 		DstPort:  0x0035, // 53
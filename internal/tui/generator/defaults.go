@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ddddddO/packemon"
+)
+
+// ApplyDefaultPackets overrides the compiled-in DEFAULT_* values with the ones set in the config.
+// Unset values and "auto" sources keep the current values, so call it before deriving sources from the interface.
+// 設定ファイルの値で組み込みの DEFAULT_* を上書きします。未設定の値と "auto" の送信元は現在の値のままです
+func ApplyDefaultPackets(d packemon.DefaultPacketsConfig) error {
+	if !d.IsAuto(d.SourceMAC) {
+		mac, err := macToHex(d.SourceMAC)
+		if err != nil {
+			return err
+		}
+		DEFAULT_MAC_SOURCE = mac
+		DEFAULT_ARP_SENDER_MAC = mac
+	}
+	if d.DestinationMAC != "" {
+		mac, err := macToHex(d.DestinationMAC)
+		if err != nil {
+			return err
+		}
+		DEFAULT_MAC_DESTINATION = mac
+	}
+
+	if !d.IsAuto(d.SourceIP) {
+		if ip := net.ParseIP(d.SourceIP); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid source IPv4 address in config: %s", d.SourceIP)
+		}
+		DEFAULT_IP_SOURCE = d.SourceIP
+		DEFAULT_ARP_SENDER_IP = d.SourceIP
+	}
+	if d.DestinationIP != "" {
+		if ip := net.ParseIP(d.DestinationIP); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid destination IPv4 address in config: %s", d.DestinationIP)
+		}
+		DEFAULT_IP_DESTINATION = d.DestinationIP
+	}
+	if !d.IsAuto(d.SourceIPv6) {
+		if ip := net.ParseIP(d.SourceIPv6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid source IPv6 address in config: %s", d.SourceIPv6)
+		}
+		DEFAULT_IPv6_SOURCE = d.SourceIPv6
+	}
+	if d.DestinationIPv6 != "" {
+		if ip := net.ParseIP(d.DestinationIPv6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid destination IPv6 address in config: %s", d.DestinationIPv6)
+		}
+		DEFAULT_IPv6_DESTINATION = d.DestinationIPv6
+	}
+
+	if d.TTL != 0 {
+		DEFAULT_IP_TTL = fmt.Sprintf("0x%02x", d.TTL)
+	}
+	if d.HopLimit != 0 {
+		DEFAULT_IPv6_HOP_LIMIT = fmt.Sprintf("0x%02x", d.HopLimit)
+	}
+
+	if d.TCPSourcePort != 0 {
+		DEFAULT_TCP_PORT_SOURCE = fmt.Sprintf("%d", d.TCPSourcePort)
+	}
+	if d.TCPDestinationPort != 0 {
+		DEFAULT_TCP_PORT_DESTINATION = fmt.Sprintf("%d", d.TCPDestinationPort)
+	}
+	if d.UDPSourcePort != 0 {
+		DEFAULT_UDP_PORT_SOURCE = fmt.Sprintf("%d", d.UDPSourcePort)
+	}
+	if d.UDPDestinationPort != 0 {
+		DEFAULT_UDP_PORT_DESTINATION = fmt.Sprintf("%d", d.UDPDestinationPort)
+	}
+
+	if d.DNSDomain != "" {
+		DEFAULT_DNS_QUERIES_DOMAIN = d.DNSDomain
+	}
+	if d.HTTPHost != "" {
+		DEFAULT_HTTP_HOST = d.HTTPHost
+	}
+	if d.HTTPUserAgent != "" {
+		DEFAULT_HTTP_USER_AGENT = d.HTTPUserAgent
+	}
+	return nil
+}
+
+// "00:15:5d:fb:bf:3a" -> "0x00155dfbbf3a"
+func macToHex(s string) (string, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address in config: %s", s)
+	}
+	return fmt.Sprintf("0x%s", strings.ReplaceAll(mac.String(), ":", "")), nil
+}
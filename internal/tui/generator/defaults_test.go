@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/ddddddO/packemon"
+)
+
+// TestApplyDefaultPackets tests that config values override the compiled-in defaults and unset values are kept
+// 設定値が組み込みのデフォルト値を上書きし、未設定の値はそのままであることをテストします
+func TestApplyDefaultPackets(t *testing.T) {
+	restoreDefaultPackets(t)
+	DEFAULT_MAC_SOURCE = "0x00155dfbbf3a"
+	DEFAULT_IP_SOURCE = "172.24.79.207"
+	defaultTCPDestination := DEFAULT_TCP_PORT_DESTINATION
+	defaultHopLimit := DEFAULT_IPv6_HOP_LIMIT
+
+	err := ApplyDefaultPackets(packemon.DefaultPacketsConfig{
+		SourceMAC:     packemon.DEFAULT_PACKETS_AUTO,
+		SourceIP:      "10.0.0.1",
+		DestinationIP: "10.0.0.254",
+		TTL:           32,
+		DNSDomain:     "example.com",
+	})
+	if err != nil {
+		t.Fatalf("ApplyDefaultPackets returned error: %v", err)
+	}
+
+	if DEFAULT_MAC_SOURCE != "0x00155dfbbf3a" {
+		t.Errorf("DEFAULT_MAC_SOURCE = %s, want it kept for auto", DEFAULT_MAC_SOURCE)
+	}
+	if DEFAULT_IP_SOURCE != "10.0.0.1" || DEFAULT_ARP_SENDER_IP != "10.0.0.1" {
+		t.Errorf("DEFAULT_IP_SOURCE = %s, DEFAULT_ARP_SENDER_IP = %s, want 10.0.0.1", DEFAULT_IP_SOURCE, DEFAULT_ARP_SENDER_IP)
+	}
+	if DEFAULT_IP_DESTINATION != "10.0.0.254" {
+		t.Errorf("DEFAULT_IP_DESTINATION = %s, want 10.0.0.254", DEFAULT_IP_DESTINATION)
+	}
+	if DEFAULT_IP_TTL != "0x20" {
+		t.Errorf("DEFAULT_IP_TTL = %s, want 0x20", DEFAULT_IP_TTL)
+	}
+	if DEFAULT_DNS_QUERIES_DOMAIN != "example.com" {
+		t.Errorf("DEFAULT_DNS_QUERIES_DOMAIN = %s, want example.com", DEFAULT_DNS_QUERIES_DOMAIN)
+	}
+	// 未設定の値は組み込みのまま
+	if DEFAULT_TCP_PORT_DESTINATION != defaultTCPDestination || DEFAULT_IPv6_HOP_LIMIT != defaultHopLimit {
+		t.Errorf("unset values should keep the compiled-in defaults")
+	}
+
+	if err := ApplyDefaultPackets(packemon.DefaultPacketsConfig{SourceMAC: "00:15:5D:FB:BF:3B"}); err != nil {
+		t.Fatalf("ApplyDefaultPackets returned error: %v", err)
+	}
+	if DEFAULT_MAC_SOURCE != "0x00155dfbbf3b" {
+		t.Errorf("DEFAULT_MAC_SOURCE = %s, want 0x00155dfbbf3b", DEFAULT_MAC_SOURCE)
+	}
+}
+
+// TestApplyDefaultPacketsInvalid tests that invalid addresses in the config are rejected
+// 設定ファイル中の不正なアドレスがエラーになることをテストします
+func TestApplyDefaultPacketsInvalid(t *testing.T) {
+	restoreDefaultPackets(t)
+	for _, d := range []packemon.DefaultPacketsConfig{
+		{SourceMAC: "not-a-mac"},
+		{SourceIP: "2001:db8::1"},
+		{DestinationIPv6: "192.168.10.110"},
+	} {
+		if err := ApplyDefaultPackets(d); err == nil {
+			t.Errorf("ApplyDefaultPackets(%+v) should fail", d)
+		}
+	}
+}
+
+// ApplyDefaultPackets が書き換えるパッケージ変数をテストの終了時に元に戻す
+func restoreDefaultPackets(t *testing.T) {
+	t.Helper()
+	globals := []*string{
+		&DEFAULT_MAC_SOURCE, &DEFAULT_MAC_DESTINATION,
+		&DEFAULT_ARP_SENDER_MAC, &DEFAULT_ARP_SENDER_IP,
+		&DEFAULT_IP_SOURCE, &DEFAULT_IP_DESTINATION, &DEFAULT_IP_TTL,
+		&DEFAULT_IPv6_SOURCE, &DEFAULT_IPv6_DESTINATION, &DEFAULT_IPv6_HOP_LIMIT,
+		&DEFAULT_UDP_PORT_SOURCE, &DEFAULT_UDP_PORT_DESTINATION,
+		&DEFAULT_TCP_PORT_SOURCE, &DEFAULT_TCP_PORT_DESTINATION,
+		&DEFAULT_DNS_QUERIES_DOMAIN, &DEFAULT_HTTP_HOST, &DEFAULT_HTTP_USER_AGENT,
+	}
+	saved := make([]string, len(globals))
+	for i, g := range globals {
+		saved[i] = *g
+	}
+	t.Cleanup(func() {
+		for i, g := range globals {
+			*g = saved[i]
+		}
+	})
+}
@@ -36,10 +36,12 @@ var (
 	DEFAULT_IP_PROTOCOL    = "ICMP"
 	DEFAULT_IP_SOURCE      = ""
 	DEFAULT_IP_DESTINATION = ""
+	DEFAULT_IP_TTL         = "0x80"
 
 	DEFAULT_IPv6_PROTOCOL    = "ICMPv6"
 	DEFAULT_IPv6_SOURCE      = ""
 	DEFAULT_IPv6_DESTINATION = ""
+	DEFAULT_IPv6_HOP_LIMIT   = "0x40"
 
 	DEFAULT_ICMP_TYPE       = "0x08"
 	DEFAULT_ICMP_CODE       = "0x00"
@@ -49,6 +51,7 @@ var (
 	DEFAULT_ICMPv6_CODE       = "0x00"
 	DEFAULT_ICMPv6_IDENTIFIER = "0x1234"
 	DEFAULT_ICMPv6_SEQUENCE   = "0x0001"
+	DEFAULT_ICMPv6_RA_PREFIX  = "2001:db8:1::/64"
 
 	DEFAULT_UDP_PORT_SOURCE      = "47000"
 	DEFAULT_UDP_PORT_DESTINATION = "53"
@@ -148,43 +151,43 @@ func (g *generator) form(ctx context.Context, sendFn func(*packemon.EthernetFram
 	//       L5 と L6 でそれぞれ分けていいかもだけど、OSI参照モデルのセッション層・プレゼンテーション層と合わないだろうし、
 	//       L + ギリシャ文字 でレイヤを表すようにする。ref: ギリシャ文字: https://opencourse.doshisha.ac.jp/opc/bj01/math-intro/PC/greece.html
 
-	l7s := []string{"", "DNS", "HTTP"}
+	l7s := g.protocolOptions(packemon.LAYER_L7, true)
 	l7Protocols := tview.NewDropDown()
 	l7Protocols.SetTitle("Lε").SetBorder(true)
 	l7Protocols.SetOptions(l7s, func(text string, index int) {
-		switchProtocol("L7")(text, l7s)
+		switchProtocol(packemon.LAYER_L7)(text, l7s)
 	})
-	l7Protocols.SetCurrentOption(1)
+	l7Protocols.SetCurrentOption(optionIndex(l7s, "DNS"))
 
-	l5_6s := []string{"", "TLSv1.2", "TLSv1.3"}
+	l5_6s := g.protocolOptions(packemon.LAYER_L5_6, true)
 	l5_6Protocols := tview.NewDropDown()
 	l5_6Protocols.SetTitle("Lδ").SetBorder(true)
 	l5_6Protocols.SetOptions(l5_6s, func(text string, index int) {
-		switchProtocol("L5/6")(text, l5_6s)
+		switchProtocol(packemon.LAYER_L5_6)(text, l5_6s)
 	})
-	l5_6Protocols.SetCurrentOption(0)
+	l5_6Protocols.SetCurrentOption(optionIndex(l5_6s, ""))
 
-	l4s := []string{"", "ICMP", "TCP", "UDP"}
+	l4s := g.protocolOptions(packemon.LAYER_L4, true)
 	l4Protocols := tview.NewDropDown()
 	l4Protocols.SetTitle("Lγ").SetBorder(true)
 	l4Protocols.SetOptions(l4s, func(text string, index int) {
-		switchProtocol("L4")(text, l4s)
+		switchProtocol(packemon.LAYER_L4)(text, l4s)
 	})
-	l4Protocols.SetCurrentOption(3)
+	l4Protocols.SetCurrentOption(optionIndex(l4s, "UDP"))
 
-	l3s := []string{"", "ARP", "IPv4", "IPv6"}
+	l3s := g.protocolOptions(packemon.LAYER_L3, true)
 	l3Protocols := tview.NewDropDown()
 	l3Protocols.SetTitle("Lβ").SetBorder(true)
 	l3Protocols.SetOptions(l3s, func(text string, index int) {
-		switchProtocol("L3")(text, l3s)
+		switchProtocol(packemon.LAYER_L3)(text, l3s)
 	})
-	l3Protocols.SetCurrentOption(2)
+	l3Protocols.SetCurrentOption(optionIndex(l3s, "IPv4"))
 
-	l2s := []string{"Ethernet"}
+	l2s := g.protocolOptions(packemon.LAYER_L2, false)
 	l2Protocols := tview.NewDropDown()
 	l2Protocols.SetTitle("Lα").SetBorder(true)
 	l2Protocols.SetOptions(l2s, func(text string, index int) {
-		switchProtocol("L2")(text, l2s)
+		switchProtocol(packemon.LAYER_L2)(text, l2s)
 	})
 	l2Protocols.SetCurrentOption(0)
 
@@ -333,7 +336,7 @@ func defaultPackets() (*packets, error) {
 		Class: binary.BigEndian.Uint16(dnsQueriesClass),
 	}
 	dns.Queries = queries
-	dns.Domain("go.dev")
+	dns.Domain(DEFAULT_DNS_QUERIES_DOMAIN)
 
 	udpSrcPort, err := packemon.StrIntToUint16(DEFAULT_UDP_PORT_SOURCE)
 	if err != nil {
@@ -421,18 +424,10 @@ func defaultPackets() (*packets, error) {
 		return nil, err
 	}
 	
-	// Create timestamp data similar to ping
-	timestamp := func() []byte {
-		now := time.Now().Unix()
-		b := make([]byte, 4)
-		binary.LittleEndian.PutUint32(b, uint32(now))
-		return binary.LittleEndian.AppendUint32(b, 0x00000000)
-	}()
-	
 	icmpv6Echo := &packemon.ICMPv6Echo{
 		Identifier:    binary.BigEndian.Uint16(icmpv6Identifier),
 		SequenceNumber: binary.BigEndian.Uint16(icmpv6Sequence),
-		Data:          timestamp,
+		Data:          packemon.EchoTimestamp(time.Now()),
 	}
 	
 	// Create echo message body
@@ -456,6 +451,10 @@ func defaultPackets() (*packets, error) {
 	if err != nil {
 		return nil, err
 	}
+	ttl, err := strHexToUint8(DEFAULT_IP_TTL)
+	if err != nil {
+		return nil, err
+	}
 
 	ipv4 := &packemon.IPv4{
 		Version:        0x04,
@@ -465,7 +464,7 @@ func defaultPackets() (*packets, error) {
 		Identification: 0xe31f,
 		Flags:          0x40,
 		FragmentOffset: 0x0,
-		Ttl:            0x80,
+		Ttl:            ttl,
 		Protocol:       packemon.IPv4_PROTO_UDP,
 		HeaderChecksum: 0,
 		SrcAddr:        binary.BigEndian.Uint32(srcIP),
@@ -481,6 +480,11 @@ func defaultPackets() (*packets, error) {
 		return nil, err
 	}
 
+	hopLimit, err := strHexToUint8(DEFAULT_IPv6_HOP_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+
 	ipv6 := &packemon.IPv6{
 		Version:      0x06,
 		TrafficClass: 0x00,
@@ -488,7 +492,7 @@ func defaultPackets() (*packets, error) {
 		FlowLabel:     0x00000,
 		PayloadLength: 0x0000,
 		NextHeader:    packemon.IPv6_NEXT_HEADER_ICMPv6,
-		HopLimit:      0x40,
+		HopLimit:      hopLimit,
 		SrcAddr:       srcIPv6.To16(),
 		DstAddr:       dstIPv6.To16(),
 	}
@@ -592,3 +596,28 @@ func strHexToUint8(s string) (uint8, error) {
 	}
 	return uint8(n), nil
 }
+
+// protocolOptions returns the protocols of the layer that can be generated and have a form.
+// With skippable, "" is prepended so the layer can be left unselected.
+// フォームがあり生成できるプロトコルを返します。skippableの場合は未選択用に "" を先頭に加えます
+func (g *generator) protocolOptions(layer string, skippable bool) []string {
+	options := []string{}
+	if skippable {
+		options = append(options, "")
+	}
+	for _, name := range packemon.GeneratableProtocols(layer) {
+		if g.pages.HasPage(name) {
+			options = append(options, name)
+		}
+	}
+	return options
+}
+
+func optionIndex(options []string, name string) int {
+	for i, option := range options {
+		if option == name {
+			return i
+		}
+	}
+	return 0
+}
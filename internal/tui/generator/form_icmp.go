@@ -76,6 +76,9 @@ func (g *generator) icmpForm() *tview.Form {
 				g.addErrPage(err)
 			}
 		}).
+		AddButton("Validate", func() {
+			g.addChecksumPage(g.sender.validate("ICMP"))
+		}).
 		AddButton("Quit", func() {
 			g.app.Stop()
 		})
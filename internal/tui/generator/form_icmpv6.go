@@ -3,6 +3,7 @@ package generator
 import (
 	"context"
 	"encoding/binary"
+	"net"
 
 	"github.com/ddddddO/packemon"
 	"github.com/rivo/tview"
@@ -14,6 +15,7 @@ func (g *generator) icmpv6Form() *tview.Form {
 		AddDropDown("Type", []string{
 			"Echo Request (128)",
 			"Router Solicitation (133)",
+			"Router Advertisement (134)",
 			"Neighbor Solicitation (135)",
 		}, 0, func(option string, optionIndex int) {
 			switch optionIndex {
@@ -22,6 +24,13 @@ func (g *generator) icmpv6Form() *tview.Form {
 			case 1:
 				g.sender.packets.icmpv6.Type = packemon.ICMPv6_TYPE_ROUTER_SOLICITATION
 			case 2:
+				ra, err := defaultRouterAdvertisement(g.sender.packets.ethernet.Src)
+				if err != nil {
+					g.addErrPage(err)
+					return
+				}
+				g.sender.packets.icmpv6 = ra
+			case 3:
 				g.sender.packets.icmpv6.Type = packemon.ICMPv6_TYPE_NEIGHBOR_SOLICITATION
 			}
 		}).
@@ -81,9 +90,34 @@ func (g *generator) icmpv6Form() *tview.Form {
 				g.addErrPage(err)
 			}
 		}).
+		AddButton("Validate", func() {
+			g.addChecksumPage(g.sender.validate("ICMPv6"))
+		}).
 		AddButton("Quit", func() {
 			g.app.Stop()
 		})
 
 	return icmpv6Form
 }
+
+// SLAAC の動作確認用に、ドキュメント用プレフィックスを広告する RA
+func defaultRouterAdvertisement(srcMAC packemon.HardwareAddr) (*packemon.ICMPv6, error) {
+	prefix, prefixLength, err := parseIPv6Prefix(DEFAULT_ICMPv6_RA_PREFIX)
+	if err != nil {
+		return nil, err
+	}
+	return packemon.NewICMPv6RouterAdvertisement(64, 0, 1800, 0, 0, []packemon.NDPOption{
+		packemon.NewNDPSourceLinkLayerAddressOption(net.HardwareAddr(srcMAC[:])),
+		packemon.NewNDPMTUOption(1500),
+		packemon.NewNDPPrefixInformationOption(prefix, prefixLength, packemon.NDP_PREFIX_FLAG_ON_LINK|packemon.NDP_PREFIX_FLAG_AUTONOMOUS, 86400, 14400),
+	})
+}
+
+func parseIPv6Prefix(s string) (net.IP, uint8, error) {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	ones, _ := ipNet.Mask.Size()
+	return ipNet.IP, uint8(ones), nil
+}
@@ -72,6 +72,9 @@ func (g *generator) ipv4Form() *tview.Form {
 				g.addErrPage(err)
 			}
 		}).
+		AddButton("Validate", func() {
+			g.addChecksumPage(g.sender.validate("IPv4"))
+		}).
 		AddButton("Quit", func() {
 			g.app.Stop()
 		})
@@ -68,6 +68,9 @@ func (g *generator) tcpForm() *tview.Form {
 				g.addErrPage(err)
 			}
 		}).
+		AddButton("Validate", func() {
+			g.addChecksumPage(g.sender.validate("TCP"))
+		}).
 		AddButton("Quit", func() {
 			g.app.Stop()
 		})
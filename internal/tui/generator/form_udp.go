@@ -62,6 +62,9 @@ func (g *generator) udpForm() *tview.Form {
 				g.addErrPage(err)
 			}
 		}).
+		AddButton("Validate", func() {
+			g.addChecksumPage(g.sender.validate("UDP"))
+		}).
 		AddButton("Quit", func() {
 			g.app.Stop()
 		})
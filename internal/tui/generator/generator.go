@@ -2,6 +2,7 @@ package generator
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cilium/ebpf"
 	"github.com/ddddddO/packemon"
@@ -54,3 +55,26 @@ func (g *generator) Run(ctx context.Context) error {
 func (g *generator) addErrPage(err error) {
 	g.pages.AddPage("ERROR", tui.ErrView(err, g.app), true, true)
 }
+
+func (g *generator) addChecksumPage(report packemon.ChecksumReport) {
+	textview := tview.NewTextView().
+		SetDynamicColors(true).
+		SetChangedFunc(func() {
+			g.app.Draw()
+		})
+	textview.SetBorder(true).SetTitle("Checksum")
+	if len(report) == 0 {
+		fmt.Fprint(textview, " No checksum to validate\n")
+	}
+	for _, result := range report {
+		color := "green"
+		switch {
+		case result.Unused:
+			color = "white"
+		case !result.Valid():
+			color = "red"
+		}
+		fmt.Fprintf(textview, " [%s]%s\n", color, result)
+	}
+	g.pages.AddPage("CHECKSUM", textview, true, true)
+}
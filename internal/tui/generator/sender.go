@@ -33,6 +33,97 @@ func newSender(packets *packets, sendFn func(*packemon.EthernetFrame) error) *se
 	}
 }
 
+// validate reports whether the checksums set in the layer of the form of protocol, and in the IPv4 header below it, are correct.
+// The correct values are computed on copies of the layers, so the packet being edited is left as it is
+// protocolのフォームのレイヤと、その下のIPv4ヘッダに設定されているチェックサムが正しいかを返します。
+// 正しい値はレイヤのコピーで計算するため、編集中のパケットはそのままです
+func (s *sender) validate(protocol string) packemon.ChecksumReport {
+	report := packemon.ChecksumReport{}
+	selectedL3 := s.selectedProtocolByLayer["L3"]
+	if selectedL3 == "IPv4" && protocol != "ICMPv6" {
+		ipv4 := cloneIPv4(s.packets.ipv4)
+		ipv4.HeaderChecksum = 0x0
+		ipv4.CalculateChecksum()
+		report = append(report, packemon.ChecksumResult{Layer: "IPv4", Stored: s.packets.ipv4.HeaderChecksum, Computed: ipv4.HeaderChecksum})
+	}
+
+	switch protocol {
+	case "ICMP":
+		icmp := *s.packets.icmpv4
+		icmp.Data = bytes.Clone(icmp.Data)
+		icmp.Checksum = 0x0
+		b := make([]byte, 2)
+		binary.LittleEndian.PutUint16(b, icmp.CalculateChecksum())
+		report = append(report, packemon.ChecksumResult{Layer: "ICMP", Stored: s.packets.icmpv4.Checksum, Computed: binary.BigEndian.Uint16(b)})
+	case "ICMPv6":
+		icmpv6 := *s.packets.icmpv6
+		icmpv6.MessageBody = bytes.Clone(icmpv6.MessageBody)
+		if icmpv6.Type == packemon.ICMPv6_TYPE_ECHO_REQUEST && s.packets.icmpv6Echo != nil {
+			icmpv6.MessageBody = icmpv6EchoBody(s.packets.icmpv6Echo)
+		}
+		report = append(report, packemon.ChecksumResult{
+			Layer:    "ICMPv6",
+			Stored:   s.packets.icmpv6.Checksum,
+			Computed: icmpv6.CalculateChecksum(s.packets.ipv6.SrcAddr, s.packets.ipv6.DstAddr),
+		})
+	case "UDP":
+		udp := *s.packets.udp
+		udp.Data = bytes.Clone(udp.Data)
+		udp.Checksum = 0x0000
+		switch selectedL3 {
+		case "IPv4":
+			udp.CalculateChecksum(cloneIPv4(s.packets.ipv4))
+			// IPv4 上の UDP は送信時にチェックサムを計算しない
+			report = append(report, packemon.ChecksumResult{Layer: "UDP", Stored: s.packets.udp.Checksum, Computed: udp.Checksum, Unused: s.packets.udp.Checksum == 0})
+		case "IPv6":
+			udp.CalculateChecksumForIPv6(cloneIPv6(s.packets.ipv6))
+			report = append(report, packemon.ChecksumResult{Layer: "UDP", Stored: s.packets.udp.Checksum, Computed: udp.Checksum})
+		}
+	case "TCP":
+		tcp := *s.packets.tcp
+		tcp.Options = bytes.Clone(tcp.Options)
+		tcp.Data = bytes.Clone(tcp.Data)
+		tcp.Checksum = 0x0000
+		switch selectedL3 {
+		case "IPv4":
+			tcp.CalculateChecksum(cloneIPv4(s.packets.ipv4))
+		case "IPv6":
+			tcp.CalculateChecksumForIPv6(cloneIPv6(s.packets.ipv6))
+		default:
+			return report
+		}
+		report = append(report, packemon.ChecksumResult{Layer: "TCP", Stored: s.packets.tcp.Checksum, Computed: tcp.Checksum})
+	}
+	return report
+}
+
+// チェックサムの計算で編集中のパケットを変えないためのコピー
+func cloneIPv4(ipv4 *packemon.IPv4) *packemon.IPv4 {
+	c := *ipv4
+	c.Options = bytes.Clone(ipv4.Options)
+	c.Padding = bytes.Clone(ipv4.Padding)
+	c.Data = bytes.Clone(ipv4.Data)
+	return &c
+}
+
+func cloneIPv6(ipv6 *packemon.IPv6) *packemon.IPv6 {
+	c := *ipv6
+	c.SrcAddr = bytes.Clone(ipv6.SrcAddr)
+	c.DstAddr = bytes.Clone(ipv6.DstAddr)
+	c.Option = bytes.Clone(ipv6.Option)
+	c.Data = bytes.Clone(ipv6.Data)
+	return &c
+}
+
+// ICMPv6 echo request のメッセージ本文
+func icmpv6EchoBody(echo *packemon.ICMPv6Echo) []byte {
+	echoBuf := &bytes.Buffer{}
+	packemon.WriteUint16(echoBuf, echo.Identifier)
+	packemon.WriteUint16(echoBuf, echo.SequenceNumber)
+	echoBuf.Write(echo.Data)
+	return echoBuf.Bytes()
+}
+
 func (s *sender) sendLayer2(ctx context.Context) error {
 	return s.send(ctx, "L2")
 }
@@ -58,14 +149,8 @@ func (s *sender) sendLayer4IPv6(ctx context.Context) error {
 
 	// Create ICMPv6 echo request body if needed
 	if s.packets.icmpv6.Type == packemon.ICMPv6_TYPE_ECHO_REQUEST && s.packets.icmpv6Echo != nil {
-		// Convert echo to bytes
-		echoBuf := &bytes.Buffer{}
-		packemon.WriteUint16(echoBuf, s.packets.icmpv6Echo.Identifier)
-		packemon.WriteUint16(echoBuf, s.packets.icmpv6Echo.SequenceNumber)
-		echoBuf.Write(s.packets.icmpv6Echo.Data)
-		
 		// Set the message body
-		s.packets.icmpv6.MessageBody = echoBuf.Bytes()
+		s.packets.icmpv6.MessageBody = icmpv6EchoBody(s.packets.icmpv6Echo)
 	}
 	
 	// Calculate ICMPv6 checksum (requires source and destination IPv6 addresses)
@@ -74,19 +159,39 @@ func (s *sender) sendLayer4IPv6(ctx context.Context) error {
 		s.packets.ipv6.DstAddr,
 	)
 
-	// Set IPv6 data and payload length
-	s.packets.ipv6.Data = s.packets.icmpv6.Bytes()
-	s.packets.ipv6.PayloadLength = uint16(len(s.packets.ipv6.Data))
+	// Build the IPv6 packet with the payload length set from the ICMPv6 message
+	data, err := s.ipv6Bytes(s.packets.icmpv6.Bytes())
+	if err != nil {
+		return err
+	}
 	
 	// Create Ethernet frame with IPv6 data
 	ethernetFrame := &packemon.EthernetFrame{
 		Header: s.packets.ethernet,
-		Data:   s.packets.ipv6.Bytes(),
+		Data:   data,
 	}
 	
 	return s.sendFn(ethernetFrame)
 }
 
+// フォームの IPv6 ヘッダーで payload を運ぶ IPv6 パケット. ペイロード長は payload から設定する
+func (s *sender) ipv6Bytes(payload []byte) ([]byte, error) {
+	ipv6, err := packemon.NewIPv6WithPayload(
+		s.packets.ipv6.SrcAddr,
+		s.packets.ipv6.DstAddr,
+		s.packets.ipv6.NextHeader,
+		payload,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// フォームで入力した値は引き継ぐ
+	ipv6.TrafficClass = s.packets.ipv6.TrafficClass
+	ipv6.FlowLabel = s.packets.ipv6.FlowLabel
+	ipv6.HopLimit = s.packets.ipv6.HopLimit
+	return ipv6.Bytes(), nil
+}
+
 func (s *sender) sendLayer7(ctx context.Context) error {
 	return s.send(ctx, "L7")
 }
@@ -136,13 +241,7 @@ func (s *sender) send(ctx context.Context, currentLayer string) (err error) {
 	case "L4":
 		switch selectedL4 {
 		case "ICMP":
-			// TODO: timestamp関数化
-			s.packets.icmpv4.Data = func() []byte {
-				now := time.Now().Unix()
-				b := make([]byte, 4)
-				binary.LittleEndian.PutUint32(b, uint32(now))
-				return binary.LittleEndian.AppendUint32(b, 0x00000000)
-			}()
+			s.packets.icmpv4.Data = packemon.EchoTimestamp(time.Now())
 			// 前回Send分が残ってると計算誤るため
 			s.packets.icmpv4.Checksum = 0x0
 			s.packets.icmpv4.Checksum = func() uint16 {
@@ -195,9 +294,11 @@ func (s *sender) send(ctx context.Context, currentLayer string) (err error) {
 				ethernetFrame.Data = s.packets.ipv4.Bytes()
 			case "IPv6":
 				s.packets.udp.CalculateChecksumForIPv6(s.packets.ipv6)
-				s.packets.ipv6.Data = s.packets.udp.Bytes()
-				s.packets.ipv6.PayloadLength = uint16(len(s.packets.ipv6.Data))
-				ethernetFrame.Data = s.packets.ipv6.Bytes()
+				data, err := s.ipv6Bytes(s.packets.udp.Bytes())
+				if err != nil {
+					return err
+				}
+				ethernetFrame.Data = data
 			case "ARP":
 				return fmt.Errorf("unsupported under ARP")
 			default:
@@ -225,9 +326,11 @@ func (s *sender) send(ctx context.Context, currentLayer string) (err error) {
 				ethernetFrame.Data = s.packets.ipv4.Bytes()
 			case "IPv6":
 				s.packets.tcp.CalculateChecksumForIPv6(s.packets.ipv6)
-				s.packets.ipv6.Data = s.packets.tcp.Bytes()
-				s.packets.ipv6.PayloadLength = uint16(len(s.packets.ipv6.Data))
-				ethernetFrame.Data = s.packets.ipv6.Bytes()
+				data, err := s.ipv6Bytes(s.packets.tcp.Bytes())
+				if err != nil {
+					return err
+				}
+				ethernetFrame.Data = data
 			case "ARP":
 				return fmt.Errorf("unsupported under ARP")
 			default:
@@ -268,9 +371,11 @@ func (s *sender) send(ctx context.Context, currentLayer string) (err error) {
 						ethernetFrame.Data = s.packets.ipv4.Bytes()
 					case "IPv6":
 						s.packets.udp.CalculateChecksumForIPv6(s.packets.ipv6)
-						s.packets.ipv6.Data = s.packets.udp.Bytes()
-						s.packets.ipv6.PayloadLength = uint16(len(s.packets.ipv6.Data))
-						ethernetFrame.Data = s.packets.ipv6.Bytes()
+						data, err := s.ipv6Bytes(s.packets.udp.Bytes())
+						if err != nil {
+							return err
+						}
+						ethernetFrame.Data = data
 					case "ARP":
 						return fmt.Errorf("unsupported under ARP")
 					default:
@@ -326,9 +431,11 @@ func (s *sender) send(ctx context.Context, currentLayer string) (err error) {
 							ethernetFrame.Data = s.packets.ipv4.Bytes()
 						case "IPv6":
 							s.packets.tcp.CalculateChecksumForIPv6(s.packets.ipv6)
-							s.packets.ipv6.Data = s.packets.tcp.Bytes()
-							s.packets.ipv6.PayloadLength = uint16(len(s.packets.ipv6.Data))
-							ethernetFrame.Data = s.packets.ipv6.Bytes()
+							data, err := s.ipv6Bytes(s.packets.tcp.Bytes())
+							if err != nil {
+								return err
+							}
+							ethernetFrame.Data = data
 						case "ARP":
 							return fmt.Errorf("unsupported under ARP")
 						default:
@@ -404,9 +511,11 @@ func (s *sender) send(ctx context.Context, currentLayer string) (err error) {
 							ethernetFrame.Data = s.packets.ipv4.Bytes()
 						case "IPv6":
 							s.packets.tcp.CalculateChecksumForIPv6(s.packets.ipv6)
-							s.packets.ipv6.Data = s.packets.tcp.Bytes()
-							s.packets.ipv6.PayloadLength = uint16(len(s.packets.ipv6.Data))
-							ethernetFrame.Data = s.packets.ipv6.Bytes()
+							data, err := s.ipv6Bytes(s.packets.tcp.Bytes())
+							if err != nil {
+								return err
+							}
+							ethernetFrame.Data = data
 						case "ARP":
 							return fmt.Errorf("unsupported under protocol: %s", selectedL3)
 						default:
@@ -9,6 +9,8 @@ import (
 
 type filter struct {
 	value string // Monitor の Filter 入力欄の文字列
+	// display は起動時に指定された表示フィルター. 一致しないパケットは value に関わらず表示しない
+	display *packemon.DisplayFilter
 }
 
 func newFilter() *filter {
@@ -16,6 +18,9 @@ func newFilter() *filter {
 }
 
 func (f *filter) contains(passive *packemon.Passive) bool {
+	if f.display != nil && !f.display.Match(passive) {
+		return false
+	}
 	// filter 文字列が空ならすべて表示
 	if len(strings.TrimSpace(f.value)) == 0 {
 		return true
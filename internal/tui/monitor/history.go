@@ -6,24 +6,36 @@ import (
 	"time"
 
 	"github.com/ddddddO/packemon"
+	"github.com/ddddddO/packemon/internal/tui"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
 func (m *monitor) updateTable() {
-	var id uint64 = 0
+	// セッションのキャプチャから読み込んだパケットの続きから
+	id := m.loaded
 	for passive := range m.passiveCh {
 		time.Sleep(10 * time.Millisecond)
 
 		m.app.QueueUpdateDraw(func() {
-			m.storedPackets.Store(id, passive)
-			m.filterAndInsertToTable(passive, id)
-			m.storedMaxID.set(id)
+			m.storePacket(passive, id)
 			atomic.AddUint64(&id, 1)
 		})
 	}
 }
 
+func (m *monitor) storePacket(passive *packemon.Passive, id uint64) {
+	m.storedPackets.Store(id, passive)
+	if alerts := m.dnsDetector.Observe(passive, passive.Timestamp); len(alerts) > 0 {
+		m.dnsAlerts.Store(id, alerts)
+	}
+	if alert := m.tunnelDetector.Observe(passive, passive.Timestamp); alert != nil {
+		m.tunnelAlerts.Store(id, alert)
+	}
+	m.filterAndInsertToTable(passive, id)
+	m.storedMaxID.set(id)
+}
+
 func (m *monitor) reCreateTable() {
 	// 一回クリア
 	m.table.Clear()
@@ -50,7 +62,19 @@ func (m *monitor) filterAndInsertToTable(passive *packemon.Passive, id uint64) {
 	}
 
 	if m.filter.contains(passive) {
-		m.insertToTable(m.newHistoryRow(passive, id))
+		r := m.newHistoryRow(passive, id)
+		// 最初に一致した色付けルールの色で行の背景を塗る
+		if color := m.coloringRules.Color(passive); color != "" {
+			r.setBackgroundColor(tcell.GetColor(color))
+		}
+		// 偽装の疑いがあるDNSレスポンスは色付けルールより優先して目立たせる
+		if _, ok := m.dnsAlerts.Load(id); ok {
+			r.setBackgroundColor(m.color(packemon.THEME_ROLE_ALERT))
+		}
+		if _, ok := m.tunnelAlerts.Load(id); ok {
+			r.setBackgroundColor(m.color(packemon.THEME_ROLE_ALERT))
+		}
+		m.insertToTable(r)
 	}
 }
 
@@ -104,15 +128,40 @@ type HistoryRow struct {
 	destinationIPAddr *tview.TableCell
 }
 
+func (r *HistoryRow) setBackgroundColor(color tcell.Color) {
+	for _, cell := range []*tview.TableCell{r.id, r.destinationMAC, r.sourceMAC, r.typ, r.protocol, r.sourceIPAddr, r.destinationIPAddr} {
+		cell.SetBackgroundColor(color)
+	}
+}
+
 func (m *monitor) newHistoryRow(passive *packemon.Passive, id uint64) *HistoryRow {
 	r := &HistoryRow{
-		id:             tview.NewTableCell(fmt.Sprintf("%d", id)).SetTextColor(tcell.ColorWhite),
+		id:             tview.NewTableCell(fmt.Sprintf("%d", id)).SetTextColor(m.color(packemon.THEME_ROLE_TEXT)),
 		destinationMAC: tview.NewTableCell(fmt.Sprintf("Dst:%x", passive.EthernetFrame.Header.Dst)).SetTextColor(tcell.Color38),
 		sourceMAC:      tview.NewTableCell(fmt.Sprintf("Src:%x", passive.EthernetFrame.Header.Src)).SetTextColor(tcell.Color48),
 		typ:            tview.NewTableCell(fmt.Sprintf("Type:%x", passive.EthernetFrame.Header.Typ)).SetTextColor(tcell.Color98),
 	}
 
-	r.protocol = tview.NewTableCell(fmt.Sprintf("Proto:%s", passive.HighLayerProto())).SetTextColor(tcell.Color50)
+	highLayerProto := passive.HighLayerProto()
+	proto := fmt.Sprintf("Proto:%s", highLayerProto)
+	if passive.Truncated {
+		proto += " [truncated]"
+	}
+	for _, layerErr := range passive.Errors {
+		proto += fmt.Sprintf(" [%s error]", layerErr.Layer)
+	}
+	if value, ok := m.dnsAlerts.Load(id); ok {
+		for _, alert := range value.([]packemon.DNSAlert) {
+			proto += fmt.Sprintf(" [%s]", alert.Kind)
+		}
+	}
+	if _, ok := m.tunnelAlerts.Load(id); ok {
+		proto += " [dns tunnel]"
+	}
+	if note, ok := m.annotations.Note(annotationNumber(id)); ok {
+		proto += fmt.Sprintf(" [note: %s]", tview.Escape(note))
+	}
+	r.protocol = tview.NewTableCell(proto).SetTextColor(tui.ProtocolColor(m.theme, highLayerProto))
 
 	if passive.IPv4 != nil {
 		viewIPv4 := &IPv4{passive.IPv4}
@@ -26,6 +26,25 @@ type monitor struct {
 	filterInput *tview.Grid
 	filter      *filter
 	pages       *tview.Pages
+
+	coloringRules *packemon.ColoringRules
+	theme         *packemon.Theme
+
+	// パケットへの注釈. パケットの受信とは別に、詳細画面から付ける
+	annotations *packemon.Annotations
+
+	// config はセッションの保存先. session は開き直したセッションで、無ければnil
+	config  *packemon.Config
+	session *packemon.Session
+	// loaded はセッションのキャプチャから読み込んだパケットの数. 受信したパケットの ID はその続きから振る
+	loaded uint64
+
+	// 偽装の疑いがあるDNSレスポンスをパケットのIDごとに保持する
+	dnsDetector *packemon.DNSMismatchDetector
+	dnsAlerts   sync.Map
+	// DNSトンネリングの疑いがあるドメインへのクエリをパケットのIDごとに保持する
+	tunnelDetector *packemon.DNSTunnelDetector
+	tunnelAlerts   sync.Map
 }
 
 type storedMaxID struct {
@@ -62,7 +81,7 @@ func New(networkInterface *packemon.NetworkInterface, columns string) *monitor {
 	grid.AddItem(pages, 1, 0, 2, 1, 5, 1, true)
 
 	footer := tview.NewTextView().
-		SetText("Focus on packet list and press Enter to selectable mode | Press Esc to return").
+		SetText("Focus on packet list and press Enter to selectable mode | Press Esc to return | Press Ctrl-S to save the session").
 		SetTextAlign(tview.AlignLeft)
 	footer.SetBorderPadding(0, 0, 1, 1)
 
@@ -81,12 +100,70 @@ func New(networkInterface *packemon.NetworkInterface, columns string) *monitor {
 		filterInput:   filterInput,
 		filter:        newFilter(),
 		pages:         pages,
+		annotations:   packemon.NewAnnotations(),
+		dnsDetector:   packemon.NewDNSMismatchDetector(0, 0),
+
+		tunnelDetector: packemon.NewDNSTunnelDetector(0, 0),
+	}
+}
+
+// SetColoringRules sets the rules used to color the rows of the packet list
+// パケット一覧の行の色付けに使うルールを設定します
+func (m *monitor) SetColoringRules(rules *packemon.ColoringRules) {
+	m.coloringRules = rules
+}
+
+// SetSession sets the config sessions are saved to with Ctrl-S, and the session re-opened or nil.
+// Saving keeps the name and the coloring rules of the re-opened session
+// Ctrl-Sでセッションを保存する設定と、開き直したセッション(無ければnil)を設定します。
+// 保存時は開き直したセッションの名前と色付けルールを引き継ぎます
+func (m *monitor) SetSession(config *packemon.Config, session *packemon.Session) {
+	m.config = config
+	m.session = session
+}
+
+// LoadPackets lists packets read from a capture, e.g. the one of a re-opened session, before the received ones.
+// The annotations are numbered by the position of the packets from 1. Call it before Run
+// キャプチャから読み込んだパケット(開き直したセッションのものなど)を、受信するパケットより前に一覧に表示します。
+// 注釈はパケットの1から始まる位置で番号付けされたものです。Runの前に呼び出します
+func (m *monitor) LoadPackets(packets []*packemon.Passive, annotations *packemon.Annotations) {
+	for id, passive := range packets {
+		m.storePacket(passive, uint64(id))
 	}
+	m.loaded = uint64(len(packets))
+	m.annotations = annotations
+}
+
+// SetDisplayFilter sets a display filter expression the listed packets must match, in addition to the Filter input.
+// nil lists every packet
+// 一覧に表示するパケットが、Filter入力欄に加えて一致する必要がある表示フィルターを設定します。nilの場合はすべて表示します
+func (m *monitor) SetDisplayFilter(f *packemon.DisplayFilter) {
+	m.filter.display = f
+}
+
+// SetTheme sets the theme of the packet list's colors. Call tui.ApplyTheme before New for the other views
+// パケット一覧の色のテーマを設定します。他のビューにはNewの前にtui.ApplyThemeを呼び出します
+func (m *monitor) SetTheme(theme *packemon.Theme) {
+	m.theme = theme
+}
+
+func (m *monitor) color(role string) tcell.Color {
+	return tui.ThemeColor(m.theme, role)
 }
 
 func (m *monitor) Run(ctx context.Context) error {
 	go m.networkInterface.Recieve(ctx)
 
+	if m.config != nil {
+		m.table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyCtrlS {
+				m.pages.AddPage("session", m.savingSessionView(), true, true)
+				return nil
+			}
+			return event
+		})
+	}
+
 	m.table.Select(0, 0).SetFixed(1, 1).SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEscape {
 			m.table.SetSelectable(false, false)
@@ -94,9 +171,9 @@ func (m *monitor) Run(ctx context.Context) error {
 		if key == tcell.KeyEnter {
 			m.table.SetSelectable(true, false)
 		}
-	}).SetSelectedStyle(tcell.Style{}.Background(tcell.ColorRed)).SetSelectedFunc(func(row int, column int) {
+	}).SetSelectedStyle(tcell.Style{}.Background(m.color(packemon.THEME_ROLE_CURSOR))).SetSelectedFunc(func(row int, column int) {
 		for i := range m.table.GetColumnCount() {
-			m.table.GetCell(row, i).SetBackgroundColor(tcell.ColorGray)
+			m.table.GetCell(row, i).SetBackgroundColor(m.color(packemon.THEME_ROLE_SELECTION))
 		}
 
 		selectedCell := m.table.GetCell(row, 0)
@@ -105,7 +182,7 @@ func (m *monitor) Run(ctx context.Context) error {
 			return
 		}
 		if p, ok := m.storedPackets.Load(id); ok {
-			m.updateView(p.(*packemon.Passive))
+			m.updateView(p.(*packemon.Passive), id)
 		}
 	})
 
@@ -128,7 +205,7 @@ func (m *monitor) Run(ctx context.Context) error {
 		m.reCreateTable()
 	})
 
-	filterClearButton.SetStyle(tcell.Style{}.Foreground(tcell.ColorWhiteSmoke).Background(tcell.ColorGray))
+	filterClearButton.SetStyle(tcell.Style{}.Foreground(m.color(packemon.THEME_ROLE_TEXT)).Background(m.color(packemon.THEME_ROLE_SELECTION)))
 	filterLayout := tview.NewGrid().
 		AddItem(filterInput, 0, 0, 1, 4, 0, 0, true).
 		AddItem(filterOKButton, 0, 5, 1, 1, 0, 0, false).
@@ -16,7 +16,7 @@ type Viewer interface {
 	viewTable() *tview.Table
 }
 
-func (m *monitor) updateView(passive *packemon.Passive) {
+func (m *monitor) updateView(passive *packemon.Passive, id uint64) {
 	go func(viewers []Viewer) {
 		defer func() {
 			if e := recover(); e != nil {
@@ -34,9 +34,9 @@ func (m *monitor) updateView(passive *packemon.Passive) {
 
 		// m.grid.RemoveItem(m.grid) // ほんと？
 
-		// +1 分は、PCAP保存領域用(savingPCAPView)
-		rows := make([]int, len(viewers)+1)
-		columns := make([]int, len(viewers)+1)
+		// +2 分は、PCAP保存領域用(savingPCAPView)と注釈用(annotationView)
+		rows := make([]int, len(viewers)+2)
+		columns := make([]int, len(viewers)+2)
 		for i := range viewers {
 			rows[i] = viewers[i].rows()
 			columns[i] = viewers[i].columns()
@@ -49,9 +49,10 @@ func (m *monitor) updateView(passive *packemon.Passive) {
 		for i := range viewers {
 			packetDetail.AddItem(viewers[i].viewTable(), i, 0, 1, 3, 0, 0, false) // focus=true にするとスクロールしない
 		}
-		savingPCAPView := m.savingPCAPView(passive)
+		savingPCAPView := m.savingPCAPView(passive, id)
 		row := len(viewers)
 		packetDetail.AddItem(savingPCAPView, row, 0, 1, 3, 0, 0, false)
+		packetDetail.AddItem(m.annotationView(id), row+1, 0, 1, 3, 0, 0, false)
 
 		packetDetail.SetInputCapture(
 			func(event *tcell.EventKey) *tcell.EventKey {
@@ -0,0 +1,33 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+)
+
+// パケットに注釈を付けるフォーム. 注釈はパケット一覧の Proto 列に表示する
+func (m *monitor) annotationView(id uint64) *tview.Form {
+	note, _ := m.annotations.Note(annotationNumber(id))
+	limitLength := 80
+
+	form := tview.NewForm().
+		AddInputField("Note", note, limitLength, func(textToCheck string, lastChar rune) bool {
+			return len(textToCheck) <= limitLength
+		}, func(text string) {
+			note = text
+		}).
+		AddButton("Annotate", func() {
+			m.annotations.Annotate(annotationNumber(id), note)
+			m.reCreateTable()
+		})
+	form.SetBorder(true)
+	form.Box = tview.NewBox().SetBorder(true).SetTitle(fmt.Sprintf(" Annotate packet %d (empty to remove) ", id)).SetTitleAlign(tview.AlignLeft).SetBorderPadding(1, 1, 1, 1)
+
+	return form
+}
+
+// パケット一覧の ID は 0 から、注釈のパケット番号は 1 から数える
+func annotationNumber(id uint64) uint64 {
+	return id + 1
+}
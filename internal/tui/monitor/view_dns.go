@@ -14,7 +14,20 @@ type DNS struct {
 }
 
 func (d *DNS) rows() int {
-	return 19 + (len(d.Answers) * 7)
+	return 19 + (len(d.Answers) * 7) + d.extensionRows()
+}
+
+func (d *DNS) extensionRows() int {
+	rows := 0
+	if d.EDNS0 != nil {
+		rows += 5
+	}
+	for _, rr := range d.Records {
+		if packemon.ParsedDNSSECRecord(rr) != nil {
+			rows++
+		}
+	}
+	return rows
 }
 
 func (*DNS) columns() int {
@@ -83,6 +96,41 @@ func (d *DNS) viewTable() *tview.Table {
 		}
 	}
 
+	position := (len(d.Answers) + 1) * 9
+	if d.EDNS0 != nil {
+		table.SetCell(position, 0, tui.TableCellTitle("EDNS0 (OPT)"))
+
+		table.SetCell(position+1, 0, tui.TableCellTitle("   UDP payload size"))
+		table.SetCell(position+1, 1, tui.TableCellContent("%d", d.EDNS0.UDPPayloadSize))
+
+		table.SetCell(position+2, 0, tui.TableCellTitle("   Extended RCODE"))
+		table.SetCell(position+2, 1, tui.TableCellContent("%x", d.EDNS0.ExtendedRCode))
+
+		table.SetCell(position+3, 0, tui.TableCellTitle("   Version"))
+		table.SetCell(position+3, 1, tui.TableCellContent("%d", d.EDNS0.Version))
+
+		table.SetCell(position+4, 0, tui.TableCellTitle("   Flags"))
+		table.SetCell(position+4, 1, tui.TableCellContent("%x (DO: %t)", d.EDNS0.Flags, d.EDNS0.DNSSECOK()))
+		position += 5
+	}
+
+	for _, rr := range d.Records {
+		dnssec := packemon.ParsedDNSSECRecord(rr)
+		if dnssec == nil {
+			continue
+		}
+		table.SetCell(position, 0, tui.TableCellTitle(packemon.DNSQueryTypes[dnssec.Typ]))
+		switch dnssec.Typ {
+		case packemon.DNS_QUERY_TYPE_RRSIG:
+			table.SetCell(position, 1, tui.TableCellContent("%s covers %s, key tag %d, signer %s.", rr.Name, packemon.DNSQueryTypes[dnssec.TypeCovered], dnssec.KeyTag, dnssec.SignerName))
+		case packemon.DNS_QUERY_TYPE_NSEC:
+			table.SetCell(position, 1, tui.TableCellContent("%s next %s.", rr.Name, dnssec.NextDomain))
+		default:
+			table.SetCell(position, 1, tui.TableCellContent("%s key tag %d, algorithm %d", rr.Name, dnssec.KeyTag, dnssec.Algorithm))
+		}
+		position++
+	}
+
 	return table
 }
 
@@ -123,14 +171,10 @@ func (d *DNS) bytesToDomain() string {
 
 // TODO:
 func (d *DNS) bytesToQueryType() string {
-	switch d.Queries.Typ {
-	case packemon.DNS_QUERY_TYPE_A:
-		return "A"
-	case packemon.DNS_QUERY_TYPE_AAAA:
-		return "AAAA"
-	default:
-		return "-"
+	if typ, ok := packemon.DNSQueryTypes[d.Queries.Typ]; ok {
+		return typ
 	}
+	return "-"
 }
 
 func (d *DNS) bytesToQueryClass() string {
@@ -29,7 +29,7 @@ func (ef *EthernetFrame) viewTable() *tview.Table {
 	table.SetCell(1, 1, tui.TableCellContent("%x", ef.Header.Src))
 
 	table.SetCell(2, 0, tui.TableCellTitle("Type"))
-	table.SetCell(2, 1, tui.TableCellContent("%x", ef.Header.Typ))
+	table.SetCell(2, 1, tui.TableCellContent("%x (%s)", ef.Header.Typ, packemon.EtherTypeName(ef.Header.Typ)))
 
 	return table
 }
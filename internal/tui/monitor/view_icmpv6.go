@@ -1,6 +1,8 @@
 package monitor
 
 import (
+	"net"
+
 	"github.com/ddddddO/packemon"
 	"github.com/ddddddO/packemon/internal/tui"
 	"github.com/rivo/tview"
@@ -54,6 +56,49 @@ func (i *ICMPv6) viewTable() *tview.Table {
 		} else {
 			viewHexadecimalDump(table, 3, "Message Body", i.MessageBody)
 		}
+	} else if tooBig := packemon.ParsedICMPv6PacketTooBig(i.ICMPv6); tooBig != nil {
+		// Path MTU Discovery の調査用に MTU と元のパケットの宛先を表示
+		table.SetCell(3, 0, tui.TableCellTitle("MTU"))
+		table.SetCell(3, 1, tui.TableCellContent("%d", tooBig.MTU))
+
+		row := 4
+		if invoking := tooBig.InvokingIPv6; invoking != nil {
+			table.SetCell(row, 0, tui.TableCellTitle("Original Src"))
+			table.SetCell(row, 1, tui.TableCellContent("%s", net.IP(invoking.SrcIP)))
+			table.SetCell(row+1, 0, tui.TableCellTitle("Original Dst"))
+			table.SetCell(row+1, 1, tui.TableCellContent("%s", net.IP(invoking.DstIP)))
+			table.SetCell(row+2, 0, tui.TableCellTitle("Original Length"))
+			table.SetCell(row+2, 1, tui.TableCellContent("%d", 40+int(invoking.PayloadLen)))
+			row += 3
+		}
+		viewHexadecimalDump(table, row, "Original Packet", tooBig.Invoking)
+	} else if redirect := packemon.ParsedICMPv6Redirect(i.ICMPv6); redirect != nil {
+		// on-link のリダイレクトかどうかの調査用に転送先と宛先を表示
+		table.SetCell(3, 0, tui.TableCellTitle("Target"))
+		if redirect.OnLink() {
+			table.SetCell(3, 1, tui.TableCellContent("%s (on-link)", redirect.Target))
+		} else {
+			table.SetCell(3, 1, tui.TableCellContent("%s", redirect.Target))
+		}
+		table.SetCell(4, 0, tui.TableCellTitle("Destination"))
+		table.SetCell(4, 1, tui.TableCellContent("%s", redirect.Destination))
+
+		row := 5
+		if redirect.TargetLinkLayerAddress != nil {
+			table.SetCell(row, 0, tui.TableCellTitle("Target MAC"))
+			table.SetCell(row, 1, tui.TableCellContent("%s", redirect.TargetLinkLayerAddress))
+			row++
+		}
+		if redirected := redirect.RedirectedIPv6; redirected != nil {
+			table.SetCell(row, 0, tui.TableCellTitle("Redirected Src"))
+			table.SetCell(row, 1, tui.TableCellContent("%s", net.IP(redirected.SrcIP)))
+			table.SetCell(row+1, 0, tui.TableCellTitle("Redirected Dst"))
+			table.SetCell(row+1, 1, tui.TableCellContent("%s", net.IP(redirected.DstIP)))
+			row += 2
+		}
+		if redirect.RedirectedHeader != nil {
+			viewHexadecimalDump(table, row, "Redirected Header", redirect.RedirectedHeader)
+		}
 	} else if i.Type == packemon.ICMPv6_TYPE_ROUTER_ADVERTISEMENT {
 		// Display Router Advertisement specific fields
 		table.SetCell(3, 0, tui.TableCellTitle("Router Advertisement"))
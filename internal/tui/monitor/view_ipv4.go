@@ -47,7 +47,7 @@ func (i *IPv4) viewTable() *tview.Table {
 	table.SetCell(7, 1, tui.TableCellContent("%d", i.Ttl))
 
 	table.SetCell(8, 0, tui.TableCellTitle("Protocol"))
-	table.SetCell(8, 1, tui.TableCellContent("%x (%s)", i.Protocol, packemon.IPv4Protocols[i.Protocol]))
+	table.SetCell(8, 1, tui.TableCellContent("%x (%s)", i.Protocol, packemon.ProtocolName(i.Protocol)))
 
 	table.SetCell(9, 0, tui.TableCellTitle("Header Checksum"))
 	table.SetCell(9, 1, tui.TableCellContent("%x", i.HeaderChecksum))
@@ -35,7 +35,7 @@ func (i *IPv6) viewTable() *tview.Table {
 	table.SetCell(3, 1, tui.TableCellContent("%x", i.PayloadLength))
 
 	table.SetCell(4, 0, tui.TableCellTitle("Next Header"))
-	table.SetCell(4, 1, tui.TableCellContent("%x", i.NextHeader))
+	table.SetCell(4, 1, tui.TableCellContent("%x (%s)", i.NextHeader, packemon.ProtocolName(i.NextHeader)))
 
 	table.SetCell(5, 0, tui.TableCellTitle("Hop Limit"))
 	table.SetCell(5, 1, tui.TableCellContent("%d", i.HopLimit))
@@ -46,6 +46,12 @@ func (i *IPv6) viewTable() *tview.Table {
 	table.SetCell(7, 0, tui.TableCellTitle("Destination Address"))
 	table.SetCell(7, 1, tui.TableCellContent("%s", i.StrDstIPAddr()))
 
+	table.SetCell(8, 0, tui.TableCellTitle("Source Scope"))
+	table.SetCell(8, 1, tui.TableCellContent("%s", packemon.IPv6Scope(i.SrcAddr)))
+
+	table.SetCell(9, 0, tui.TableCellTitle("Destination Scope"))
+	table.SetCell(9, 1, tui.TableCellContent("%s", packemon.IPv6Scope(i.DstAddr)))
+
 	// TODO: Option. 拡張ヘッダ
 
 	return table
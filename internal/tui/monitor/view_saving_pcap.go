@@ -14,7 +14,8 @@ import (
 	"github.com/rivo/tview"
 )
 
-func (m *monitor) savingPCAPView(p *packemon.Passive) *tview.Form {
+// id のパケットを保存する. 注釈があれば、保存したファイルでのパケット番号(1)でサイドカーファイルにも保存する
+func (m *monitor) savingPCAPView(p *packemon.Passive, id uint64) *tview.Form {
 	now := time.Now()
 	fpath := fmt.Sprintf("./packemon_pcap/%s.pcapng", now.Format("20060102150405"))
 	limitLength := 60
@@ -56,7 +57,10 @@ func (m *monitor) savingPCAPView(p *packemon.Passive) *tview.Form {
 			Length:        length,
 			// InterfaceIndex: intf.Index, // 必須ではなさそう. そもそもこういう事象がある: https://x.com/ddddddOpppppp/status/1893838539881631829
 		}
-		return pcapw.WritePacket(ci, p.EthernetFrame.Bytes())
+		if err := pcapw.WritePacket(ci, p.EthernetFrame.Bytes()); err != nil {
+			return err
+		}
+		return saveAnnotation(fpath, m.annotations, id)
 	}
 
 	form := tview.NewForm().
@@ -80,3 +84,20 @@ func (m *monitor) savingPCAPView(p *packemon.Passive) *tview.Form {
 
 	return form
 }
+
+// 1パケットだけ保存したファイル用に、id のパケットの注釈をパケット番号1に振り直してサイドカーファイルに書き込む
+func saveAnnotation(fpath string, annotations *packemon.Annotations, id uint64) error {
+	note, ok := annotations.Note(annotationNumber(id))
+	if !ok {
+		return nil
+	}
+	exported := packemon.NewAnnotations()
+	exported.Annotate(1, note)
+
+	f, err := os.Create(packemon.AnnotationsPath(fpath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return exported.Save(f)
+}
@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ddddddO/packemon"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// 表示中のパケットを pcap に書き出し、表示フィルター、Decode As の設定とともにセッションとして保存するフォーム.
+// 注釈は書き出したファイルでのパケット番号に振り直してサイドカーファイルに保存する
+func (m *monitor) savingSessionView() *tview.Form {
+	name := time.Now().Format("20060102150405")
+	if m.session != nil {
+		name = m.session.Name
+	}
+	limitLength := 60
+
+	save := func() error {
+		fpath := fmt.Sprintf("./packemon_pcap/%s.pcap", name)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return err
+		}
+		if err := m.writeCapture(fpath); err != nil {
+			return err
+		}
+
+		session := packemon.Session{
+			Name:        name,
+			DecodeAs:    m.networkInterface.DecodeAsOverrides(),
+			CaptureFile: fpath,
+		}
+		if m.networkInterface.Intf != nil {
+			session.Interface = m.networkInterface.Intf.Name
+		}
+		if m.filter.display != nil {
+			session.Filter = m.filter.display.String()
+		}
+		if m.session != nil {
+			session.ColoringRules = m.session.ColoringRules
+		}
+		return m.config.SaveSession(session)
+	}
+
+	form := tview.NewForm().
+		AddInputField("Session Name", name, limitLength, func(textToCheck string, lastChar rune) bool {
+			return len(textToCheck) <= limitLength
+		}, func(text string) {
+			name = text
+		}).
+		AddButton("Save", func() {
+			if err := save(); err != nil {
+				m.addErrPage(err)
+				return
+			}
+			m.pages.RemovePage("session")
+			m.pages.SwitchToPage("history")
+		})
+	form.SetBorder(true)
+	form.Box = tview.NewBox().SetBorder(true).SetTitle(" Save session (Esc to cancel) ").SetTitleAlign(tview.AlignLeft).SetBorderPadding(1, 1, 1, 1)
+	// Box を置き換えた後に設定する
+	form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			m.pages.RemovePage("session")
+			m.pages.SwitchToPage("history")
+			return nil
+		}
+		return event
+	})
+
+	return form
+}
+
+// 受信した順に全パケットを fpath に書き出し、注釈を書き出したファイルでのパケット番号でサイドカーファイルに書き込む
+func (m *monitor) writeCapture(fpath string) error {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pw, err := packemon.NewPcapWriter(f, 0)
+	if err != nil {
+		return err
+	}
+
+	exported := packemon.NewAnnotations()
+	var number uint64
+	for id := range m.storedMaxID.get() + 1 {
+		value, ok := m.storedPackets.Load(id)
+		if !ok {
+			continue
+		}
+		passive := value.(*packemon.Passive)
+		if err := pw.WriteTruncatedPacket(passive.Timestamp, passive.Raw, passive.WireLength); err != nil {
+			return err
+		}
+		number++
+		if note, ok := m.annotations.Note(annotationNumber(id)); ok {
+			exported.Annotate(number, note)
+		}
+	}
+
+	sidecar, err := os.Create(packemon.AnnotationsPath(fpath))
+	if err != nil {
+		return err
+	}
+	defer sidecar.Close()
+	return exported.Save(sidecar)
+}
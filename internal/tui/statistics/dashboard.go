@@ -1,7 +1,12 @@
 package statistics
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,6 +14,7 @@ import (
 	"github.com/rivo/tview"
 
 	"github.com/ddddddO/packemon"
+	"github.com/ddddddO/packemon/internal/tui"
 )
 
 // Dashboard represents a statistics dashboard for packet monitoring
@@ -21,6 +27,15 @@ type Dashboard struct {
 	timelineChart  *tview.TextView
 	topTalkers     *tview.TextView
 	
+	// Show the protocol hierarchy instead of the flat distribution, toggled with 'h'
+	// フラットな分布の代わりにプロトコル階層を表示する。'h'で切り替え
+	showHierarchy  bool
+	
+	// Colors of the texts, set with SetTheme
+	// テキストの色。SetThemeで設定する
+	theme          *packemon.Theme
+	colors         *strings.Replacer
+	
 	// Statistics data
 	// 統計データ
 	stats          *Statistics
@@ -40,8 +55,9 @@ type Dashboard struct {
 func NewDashboard(app *tview.Application) *Dashboard {
 	d := &Dashboard{
 		app:   app,
-		stats: NewStatistics(),
-		done:  make(chan bool),
+		stats:  NewStatistics(),
+		colors: tui.ThemeTagReplacer(nil),
+		done:   make(chan bool),
 	}
 	
 	// Initialize UI components
@@ -104,6 +120,28 @@ func (d *Dashboard) initUI() {
 		AddItem(bottomRow, 0, 1, false)
 }
 
+// SetTheme sets the colors of the dashboard from the theme
+// テーマからダッシュボードの色を設定します
+func (d *Dashboard) SetTheme(theme *packemon.Theme) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	d.theme = theme
+	d.colors = tui.ThemeTagReplacer(theme)
+	for _, view := range []*tview.TextView{d.packetCountBox, d.protocolChart, d.timelineChart, d.topTalkers} {
+		view.SetTextColor(tui.ThemeColor(theme, packemon.THEME_ROLE_TEXT))
+		view.SetBackgroundColor(tui.ThemeColor(theme, packemon.THEME_ROLE_BACKGROUND))
+		view.SetBorderColor(tui.ThemeColor(theme, packemon.THEME_ROLE_BORDER))
+		view.SetTitleColor(tui.ThemeColor(theme, packemon.THEME_ROLE_TITLE))
+	}
+}
+
+// printf writes to w, replacing the role tags such as [title] in format with the colors of the theme
+// formatの[title]などの役割のタグをテーマの色に置き換えてwに書き込みます
+func (d *Dashboard) printf(w io.Writer, format string, args ...any) {
+	fmt.Fprintf(w, d.colors.Replace(format), args...)
+}
+
 // updateLoop updates the dashboard periodically
 // ダッシュボードを定期的に更新します
 func (d *Dashboard) updateLoop() {
@@ -151,10 +189,13 @@ func (d *Dashboard) updatePacketCountBox() {
 	avgSize := d.stats.AveragePacketSize()
 	packetRate := d.stats.PacketRate()
 	
-	fmt.Fprintf(d.packetCountBox, "[yellow]Total Packets:[white] %d\n", totalPackets)
-	fmt.Fprintf(d.packetCountBox, "[yellow]Average Size:[white] %.2f bytes\n", avgSize)
-	fmt.Fprintf(d.packetCountBox, "[yellow]Packet Rate:[white] %.2f pps\n", packetRate)
-	fmt.Fprintf(d.packetCountBox, "[yellow]Monitoring Time:[white] %s\n", d.stats.MonitoringTime().String())
+	d.printf(d.packetCountBox, "[title]Total Packets:[text] %d\n", totalPackets)
+	d.printf(d.packetCountBox, "[title]Average Size:[text] %.2f bytes\n", avgSize)
+	d.printf(d.packetCountBox, "[title]Packet Rate:[text] %.2f pps\n", packetRate)
+	if p50 := d.stats.InterPacketGapPercentile(50); p50 > 0 {
+		d.printf(d.packetCountBox, "[title]Packet Gap p50/p99:[text] < %s / < %s\n", p50, d.stats.InterPacketGapPercentile(99))
+	}
+	d.printf(d.packetCountBox, "[title]Monitoring Time:[text] %s\n", d.stats.MonitoringTime().String())
 }
 
 // updateProtocolChart updates the protocol distribution chart
@@ -162,6 +203,13 @@ func (d *Dashboard) updatePacketCountBox() {
 func (d *Dashboard) updateProtocolChart() {
 	d.protocolChart.Clear()
 	
+	if d.showHierarchy {
+		d.protocolChart.SetTitle("Protocol Hierarchy")
+		d.printProtocolNode(d.stats.ProtocolHierarchy(), 0, 0)
+		return
+	}
+	d.protocolChart.SetTitle("Protocol Distribution")
+	
 	// Get protocol distribution
 	// プロトコル分布を取得
 	protocols := d.stats.ProtocolDistribution()
@@ -201,7 +249,41 @@ func (d *Dashboard) updateProtocolChart() {
 		
 		// Print the bar
 		// バーを表示
-		fmt.Fprintf(d.protocolChart, "[yellow]%-8s[green]%s [white]%d [blue](%.1f%%)\n", proto, bar, count, percentage)
+		d.printf(d.protocolChart, "[%s]%-8s[chart-bar]%s [text]%d [accent](%.1f%%)\n", d.theme.ProtocolColor(proto), proto, bar, count, percentage)
+	}
+	
+	// Print parsers that failed, which hints at traffic not matching its port
+	// 失敗したパーサーを表示（ポートと実際の通信が一致していない可能性がある）
+	failed := false
+	for _, stat := range d.stats.DecodeStats() {
+		if stat.Failures == 0 {
+			continue
+		}
+		if !failed {
+			d.printf(d.protocolChart, "\n[title]Decode Failures:\n")
+			failed = true
+		}
+		d.printf(d.protocolChart, "[alert]%-8s[text]%d / %d [accent](%.1f%%)\n", stat.Protocol, stat.Failures, stat.Attempts, float64(stat.Failures)*100.0/float64(stat.Attempts))
+	}
+}
+
+// printProtocolNode prints a node of the protocol hierarchy and its children, indented by depth
+// プロトコル階層のノードとその子を深さに応じて字下げして表示します
+func (d *Dashboard) printProtocolNode(node ProtocolNode, depth int, totalBytes int64) {
+	if depth == 0 {
+		totalBytes = node.Bytes
+	}
+	
+	// Percentage of all bytes, as in Wireshark
+	// Wiresharkと同様に全バイト数に対する割合
+	percentage := 0.0
+	if totalBytes > 0 {
+		percentage = float64(node.Bytes) * 100.0 / float64(totalBytes)
+	}
+	d.printf(d.protocolChart, "%*s[title]%-10s[text]%d packets %d bytes [accent](%.1f%%)\n", depth*2, "", node.Protocol, node.Packets, node.Bytes, percentage)
+	
+	for _, child := range node.Children {
+		d.printProtocolNode(child, depth+1, totalBytes)
 	}
 }
 
@@ -246,7 +328,7 @@ func (d *Dashboard) updateTimelineChart() {
 		// Print the bar with timestamp
 		// タイムスタンプ付きでバーを表示
 		timeAgo := len(history) - i - 1
-		fmt.Fprintf(d.timelineChart, "[yellow]%2ds ago:[blue]%s [white]%.2f pps\n", timeAgo, bar, rate)
+		d.printf(d.timelineChart, "[title]%2ds ago:[chart-bar]%s [text]%.2f pps\n", timeAgo, bar, rate)
 	}
 }
 
@@ -261,12 +343,27 @@ func (d *Dashboard) updateTopTalkers() {
 	
 	// Print top source IPs
 	// トップ送信元IPを表示
-	fmt.Fprintf(d.topTalkers, "[yellow]Top Source IPs:\n")
+	// The OS is only a guess from the TTL and TCP SYNs. A SYN matching the fingerprint database shows its label and confidence
+	// OSはTTLとTCP SYNからの推測にすぎない。フィンガープリントのデータベースに一致したSYNはそのラベルと確からしさを表示する
+	osHints := d.stats.OSHints()
+	d.printf(d.topTalkers, "[title]Top Source IPs:\n")
 	for i, entry := range srcIPs {
-		fmt.Fprintf(d.topTalkers, "[white]%d. [green]%s [white]- %d packets\n", i+1, entry.IP, entry.Count)
+		d.printf(d.topTalkers, "[text]%d. [highlight]%s", i+1, entry.IP)
+		if entry.Hostname != "" {
+			d.printf(d.topTalkers, " (%s)", entry.Hostname)
+		}
+		d.printf(d.topTalkers, " [text]- %d packets", entry.Count)
+		if hint, ok := osHints[entry.IP]; ok {
+			if hint.Label != "" {
+				d.printf(d.topTalkers, " [muted](%s?, %s confidence, %d hops)", hint.Label, hint.Confidence, hint.Hops)
+			} else {
+				d.printf(d.topTalkers, " [muted](%s?, %d hops)", hint.Family, hint.Hops)
+			}
+		}
+		d.printf(d.topTalkers, "\n")
 	}
 	
-	fmt.Fprintf(d.topTalkers, "\n")
+	d.printf(d.topTalkers, "\n")
 	
 	// Get top destination IPs
 	// トップ宛先IPを取得
@@ -274,9 +371,109 @@ func (d *Dashboard) updateTopTalkers() {
 	
 	// Print top destination IPs
 	// トップ宛先IPを表示
-	fmt.Fprintf(d.topTalkers, "[yellow]Top Destination IPs:\n")
+	d.printf(d.topTalkers, "[title]Top Destination IPs:\n")
 	for i, entry := range dstIPs {
-		fmt.Fprintf(d.topTalkers, "[white]%d. [green]%s [white]- %d packets\n", i+1, entry.IP, entry.Count)
+		d.printf(d.topTalkers, "[text]%d. [highlight]%s", i+1, entry.IP)
+		if entry.Hostname != "" {
+			d.printf(d.topTalkers, " (%s)", entry.Hostname)
+		}
+		d.printf(d.topTalkers, " [text]- %d packets\n", entry.Count)
+	}
+	
+	// Print the sources sending faster than the rate threshold in red
+	// パケットレートの閾値より速く送信している送信元を赤で表示
+	flagged := d.stats.FlaggedSources()
+	if len(flagged) > 0 {
+		d.printf(d.topTalkers, "\n[title]High Rate Sources:\n")
+	}
+	for _, source := range flagged {
+		d.printf(d.topTalkers, "[alert]%s [text]- %.1f packets/s\n", source.IP, source.Rate)
+	}
+	
+	// Print TCP connections with retransmissions, duplicate ACKs or segments acknowledged selectively past a loss
+	// 再送、重複ACK、または損失より後で選択的に確認応答されたセグメントのあるTCPコネクションを表示
+	unhealthy := false
+	for _, flow := range d.stats.TCPFlowStats() {
+		sacked := flow.SrcSACKed + flow.DstSACKed
+		if flow.Retransmissions == 0 && flow.DuplicateACKs == 0 && sacked == 0 {
+			continue
+		}
+		if !unhealthy {
+			d.printf(d.topTalkers, "\n[title]TCP Retransmissions / Dup ACKs / SACKed:\n")
+			unhealthy = true
+		}
+		d.printf(d.topTalkers, "[highlight]%s -> %s [text]- %d / %d / %d\n", flow.Src, flow.Dst, flow.Retransmissions, flow.DuplicateACKs, sacked)
+	}
+	
+	// Print the throughput of each TCP transfer with the window of its receiver. A throughput close to window / RTT hints at a window-limited transfer
+	// TCPの転送ごとのスループットを受信側のウィンドウとともに表示。ウィンドウ / RTT に近いスループットはウィンドウに律速された転送の可能性がある
+	transferring := false
+	for _, flow := range d.stats.TCPFlowStats() {
+		for _, transfer := range []struct {
+			src, dst   string
+			throughput float64
+			window     uint32
+		}{
+			{flow.Src, flow.Dst, flow.SrcThroughput, flow.DstWindow},
+			{flow.Dst, flow.Src, flow.DstThroughput, flow.SrcWindow},
+		} {
+			if transfer.throughput == 0 {
+				continue
+			}
+			if !transferring {
+				d.printf(d.topTalkers, "\n[title]TCP Throughput / Window:\n")
+				transferring = true
+			}
+			d.printf(d.topTalkers, "[highlight]%s -> %s [text]- %.1f KB/s / %d bytes\n", transfer.src, transfer.dst, transfer.throughput/1024, transfer.window)
+		}
+	}
+	
+	// Print DNS response codes per resolver. Resolvers answering SERVFAIL or REFUSED are shown in red
+	// リゾルバごとのDNSの応答コードを表示。SERVFAILまたはREFUSEDを返したリゾルバは赤で表示
+	rcodeStats := d.stats.DNSRcodeStats()
+	if len(rcodeStats) > 0 {
+		d.printf(d.topTalkers, "\n[title]DNS Resolvers:\n")
+	}
+	for _, resolver := range slices.Sorted(maps.Keys(rcodeStats)) {
+		stats := rcodeStats[resolver]
+		color := d.theme.Color(packemon.THEME_ROLE_HIGHLIGHT)
+		if stats.FailureRate > 0 {
+			color = d.theme.Color(packemon.THEME_ROLE_ALERT)
+		}
+		rcodes := make([]string, 0, len(stats.Rcodes))
+		for _, rcode := range slices.Sorted(maps.Keys(stats.Rcodes)) {
+			rcodes = append(rcodes, fmt.Sprintf("%s %d", rcode, stats.Rcodes[rcode]))
+		}
+		d.printf(d.topTalkers, "[%s]%s [text]- %s [accent](%.1f%% failed)\n", color, resolver, strings.Join(rcodes, ", "), stats.FailureRate*100.0)
+	}
+	
+	// Print the neighbor table. IPs claimed by several MAC addresses are shown in red
+	// 近隣テーブルを表示。複数のMACアドレスが名乗ったIPは赤で表示
+	neighbors := d.stats.Neighbors()
+	if len(neighbors) > 0 {
+		d.printf(d.topTalkers, "\n[title]Neighbors:\n")
+	}
+	for i, entry := range neighbors {
+		if i == 10 {
+			d.printf(d.topTalkers, "[muted]... %d more\n", len(neighbors)-i)
+			break
+		}
+		color := d.theme.Color(packemon.THEME_ROLE_HIGHLIGHT)
+		if entry.Conflicts > 0 {
+			color = d.theme.Color(packemon.THEME_ROLE_ALERT)
+		}
+		d.printf(d.topTalkers, "[%s]%s [text]- %s (%s)\n", color, entry.IP, entry.MAC, entry.Protocol)
+	}
+	
+	// Print IPv6 traffic grouped by scope
+	// スコープ別のIPv6トラフィックを表示
+	scopes := d.stats.IPv6ScopeDistribution()
+	if len(scopes) == 0 {
+		return
+	}
+	d.printf(d.topTalkers, "\n[title]IPv6 Scopes:\n")
+	for _, scope := range slices.Sorted(maps.Keys(scopes)) {
+		d.printf(d.topTalkers, "[highlight]%s [text]- %d packets\n", scope, scopes[scope])
 	}
 }
 
@@ -289,6 +486,36 @@ func (d *Dashboard) ProcessPacket(passive *packemon.Passive) {
 	d.stats.ProcessPacket(passive)
 }
 
+// StartDemo feeds the dashboard with generated packets at the given interval until ctx is done.
+// Packets are generated from consecutive seeds starting at seed, so a demo is reproducible without a live interface.
+// ctxが終了するまで、生成したパケットを一定間隔でダッシュボードに流します。実際のインターフェースなしで再現可能なデモになります
+func (d *Dashboard) StartDemo(ctx context.Context, seed int64, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				passive, err := packemon.DecodeFrame(packemon.GenerateRandomPacket(seed))
+				seed++
+				if err != nil {
+					continue
+				}
+				d.ProcessPacket(passive)
+			}
+		}
+	}()
+}
+
+// StartReverseDNS shows the PTR names of top talkers, looked up with resolver until ctx is done, e.g. net.DefaultResolver
+// ctxが終了するまでresolver(例えばnet.DefaultResolver)で問い合わせたトップトーカーのPTR名を表示します
+func (d *Dashboard) StartReverseDNS(ctx context.Context, resolver ReverseResolver) {
+	d.stats.StartReverseDNS(ctx, resolver)
+}
+
 // GetView returns the main view of the dashboard
 // ダッシュボードのメインビューを返します
 func (d *Dashboard) GetView() tview.Primitive {
@@ -305,10 +532,18 @@ func (d *Dashboard) Stop() {
 // HandleKey handles key events
 // キーイベントを処理します
 func (d *Dashboard) HandleKey(event *tcell.EventKey) *tcell.EventKey {
-	// Handle key events here
-	// ここでキーイベントを処理
+	// Toggle the protocol hierarchy
+	// プロトコル階層の表示を切り替え
+	if event.Key() == tcell.KeyRune && event.Rune() == 'h' {
+		// キーイベントはメインのゴルーチンで呼ばれるため、QueueUpdateDrawを使わずに直接描画する
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.showHierarchy = !d.showHierarchy
+		d.updateProtocolChart()
+		return nil
+	}
 	
-	// For now, just pass the event through
-	// 今のところ、イベントをそのまま通過させる
+	// Pass other events through
+	// それ以外のイベントはそのまま通過させる
 	return event
 }
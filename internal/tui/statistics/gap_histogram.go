@@ -0,0 +1,88 @@
+package statistics
+
+import (
+	"time"
+)
+
+// GAP_HISTOGRAM_BUCKETS is the number of inter-packet gap buckets.
+// Bucket 0 holds gaps under 1µs, bucket i holds gaps in [2^(i-1)µs, 2^iµs) and the last bucket holds everything longer.
+// パケット間隔のバケット数です。バケット0は1µs未満、バケットiは[2^(i-1)µs, 2^iµs)、最後のバケットはそれ以上の間隔を保持します
+const GAP_HISTOGRAM_BUCKETS = 28
+
+// GapBucket is one bucket of the inter-packet gap histogram
+// パケット間隔のヒストグラムの1つのバケットです
+type GapBucket struct {
+	// UpperBound is the exclusive upper bound of the bucket. 0 for the last bucket, which has no upper bound
+	// バケットの上限(この値を含まない)。上限の無い最後のバケットは0
+	UpperBound time.Duration
+	Count      int
+}
+
+// gapHistogram buckets the gaps between capture timestamps logarithmically
+// キャプチャ時刻の間隔を対数スケールのバケットに集計します
+type gapHistogram struct {
+	buckets [GAP_HISTOGRAM_BUCKETS]int
+	count   int
+	last    time.Time
+}
+
+// add records the gap since the previous packet. Timestamps going backwards, e.g. from several interfaces, count as no gap
+// 前のパケットからの間隔を記録します。複数のインターフェースなどで時刻が戻った場合は間隔0とみなします
+func (h *gapHistogram) add(timestamp time.Time) {
+	if !h.last.IsZero() {
+		gap := max(timestamp.Sub(h.last), 0)
+		h.buckets[gapBucket(gap)]++
+		h.count++
+	}
+	if timestamp.After(h.last) {
+		h.last = timestamp
+	}
+}
+
+func gapBucket(gap time.Duration) int {
+	bucket := 0
+	for bound := time.Microsecond; gap >= bound && bucket < GAP_HISTOGRAM_BUCKETS-1; bound *= 2 {
+		bucket++
+	}
+	return bucket
+}
+
+// gapBucketUpperBound returns the exclusive upper bound of bucket, or 0 for the last bucket
+func gapBucketUpperBound(bucket int) time.Duration {
+	if bucket >= GAP_HISTOGRAM_BUCKETS-1 {
+		return 0
+	}
+	return time.Microsecond << bucket
+}
+
+func (h *gapHistogram) snapshot() []GapBucket {
+	buckets := make([]GapBucket, GAP_HISTOGRAM_BUCKETS)
+	for i, count := range h.buckets {
+		buckets[i] = GapBucket{UpperBound: gapBucketUpperBound(i), Count: count}
+	}
+	return buckets
+}
+
+// percentile returns the upper bound of the bucket holding the p-th percentile gap (0 < p <= 100).
+// The last bucket has no upper bound, so its lower bound is returned instead. 0 if there are no gaps yet
+// p パーセンタイルの間隔を含むバケットの上限を返します。上限の無い最後のバケットの場合は下限を返します
+func (h *gapHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	p = min(max(p, 0), 100)
+
+	// 小さい方から数えて rank 番目の間隔を含むバケット
+	rank := max(int(float64(h.count)*p/100+0.5), 1)
+	seen := 0
+	for i, count := range h.buckets {
+		seen += count
+		if seen >= rank {
+			if i == GAP_HISTOGRAM_BUCKETS-1 {
+				return gapBucketUpperBound(i - 1)
+			}
+			return gapBucketUpperBound(i)
+		}
+	}
+	return gapBucketUpperBound(GAP_HISTOGRAM_BUCKETS - 2)
+}
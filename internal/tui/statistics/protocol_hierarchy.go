@@ -0,0 +1,145 @@
+package statistics
+
+import (
+	"sort"
+
+	"github.com/ddddddO/packemon"
+)
+
+// ProtocolNode is one protocol in the protocol hierarchy, like Wireshark's "Protocol Hierarchy".
+// Packets and Bytes count the packets carrying the protocol at this position, and Bytes is their whole frame length
+// プロトコル階層(Wireshark の "Protocol Hierarchy" と同様)の1つのプロトコルです。
+// PacketsとBytesはこの位置でプロトコルを含むパケットの数で、Bytesはそれらのフレーム全体の長さです
+type ProtocolNode struct {
+	Protocol string `json:"protocol"`
+	Packets  int    `json:"packets"`
+	Bytes    int64  `json:"bytes"`
+
+	// Children are the protocols carried directly in this one, ordered by bytes
+	// このプロトコルが直接運ぶプロトコル。バイト数の多い順
+	Children []ProtocolNode `json:"children,omitempty"`
+}
+
+// protocolTree counts packets and bytes per protocol path
+// プロトコルの経路ごとにパケット数とバイト数を集計します
+type protocolTree struct {
+	packets  int
+	bytes    int64
+	children map[string]*protocolTree
+}
+
+// add counts a packet of size bytes at each level of path
+// パスの各階層でsizeバイトのパケットを1つ数えます
+func (t *protocolTree) add(path []string, size int) {
+	node := t
+	node.packets++
+	node.bytes += int64(size)
+	for _, protocol := range path {
+		if node.children == nil {
+			node.children = make(map[string]*protocolTree)
+		}
+		child, ok := node.children[protocol]
+		if !ok {
+			child = &protocolTree{}
+			node.children[protocol] = child
+		}
+		child.packets++
+		child.bytes += int64(size)
+		node = child
+	}
+}
+
+func (t *protocolTree) snapshot(protocol string) ProtocolNode {
+	node := ProtocolNode{
+		Protocol: protocol,
+		Packets:  t.packets,
+		Bytes:    t.bytes,
+	}
+	for name, child := range t.children {
+		node.Children = append(node.Children, child.snapshot(name))
+	}
+	sort.Slice(node.Children, func(i, j int) bool {
+		if node.Children[i].Bytes != node.Children[j].Bytes {
+			return node.Children[i].Bytes > node.Children[j].Bytes
+		}
+		return node.Children[i].Protocol < node.Children[j].Protocol
+	})
+	return node
+}
+
+// ipProtocolName returns the name of the IPv4 Protocol or IPv6 Next Header of a packet
+// パケットのIPv4のProtocolまたはIPv6のNext Headerの名前を返します
+func ipProtocolName(passive *packemon.Passive) (string, bool) {
+	switch {
+	case passive.IPv4 != nil:
+		return packemon.ProtocolName(passive.IPv4.Protocol), true
+	case passive.IPv6 != nil:
+		return packemon.ProtocolName(passive.IPv6.NextHeader), true
+	}
+	return "", false
+}
+
+// protocolPath returns the parsed layers of a packet from the outermost, e.g. Ethernet, IPv4, TCP, HTTP.
+// A packet tunneled in IP continues with the layers of the inner packet
+// パケットの解析済みのレイヤーを外側から順に返します(例: Ethernet, IPv4, TCP, HTTP)。
+// IPでトンネルされたパケットは内側のパケットのレイヤーが続きます
+func protocolPath(passive *packemon.Passive) []string {
+	path := []string{}
+	switch {
+	case passive.IEEE80211 != nil:
+		// 802.11 のデータフレームの EthernetFrame は解析のために作ったもの
+		path = append(path, "802.11")
+	case passive.EthernetFrame != nil:
+		path = append(path, "Ethernet")
+	}
+
+	// Network layer
+	// ネットワーク層
+	switch {
+	case passive.ARP != nil:
+		path = append(path, "ARP")
+	case passive.IPv4 != nil:
+		path = append(path, "IPv4")
+	case passive.IPv6 != nil:
+		path = append(path, "IPv6")
+	}
+	if passive.Inner != nil {
+		return append(path, protocolPath(passive.Inner)...)
+	}
+
+	// Transport layer
+	// トランスポート層
+	switch {
+	case passive.ICMP != nil:
+		path = append(path, "ICMP")
+	case passive.ICMPv6 != nil:
+		path = append(path, "ICMPv6")
+	case passive.TCP != nil:
+		path = append(path, "TCP")
+	case passive.UDP != nil:
+		path = append(path, "UDP")
+	default:
+		// 解析しないプロトコル(GRE、ESP など)は番号の名前で数える
+		if name, ok := ipProtocolName(passive); ok {
+			path = append(path, name)
+		}
+	}
+
+	// Application layer
+	// アプリケーション層
+	switch {
+	case passive.HTTP != nil || passive.HTTPRes != nil:
+		path = append(path, "HTTP")
+	case passive.TLS != nil:
+		path = append(path, "TLS")
+	case passive.DNS != nil:
+		path = append(path, "DNS")
+	case passive.RTP != nil:
+		path = append(path, "RTP")
+	case passive.GENEVE != nil:
+		path = append(path, "GENEVE")
+	case passive.SMB != nil:
+		path = append(path, "SMB")
+	}
+	return path
+}
@@ -0,0 +1,149 @@
+package statistics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	REPORT_FORMAT_TEXT = "text"
+	REPORT_FORMAT_JSON = "json" // 1 行に 1 つの JSON オブジェクト
+
+	// REPORT_TOP_TALKERS is the number of source and destination IPs in a report
+	// レポートに含める送信元と宛先のIPの数です
+	REPORT_TOP_TALKERS = 5
+)
+
+// StatisticsReporter periodically writes summaries of the statistics as text or JSON lines, without a terminal UI.
+// It is the headless counterpart of the Dashboard, e.g. to run packemon as a daemon
+// 統計の要約を、端末のUIなしでテキストまたはJSON Linesとして定期的に書き込みます。
+// Dashboardのヘッドレス版で、packemonをデーモンとして動かす場合などに使います
+type StatisticsReporter struct {
+	stats    *Statistics
+	interval time.Duration
+	format   string
+	w        io.Writer
+}
+
+// NewStatisticsReporter creates a reporter writing a summary of stats to w every interval, in format REPORT_FORMAT_TEXT or REPORT_FORMAT_JSON
+// statsの要約をintervalごとにwへ書き込むレポーターを作成します。formatはREPORT_FORMAT_TEXTまたはREPORT_FORMAT_JSONです
+func NewStatisticsReporter(stats *Statistics, interval time.Duration, format string, w io.Writer) (*StatisticsReporter, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("report interval must be positive: %s", interval)
+	}
+	switch format {
+	case REPORT_FORMAT_TEXT, REPORT_FORMAT_JSON:
+	default:
+		return nil, fmt.Errorf("unsupported report format: %q (want %q or %q)", format, REPORT_FORMAT_TEXT, REPORT_FORMAT_JSON)
+	}
+	return &StatisticsReporter{stats: stats, interval: interval, format: format, w: w}, nil
+}
+
+// Run writes a report every interval until ctx is done, then a final one
+// ctxが終了するまでintervalごとにレポートを書き込み、最後にもう1度書き込みます
+func (r *StatisticsReporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return r.Report()
+		case <-ticker.C:
+			if err := r.Report(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Report writes one report of the current statistics
+// 現在の統計のレポートを1つ書き込みます
+func (r *StatisticsReporter) Report() error {
+	snapshot := r.stats.Snapshot(REPORT_TOP_TALKERS)
+	if r.format == REPORT_FORMAT_JSON {
+		return json.NewEncoder(r.w).Encode(snapshot)
+	}
+	_, err := io.WriteString(r.w, formatSnapshot(snapshot))
+	return err
+}
+
+// 1 行目に全体の値、以降の行に内訳を字下げして並べる
+func formatSnapshot(snapshot Snapshot) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s packets=%d bytes=%d avg=%.1f rate=%.2f/s uptime=%s\n",
+		snapshot.Time.Format(time.RFC3339),
+		snapshot.TotalPackets,
+		snapshot.TotalBytes,
+		snapshot.AveragePacketSize,
+		snapshot.PacketRate,
+		time.Duration(snapshot.MonitoringSeconds*float64(time.Second)).Round(time.Second))
+
+	writeCounts := func(label string, counts map[string]int) {
+		if len(counts) == 0 {
+			return
+		}
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if counts[names[i]] != counts[names[j]] {
+				return counts[names[i]] > counts[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		fields := make([]string, len(names))
+		for i, name := range names {
+			fields[i] = fmt.Sprintf("%s=%d", name, counts[name])
+		}
+		fmt.Fprintf(b, "  %s: %s\n", label, strings.Join(fields, " "))
+	}
+	writeTalkers := func(label string, talkers []IPCount) {
+		if len(talkers) == 0 {
+			return
+		}
+		fields := make([]string, len(talkers))
+		for i, talker := range talkers {
+			name := talker.IP
+			if talker.Hostname != "" {
+				name = fmt.Sprintf("%s(%s)", talker.IP, talker.Hostname)
+			}
+			fields[i] = fmt.Sprintf("%s=%d", name, talker.Count)
+		}
+		fmt.Fprintf(b, "  %s: %s\n", label, strings.Join(fields, " "))
+	}
+
+	writeCounts("protocols", snapshot.Protocols)
+	writeCounts("ipv6 scopes", snapshot.IPv6Scopes)
+	classes := make(map[string]int, len(snapshot.Classes))
+	for class, stats := range snapshot.Classes {
+		classes[class] = stats.Packets
+	}
+	writeCounts("classes", classes)
+	writeTalkers("top sources", snapshot.TopSources)
+	writeTalkers("top destinations", snapshot.TopDestinations)
+	if snapshot.ECN.Capable > 0 {
+		fmt.Fprintf(b, "  ecn: capable=%d ce=%d marking=%.2f%%\n", snapshot.ECN.Capable, snapshot.ECN.CE, snapshot.ECN.MarkingRate*100)
+	}
+	if len(snapshot.FlaggedSources) > 0 {
+		fields := make([]string, len(snapshot.FlaggedSources))
+		for i, source := range snapshot.FlaggedSources {
+			fields[i] = fmt.Sprintf("%s=%.1f/s", source.IP, source.Rate)
+		}
+		fmt.Fprintf(b, "  flagged sources: %s\n", strings.Join(fields, " "))
+	}
+	if snapshot.Fragments.Fragments > 0 {
+		fmt.Fprintf(b, "  fragments: received=%d reassembled=%d\n", snapshot.Fragments.Fragments, snapshot.Fragments.Reassembled)
+	}
+	failures := make(map[string]int, len(snapshot.DecodeFailures))
+	for proto, count := range snapshot.DecodeFailures {
+		failures[proto] = int(count)
+	}
+	writeCounts("decode failures", failures)
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package statistics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ddddddO/packemon"
+)
+
+func reporterTestStatistics() *Statistics {
+	s := NewStatistics()
+	for i := 0; i < 3; i++ {
+		s.ProcessPacket(&packemon.Passive{RawLength: 60, IPv4: &packemon.IPv4Packet{SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 2}}})
+	}
+	return s
+}
+
+// TestStatisticsReporter tests that one report is written to a buffer as a JSON line and as text
+// 1つのレポートがJSON Linesとテキストでバッファに書き込まれることをテストします
+func TestStatisticsReporter(t *testing.T) {
+	s := reporterTestStatistics()
+
+	buf := &bytes.Buffer{}
+	reporter, err := NewStatisticsReporter(s, time.Second, REPORT_FORMAT_JSON, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Report(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Fatalf("JSON report has %d lines, want 1: %s", lines, buf)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(buf.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.TotalPackets != 3 || snapshot.TotalBytes != 180 || snapshot.Protocols["IPv4"] != 3 {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+	if len(snapshot.TopSources) != 1 || snapshot.TopSources[0].IP != "192.168.0.1" || snapshot.TopSources[0].Count != 3 {
+		t.Errorf("top sources = %+v", snapshot.TopSources)
+	}
+
+	buf.Reset()
+	reporter, err = NewStatisticsReporter(s, time.Second, REPORT_FORMAT_TEXT, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Report(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"packets=3 bytes=180 avg=60.0", "protocols: IPv4=3", "top sources: 192.168.0.1=3", "top destinations: 192.168.0.2=3"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text report does not contain %q:\n%s", want, buf)
+		}
+	}
+
+	if _, err := NewStatisticsReporter(s, time.Second, "xml", buf); err == nil {
+		t.Error("an unsupported format should be an error")
+	}
+	if _, err := NewStatisticsReporter(s, 0, REPORT_FORMAT_TEXT, buf); err == nil {
+		t.Error("a zero interval should be an error")
+	}
+}
+
+// TestStatisticsReporterRun tests that reports are written every interval and once more when stopped
+// レポートが間隔ごとに書き込まれ、停止時にもう1度書き込まれることをテストします
+func TestStatisticsReporterRun(t *testing.T) {
+	buf := &bytes.Buffer{}
+	reporter, err := NewStatisticsReporter(reporterTestStatistics(), 20*time.Millisecond, REPORT_FORMAT_JSON, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := reporter.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 20ms ごとの分と停止時の 1 回
+	if lines := strings.Count(buf.String(), "\n"); lines < 2 {
+		t.Errorf("wrote %d reports, want at least 2:\n%s", lines, buf)
+	}
+}
@@ -0,0 +1,122 @@
+package statistics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// REVERSE_DNS_CACHE_SIZE is the number of PTR names (or failed lookups) kept. The oldest is evicted first
+	// 保持するPTR名(または失敗した問い合わせ)の数です。古いものから削除します
+	REVERSE_DNS_CACHE_SIZE = 1024
+
+	// REVERSE_DNS_QUEUE_SIZE is the number of IPs waiting for a lookup. IPs beyond it are retried on a later refresh
+	// 問い合わせを待つIPの数です。溢れたIPは次回の更新時に再度キューに入れます
+	REVERSE_DNS_QUEUE_SIZE = 64
+
+	// REVERSE_DNS_TIMEOUT bounds a single PTR lookup
+	// 1回のPTR問い合わせの時間の上限です
+	REVERSE_DNS_TIMEOUT = 2 * time.Second
+
+	// REVERSE_DNS_INTERVAL is the minimum gap between lookups, so at most 10 lookups are sent per second
+	// 問い合わせの最小間隔です。1秒あたり最大10回まで問い合わせます
+	REVERSE_DNS_INTERVAL = 100 * time.Millisecond
+)
+
+// ReverseResolver looks up the PTR names of an IP address. *net.Resolver satisfies it
+// IPアドレスのPTR名を問い合わせます。*net.Resolverが満たします
+type ReverseResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// reverseDNS resolves IPs on its own goroutine and caches the names, so statistics never waits for DNS
+// 別のゴルーチンでIPを解決して名前をキャッシュします。統計処理がDNSを待つことはありません
+type reverseDNS struct {
+	resolver ReverseResolver
+	queue    chan string
+
+	mu sync.Mutex
+	// 名前が無い、または問い合わせに失敗したIPは空文字列でキャッシュする
+	names   map[string]string
+	order   []string
+	pending map[string]bool
+}
+
+func newReverseDNS(resolver ReverseResolver) *reverseDNS {
+	return &reverseDNS{
+		resolver: resolver,
+		queue:    make(chan string, REVERSE_DNS_QUEUE_SIZE),
+		names:    make(map[string]string),
+		pending:  make(map[string]bool),
+	}
+}
+
+// hostname returns the cached name of ip. If ip has not been looked up yet, it queues ip and returns ""
+// ipのキャッシュされた名前を返します。まだ問い合わせていなければキューに入れて""を返します
+func (r *reverseDNS) hostname(ip string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if name, ok := r.names[ip]; ok {
+		return name
+	}
+	if r.pending[ip] {
+		return ""
+	}
+	select {
+	case r.queue <- ip:
+		r.pending[ip] = true
+	default:
+	}
+	return ""
+}
+
+// run resolves queued IPs one at a time, no more often than REVERSE_DNS_INTERVAL, until ctx is done
+// ctxが終了するまで、キューのIPをREVERSE_DNS_INTERVAL以上の間隔で1つずつ解決します
+func (r *reverseDNS) run(ctx context.Context) {
+	ticker := time.NewTicker(REVERSE_DNS_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ip := <-r.queue:
+			r.store(ip, r.lookup(ctx, ip))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *reverseDNS) lookup(ctx context.Context, ip string) string {
+	ctx, cancel := context.WithTimeout(ctx, REVERSE_DNS_TIMEOUT)
+	defer cancel()
+
+	names, err := r.resolver.LookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+func (r *reverseDNS) store(ip, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pending, ip)
+	if _, ok := r.names[ip]; !ok {
+		if len(r.order) >= REVERSE_DNS_CACHE_SIZE {
+			delete(r.names, r.order[0])
+			r.order = r.order[1:]
+		}
+		r.order = append(r.order, ip)
+	}
+	r.names[ip] = name
+}
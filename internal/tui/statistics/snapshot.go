@@ -0,0 +1,89 @@
+package statistics
+
+import (
+	"time"
+)
+
+// Snapshot is a point-in-time copy of the statistics, taken at once so the values are consistent with each other
+// 統計のある時点のコピーです。値同士が矛盾しないよう一度に取得します
+type Snapshot struct {
+	Time              time.Time `json:"time"`
+	MonitoringSeconds float64   `json:"monitoring_seconds"`
+	TotalPackets      int       `json:"total_packets"`
+	TotalBytes        int64     `json:"total_bytes"`
+	AveragePacketSize float64   `json:"average_packet_size"`
+	PacketRate        float64   `json:"packet_rate"`
+
+	Protocols         map[string]int `json:"protocols"`
+	ProtocolHierarchy ProtocolNode   `json:"protocol_hierarchy"`
+	IPv6Scopes        map[string]int `json:"ipv6_scopes,omitempty"`
+
+	Classes map[string]TrafficClassStats `json:"classes"`
+
+	TopSources      []IPCount `json:"top_sources"`
+	TopDestinations []IPCount `json:"top_destinations"`
+
+	ECN       ECNStats      `json:"ecn"`
+	Fragments FragmentStats `json:"fragments"`
+
+	// FlaggedSources is the source IPs sending at or above the rate threshold, the fastest first
+	// パケットレートの閾値以上で送信している送信元IP。速い順
+	FlaggedSources []SourceRate `json:"flagged_sources,omitempty"`
+
+	// DNSRcodes is the DNS response codes per resolver
+	// リゾルバごとのDNSの応答コード
+	DNSRcodes map[string]DNSRcodeStats `json:"dns_rcodes,omitempty"`
+
+	// DecodeFailures is the number of parse failures per protocol since the statistics were started or reset
+	// 統計の開始時またはリセット以降の、プロトコルごとの解析失敗回数
+	DecodeFailures map[string]uint64 `json:"decode_failures,omitempty"`
+}
+
+// Snapshot returns a copy of the statistics with the top n source and destination IPs
+// 送信元と宛先のトップnのIPを含む、統計のコピーを返します
+func (s *Statistics) Snapshot(n int) Snapshot {
+	decodeFailures := map[string]uint64{}
+	for _, stat := range s.DecodeStats() {
+		if stat.Failures > 0 {
+			decodeFailures[stat.Protocol] = stat.Failures
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	snapshot := Snapshot{
+		Time:              now,
+		MonitoringSeconds: now.Sub(s.startTime).Seconds(),
+		TotalPackets:      s.totalPackets,
+		TotalBytes:        s.totalBytes,
+		Protocols:         make(map[string]int, len(s.protocolCounts)),
+		ProtocolHierarchy: s.protocols.snapshot("Frame"),
+		IPv6Scopes:        make(map[string]int, len(s.ipv6Scopes)),
+		Classes:           make(map[string]TrafficClassStats, len(s.classes)),
+		TopSources:        s.topIPs(s.sourceIPs, n),
+		TopDestinations:   s.topIPs(s.destIPs, n),
+		ECN:               s.ecnStats(),
+		Fragments:         FragmentStats{Fragments: s.fragments, Reassembled: s.reassembledPackets},
+		DNSRcodes:         s.dnsRcodeStats(),
+		FlaggedSources:    s.sourceRates.flagged(),
+		DecodeFailures:    decodeFailures,
+	}
+	if s.totalPackets > 0 {
+		snapshot.AveragePacketSize = float64(s.totalBytes) / float64(s.totalPackets)
+	}
+	if snapshot.MonitoringSeconds > 0 {
+		snapshot.PacketRate = float64(s.totalPackets) / snapshot.MonitoringSeconds
+	}
+	for proto, count := range s.protocolCounts {
+		snapshot.Protocols[proto] = count
+	}
+	for scope, count := range s.ipv6Scopes {
+		snapshot.IPv6Scopes[scope] = count
+	}
+	for class, stats := range s.classes {
+		snapshot.Classes[class] = stats
+	}
+	return snapshot
+}
@@ -0,0 +1,145 @@
+package statistics
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// DEFAULT_SOURCE_RATE_THRESHOLD is the packet rate per second above which a source is flagged
+	// 送信元にフラグを立てるパケットレート(毎秒)です
+	DEFAULT_SOURCE_RATE_THRESHOLD = 1000.0
+	// DEFAULT_SOURCE_RATE_WINDOW is the sliding window the packet rate of a source is measured over
+	// 送信元のパケットレートを測るスライディングウィンドウです
+	DEFAULT_SOURCE_RATE_WINDOW = 10 * time.Second
+	// SOURCE_RATE_MAX_SOURCES is the number of sources whose rate is tracked at once. Beyond it, sources quiet for
+	// the whole window are dropped first, then the least recently seen one
+	// 同時にレートを追跡する送信元の数です。超えた場合はウィンドウの間パケットの無い送信元を、次に最も長くパケットの無い送信元を捨てます
+	SOURCE_RATE_MAX_SOURCES = 4096
+)
+
+// ウィンドウを分割する時間枠の数. ウィンドウはこの粒度で進む
+const sourceRateSlots = 10
+
+// SourceRate is a source IP sending faster than the threshold, and its packet rate over the window
+// 閾値より速く送信している送信元IPと、ウィンドウでのパケットレートです
+type SourceRate struct {
+	IP   string  `json:"ip"`
+	Rate float64 `json:"rate"` // Packets per second / 毎秒のパケット数
+}
+
+// 送信元ごとの時間枠のリングバッファ
+type sourceRateWindow struct {
+	counts [sourceRateSlots]int
+	slots  [sourceRateSlots]int64 // counts が数えている時間枠の番号
+	last   time.Time
+}
+
+// sourceRates measures the packet rate of each source over a sliding window, by the timestamps of the packets
+// パケットのタイムスタンプに従い、スライディングウィンドウで送信元ごとのパケットレートを測ります
+type sourceRates struct {
+	threshold  float64
+	window     time.Duration
+	maxSources int
+	sources    map[string]*sourceRateWindow
+
+	// 最後のパケットの時刻と、それを受信した実際の時刻. 送信が止まった送信元のレートも下がるよう、現在時刻はここから進める
+	lastPacket   time.Time
+	lastReceived time.Time
+	now          func() time.Time
+}
+
+func newSourceRates(threshold float64, window time.Duration) *sourceRates {
+	return &sourceRates{
+		threshold:  threshold,
+		window:     window,
+		maxSources: SOURCE_RATE_MAX_SOURCES,
+		sources:    make(map[string]*sourceRateWindow),
+		now:        time.Now,
+	}
+}
+
+func (r *sourceRates) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(max(r.window/sourceRateSlots, 1))
+}
+
+// add counts a packet from ip at t
+// tにipから届いたパケットを数えます
+func (r *sourceRates) add(ip string, t time.Time) {
+	if t.After(r.lastPacket) {
+		r.lastPacket = t
+	}
+	r.lastReceived = r.now()
+
+	w, ok := r.sources[ip]
+	if !ok {
+		if len(r.sources) >= r.maxSources {
+			r.evict(t)
+		}
+		w = &sourceRateWindow{}
+		r.sources[ip] = w
+	}
+	slot := r.slot(t)
+	i := int(slot % sourceRateSlots)
+	if w.slots[i] != slot {
+		w.slots[i], w.counts[i] = slot, 0
+	}
+	w.counts[i]++
+	if t.After(w.last) {
+		w.last = t
+	}
+}
+
+// ウィンドウの間パケットの無い送信元を捨てる. 無ければ最も長くパケットの無い送信元を捨てる
+func (r *sourceRates) evict(now time.Time) {
+	var oldest string
+	var oldestLast time.Time
+	for ip, w := range r.sources {
+		if now.Sub(w.last) >= r.window {
+			delete(r.sources, ip)
+			continue
+		}
+		if oldest == "" || w.last.Before(oldestLast) {
+			oldest, oldestLast = ip, w.last
+		}
+	}
+	if len(r.sources) >= r.maxSources {
+		delete(r.sources, oldest)
+	}
+}
+
+// rate returns the packets per second of w in the window ending at slot
+// slotで終わるウィンドウでのwの毎秒のパケット数を返します
+func (r *sourceRates) rate(w *sourceRateWindow, slot int64) float64 {
+	packets := 0
+	for i, count := range w.counts {
+		if w.slots[i] <= slot && w.slots[i] > slot-sourceRateSlots {
+			packets += count
+		}
+	}
+	return float64(packets) / r.window.Seconds()
+}
+
+// flagged returns the sources at or above the threshold, the fastest first
+// 閾値以上の送信元を速い順に返します
+func (r *sourceRates) flagged() []SourceRate {
+	now := r.now()
+	if !r.lastPacket.IsZero() {
+		now = r.lastPacket.Add(now.Sub(r.lastReceived))
+	}
+	slot := r.slot(now)
+
+	flagged := []SourceRate{}
+	for ip, w := range r.sources {
+		if rate := r.rate(w, slot); rate >= r.threshold {
+			flagged = append(flagged, SourceRate{IP: ip, Rate: rate})
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool {
+		if flagged[i].Rate != flagged[j].Rate {
+			return flagged[i].Rate > flagged[j].Rate
+		}
+		return flagged[i].IP < flagged[j].IP
+	})
+	return flagged
+}
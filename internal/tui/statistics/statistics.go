@@ -1,6 +1,7 @@
 package statistics
 
 import (
+	"context"
 	"net"
 	"sort"
 	"sync"
@@ -22,11 +23,73 @@ type Statistics struct {
 	// プロトコル統計
 	protocolCounts map[string]int
 	
+	// Packets and bytes per nesting of protocols (Ethernet → IPv4 → TCP → HTTP)
+	// プロトコルの入れ子ごとのパケット数とバイト数(Ethernet → IPv4 → TCP → HTTP)
+	protocols      protocolTree
+	
 	// IP statistics
 	// IP統計
 	sourceIPs      map[string]int
 	destIPs        map[string]int
 	
+	// IPv6 traffic grouped by destination address scope
+	// 宛先アドレスのスコープ別IPv6トラフィック
+	ipv6Scopes     map[string]int
+	
+	// Heuristic OS hints keyed by source IP
+	// 送信元IPごとの推測したOSのヒント
+	osHints        map[string]packemon.OSHint
+	
+	// IP packets from ECN-capable transports (ECT(0), ECT(1) or CE) and those marked CE by a congested router
+	// ECN対応のトランスポート(ECT(0)、ECT(1)、CE)からのIPパケット数と、輻輳したルーターでCEがマークされたパケット数
+	ecnCapable     int
+	ecnCE          int
+	
+	// IP fragments are reassembled so that a fragmented packet is counted once at its full size.
+	// fragments counts the fragments consumed and reassembledPackets the packets rebuilt from them
+	// 分割されたパケットを完全なサイズで1回だけ数えるため、IPフラグメントは再構築する。
+	// fragmentsは受け取ったフラグメント数、reassembledPacketsはそれらから再構築したパケット数
+	reassembler        Reassembler
+	fragments          int
+	reassembledPackets int
+	
+	// Packets and bytes per traffic class (VoIP, video, interactive, bulk) of the classifier's profiles
+	// 分類器のプロファイルによるトラフィッククラス(VoIP、ビデオ、インタラクティブ、バルク)ごとのパケット数とバイト数
+	classifier     *packemon.TrafficClassifier
+	classes        map[string]TrafficClassStats
+	
+	// DNS responses per response code, keyed by the resolver that sent them
+	// 送信したリゾルバごとの、応答コード別のDNSレスポンス数
+	dnsRcodes      map[string]map[string]int
+	
+	// RTP streams keyed by SSRC
+	// SSRCごとのRTPストリーム
+	rtpStreams     *packemon.RTPStreams
+	
+	// Retransmissions and duplicate ACKs per TCP connection
+	// TCPコネクションごとの再送と重複ACK
+	tcpFlows       *packemon.TCPFlows
+	
+	// IP to MAC address mappings learned from ARP and Neighbor Discovery
+	// ARPと近隣探索から得たIPアドレスとMACアドレスの対応
+	neighbors      *packemon.NeighborTable
+	
+	// Decode counts at start or reset, subtracted from packemon.DecodeStats
+	// 開始時またはリセット時の解析回数。packemon.DecodeStatsから差し引く
+	decodeBase     map[string]packemon.DecodeStat
+	
+	// Gaps between the capture timestamps of consecutive packets
+	// 連続するパケットのキャプチャ時刻の間隔
+	gaps           gapHistogram
+	
+	// Packet rate per source IP over a sliding window, to flag sources above the threshold
+	// 閾値を超える送信元にフラグを立てるための、スライディングウィンドウでの送信元IPごとのパケットレート
+	sourceRates    *sourceRates
+	
+	// PTR names of top talkers. nil until StartReverseDNS
+	// トップトーカーのPTR名。StartReverseDNSまではnil
+	reverseDNS     *reverseDNS
+	
 	// Packet rate statistics
 	// パケットレート統計
 	packetCounts   []int
@@ -41,8 +104,97 @@ type Statistics struct {
 // IPCount represents an IP address and its packet count
 // IPCountはIPアドレスとそのパケット数を表します
 type IPCount struct {
-	IP    string
-	Count int
+	IP    string `json:"ip"`
+	Count int    `json:"count"`
+	
+	// Hostname is the PTR name of IP. Empty until resolved, or without reverse DNS
+	// IPのPTR名。解決されるまで、または逆引きが無効な場合は空
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// ECNStats represents the ECN counts of IP packets
+// ECNStatsはIPパケットのECNの集計を表します
+type ECNStats struct {
+	// Capable is the number of packets from ECN-capable transports, including those marked CE
+	// ECN対応のトランスポートからのパケット数。CEがマークされたものを含む
+	Capable int `json:"capable"`
+	
+	// CE is the number of packets marked Congestion Experienced
+	// 輻輳を経験した(CE)とマークされたパケット数
+	CE int `json:"ce"`
+	
+	// MarkingRate is CE / Capable, the share of ECN-capable packets a router marked instead of dropping them
+	// CE / Capable。ルーターが破棄せずにマークしたECN対応パケットの割合
+	MarkingRate float64 `json:"marking_rate"`
+}
+
+// DNSRcodeStats represents the DNS responses of a resolver per response code
+// DNSRcodeStatsはリゾルバのDNSレスポンスの応答コード別の集計を表します
+type DNSRcodeStats struct {
+	// Responses is the number of DNS responses from the resolver
+	// リゾルバからのDNSレスポンス数
+	Responses int `json:"responses"`
+	
+	// Rcodes is the number of responses per response code name, e.g. "NOERROR" or "NXDOMAIN"
+	// 応答コード名("NOERROR"や"NXDOMAIN"など)ごとのレスポンス数
+	Rcodes map[string]int `json:"rcodes"`
+	
+	// FailureRate is the share of SERVFAIL and REFUSED responses, which rise when the resolver has problems.
+	// NXDOMAIN is not a failure of the resolver
+	// SERVFAILとREFUSEDのレスポンスの割合。リゾルバに問題があると上がる。
+	// NXDOMAINはリゾルバの失敗ではない
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// Reassembler rebuilds a packet from its IP fragments. Reassemble returns p as is unless it is a fragment,
+// nil while fragments are missing and the reassembled packet at its full size once the last one arrives.
+// *packemon.IPv4Reassembler and *packemon.IPv6Reassembler satisfy it
+// IPフラグメントからパケットを再構築します。Reassembleはフラグメントでなければpをそのまま返し、
+// フラグメントが欠けている間はnilを、最後のフラグメントが届いたら完全なサイズの再構築済みパケットを返します。
+// *packemon.IPv4Reassemblerと*packemon.IPv6Reassemblerが満たします
+type Reassembler interface {
+	Reassemble(p *packemon.Passive) *packemon.Passive
+}
+
+// 既定の再構築器. IPv4 と IPv6 のフラグメントをそれぞれの再構築器に渡す
+type ipReassembler struct {
+	ipv4 *packemon.IPv4Reassembler
+	ipv6 *packemon.IPv6Reassembler
+}
+
+func newIPReassembler() *ipReassembler {
+	return &ipReassembler{
+		ipv4: packemon.NewIPv4Reassembler(),
+		ipv6: packemon.NewIPv6Reassembler(),
+	}
+}
+
+func (r *ipReassembler) Reassemble(p *packemon.Passive) *packemon.Passive {
+	if p != nil && p.IPv4 != nil {
+		return r.ipv4.Reassemble(p)
+	}
+	return r.ipv6.Reassemble(p)
+}
+
+// FragmentStats represents the counts of IP fragments and the packets reassembled from them
+// FragmentStatsはIPフラグメントと、それらから再構築したパケットの集計を表します
+type FragmentStats struct {
+	// Fragments is the number of fragments received. They are not counted as packets themselves
+	// 受信したフラグメント数。フラグメント自体はパケットとして数えない
+	Fragments int `json:"fragments"`
+	
+	// Reassembled is the number of fragmented packets reassembled, each counted once as a packet at its full size.
+	// Fragments of a packet never completed are not counted as packets or bytes
+	// 再構築した分割パケットの数。それぞれ完全なサイズのパケットとして1回だけ数える。
+	// 揃わなかったパケットのフラグメントはパケット数にもバイト数にも数えない
+	Reassembled int `json:"reassembled"`
+}
+
+// TrafficClassStats represents the packets and bytes of a traffic class
+// TrafficClassStatsはトラフィッククラスのパケット数とバイト数を表します
+type TrafficClassStats struct {
+	Packets int   `json:"packets"`
+	Bytes   int64 `json:"bytes"`
 }
 
 // NewStatistics creates a new statistics object
@@ -53,6 +205,17 @@ func NewStatistics() *Statistics {
 		protocolCounts: make(map[string]int),
 		sourceIPs:      make(map[string]int),
 		destIPs:        make(map[string]int),
+		ipv6Scopes:     make(map[string]int),
+		osHints:        make(map[string]packemon.OSHint),
+		rtpStreams:     packemon.NewRTPStreams(),
+		tcpFlows:       packemon.NewTCPFlows(),
+		neighbors:      packemon.NewNeighborTable(),
+		reassembler:    newIPReassembler(),
+		classifier:     defaultTrafficClassifier(),
+		classes:        make(map[string]TrafficClassStats),
+		dnsRcodes:      make(map[string]map[string]int),
+		decodeBase:     decodeStatsByProtocol(packemon.DecodeStats()),
+		sourceRates:    newSourceRates(DEFAULT_SOURCE_RATE_THRESHOLD, DEFAULT_SOURCE_RATE_WINDOW),
 		packetCounts:   make([]int, 60), // Store 60 seconds of history / 60秒間の履歴を保存
 		lastCountTime:  time.Now(),
 	}
@@ -64,6 +227,20 @@ func (s *Statistics) ProcessPacket(passive *packemon.Passive) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
+	// Count a fragmented packet once it is reassembled, instead of each fragment
+	// 分割されたパケットはフラグメントごとではなく、再構築した時に数える
+	if s.reassembler != nil {
+		reassembled := s.reassembler.Reassemble(passive)
+		if reassembled != passive {
+			s.fragments++
+			if reassembled == nil {
+				return
+			}
+			s.reassembledPackets++
+			passive = reassembled
+		}
+	}
+	
 	// Update total packet count and size
 	// 総パケット数とサイズを更新
 	s.totalPackets++
@@ -76,11 +253,46 @@ func (s *Statistics) ProcessPacket(passive *packemon.Passive) {
 	// Update protocol statistics
 	// プロトコル統計を更新
 	s.updateProtocolStats(passive)
+	s.protocols.add(protocolPath(passive), packetSize)
+	
+	// Update traffic class statistics
+	// トラフィッククラス統計を更新
+	class := s.classifier.Classify(passive)
+	classStats := s.classes[class]
+	classStats.Packets++
+	classStats.Bytes += int64(packetSize)
+	s.classes[class] = classStats
 	
 	// Update IP statistics
 	// IP統計を更新
 	s.updateIPStats(passive)
 	
+	// Update ECN statistics
+	// ECN統計を更新
+	s.updateECNStats(passive)
+	
+	// Update DNS response code statistics
+	// DNS応答コード統計を更新
+	s.updateDNSRcodeStats(passive)
+	
+	// Update RTP stream statistics
+	// RTPストリーム統計を更新
+	if passive.RTP != nil {
+		s.rtpStreams.Update(passive.RTP, packetTime(passive))
+	}
+	
+	// Update TCP flow statistics
+	// TCPフロー統計を更新
+	s.tcpFlows.Update(passive)
+	
+	// Update the neighbor table
+	// 近隣テーブルを更新
+	s.neighbors.Update(passive)
+	
+	// Update inter-packet gap histogram
+	// パケット間隔のヒストグラムを更新
+	s.gaps.add(packetTime(passive))
+	
 	// Update packet rate statistics
 	// パケットレート統計を更新
 	s.updatePacketRateStats()
@@ -89,50 +301,34 @@ func (s *Statistics) ProcessPacket(passive *packemon.Passive) {
 // calculatePacketSize calculates the size of a packet
 // パケットのサイズを計算します
 func (s *Statistics) calculatePacketSize(passive *packemon.Passive) int {
+	// Use the captured frame length if available, which also covers VLAN/tunnel headers
+	// キャプチャしたフレーム長が利用可能な場合はそれを使用（VLANやトンネルのヘッダーも含まれる）
+	if passive.RawLength > 0 {
+		return passive.RawLength
+	}
+	
 	size := 0
 	
-	// Add Ethernet frame size if available
-	// イーサネットフレームサイズが利用可能な場合は追加
+	// Otherwise (synthetic packets) re-serialize the Ethernet frame if available
+	// それ以外（生成したパケット）の場合は、イーサネットフレームが利用可能ならシリアル化
 	if passive.EthernetFrame != nil {
 		size = len(passive.EthernetFrame.Bytes())
 	} else {
-		// Otherwise estimate size based on available layers
-		// それ以外の場合は、利用可能なレイヤーに基づいてサイズを推定
-		
-		// Add IPv4 size
-		// IPv4サイズを追加
-		if passive.IPv4 != nil {
-			size += len(passive.IPv4.Bytes())
-		}
-		
-		// Add IPv6 size
-		// IPv6サイズを追加
-		if passive.IPv6 != nil {
-			size += len(passive.IPv6.Bytes())
-		}
-		
-		// Add TCP size
-		// TCPサイズを追加
-		if passive.TCP != nil {
-			size += len(passive.TCP.Bytes())
-		}
-		
-		// Add UDP size
-		// UDPサイズを追加
-		if passive.UDP != nil {
-			size += len(passive.UDP.Bytes())
-		}
-		
-		// Add ICMP size
-		// ICMPサイズを追加
-		if passive.ICMP != nil {
-			size += len(passive.ICMP.Bytes())
-		}
-		
-		// Add ICMPv6 size
-		// ICMPv6サイズを追加
-		if passive.ICMPv6 != nil {
-			size += len(passive.ICMPv6.Bytes())
+		// Otherwise estimate size from the length field of the outermost available layer, which covers the inner layers
+		// それ以外の場合は、内側のレイヤーを含む最も外側のレイヤーの長さフィールドからサイズを推定
+		switch {
+		case passive.IPv4 != nil:
+			size = int(passive.IPv4.TotalLength)
+		case passive.IPv6 != nil:
+			size = 40 + int(passive.IPv6.PayloadLen)
+		case passive.TCP != nil:
+			size = int(passive.TCP.DataOffset)*4 + len(passive.TCP.Payload)
+		case passive.UDP != nil:
+			size = int(passive.UDP.Length)
+		case passive.ICMP != nil:
+			size = 8 + len(passive.ICMP.Payload)
+		case passive.ICMPv6 != nil:
+			size = 4 + len(passive.ICMPv6.Payload)
 		}
 	}
 	
@@ -172,6 +368,12 @@ func (s *Statistics) updateProtocolStats(passive *packemon.Passive) {
 		s.protocolCounts["UDP"]++
 	}
 	
+	// Update RTP count
+	// RTP数を更新
+	if passive.RTP != nil {
+		s.protocolCounts["RTP"]++
+	}
+	
 	// Update ICMP count
 	// ICMP数を更新
 	if passive.ICMP != nil {
@@ -221,6 +423,54 @@ func (s *Statistics) updateProtocolStats(passive *packemon.Passive) {
 	}
 }
 
+// updateECNStats counts ECN-capable and CE-marked IP packets
+// ECN対応のIPパケットとCEがマークされたIPパケットを数えます
+func (s *Statistics) updateECNStats(passive *packemon.Passive) {
+	var ecn packemon.ECN
+	switch {
+	case passive.IPv4 != nil:
+		ecn = passive.IPv4.ECN()
+	case passive.IPv6 != nil:
+		ecn = passive.IPv6.ECN()
+	default:
+		return
+	}
+	
+	if ecn == packemon.ECN_NOT_ECT {
+		return
+	}
+	s.ecnCapable++
+	if ecn == packemon.ECN_CE {
+		s.ecnCE++
+	}
+}
+
+// updateDNSRcodeStats counts DNS responses per resolver and response code
+// リゾルバと応答コードごとにDNSレスポンスを数えます
+func (s *Statistics) updateDNSRcodeStats(passive *packemon.Passive) {
+	if passive.DNS == nil || !packemon.IsDNSResponse(passive.DNS.Flags) {
+		return
+	}
+	
+	// レスポンスの送信元をリゾルバとする
+	var resolver string
+	switch {
+	case passive.IPv4 != nil:
+		resolver = net.IP(passive.IPv4.SrcIP).String()
+	case passive.IPv6 != nil:
+		resolver = net.IP(passive.IPv6.SrcIP).String()
+	default:
+		return
+	}
+	
+	rcodes, ok := s.dnsRcodes[resolver]
+	if !ok {
+		rcodes = make(map[string]int)
+		s.dnsRcodes[resolver] = rcodes
+	}
+	rcodes[packemon.DNSRcodeName(packemon.DNSRcode(passive.DNS.Flags))]++
+}
+
 // updateIPStats updates IP statistics
 // IP統計を更新します
 func (s *Statistics) updateIPStats(passive *packemon.Passive) {
@@ -229,17 +479,29 @@ func (s *Statistics) updateIPStats(passive *packemon.Passive) {
 	var srcIP, dstIP net.IP
 	
 	if passive.IPv4 != nil {
-		srcIP = passive.IPv4.SrcAddr
-		dstIP = passive.IPv4.DstAddr
+		srcIP = passive.IPv4.SrcIP
+		dstIP = passive.IPv4.DstIP
 	} else if passive.IPv6 != nil {
-		srcIP = passive.IPv6.SrcAddr
-		dstIP = passive.IPv6.DstAddr
+		srcIP = passive.IPv6.SrcIP
+		dstIP = passive.IPv6.DstIP
 	}
 	
 	// Update source IP count
 	// 送信元IP数を更新
 	if srcIP != nil {
 		s.sourceIPs[srcIP.String()]++
+		
+		// Track the packet rate of the source over the sliding window
+		// スライディングウィンドウでの送信元のパケットレートを追跡
+		s.sourceRates.add(srcIP.String(), packetTime(passive))
+		
+		// Keep a hint refined by a TCP SYN over later TTL-only hints
+		// TCP SYNで絞り込んだヒントは、後のTTLのみのヒントで上書きしない
+		if hint, ok := packemon.GuessOS(passive); ok {
+			if prev, ok := s.osHints[srcIP.String()]; !ok || hint.FromTCPSYN || !prev.FromTCPSYN {
+				s.osHints[srcIP.String()] = hint
+			}
+		}
 	}
 	
 	// Update destination IP count
@@ -247,6 +509,21 @@ func (s *Statistics) updateIPStats(passive *packemon.Passive) {
 	if dstIP != nil {
 		s.destIPs[dstIP.String()]++
 	}
+	
+	// Update IPv6 scope count
+	// IPv6スコープ数を更新
+	if passive.IPv6 != nil {
+		s.ipv6Scopes[packemon.IPv6Scope(passive.IPv6.DstIP)]++
+	}
+}
+
+// packetTime returns the capture time of a packet, or now for a synthetic packet without one
+// パケットのキャプチャ時刻を返します。時刻の無い生成したパケットの場合は現在時刻を返します
+func packetTime(passive *packemon.Passive) time.Time {
+	if passive.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return passive.Timestamp
 }
 
 // updatePacketRateStats updates packet rate statistics
@@ -347,6 +624,252 @@ func (s *Statistics) ProtocolDistribution() map[string]int {
 	return counts
 }
 
+// ProtocolHierarchy returns the packets and bytes at each nesting level of protocols.
+// The root "Frame" counts every packet and its children are the outermost layers, usually Ethernet
+// プロトコルの入れ子の各階層のパケット数とバイト数を返します。
+// ルートの"Frame"はすべてのパケットを数え、その子は最も外側のレイヤー(通常はEthernet)です
+func (s *Statistics) ProtocolHierarchy() ProtocolNode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.protocols.snapshot("Frame")
+}
+
+// SetReassembler replaces the IP reassembler, e.g. to share the one the capture already uses so its output passes through as is.
+// nil counts every fragment as a packet
+// IP再構築器を置き換えます。キャプチャが使っているものを共有して、その出力をそのまま通す場合などに使います。
+// nilの場合はフラグメントをそれぞれパケットとして数えます
+func (s *Statistics) SetReassembler(r Reassembler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	s.reassembler = r
+}
+
+// FragmentStats returns the number of IP fragments received and packets reassembled from them
+// 受信したIPフラグメント数と、それらから再構築したパケット数を返します
+func (s *Statistics) FragmentStats() FragmentStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return FragmentStats{Fragments: s.fragments, Reassembled: s.reassembledPackets}
+}
+
+// defaultTrafficClassifier returns the classifier of packemon.DefaultTrafficClassProfiles, which are always valid
+// 常に有効なpackemon.DefaultTrafficClassProfilesの分類器を返します
+func defaultTrafficClassifier() *packemon.TrafficClassifier {
+	classifier, _ := packemon.NewTrafficClassifier(packemon.DefaultTrafficClassProfiles())
+	return classifier
+}
+
+// SetTrafficClassifier replaces the classifier of ClassDistribution, e.g. with the profiles of the config.
+// Packets already counted keep their class. nil counts every packet as packemon.TRAFFIC_CLASS_BEST_EFFORT
+// ClassDistributionの分類器を置き換えます。設定ファイルのプロファイルを使う場合などに使います。
+// 数えたパケットのクラスはそのままです。nilの場合はすべてのパケットをpackemon.TRAFFIC_CLASS_BEST_EFFORTとして数えます
+func (s *Statistics) SetTrafficClassifier(c *packemon.TrafficClassifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	s.classifier = c
+}
+
+// ClassDistribution returns the packets and bytes per traffic class, e.g. "voip" or "bulk".
+// Packets matching no profile are counted as packemon.TRAFFIC_CLASS_BEST_EFFORT
+// トラフィッククラス("voip"や"bulk"など)ごとのパケット数とバイト数を返します。
+// どのプロファイルにも一致しないパケットはpackemon.TRAFFIC_CLASS_BEST_EFFORTとして数えます
+func (s *Statistics) ClassDistribution() map[string]TrafficClassStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	classes := make(map[string]TrafficClassStats, len(s.classes))
+	for class, stats := range s.classes {
+		classes[class] = stats
+	}
+	
+	return classes
+}
+
+// ECNStats returns the ECN-capable and CE-marked packet counts, read together so the marking rate is consistent with them
+// ECN対応とCEがマークされたパケット数を返します。マーク率と矛盾しないよう一度に取得します
+func (s *Statistics) ECNStats() ECNStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.ecnStats()
+}
+
+func (s *Statistics) ecnStats() ECNStats {
+	stats := ECNStats{
+		Capable: s.ecnCapable,
+		CE:      s.ecnCE,
+	}
+	if s.ecnCapable > 0 {
+		stats.MarkingRate = float64(s.ecnCE) / float64(s.ecnCapable)
+	}
+	return stats
+}
+
+// DNSRcodeStats returns the DNS response codes per resolver, keyed by the IP address of the resolver
+// リゾルバのIPアドレスをキーに、リゾルバごとのDNSの応答コードを返します
+func (s *Statistics) DNSRcodeStats() map[string]DNSRcodeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.dnsRcodeStats()
+}
+
+func (s *Statistics) dnsRcodeStats() map[string]DNSRcodeStats {
+	stats := make(map[string]DNSRcodeStats, len(s.dnsRcodes))
+	for resolver, rcodes := range s.dnsRcodes {
+		resolverStats := DNSRcodeStats{Rcodes: make(map[string]int, len(rcodes))}
+		for rcode, count := range rcodes {
+			resolverStats.Rcodes[rcode] = count
+			resolverStats.Responses += count
+		}
+		failures := rcodes[packemon.DNSRcodeName(packemon.DNS_RCODE_SERVFAIL)] + rcodes[packemon.DNSRcodeName(packemon.DNS_RCODE_REFUSED)]
+		resolverStats.FailureRate = float64(failures) / float64(resolverStats.Responses)
+		stats[resolver] = resolverStats
+	}
+	return stats
+}
+
+// IPv6ScopeDistribution returns the number of IPv6 packets per destination address scope
+// 宛先アドレスのスコープ別IPv6パケット数を返します
+func (s *Statistics) IPv6ScopeDistribution() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	counts := make(map[string]int)
+	for scope, count := range s.ipv6Scopes {
+		counts[scope] = count
+	}
+	
+	return counts
+}
+
+// OSHints returns the heuristic OS hint of each source IP, guessed from the TTL and TCP SYNs.
+// Sources that sent a SYN matching the fingerprint database carry its label and confidence
+// TTLとTCP SYNから推測した、送信元IPごとのOSのヒント(推測)を返します。
+// フィンガープリントのデータベースに一致したSYNを送った送信元には、そのラベルと確からしさが含まれます
+func (s *Statistics) OSHints() map[string]packemon.OSHint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	hints := make(map[string]packemon.OSHint, len(s.osHints))
+	for ip, hint := range s.osHints {
+		hints[ip] = hint
+	}
+	
+	return hints
+}
+
+// RTPStreamStats returns the packet count, loss and jitter per RTP stream
+// RTPストリームごとのパケット数、損失、ジッターを返します
+func (s *Statistics) RTPStreamStats() []packemon.RTPStreamStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.rtpStreams.Stats()
+}
+
+// SetSourceRateThreshold sets the packet rate per second above which a source is flagged, and the sliding window
+// the rate is measured over. Values of 0 or less keep the current ones. The rates measured so far are cleared
+// 送信元にフラグを立てる毎秒のパケットレートと、レートを測るスライディングウィンドウを設定します。
+// 0以下の値は現在の値のままにします。それまでに測ったレートはクリアします
+func (s *Statistics) SetSourceRateThreshold(packetsPerSecond float64, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	threshold, current := s.sourceRates.threshold, s.sourceRates.window
+	if packetsPerSecond > 0 {
+		threshold = packetsPerSecond
+	}
+	if window > 0 {
+		current = window
+	}
+	s.sourceRates = newSourceRates(threshold, current)
+}
+
+// FlaggedSources returns the source IPs sending at or above the threshold over the sliding window, the fastest first.
+// Useful to spot a flooding or scanning source, or one a rate limiter will throttle
+// スライディングウィンドウで閾値以上の速さで送信している送信元IPを速い順に返します。
+// 大量に送りつけている送信元やスキャンしている送信元、レート制限にかかる送信元を見つけるのに使えます
+func (s *Statistics) FlaggedSources() []SourceRate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.sourceRates.flagged()
+}
+
+// TCPFlowStats returns the retransmissions, duplicate ACKs, selectively acknowledged segments, windows and throughput per TCP connection
+// TCPコネクションごとの再送、重複ACK、選択的に確認応答されたセグメント、ウィンドウ、スループットを返します
+func (s *Statistics) TCPFlowStats() []packemon.TCPFlowStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.tcpFlows.Stats()
+}
+
+// Neighbors returns the IP to MAC address mappings seen in ARP and Neighbor Discovery
+// ARPと近隣探索で観測したIPアドレスとMACアドレスの対応を返します
+func (s *Statistics) Neighbors() []packemon.NeighborEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.neighbors.Snapshot()
+}
+
+// DecodeStats returns the per-protocol parser attempts, successes and failures since the statistics were started or reset
+// 統計の開始時またはリセット以降の、プロトコルごとのパーサーの試行・成功・失敗回数を返します
+func (s *Statistics) DecodeStats() []packemon.DecodeStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	stats := []packemon.DecodeStat{}
+	for _, stat := range packemon.DecodeStats() {
+		// Counters reset by packemon.ResetDecodeStats are smaller than the base, so use them as they are
+		// packemon.ResetDecodeStatsでリセットされたカウンターはベースより小さくなるため、そのまま使う
+		if base, ok := s.decodeBase[stat.Protocol]; ok && base.Attempts <= stat.Attempts && base.Failures <= stat.Failures {
+			stat.Attempts -= base.Attempts
+			stat.Successes -= base.Successes
+			stat.Failures -= base.Failures
+		}
+		if stat.Attempts > 0 {
+			stats = append(stats, stat)
+		}
+	}
+	
+	return stats
+}
+
+// decodeStatsByProtocol indexes decode stats by protocol
+// 解析回数をプロトコル名で引けるようにします
+func decodeStatsByProtocol(stats []packemon.DecodeStat) map[string]packemon.DecodeStat {
+	byProtocol := make(map[string]packemon.DecodeStat, len(stats))
+	for _, stat := range stats {
+		byProtocol[stat.Protocol] = stat
+	}
+	return byProtocol
+}
+
+// InterPacketGaps returns the histogram of gaps between consecutive packets, bucketed logarithmically
+// 連続するパケットの間隔のヒストグラムを対数スケールのバケットで返します
+func (s *Statistics) InterPacketGaps() []GapBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.gaps.snapshot()
+}
+
+// InterPacketGapPercentile returns the p-th percentile (0-100) of the gaps between consecutive packets, rounded up to a bucket bound
+// 連続するパケットの間隔のpパーセンタイル(0-100)を、バケットの境界に切り上げて返します
+func (s *Statistics) InterPacketGapPercentile(p float64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.gaps.percentile(p)
+}
+
 // PacketRateHistory returns the packet rate history
 // パケットレート履歴を返します
 func (s *Statistics) PacketRateHistory() []float64 {
@@ -400,12 +923,34 @@ func (s *Statistics) topIPs(ips map[string]int, n int) []IPCount {
 	// Return top n
 	// トップnを返す
 	if len(ipCounts) > n {
-		return ipCounts[:n]
+		ipCounts = ipCounts[:n]
+	}
+	
+	// Annotate with cached PTR names. Uncached IPs are resolved in the background and show up on a later call
+	// キャッシュ済みのPTR名を付ける。未解決のIPはバックグラウンドで解決され、後の呼び出しで反映される
+	if s.reverseDNS != nil {
+		for i := range ipCounts {
+			ipCounts[i].Hostname = s.reverseDNS.hostname(ipCounts[i].IP)
+		}
 	}
 	
 	return ipCounts
 }
 
+// StartReverseDNS annotates top talkers with PTR names from resolver until ctx is done.
+// Lookups are rate limited, time out after REVERSE_DNS_TIMEOUT and run off the packet path; names appear once resolved.
+// ctxが終了するまで、resolverから得たPTR名をトップトーカーに付けます。
+// 問い合わせは頻度が制限され、REVERSE_DNS_TIMEOUTでタイムアウトし、パケット処理とは別に行われます。名前は解決後に反映されます
+func (s *Statistics) StartReverseDNS(ctx context.Context, resolver ReverseResolver) {
+	r := newReverseDNS(resolver)
+	
+	s.mu.Lock()
+	s.reverseDNS = r
+	s.mu.Unlock()
+	
+	go r.run(ctx)
+}
+
 // Reset resets all statistics
 // すべての統計をリセットします
 func (s *Statistics) Reset() {
@@ -416,8 +961,27 @@ func (s *Statistics) Reset() {
 	s.totalPackets = 0
 	s.totalBytes = 0
 	s.protocolCounts = make(map[string]int)
+	s.protocols = protocolTree{}
 	s.sourceIPs = make(map[string]int)
 	s.destIPs = make(map[string]int)
+	s.ipv6Scopes = make(map[string]int)
+	s.osHints = make(map[string]packemon.OSHint)
+	s.ecnCapable = 0
+	s.ecnCE = 0
+	s.fragments = 0
+	s.reassembledPackets = 0
+	// SetReassembler で共有された再構築器はキャプチャのものなので、既定のものだけ作り直す
+	if _, ok := s.reassembler.(*ipReassembler); ok {
+		s.reassembler = newIPReassembler()
+	}
+	s.classes = make(map[string]TrafficClassStats)
+	s.dnsRcodes = make(map[string]map[string]int)
+	s.rtpStreams = packemon.NewRTPStreams()
+	s.tcpFlows = packemon.NewTCPFlows()
+	s.neighbors = packemon.NewNeighborTable()
+	s.decodeBase = decodeStatsByProtocol(packemon.DecodeStats())
+	s.gaps = gapHistogram{}
+	s.sourceRates = newSourceRates(s.sourceRates.threshold, s.sourceRates.window)
 	s.packetCounts = make([]int, 60)
 	s.lastCountTime = time.Now()
 	s.currentCount = 0
@@ -0,0 +1,513 @@
+package statistics
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ddddddO/packemon"
+)
+
+// TestCalculatePacketSizeRawLength tests that the captured frame length is used as the packet size
+// キャプチャしたフレーム長がパケットサイズとして使われることをテストします
+func TestCalculatePacketSizeRawLength(t *testing.T) {
+	s := NewStatistics()
+
+	// VLANタグ付き(4 bytes)の最大フレーム
+	const frameLength = 14 + 4 + 1500
+	s.ProcessPacket(&packemon.Passive{
+		UDP: &packemon.UDPPacket{
+			SrcPort: 0xd4c0,
+			DstPort: packemon.PORT_DNS,
+			Length:  8,
+		},
+		RawLength: frameLength,
+	})
+
+	if got := s.TotalBytes(); got != frameLength {
+		t.Errorf("TotalBytes() = %d, want %d", got, frameLength)
+	}
+}
+
+// TestCalculatePacketSizeSynthetic tests the estimation for synthetic packets
+// 生成したパケットのサイズ推定をテストします
+func TestCalculatePacketSizeSynthetic(t *testing.T) {
+	s := NewStatistics()
+
+	// 長さフィールドは UDP ヘッダー(8 bytes)とペイロードの合計
+	udp := &packemon.UDPPacket{
+		SrcPort: 0xd4c0,
+		DstPort: packemon.PORT_DNS,
+		Length:  8 + 4,
+		Payload: []byte{0x01, 0x02, 0x03, 0x04},
+	}
+	s.ProcessPacket(&packemon.Passive{UDP: udp})
+
+	if got := s.TotalBytes(); got != 12 {
+		t.Errorf("TotalBytes() = %d, want %d", got, 12)
+	}
+
+	// 内側のレイヤーは外側の IPv4 の全長に含まれるので重ねて数えない
+	s.ProcessPacket(&packemon.Passive{IPv4: &packemon.IPv4Packet{TotalLength: 20 + 12}, UDP: udp})
+	if got := s.TotalBytes(); got != 12+32 {
+		t.Errorf("TotalBytes() = %d, want %d", got, 12+32)
+	}
+}
+
+// TestInterPacketGaps tests that gaps between capture timestamps land in their logarithmic buckets
+// キャプチャ時刻の間隔が対数スケールのバケットに集計されることをテストします
+func TestInterPacketGaps(t *testing.T) {
+	s := NewStatistics()
+
+	// 1ms 間隔のパケットの間に 3µs 後のパケットが 1 つ (バースト)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timestamps := []time.Time{
+		base,
+		base.Add(time.Millisecond),
+		base.Add(time.Millisecond + 3*time.Microsecond),
+		base.Add(2*time.Millisecond + 3*time.Microsecond),
+		base.Add(3*time.Millisecond + 3*time.Microsecond),
+	}
+	for _, timestamp := range timestamps {
+		s.ProcessPacket(&packemon.Passive{RawLength: 60, Timestamp: timestamp})
+	}
+
+	buckets := s.InterPacketGaps()
+	if len(buckets) != GAP_HISTOGRAM_BUCKETS {
+		t.Fatalf("len(InterPacketGaps()) = %d, want %d", len(buckets), GAP_HISTOGRAM_BUCKETS)
+	}
+	want := map[time.Duration]int{
+		4 * time.Microsecond:    1, // 3µs は [2µs, 4µs)
+		1024 * time.Microsecond: 3, // 1ms は [512µs, 1024µs)
+	}
+	for _, bucket := range buckets {
+		if bucket.Count != want[bucket.UpperBound] {
+			t.Errorf("bucket < %s has %d gaps, want %d", bucket.UpperBound, bucket.Count, want[bucket.UpperBound])
+		}
+	}
+
+	if got := s.InterPacketGapPercentile(25); got != 4*time.Microsecond {
+		t.Errorf("InterPacketGapPercentile(25) = %s, want 4µs", got)
+	}
+	if got := s.InterPacketGapPercentile(99); got != 1024*time.Microsecond {
+		t.Errorf("InterPacketGapPercentile(99) = %s, want 1.024ms", got)
+	}
+
+	s.Reset()
+	if got := s.InterPacketGapPercentile(50); got != 0 {
+		t.Errorf("InterPacketGapPercentile(50) after Reset() = %s, want 0", got)
+	}
+}
+
+// 逆引きのスタブ. 問い合わせ回数を数える
+type stubReverseResolver struct {
+	mu      sync.Mutex
+	names   map[string]string
+	lookups map[string]int
+}
+
+func (r *stubReverseResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups[addr]++
+	if name, ok := r.names[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, context.DeadlineExceeded
+}
+
+// TestReverseDNS tests that PTR names of top talkers appear once resolved in the background, and are looked up only once
+// トップトーカーのPTR名がバックグラウンドで解決された後に反映され、問い合わせが1回だけであることをテストします
+func TestReverseDNS(t *testing.T) {
+	resolver := &stubReverseResolver{
+		names:   map[string]string{"192.168.0.1": "router.example.com."},
+		lookups: map[string]int{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := NewStatistics()
+	s.StartReverseDNS(ctx, resolver)
+	for i := 0; i < 3; i++ {
+		s.ProcessPacket(&packemon.Passive{RawLength: 60, IPv4: &packemon.IPv4Packet{SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 2}}})
+	}
+
+	// 最初の呼び出しでは未解決
+	if got := s.TopSourceIPs(5); len(got) != 1 || got[0].Hostname != "" {
+		t.Fatalf("TopSourceIPs() before resolution = %+v, want 192.168.0.1 without a name", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		src, dst := s.TopSourceIPs(5), s.TopDestinationIPs(5)
+		if src[0].Hostname == "router.example.com" && len(dst) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("TopSourceIPs() = %+v, want router.example.com after resolution", src)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 名前の無い宛先も失敗としてキャッシュされ、再度問い合わせない
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		resolver.mu.Lock()
+		resolved := resolver.lookups["192.168.0.2"] > 0
+		resolver.mu.Unlock()
+		if resolved {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("192.168.0.2 was never looked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		s.TopSourceIPs(5)
+		if got := s.TopDestinationIPs(5); got[0].Hostname != "" {
+			t.Errorf("TopDestinationIPs() = %+v, want no name for a failed lookup", got)
+		}
+	}
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+	for addr, n := range resolver.lookups {
+		if n != 1 {
+			t.Errorf("%s looked up %d times, want 1", addr, n)
+		}
+	}
+}
+
+// TestECNStats tests the ECN marking rate after feeding CE-marked and ECN-capable packets
+// CEがマークされたパケットとECN対応のパケットを与えた後のECNのマーク率をテストします
+func TestECNStats(t *testing.T) {
+	s := NewStatistics()
+
+	for _, tos := range []uint8{
+		0xb8, // EF, Not-ECT
+		0xba, // EF, ECT(0)
+		0xba,
+		0xb9, // EF, ECT(1)
+		0xbb, // EF, CE
+	} {
+		s.ProcessPacket(&packemon.Passive{RawLength: 60, IPv4: &packemon.IPv4Packet{TOS: tos, SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 2}}})
+	}
+	// IPv6 の Traffic Class も数える
+	s.ProcessPacket(&packemon.Passive{RawLength: 80, IPv6: &packemon.IPv6Packet{TrafficClass: 0x03}})
+	// IP 以外は数えない
+	s.ProcessPacket(&packemon.Passive{RawLength: 60, ARP: &packemon.ARPPacket{}})
+
+	got := s.ECNStats()
+	if got.Capable != 5 || got.CE != 2 || got.MarkingRate != 0.4 {
+		t.Errorf("ECNStats() = %+v, want 5 capable, 2 CE and a marking rate of 0.4", got)
+	}
+	if snapshot := s.Snapshot(5); snapshot.ECN != got {
+		t.Errorf("Snapshot().ECN = %+v, want %+v", snapshot.ECN, got)
+	}
+
+	s.Reset()
+	if got := s.ECNStats(); got != (ECNStats{}) {
+		t.Errorf("ECNStats() after Reset = %+v", got)
+	}
+}
+
+// TestProtocolHierarchy tests the protocol tree after feeding an HTTP request over TCP/IPv4, a DNS query over UDP/IPv4 and a GRE packet
+// TCP/IPv4上のHTTPリクエスト、UDP/IPv4上のDNSクエリおよびGREパケットを与えた後のプロトコルの木をテストします
+func TestProtocolHierarchy(t *testing.T) {
+	s := NewStatistics()
+
+	s.ProcessPacket(&packemon.Passive{
+		RawLength:     200,
+		EthernetFrame: &packemon.EthernetFrame{},
+		IPv4:          &packemon.IPv4Packet{SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 2}},
+		TCP:           &packemon.TCPPacket{},
+		HTTP:          &packemon.HTTPRequest{},
+	})
+	s.ProcessPacket(&packemon.Passive{
+		RawLength:     80,
+		EthernetFrame: &packemon.EthernetFrame{},
+		IPv4:          &packemon.IPv4Packet{SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 53}},
+		UDP:           &packemon.UDPPacket{},
+		DNS:           &packemon.DNSPacket{},
+	})
+	// 解析しない GRE はプロトコル番号の名前で数える
+	s.ProcessPacket(&packemon.Passive{
+		RawLength:     120,
+		EthernetFrame: &packemon.EthernetFrame{},
+		IPv4:          &packemon.IPv4Packet{Protocol: packemon.IPv4_PROTO_GRE, SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 254}},
+	})
+
+	want := ProtocolNode{Protocol: "Frame", Packets: 3, Bytes: 400, Children: []ProtocolNode{
+		{Protocol: "Ethernet", Packets: 3, Bytes: 400, Children: []ProtocolNode{
+			{Protocol: "IPv4", Packets: 3, Bytes: 400, Children: []ProtocolNode{
+				{Protocol: "TCP", Packets: 1, Bytes: 200, Children: []ProtocolNode{
+					{Protocol: "HTTP", Packets: 1, Bytes: 200},
+				}},
+				{Protocol: "GRE", Packets: 1, Bytes: 120},
+				{Protocol: "UDP", Packets: 1, Bytes: 80, Children: []ProtocolNode{
+					{Protocol: "DNS", Packets: 1, Bytes: 80},
+				}},
+			}},
+		}},
+	}}
+	if got := s.ProtocolHierarchy(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ProtocolHierarchy() = %+v, want %+v", got, want)
+	}
+	if got := s.Snapshot(5).ProtocolHierarchy; !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot().ProtocolHierarchy = %+v, want %+v", got, want)
+	}
+
+	s.Reset()
+	if got := s.ProtocolHierarchy(); !reflect.DeepEqual(got, ProtocolNode{Protocol: "Frame"}) {
+		t.Errorf("ProtocolHierarchy() after Reset = %+v", got)
+	}
+}
+
+// fragmentTestFrame returns the Ethernet frame of an IPv4 or IPv6 fragment carrying data at offset
+// offsetからdataを運ぶIPv4またはIPv6フラグメントのEthernetフレームを返します
+func fragmentTestFrame(t *testing.T, etherType uint16, offset int, more bool, data []byte) *packemon.Passive {
+	t.Helper()
+	var packet []byte
+	switch etherType {
+	case packemon.ETHER_TYPE_IPv4:
+		ipv4 := []byte{0x45, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x00, 0x40, packemon.IPv4_PROTO_UDP, 0x00, 0x00, 192, 0, 2, 1, 192, 0, 2, 2}
+		binary.BigEndian.PutUint16(ipv4[2:4], uint16(len(ipv4)+len(data)))
+		flagsOffset := uint16(offset / 8)
+		if more {
+			flagsOffset |= 0x2000
+		}
+		binary.BigEndian.PutUint16(ipv4[6:8], flagsOffset)
+		packet = ipv4
+	case packemon.ETHER_TYPE_IPv6:
+		fragment := []byte{packemon.IPv6_NEXT_HEADER_UDP, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x34}
+		offsetFlags := uint16(offset/8) << 3
+		if more {
+			offsetFlags |= 0x0001
+		}
+		binary.BigEndian.PutUint16(fragment[2:4], offsetFlags)
+
+		ipv6 := []byte{0x60, 0x00, 0x00, 0x00, 0x00, 0x00, packemon.IPv6_NEXT_HEADER_FRAGMENT, 0x40}
+		binary.BigEndian.PutUint16(ipv6[4:6], uint16(len(fragment)+len(data)))
+		ipv6 = append(ipv6, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01)
+		ipv6 = append(ipv6, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x02)
+		packet = append(ipv6, fragment...)
+	default:
+		t.Fatalf("unsupported ether type 0x%04x", etherType)
+	}
+
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b}
+	frame = binary.BigEndian.AppendUint16(frame, etherType)
+	frame = append(append(frame, packet...), data...)
+	passive, err := packemon.DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passive
+}
+
+// 2つのフラグメントに分割する、ポート53宛の1408バイトの UDP データグラム
+func fragmentTestUDP() []byte {
+	payload := make([]byte, 1400)
+	udp := []byte{0xc3, 0x50, 0x00, 0x35, 0x00, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	return append(udp, payload...)
+}
+
+// TestFragmentStats tests that a UDP datagram split into two IPv6 fragments is counted once at its reassembled size
+// 2つのIPv6フラグメントに分割されたUDPデータグラムが、再構築したサイズで1回だけ数えられることをテストします
+func TestFragmentStats(t *testing.T) {
+	s := NewStatistics()
+
+	udp := fragmentTestUDP()
+
+	s.ProcessPacket(fragmentTestFrame(t, packemon.ETHER_TYPE_IPv6, 0, true, udp[:1232]))
+	if got := s.TotalPackets(); got != 0 {
+		t.Errorf("TotalPackets() = %d after the first fragment, want 0", got)
+	}
+	s.ProcessPacket(fragmentTestFrame(t, packemon.ETHER_TYPE_IPv6, 1232, false, udp[1232:]))
+
+	// Ethernet 14 + IPv6 40 + UDP 1408 (Fragment ヘッダーは取り除かれる)
+	const fullSize = 14 + 40 + 8 + 1400
+	if got := s.TotalPackets(); got != 1 {
+		t.Errorf("TotalPackets() = %d, want 1", got)
+	}
+	if got := s.TotalBytes(); got != fullSize {
+		t.Errorf("TotalBytes() = %d, want %d", got, fullSize)
+	}
+	if got := s.ProtocolDistribution()["UDP"]; got != 1 {
+		t.Errorf("UDP count = %d, want 1", got)
+	}
+	want := FragmentStats{Fragments: 2, Reassembled: 1}
+	if got := s.FragmentStats(); got != want {
+		t.Errorf("FragmentStats() = %+v, want %+v", got, want)
+	}
+	if got := s.Snapshot(5).Fragments; got != want {
+		t.Errorf("Snapshot().Fragments = %+v, want %+v", got, want)
+	}
+}
+
+// TestFragmentStatsIPv4 tests that IPv4 fragments are reassembled too, and that Reset drops the fragments still pending
+// IPv4のフラグメントも再構築されること、Resetで待機中のフラグメントが破棄されることをテストします
+func TestFragmentStatsIPv4(t *testing.T) {
+	s := NewStatistics()
+
+	udp := fragmentTestUDP()
+
+	s.ProcessPacket(fragmentTestFrame(t, packemon.ETHER_TYPE_IPv4, 0, true, udp[:1200]))
+	s.ProcessPacket(fragmentTestFrame(t, packemon.ETHER_TYPE_IPv4, 1200, false, udp[1200:]))
+
+	// Ethernet 14 + IPv4 20 + UDP 1408
+	const fullSize = 14 + 20 + 8 + 1400
+	if got := s.TotalPackets(); got != 1 {
+		t.Errorf("TotalPackets() = %d, want 1", got)
+	}
+	if got := s.TotalBytes(); got != fullSize {
+		t.Errorf("TotalBytes() = %d, want %d", got, fullSize)
+	}
+	if want, got := (FragmentStats{Fragments: 2, Reassembled: 1}), s.FragmentStats(); got != want {
+		t.Errorf("FragmentStats() = %+v, want %+v", got, want)
+	}
+
+	// Reset の前に届いたフラグメントとは組み合わせない
+	s.ProcessPacket(fragmentTestFrame(t, packemon.ETHER_TYPE_IPv4, 0, true, udp[:1200]))
+	s.Reset()
+	s.ProcessPacket(fragmentTestFrame(t, packemon.ETHER_TYPE_IPv4, 1200, false, udp[1200:]))
+	if got := s.TotalPackets(); got != 0 {
+		t.Errorf("TotalPackets() after Reset = %d, want 0", got)
+	}
+	if want, got := (FragmentStats{Fragments: 1}), s.FragmentStats(); got != want {
+		t.Errorf("FragmentStats() after Reset = %+v, want %+v", got, want)
+	}
+}
+
+// TestClassDistribution tests that an RTP-like UDP flow is counted as VoIP, and that other traffic falls back to best effort
+// RTPのようなUDPフローがVoIPとして数えられ、それ以外のトラフィックがベストエフォートになることをテストします
+func TestClassDistribution(t *testing.T) {
+	s := NewStatistics()
+
+	// EF(0xb8)でマークされた、20msごとの音声のようなUDPフロー
+	for i := 0; i < 3; i++ {
+		s.ProcessPacket(&packemon.Passive{
+			RawLength: 214,
+			IPv4:      &packemon.IPv4Packet{TOS: 0xb8},
+			UDP:       &packemon.UDPPacket{SrcPort: 16384, DstPort: 16386},
+		})
+	}
+	s.ProcessPacket(&packemon.Passive{
+		RawLength: 1514,
+		IPv4:      &packemon.IPv4Packet{},
+		TCP:       &packemon.TCPPacket{SrcPort: 51000, DstPort: 443},
+	})
+
+	want := map[string]TrafficClassStats{
+		packemon.TRAFFIC_CLASS_VOIP:        {Packets: 3, Bytes: 3 * 214},
+		packemon.TRAFFIC_CLASS_BEST_EFFORT: {Packets: 1, Bytes: 1514},
+	}
+	if got := s.ClassDistribution(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ClassDistribution() = %v, want %v", got, want)
+	}
+	if got := s.Snapshot(REPORT_TOP_TALKERS).Classes; !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot().Classes = %v, want %v", got, want)
+	}
+
+	s.Reset()
+	if got := s.ClassDistribution(); len(got) != 0 {
+		t.Errorf("ClassDistribution() = %v after Reset, want empty", got)
+	}
+}
+
+// TestDNSRcodeStats tests counting DNS responses per resolver and response code, and the failure rate of SERVFAIL and REFUSED
+// リゾルバと応答コードごとにDNSレスポンスを数えること、およびSERVFAILとREFUSEDの失敗率をテストします
+func TestDNSRcodeStats(t *testing.T) {
+	s := NewStatistics()
+	dns := func(resolver byte, flags uint16) *packemon.Passive {
+		return &packemon.Passive{
+			RawLength: 80,
+			IPv4:      &packemon.IPv4Packet{SrcIP: []byte{192, 168, 0, resolver}, DstIP: []byte{192, 168, 0, 10}},
+			UDP:       &packemon.UDPPacket{SrcPort: 53, DstPort: 40000},
+			DNS:       &packemon.DNSPacket{Flags: flags},
+		}
+	}
+
+	// クエリは数えない
+	s.ProcessPacket(dns(1, 0x0100))
+	for _, flags := range []uint16{0x8180, 0x8180, 0x8183, 0x8182} { // NOERROR, NOERROR, NXDOMAIN, SERVFAIL
+		s.ProcessPacket(dns(1, flags))
+	}
+	s.ProcessPacket(dns(2, 0x8185)) // REFUSED
+	s.ProcessPacket(dns(2, 0x818b)) // 未定義の RCODE
+
+	want := map[string]DNSRcodeStats{
+		"192.168.0.1": {Responses: 4, Rcodes: map[string]int{"NOERROR": 2, "NXDOMAIN": 1, "SERVFAIL": 1}, FailureRate: 0.25},
+		"192.168.0.2": {Responses: 2, Rcodes: map[string]int{"REFUSED": 1, "RCODE11": 1}, FailureRate: 0.5},
+	}
+	if got := s.DNSRcodeStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DNSRcodeStats() = %v, want %v", got, want)
+	}
+	if got := s.Snapshot(REPORT_TOP_TALKERS).DNSRcodes; !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot().DNSRcodes = %v, want %v", got, want)
+	}
+
+	s.Reset()
+	if got := s.DNSRcodeStats(); len(got) != 0 {
+		t.Errorf("DNSRcodeStats() = %v after Reset, want empty", got)
+	}
+}
+
+// TestSourceRates tests that a source sending over the threshold is flagged with its rate, that its rate falls when it stops,
+// and that the tracked sources are bounded
+// 閾値を超えて送信している送信元がレートとともにフラグ付けされ、送信が止まるとレートが下がること、追跡する送信元の数に上限があることをテストします
+func TestSourceRates(t *testing.T) {
+	s := NewStatistics()
+	s.SetSourceRateThreshold(100, 10*time.Second)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	received := start
+	s.sourceRates.now = func() time.Time { return received }
+
+	packet := func(src byte, timestamp time.Time) *packemon.Passive {
+		return &packemon.Passive{
+			RawLength: 60,
+			IPv4:      &packemon.IPv4Packet{SrcIP: []byte{192, 168, 0, src}, DstIP: []byte{192, 168, 0, 10}},
+			Timestamp: timestamp,
+		}
+	}
+	// 192.168.0.1 は 10 秒間に 2000 パケット(200/s)、192.168.0.2 は 500 パケット(50/s)
+	for i := 0; i < 2000; i++ {
+		timestamp := start.Add(time.Duration(i) * 5 * time.Millisecond)
+		s.ProcessPacket(packet(1, timestamp))
+		if i%4 == 0 {
+			s.ProcessPacket(packet(2, timestamp))
+		}
+	}
+
+	want := []SourceRate{{IP: "192.168.0.1", Rate: 200}}
+	if got := s.FlaggedSources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FlaggedSources() = %v, want %v", got, want)
+	}
+	if got := s.Snapshot(REPORT_TOP_TALKERS).FlaggedSources; !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot().FlaggedSources = %v, want %v", got, want)
+	}
+
+	// 送信が止まってからウィンドウが過ぎればフラグは外れる
+	received = received.Add(10 * time.Second)
+	if got := s.FlaggedSources(); len(got) != 0 {
+		t.Errorf("FlaggedSources() = %v after the window, want none", got)
+	}
+
+	// 上限を超えた送信元は最も長くパケットの無いものから捨てる
+	s.sourceRates.maxSources = 2
+	later := start.Add(15 * time.Second)
+	for src := byte(3); src <= 5; src++ {
+		s.ProcessPacket(packet(src, later.Add(time.Duration(src)*time.Millisecond)))
+	}
+	if got := len(s.sourceRates.sources); got != 2 {
+		t.Errorf("tracked sources = %d, want 2", got)
+	}
+	if _, ok := s.sourceRates.sources["192.168.0.3"]; ok {
+		t.Errorf("192.168.0.3 is still tracked, want it evicted")
+	}
+}
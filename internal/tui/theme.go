@@ -0,0 +1,44 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/ddddddO/packemon"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// ThemeColor resolves the color of the role in the theme. A nil theme uses the dark theme
+// テーマの役割の色を解決します。nilのテーマはdarkのテーマを使います
+func ThemeColor(theme *packemon.Theme, role string) tcell.Color {
+	return tcell.GetColor(theme.Color(role))
+}
+
+// ProtocolColor resolves the color of the protocol in the theme, see packemon.Theme.ProtocolColor
+// テーマのプロトコルの色を解決します。packemon.Theme.ProtocolColorを参照してください
+func ProtocolColor(theme *packemon.Theme, protocol string) tcell.Color {
+	return tcell.GetColor(theme.ProtocolColor(protocol))
+}
+
+// ApplyTheme sets the default styles of tview's primitives from the theme. Primitives created before the call keep their styles
+// tviewのプリミティブのデフォルトのスタイルをテーマから設定します。呼び出し前に作成したプリミティブのスタイルは変わりません
+func ApplyTheme(theme *packemon.Theme) {
+	tview.Styles.PrimitiveBackgroundColor = ThemeColor(theme, packemon.THEME_ROLE_BACKGROUND)
+	tview.Styles.PrimaryTextColor = ThemeColor(theme, packemon.THEME_ROLE_TEXT)
+	tview.Styles.BorderColor = ThemeColor(theme, packemon.THEME_ROLE_BORDER)
+	tview.Styles.TitleColor = ThemeColor(theme, packemon.THEME_ROLE_TITLE)
+	tview.Styles.SecondaryTextColor = ThemeColor(theme, packemon.THEME_ROLE_TITLE)
+	tview.Styles.TertiaryTextColor = ThemeColor(theme, packemon.THEME_ROLE_HIGHLIGHT)
+	tview.Styles.ContrastBackgroundColor = ThemeColor(theme, packemon.THEME_ROLE_SELECTION)
+}
+
+// ThemeTagReplacer rewrites role tags such as "[title]" in a text with dynamic colors into the theme's color tags such as "[yellow]"
+// 動的な色のテキストの"[title]"などの役割のタグを、"[yellow]"などのテーマの色のタグに書き換えます
+func ThemeTagReplacer(theme *packemon.Theme) *strings.Replacer {
+	roles := packemon.ThemeRoles()
+	oldnew := make([]string, 0, len(roles)*2)
+	for _, role := range roles {
+		oldnew = append(oldnew, "["+role+"]", "["+theme.Color(role)+"]")
+	}
+	return strings.NewReplacer(oldnew...)
+}
@@ -0,0 +1,42 @@
+package packemon
+
+import (
+	"fmt"
+)
+
+// IPv4 の Protocol と IPv6 の Next Header は同じ番号を使う
+// https://www.iana.org/assignments/protocol-numbers/protocol-numbers.xhtml
+var ipProtocolNames = map[uint8]string{
+	0x00:                      "HOPOPT",
+	IPv4_PROTO_ICMP:           "ICMP",
+	IPv4_PROTO_IGMP:           "IGMP",
+	IPv4_PROTO_IPIP:           "IPIP",
+	IPv4_PROTO_TCP:            "TCP",
+	IPv4_PROTO_UDP:            "UDP",
+	IPv4_PROTO_IPv6:           "IPv6",
+	0x2b:                      "IPv6-Route",
+	IPv6_NEXT_HEADER_FRAGMENT: "IPv6-Frag",
+	0x2e:                      "RSVP",
+	IPv4_PROTO_GRE:            "GRE",
+	IPv4_PROTO_ESP:            "ESP",
+	IPv4_PROTO_AH:             "AH",
+	IPv6_NEXT_HEADER_ICMPv6:   "ICMPv6",
+	0x3b:                      "IPv6-NoNxt",
+	0x3c:                      "IPv6-Opts",
+	IPv4_PROTO_OSPF:           "OSPF",
+	0x67:                      "PIM",
+	0x70:                      "VRRP",
+	0x73:                      "L2TP",
+	IPv4_PROTO_SCTP:           "SCTP",
+	0x88:                      "UDPLite",
+	0x89:                      "MPLS-in-IP",
+}
+
+// ProtocolName returns the name of an IPv4 Protocol or IPv6 Next Header number, e.g. "TCP" for 6, or "Unknown(n)" for the others
+// IPv4のProtocolまたはIPv6のNext Headerの番号の名前(6なら"TCP"など)を返します。それ以外は"Unknown(n)"を返します
+func ProtocolName(n uint8) string {
+	if name, ok := ipProtocolNames[n]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", n)
+}
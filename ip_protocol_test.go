@@ -0,0 +1,26 @@
+package packemon
+
+import (
+	"testing"
+)
+
+func TestProtocolName(t *testing.T) {
+	tests := []struct {
+		n    uint8
+		want string
+	}{
+		{n: IPv4_PROTO_ICMP, want: "ICMP"},
+		{n: IPv4_PROTO_TCP, want: "TCP"},
+		{n: IPv6_NEXT_HEADER_UDP, want: "UDP"},
+		{n: 47, want: "GRE"},
+		{n: 50, want: "ESP"},
+		{n: IPv6_NEXT_HEADER_ICMPv6, want: "ICMPv6"},
+		{n: 132, want: "SCTP"},
+		{n: 200, want: "Unknown(200)"}, // 未割り当て
+	}
+	for _, tt := range tests {
+		if got := ProtocolName(tt.n); got != tt.want {
+			t.Errorf("ProtocolName(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package packemon
+
+import "sync/atomic"
+
+// DEFAULT_MAX_TUNNEL_NESTING is the default limit of nested tunnels (IP-in-IP, GENEVE) decoded, so that a crafted packet nesting tunnels cannot make decoding recurse deeply
+// 解析するトンネル(IP-in-IP、GENEVE)の入れ子の深さのデフォルトの上限です。トンネルを入れ子にした細工パケットで再帰が深くならないようにします
+const DEFAULT_MAX_TUNNEL_NESTING = 8
+
+// 0 は DEFAULT_MAX_TUNNEL_NESTING
+var maxTunnelNesting atomic.Int32
+
+// SetMaxTunnelNesting sets how many nested tunnels are decoded. Zero or less restores DEFAULT_MAX_TUNNEL_NESTING.
+// A packet nested deeper is decoded down to the limit and marked partial.
+// 解析するトンネルの入れ子の深さを設定します。0以下の場合はDEFAULT_MAX_TUNNEL_NESTINGに戻します。
+// それより深いパケットは上限まで解析し、一部のみ解析したものとして記録します
+func SetMaxTunnelNesting(n int) {
+	if n <= 0 {
+		n = DEFAULT_MAX_TUNNEL_NESTING
+	}
+	maxTunnelNesting.Store(int32(n))
+}
+
+// MaxTunnelNesting returns the current limit of nested tunnels
+// 現在のトンネルの入れ子の深さの上限を返します
+func MaxTunnelNesting() int {
+	if n := maxTunnelNesting.Load(); n > 0 {
+		return int(n)
+	}
+	return DEFAULT_MAX_TUNNEL_NESTING
+}
+
+// decodeTunnel decodes payload, carried in a tunnel of layer in p, one nesting level deeper than p.
+// etherType 0 means payload is an Ethernet frame. Beyond MaxTunnelNesting it marks p partial and returns nil
+// pのlayerのトンネルで運ばれるpayloadを、pより1段深い入れ子としてデコードします。
+// etherTypeが0の場合、payloadはEthernetフレームです。MaxTunnelNestingを超える場合はpを一部のみ解析したものとしてnilを返します
+func (p *Passive) decodeTunnel(layer string, payload []byte, etherType uint16, decodeAs *DecodeAsTable, depth ParseDepth) *Passive {
+	if p.tunnelNesting >= MaxTunnelNesting() {
+		logParseFailure(layer, payload)
+		p.markPartial(layer)
+		return nil
+	}
+
+	var inner *Passive
+	if etherType == 0 {
+		if len(payload) <= 14 {
+			return nil
+		}
+		inner = &Passive{
+			EthernetFrame: &EthernetFrame{
+				DstAddr: payload[0:6],
+				SrcAddr: payload[6:12],
+				Type:    uint16(payload[12])<<8 | uint16(payload[13]),
+				Payload: payload[14:],
+			},
+			Raw:        payload,
+			RawLength:  len(payload),
+			WireLength: len(payload),
+		}
+	} else {
+		inner = &Passive{
+			EthernetFrame: &EthernetFrame{
+				Type:    etherType,
+				Payload: payload,
+			},
+			Raw:       payload,
+			RawLength: len(payload),
+		}
+	}
+	inner.tunnelNesting = p.tunnelNesting + 1
+	parseEthernetPayload(inner, decodeAs, depth)
+	return inner
+}
+
+// parseIPinIP decodes the IPv4 (protocol 4) or IPv6 (protocol 41, e.g. 6in4) packet carried in an IPv4 packet into passive.Inner
+// IPv4パケットに含まれるIPv4(プロトコル4)またはIPv6(プロトコル41、6in4など)のパケットをpassive.Innerにデコードします
+func parseIPinIP(passive *Passive, ipv4 *IPv4Packet, decodeAs *DecodeAsTable, depth ParseDepth) {
+	etherType := ETHER_TYPE_IPv4
+	if ipv4.Protocol == IPv4_PROTO_IPv6 {
+		etherType = ETHER_TYPE_IPv6
+	}
+	// Ethernet のパディングは内側のパケットに含めない
+	payload := ipv4.Payload
+	if total := int(ipv4.TotalLength) - int(ipv4.IHL); total >= 0 && total < len(payload) {
+		payload = payload[:total]
+	}
+
+	passive.Inner = passive.decodeTunnel("IP-in-IP", payload, etherType, decodeAs, depth)
+}
@@ -0,0 +1,132 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// inner を IPv4 (proto) でカプセル化した Ethernet フレーム
+func ipTunnelTestFrame(proto uint8, inner []byte) []byte {
+	ipv4 := []byte{
+		0x45, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, 0x00, 0x40, proto, 0x00, 0x00,
+		198, 51, 100, 1, 203, 0, 113, 1,
+	}
+	binary.BigEndian.PutUint16(ipv4[2:4], uint16(20+len(inner)))
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x08, 0x00}
+	return append(append(frame, ipv4...), inner...)
+}
+
+// TestIPinIP6in4 tests that an IPv6 packet carrying ICMPv6 inside IPv4 protocol 41 is decoded into Passive.Inner
+// IPv4のプロトコル41で運ばれるICMPv6のIPv6パケットがPassive.Innerにデコードされることをテストします
+func TestIPinIP6in4(t *testing.T) {
+	inner := checksumReportTestICMPv6Frame()[14:]
+	passive, err := DecodeFrame(ipTunnelTestFrame(IPv4_PROTO_IPv6, inner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.IPv4 == nil || passive.IPv4.Protocol != IPv4_PROTO_IPv6 {
+		t.Fatalf("outer IPv4 = %+v, want protocol 41", passive.IPv4)
+	}
+	if passive.Inner == nil {
+		t.Fatal("Passive.Inner should be set for a 6in4 packet")
+	}
+	if passive.Inner.EthernetFrame.Type != ETHER_TYPE_IPv6 || passive.Inner.RawLength != len(inner) {
+		t.Errorf("inner type = 0x%04x, length = %d, want 0x86dd, %d", passive.Inner.EthernetFrame.Type, passive.Inner.RawLength, len(inner))
+	}
+	if passive.Inner.IPv6 == nil || passive.Inner.ICMPv6 == nil {
+		t.Fatalf("inner = %+v, want IPv6 and ICMPv6", passive.Inner)
+	}
+	if icmpv6 := passive.Inner.ICMPv6; icmpv6.Type != ICMPv6_TYPE_ECHO_REQUEST || string(icmpv6.Payload[4:]) != "ping!" {
+		t.Errorf("inner ICMPv6 = %+v, want an echo request with \"ping!\"", icmpv6)
+	}
+	if passive.ICMPv6 != nil || passive.IPv6 != nil {
+		t.Error("the inner layers should not be set on the outer Passive")
+	}
+
+	// 最小フレーム長に満たないためのパディングは内側のパケットに含めない
+	padded := append(ipTunnelTestFrame(IPv4_PROTO_IPIP, []byte{
+		0x45, 0x00, 0x00, 0x14, 0x00, 0x00, 0x40, 0x00, 0x40, 0xfd, 0x00, 0x00,
+		10, 0, 0, 1, 10, 0, 0, 2,
+	}), make([]byte, 20)...)
+	passive, err = DecodeFrame(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.Inner == nil || passive.Inner.IPv4 == nil || passive.Inner.RawLength != 20 {
+		t.Errorf("inner = %+v, want a 20 byte IPv4 packet without the padding", passive.Inner)
+	}
+}
+
+// TestIPinIPNesting tests that nested IP-in-IP headers are decoded only up to MaxTunnelNesting
+// 入れ子のIP-in-IPヘッダーがMaxTunnelNestingまでしか解析されないことをテストします
+func TestIPinIPNesting(t *testing.T) {
+	frame := checksumReportTestTCPFrame()
+	for i := 0; i < 100; i++ {
+		frame = ipTunnelTestFrame(IPv4_PROTO_IPIP, frame[14:])
+	}
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nesting := 0
+	deepest := passive
+	for inner := passive.Inner; inner != nil; inner = inner.Inner {
+		nesting++
+		if inner.IPv4 == nil {
+			t.Fatalf("inner packet %d has no IPv4 header", nesting)
+		}
+		deepest = inner
+	}
+	if nesting != DEFAULT_MAX_TUNNEL_NESTING {
+		t.Errorf("decoded %d nested packets, want %d", nesting, DEFAULT_MAX_TUNNEL_NESTING)
+	}
+	if !deepest.Truncated || len(deepest.PartialLayers) != 1 || deepest.PartialLayers[0] != "IP-in-IP" {
+		t.Errorf("deepest packet Truncated = %t, PartialLayers = %v, want marked at IP-in-IP", deepest.Truncated, deepest.PartialLayers)
+	}
+}
+
+// inner を Ethernet/IPv4/UDP/GENEVE でカプセル化した Ethernet フレーム
+func geneveNestingTestFrame(inner []byte) []byte {
+	geneve := append([]byte{0x00, 0x00, 0x65, 0x58, 0x00, 0x00, 0x01, 0x00}, inner...)
+	udp := []byte{0xc3, 0x50, 0x17, 0xc1, 0x00, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(geneve)))
+	return ipTunnelTestFrame(IPv4_PROTO_UDP, append(udp, geneve...))
+}
+
+// TestSetMaxTunnelNesting tests that a lowered limit stops deeply nested GENEVE packets cleanly
+// 上限を下げると、深く入れ子になったGENEVEパケットの解析が途中で止まることをテストします
+func TestSetMaxTunnelNesting(t *testing.T) {
+	SetMaxTunnelNesting(2)
+	t.Cleanup(func() { SetMaxTunnelNesting(0) })
+	if MaxTunnelNesting() != 2 {
+		t.Fatalf("MaxTunnelNesting() = %d, want 2", MaxTunnelNesting())
+	}
+
+	frame := checksumReportTestTCPFrame()
+	for i := 0; i < 50; i++ {
+		frame = geneveNestingTestFrame(frame)
+	}
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nesting := 0
+	deepest := passive
+	for deepest.GENEVE != nil && deepest.GENEVE.Inner != nil {
+		deepest = deepest.GENEVE.Inner
+		nesting++
+	}
+	if nesting != 2 {
+		t.Errorf("decoded %d nested packets, want 2", nesting)
+	}
+	if deepest.GENEVE == nil || len(deepest.PartialLayers) != 1 || deepest.PartialLayers[0] != "GENEVE" {
+		t.Errorf("deepest packet GENEVE = %+v, PartialLayers = %v, want the GENEVE header decoded and marked partial", deepest.GENEVE, deepest.PartialLayers)
+	}
+
+	SetMaxTunnelNesting(0)
+	if MaxTunnelNesting() != DEFAULT_MAX_TUNNEL_NESTING {
+		t.Errorf("MaxTunnelNesting() after reset = %d, want %d", MaxTunnelNesting(), DEFAULT_MAX_TUNNEL_NESTING)
+	}
+}
@@ -33,7 +33,7 @@ func NewIPv4(protocol uint8, srcAddr uint32, dstAddr uint32) *IPv4 {
 		Ihl:            0x05,
 		Tos:            0x00,
 		TotalLength:    0x54,
-		Identification: 0x0d94,
+		Identification: IPv4IDs.Next(),
 		Flags:          0x40,
 		FragmentOffset: 0x0,
 		Ttl:            0x40,
@@ -46,8 +46,16 @@ func NewIPv4(protocol uint8, srcAddr uint32, dstAddr uint32) *IPv4 {
 
 const (
 	IPv4_PROTO_ICMP uint8 = 0x01
+	IPv4_PROTO_IGMP uint8 = 0x02
+	IPv4_PROTO_IPIP uint8 = 0x04 // IPv4 in IPv4
 	IPv4_PROTO_TCP  uint8 = 0x06
 	IPv4_PROTO_UDP  uint8 = 0x11
+	IPv4_PROTO_IPv6 uint8 = 0x29 // IPv6 in IPv4 (6in4)
+	IPv4_PROTO_GRE  uint8 = 0x2f
+	IPv4_PROTO_ESP  uint8 = 0x32
+	IPv4_PROTO_AH   uint8 = 0x33
+	IPv4_PROTO_OSPF uint8 = 0x59
+	IPv4_PROTO_SCTP uint8 = 0x84
 )
 
 var IPv4Protocols = map[uint8]string{
@@ -0,0 +1,219 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// IPv4_REASSEMBLY_TIMEOUT is how long the fragments of a packet are kept after the first one arrives, as for IPv6
+	// ref: https://datatracker.ietf.org/doc/html/rfc791#section-3.2
+	// 最初のフラグメントが届いてから、パケットのフラグメントを保持する時間です。IPv6と同じです
+	IPv4_REASSEMBLY_TIMEOUT = IPv6_REASSEMBLY_TIMEOUT
+
+	// IPv4_REASSEMBLY_MAX_FLOWS is the number of packets reassembled at once. The oldest one is dropped beyond it
+	// 同時に再構築するパケットの数です。超えた場合は最も古いものを破棄します
+	IPv4_REASSEMBLY_MAX_FLOWS = IPv6_REASSEMBLY_MAX_FLOWS
+)
+
+// IPv4 ヘッダーの Flags (3ビット) の More Fragments
+const ipv4FlagMoreFragments = 0x01
+
+type ipv4FragmentKey struct {
+	src, dst       [4]byte
+	protocol       uint8
+	identification uint16
+}
+
+type ipv4FragmentFlow struct {
+	firstSeen time.Time
+	// fragments はオフセットをキーにしたデータ
+	fragments map[int][]byte
+	// total は最後のフラグメント(MF=0)が届くまで0
+	total int
+	// header は先頭のフラグメントのIPv4ヘッダー(オプションを含む), link はそのEthernetヘッダー (無ければnil)
+	header []byte
+	link   []byte
+}
+
+// IPv4Reassembler reassembles fragmented IPv4 packets
+// 分割されたIPv4パケットを再構築します
+type IPv4Reassembler struct {
+	mu    sync.Mutex
+	flows map[ipv4FragmentKey]*ipv4FragmentFlow
+}
+
+// NewIPv4Reassembler creates an empty IPv4 reassembler
+// 空のIPv4再構築器を作成します
+func NewIPv4Reassembler() *IPv4Reassembler {
+	return &IPv4Reassembler{
+		flows: make(map[ipv4FragmentKey]*ipv4FragmentFlow),
+	}
+}
+
+// Reassemble returns p as is unless it is an IPv4 fragment. For a fragment it returns nil until the last missing one arrives,
+// and then the reassembled packet with the header of the first fragment, its fragment fields cleared.
+// Fragments are keyed by source, destination, protocol and identification (RFC 791). Overlapping fragments drop the whole packet.
+// IPv4のフラグメントでなければpをそのまま返します。フラグメントの場合は、欠けている最後のフラグメントが届くまでnilを返し、
+// 届いたら先頭のフラグメントのヘッダーの分割に関するフィールドをクリアした再構築済みのパケットを返します。
+// フラグメントは送信元、宛先、プロトコル、識別子で対応付けます(RFC 791)。重なるフラグメントがあればパケット全体を破棄します
+func (r *IPv4Reassembler) Reassemble(p *Passive) *Passive {
+	if p == nil || p.EthernetFrame == nil || p.IPv4 == nil {
+		return p
+	}
+	ipv4 := p.IPv4
+	more := ipv4.Flags&ipv4FlagMoreFragments != 0
+	if !more && ipv4.FragOffset == 0 {
+		return p
+	}
+	if int(ipv4.IHL) > len(p.EthernetFrame.Payload) || int(ipv4.TotalLength) < int(ipv4.IHL) {
+		return nil
+	}
+	// Ethernet のパディングを除く
+	payload := ipv4.Payload[:min(int(ipv4.TotalLength)-int(ipv4.IHL), len(ipv4.Payload))]
+	offset := int(ipv4.FragOffset) * 8
+	// 最後以外のフラグメントの長さは8の倍数
+	if more && len(payload)%8 != 0 {
+		return nil
+	}
+
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := ipv4FragmentKey{protocol: ipv4.Protocol, identification: ipv4.ID}
+	copy(key.src[:], ipv4.SrcIP)
+	copy(key.dst[:], ipv4.DstIP)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expire(now)
+	flow, ok := r.flows[key]
+	if !ok {
+		if len(r.flows) >= IPv4_REASSEMBLY_MAX_FLOWS {
+			r.evictOldest()
+		}
+		flow = &ipv4FragmentFlow{firstSeen: now, fragments: make(map[int][]byte)}
+		r.flows[key] = flow
+	}
+
+	end := offset + len(payload)
+	if end+int(ipv4.IHL) > 0xffff || (flow.total > 0 && end > flow.total) || (!more && flow.total > 0 && end != flow.total) {
+		delete(r.flows, key)
+		return nil
+	}
+	if data, ok := flow.fragments[offset]; ok && len(data) == len(payload) {
+		// 再送された同じフラグメント
+		return nil
+	}
+	for o, data := range flow.fragments {
+		if offset < o+len(data) && o < end {
+			delete(r.flows, key)
+			return nil
+		}
+	}
+
+	// 受信バッファは再利用されることがあるため、保持するデータはコピーする
+	flow.fragments[offset] = append([]byte(nil), payload...)
+	if !more {
+		for o, data := range flow.fragments {
+			if o+len(data) > end {
+				delete(r.flows, key)
+				return nil
+			}
+		}
+		flow.total = end
+	}
+	if offset == 0 {
+		flow.header = append([]byte(nil), p.EthernetFrame.Payload[:ipv4.IHL]...)
+		if eth := p.EthernetFrame; len(eth.DstAddr) == 6 && len(eth.SrcAddr) == 6 {
+			flow.link = append(append([]byte(nil), eth.DstAddr...), eth.SrcAddr...)
+		}
+	}
+
+	packet, ok := flow.assemble()
+	if !ok {
+		return nil
+	}
+	delete(r.flows, key)
+
+	var reassembled *Passive
+	if flow.link != nil {
+		frame := binary.BigEndian.AppendUint16(flow.link, ETHER_TYPE_IPv4)
+		var err error
+		reassembled, err = DecodeFrame(append(frame, packet...))
+		if err != nil {
+			return nil
+		}
+	} else {
+		reassembled = decodeLinklessPacket(packet, ETHER_TYPE_IPv4, nil)
+	}
+	reassembled.Timestamp = p.Timestamp
+	reassembled.Interface = p.Interface
+	return reassembled
+}
+
+// assemble returns the reassembled IPv4 packet once the fragments cover the whole payload
+// フラグメントがペイロード全体を埋めたら、再構築したIPv4パケットを返します
+func (f *ipv4FragmentFlow) assemble() ([]byte, bool) {
+	if f.total == 0 || f.header == nil {
+		return nil, false
+	}
+	offsets := make([]int, 0, len(f.fragments))
+	for offset := range f.fragments {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+
+	ihl := len(f.header)
+	packet := make([]byte, ihl, ihl+f.total)
+	copy(packet, f.header)
+	for _, offset := range offsets {
+		// 隙間がある
+		if offset != len(packet)-ihl {
+			return nil, false
+		}
+		packet = append(packet, f.fragments[offset]...)
+	}
+	if len(packet)-ihl != f.total {
+		return nil, false
+	}
+
+	// DF は残し、MF とフラグメントオフセットをクリアしてチェックサムを計算し直す
+	binary.BigEndian.PutUint16(packet[2:4], uint16(ihl+f.total))
+	binary.BigEndian.PutUint16(packet[6:8], binary.BigEndian.Uint16(packet[6:8])&0x4000)
+	binary.BigEndian.PutUint16(packet[10:12], 0)
+	binary.BigEndian.PutUint16(packet[10:12], calculateInternetChecksum(packet[:ihl]))
+	return packet, true
+}
+
+func (r *IPv4Reassembler) expire(now time.Time) {
+	for key, flow := range r.flows {
+		if now.Sub(flow.firstSeen) > IPv4_REASSEMBLY_TIMEOUT {
+			delete(r.flows, key)
+		}
+	}
+}
+
+func (r *IPv4Reassembler) evictOldest() {
+	var oldest ipv4FragmentKey
+	var oldestSeen time.Time
+	for key, flow := range r.flows {
+		if oldestSeen.IsZero() || flow.firstSeen.Before(oldestSeen) {
+			oldest, oldestSeen = key, flow.firstSeen
+		}
+	}
+	delete(r.flows, oldest)
+}
+
+// Pending returns the number of packets waiting for more fragments
+// フラグメントの到着を待っているパケットの数を返します
+func (r *IPv4Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.flows)
+}
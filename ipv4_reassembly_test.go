@@ -0,0 +1,73 @@
+package packemon
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIPv4Reassembler tests that a UDP packet split into two fragments is reassembled, in either order, with a valid header
+// 2つのフラグメントに分割されたUDPパケットが、どちらの順で届いても正しいヘッダーで再構築されることをテストします
+func TestIPv4Reassembler(t *testing.T) {
+	udp := testFragmentedUDP()
+	first := testIPv4Frame(IPv4_PROTO_UDP, 0x1234, 0, true, udp[:16])
+	last := testIPv4Frame(IPv4_PROTO_UDP, 0x1234, 16, false, udp[16:])
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, frames := range map[string][][]byte{
+		"in order":     {first, last},
+		"out of order": {last, first},
+	} {
+		r := NewIPv4Reassembler()
+		if got := r.Reassemble(testDecodeFrameAt(t, frames[0], now)); got != nil {
+			t.Fatalf("%s: Reassemble() of the first fragment = %+v, want nil", name, got)
+		}
+		if r.Pending() != 1 {
+			t.Errorf("%s: Pending() = %d, want 1", name, r.Pending())
+		}
+
+		got := r.Reassemble(testDecodeFrameAt(t, frames[1], now.Add(time.Millisecond)))
+		if got == nil {
+			t.Fatalf("%s: Reassemble() of the second fragment should return the packet", name)
+		}
+		if got.IPv4 == nil || got.IPv4.TotalLength != uint16(20+len(udp)) || got.IPv4.Flags != 0 || got.IPv4.FragOffset != 0 {
+			t.Errorf("%s: IPv4 = %+v, want an unfragmented packet of %d bytes", name, got.IPv4, 20+len(udp))
+		}
+		if calculateInternetChecksum(got.EthernetFrame.Payload[:20]) != 0 {
+			t.Errorf("%s: header checksum 0x%04x is not valid", name, got.IPv4.Checksum)
+		}
+		if got.UDP == nil || got.UDP.DstPort != 53 || string(got.UDP.Payload) != "fragmented dns over ipv6" {
+			t.Errorf("%s: UDP = %+v, want the whole datagram to port 53", name, got.UDP)
+		}
+		if r.Pending() != 0 {
+			t.Errorf("%s: Pending() after reassembly = %d, want 0", name, r.Pending())
+		}
+	}
+
+	// フラグメントでないパケットはそのまま
+	r := NewIPv4Reassembler()
+	passive := testDecodeFrameAt(t, checksumReportTestTCPFrame(), now)
+	if got := r.Reassemble(passive); got != passive {
+		t.Errorf("Reassemble() of an unfragmented packet = %+v, want it unchanged", got)
+	}
+}
+
+// TestIPv4ReassemblerDrop tests that fragments are dropped on timeout and on overlap
+// タイムアウトと重なりでフラグメントが破棄されることをテストします
+func TestIPv4ReassemblerDrop(t *testing.T) {
+	udp := testFragmentedUDP()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := NewIPv4Reassembler()
+	r.Reassemble(testDecodeFrameAt(t, testIPv4Frame(IPv4_PROTO_UDP, 1, 0, true, udp[:16]), now))
+	late := testDecodeFrameAt(t, testIPv4Frame(IPv4_PROTO_UDP, 1, 16, false, udp[16:]), now.Add(IPv4_REASSEMBLY_TIMEOUT+time.Second))
+	if got := r.Reassemble(late); got != nil {
+		t.Errorf("Reassemble() after the timeout = %+v, want nil", got)
+	}
+
+	r = NewIPv4Reassembler()
+	r.Reassemble(testDecodeFrameAt(t, testIPv4Frame(IPv4_PROTO_UDP, 2, 0, true, udp[:16]), now))
+	r.Reassemble(testDecodeFrameAt(t, testIPv4Frame(IPv4_PROTO_UDP, 2, 8, true, udp[8:24]), now))
+	if r.Pending() != 0 {
+		t.Errorf("Pending() after overlapping fragments = %d, want 0", r.Pending())
+	}
+}
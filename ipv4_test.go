@@ -5,6 +5,8 @@ import (
 	"encoding/binary"
 	"testing"
 	"time"
+
+	"github.com/ddddddO/packemon"
 )
 
 func Test_sandbox(t *testing.T) {
@@ -48,15 +50,13 @@ func Test_sandbox(t *testing.T) {
 	buf1.WriteByte(0x07)
 	t.Logf("buf1 bytes(want: 0f0e07): %x = %b(= [1111 1110 111])", buf1.Bytes(), buf1.Bytes())
 
-	now := time.Now().Unix()
-	t.Logf("unixtime: %d\n", now)
+	now := time.Now()
+	t.Logf("unixtime: %d\n", now.Unix())
 	b := make([]byte, 4)
-	binary.BigEndian.PutUint32(b, uint32(now))
-	t.Logf("unixtime bytes: %x\n", b) // リトルエンディアンでicmpのtimestampに詰めればよさそう
-	b = make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, uint32(now))
-	t.Logf("unixtime bytes(lit): %x\n", b) // これで良さそうtimestampは
-	b = binary.LittleEndian.AppendUint32(b, 0x00000000)
+	binary.BigEndian.PutUint32(b, uint32(now.Unix()))
+	t.Logf("unixtime bytes: %x\n", b)
+	// icmp の timestamp はリトルエンディアン
+	b = packemon.EchoTimestamp(now)
 	t.Logf("icmp timestamp: %x\n", b)
 
 	var buf3, buf4 bytes.Buffer
@@ -3,6 +3,7 @@ package packemon
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"net"
 )
 
@@ -39,6 +40,32 @@ func NewIPv6(protocol uint8, srcAddr []uint8, dstAddr []uint8) *IPv6 {
 	}
 }
 
+// NewIPv6WithPayload creates an IPv6 packet carrying payload, with PayloadLength set from it.
+// The addresses must be IPv6 addresses; IPv4 and IPv4-mapped addresses are rejected
+// payloadを運ぶIPv6パケットを作成し、PayloadLengthをその長さに設定します。
+// アドレスはIPv6アドレスである必要があり、IPv4およびIPv4射影アドレスはエラーになります
+func NewIPv6WithPayload(src, dst net.IP, nextHeader uint8, payload []byte) (*IPv6, error) {
+	if !isIPv6Addr(src) {
+		return nil, fmt.Errorf("invalid IPv6 source address: %v", src)
+	}
+	if !isIPv6Addr(dst) {
+		return nil, fmt.Errorf("invalid IPv6 destination address: %v", dst)
+	}
+	if len(payload) > 0xffff {
+		return nil, fmt.Errorf("IPv6 payload too long: %d bytes", len(payload))
+	}
+
+	ipv6 := NewIPv6(nextHeader, bytes.Clone(src), bytes.Clone(dst))
+	ipv6.Data = payload
+	ipv6.PayloadLength = uint16(len(payload))
+	return ipv6, nil
+}
+
+// net.ParseIP は IPv4 アドレスも 16 バイトで返すため、長さに加えて IPv4 でないことも見る
+func isIPv6Addr(ip net.IP) bool {
+	return len(ip) == net.IPv6len && ip.To4() == nil
+}
+
 func ParsedIPv6(payload []byte) *IPv6 {
 	return &IPv6{
 		Version:      payload[0] >> 4,
@@ -61,6 +88,8 @@ const (
 	IPv6_NEXT_HEADER_TCP    = IPv4_PROTO_TCP
 	IPv6_NEXT_HEADER_UDP    = IPv4_PROTO_UDP
 	IPv6_NEXT_HEADER_ICMPv6 = 0x3a
+
+	IPv6_NEXT_HEADER_FRAGMENT = 0x2c
 )
 
 func (i *IPv6) StrSrcIPAddr() string {
@@ -96,10 +125,20 @@ func (i *IPv6) Bytes() []byte {
 // 上位レイヤのチェックサムを求めるための
 // ref: https://datatracker.ietf.org/doc/html/rfc8200#section-8.1
 func (i *IPv6) PseudoHeader(upperLayerLength uint32) []byte {
-	buf := &bytes.Buffer{}
-	binary.Write(buf, binary.BigEndian, i.SrcAddr)
-	binary.Write(buf, binary.BigEndian, i.DstAddr)
-	WriteUint32(buf, upperLayerLength)
-	WriteUint32(buf, uint32(i.NextHeader))
-	return buf.Bytes()
+	return BuildIPv6PseudoHeader(i.SrcAddr, i.DstAddr, upperLayerLength, i.NextHeader)
+}
+
+// BuildIPv6PseudoHeader builds the 40 byte pseudo-header that TCP, UDP and ICMPv6 checksums over IPv6 cover:
+// the source and destination addresses, the upper-layer length, 3 zero bytes and the next header.
+// IPv6 has no header checksum, so this is what protects the addresses, and the UDP checksum cannot be omitted
+// IPv6上のTCP/UDP/ICMPv6のチェックサムが対象とする40バイトの疑似ヘッダを作成します。
+// 送信元・宛先アドレス、上位レイヤの長さ、3バイトの0、次ヘッダからなります。
+// IPv6にはヘッダチェックサムが無いため、これがアドレスを保護し、UDPのチェックサムも省略できません
+// ref: https://datatracker.ietf.org/doc/html/rfc8200#section-8.1
+func BuildIPv6PseudoHeader(src, dst net.IP, length uint32, nextHeader uint8) []byte {
+	b := make([]byte, 0, 40)
+	b = append(b, src.To16()...)
+	b = append(b, dst.To16()...)
+	b = binary.BigEndian.AppendUint32(b, length)
+	return append(b, 0x00, 0x00, 0x00, nextHeader)
 }
@@ -0,0 +1,137 @@
+package packemon
+
+import (
+	"fmt"
+	"net"
+)
+
+// IPv6AddrType classifies an IPv6 address as defined in RFC 4291 and RFC 4193
+// IPv6AddrTypeはRFC 4291およびRFC 4193で定義されているIPv6アドレスの種類を表します
+type IPv6AddrType uint8
+
+const (
+	IPv6_ADDR_TYPE_UNKNOWN        IPv6AddrType = iota // Not an IPv6 address / IPv6アドレスではない
+	IPv6_ADDR_TYPE_UNSPECIFIED                        // :: / 未指定アドレス
+	IPv6_ADDR_TYPE_LOOPBACK                           // ::1 / ループバック
+	IPv6_ADDR_TYPE_LINK_LOCAL                         // fe80::/10 / リンクローカル
+	IPv6_ADDR_TYPE_UNIQUE_LOCAL                       // fc00::/7 / ユニークローカル
+	IPv6_ADDR_TYPE_GLOBAL_UNICAST                     // 2000::/3 など / グローバルユニキャスト
+	IPv6_ADDR_TYPE_MULTICAST                          // ff00::/8 / マルチキャスト
+)
+
+var ipv6AddrTypeNames = map[IPv6AddrType]string{
+	IPv6_ADDR_TYPE_UNKNOWN:        "unknown",
+	IPv6_ADDR_TYPE_UNSPECIFIED:    "unspecified",
+	IPv6_ADDR_TYPE_LOOPBACK:       "loopback",
+	IPv6_ADDR_TYPE_LINK_LOCAL:     "link-local",
+	IPv6_ADDR_TYPE_UNIQUE_LOCAL:   "unique-local",
+	IPv6_ADDR_TYPE_GLOBAL_UNICAST: "global-unicast",
+	IPv6_ADDR_TYPE_MULTICAST:      "multicast",
+}
+
+// String returns a human readable name of the address type
+// アドレス種別の名前を返します
+func (t IPv6AddrType) String() string {
+	if name, ok := ipv6AddrTypeNames[t]; ok {
+		return name
+	}
+	return ipv6AddrTypeNames[IPv6_ADDR_TYPE_UNKNOWN]
+}
+
+// IPv6MulticastScope is the 4bit scope field of an IPv6 multicast address
+// ref: https://datatracker.ietf.org/doc/html/rfc7346#section-2
+// IPv6MulticastScopeはIPv6マルチキャストアドレスの4bitのスコープフィールドです
+type IPv6MulticastScope uint8
+
+const (
+	IPv6_MULTICAST_SCOPE_INTERFACE_LOCAL    IPv6MulticastScope = 0x1
+	IPv6_MULTICAST_SCOPE_LINK_LOCAL         IPv6MulticastScope = 0x2
+	IPv6_MULTICAST_SCOPE_REALM_LOCAL        IPv6MulticastScope = 0x3
+	IPv6_MULTICAST_SCOPE_ADMIN_LOCAL        IPv6MulticastScope = 0x4
+	IPv6_MULTICAST_SCOPE_SITE_LOCAL         IPv6MulticastScope = 0x5
+	IPv6_MULTICAST_SCOPE_ORGANIZATION_LOCAL IPv6MulticastScope = 0x8
+	IPv6_MULTICAST_SCOPE_GLOBAL             IPv6MulticastScope = 0xe
+)
+
+var ipv6MulticastScopeNames = map[IPv6MulticastScope]string{
+	IPv6_MULTICAST_SCOPE_INTERFACE_LOCAL:    "interface-local",
+	IPv6_MULTICAST_SCOPE_LINK_LOCAL:         "link-local",
+	IPv6_MULTICAST_SCOPE_REALM_LOCAL:        "realm-local",
+	IPv6_MULTICAST_SCOPE_ADMIN_LOCAL:        "admin-local",
+	IPv6_MULTICAST_SCOPE_SITE_LOCAL:         "site-local",
+	IPv6_MULTICAST_SCOPE_ORGANIZATION_LOCAL: "organization-local",
+	IPv6_MULTICAST_SCOPE_GLOBAL:             "global",
+}
+
+// String returns a human readable name of the multicast scope
+// マルチキャストスコープの名前を返します
+func (s IPv6MulticastScope) String() string {
+	if name, ok := ipv6MulticastScopeNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("reserved(%x)", uint8(s))
+}
+
+// ClassifyIPv6Addr returns the type of the given IPv6 address
+// 指定されたIPv6アドレスの種別を返します
+func ClassifyIPv6Addr(ip net.IP) IPv6AddrType {
+	// IPv4 (IPv4-mapped 含む) は対象外
+	if len(ip) != net.IPv6len || ip.To4() != nil {
+		return IPv6_ADDR_TYPE_UNKNOWN
+	}
+
+	switch {
+	case ip.IsUnspecified():
+		return IPv6_ADDR_TYPE_UNSPECIFIED
+	case ip.IsLoopback():
+		return IPv6_ADDR_TYPE_LOOPBACK
+	case ip.IsMulticast():
+		return IPv6_ADDR_TYPE_MULTICAST
+	case ip.IsLinkLocalUnicast():
+		return IPv6_ADDR_TYPE_LINK_LOCAL
+	case ip.IsPrivate(): // fc00::/7
+		return IPv6_ADDR_TYPE_UNIQUE_LOCAL
+	case ip.IsGlobalUnicast():
+		return IPv6_ADDR_TYPE_GLOBAL_UNICAST
+	}
+	return IPv6_ADDR_TYPE_UNKNOWN
+}
+
+// IPv6MulticastScopeOf returns the scope of an IPv6 multicast address.
+// The second return value is false if ip is not an IPv6 multicast address.
+// IPv6マルチキャストアドレスのスコープを返します。マルチキャストでない場合は false を返します
+func IPv6MulticastScopeOf(ip net.IP) (IPv6MulticastScope, bool) {
+	if ClassifyIPv6Addr(ip) != IPv6_ADDR_TYPE_MULTICAST {
+		return 0, false
+	}
+	// ff<flags 4bit><scope 4bit>::
+	return IPv6MulticastScope(ip[1] & 0x0f), true
+}
+
+// IPv6Scope returns a label used to group IPv6 traffic, e.g. "link-local" or "multicast(site-local)"
+// IPv6トラフィックをグループ化するためのラベルを返します
+func IPv6Scope(ip net.IP) string {
+	typ := ClassifyIPv6Addr(ip)
+	if typ != IPv6_ADDR_TYPE_MULTICAST {
+		return typ.String()
+	}
+	scope, _ := IPv6MulticastScopeOf(ip)
+	return fmt.Sprintf("%s(%s)", typ, scope)
+}
+
+// SolicitedNodeMulticastAddr returns the solicited-node multicast group (ff02::1:ffXX:XXXX) of a unicast address.
+// nil is returned for non-unicast addresses.
+// ref: https://datatracker.ietf.org/doc/html/rfc4291#section-2.7.1
+// ユニキャストアドレスに対応する要請ノードマルチキャストアドレスを返します
+func SolicitedNodeMulticastAddr(ip net.IP) net.IP {
+	switch ClassifyIPv6Addr(ip) {
+	case IPv6_ADDR_TYPE_LINK_LOCAL, IPv6_ADDR_TYPE_UNIQUE_LOCAL, IPv6_ADDR_TYPE_GLOBAL_UNICAST:
+	default:
+		return nil
+	}
+
+	snm := net.ParseIP("ff02::1:ff00:0")
+	// 下位24bitをコピー
+	copy(snm[13:], ip[13:16])
+	return snm
+}
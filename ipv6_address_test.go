@@ -0,0 +1,88 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+)
+
+// TestClassifyIPv6Addr tests the IPv6 address classification
+// IPv6アドレスの種別判定をテストします
+func TestClassifyIPv6Addr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want IPv6AddrType
+	}{
+		{"::", IPv6_ADDR_TYPE_UNSPECIFIED},
+		{"::1", IPv6_ADDR_TYPE_LOOPBACK},
+		{"fe80::1", IPv6_ADDR_TYPE_LINK_LOCAL},
+		{"fe80::215:5dff:fefb:bf3a", IPv6_ADDR_TYPE_LINK_LOCAL},
+		{"fd12:3456:789a::1", IPv6_ADDR_TYPE_UNIQUE_LOCAL},
+		{"fc00::1", IPv6_ADDR_TYPE_UNIQUE_LOCAL},
+		{"2001:db8::1", IPv6_ADDR_TYPE_GLOBAL_UNICAST},
+		{"2404:6800:4004:81b::200e", IPv6_ADDR_TYPE_GLOBAL_UNICAST},
+		{"ff02::1", IPv6_ADDR_TYPE_MULTICAST},
+		{"ff05::1:3", IPv6_ADDR_TYPE_MULTICAST},
+		{"192.168.10.110", IPv6_ADDR_TYPE_UNKNOWN},
+	}
+
+	for _, tt := range tests {
+		got := ClassifyIPv6Addr(net.ParseIP(tt.addr))
+		if got != tt.want {
+			t.Errorf("ClassifyIPv6Addr(%s) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// TestIPv6MulticastScopeOf tests the scope extraction of multicast addresses
+// マルチキャストアドレスのスコープ取得をテストします
+func TestIPv6MulticastScopeOf(t *testing.T) {
+	tests := []struct {
+		addr   string
+		want   IPv6MulticastScope
+		wantOK bool
+	}{
+		{"ff01::1", IPv6_MULTICAST_SCOPE_INTERFACE_LOCAL, true},
+		{"ff02::1:ff00:1", IPv6_MULTICAST_SCOPE_LINK_LOCAL, true},
+		{"ff05::1:3", IPv6_MULTICAST_SCOPE_SITE_LOCAL, true},
+		{"ff0e::101", IPv6_MULTICAST_SCOPE_GLOBAL, true},
+		{"ff12::1", IPv6_MULTICAST_SCOPE_LINK_LOCAL, true}, // transient flag 付き
+		{"fe80::1", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := IPv6MulticastScopeOf(net.ParseIP(tt.addr))
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("IPv6MulticastScopeOf(%s) = (%s, %v), want (%s, %v)", tt.addr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if got := IPv6Scope(net.ParseIP("ff02::2")); got != "multicast(link-local)" {
+		t.Errorf("IPv6Scope(ff02::2) = %s, want %s", got, "multicast(link-local)")
+	}
+}
+
+// TestSolicitedNodeMulticastAddr tests the solicited-node multicast address derivation
+// 要請ノードマルチキャストアドレスの算出をテストします
+func TestSolicitedNodeMulticastAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"fe80::215:5dff:fefb:bf3a", "ff02::1:fffb:bf3a"},
+		{"2001:db8::1", "ff02::1:ff00:1"},
+		{"fd00::abcd:ef01", "ff02::1:ffcd:ef01"},
+	}
+
+	for _, tt := range tests {
+		got := SolicitedNodeMulticastAddr(net.ParseIP(tt.addr))
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("SolicitedNodeMulticastAddr(%s) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+
+	for _, addr := range []string{"::", "::1", "ff02::1", "192.168.10.110"} {
+		if got := SolicitedNodeMulticastAddr(net.ParseIP(addr)); got != nil {
+			t.Errorf("SolicitedNodeMulticastAddr(%s) = %s, want nil", addr, got)
+		}
+	}
+}
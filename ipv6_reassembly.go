@@ -0,0 +1,238 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// IPv6_REASSEMBLY_TIMEOUT is how long the fragments of a packet are kept after the first one arrives
+	// ref: https://datatracker.ietf.org/doc/html/rfc8200#section-4.5
+	// 最初のフラグメントが届いてから、パケットのフラグメントを保持する時間です
+	IPv6_REASSEMBLY_TIMEOUT = 60 * time.Second
+
+	// IPv6_REASSEMBLY_MAX_FLOWS is the number of packets reassembled at once. The oldest one is dropped beyond it
+	// 同時に再構築するパケットの数です。超えた場合は最も古いものを破棄します
+	IPv6_REASSEMBLY_MAX_FLOWS = 256
+)
+
+// IPv6Fragment is the Fragment extension header and the fragment data following it
+// ref: https://datatracker.ietf.org/doc/html/rfc8200#section-4.5
+// Fragment拡張ヘッダーと、それに続くフラグメントのデータです
+type IPv6Fragment struct {
+	NextHeader uint8
+	// Offset is in bytes, already multiplied by 8
+	// バイト単位(8倍済み)
+	Offset         int
+	MoreFragments  bool
+	Identification uint32
+	Payload        []byte
+}
+
+// ParsedIPv6Fragment parses the Fragment extension header at the start of payload
+// payloadの先頭のFragment拡張ヘッダーを解析します
+func ParsedIPv6Fragment(payload []byte) (*IPv6Fragment, error) {
+	r := NewFieldReader(payload, binary.BigEndian)
+	nextHeader, _ := r.Read8()
+	r.Skip(1) // Reserved
+	offsetFlags, _ := r.Read16()
+	identification, err := r.Read32()
+	if err != nil {
+		return nil, errors.New("ipv6 fragment header too short")
+	}
+	return &IPv6Fragment{
+		NextHeader:     nextHeader,
+		Offset:         int(offsetFlags>>3) * 8,
+		MoreFragments:  offsetFlags&0x0001 != 0,
+		Identification: identification,
+		Payload:        r.Rest(),
+	}, nil
+}
+
+type ipv6FragmentKey struct {
+	src, dst       [16]byte
+	identification uint32
+}
+
+type ipv6FragmentFlow struct {
+	firstSeen time.Time
+	// fragments はオフセットをキーにしたデータ
+	fragments map[int][]byte
+	// total は最後のフラグメント(M=0)が届くまで0
+	total int
+	// header は先頭のフラグメントのIPv6ヘッダー, link はそのEthernetヘッダー (無ければnil)
+	header []byte
+	link   []byte
+}
+
+// IPv6Reassembler reassembles IPv6 packets split with the Fragment extension header.
+// Only a Fragment header directly after the IPv6 header is recognized.
+// Fragment拡張ヘッダーで分割されたIPv6パケットを再構築します。
+// IPv6ヘッダーの直後にあるFragmentヘッダーのみを扱います
+type IPv6Reassembler struct {
+	mu    sync.Mutex
+	flows map[ipv6FragmentKey]*ipv6FragmentFlow
+}
+
+// NewIPv6Reassembler creates an empty IPv6 reassembler
+// 空のIPv6再構築器を作成します
+func NewIPv6Reassembler() *IPv6Reassembler {
+	return &IPv6Reassembler{
+		flows: make(map[ipv6FragmentKey]*ipv6FragmentFlow),
+	}
+}
+
+// Reassemble returns p as is unless it is an IPv6 fragment. For a fragment it returns nil until the last missing one arrives,
+// and then the reassembled packet with the Fragment header removed and the next header restored.
+// Fragments are keyed by source, destination and identification. Overlapping fragments drop the whole packet (RFC 5722).
+// IPv6のフラグメントでなければpをそのまま返します。フラグメントの場合は、欠けている最後のフラグメントが届くまでnilを返し、
+// 届いたらFragmentヘッダーを取り除き次ヘッダーを戻した再構築済みのパケットを返します。
+// フラグメントは送信元、宛先、識別子で対応付けます。重なるフラグメントがあればパケット全体を破棄します(RFC 5722)
+func (r *IPv6Reassembler) Reassemble(p *Passive) *Passive {
+	if p == nil || p.EthernetFrame == nil || p.IPv6 == nil || p.IPv6.NextHeader != IPv6_NEXT_HEADER_FRAGMENT {
+		return p
+	}
+	ipv6 := p.IPv6
+	payload := ipv6.Payload[:min(int(ipv6.PayloadLen), len(ipv6.Payload))]
+	fragment, err := ParsedIPv6Fragment(payload)
+	if err != nil {
+		logParseFailure("IPv6 Fragment", payload)
+		return nil
+	}
+	// 最後以外のフラグメントの長さは8の倍数
+	if fragment.MoreFragments && len(fragment.Payload)%8 != 0 {
+		return nil
+	}
+
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := ipv6FragmentKey{identification: fragment.Identification}
+	copy(key.src[:], ipv6.SrcIP)
+	copy(key.dst[:], ipv6.DstIP)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expire(now)
+	flow, ok := r.flows[key]
+	if !ok {
+		if len(r.flows) >= IPv6_REASSEMBLY_MAX_FLOWS {
+			r.evictOldest()
+		}
+		flow = &ipv6FragmentFlow{firstSeen: now, fragments: make(map[int][]byte)}
+		r.flows[key] = flow
+	}
+
+	end := fragment.Offset + len(fragment.Payload)
+	if end > 0xffff || (flow.total > 0 && end > flow.total) || (!fragment.MoreFragments && flow.total > 0 && end != flow.total) {
+		delete(r.flows, key)
+		return nil
+	}
+	if data, ok := flow.fragments[fragment.Offset]; ok && len(data) == len(fragment.Payload) {
+		// 再送された同じフラグメント
+		return nil
+	}
+	for offset, data := range flow.fragments {
+		if fragment.Offset < offset+len(data) && offset < end {
+			delete(r.flows, key)
+			return nil
+		}
+	}
+
+	// 受信バッファは再利用されることがあるため、保持するデータはコピーする
+	flow.fragments[fragment.Offset] = append([]byte(nil), fragment.Payload...)
+	if !fragment.MoreFragments {
+		for offset, data := range flow.fragments {
+			if offset+len(data) > end {
+				delete(r.flows, key)
+				return nil
+			}
+		}
+		flow.total = end
+	}
+	if fragment.Offset == 0 {
+		flow.header = append([]byte(nil), p.EthernetFrame.Payload[:40]...)
+		flow.header[6] = fragment.NextHeader
+		if eth := p.EthernetFrame; len(eth.DstAddr) == 6 && len(eth.SrcAddr) == 6 {
+			flow.link = append(append([]byte(nil), eth.DstAddr...), eth.SrcAddr...)
+		}
+	}
+
+	packet, ok := flow.assemble()
+	if !ok {
+		return nil
+	}
+	delete(r.flows, key)
+
+	var reassembled *Passive
+	if flow.link != nil {
+		frame := binary.BigEndian.AppendUint16(flow.link, ETHER_TYPE_IPv6)
+		reassembled, err = DecodeFrame(append(frame, packet...))
+		if err != nil {
+			return nil
+		}
+	} else {
+		reassembled = decodeLinklessPacket(packet, ETHER_TYPE_IPv6, nil)
+	}
+	reassembled.Timestamp = p.Timestamp
+	reassembled.Interface = p.Interface
+	return reassembled
+}
+
+// assemble returns the reassembled IPv6 packet once the fragments cover the whole payload
+// フラグメントがペイロード全体を埋めたら、再構築したIPv6パケットを返します
+func (f *ipv6FragmentFlow) assemble() ([]byte, bool) {
+	if f.total == 0 || f.header == nil {
+		return nil, false
+	}
+	offsets := make([]int, 0, len(f.fragments))
+	for offset := range f.fragments {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+
+	packet := make([]byte, 40, 40+f.total)
+	copy(packet, f.header)
+	binary.BigEndian.PutUint16(packet[4:6], uint16(f.total))
+	for _, offset := range offsets {
+		// 隙間がある
+		if offset != len(packet)-40 {
+			return nil, false
+		}
+		packet = append(packet, f.fragments[offset]...)
+	}
+	return packet, len(packet)-40 == f.total
+}
+
+func (r *IPv6Reassembler) expire(now time.Time) {
+	for key, flow := range r.flows {
+		if now.Sub(flow.firstSeen) > IPv6_REASSEMBLY_TIMEOUT {
+			delete(r.flows, key)
+		}
+	}
+}
+
+func (r *IPv6Reassembler) evictOldest() {
+	var oldest ipv6FragmentKey
+	var oldestSeen time.Time
+	for key, flow := range r.flows {
+		if oldestSeen.IsZero() || flow.firstSeen.Before(oldestSeen) {
+			oldest, oldestSeen = key, flow.firstSeen
+		}
+	}
+	delete(r.flows, oldest)
+}
+
+// Pending returns the number of packets waiting for more fragments
+// フラグメントの到着を待っているパケットの数を返します
+func (r *IPv6Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.flows)
+}
@@ -0,0 +1,110 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// identification の IPv6 パケットのうち、offset から data を運ぶフラグメントの Ethernet フレーム
+func ipv6ReassemblyTestFrame(identification uint32, offset int, more bool, data []byte) []byte {
+	fragment := []byte{IPv6_NEXT_HEADER_UDP, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	offsetFlags := uint16(offset/8) << 3
+	if more {
+		offsetFlags |= 0x0001
+	}
+	binary.BigEndian.PutUint16(fragment[2:4], offsetFlags)
+	binary.BigEndian.PutUint32(fragment[4:8], identification)
+
+	ipv6 := []byte{0x60, 0x00, 0x00, 0x00, 0x00, 0x00, IPv6_NEXT_HEADER_FRAGMENT, 0x40}
+	binary.BigEndian.PutUint16(ipv6[4:6], uint16(len(fragment)+len(data)))
+	ipv6 = append(ipv6, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01)
+	ipv6 = append(ipv6, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x02)
+
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x86, 0xdd}
+	frame = append(append(append(frame, ipv6...), fragment...), data...)
+	return frame
+}
+
+// 53番ポート宛の UDP データグラム (ヘッダー 8 バイト + ペイロード 24 バイト)
+// TestIPv6Reassembler tests that a UDP packet split into two fragments is reassembled, in either order
+// 2つのフラグメントに分割されたUDPパケットが、どちらの順で届いても再構築されることをテストします
+func TestIPv6Reassembler(t *testing.T) {
+	udp := testFragmentedUDP()
+	first := ipv6ReassemblyTestFrame(0x1234, 0, true, udp[:16])
+	last := ipv6ReassemblyTestFrame(0x1234, 16, false, udp[16:])
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, frames := range map[string][][]byte{
+		"in order":     {first, last},
+		"out of order": {last, first},
+	} {
+		r := NewIPv6Reassembler()
+		if got := r.Reassemble(testDecodeFrameAt(t, frames[0], now)); got != nil {
+			t.Fatalf("%s: Reassemble() of the first fragment = %+v, want nil", name, got)
+		}
+		if r.Pending() != 1 {
+			t.Errorf("%s: Pending() = %d, want 1", name, r.Pending())
+		}
+
+		got := r.Reassemble(testDecodeFrameAt(t, frames[1], now.Add(time.Millisecond)))
+		if got == nil {
+			t.Fatalf("%s: Reassemble() of the second fragment should return the packet", name)
+		}
+		if got.IPv6 == nil || got.IPv6.NextHeader != IPv6_NEXT_HEADER_UDP || got.IPv6.PayloadLen != uint16(len(udp)) {
+			t.Errorf("%s: IPv6 = %+v, want next header UDP and payload length %d", name, got.IPv6, len(udp))
+		}
+		if got.UDP == nil || got.UDP.DstPort != 53 || string(got.UDP.Payload) != "fragmented dns over ipv6" {
+			t.Errorf("%s: UDP = %+v, want the whole datagram to port 53", name, got.UDP)
+		}
+		if got.EthernetFrame.Type != ETHER_TYPE_IPv6 || !got.Timestamp.Equal(now.Add(time.Millisecond)) {
+			t.Errorf("%s: reassembled frame type 0x%04x at %s", name, got.EthernetFrame.Type, got.Timestamp)
+		}
+		if r.Pending() != 0 {
+			t.Errorf("%s: Pending() after reassembly = %d, want 0", name, r.Pending())
+		}
+	}
+
+	// フラグメントでないパケットはそのまま
+	r := NewIPv6Reassembler()
+	passive := testDecodeFrameAt(t, checksumReportTestICMPv6Frame(), now)
+	if got := r.Reassemble(passive); got != passive {
+		t.Errorf("Reassemble() of an unfragmented packet = %+v, want it unchanged", got)
+	}
+}
+
+// TestIPv6ReassemblerDrop tests that fragments are dropped on timeout, on overlap and beyond the flow cap
+// タイムアウト、重なり、同時に再構築する数の上限でフラグメントが破棄されることをテストします
+func TestIPv6ReassemblerDrop(t *testing.T) {
+	udp := testFragmentedUDP()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := NewIPv6Reassembler()
+	r.Reassemble(testDecodeFrameAt(t, ipv6ReassemblyTestFrame(1, 0, true, udp[:16]), now))
+	late := testDecodeFrameAt(t, ipv6ReassemblyTestFrame(1, 16, false, udp[16:]), now.Add(IPv6_REASSEMBLY_TIMEOUT+time.Second))
+	if got := r.Reassemble(late); got != nil {
+		t.Errorf("Reassemble() after the timeout = %+v, want nil", got)
+	}
+
+	r = NewIPv6Reassembler()
+	r.Reassemble(testDecodeFrameAt(t, ipv6ReassemblyTestFrame(2, 0, true, udp[:16]), now))
+	r.Reassemble(testDecodeFrameAt(t, ipv6ReassemblyTestFrame(2, 8, true, udp[8:24]), now))
+	if r.Pending() != 0 {
+		t.Errorf("Pending() after overlapping fragments = %d, want 0", r.Pending())
+	}
+	if got := r.Reassemble(testDecodeFrameAt(t, ipv6ReassemblyTestFrame(2, 16, false, udp[16:]), now)); got != nil {
+		t.Errorf("Reassemble() after an overlap = %+v, want nil", got)
+	}
+
+	r = NewIPv6Reassembler()
+	for i := 0; i < IPv6_REASSEMBLY_MAX_FLOWS+10; i++ {
+		r.Reassemble(testDecodeFrameAt(t, ipv6ReassemblyTestFrame(uint32(100+i), 0, true, udp[:16]), now.Add(time.Duration(i)*time.Millisecond)))
+	}
+	if r.Pending() != IPv6_REASSEMBLY_MAX_FLOWS {
+		t.Errorf("Pending() = %d, want at most %d", r.Pending(), IPv6_REASSEMBLY_MAX_FLOWS)
+	}
+	// 最も古いものから破棄されている
+	if got := r.Reassemble(testDecodeFrameAt(t, ipv6ReassemblyTestFrame(100, 16, false, udp[16:]), now)); got != nil {
+		t.Error("the oldest packet should have been evicted")
+	}
+}
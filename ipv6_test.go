@@ -0,0 +1,91 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// TestNewIPv6WithPayload tests that an IPv6 packet built with its payload survives serializing and decoding,
+// and that addresses which are not IPv6 are rejected
+// ペイロード付きで作成したIPv6パケットがバイト列にして解析し直しても変わらないこと、
+// およびIPv6でないアドレスがエラーになることをテストします
+func TestNewIPv6WithPayload(t *testing.T) {
+	src, dst := net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::1")
+	icmpv6 := NewICMPv6EchoRequest()
+	icmpv6.Checksum = icmpv6.CalculateChecksum(src, dst)
+	payload := icmpv6.Bytes()
+
+	ipv6, err := NewIPv6WithPayload(src, dst, IPv6_NEXT_HEADER_ICMPv6, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv6.PayloadLength != uint16(len(payload)) {
+		t.Errorf("PayloadLength = %d, want %d", ipv6.PayloadLength, len(payload))
+	}
+
+	parsed := ParsedIPv6(ipv6.Bytes())
+	if parsed.Version != 6 || parsed.NextHeader != IPv6_NEXT_HEADER_ICMPv6 || parsed.HopLimit != 0x40 ||
+		parsed.PayloadLength != uint16(len(payload)) || !bytes.Equal(parsed.Data, payload) {
+		t.Errorf("ParsedIPv6() = %+v", parsed)
+	}
+	if parsed.StrSrcIPAddr() != "2001:db8::2" || parsed.StrDstIPAddr() != "2001:db8::1" {
+		t.Errorf("addresses = %s -> %s", parsed.StrSrcIPAddr(), parsed.StrDstIPAddr())
+	}
+
+	frame := NewEthernetFrame(
+		HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a},
+		HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b},
+		ETHER_TYPE_IPv6,
+		ipv6.Bytes(),
+	)
+	passive, err := DecodeFrame(frame.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.IPv6 == nil || !net.IP(passive.IPv6.SrcIP).Equal(src) || passive.IPv6.PayloadLen != uint16(len(payload)) {
+		t.Fatalf("IPv6 = %+v", passive.IPv6)
+	}
+	if passive.ICMPv6 == nil || passive.ICMPv6.Type != ICMPv6_TYPE_ECHO_REQUEST {
+		t.Errorf("ICMPv6 = %+v", passive.ICMPv6)
+	}
+	if report := ValidatePacket(passive); len(report) == 0 || !report.Valid() {
+		t.Errorf("ValidatePacket() = %+v\n%s", report, report)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		src, dst net.IP
+	}{
+		{name: "IPv4 source", src: net.ParseIP("192.168.10.2"), dst: dst},
+		{name: "IPv4-mapped destination", src: src, dst: net.ParseIP("::ffff:192.168.10.1")},
+		{name: "4-byte source", src: net.IPv4(192, 168, 10, 2).To4(), dst: dst},
+		{name: "nil destination", src: src, dst: nil},
+	} {
+		if _, err := NewIPv6WithPayload(tt.src, tt.dst, IPv6_NEXT_HEADER_ICMPv6, payload); err == nil {
+			t.Errorf("%s: NewIPv6WithPayload() succeeded", tt.name)
+		}
+	}
+}
+
+// TestBuildIPv6PseudoHeader tests the pseudo-header byte for byte against the layout of RFC 8200 section 8.1,
+// and that the pseudo-header of an IPv6 packet is the same
+// 疑似ヘッダがRFC 8200 8.1節のレイアウトとバイト単位で一致すること、およびIPv6パケットの疑似ヘッダも同じであることをテストします
+func TestBuildIPv6PseudoHeader(t *testing.T) {
+	src, dst := net.ParseIP("2001:db8::2"), net.ParseIP("fe80::1")
+	want := []byte{
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // Source Address
+		0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // Destination Address
+		0x00, 0x01, 0x02, 0x03, // Upper-Layer Packet Length
+		0x00, 0x00, 0x00, // zero
+		IPv6_NEXT_HEADER_UDP, // Next Header
+	}
+
+	got := BuildIPv6PseudoHeader(src, dst, 0x00010203, IPv6_NEXT_HEADER_UDP)
+	if !bytes.Equal(got, want) {
+		t.Errorf("BuildIPv6PseudoHeader() = % x\nwant % x", got, want)
+	}
+	if got := NewIPv6(IPv6_NEXT_HEADER_UDP, src, dst).PseudoHeader(0x00010203); !bytes.Equal(got, want) {
+		t.Errorf("IPv6.PseudoHeader() = % x\nwant % x", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package packemon
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	tlsExtensionSupportedGroups = 10 // 旧 elliptic_curves
+	tlsExtensionECPointFormats  = 11
+)
+
+// tlsHello is the part of a ClientHello or ServerHello used for JA3 and JA3S
+// JA3とJA3Sに使うClientHelloまたはServerHelloの部分です
+type tlsHello struct {
+	handshakeType uint8
+	version       uint16
+	cipherSuites  []uint16
+	extensions    []uint16
+	curves        []uint16
+	pointFormats  []uint8
+}
+
+// parsedTLSHello parses a ClientHello or ServerHello handshake message, bounds-checked since it reads captured records
+// ClientHelloまたはServerHelloのハンドシェイクメッセージを解析します。キャプチャしたレコードを読むため境界を確認します
+func parsedTLSHello(handshake []byte) (*tlsHello, error) {
+	r := NewFieldReader(handshake, binary.BigEndian)
+	handshakeType, _ := r.Read8()
+	if handshakeType != TLS_HANDSHAKE_TYPE_CLIENT_HELLO && handshakeType != TLS_HANDSHAKE_TYPE_SERVER_HELLO {
+		return nil, fmt.Errorf("not a hello handshake: %d", handshakeType)
+	}
+	r.Skip(3) // Length
+	hello := &tlsHello{handshakeType: handshakeType}
+
+	var err error
+	if hello.version, err = r.Read16(); err != nil {
+		return nil, fmt.Errorf("tls hello too short: %w", err)
+	}
+	if err := r.Skip(32); err != nil { // Random
+		return nil, fmt.Errorf("tls hello too short: %w", err)
+	}
+	sessionIDLength, err := r.Read8()
+	if err != nil {
+		return nil, fmt.Errorf("tls hello too short: %w", err)
+	}
+	if err := r.Skip(int(sessionIDLength)); err != nil {
+		return nil, fmt.Errorf("tls session id too short: %w", err)
+	}
+
+	if handshakeType == TLS_HANDSHAKE_TYPE_CLIENT_HELLO {
+		length, err := r.Read16()
+		if err != nil {
+			return nil, fmt.Errorf("tls hello too short: %w", err)
+		}
+		cipherSuites, err := r.ReadBytes(int(length))
+		if err != nil {
+			return nil, fmt.Errorf("tls cipher suites too short: %w", err)
+		}
+		hello.cipherSuites = tlsUint16s(cipherSuites)
+
+		compressionMethodsLength, err := r.Read8()
+		if err != nil {
+			return nil, fmt.Errorf("tls hello too short: %w", err)
+		}
+		if err := r.Skip(int(compressionMethodsLength)); err != nil {
+			return nil, fmt.Errorf("tls compression methods too short: %w", err)
+		}
+	} else {
+		cipherSuite, err := r.Read16()
+		if err != nil {
+			return nil, fmt.Errorf("tls hello too short: %w", err)
+		}
+		hello.cipherSuites = []uint16{cipherSuite}
+		if err := r.Skip(1); err != nil { // Compression Method
+			return nil, fmt.Errorf("tls hello too short: %w", err)
+		}
+	}
+
+	// 拡張は省略されることがある
+	if r.Remaining() == 0 {
+		return hello, nil
+	}
+	length, err := r.Read16()
+	if err != nil {
+		return nil, fmt.Errorf("tls extensions too short: %w", err)
+	}
+	extensions, err := r.ReadBytes(int(length))
+	if err != nil {
+		return nil, fmt.Errorf("tls extensions too short: %w", err)
+	}
+	for er := NewFieldReader(extensions, binary.BigEndian); er.Remaining() > 0; {
+		typ, _ := er.Read16()
+		length, err := er.Read16()
+		if err != nil {
+			return nil, fmt.Errorf("tls extension header too short: %w", err)
+		}
+		data, err := er.ReadBytes(int(length))
+		if err != nil {
+			return nil, fmt.Errorf("tls extension %d too short: %w", typ, err)
+		}
+		hello.extensions = append(hello.extensions, typ)
+
+		switch typ {
+		case tlsExtensionSupportedGroups:
+			if len(data) >= 2 {
+				hello.curves = tlsUint16s(data[2:min(len(data), 2+int(binary.BigEndian.Uint16(data)))])
+			}
+		case tlsExtensionECPointFormats:
+			if len(data) >= 1 {
+				hello.pointFormats = data[1:min(len(data), 1+int(data[0]))]
+			}
+		}
+	}
+	return hello, nil
+}
+
+func tlsUint16s(b []byte) []uint16 {
+	values := make([]uint16, 0, len(b)/2)
+	for ; len(b) >= 2; b = b[2:] {
+		values = append(values, binary.BigEndian.Uint16(b))
+	}
+	return values
+}
+
+// isTLSGREASE reports whether v is a GREASE value (RFC 8701), which JA3 ignores since clients pick them at random
+// ref: https://datatracker.ietf.org/doc/html/rfc8701
+// vがGREASEの値かどうかを返します。クライアントがランダムに選ぶ値のため、JA3では無視します
+func isTLSGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// ja3Join joins values in decimal with "-", skipping GREASE values if skipGREASE
+func ja3Join[T uint8 | uint16](values []T, skipGREASE bool) string {
+	fields := make([]string, 0, len(values))
+	for _, v := range values {
+		if skipGREASE && isTLSGREASE(uint16(v)) {
+			continue
+		}
+		fields = append(fields, strconv.Itoa(int(v)))
+	}
+	return strings.Join(fields, "-")
+}
+
+// ja3 returns the JA3 string of a ClientHello: SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats,
+// or the JA3S string of a ServerHello: SSLVersion,Cipher,Extensions
+// ref: https://github.com/salesforce/ja3
+// ClientHelloのJA3文字列、またはServerHelloのJA3S文字列を返します
+func (h *tlsHello) ja3() string {
+	version := strconv.Itoa(int(h.version))
+	if h.handshakeType == TLS_HANDSHAKE_TYPE_SERVER_HELLO {
+		return strings.Join([]string{version, ja3Join(h.cipherSuites, false), ja3Join(h.extensions, false)}, ",")
+	}
+	return strings.Join([]string{
+		version,
+		ja3Join(h.cipherSuites, true),
+		ja3Join(h.extensions, true),
+		ja3Join(h.curves, true),
+		ja3Join(h.pointFormats, false),
+	}, ",")
+}
+
+// JA3Hash returns the MD5 of a JA3 or JA3S string in hex, as the fingerprint is usually shared
+// JA3またはJA3S文字列のMD5を16進数で返します。フィンガープリントは通常この形式で共有されます
+func JA3Hash(ja3 string) string {
+	sum := md5.Sum([]byte(ja3))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint sets JA3 or JA3S when the record is a ClientHello or ServerHello handshake
+// レコードがClientHelloまたはServerHelloのハンドシェイクの場合に、JA3またはJA3Sを設定します
+func (t *TLSRecord) fingerprint() error {
+	if t.Type != TLS_CONTENT_TYPE_HANDSHAKE || len(t.Data) == 0 {
+		return nil
+	}
+	if t.Data[0] != TLS_HANDSHAKE_TYPE_CLIENT_HELLO && t.Data[0] != TLS_HANDSHAKE_TYPE_SERVER_HELLO {
+		return nil
+	}
+	hello, err := parsedTLSHello(t.Data[:min(len(t.Data), int(t.Length))])
+	if err != nil {
+		return err
+	}
+	if hello.handshakeType == TLS_HANDSHAKE_TYPE_CLIENT_HELLO {
+		t.JA3 = hello.ja3()
+		t.JA3Hash = JA3Hash(t.JA3)
+		return nil
+	}
+	t.JA3S = hello.ja3()
+	t.JA3SHash = JA3Hash(t.JA3S)
+	return nil
+}
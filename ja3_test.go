@@ -0,0 +1,122 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// ハンドシェイクメッセージ body を TLS レコードにする
+func ja3TestRecord(handshakeType uint8, body []byte) []byte {
+	handshake := []byte{handshakeType, 0x00, 0x00, 0x00}
+	handshake[1], handshake[2], handshake[3] = byte(len(body)>>16), byte(len(body)>>8), byte(len(body))
+	handshake = append(handshake, body...)
+
+	record := []byte{TLS_CONTENT_TYPE_HANDSHAKE, 0x03, 0x01, 0x00, 0x00}
+	binary.BigEndian.PutUint16(record[3:5], uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+func ja3TestExtensions(extensions ...[]byte) []byte {
+	b := []byte{0x00, 0x00}
+	for _, extension := range extensions {
+		b = append(b, extension...)
+	}
+	binary.BigEndian.PutUint16(b, uint16(len(b)-2))
+	return b
+}
+
+func ja3TestExtension(typ uint16, data []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// JA3 の README に載っている ClientHello に、無視されるべき GREASE の値を加えたもの
+// ref: https://github.com/salesforce/ja3
+func ja3TestClientHello() []byte {
+	body := []byte{0x03, 0x01} // TLS 1.0 (769)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0x00) // Session ID なし
+
+	ciphers := []uint16{0x0a0a, 47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ciphers)*2))
+	for _, cipher := range ciphers {
+		body = binary.BigEndian.AppendUint16(body, cipher)
+	}
+	body = append(body, 0x01, COMPRESSION_METHOD_NULL)
+
+	body = append(body, ja3TestExtensions(
+		ja3TestExtension(0x1a1a, nil),
+		ja3TestExtension(0, []byte{0x00, 0x0b, 0x00, 0x00, 0x08, 'e', 'x', 'a', 'm', 'p', 'l', 'e'}),
+		ja3TestExtension(10, []byte{0x00, 0x08, 0x2a, 0x2a, 0x00, 23, 0x00, 24, 0x00, 25}),
+		ja3TestExtension(11, []byte{0x01, 0x00}),
+	)...)
+	return ja3TestRecord(TLS_HANDSHAKE_TYPE_CLIENT_HELLO, body)
+}
+
+// TestTLSFingerprintJA3 tests the JA3 of a ClientHello against the published fingerprint, with GREASE values ignored
+// ClientHelloのJA3を公開されているフィンガープリントと比較します。GREASEの値は無視されます
+func TestTLSFingerprintJA3(t *testing.T) {
+	tcp := []byte{0xc3, 0x50, 0x01, 0xbb, 0, 0, 0, 1, 0, 0, 0, 0, 0x50, TCP_FLAGS_PSH_ACK, 0xff, 0xff, 0, 0, 0, 0}
+	passive, err := DecodeFrame(ipTunnelTestFrame(IPv4_PROTO_TCP, append(tcp, ja3TestClientHello()...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.TLS == nil {
+		t.Fatal("TLS should be decoded")
+	}
+
+	const want = "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0"
+	if passive.TLS.JA3 != want {
+		t.Errorf("JA3 = %q, want %q", passive.TLS.JA3, want)
+	}
+	if passive.TLS.JA3Hash != "ada70206e40642a3e4461f35503241d5" {
+		t.Errorf("JA3Hash = %s, want ada70206e40642a3e4461f35503241d5", passive.TLS.JA3Hash)
+	}
+	if passive.TLS.JA3S != "" {
+		t.Errorf("JA3S of a ClientHello = %q, want empty", passive.TLS.JA3S)
+	}
+
+	// JA3 はコネクションごとに記録される
+	flows := NewTCPFlows()
+	flows.Update(passive)
+	if stats := flows.Stats(); len(stats) != 1 || stats[0].JA3Hash != passive.TLS.JA3Hash {
+		t.Errorf("TCPFlows.Stats() = %+v, want the JA3 hash on the flow", stats)
+	}
+
+	// 途中で切れた ClientHello ではフィンガープリントを求めない
+	record := ja3TestClientHello()
+	truncated := &TLSRecord{Type: record[0], Length: binary.BigEndian.Uint16(record[3:5]), Data: record[5 : len(record)-10]}
+	if err := truncated.fingerprint(); err == nil || truncated.JA3 != "" {
+		t.Errorf("fingerprint() of a truncated ClientHello = %v, JA3 %q, want an error", err, truncated.JA3)
+	}
+}
+
+// TestTLSFingerprintJA3S tests the JA3S of a ServerHello
+// ServerHelloのJA3Sをテストします
+func TestTLSFingerprintJA3S(t *testing.T) {
+	body := []byte{0x03, 0x01}
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0x00)
+	body = append(body, 0x00, 47, COMPRESSION_METHOD_NULL)
+	body = append(body, ja3TestExtensions(
+		ja3TestExtension(65281, []byte{0x00}),
+		ja3TestExtension(0, nil),
+		ja3TestExtension(11, []byte{0x01, 0x00}),
+		ja3TestExtension(35, nil),
+		ja3TestExtension(5, nil),
+		ja3TestExtension(16, []byte{0x00, 0x03, 0x02, 'h', '2'}),
+	)...)
+	record := ja3TestRecord(TLS_HANDSHAKE_TYPE_SERVER_HELLO, body)
+
+	tls := &TLSRecord{Type: record[0], Length: binary.BigEndian.Uint16(record[3:5]), Data: record[5:]}
+	if err := tls.fingerprint(); err != nil {
+		t.Fatal(err)
+	}
+	if tls.JA3S != "769,47,65281-0-11-35-5-16" || tls.JA3SHash != "836ce314215654b5b1f85f97c73e506f" {
+		t.Errorf("JA3S = %q (%s), want 769,47,65281-0-11-35-5-16 (836ce314215654b5b1f85f97c73e506f)", tls.JA3S, tls.JA3SHash)
+	}
+	if tls.JA3 != "" {
+		t.Errorf("JA3 of a ServerHello = %q, want empty", tls.JA3)
+	}
+}
@@ -0,0 +1,158 @@
+package packemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Serializable is a packet, or a part of one, that can be written to the wire
+// ネットワークに送出できる形に変換できるパケット(またはその一部)です
+type Serializable interface {
+	Bytes() []byte
+}
+
+// Layer is a protocol layer that can be stacked with SerializeStack.
+// SetPayload sets the serialized upper layers, and returns ErrNoPayload for layers that carry none
+// SerializeStackで積み重ねられるプロトコルのレイヤです。
+// SetPayloadは上位のレイヤをバイト列にしたものを設定します。ペイロードを持たないレイヤはErrNoPayloadを返します
+type Layer interface {
+	Serializable
+	SetPayload(payload []byte) error
+}
+
+// ErrNoPayload is returned by SetPayload of a layer that has no upper layer, e.g. ARP or DNS
+// 上位のレイヤを持たないレイヤ(例: ARP、DNS)のSetPayloadが返すエラーです
+var ErrNoPayload = errors.New("layer carries no payload")
+
+var (
+	_ Layer = (*EthernetFrame)(nil)
+	_ Layer = (*ARP)(nil)
+	_ Layer = (*IPv4)(nil)
+	_ Layer = (*IPv6)(nil)
+	_ Layer = (*ICMP)(nil)
+	_ Layer = (*ICMPv6)(nil)
+	_ Layer = (*TCP)(nil)
+	_ Layer = (*UDP)(nil)
+	_ Layer = (*DNS)(nil)
+	_ Layer = (*HTTP)(nil)
+	_ Layer = (*DHCP)(nil)
+	_ Layer = (*BGP)(nil)
+	_ Layer = (*OSPF)(nil)
+	_ Layer = Payload(nil)
+)
+
+// SerializeStack serializes layers, ordered from the lowest (e.g. Ethernet) to the highest, into one packet.
+// Each layer gets the layers above it as its payload, and the IPv4/IPv6/UDP lengths and the IPv4, TCP, UDP, ICMP and ICMPv6 checksums are recalculated.
+// Transport checksums use the addresses of the IPv4 or IPv6 layer right below them
+// 下位(例: Ethernet)から上位の順に並んだレイヤを1つのパケットにします。
+// 各レイヤには上位のレイヤがペイロードとして設定され、IPv4/IPv6/UDPの長さとIPv4、TCP、UDP、ICMP、ICMPv6のチェックサムは計算し直されます。
+// トランスポート層のチェックサムは直下のIPv4またはIPv6のレイヤのアドレスで計算します
+func SerializeStack(layers []Layer) ([]byte, error) {
+	if len(layers) == 0 {
+		return nil, errors.New("no layers to serialize")
+	}
+
+	var payload []byte
+	for i := len(layers) - 1; i >= 0; i-- {
+		if i < len(layers)-1 {
+			if err := layers[i].SetPayload(payload); err != nil {
+				return nil, fmt.Errorf("layer %d (%T): %w", i, layers[i], err)
+			}
+		}
+		var lower Layer
+		if i > 0 {
+			lower = layers[i-1]
+		}
+		finalizeLayer(layers[i], lower)
+		payload = layers[i].Bytes()
+	}
+	return payload, nil
+}
+
+// 長さとチェックサムを、設定済みのペイロードと直下のレイヤから計算し直す
+func finalizeLayer(layer Layer, lower Layer) {
+	switch l := layer.(type) {
+	case *IPv4:
+		l.CalculateTotalLength()
+		l.HeaderChecksum = 0
+		l.CalculateChecksum()
+	case *IPv6:
+		l.PayloadLength = uint16(len(l.Option) + len(l.Data))
+	case *TCP:
+		l.Checksum = 0
+		switch ip := lower.(type) {
+		case *IPv4:
+			l.CalculateChecksum(ip)
+		case *IPv6:
+			l.CalculateChecksumForIPv6(ip)
+		}
+	case *UDP:
+		l.Len()
+		l.Checksum = 0
+		switch ip := lower.(type) {
+		case *IPv4:
+			l.CalculateChecksum(ip)
+		case *IPv6:
+			l.CalculateChecksumForIPv6(ip)
+		}
+	case *ICMP:
+		l.setChecksum()
+	case *ICMPv6:
+		if ip, ok := lower.(*IPv6); ok {
+			l.Checksum = l.CalculateChecksum(net.IP(ip.SrcAddr), net.IP(ip.DstAddr))
+		}
+	}
+}
+
+// Payload is application data without a header of its own, e.g. the data of a TCP segment
+// 独自のヘッダを持たないアプリケーションのデータです(例: TCPセグメントのデータ)
+type Payload []byte
+
+func (p Payload) Bytes() []byte {
+	return append([]byte(nil), p...)
+}
+
+func (Payload) SetPayload([]byte) error {
+	return ErrNoPayload
+}
+
+func (ef *EthernetFrame) SetPayload(payload []byte) error {
+	ef.Data = payload
+	return nil
+}
+
+func (i *IPv4) SetPayload(payload []byte) error {
+	i.Data = payload
+	return nil
+}
+
+func (i *IPv6) SetPayload(payload []byte) error {
+	i.Data = payload
+	return nil
+}
+
+func (t *TCP) SetPayload(payload []byte) error {
+	t.Data = payload
+	return nil
+}
+
+func (u *UDP) SetPayload(payload []byte) error {
+	u.Data = payload
+	return nil
+}
+
+// ICMP のペイロードは識別子とシーケンス番号(エラーメッセージでは未使用のフィールド)の後に続くデータ
+func (i *ICMP) SetPayload(payload []byte) error {
+	i.Data = payload
+	return nil
+}
+
+// ICMPv6 の MessageBody はメッセージの種類ごとのフィールドを含むため、上位のレイヤとしては扱わない
+func (*ICMPv6) SetPayload([]byte) error { return ErrNoPayload }
+func (*ARP) SetPayload([]byte) error    { return ErrNoPayload }
+func (*DNS) SetPayload([]byte) error    { return ErrNoPayload }
+func (*HTTP) SetPayload([]byte) error   { return ErrNoPayload }
+func (*DHCP) SetPayload([]byte) error   { return ErrNoPayload }
+func (*BGP) SetPayload([]byte) error    { return ErrNoPayload }
+func (*OSPF) SetPayload([]byte) error   { return ErrNoPayload }
@@ -0,0 +1,121 @@
+package packemon
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// TestSerializeStack tests serializing mixed stacks through the Layer interface and decoding them back with valid lengths and checksums
+// Layerインタフェースを通して様々なスタックをバイト列にし、正しい長さとチェックサムで解析し直せることをテストします
+func TestSerializeStack(t *testing.T) {
+	src := HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b}
+	dst := HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}
+	srcIPv4, dstIPv4 := uint32(0xc0a80a02), uint32(0xc0a80a01) // 192.168.10.2 -> 192.168.10.1
+	srcIPv6, dstIPv6 := net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::1")
+
+	tests := []struct {
+		name  string
+		stack []Layer
+		check func(t *testing.T, passive *Passive)
+	}{
+		{
+			name: "Ethernet/IPv4/UDP/DNS",
+			stack: []Layer{
+				NewEthernetFrame(dst, src, ETHER_TYPE_IPv4, nil),
+				NewIPv4(IPv4_PROTO_UDP, srcIPv4, dstIPv4),
+				&UDP{SrcPort: 50000, DstPort: PORT_DNS},
+				NewDNSQuery("go.dev", DNS_QUERY_TYPE_A),
+			},
+			check: func(t *testing.T, passive *Passive) {
+				if passive.UDP == nil || passive.UDP.DstPort != PORT_DNS || passive.DNS == nil || passive.DNS.Questions != 1 {
+					t.Errorf("UDP = %+v, DNS = %+v", passive.UDP, passive.DNS)
+				}
+			},
+		},
+		{
+			name: "Ethernet/IPv6/TCP/HTTP",
+			stack: []Layer{
+				NewEthernetFrame(dst, src, ETHER_TYPE_IPv6, nil),
+				NewIPv6(IPv6_NEXT_HEADER_TCP, srcIPv6, dstIPv6),
+				NewTCPWithData(50000, PORT_HTTP, nil, 1, 1),
+				NewHTTP(),
+			},
+			check: func(t *testing.T, passive *Passive) {
+				if passive.IPv6 == nil || passive.TCP == nil || passive.HTTP == nil || passive.HTTP.Method != "GET" {
+					t.Errorf("IPv6 = %+v, TCP = %+v, HTTP = %+v", passive.IPv6, passive.TCP, passive.HTTP)
+				}
+			},
+		},
+		{
+			name: "Ethernet/IPv4/TCP/Payload with an odd length",
+			stack: []Layer{
+				NewEthernetFrame(dst, src, ETHER_TYPE_IPv4, nil),
+				NewIPv4(IPv4_PROTO_TCP, srcIPv4, dstIPv4),
+				NewTCPWithData(50000, 9999, nil, 1, 1),
+				Payload("hello"),
+			},
+			check: func(t *testing.T, passive *Passive) {
+				if passive.TCP == nil || string(passive.TCP.Payload) != "hello" {
+					t.Errorf("TCP = %+v", passive.TCP)
+				}
+			},
+		},
+		{
+			name: "Ethernet/IPv4/ICMP/Payload",
+			stack: []Layer{
+				NewEthernetFrame(dst, src, ETHER_TYPE_IPv4, nil),
+				NewIPv4(IPv4_PROTO_ICMP, srcIPv4, dstIPv4),
+				NewICMP(),
+				Payload("ping"),
+			},
+			check: func(t *testing.T, passive *Passive) {
+				if passive.ICMP == nil || passive.ICMP.Type != ICMP_TYPE_REQUEST {
+					t.Errorf("ICMP = %+v", passive.ICMP)
+				}
+			},
+		},
+		{
+			name: "Ethernet/IPv6/ICMPv6",
+			stack: []Layer{
+				NewEthernetFrame(dst, src, ETHER_TYPE_IPv6, nil),
+				NewIPv6(IPv6_NEXT_HEADER_ICMPv6, srcIPv6, dstIPv6),
+				NewICMPv6EchoRequest(),
+			},
+			check: func(t *testing.T, passive *Passive) {
+				if passive.ICMPv6 == nil || passive.ICMPv6.Type != ICMPv6_TYPE_ECHO_REQUEST {
+					t.Errorf("ICMPv6 = %+v", passive.ICMPv6)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := SerializeStack(tt.stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			passive, err := DecodeFrame(frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report := ValidatePacket(passive); len(report) == 0 || !report.Valid() {
+				t.Errorf("ValidatePacket() = %+v\n%s", report, report)
+			}
+			tt.check(t, passive)
+		})
+	}
+
+	stack := []Layer{
+		NewIPv4(IPv4_PROTO_UDP, srcIPv4, dstIPv4),
+		NewDNSQuery("go.dev", DNS_QUERY_TYPE_A),
+		Payload("trailing"),
+	}
+	if _, err := SerializeStack(stack); !errors.Is(err, ErrNoPayload) {
+		t.Errorf("DNS with a payload: SerializeStack() error = %v, want ErrNoPayload", err)
+	}
+	if _, err := SerializeStack(nil); err == nil {
+		t.Error("empty stack: SerializeStack() succeeded")
+	}
+}
@@ -0,0 +1,24 @@
+package packemon
+
+// LinkStats are the kernel's counters of a network interface, counted for every program on the host since the interface came up.
+// Comparing RxDropped with the packets packemon itself drops (see SetLogger) tells whether loss happens in the kernel or in packemon.
+// カーネルが数えているネットワークインターフェースのカウンタです。インターフェースが起動してからの、ホストの全プログラム分の値です。
+// RxDroppedとpackemon自身が破棄したパケット(SetLogger参照)を比べると、カーネルとpackemonのどちらで欠落しているかが分かります
+type LinkStats struct {
+	RxPackets uint64
+	TxPackets uint64
+	RxBytes   uint64
+	TxBytes   uint64
+	RxDropped uint64
+	TxDropped uint64
+	RxErrors  uint64
+	TxErrors  uint64
+}
+
+// LinkStats reads the interface's counters from the kernel. Each call reads them afresh, and it works on an offline interface too.
+// Only supported on Linux, where they are read via netlink.
+// インターフェースのカウンタをカーネルから読み込みます。呼び出すたびに読み直し、オフラインのインターフェースでも使えます。
+// netlinkで読み込むLinuxのみ対応しています
+func (nwif *NetworkInterface) LinkStats() (LinkStats, error) {
+	return linkStatsPlatform(nwif.Intf.Name)
+}
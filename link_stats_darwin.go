@@ -0,0 +1,12 @@
+//go:build darwin
+// +build darwin
+
+package packemon
+
+import (
+	"errors"
+)
+
+func linkStatsPlatform(name string) (LinkStats, error) {
+	return LinkStats{}, errors.New("link statistics are only supported on Linux")
+}
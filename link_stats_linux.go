@@ -0,0 +1,31 @@
+//go:build linux
+// +build linux
+
+package packemon
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+func linkStatsPlatform(name string) (LinkStats, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return LinkStats{}, fmt.Errorf("getting interface %s: %w", name, err)
+	}
+	stats := link.Attrs().Statistics
+	if stats == nil {
+		return LinkStats{}, fmt.Errorf("no statistics for interface %s", name)
+	}
+	return LinkStats{
+		RxPackets: stats.RxPackets,
+		TxPackets: stats.TxPackets,
+		RxBytes:   stats.RxBytes,
+		TxBytes:   stats.TxBytes,
+		RxDropped: stats.RxDropped,
+		TxDropped: stats.TxDropped,
+		RxErrors:  stats.RxErrors,
+		TxErrors:  stats.TxErrors,
+	}, nil
+}
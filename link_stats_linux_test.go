@@ -0,0 +1,40 @@
+//go:build linux
+// +build linux
+
+package packemon
+
+import (
+	"net"
+	"testing"
+)
+
+// TestLinkStats tests reading the loopback interface's counters, which are moved by a packet sent over it
+// ループバックインターフェースのカウンタを読み込み、送信したパケットで増えることをテストします
+func TestLinkStats(t *testing.T) {
+	lo := NewOfflineNetworkInterface("lo")
+	before, err := lo.LinkStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("packemon")); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := lo.LinkStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.TxPackets <= before.TxPackets || after.TxBytes <= before.TxBytes {
+		t.Errorf("loopback counters did not grow: before %+v, after %+v", before, after)
+	}
+
+	if _, err := NewOfflineNetworkInterface("packemon-missing0").LinkStats(); err == nil {
+		t.Error("LinkStats() of a missing interface should be an error")
+	}
+}
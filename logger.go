@@ -0,0 +1,119 @@
+package packemon
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sync/atomic"
+)
+
+// logger is nil by default, so logging costs only a nil check unless SetLogger is called
+// loggerはデフォルトでnilのため、SetLoggerが呼ばれない限りnilチェックのコストのみです
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger used for debug events such as parse failures, channel drops and checksum mismatches.
+// Passing nil disables logging.
+// パース失敗、チャネルのドロップ、チェックサム不一致などのデバッグイベントを出力するロガーを設定します。nilで無効化します
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// logEnabled reports whether a logger is set
+// ロガーが設定されているかどうかを返します
+func logEnabled() bool {
+	return logger.Load() != nil
+}
+
+// logParseFailure emits a debug event when a layer could not be parsed
+// レイヤーを解析できなかった場合にデバッグイベントを出力します
+func logParseFailure(layer string, data []byte) {
+	if l := logger.Load(); l != nil {
+		l.Debug("failed to parse packet", slog.String("layer", layer), slog.Int("length", len(data)))
+	}
+}
+
+// logChannelDrop emits a debug event when a parsed packet is discarded because the channel is full
+// チャネルが満杯のためパケットを破棄した場合にデバッグイベントを出力します
+func logChannelDrop(channel string, capacity int) {
+	if l := logger.Load(); l != nil {
+		l.Debug("channel is full, packet dropped", slog.String("channel", channel), slog.Int("capacity", capacity))
+	}
+}
+
+// logChecksumMismatch emits a debug event when a received checksum is invalid
+// 受信したチェックサムが不正な場合にデバッグイベントを出力します
+func logChecksumMismatch(layer string, checksum uint16) {
+	if l := logger.Load(); l != nil {
+		l.Debug("checksum mismatch", slog.String("layer", layer), slog.String("checksum", fmt.Sprintf("0x%04x", checksum)))
+	}
+}
+
+// logDNSAlert emits a warning when a DNS response does not match its query
+// DNSレスポンスが問い合わせと一致しない場合に警告を出力します
+func logDNSAlert(alert DNSAlert) {
+	if l := logger.Load(); l != nil {
+		l.Warn("suspicious dns response",
+			slog.String("kind", alert.Kind.String()),
+			slog.String("query", alert.Query),
+			slog.String("resolver", alert.Resolver),
+			slog.String("source", alert.Source),
+			slog.String("detail", alert.Detail))
+	}
+}
+
+// logDNSTunnel emits a warning when a domain is first suspected of DNS tunneling
+// ドメインに初めてDNSトンネリングの疑いが出た場合に警告を出力します
+func logDNSTunnel(alert DNSTunnelAlert) {
+	if l := logger.Load(); l != nil {
+		l.Warn("suspected dns tunnel",
+			slog.String("domain", alert.Domain.Domain),
+			slog.Int("score", alert.Domain.Score()),
+			slog.String("signals", alert.Domain.Signals.String()),
+			slog.String("qname", alert.QName),
+			slog.String("client", alert.Client))
+	}
+}
+
+// logNeighborConflict emits a warning when an IP address is claimed by another MAC address
+// IPアドレスを別のMACアドレスが名乗った場合に警告を出力します
+func logNeighborConflict(conflict NeighborConflict) {
+	if l := logger.Load(); l != nil {
+		l.Warn("ip address claimed by another mac address",
+			slog.String("ip", conflict.IP.String()),
+			slog.String("old_mac", conflict.OldMAC.String()),
+			slog.String("new_mac", conflict.NewMAC.String()))
+	}
+}
+
+// logARPDefense emits a warning when a corrective gratuitous ARP is sent for a protected IP address
+// 保護するIPアドレスに訂正のGratuitous ARPを送信する場合に警告を出力します
+func logARPDefense(ip net.IP, spoofed net.HardwareAddr, legitimate net.HardwareAddr) {
+	if l := logger.Load(); l != nil {
+		l.Warn("sending corrective arp for spoofed ip address",
+			slog.String("ip", ip.String()),
+			slog.String("spoofed_mac", spoofed.String()),
+			slog.String("mac", legitimate.String()))
+	}
+}
+
+// logBGPState emits a debug event when a BGP session changes state
+// BGPセッションの状態が変わった場合にデバッグイベントを出力します
+func logBGPState(from BGPState, to BGPState) {
+	if l := logger.Load(); l != nil {
+		l.Debug("bgp state changed", slog.String("from", from.String()), slog.String("to", to.String()))
+	}
+}
+
+// logOSPFNeighborDrop emits a warning when an OSPF neighbor is no longer listed in the Hellos of a router
+// OSPFの隣接ルーターがルーターのHelloに載らなくなった場合に警告を出力します
+func logOSPFNeighborDrop(drop OSPFNeighborDrop) {
+	if l := logger.Load(); l != nil {
+		l.Warn("ospf neighbor dropped",
+			slog.String("router_id", uint32ToStrIPv4Addr(drop.Router.RouterID)),
+			slog.String("area_id", uint32ToStrIPv4Addr(drop.Router.AreaID)),
+			slog.String("interface", drop.Router.Interface),
+			slog.String("neighbor", uint32ToStrIPv4Addr(drop.Neighbor)),
+			slog.Duration("dead_interval", drop.DeadInterval),
+			slog.Bool("router_silent", drop.RouterSilent))
+	}
+}
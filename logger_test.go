@@ -0,0 +1,48 @@
+package packemon
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestSetLoggerParseFailure tests that a forced parse failure is logged
+// 強制的なパース失敗がログ出力されることをテストします
+func TestSetLoggerParseFailure(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+
+	// IHL が 1 (4 bytes) で最小ヘッダー長に満たない IPv4 パケット
+	payload := make([]byte, 20)
+	payload[0] = 0x41
+	passive := &Passive{
+		EthernetFrame: &EthernetFrame{
+			Type:    ETHER_TYPE_IPv4,
+			Payload: payload,
+		},
+	}
+	parseEthernetPayload(passive, nil, PARSE_DEPTH_FULL)
+
+	if passive.IPv4 != nil {
+		t.Fatalf("IPv4 should not be parsed")
+	}
+	out := buf.String()
+	if !strings.Contains(out, "failed to parse packet") || !strings.Contains(out, "layer=IPv4") {
+		t.Errorf("log output = %q, want parse failure of IPv4", out)
+	}
+}
+
+// TestSetLoggerNil tests that nothing is logged when no logger is set
+// ロガーが未設定の場合に何も出力されないことをテストします
+func TestSetLoggerNil(t *testing.T) {
+	SetLogger(nil)
+	if logEnabled() {
+		t.Fatalf("logEnabled() = true, want false")
+	}
+	// panic しないこと
+	logParseFailure("IPv4", nil)
+	logChannelDrop("PassiveCh", 100)
+	logChecksumMismatch("IPv4", 0x1234)
+}
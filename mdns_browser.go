@@ -0,0 +1,347 @@
+package packemon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PORT_MDNS is the port of multicast DNS (RFC 6762)
+// マルチキャストDNS(RFC 6762)のポートです
+const PORT_MDNS = 5353
+
+// MDNS_SERVICES_QUERY is the DNS-SD meta-query name, answered with the service types on the link (RFC 6763 section 9)
+// リンク上のサービスタイプが応答されるDNS-SDのメタクエリの名前です(RFC 6763 9章)
+const MDNS_SERVICES_QUERY = "_services._dns-sd._udp.local"
+
+const (
+	// MDNS_BROWSER_INTERVAL is the interval between the first two queries of ServiceBrowser. It doubles after each query (RFC 6762 section 5.2)
+	// ServiceBrowserの最初の2つのクエリの間隔です。クエリごとに2倍になります(RFC 6762 5.2章)
+	MDNS_BROWSER_INTERVAL = time.Second
+	// MDNS_BROWSER_MAX_INTERVAL caps the interval between queries
+	// クエリの間隔の上限です
+	MDNS_BROWSER_MAX_INTERVAL = time.Hour
+)
+
+// mDNS の CLASS の最上位ビットは、応答では cache-flush、質問では unicast-response を表す
+const mdnsClassMask = 0x7fff
+
+// MDNSService is a service instance discovered on the link with DNS-SD (RFC 6763)
+// DNS-SD(RFC 6763)でリンク上に見つかったサービスのインスタンスです
+type MDNSService struct {
+	Instance  string            // e.g. "Office Printer._ipp._tcp.local"
+	Type      string            // e.g. "_ipp._tcp.local"
+	Host      string            // SRV のターゲット. e.g. "printer.local"
+	Port      uint16            // SRV のポート
+	Addresses []net.IP          // Host の A/AAAA レコード
+	TXT       map[string]string // キーは小文字. 値の無いキーは空文字列
+	LastSeen  time.Time
+}
+
+// Name returns the instance name without the service type, e.g. "Office Printer"
+// サービスタイプを除いたインスタンス名を返します
+func (s *MDNSService) Name() string {
+	if name, ok := strings.CutSuffix(s.Instance, "."+s.Type); ok {
+		return name
+	}
+	return s.Instance
+}
+
+// ServiceBrowser discovers the services on the link by sending mDNS PTR queries for the service types and aggregating
+// the PTR, SRV, TXT and A/AAAA records of the responses into a table of services.
+// Without Types, the types are found with the DNS-SD meta-query first
+// サービスタイプに対するmDNSのPTRクエリを送信し、応答のPTR、SRV、TXT、A/AAAAレコードをサービスの一覧に集計して、
+// リンク上のサービスを見つけます。Typesが無い場合は、まずDNS-SDのメタクエリでタイプを見つけます
+type ServiceBrowser struct {
+	MAC   net.HardwareAddr
+	IP    net.IP
+	Types []string // e.g. "_http._tcp.local"
+
+	mu       sync.Mutex
+	services map[string]*MDNSService // インスタンス名(小文字)ごと
+	hosts    map[string][]net.IP     // ホスト名(小文字)ごと
+	types    map[string]string       // 見つけたサービスタイプ(小文字 -> 元の表記)
+
+	send      func(ctx context.Context, frame []byte) error
+	passiveCh <-chan *Passive
+	// receive runs the receive loop until ctx is done. nil if someone else runs it
+	receive func(ctx context.Context)
+	join    func(group net.IP) error
+}
+
+// NewServiceBrowser creates a service browser that sends from the addresses of nwif.
+// Browse runs the receive loop itself, so don't run it together with another receiver such as the Monitor
+// nwifのアドレスから送信するサービスブラウザを作成します。
+// Browseは自身で受信ループを動かすため、Monitorなど他の受信処理と同時に動かさないでください
+func NewServiceBrowser(nwif *NetworkInterface, types ...string) *ServiceBrowser {
+	mac, ipv4, _ := nwif.GetNetworkInfo()
+	return &ServiceBrowser{
+		MAC:       mac,
+		IP:        ipv4,
+		Types:     types,
+		send:      nwif.SendEthernetFrame,
+		passiveCh: nwif.PassiveCh,
+		receive:   nwif.ReceiveEthernetFrame,
+		join:      nwif.JoinMulticast,
+	}
+}
+
+// Browse joins the mDNS group and queries for the services until ctx is done, with the interval between queries doubling
+// from MDNS_BROWSER_INTERVAL. Service types found with the meta-query are queried as soon as they are found.
+// It returns nil when ctx is done, and the services found are in Services
+// mDNSのグループに参加し、ctxが終了するまでサービスを問い合わせます。クエリの間隔はMDNS_BROWSER_INTERVALから倍になっていきます。
+// メタクエリで見つけたサービスタイプは見つけ次第問い合わせます。ctxが終了するとnilを返し、見つけたサービスはServicesで得られます
+func (b *ServiceBrowser) Browse(ctx context.Context) error {
+	if b.IP.To4() == nil {
+		return errors.New("mdns browser needs an ipv4 address")
+	}
+	if b.join != nil {
+		if err := b.join(MULTICAST_MDNS_IPv4); err != nil {
+			return err
+		}
+	}
+	if b.receive != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			b.receive(ctx)
+			close(done)
+		}()
+		// 受信ループが止まってから返す
+		defer func() {
+			cancel()
+			<-done
+		}()
+	}
+
+	queried := map[string]bool{}
+	query := func(all bool) error {
+		names := []string{}
+		for _, name := range b.queryNames() {
+			if all || !queried[strings.ToLower(name)] {
+				names = append(names, name)
+				queried[strings.ToLower(name)] = true
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		return b.send(ctx, b.queryFrame(names))
+	}
+
+	interval := MDNS_BROWSER_INTERVAL
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			if err := query(true); err != nil {
+				return err
+			}
+			timer.Reset(interval)
+			interval = min(interval*2, MDNS_BROWSER_MAX_INTERVAL)
+		case passive := <-b.passiveCh:
+			if !b.Update(passive) {
+				continue
+			}
+			// メタクエリで新しいサービスタイプが見つかった
+			if err := query(false); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Update aggregates the records of an mDNS response. A record with a TTL of 0 (goodbye) removes the service.
+// It reports whether new service types were found with the meta-query
+// mDNSの応答のレコードを集計します。TTLが0のレコード(goodbye)はサービスを削除します。
+// メタクエリで新しいサービスタイプが見つかったかを返します
+func (b *ServiceBrowser) Update(p *Passive) bool {
+	if p == nil || p.UDP == nil || p.UDP.SrcPort != PORT_MDNS || len(p.UDP.Payload) < 12 {
+		return false
+	}
+	msg := p.UDP.Payload
+	if !IsDNSResponse(binary.BigEndian.Uint16(msg[2:4])) {
+		return false
+	}
+	// 途中で壊れていても、解析できたところまでは集計する
+	records, _ := ParsedDNSResourceRecords(msg)
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.services == nil {
+		b.services = make(map[string]*MDNSService)
+		b.hosts = make(map[string][]net.IP)
+		b.types = make(map[string]string)
+	}
+
+	newTypes := false
+	for _, rr := range records {
+		if rr.Class&mdnsClassMask != DNS_QUERY_CLASS_IN {
+			continue
+		}
+		goodbye := rr.Ttl == 0
+		switch rr.Typ {
+		case DNS_QUERY_TYPE_PTR:
+			target, _, err := parseDNSName(msg, rr.dataOffset)
+			if err != nil {
+				continue
+			}
+			if strings.EqualFold(rr.Name, MDNS_SERVICES_QUERY) {
+				if _, ok := b.types[strings.ToLower(target)]; !ok && !goodbye {
+					b.types[strings.ToLower(target)] = target
+					newTypes = true
+				}
+				continue
+			}
+			if goodbye {
+				delete(b.services, strings.ToLower(target))
+				continue
+			}
+			service := b.service(target, now)
+			service.Type = rr.Name
+		case DNS_QUERY_TYPE_SRV:
+			if len(rr.Data) < 7 {
+				continue
+			}
+			target, _, err := parseDNSName(msg, rr.dataOffset+6)
+			if err != nil {
+				continue
+			}
+			if goodbye {
+				delete(b.services, strings.ToLower(rr.Name))
+				continue
+			}
+			service := b.service(rr.Name, now)
+			service.Port = binary.BigEndian.Uint16(rr.Data[4:6])
+			service.Host = target
+		case DNS_QUERY_TYPE_TXT:
+			if goodbye {
+				continue
+			}
+			b.service(rr.Name, now).TXT = parseMDNSTXT(rr.Data)
+		case DNS_QUERY_TYPE_A, DNS_QUERY_TYPE_AAAA:
+			if len(rr.Data) != net.IPv4len && len(rr.Data) != net.IPv6len {
+				continue
+			}
+			host := strings.ToLower(rr.Name)
+			addr := net.IP(slices.Clone(rr.Data))
+			addrs := slices.DeleteFunc(b.hosts[host], func(a net.IP) bool { return a.Equal(addr) })
+			if !goodbye {
+				addrs = append(addrs, addr)
+			}
+			b.hosts[host] = addrs
+		}
+	}
+	return newTypes
+}
+
+// 見つけた、または見つかり始めたサービス. タイプは PTR が無くてもインスタンス名から決まる
+func (b *ServiceBrowser) service(instance string, now time.Time) *MDNSService {
+	key := strings.ToLower(instance)
+	service, ok := b.services[key]
+	if !ok {
+		service = &MDNSService{Instance: instance}
+		// インスタンス名は "<名前>.<_サービス>.<_プロトコル>.<ドメイン>"
+		if i := strings.Index(instance, "._"); i >= 0 {
+			service.Type = instance[i+1:]
+		}
+		b.services[key] = service
+	}
+	service.LastSeen = now
+	return service
+}
+
+// Services returns the services found, sorted by type and instance, with the addresses of their hosts
+// 見つけたサービスを、ホストのアドレスとともにタイプとインスタンスの順に並べて返します
+func (b *ServiceBrowser) Services() []MDNSService {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	services := make([]MDNSService, 0, len(b.services))
+	for _, service := range b.services {
+		s := *service
+		s.Addresses = slices.Clone(b.hosts[strings.ToLower(s.Host)])
+		services = append(services, s)
+	}
+	slices.SortFunc(services, func(a, b MDNSService) int {
+		if c := strings.Compare(strings.ToLower(a.Type), strings.ToLower(b.Type)); c != 0 {
+			return c
+		}
+		return strings.Compare(strings.ToLower(a.Instance), strings.ToLower(b.Instance))
+	})
+	return services
+}
+
+// 問い合わせるサービスタイプ. 指定が無ければメタクエリと、それで見つけたタイプ
+func (b *ServiceBrowser) queryNames() []string {
+	if len(b.Types) > 0 {
+		return b.Types
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := []string{MDNS_SERVICES_QUERY}
+	for _, key := range slices.Sorted(maps.Keys(b.types)) {
+		names = append(names, b.types[key])
+	}
+	return names
+}
+
+// names の PTR を問い合わせる mDNS のクエリを 224.0.0.251:5353 へ送るフレーム. mDNS では TTL は 255 にする (RFC 6762 11章)
+func (b *ServiceBrowser) queryFrame(names []string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:6], uint16(len(names)))
+	for _, name := range names {
+		msg = appendDNSName(msg, name)
+		msg = binary.BigEndian.AppendUint16(msg, DNS_QUERY_TYPE_PTR)
+		msg = binary.BigEndian.AppendUint16(msg, DNS_QUERY_CLASS_IN)
+	}
+	dstMAC, _ := MulticastMAC(MULTICAST_MDNS_IPv4)
+	return udpIPv4Frame(b.MAC, dstMAC, b.IP, MULTICAST_MDNS_IPv4, PORT_MDNS, PORT_MDNS, 0xff, msg)
+}
+
+// 圧縮せずにドメイン名をラベルの並びにする
+func appendDNSName(msg []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0x00)
+}
+
+// TXT レコードの "key=value" の並び (RFC 6763 6章)
+func parseMDNSTXT(data []byte) map[string]string {
+	txt := map[string]string{}
+	for len(data) > 0 {
+		length := int(data[0])
+		if 1+length > len(data) {
+			break
+		}
+		entry := string(data[1 : 1+length])
+		data = data[1+length:]
+		if entry == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, "=")
+		// 同じキーは最初のものを使う
+		if _, ok := txt[strings.ToLower(key)]; !ok {
+			txt[strings.ToLower(key)] = value
+		}
+	}
+	return txt
+}
@@ -0,0 +1,184 @@
+package packemon
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mDNS のレコード. target は PTR と SRV のデータの名前で、先に出てきた名前への圧縮ポインタにする
+type mdnsTestRecord struct {
+	name   string
+	typ    uint16
+	ttl    uint32
+	target string
+	port   uint16
+	data   []byte
+}
+
+// 応答のメッセージを組み立てる. 名前は出てきた位置を覚えておき、2回目以降は圧縮ポインタにする
+func mdnsTestResponse(records ...mdnsTestRecord) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[2:4], DNS_QR_RESPONSE|0x0400) // authoritative
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(records)))
+	offsets := map[string]int{}
+	appendName := func(msg []byte, name string) []byte {
+		if offset, ok := offsets[name]; ok {
+			return binary.BigEndian.AppendUint16(msg, 0xc000|uint16(offset))
+		}
+		offsets[name] = len(msg)
+		return appendDNSName(msg, name)
+	}
+	for _, r := range records {
+		msg = appendName(msg, r.name)
+		msg = binary.BigEndian.AppendUint16(msg, r.typ)
+		msg = binary.BigEndian.AppendUint16(msg, 0x8000|DNS_QUERY_CLASS_IN) // cache-flush
+		msg = binary.BigEndian.AppendUint32(msg, r.ttl)
+		lengthAt := len(msg)
+		msg = append(msg, 0, 0)
+		switch r.typ {
+		case DNS_QUERY_TYPE_PTR:
+			msg = appendName(msg, r.target)
+		case DNS_QUERY_TYPE_SRV:
+			msg = append(msg, 0, 0, 0, 0) // priority, weight
+			msg = binary.BigEndian.AppendUint16(msg, r.port)
+			msg = appendName(msg, r.target)
+		default:
+			msg = append(msg, r.data...)
+		}
+		binary.BigEndian.PutUint16(msg[lengthAt:lengthAt+2], uint16(len(msg)-lengthAt-2))
+	}
+	return msg
+}
+
+func mdnsTestTXT(entries ...string) []byte {
+	data := []byte{}
+	for _, entry := range entries {
+		data = append(append(data, byte(len(entry))), entry...)
+	}
+	return data
+}
+
+// mDNS で応答するプリンターのモック. メタクエリにはサービスタイプを、サービスタイプのクエリにはインスタンスを答える
+type mdnsTestResponder struct {
+	t       *testing.T
+	replyCh chan *Passive
+	queries []string
+}
+
+func (r *mdnsTestResponder) send(ctx context.Context, frame []byte) error {
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		return err
+	}
+	if passive.IPv4 == nil || !net.IP(passive.IPv4.DstIP).Equal(MULTICAST_MDNS_IPv4) || passive.IPv4.TTL != 255 ||
+		passive.UDP == nil || passive.UDP.DstPort != PORT_MDNS || net.HardwareAddr(passive.EthernetFrame.DstAddr).String() != "01:00:5e:00:00:fb" {
+		r.t.Errorf("browser sent %+v, want an mDNS query to 224.0.0.251:5353", passive)
+		return nil
+	}
+	msg := passive.UDP.Payload
+	offset := 12
+	for range binary.BigEndian.Uint16(msg[4:6]) {
+		name, next, err := parseDNSName(msg, offset)
+		if err != nil {
+			return err
+		}
+		if typ := binary.BigEndian.Uint16(msg[next : next+2]); typ != DNS_QUERY_TYPE_PTR {
+			r.t.Errorf("query type = %d, want PTR", typ)
+		}
+		offset = next + 4
+		r.queries = append(r.queries, name)
+
+		switch name {
+		case MDNS_SERVICES_QUERY:
+			r.reply(mdnsTestResponse(mdnsTestRecord{name: MDNS_SERVICES_QUERY, typ: DNS_QUERY_TYPE_PTR, ttl: 4500, target: "_ipp._tcp.local"}))
+		case "_ipp._tcp.local":
+			r.reply(mdnsTestResponse(
+				mdnsTestRecord{name: "_ipp._tcp.local", typ: DNS_QUERY_TYPE_PTR, ttl: 4500, target: "Office Printer._ipp._tcp.local"},
+				mdnsTestRecord{name: "Office Printer._ipp._tcp.local", typ: DNS_QUERY_TYPE_SRV, ttl: 120, target: "printer.local", port: 631},
+				mdnsTestRecord{name: "Office Printer._ipp._tcp.local", typ: DNS_QUERY_TYPE_TXT, ttl: 4500, data: mdnsTestTXT("txtvers=1", "ty=Office Laser", "Color=T", "duplex")},
+				mdnsTestRecord{name: "printer.local", typ: DNS_QUERY_TYPE_A, ttl: 120, data: []byte{192, 168, 10, 20}},
+			))
+		}
+	}
+	return nil
+}
+
+func (r *mdnsTestResponder) reply(msg []byte) {
+	frame := udpIPv4Frame(net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}, net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb},
+		net.IPv4(192, 168, 10, 20), MULTICAST_MDNS_IPv4, PORT_MDNS, PORT_MDNS, 0xff, msg)
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.replyCh <- passive
+}
+
+// TestServiceBrowser tests that the browser finds the service types with the meta-query, queries them and
+// aggregates the announced service from its PTR, SRV, TXT and A records
+// ブラウザがメタクエリでサービスタイプを見つけて問い合わせ、告知されたサービスをPTR、SRV、TXT、Aレコードから集計することをテストします
+func TestServiceBrowser(t *testing.T) {
+	responder := &mdnsTestResponder{t: t, replyCh: make(chan *Passive, 10)}
+	joined := []net.IP{}
+	browser := &ServiceBrowser{
+		MAC:       net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b},
+		IP:        net.IPv4(192, 168, 10, 2),
+		send:      responder.send,
+		passiveCh: responder.replyCh,
+		join: func(group net.IP) error {
+			joined = append(joined, group)
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- browser.Browse(ctx)
+	}()
+	for len(browser.Services()) == 0 || len(browser.Services()[0].Addresses) == 0 {
+		select {
+		case err := <-done:
+			t.Fatalf("Browse() = %v before finding the service", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(joined) != 1 || !joined[0].Equal(MULTICAST_MDNS_IPv4) {
+		t.Errorf("joined %v, want 224.0.0.251", joined)
+	}
+	// 新しいタイプは次のクエリを待たずに問い合わせる
+	if got := strings.Join(responder.queries, ","); got != MDNS_SERVICES_QUERY+",_ipp._tcp.local" {
+		t.Errorf("queries = %s", got)
+	}
+
+	services := browser.Services()
+	if len(services) != 1 {
+		t.Fatalf("Services() = %+v, want 1", services)
+	}
+	got := services[0]
+	if got.Name() != "Office Printer" || got.Type != "_ipp._tcp.local" || got.Host != "printer.local" || got.Port != 631 {
+		t.Errorf("service = %+v", got)
+	}
+	if len(got.Addresses) != 1 || !got.Addresses[0].Equal(net.IPv4(192, 168, 10, 20)) {
+		t.Errorf("Addresses = %v, want 192.168.10.20", got.Addresses)
+	}
+	if got.TXT["ty"] != "Office Laser" || got.TXT["color"] != "T" || got.TXT["duplex"] != "" || len(got.TXT) != 4 {
+		t.Errorf("TXT = %v", got.TXT)
+	}
+
+	// goodbye (TTL 0) でサービスが消える
+	goodbye := mdnsTestResponse(mdnsTestRecord{name: "_ipp._tcp.local", typ: DNS_QUERY_TYPE_PTR, ttl: 0, target: "Office Printer._ipp._tcp.local"})
+	browser.Update(&Passive{UDP: &UDPPacket{SrcPort: PORT_MDNS, DstPort: PORT_MDNS, Payload: goodbye}})
+	if services := browser.Services(); len(services) != 0 {
+		t.Errorf("Services() after goodbye = %+v, want none", services)
+	}
+}
@@ -0,0 +1,291 @@
+package packemon
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MULTI_INTERFACE_REORDER_WINDOW is how long MultiInterface holds a packet back while waiting for earlier packets from idle interfaces
+// MultiInterfaceが、受信の少ないインターフェースからのより早いパケットを待つために保留する時間です
+const MULTI_INTERFACE_REORDER_WINDOW = 50 * time.Millisecond
+
+// MultiInterface captures from several interfaces at once and merges their packets into one PassiveCh in capture time order.
+// Each Passive has the name of its interface in Passive.Interface.
+// 複数のインターフェースから同時にキャプチャし、パケットをキャプチャ時刻順に1つのPassiveChにまとめます
+type MultiInterface struct {
+	PassiveCh chan *Passive
+
+	// Interfaces are the opened interfaces, e.g. to set Decode As or the capture filter on each
+	// 開いたインターフェース。それぞれにDecode Asやキャプチャフィルターを設定する場合などに使用します
+	Interfaces []*NetworkInterface
+
+	sources []multiInterfaceSource
+
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+// 1つのインターフェース. テストではモックに差し替える
+type multiInterfaceSource struct {
+	name      string
+	passiveCh <-chan *Passive
+	// receive runs the receive loop until ctx is done
+	receive func(ctx context.Context) error
+	close   func()
+}
+
+// NewMultiInterface opens the named interfaces. An interface that fails to open is recorded in Errors and the others are still used.
+// It returns an error only if none of them could be opened.
+// 指定したインターフェースを開きます。開けなかったインターフェースはErrorsに記録し、残りのインターフェースを使います。
+// 1つも開けなかった場合のみエラーを返します
+func NewMultiInterface(nwInterfaces ...string) (*MultiInterface, error) {
+	if len(nwInterfaces) == 0 {
+		return nil, errors.New("no interface to capture from")
+	}
+
+	interfaces := make([]*NetworkInterface, 0, len(nwInterfaces))
+	sources := make([]multiInterfaceSource, 0, len(nwInterfaces))
+	errs := map[string]error{}
+	for _, name := range nwInterfaces {
+		nwif, err := NewNetworkInterface(name)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		interfaces = append(interfaces, nwif)
+		sources = append(sources, multiInterfaceSource{
+			name:      name,
+			passiveCh: nwif.PassiveCh,
+			receive: func(ctx context.Context) error {
+				nwif.ReceiveEthernetFrame(ctx)
+				return nil
+			},
+			close: nwif.Close,
+		})
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no interface could be opened: %w", errors.Join(joinedErrs(nwInterfaces, errs)...))
+	}
+
+	m := newMultiInterface(sources)
+	m.Interfaces = interfaces
+	m.errs = errs
+	return m, nil
+}
+
+// 指定した順にインターフェース名を付けて並べる
+func joinedErrs(names []string, errs map[string]error) []error {
+	joined := make([]error, 0, len(errs))
+	for _, name := range names {
+		if err, ok := errs[name]; ok {
+			joined = append(joined, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return joined
+}
+
+func newMultiInterface(sources []multiInterfaceSource) *MultiInterface {
+	return &MultiInterface{
+		PassiveCh: make(chan *Passive, 100*len(sources)),
+		sources:   sources,
+		errs:      map[string]error{},
+	}
+}
+
+// ReceiveEthernetFrame receives on every interface until ctx is done, like NetworkInterface.ReceiveEthernetFrame.
+// An interface whose receive loop fails or stops early is recorded in Errors, and the others keep receiving.
+// ctxが終了するまで全てのインターフェースで受信します。受信ループが失敗したインターフェースはErrorsに記録し、他のインターフェースは受信を続けます
+func (m *MultiInterface) ReceiveEthernetFrame(ctx context.Context) {
+	events := make(chan multiInterfaceEvent, cap(m.PassiveCh))
+	for i, source := range m.sources {
+		go source.forward(ctx, i, events)
+	}
+
+	ticker := time.NewTicker(MULTI_INTERFACE_REORDER_WINDOW / 2)
+	defer ticker.Stop()
+
+	merger := newPassiveMerger(len(m.sources))
+	for running := len(m.sources); running > 0; {
+		select {
+		case event := <-events:
+			if event.passive == nil {
+				running--
+				merger.stop(event.source)
+				// ctx の終了以外で止まった場合はエラーとして記録する
+				if event.err == nil && ctx.Err() == nil {
+					event.err = errors.New("receive loop stopped")
+				}
+				if event.err != nil {
+					m.setErr(m.sources[event.source].name, event.err)
+				}
+			} else {
+				event.passive.Interface = m.sources[event.source].name
+				merger.push(event.source, event.passive, time.Now())
+			}
+		case <-ticker.C:
+		}
+		merger.pop(time.Now(), m.emit)
+	}
+	// 全て止まったので残りは順に流すだけ
+	merger.flush(m.emit)
+}
+
+func (m *MultiInterface) emit(passive *Passive) {
+	select {
+	case m.PassiveCh <- passive:
+	default:
+		// Channel is full, discard packet
+		logChannelDrop("MultiInterface.PassiveCh", cap(m.PassiveCh))
+	}
+}
+
+func (m *MultiInterface) setErr(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs[name] = err
+}
+
+// Errors returns the interfaces that failed to open or whose receive loop failed, keyed by interface name
+// 開けなかった、または受信ループが失敗したインターフェースを、インターフェース名をキーにして返します
+func (m *MultiInterface) Errors() map[string]error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	errs := make(map[string]error, len(m.errs))
+	for name, err := range m.errs {
+		errs[name] = err
+	}
+	return errs
+}
+
+// Close closes every interface
+// 全てのインターフェースを閉じます
+func (m *MultiInterface) Close() {
+	for _, source := range m.sources {
+		if source.close != nil {
+			source.close()
+		}
+	}
+}
+
+type multiInterfaceEvent struct {
+	source  int
+	passive *Passive // nil は受信ループの終了
+	err     error
+}
+
+// 受信ループを動かし、受信したパケットを events に流す. 最後に終了を知らせる
+func (s multiInterfaceSource) forward(ctx context.Context, index int, events chan<- multiInterfaceEvent) {
+	stopped := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				stopped <- fmt.Errorf("panic in receive loop: %v", e)
+			}
+		}()
+		stopped <- s.receive(ctx)
+	}()
+
+	for {
+		select {
+		case passive := <-s.passiveCh:
+			events <- multiInterfaceEvent{source: index, passive: passive}
+		case err := <-stopped:
+			// 止まるまでに受信した分を先に流す
+			for {
+				select {
+				case passive := <-s.passiveCh:
+					events <- multiInterfaceEvent{source: index, passive: passive}
+				default:
+					events <- multiInterfaceEvent{source: index, err: err}
+					return
+				}
+			}
+		}
+	}
+}
+
+// passiveMerger merges per-interface packet streams by capture time.
+// A packet is released once every running interface has a later packet queued, or after the reorder window.
+// インターフェースごとのパケットをキャプチャ時刻順にまとめます。
+// 動いている全てのインターフェースにより後のパケットが揃うか、並べ替えの待ち時間が過ぎたら送り出します
+type passiveMerger struct {
+	pending passiveHeap
+	queued  []int // インターフェースごとの保留数
+	stopped []bool
+}
+
+func newPassiveMerger(sources int) *passiveMerger {
+	return &passiveMerger{
+		queued:  make([]int, sources),
+		stopped: make([]bool, sources),
+	}
+}
+
+func (m *passiveMerger) push(source int, passive *Passive, now time.Time) {
+	heap.Push(&m.pending, pendingPassive{passive: passive, source: source, arrived: now})
+	m.queued[source]++
+}
+
+func (m *passiveMerger) stop(source int) {
+	m.stopped[source] = true
+}
+
+func (m *passiveMerger) pop(now time.Time, emit func(*Passive)) {
+	for m.pending.Len() > 0 {
+		head := m.pending[0]
+		if !m.allQueued() && now.Sub(head.arrived) < MULTI_INTERFACE_REORDER_WINDOW {
+			return
+		}
+		heap.Pop(&m.pending)
+		m.queued[head.source]--
+		emit(head.passive)
+	}
+}
+
+func (m *passiveMerger) flush(emit func(*Passive)) {
+	for m.pending.Len() > 0 {
+		head := heap.Pop(&m.pending).(pendingPassive)
+		m.queued[head.source]--
+		emit(head.passive)
+	}
+}
+
+// 各インターフェースのパケットは時刻順に届くので、全て揃っていれば先頭より早いパケットはもう来ない
+func (m *passiveMerger) allQueued() bool {
+	for source, queued := range m.queued {
+		if !m.stopped[source] && queued == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type pendingPassive struct {
+	passive *Passive
+	source  int
+	arrived time.Time
+}
+
+type passiveHeap []pendingPassive
+
+func (h passiveHeap) Len() int { return len(h) }
+func (h passiveHeap) Less(i, j int) bool {
+	if !h[i].passive.Timestamp.Equal(h[j].passive.Timestamp) {
+		return h[i].passive.Timestamp.Before(h[j].passive.Timestamp)
+	}
+	// 同時刻なら届いた順
+	return h[i].arrived.Before(h[j].arrived)
+}
+func (h passiveHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *passiveHeap) Push(x any)   { *h = append(*h, x.(pendingPassive)) }
+func (h *passiveHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,97 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 受信済みのパケットを持ち、receive を返すまで待つモックのインターフェース
+func multiInterfaceTestSource(t *testing.T, name string, receiveErr error, closed *[]string, timestamps ...time.Time) multiInterfaceSource {
+	t.Helper()
+	passiveCh := make(chan *Passive, len(timestamps))
+	for _, timestamp := range timestamps {
+		passive, err := DecodeFrame(frameReaderTestARP())
+		if err != nil {
+			t.Fatal(err)
+		}
+		passive.Timestamp = timestamp
+		passiveCh <- passive
+	}
+
+	return multiInterfaceSource{
+		name:      name,
+		passiveCh: passiveCh,
+		receive: func(ctx context.Context) error {
+			if receiveErr != nil {
+				return receiveErr
+			}
+			<-ctx.Done()
+			return nil
+		},
+		close: func() { *closed = append(*closed, name) },
+	}
+}
+
+// TestMultiInterface tests that packets from two interfaces are merged in capture time order,
+// and that an interface whose receive loop fails does not stop the other
+// 2つのインターフェースのパケットがキャプチャ時刻順にまとめられ、受信ループが失敗したインターフェースが他方を止めないことをテストします
+func TestMultiInterface(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	errRecv := errors.New("recvfrom: network is down")
+
+	var closed []string
+	m := newMultiInterface([]multiInterfaceSource{
+		multiInterfaceTestSource(t, "lo0", nil, &closed, at(1), at(3), at(5)),
+		multiInterfaceTestSource(t, "lo1", errRecv, &closed, at(2), at(4)),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.ReceiveEthernetFrame(ctx)
+		close(done)
+	}()
+
+	want := []struct {
+		ms   int
+		intf string
+	}{
+		{1, "lo0"}, {2, "lo1"}, {3, "lo0"}, {4, "lo1"}, {5, "lo0"},
+	}
+	for i, w := range want {
+		select {
+		case passive := <-m.PassiveCh:
+			if !passive.Timestamp.Equal(at(w.ms)) || passive.Interface != w.intf {
+				t.Errorf("packet %d = %s on %q, want %s on %q", i, passive.Timestamp.Format(time.StampMilli), passive.Interface, at(w.ms).Format(time.StampMilli), w.intf)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("received %d packets, want %d", i, len(want))
+		}
+	}
+
+	// lo1 が止まっても lo0 は受信を続けている
+	select {
+	case <-done:
+		t.Fatal("ReceiveEthernetFrame returned before ctx was done")
+	default:
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ReceiveEthernetFrame did not return after ctx was done")
+	}
+
+	errs := m.Errors()
+	if len(errs) != 1 || !errors.Is(errs["lo1"], errRecv) {
+		t.Errorf("Errors() = %v, want only lo1: %v", errs, errRecv)
+	}
+
+	m.Close()
+	if len(closed) != 2 {
+		t.Errorf("Close() closed %v, want lo0 and lo1", closed)
+	}
+}
@@ -0,0 +1,224 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// NEIGHBOR_TABLE_EXPIRY is how long a mapping is kept after it was last seen
+	// 最後に観測してから対応付けを保持する時間です
+	NEIGHBOR_TABLE_EXPIRY = 5 * time.Minute
+
+	// NEIGHBOR_TABLE_MAX_CONFLICTS is the number of recent conflicts kept
+	// 保持する直近の競合の数です
+	NEIGHBOR_TABLE_MAX_CONFLICTS = 64
+)
+
+// NeighborEntry is an IP to MAC address mapping learned from ARP or Neighbor Discovery
+// ARPまたは近隣探索から得たIPアドレスとMACアドレスの対応です
+type NeighborEntry struct {
+	IP  net.IP
+	MAC net.HardwareAddr
+	// Protocol is "ARP" or "NDP"
+	// "ARP"または"NDP"
+	Protocol string
+	LastSeen time.Time
+
+	// Conflicts counts how many times another MAC address claimed IP
+	// 他のMACアドレスがIPを名乗った回数
+	Conflicts int
+
+	// Static is set for a mapping fixed with Pin, which never expires and keeps its MAC address when another one claims IP
+	// Pinで固定した対応の場合に設定されます。期限切れにならず、他のMACアドレスがIPを名乗ってもMACアドレスは変わりません
+	Static bool
+}
+
+// NeighborConflict is an IP address claimed by a MAC address other than the known one, e.g. ARP spoofing or a duplicate address
+// 既知のものと異なるMACアドレスがIPアドレスを名乗ったことを表します。ARPスプーフィングやアドレスの重複などで起こります
+type NeighborConflict struct {
+	IP     net.IP
+	OldMAC net.HardwareAddr
+	NewMAC net.HardwareAddr
+	Time   time.Time
+}
+
+// NeighborTable builds a live IP to MAC address table from ARP packets and Neighbor Solicitations / Advertisements
+// ARPパケットと近隣要請/近隣広告から、IPアドレスとMACアドレスの対応表を作ります
+type NeighborTable struct {
+	mu        sync.Mutex
+	entries   map[string]*NeighborEntry
+	conflicts []NeighborConflict
+	// now は観測した最新の時刻。キャプチャファイルの再生でも期限切れを判定できるよう、現在時刻ではなくこれを使う
+	now time.Time
+}
+
+// NewNeighborTable creates an empty neighbor table
+// 空の近隣テーブルを作成します
+func NewNeighborTable() *NeighborTable {
+	return &NeighborTable{
+		entries: make(map[string]*NeighborEntry),
+	}
+}
+
+// Update learns the mapping announced by a packet, and returns the conflict it caused or nil.
+// Packets other than ARP, Neighbor Solicitations and Advertisements are ignored.
+// パケットが通知する対応を記録し、それにより起きた競合を返します(無ければnil)。ARP、近隣要請、近隣広告以外のパケットは無視します
+func (t *NeighborTable) Update(p *Passive) *NeighborConflict {
+	if p == nil {
+		return nil
+	}
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	switch {
+	case p.ARP != nil:
+		arp := p.ARP
+		// 送信元IPが0.0.0.0のARP Probeは対応を表さない
+		if arp.HardwareSize != 6 || arp.ProtocolSize != 4 || net.IP(arp.SenderIP).IsUnspecified() {
+			return nil
+		}
+		return t.learn(net.IP(arp.SenderIP), net.HardwareAddr(arp.SenderMAC), "ARP", now)
+
+	case p.ICMPv6 != nil && p.IPv6 != nil:
+		icmpv6 := p.ICMPv6
+		if len(icmpv6.Payload) < 20 {
+			return nil
+		}
+		// Reserved(またはフラグ) 4 バイト、Target Address 16 バイトの後にオプション
+		target := net.IP(icmpv6.Payload[4:20])
+		switch icmpv6.Type {
+		case ICMPv6_TYPE_NEIGHBOR_ADVERTISEMENT:
+			if mac := ndpLinkLayerAddress(icmpv6.Payload[20:], NDP_OPTION_TARGET_LINK_LAYER_ADDRESS); mac != nil {
+				return t.learn(target, mac, "NDP", now)
+			}
+		case ICMPv6_TYPE_NEIGHBOR_SOLICITATION:
+			// 重複アドレス検出(送信元 ::)は対応を表さない
+			src := net.IP(p.IPv6.SrcIP)
+			if mac := ndpLinkLayerAddress(icmpv6.Payload[20:], NDP_OPTION_SOURCE_LINK_LAYER_ADDRESS); mac != nil && !src.IsUnspecified() {
+				return t.learn(src, mac, "NDP", now)
+			}
+		}
+	}
+	return nil
+}
+
+// Pin fixes the mapping of ip to mac, like a static ARP entry: it never expires, and a claim of ip by another MAC address
+// is reported as a conflict without replacing mac
+// 静的なARPエントリのように、ipとmacの対応を固定します。期限切れにならず、他のMACアドレスがipを名乗っても
+// macを置き換えずに競合として報告します
+func (t *NeighborTable) Pin(ip net.IP, mac net.HardwareAddr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries[ip.String()] = &NeighborEntry{
+		IP:       append(net.IP(nil), ip...),
+		MAC:      append(net.HardwareAddr(nil), mac...),
+		Protocol: "static",
+		Static:   true,
+	}
+}
+
+// ndpLinkLayerAddress returns the MAC address of the first Neighbor Discovery option of optionType, or nil
+// optionTypeの最初の近隣探索オプションのMACアドレスを返します。無い場合はnil
+func ndpLinkLayerAddress(options []byte, optionType uint8) net.HardwareAddr {
+	// 不正なオプションより前に見つかればそれを使う
+	opts, _ := parseNDPOptions(options)
+	for _, opt := range opts {
+		if opt.Type == optionType {
+			return net.HardwareAddr(opt.Data[:6])
+		}
+	}
+	return nil
+}
+
+func (t *NeighborTable) learn(ip net.IP, mac net.HardwareAddr, protocol string, now time.Time) *NeighborConflict {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.After(t.now) {
+		t.now = now
+	}
+	t.expire()
+
+	key := ip.String()
+	entry, ok := t.entries[key]
+	if !ok {
+		// 受信バッファは再利用されることがあるため、保持するアドレスはコピーする
+		t.entries[key] = &NeighborEntry{
+			IP:       append(net.IP(nil), ip...),
+			MAC:      append(net.HardwareAddr(nil), mac...),
+			Protocol: protocol,
+			LastSeen: now,
+		}
+		return nil
+	}
+
+	var conflict *NeighborConflict
+	if !bytes.Equal(entry.MAC, mac) {
+		entry.Conflicts++
+		conflict = &NeighborConflict{
+			IP:     entry.IP,
+			OldMAC: entry.MAC,
+			NewMAC: append(net.HardwareAddr(nil), mac...),
+			Time:   now,
+		}
+		t.conflicts = append(t.conflicts, *conflict)
+		if len(t.conflicts) > NEIGHBOR_TABLE_MAX_CONFLICTS {
+			t.conflicts = t.conflicts[len(t.conflicts)-NEIGHBOR_TABLE_MAX_CONFLICTS:]
+		}
+		logNeighborConflict(*conflict)
+		// 固定した対応は他の MAC アドレスの主張で変えない
+		if entry.Static {
+			return conflict
+		}
+		entry.MAC = append(net.HardwareAddr(nil), mac...)
+	}
+	if !entry.Static {
+		entry.Protocol = protocol
+	}
+	if now.After(entry.LastSeen) {
+		entry.LastSeen = now
+	}
+	return conflict
+}
+
+func (t *NeighborTable) expire() {
+	for key, entry := range t.entries {
+		if !entry.Static && t.now.Sub(entry.LastSeen) > NEIGHBOR_TABLE_EXPIRY {
+			delete(t.entries, key)
+		}
+	}
+}
+
+// Snapshot returns the mappings sorted by IP address. Mappings not seen for NEIGHBOR_TABLE_EXPIRY before the latest packet are expired,
+// except those fixed with Pin
+// IPアドレス順の対応を返します。最新のパケットからNEIGHBOR_TABLE_EXPIRY以上観測されていない対応は、Pinで固定したものを除き期限切れとして削除します
+func (t *NeighborTable) Snapshot() []NeighborEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire()
+	entries := make([]NeighborEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].IP.To16(), entries[j].IP.To16()) < 0
+	})
+	return entries
+}
+
+// Conflicts returns the recent conflicts, oldest first
+// 直近の競合を古い順に返します
+func (t *NeighborTable) Conflicts() []NeighborConflict {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]NeighborConflict(nil), t.conflicts...)
+}
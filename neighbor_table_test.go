@@ -0,0 +1,121 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// sender の MAC と IP を通知する ARP Reply のフレーム
+func neighborTableTestARP(mac []byte, ip []byte) []byte {
+	arp := []byte{0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, ARP_OPERATION_CODE_REPLY}
+	arp = append(append(arp, mac...), ip...)
+	arp = append(arp, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 192, 168, 10, 2)
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}
+	frame = append(append(frame, mac...), 0x08, 0x06)
+	return append(frame, arp...)
+}
+
+// target の MAC を Target Link-Layer Address オプションで通知する近隣広告のフレーム
+func neighborTableTestNA(mac []byte, target []byte) []byte {
+	icmpv6 := []byte{ICMPv6_TYPE_NEIGHBOR_ADVERTISEMENT, 0x00, 0x00, 0x00, 0x60, 0x00, 0x00, 0x00}
+	icmpv6 = append(icmpv6, target...)
+	icmpv6 = append(icmpv6, NDPOption{Type: NDP_OPTION_TARGET_LINK_LAYER_ADDRESS, Data: mac}.Bytes()...)
+
+	ipv6 := []byte{0x60, 0x00, 0x00, 0x00, 0x00, byte(len(icmpv6)), IPv6_NEXT_HEADER_ICMPv6, 0xff}
+	ipv6 = append(append(ipv6, target...), 0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01)
+	frame := []byte{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+	frame = append(append(frame, mac...), 0x86, 0xdd)
+	return append(append(frame, ipv6...), icmpv6...)
+}
+
+func neighborTableTestUpdate(t *testing.T, table *NeighborTable, frame []byte, timestamp time.Time) {
+	t.Helper()
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passive.Timestamp = timestamp
+	table.Update(passive)
+}
+
+// TestNeighborTable tests that ARP replies and Neighbor Advertisements build the table, conflicts are detected and stale entries expire
+// ARP Replyと近隣広告から表が作られ、競合が検出され、古い対応が期限切れになることをテストします
+func TestNeighborTable(t *testing.T) {
+	router := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b}
+	spoofer := []byte{0x00, 0x15, 0x5d, 0xde, 0xad, 0x01}
+	linkLocal := []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0x02, 0x15, 0x5d, 0xff, 0xfe, 0xfb, 0xbf, 0x3b}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	table := NewNeighborTable()
+	neighborTableTestUpdate(t, table, neighborTableTestARP(router, []byte{192, 168, 10, 1}), now)
+	neighborTableTestUpdate(t, table, neighborTableTestNA(router, linkLocal), now.Add(time.Second))
+	neighborTableTestUpdate(t, table, parseDepthTestFrame(), now.Add(2*time.Second))
+
+	entries := table.Snapshot()
+	if len(entries) != 2 {
+		t.Fatalf("Snapshot() = %+v, want 2 entries", entries)
+	}
+	if entries[0].IP.String() != "192.168.10.1" || entries[0].MAC.String() != "00:15:5d:fb:bf:3b" || entries[0].Protocol != "ARP" || !entries[0].LastSeen.Equal(now) {
+		t.Errorf("entries[0] = %+v, want 192.168.10.1 at 00:15:5d:fb:bf:3b from ARP", entries[0])
+	}
+	if entries[1].IP.String() != "fe80::215:5dff:fefb:bf3b" || entries[1].MAC.String() != "00:15:5d:fb:bf:3b" || entries[1].Protocol != "NDP" {
+		t.Errorf("entries[1] = %+v, want fe80::215:5dff:fefb:bf3b at 00:15:5d:fb:bf:3b from NDP", entries[1])
+	}
+
+	// 同じ IP を別の MAC が名乗る
+	neighborTableTestUpdate(t, table, neighborTableTestARP(spoofer, []byte{192, 168, 10, 1}), now.Add(3*time.Second))
+	conflicts := table.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].OldMAC.String() != "00:15:5d:fb:bf:3b" || conflicts[0].NewMAC.String() != "00:15:5d:de:ad:01" {
+		t.Errorf("Conflicts() = %+v, want 192.168.10.1 moved to 00:15:5d:de:ad:01", conflicts)
+	}
+	if entry := table.Snapshot()[0]; entry.MAC.String() != "00:15:5d:de:ad:01" || entry.Conflicts != 1 {
+		t.Errorf("entry after the conflict = %+v, want the new MAC and 1 conflict", entry)
+	}
+
+	// IPv6 の対応だけ更新し続け、IPv4 の対応を期限切れにする
+	neighborTableTestUpdate(t, table, neighborTableTestNA(router, linkLocal), now.Add(NEIGHBOR_TABLE_EXPIRY+time.Minute))
+	if entries := table.Snapshot(); len(entries) != 1 || entries[0].Protocol != "NDP" {
+		t.Errorf("Snapshot() after expiry = %+v, want only the NDP entry", entries)
+	}
+}
+
+// TestNeighborTablePin tests that a pinned mapping reports every claim by another MAC address as a conflict,
+// keeps its MAC address and does not expire
+// 固定した対応は、他のMACアドレスの主張を毎回競合として報告し、MACアドレスが変わらず、期限切れにならないことをテストします
+func TestNeighborTablePin(t *testing.T) {
+	legitimate := net.HardwareAddr{0x00, 0x15, 0x5d, 0x00, 0x00, 0x01}
+	spoofer := net.HardwareAddr{0x02, 0x00, 0x00, 0xba, 0xd0, 0x01}
+	gateway := net.IPv4(192, 168, 0, 1).To4()
+
+	table := NewNeighborTable()
+	table.Pin(gateway, legitimate)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	update := func(frame []byte, at time.Duration) *NeighborConflict {
+		t.Helper()
+		passive, err := DecodeFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		passive.Timestamp = start.Add(at)
+		return table.Update(passive)
+	}
+
+	if conflict := update(gratuitousARPFrame(legitimate, gateway), 0); conflict != nil {
+		t.Errorf("Update() of the pinned mapping = %+v, want no conflict", conflict)
+	}
+	for i := range 2 {
+		conflict := update(gratuitousARPFrame(spoofer, gateway), time.Duration(i+1)*time.Second)
+		if conflict == nil || !bytes.Equal(conflict.OldMAC, legitimate) || !bytes.Equal(conflict.NewMAC, spoofer) {
+			t.Errorf("Update() of claim %d = %+v, want a conflict with the pinned mapping", i+1, conflict)
+		}
+	}
+
+	// 他の対応の時刻が進んでも期限切れにならない
+	update(gratuitousARPFrame(spoofer, net.IPv4(192, 168, 0, 50).To4()), 2*NEIGHBOR_TABLE_EXPIRY)
+	entries := table.Snapshot()
+	if len(entries) != 2 || !entries[0].IP.Equal(gateway) || !bytes.Equal(entries[0].MAC, legitimate) || !entries[0].Static || entries[0].Conflicts != 2 {
+		t.Errorf("Snapshot() = %+v, want the gateway pinned at %s with 2 conflicts", entries, legitimate)
+	}
+}
@@ -4,26 +4,46 @@ package packemon
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"net"
+	"time"
 )
 
-// NewNetworkInterface creates a new NetworkInterface for the specified interface
+// DEFAULT_READ_TIMEOUT is how long the receive loop waits for a frame before it checks ctx again
+// 受信ループがctxを確認し直すまでにフレームを待つ時間のデフォルト値です
+const DEFAULT_READ_TIMEOUT = 100 * time.Millisecond
+
+// NewNetworkInterface creates a new NetworkInterface for the specified interface.
+// Without the permission to capture it returns an error wrapping ErrCapturePermission; NewOfflineNetworkInterface works without it.
 // The implementation is platform-specific and is defined in:
 // - networkinterface_linux.go for Linux
 // - networkinterface_darwin.go for macOS
 func NewNetworkInterface(nwInterface string) (*NetworkInterface, error) {
 	// Each platform implements this function differently
 	// The actual implementation is in the platform-specific files
-	return newNetworkInterfacePlatform(nwInterface)
+	nwif, err := newNetworkInterfacePlatform(nwInterface)
+	if err != nil {
+		return nil, capturePermissionError(nwInterface, err)
+	}
+	return nwif, nil
 }
 
 // SendEthernetFrame sends an Ethernet frame
 func (nwif *NetworkInterface) SendEthernetFrame(ctx context.Context, data []byte) error {
+	if nwif.offline {
+		return ErrOffline
+	}
 	return nwif.sendEthernetFramePlatform(ctx, data)
 }
 
 // ReceiveEthernetFrame receives Ethernet frames
 func (nwif *NetworkInterface) ReceiveEthernetFrame(ctx context.Context) {
+	if nwif.offline {
+		// オフラインではフレームが届かないので、ctx が終了するまで待つだけ
+		<-ctx.Done()
+		return
+	}
 	nwif.receiveEthernetFramePlatform(ctx)
 }
 
@@ -32,7 +52,143 @@ func (nwif *NetworkInterface) GetNetworkInfo() (macAddr net.HardwareAddr, ipv4Ad
 	return nwif.getNetworkInfoPlatform()
 }
 
+// SetReadTimeout sets how long the receive loop blocks waiting for a frame, so that it wakes on a quiet interface to notice ctx cancellation.
+// On Linux this is SO_RCVTIMEO on the socket. On macOS it is the pcap timeout, which can only be changed while not receiving.
+// The default is DEFAULT_READ_TIMEOUT.
+// 受信ループがフレームを待つ最大時間を設定します。通信の無いインターフェースでもctxのキャンセルに気付けるよう定期的に戻ります。
+// LinuxではソケットのSO_RCVTIMEO、macOSではpcapのタイムアウトで、macOSでは受信中は変更できません
+func (nwif *NetworkInterface) SetReadTimeout(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("read timeout must be positive")
+	}
+	if !nwif.offline {
+		if err := nwif.setReadTimeoutPlatform(d); err != nil {
+			return err
+		}
+	}
+	nwif.readTimeout.Store(int64(d))
+	return nil
+}
+
+// ReadTimeout returns the current read timeout
+// 現在の読み込みタイムアウトを返します
+func (nwif *NetworkInterface) ReadTimeout() time.Duration {
+	if d := nwif.readTimeout.Load(); d > 0 {
+		return time.Duration(d)
+	}
+	return DEFAULT_READ_TIMEOUT
+}
+
+// DecodeAs makes this capture decode traffic on port as proto ("http", "tls", "dns" or "rtp"), overriding the default port map.
+// An empty proto removes the override.
+// このキャプチャで指定ポートの通信をprotoとして解析します。デフォルトのポート判定より優先されます
+func (nwif *NetworkInterface) DecodeAs(port uint16, proto string) error {
+	return nwif.decodeAs.Set(port, proto)
+}
+
+// DecodeAsOverrides returns the current Decode As overrides
+// 現在のDecode Asの設定を返します
+func (nwif *NetworkInterface) DecodeAsOverrides() map[uint16]string {
+	return nwif.decodeAs.Overrides()
+}
+
+// CaptureFilter returns the MAC/IP allowlist and denylist applied to received frames before they are decoded.
+// The lists can be updated while receiving.
+// 受信したフレームにデコード前に適用するMAC/IPアドレスのallowlistとdenylistを返します。受信中でも更新できます
+func (nwif *NetworkInterface) CaptureFilter() *CaptureFilter {
+	return &nwif.captureFilter
+}
+
+// SetParseDepth limits how far received frames are decoded, e.g. PARSE_DEPTH_NETWORK for link/IP-level statistics at high rates.
+// The default is PARSE_DEPTH_FULL.
+// 受信したフレームをどのレイヤまで解析するかを設定します。デフォルトはPARSE_DEPTH_FULLです
+func (nwif *NetworkInterface) SetParseDepth(depth ParseDepth) {
+	nwif.parseDepth.Store(int32(depth))
+}
+
+// ParseDepth returns the current parse depth
+// 現在の解析の深さを返します
+func (nwif *NetworkInterface) ParseDepth() ParseDepth {
+	return ParseDepth(nwif.parseDepth.Load())
+}
+
+// SelectSourceAddress returns the local address to be used as the source when sending to dst.
+// IPv4 destinations get the interface's IPv4 address, IPv6 destinations get an address of the same scope if possible.
+// 宛先に到達するために使用する送信元アドレスを返します
+func (nwif *NetworkInterface) SelectSourceAddress(dst net.IP) (net.IP, error) {
+	var ipv4Addr net.IP
+	if nwif.IPAddr != 0 {
+		ipv4Addr = make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ipv4Addr, nwif.IPAddr)
+	}
+	ipv6Addrs := nwif.IPv6Addrs
+	if len(ipv6Addrs) == 0 && nwif.IPv6Addr != nil {
+		ipv6Addrs = []net.IP{nwif.IPv6Addr}
+	}
+	return selectSourceAddress(dst, ipv4Addr, ipv6Addrs)
+}
+
+func selectSourceAddress(dst net.IP, ipv4Addr net.IP, ipv6Addrs []net.IP) (net.IP, error) {
+	if dst == nil {
+		return nil, errors.New("destination address is empty")
+	}
+
+	if dst4 := dst.To4(); dst4 != nil {
+		if dst4.IsLoopback() {
+			return net.IPv4(127, 0, 0, 1).To4(), nil
+		}
+		if ipv4Addr == nil {
+			return nil, errors.New("no IPv4 address found for interface")
+		}
+		return ipv4Addr, nil
+	}
+
+	if dst.IsLoopback() {
+		return net.IPv6loopback, nil
+	}
+	if len(ipv6Addrs) == 0 {
+		return nil, errors.New("no IPv6 address found for interface")
+	}
+
+	// RFC 6724 の Rule 2 (Prefer appropriate scope) を簡易的に実装
+	want := sourceScopeFor(dst)
+	for _, addr := range ipv6Addrs {
+		if ClassifyIPv6Addr(addr) == want {
+			return addr, nil
+		}
+	}
+	// グローバル宛てならユニークローカルでも届く可能性があるので次点とする
+	if want == IPv6_ADDR_TYPE_GLOBAL_UNICAST {
+		for _, addr := range ipv6Addrs {
+			if ClassifyIPv6Addr(addr) == IPv6_ADDR_TYPE_UNIQUE_LOCAL {
+				return addr, nil
+			}
+		}
+	}
+	return ipv6Addrs[0], nil
+}
+
+// 宛先に対して望ましい送信元アドレスの種別
+func sourceScopeFor(dst net.IP) IPv6AddrType {
+	switch typ := ClassifyIPv6Addr(dst); typ {
+	case IPv6_ADDR_TYPE_MULTICAST:
+		scope, _ := IPv6MulticastScopeOf(dst)
+		if scope <= IPv6_MULTICAST_SCOPE_LINK_LOCAL {
+			return IPv6_ADDR_TYPE_LINK_LOCAL
+		}
+		return IPv6_ADDR_TYPE_GLOBAL_UNICAST
+	case IPv6_ADDR_TYPE_LINK_LOCAL, IPv6_ADDR_TYPE_UNIQUE_LOCAL:
+		return typ
+	default:
+		return IPv6_ADDR_TYPE_GLOBAL_UNICAST
+	}
+}
+
 // Close cleans up resources
 func (nwif *NetworkInterface) Close() {
+	if nwif.offline {
+		return
+	}
+	nwif.leaveAllMulticast()
 	nwif.closePlatform()
 }
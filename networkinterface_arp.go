@@ -0,0 +1,30 @@
+package packemon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// AnnounceIP broadcasts a gratuitous ARP announcing that ip is at this interface's MAC address, e.g. to test failover or an IP takeover.
+// ip must be an IPv4 address, but need not be assigned to the interface.
+// ipがこのインターフェースのMACアドレスにあることをGratuitous ARPでブロードキャストします(フェイルオーバーやIPの引き継ぎのテストなど)。
+// ipはIPv4アドレスである必要がありますが、インターフェースに割り当てられている必要はありません
+func (nwif *NetworkInterface) AnnounceIP(ctx context.Context, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return errors.New("not an IPv4 address: " + ip.String())
+	}
+	mac, _, _ := nwif.GetNetworkInfo()
+	return nwif.SendEthernetFrame(ctx, gratuitousARPFrame(mac, ip4))
+}
+
+func gratuitousARPFrame(mac net.HardwareAddr, ip4 net.IP) []byte {
+	// ループバックなど MAC アドレスの無いインターフェースではゼロのまま
+	var src HardwareAddr
+	copy(src[:], mac)
+	arp := NewGratuitousARP(src, binary.BigEndian.Uint32(ip4))
+	dst := HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	return NewEthernetFrame(dst, src, ETHER_TYPE_ARP, arp.Bytes()).Bytes()
+}
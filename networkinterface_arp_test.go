@@ -0,0 +1,77 @@
+package packemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestGratuitousARPFrame tests that the announcement is a broadcast ARP request whose sender and target IP are the announced address
+// アナウンスが、送信元と宛先のIPが共にアナウンスするアドレスの、ブロードキャストのARPリクエストであることをテストします
+func TestGratuitousARPFrame(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}
+	ip := net.IPv4(192, 168, 10, 110).To4()
+
+	passive, err := DecodeFrame(gratuitousARPFrame(mac, ip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(passive.EthernetFrame.DstAddr, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) || !bytes.Equal(passive.EthernetFrame.SrcAddr, mac) {
+		t.Errorf("Ethernet Dst %x, Src %x, want broadcast from %s", passive.EthernetFrame.DstAddr, passive.EthernetFrame.SrcAddr, mac)
+	}
+	arp := passive.ARP
+	if arp == nil {
+		t.Fatal("ARP should be decoded")
+	}
+	if arp.Operation != ARP_OPERATION_CODE_REQUEST || !bytes.Equal(arp.SenderMAC, mac) || !bytes.Equal(arp.TargetMAC, make([]byte, 6)) {
+		t.Errorf("ARP = %+v, want a request from %s to an unknown hardware address", arp, mac)
+	}
+	if !bytes.Equal(arp.SenderIP, ip) || !bytes.Equal(arp.TargetIP, ip) {
+		t.Errorf("sender IP %v, target IP %v, want both %v", net.IP(arp.SenderIP), net.IP(arp.TargetIP), ip)
+	}
+}
+
+// TestAnnounceIP tests that only IPv4 addresses are announced, and that a gratuitous ARP is captured on the loopback interface after announcing
+// IPv4アドレスのみアナウンスされ、アナウンスするとループバックインターフェースでGratuitous ARPがキャプチャされることをテストします
+func TestAnnounceIP(t *testing.T) {
+	offline := NewOfflineNetworkInterface("lo")
+	if err := offline.AnnounceIP(context.Background(), net.ParseIP("2001:db8::1")); err == nil || errors.Is(err, ErrOffline) {
+		t.Errorf("an IPv6 address: err = %v, want a validation error", err)
+	}
+	if err := offline.AnnounceIP(context.Background(), net.IPv4(192, 0, 2, 1)); !errors.Is(err, ErrOffline) {
+		t.Errorf("offline: err = %v, want ErrOffline", err)
+	}
+
+	lo, err := NewNetworkInterface("lo")
+	if err != nil {
+		t.Skipf("capture on lo is not available: %v", err)
+	}
+	defer lo.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go lo.ReceiveEthernetFrame(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	ip := net.IPv4(192, 0, 2, 1).To4()
+	if err := lo.AnnounceIP(ctx, ip); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case passive := <-lo.PassiveCh:
+			if arp := passive.ARP; arp != nil && bytes.Equal(arp.SenderIP, ip) {
+				if arp.Operation != ARP_OPERATION_CODE_REQUEST || !bytes.Equal(arp.TargetIP, ip) {
+					t.Errorf("captured ARP = %+v, want a gratuitous request for %v", arp, ip)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("the announcement was not captured on lo")
+		}
+	}
+}
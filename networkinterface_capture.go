@@ -0,0 +1,93 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// CaptureN captures exactly n packets and returns them. If ctx is canceled first, the packets collected so far are returned with ctx's error.
+// Capture functions read PassiveCh themselves, so don't run them together with another receiver such as the Monitor.
+// n個のパケットをキャプチャして返します。先にctxがキャンセルされた場合は、それまでのパケットとctxのエラーを返します
+func (nwif *NetworkInterface) CaptureN(ctx context.Context, n int) ([]*Passive, error) {
+	if n <= 0 {
+		return nil, errors.New("packet count must be positive")
+	}
+
+	passives := make([]*Passive, 0, n)
+	err := nwif.Capture(ctx, n, 0, func(passive *Passive) error {
+		passives = append(passives, passive)
+		return nil
+	})
+	return passives, err
+}
+
+// CaptureFor captures packets for d and returns them. If ctx is canceled first, the packets collected so far are returned with ctx's error.
+// dの間パケットをキャプチャして返します。先にctxがキャンセルされた場合は、それまでのパケットとctxのエラーを返します
+func (nwif *NetworkInterface) CaptureFor(ctx context.Context, d time.Duration) ([]*Passive, error) {
+	if d <= 0 {
+		return nil, errors.New("capture duration must be positive")
+	}
+
+	passives := []*Passive{}
+	err := nwif.Capture(ctx, 0, d, func(passive *Passive) error {
+		passives = append(passives, passive)
+		return nil
+	})
+	return passives, err
+}
+
+// Capture runs the receive loop and streams each packet to fn until n packets have been captured or d has elapsed.
+// Zero n or d means no limit. The loop is stopped before Capture returns.
+// Reaching a limit returns nil, canceling ctx returns ctx's error and an error from fn stops the capture and is returned.
+// n個のパケットをキャプチャするかdが経過するまで、受信したパケットをfnに渡します。nやdが0の場合は制限しません
+func (nwif *NetworkInterface) Capture(ctx context.Context, n int, d time.Duration, fn func(*Passive) error) error {
+	if n < 0 || d < 0 {
+		return errors.New("capture limits must not be negative")
+	}
+
+	parent := ctx
+	var cancel context.CancelFunc
+	if d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	// 前回の受信で残っているパケットは数えない
+	for drained := false; !drained; {
+		select {
+		case <-nwif.PassiveCh:
+		default:
+			drained = true
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		nwif.ReceiveEthernetFrame(ctx)
+		close(done)
+	}()
+	// 受信ループが止まってから返す
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	captured := 0
+	for {
+		select {
+		case <-ctx.Done():
+			// 時間制限に達した場合は正常終了
+			return parent.Err()
+		case passive := <-nwif.PassiveCh:
+			if err := fn(passive); err != nil {
+				return err
+			}
+			captured++
+			if n > 0 && captured >= n {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package packemon
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestCaptureNLoopback tests that CaptureN stops after exactly N frames on the loopback interface.
+// Opening the capture needs privileges, so the test is skipped when it fails.
+// ループバックインターフェースでCaptureNがちょうどN個のフレームで止まることをテストします。権限が無い場合はスキップします
+func TestCaptureNLoopback(t *testing.T) {
+	nwif := loopbackTestInterface(t)
+	defer nwif.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go sendLoopbackUDP(ctx, t)
+
+	const n = 5
+	passives, err := nwif.CaptureN(ctx, n)
+	if err != nil {
+		t.Fatalf("CaptureN returned error: %v", err)
+	}
+	if len(passives) != n {
+		t.Fatalf("len(passives) = %d, want %d", len(passives), n)
+	}
+	for i, passive := range passives {
+		if passive.EthernetFrame == nil || passive.RawLength == 0 {
+			t.Errorf("passives[%d] is not decoded: %+v", i, passive)
+		}
+	}
+}
+
+// TestCaptureForCancel tests that CaptureFor stops at the deadline and that canceling ctx returns its error
+// CaptureForが時間制限で止まること、ctxをキャンセルするとそのエラーが返ることをテストします
+func TestCaptureForCancel(t *testing.T) {
+	nwif := loopbackTestInterface(t)
+	defer nwif.Close()
+
+	start := time.Now()
+	if _, err := nwif.CaptureFor(context.Background(), 300*time.Millisecond); err != nil {
+		t.Errorf("CaptureFor returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("CaptureFor took %s, want it to stop shortly after 300ms", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := nwif.CaptureFor(ctx, time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("CaptureFor error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if _, err := nwif.CaptureN(context.Background(), 0); err == nil {
+		t.Errorf("CaptureN(0) should fail")
+	}
+}
+
+func loopbackTestInterface(t *testing.T) *NetworkInterface {
+	t.Helper()
+	intfs, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("cannot list interfaces: %v", err)
+	}
+	for _, intf := range intfs {
+		if intf.Flags&net.FlagUp != 0 && intf.Flags&net.FlagLoopback != 0 {
+			nwif, err := NewNetworkInterface(intf.Name)
+			if err != nil {
+				t.Skipf("cannot open capture on %s: %v", intf.Name, err)
+			}
+			return nwif
+		}
+	}
+	t.Skip("no loopback interface")
+	return nil
+}
+
+// キャプチャが終わるまでループバックにUDPを送り続ける
+func sendLoopbackUDP(ctx context.Context, t *testing.T) {
+	conn, err := net.Dial("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Errorf("cannot dial loopback: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			conn.Write([]byte("packemon"))
+		}
+	}
+}
@@ -3,7 +3,6 @@ package packemon
 import (
 	"context"
 	"encoding/binary"
-	"errors"
 	"net"
 	"strings"
 )
@@ -16,11 +15,20 @@ type NetworkInterfaceer interface {
 	Close()
 }
 
-// Parse an Ethernet payload into upper-layer protocols
-func parseEthernetPayload(passive *Passive) {
+// DecodeFrame decodes a raw Ethernet frame, e.g. one imported from a hex dump or generated for a demo, into a Passive
+// 生のEthernetフレーム(16進ダンプからのインポートやデモ用に生成したものなど)をPassiveにデコードします
+func DecodeFrame(data []byte) (*Passive, error) {
+	return decodeFrame(data, len(data), nil, PARSE_DEPTH_FULL)
+}
+
+// Parse an Ethernet payload into upper-layer protocols, down to depth
+func parseEthernetPayload(passive *Passive, decodeAs *DecodeAsTable, depth ParseDepth) {
 	if passive.EthernetFrame == nil || len(passive.EthernetFrame.Payload) == 0 {
 		return
 	}
+	if !depth.includes(PARSE_DEPTH_NETWORK) {
+		return
+	}
 
 	etherType := passive.EthernetFrame.Type
 
@@ -31,19 +39,37 @@ func parseEthernetPayload(passive *Passive) {
 			// Minimum ARP packet size
 			arp := ParseARPPacket(passive.EthernetFrame.Payload)
 			passive.ARP = arp
+		} else {
+			logParseFailure("ARP", passive.EthernetFrame.Payload)
+			passive.markPartial("ARP")
 		}
+		recordDecode("ARP", passive.ARP != nil)
 
 	case 0x0800: // IPv4
 		// Parse IPv4 packet
 		if len(passive.EthernetFrame.Payload) >= 20 {
 			// Minimum IPv4 header size
 			ipv4 := ParseIPv4Packet(passive.EthernetFrame.Payload)
+			recordDecode("IPv4", ipv4 != nil)
+			if ipv4 == nil {
+				logParseFailure("IPv4", passive.EthernetFrame.Payload)
+				// 復旧モードではヘッダーを推測して上位レイヤの解析を続ける
+				ipv4 = passive.recoverIPv4Packet(passive.EthernetFrame.Payload)
+			} else if len(passive.EthernetFrame.Payload) < int(ipv4.IHL) || len(passive.EthernetFrame.Payload) < int(ipv4.TotalLength) {
+				passive.markPartial("IPv4")
+			} else if logEnabled() {
+				verifyIPv4HeaderChecksum(passive.EthernetFrame.Payload[:ipv4.IHL])
+			}
 			passive.IPv4 = ipv4
 
 			// Parse upper layer based on protocol
-			if ipv4 != nil && len(ipv4.Payload) > 0 {
-				parseIPv4Payload(passive, ipv4)
+			if ipv4 != nil && len(ipv4.Payload) > 0 && depth.includes(PARSE_DEPTH_TRANSPORT) {
+				parseIPv4Payload(passive, ipv4, decodeAs, depth)
 			}
+		} else {
+			logParseFailure("IPv4", passive.EthernetFrame.Payload)
+			passive.markPartial("IPv4")
+			recordDecode("IPv4", false)
 		}
 
 	case 0x86DD: // IPv6
@@ -52,35 +78,64 @@ func parseEthernetPayload(passive *Passive) {
 			// IPv6 header size
 			ipv6 := ParseIPv6Packet(passive.EthernetFrame.Payload)
 			passive.IPv6 = ipv6
+			recordDecode("IPv6", ipv6 != nil)
+			if ipv6 != nil && len(ipv6.Payload) < int(ipv6.PayloadLen) {
+				passive.markPartial("IPv6")
+			}
 
 			// Parse upper layer based on next header
-			if ipv6 != nil && len(ipv6.Payload) > 0 {
-				parseIPv6Payload(passive, ipv6)
+			if ipv6 != nil && len(ipv6.Payload) > 0 && depth.includes(PARSE_DEPTH_TRANSPORT) {
+				parseIPv6Payload(passive, ipv6, decodeAs, depth)
 			}
+		} else {
+			logParseFailure("IPv6", passive.EthernetFrame.Payload)
+			passive.markPartial("IPv6")
+			recordDecode("IPv6", false)
 		}
 	}
 }
 
 // Parse an IPv4 payload into upper-layer protocols
-func parseIPv4Payload(passive *Passive, ipv4 *IPv4Packet) {
+func parseIPv4Payload(passive *Passive, ipv4 *IPv4Packet, decodeAs *DecodeAsTable, depth ParseDepth) {
 	switch ipv4.Protocol {
 	case 1: // ICMP
 		if len(ipv4.Payload) >= 8 {
 			// Minimum ICMP message size
 			icmp := ParseICMPPacket(ipv4.Payload)
 			passive.ICMP = icmp
+		} else {
+			logParseFailure("ICMP", ipv4.Payload)
+			passive.markPartial("ICMP")
 		}
+		recordDecode("ICMP", passive.ICMP != nil)
 
 	case 6: // TCP
 		if len(ipv4.Payload) >= 20 {
 			// Minimum TCP header size
 			tcp := ParseTCPPacket(ipv4.Payload)
+			recordDecode("TCP", tcp != nil)
+			recovered := false
+			if tcp == nil {
+				logParseFailure("TCP", ipv4.Payload)
+				// 復旧モードではヘッダーを推測して上位レイヤの解析を続ける
+				tcp = passive.recoverTCPPacket(ipv4.Payload)
+				recovered = tcp != nil
+			} else if len(ipv4.Payload) < int(tcp.DataOffset) {
+				passive.markPartial("TCP")
+			}
 			passive.TCP = tcp
 
 			// Parse application layer protocols based on port
-			if tcp != nil && len(tcp.Payload) > 0 {
-				parseTCPPayload(passive, tcp)
+			if tcp != nil && len(tcp.Payload) > 0 && depth.includes(PARSE_DEPTH_APPLICATION) {
+				parseTCPPayload(passive, tcp, decodeAs)
+				if recovered {
+					sniffTCPPayload(passive, tcp.Payload)
+				}
 			}
+		} else {
+			logParseFailure("TCP", ipv4.Payload)
+			passive.markPartial("TCP")
+			recordDecode("TCP", false)
 		}
 
 	case 17: // UDP
@@ -88,35 +143,66 @@ func parseIPv4Payload(passive *Passive, ipv4 *IPv4Packet) {
 			// UDP header size
 			udp := ParseUDPPacket(ipv4.Payload)
 			passive.UDP = udp
+			if udp != nil && len(ipv4.Payload) < int(udp.Length) {
+				passive.markPartial("UDP")
+			}
 
 			// Parse application layer protocols based on port
-			if udp != nil && len(udp.Payload) > 0 {
-				parseUDPPayload(passive, udp)
+			if udp != nil && len(udp.Payload) > 0 && depth.includes(PARSE_DEPTH_APPLICATION) {
+				parseUDPPayload(passive, udp, decodeAs)
 			}
+		} else {
+			logParseFailure("UDP", ipv4.Payload)
+			passive.markPartial("UDP")
 		}
+		recordDecode("UDP", passive.UDP != nil)
+
+	case IPv4_PROTO_IPIP, IPv4_PROTO_IPv6: // IP-in-IP, 6in4
+		parseIPinIP(passive, ipv4, decodeAs, depth)
 	}
 }
 
 // Parse an IPv6 payload into upper-layer protocols
-func parseIPv6Payload(passive *Passive, ipv6 *IPv6Packet) {
+func parseIPv6Payload(passive *Passive, ipv6 *IPv6Packet, decodeAs *DecodeAsTable, depth ParseDepth) {
 	switch ipv6.NextHeader {
 	case 58: // ICMPv6
 		if len(ipv6.Payload) >= 8 {
 			// Minimum ICMPv6 message size
 			icmpv6 := ParseICMPv6Packet(ipv6.Payload)
 			passive.ICMPv6 = icmpv6
+		} else {
+			logParseFailure("ICMPv6", ipv6.Payload)
+			passive.markPartial("ICMPv6")
 		}
+		recordDecode("ICMPv6", passive.ICMPv6 != nil)
 
 	case 6: // TCP
 		if len(ipv6.Payload) >= 20 {
 			// Minimum TCP header size
 			tcp := ParseTCPPacket(ipv6.Payload)
+			recordDecode("TCP", tcp != nil)
+			recovered := false
+			if tcp == nil {
+				logParseFailure("TCP", ipv6.Payload)
+				// 復旧モードではヘッダーを推測して上位レイヤの解析を続ける
+				tcp = passive.recoverTCPPacket(ipv6.Payload)
+				recovered = tcp != nil
+			} else if len(ipv6.Payload) < int(tcp.DataOffset) {
+				passive.markPartial("TCP")
+			}
 			passive.TCP = tcp
 
 			// Parse application layer protocols based on port
-			if tcp != nil && len(tcp.Payload) > 0 {
-				parseTCPPayload(passive, tcp)
+			if tcp != nil && len(tcp.Payload) > 0 && depth.includes(PARSE_DEPTH_APPLICATION) {
+				parseTCPPayload(passive, tcp, decodeAs)
+				if recovered {
+					sniffTCPPayload(passive, tcp.Payload)
+				}
 			}
+		} else {
+			logParseFailure("TCP", ipv6.Payload)
+			passive.markPartial("TCP")
+			recordDecode("TCP", false)
 		}
 
 	case 17: // UDP
@@ -124,17 +210,30 @@ func parseIPv6Payload(passive *Passive, ipv6 *IPv6Packet) {
 			// UDP header size
 			udp := ParseUDPPacket(ipv6.Payload)
 			passive.UDP = udp
+			if udp != nil && len(ipv6.Payload) < int(udp.Length) {
+				passive.markPartial("UDP")
+			}
 
 			// Parse application layer protocols based on port
-			if udp != nil && len(udp.Payload) > 0 {
-				parseUDPPayload(passive, udp)
+			if udp != nil && len(udp.Payload) > 0 && depth.includes(PARSE_DEPTH_APPLICATION) {
+				parseUDPPayload(passive, udp, decodeAs)
 			}
+		} else {
+			logParseFailure("UDP", ipv6.Payload)
+			passive.markPartial("UDP")
 		}
+		recordDecode("UDP", passive.UDP != nil)
 	}
 }
 
 // Parse TCP payload based on port numbers
-func parseTCPPayload(passive *Passive, tcp *TCPPacket) {
+func parseTCPPayload(passive *Passive, tcp *TCPPacket, decodeAs *DecodeAsTable) {
+	// Decode As overrides take precedence over the default port map
+	if proto, toPort, ok := decodeAs.Lookup(tcp.SrcPort, tcp.DstPort); ok {
+		decodeTCPPayloadAs(passive, tcp, proto, toPort)
+		return
+	}
+
 	// HTTP (port 80)
 	if tcp.DstPort == 80 || tcp.SrcPort == 80 {
 		if tcp.DstPort == 80 {
@@ -143,12 +242,14 @@ func parseTCPPayload(passive *Passive, tcp *TCPPacket) {
 			if http != nil {
 				passive.HTTP = http
 			}
+			recordDecode("HTTP", http != nil)
 		} else {
 			// HTTP Response
 			httpRes := ParseHTTPResponse(tcp.Payload)
 			if httpRes != nil {
 				passive.HTTPRes = httpRes
 			}
+			recordDecode("HTTP", httpRes != nil)
 		}
 	}
 
@@ -156,43 +257,84 @@ func parseTCPPayload(passive *Passive, tcp *TCPPacket) {
 	if tcp.DstPort == 443 || tcp.SrcPort == 443 {
 		// TLS parsing
 		ParseTLSData(tcp.Payload, passive)
+		recordDecode("TLS", passive.TLS != nil)
 	}
 
 	// DNS over TCP (port 53)
 	if tcp.DstPort == 53 || tcp.SrcPort == 53 {
-		if len(tcp.Payload) > 2 {
-			// Skip TCP DNS length field (first 2 bytes)
-			dnsData := tcp.Payload[2:]
-			parseDNSData(dnsData, passive)
+		parseDNSOverTCP(passive, tcp)
+	}
+
+	// SMB (port 445) / NetBIOS Session Service (port 139)
+	// ACK のみのセグメントなど、ペイロードがなければ解析しない
+	if isSMBPort(tcp.SrcPort, tcp.DstPort) && len(tcp.Payload) > 0 {
+		smb, err := ParsedSMB(tcp.Payload)
+		recordDecode("SMB", err == nil)
+		if err != nil {
+			logParseFailure("SMB", tcp.Payload)
+			return
 		}
+		passive.SMB = smb
 	}
 }
 
 // Parse UDP payload based on port numbers
-func parseUDPPayload(passive *Passive, udp *UDPPacket) {
+func parseUDPPayload(passive *Passive, udp *UDPPacket, decodeAs *DecodeAsTable) {
+	// Decode As overrides take precedence over the default port map
+	if proto, _, ok := decodeAs.Lookup(udp.SrcPort, udp.DstPort); ok {
+		decodeUDPPayloadAs(passive, udp, proto)
+		return
+	}
+
 	// DNS (port 53)
 	if udp.DstPort == 53 || udp.SrcPort == 53 {
 		parseDNSData(udp.Payload, passive)
 	}
+
+	// GENEVE (port 6081)
+	if udp.DstPort == PORT_GENEVE {
+		geneve, err := parsedGENEVE(udp.Payload, decodeAs, passive)
+		recordDecode("GENEVE", err == nil)
+		if err != nil {
+			logParseFailure("GENEVE", udp.Payload)
+			return
+		}
+		passive.GENEVE = geneve
+	}
 }
 
 // Parse DNS data
 func parseDNSData(data []byte, passive *Passive) {
+	if dns := parsedDNSMessage(data); dns != nil {
+		passive.DNS = dns
+	}
+}
+
+// Parse a DNS message, nil if it is shorter than the header
+func parsedDNSMessage(data []byte) *DNSPacket {
 	if len(data) < 12 {
 		// DNS header is 12 bytes
-		return
+		logParseFailure("DNS", data)
+		recordDecode("DNS", false)
+		return nil
 	}
+	recordDecode("DNS", true)
 
 	// Check if it's a DNS query or response
 	flags := binary.BigEndian.Uint16(data[2:4])
 	isResponse := (flags & 0x8000) != 0
 
 	if isResponse {
-		dns := ParseDNSResponse(data)
-		passive.DNS = dns
-	} else {
-		dns := ParseDNSRequest(data)
-		passive.DNS = dns
+		return ParseDNSResponse(data)
+	}
+	return ParseDNSRequest(data)
+}
+
+// verifyIPv4HeaderChecksum logs a checksum mismatch of the received IPv4 header
+// 受信したIPv4ヘッダーのチェックサム不一致をログ出力します
+func verifyIPv4HeaderChecksum(header []byte) {
+	if calculateInternetChecksum(header) != 0 {
+		logChecksumMismatch("IPv4", binary.BigEndian.Uint16(header[10:12]))
 	}
 }
 
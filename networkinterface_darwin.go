@@ -7,23 +7,51 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/google/gopacket"
-	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
+	"golang.org/x/sys/unix"
 )
 
+// CAPTURE_PERMISSION_HINT tells how to get the permission to capture with libpcap
+// libpcapでキャプチャする権限を得る方法です
+const CAPTURE_PERMISSION_HINT = "run as root with sudo, or give your user read and write access to /dev/bpf*"
+
 // NetworkInterface represents a network interface on macOS
 type NetworkInterface struct {
 	Intf       *net.Interface
 	Handle     *pcap.Handle
 	IPAddr     uint32
 	IPv6Addr   net.IP // For IPv6 support
+	IPv6Addrs  []net.IP // All IPv6 addresses of the interface, used for source address selection
 	MacAddr    net.HardwareAddr
 
 	PassiveCh chan *Passive
+
+	decodeAs        DecodeAsTable
+	captureFilter   CaptureFilter
+	parseDepth      atomic.Int32 // ParseDepth
+	snapLen         atomic.Int32
+	readTimeout     atomic.Int64 // time.Duration
+	captureDirection atomic.Int32 // CaptureDirection
+	fcsMode         atomic.Int32 // FCSMode
+	linkType        atomic.Int32 // 0 は PCAP_LINKTYPE_ETHERNET
+	parseWorkers    atomic.Int32 // 0 は 1 と同じ
+	quietWatchdog   atomic.Pointer[QuietWatchdog]
+	deduplicator    atomic.Pointer[Deduplicator]
+	sendMethod      atomic.Int32 // SendMethod
+	bpfWriter       int          // SEND_METHOD_BPF で開いた /dev/bpf. 0 は未使用
+	receiving       atomic.Bool
+	multicastMu     sync.Mutex // multicastGroups を守る
+	multicastGroups []net.IP
+	offline         bool // NewOfflineNetworkInterface で作成した
+	// IP_ADD_MEMBERSHIP / IPV6_JOIN_GROUP を保持するためのソケット (address family -> fd)
+	multicastSockets map[int]int
 }
 
 // newNetworkInterfacePlatform creates a new NetworkInterface for the specified interface on macOS
@@ -41,6 +69,7 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 
 	var ipAddr uint32
 	var ipv6Addr net.IP
+	var ipv6Addrs []net.IP
 
 	// Find the first IPv4 and IPv6 address for the interface
 	for _, addr := range ipAddrs {
@@ -51,8 +80,11 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 
 		if ip4 := ipnet.IP.To4(); ip4 != nil {
 			ipAddr = binary.BigEndian.Uint32(ip4)
-		} else if ipnet.IP.To16() != nil && ipAddr == 0 {
-			ipv6Addr = ipnet.IP
+		} else if ipnet.IP.To16() != nil {
+			if ipAddr == 0 {
+				ipv6Addr = ipnet.IP
+			}
+			ipv6Addrs = append(ipv6Addrs, ipnet.IP)
 		}
 	}
 
@@ -61,9 +93,9 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 	}
 
 	// Create a new pcap handle for packet capture
-	handle, err := pcap.OpenLive(intf.Name, 65536, true, pcap.BlockForever)
+	handle, err := openPcapHandle(intf.Name, DEFAULT_SNAPLEN, DEFAULT_READ_TIMEOUT)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open pcap handle: %v", err)
+		return nil, err
 	}
 
 	nwif := &NetworkInterface{
@@ -71,6 +103,7 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 		Handle:    handle,
 		IPAddr:    ipAddr,
 		IPv6Addr:  ipv6Addr,
+		IPv6Addrs: ipv6Addrs,
 		MacAddr:   intf.HardwareAddr,
 		PassiveCh: make(chan *Passive, 100),
 	}
@@ -78,6 +111,15 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 	return nwif, nil
 }
 
+// openPcapHandle opens a live pcap handle capturing up to snapLen bytes of each frame, whose reads return after timeout even if no packet arrived
+func openPcapHandle(name string, snapLen int, timeout time.Duration) (*pcap.Handle, error) {
+	handle, err := pcap.OpenLive(name, int32(snapLen), true, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pcap handle: %v", err)
+	}
+	return handle, nil
+}
+
 // getInterface finds the specified network interface
 func getInterface(nwInterface string) (*net.Interface, error) {
 	// List all network interfaces
@@ -98,6 +140,12 @@ func getInterface(nwInterface string) (*net.Interface, error) {
 
 // sendEthernetFramePlatform sends an Ethernet frame on macOS
 func (nwif *NetworkInterface) sendEthernetFramePlatform(ctx context.Context, data []byte) error {
+	if nwif.SendMethod() == SEND_METHOD_BPF {
+		if _, err := unix.Write(nwif.bpfWriter, data); err != nil {
+			return fmt.Errorf("failed to write to the bpf device: %v", err)
+		}
+		return nil
+	}
 	if err := nwif.Handle.WritePacketData(data); err != nil {
 		return fmt.Errorf("failed to write packet data: %v", err)
 	}
@@ -106,46 +154,92 @@ func (nwif *NetworkInterface) sendEthernetFramePlatform(ctx context.Context, dat
 
 // receiveEthernetFramePlatform receives Ethernet frames on macOS
 func (nwif *NetworkInterface) receiveEthernetFramePlatform(ctx context.Context) {
-	packetSource := gopacket.NewPacketSource(nwif.Handle, layers.LayerTypeEthernet)
-	packetChan := packetSource.Packets()
+	nwif.receiving.Store(true)
+	defer nwif.receiving.Store(false)
+	handle, stop := nwif.captureHandler()
+	defer stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case packet := <-packetChan:
-			if packet == nil {
+		default:
+			// 読み込みタイムアウトで戻ってくるので、通信が無くても ctx のキャンセルに気付ける
+			// ReadPacketData は毎回新しいスライスを返すため、コピーせずに Passive.Raw にできる
+			data, ci, err := nwif.Handle.ReadPacketData()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				nwif.watchQuiet(time.Now())
 				continue
 			}
+			nwif.watchFrame(ci.Timestamp)
 
-			// Process received packet
-			data := packet.Data()
-			if len(data) < 14 { // Minimum Ethernet frame size
+			if !nwif.captureFilter.Allows(data) {
 				continue
 			}
 
-			passive := &Passive{}
+			// Process received packet and parse upper-layer protocols
+			handle(capturedFrame{data: data, wireLength: ci.Length, timestamp: ci.Timestamp})
+		}
+	}
+}
 
-			// Parse Ethernet frame
-			ethernetFrame := &EthernetFrame{
-				DstAddr: data[0:6],
-				SrcAddr: data[6:12],
-				Type:    binary.BigEndian.Uint16(data[12:14]),
-				Payload: data[14:],
-			}
-			passive.EthernetFrame = ethernetFrame
+// setReadTimeoutPlatform reopens the pcap handle with timeout d, since pcap fixes the timeout when the handle is activated
+func (nwif *NetworkInterface) setReadTimeoutPlatform(d time.Duration) error {
+	if nwif.receiving.Load() {
+		return errors.New("read timeout cannot be changed while receiving on macOS")
+	}
+	handle, err := openPcapHandle(nwif.Intf.Name, nwif.SnapLen(), d)
+	if err != nil {
+		return err
+	}
+	if nwif.Handle != nil {
+		nwif.Handle.Close()
+	}
+	nwif.Handle = handle
+	return nil
+}
 
-			// Parse upper-layer protocols
-			parseEthernetPayload(passive)
+// setSnapLenPlatform reopens the pcap handle with snapLen, since pcap fixes the snap length when the handle is activated
+func (nwif *NetworkInterface) setSnapLenPlatform(snapLen int) error {
+	if nwif.receiving.Load() {
+		return errors.New("snap length cannot be changed while receiving on macOS")
+	}
+	handle, err := openPcapHandle(nwif.Intf.Name, snapLen, nwif.ReadTimeout())
+	if err != nil {
+		return err
+	}
+	if nwif.Handle != nil {
+		nwif.Handle.Close()
+	}
+	nwif.Handle = handle
+	return nil
+}
 
-			// Send to channel
-			select {
-			case nwif.PassiveCh <- passive:
-			default:
-				// Channel is full, discard packet
-			}
+// setSendMethodPlatform opens a /dev/bpf device for SEND_METHOD_BPF, and closes it when going back to pcap
+func (nwif *NetworkInterface) setSendMethodPlatform(method SendMethod) error {
+	if method == SEND_METHOD_BPF && nwif.bpfWriter == 0 {
+		fd, err := openBPFWriter(nwif.Intf.Name)
+		if err != nil {
+			return err
 		}
+		nwif.bpfWriter = fd
+	}
+	if method != SEND_METHOD_BPF && nwif.bpfWriter != 0 {
+		unix.Close(nwif.bpfWriter)
+		nwif.bpfWriter = 0
 	}
+	return nil
+}
+
+// setCaptureDirectionPlatform only accepts CAPTURE_DIRECTION_BOTH, since the handle is not told the direction of a frame
+func (nwif *NetworkInterface) setCaptureDirectionPlatform(direction CaptureDirection) error {
+	if direction != CAPTURE_DIRECTION_BOTH {
+		return errors.New("capture direction is only supported on Linux")
+	}
+	return nil
 }
 
 // getNetworkInfoPlatform returns information about the network interface
@@ -153,7 +247,76 @@ func (nwif *NetworkInterface) getNetworkInfoPlatform() (macAddr net.HardwareAddr
 	ipv4 := make(net.IP, 4)
 	binary.BigEndian.PutUint32(ipv4, nwif.IPAddr)
 	
-	return nwif.MacAddr, ipv4, nwif.IPv6Addr
+	// NewOfflineNetworkInterface は MacAddr を設定しない
+	macAddr = nwif.MacAddr
+	if macAddr == nil && nwif.Intf != nil {
+		macAddr = nwif.Intf.HardwareAddr
+	}
+	return macAddr, ipv4, nwif.IPv6Addr
+}
+
+// joinMulticastPlatform joins the group with IP_ADD_MEMBERSHIP / IPV6_JOIN_GROUP.
+// pcap cannot add link-layer memberships, so a UDP socket is kept open to hold the membership.
+// pcap ではリンク層のメンバーシップを追加できないため、UDPソケットでメンバーシップを保持します
+func (nwif *NetworkInterface) joinMulticastPlatform(group net.IP) error {
+	if group4 := group.To4(); group4 != nil {
+		fd, err := nwif.multicastSocket(unix.AF_INET)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptIPMreq(fd, unix.IPPROTO_IP, unix.IP_ADD_MEMBERSHIP, nwif.ipMreq(group4))
+	}
+
+	fd, err := nwif.multicastSocket(unix.AF_INET6)
+	if err != nil {
+		return err
+	}
+	return unix.SetsockoptIPv6Mreq(fd, unix.IPPROTO_IPV6, unix.IPV6_JOIN_GROUP, nwif.ipv6Mreq(group))
+}
+
+// leaveMulticastPlatform leaves the group with IP_DROP_MEMBERSHIP / IPV6_LEAVE_GROUP
+func (nwif *NetworkInterface) leaveMulticastPlatform(group net.IP) error {
+	if group4 := group.To4(); group4 != nil {
+		fd, err := nwif.multicastSocket(unix.AF_INET)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptIPMreq(fd, unix.IPPROTO_IP, unix.IP_DROP_MEMBERSHIP, nwif.ipMreq(group4))
+	}
+
+	fd, err := nwif.multicastSocket(unix.AF_INET6)
+	if err != nil {
+		return err
+	}
+	return unix.SetsockoptIPv6Mreq(fd, unix.IPPROTO_IPV6, unix.IPV6_LEAVE_GROUP, nwif.ipv6Mreq(group))
+}
+
+func (nwif *NetworkInterface) multicastSocket(family int) (int, error) {
+	if fd, ok := nwif.multicastSockets[family]; ok {
+		return fd, nil
+	}
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open multicast socket: %v", err)
+	}
+	if nwif.multicastSockets == nil {
+		nwif.multicastSockets = make(map[int]int)
+	}
+	nwif.multicastSockets[family] = fd
+	return fd, nil
+}
+
+func (nwif *NetworkInterface) ipMreq(group4 net.IP) *unix.IPMreq {
+	mreq := &unix.IPMreq{}
+	copy(mreq.Multiaddr[:], group4)
+	binary.BigEndian.PutUint32(mreq.Interface[:], nwif.IPAddr)
+	return mreq
+}
+
+func (nwif *NetworkInterface) ipv6Mreq(group net.IP) *unix.IPv6Mreq {
+	mreq := &unix.IPv6Mreq{Interface: uint32(nwif.Intf.Index)}
+	copy(mreq.Multiaddr[:], group.To16())
+	return mreq
 }
 
 // closePlatform cleans up resources
@@ -161,4 +324,16 @@ func (nwif *NetworkInterface) closePlatform() {
 	if nwif.Handle != nil {
 		nwif.Handle.Close()
 	}
+	for _, fd := range nwif.multicastSockets {
+		unix.Close(fd)
+	}
+	if nwif.bpfWriter != 0 {
+		unix.Close(nwif.bpfWriter)
+	}
+}
+
+// ethernetLinkPlatform reports whether the pcap handle captures Ethernet frames. The loopback interface lo0 is DLT_NULL instead
+// pcapのハンドルがEthernetフレームをキャプチャするかどうかを返します。ループバックインターフェースのlo0はDLT_NULLです
+func (nwif *NetworkInterface) ethernetLinkPlatform() bool {
+	return int(nwif.Handle.LinkType()) == PCAP_LINKTYPE_ETHERNET
 }
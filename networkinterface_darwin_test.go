@@ -0,0 +1,68 @@
+//go:build darwin
+// +build darwin
+
+package packemon
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSendMethodBPF tests that a frame written to /dev/bpf is sent on the interface, by capturing it with pcap on the same interface
+// /dev/bpfに書き込んだフレームがインターフェースから送信されることを、同じインターフェースのpcapでキャプチャしてテストします
+func TestSendMethodBPF(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("writing to /dev/bpf needs root")
+	}
+	// lo0 は DLT_NULL のため、Ethernet のインターフェースを使う
+	intfs, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("cannot list interfaces: %v", err)
+	}
+	var nwif *NetworkInterface
+	for _, intf := range intfs {
+		if intf.Flags&net.FlagUp == 0 || intf.Flags&net.FlagLoopback != 0 || len(intf.HardwareAddr) != 6 {
+			continue
+		}
+		if nwif, err = newNetworkInterfacePlatform(intf.Name); err == nil && nwif.ethernetLinkPlatform() {
+			break
+		}
+		nwif = nil
+	}
+	if nwif == nil {
+		t.Skip("no Ethernet interface to capture on")
+	}
+	defer nwif.closePlatform()
+
+	if err := nwif.SetSendMethod(SEND_METHOD_BPF); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nwif.ReceiveEthernetFrame(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	// 実験用の EtherType 0x88b5 で自身宛てに送り、他の通信と区別する
+	frame := append(append(append([]byte{}, nwif.MacAddr...), nwif.MacAddr...), 0x88, 0xb5)
+	frame = append(frame, []byte("packemon bpf send")...)
+	if err := nwif.SendEthernetFrame(ctx, frame); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case passive := <-nwif.PassiveCh:
+			if passive.EthernetFrame.Type == 0x88b5 && bytes.HasPrefix(passive.Raw, frame) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the frame written to /dev/bpf was not captured")
+		}
+	}
+}
@@ -8,10 +8,17 @@ import (
 	"errors"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
 
+// CAPTURE_PERMISSION_HINT tells how to get the permission to capture with a raw socket
+// raw socketでキャプチャする権限を得る方法です
+const CAPTURE_PERMISSION_HINT = "run as root with sudo, or grant the capability with: sudo setcap cap_net_raw+ep /path/to/packemon"
+
 // NetworkInterface represents a network interface on Linux
 type NetworkInterface struct {
 	Intf       *net.Interface
@@ -19,8 +26,25 @@ type NetworkInterface struct {
 	SocketAddr unix.SockaddrLinklayer
 	IPAddr     uint32
 	IPv6Addr   net.IP // For IPv6 support
+	IPv6Addrs  []net.IP // All IPv6 addresses of the interface, used for source address selection
 
 	PassiveCh chan *Passive
+
+	decodeAs        DecodeAsTable
+	captureFilter   CaptureFilter
+	parseDepth      atomic.Int32 // ParseDepth
+	snapLen         atomic.Int32
+	readTimeout     atomic.Int64 // time.Duration
+	captureDirection atomic.Int32 // CaptureDirection
+	fcsMode         atomic.Int32 // FCSMode
+	linkType        atomic.Int32 // 0 は PCAP_LINKTYPE_ETHERNET
+	parseWorkers    atomic.Int32 // 0 は 1 と同じ
+	quietWatchdog   atomic.Pointer[QuietWatchdog]
+	deduplicator    atomic.Pointer[Deduplicator]
+	sendMethod      atomic.Int32 // SendMethod
+	multicastMu     sync.Mutex // multicastGroups を守る
+	multicastGroups []net.IP
+	offline         bool // NewOfflineNetworkInterface で作成した
 }
 
 // newNetworkInterfacePlatform creates a new NetworkInterface for the specified interface on Linux
@@ -36,6 +60,7 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 
 	var ipAddr uint32
 	var ipv6Addr net.IP
+	var ipv6Addrs []net.IP
 	
 	for _, addr := range ipAddrs {
 		var ip net.IP
@@ -51,8 +76,11 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 		
 		if ip4 := ip.To4(); ip4 != nil {
 			ipAddr = binary.BigEndian.Uint32(ip4)
-		} else if ip.To16() != nil && ipv6Addr == nil {
-			ipv6Addr = ip
+		} else if ip.To16() != nil {
+			if ipv6Addr == nil {
+				ipv6Addr = ip
+			}
+			ipv6Addrs = append(ipv6Addrs, ip)
 		}
 	}
 
@@ -79,8 +107,13 @@ func newNetworkInterfacePlatform(nwInterface string) (*NetworkInterface, error)
 		SocketAddr: addr,
 		IPAddr:     ipAddr,
 		IPv6Addr:   ipv6Addr,
+		IPv6Addrs:  ipv6Addrs,
 		PassiveCh:  make(chan *Passive, 100),
 	}
+	if err := nwif.setReadTimeoutPlatform(DEFAULT_READ_TIMEOUT); err != nil {
+		unix.Close(sock)
+		return nil, err
+	}
 
 	return nwif, nil
 }
@@ -108,44 +141,95 @@ func (nwif *NetworkInterface) sendEthernetFramePlatform(ctx context.Context, dat
 
 // receiveEthernetFramePlatform receives Ethernet frames on Linux
 func (nwif *NetworkInterface) receiveEthernetFramePlatform(ctx context.Context) {
-	buf := make([]byte, 1500)
+	buf := make([]byte, DEFAULT_SNAPLEN)
+	fds := []unix.PollFd{{Fd: int32(nwif.Socket), Events: unix.POLLIN}}
+	handle, stop := nwif.captureHandler()
+	defer stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			n, _, err := unix.Recvfrom(nwif.Socket, buf, 0)
-			if err != nil {
+			// ctx のキャンセルに気付けるよう、受信を待つのは読み込みタイムアウトまで
+			ready, err := unix.Poll(fds, max(1, int(nwif.ReadTimeout().Milliseconds())))
+			if err != nil || ready == 0 {
+				nwif.watchQuiet(time.Now())
 				continue
 			}
 
-			if n <= 14 {
+			// スナップ長を超える分はカーネルからコピーしない. MSG_TRUNC で収まらなかった場合も回線上の長さが返る
+			snapLen := nwif.SnapLen()
+			wireLength, from, err := unix.Recvfrom(nwif.Socket, buf[:snapLen], unix.MSG_TRUNC)
+			if err != nil {
 				continue
 			}
+			timestamp := time.Now()
+			nwif.watchFrame(timestamp)
 
-			frame := &EthernetFrame{
-				DstAddr: buf[0:6],
-				SrcAddr: buf[6:12],
-				Type:    binary.BigEndian.Uint16(buf[12:14]),
-				Payload: buf[14:n],
+			// 解析する前に、対象外の方向のフレームを捨てる
+			if !nwif.CaptureDirection().allows(frameDirection(from)) {
+				continue
 			}
 
-			passive := &Passive{
-				EthernetFrame: frame,
+			received := buf[:min(wireLength, snapLen)]
+			if !nwif.captureFilter.Allows(received) {
+				continue
 			}
 
-			parseEthernetPayload(passive)
+			// buf は次の受信で上書きされるため、Passive が参照する分はコピーしておく
+			data := make([]byte, len(received))
+			copy(data, received)
 
-			select {
-			case nwif.PassiveCh <- passive:
-			default:
-				// Channel is full, discard packet
-			}
+			handle(capturedFrame{data: data, wireLength: wireLength, timestamp: timestamp})
 		}
 	}
 }
 
+// setReadTimeoutPlatform sets SO_RCVTIMEO so that Recvfrom never blocks longer than d
+func (nwif *NetworkInterface) setReadTimeoutPlatform(d time.Duration) error {
+	tv := unix.NsecToTimeval(d.Nanoseconds())
+	return unix.SetsockoptTimeval(nwif.Socket, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+}
+
+// setSendMethodPlatform only accepts SEND_METHOD_DEFAULT, since the raw socket already injects at layer 2
+func (nwif *NetworkInterface) setSendMethodPlatform(method SendMethod) error {
+	if method != SEND_METHOD_DEFAULT {
+		return errors.New("send method is only supported on macOS")
+	}
+	return nil
+}
+
+// setSnapLenPlatform has nothing to do, since the receive loop reads at most SnapLen bytes of each frame
+func (nwif *NetworkInterface) setSnapLenPlatform(snapLen int) error {
+	return nil
+}
+
+// setCaptureDirectionPlatform sets PACKET_IGNORE_OUTGOING for ingress-only capture, so the kernel does not even queue sent frames.
+// Kernels before 4.20 lack it; the receive loop drops sent frames by their packet type anyway.
+// ingressのみの場合はPACKET_IGNORE_OUTGOINGを設定し、送信したフレームをカーネルがキューに入れないようにします。
+// 4.20より前のカーネルには無いが、受信ループでもパケットタイプで送信したフレームを捨てる
+func (nwif *NetworkInterface) setCaptureDirectionPlatform(direction CaptureDirection) error {
+	ignoreOutgoing := 0
+	if direction == CAPTURE_DIRECTION_INGRESS {
+		ignoreOutgoing = 1
+	}
+	if err := unix.SetsockoptInt(nwif.Socket, unix.SOL_PACKET, unix.PACKET_IGNORE_OUTGOING, ignoreOutgoing); err != nil && !errors.Is(err, unix.ENOPROTOOPT) {
+		return err
+	}
+	return nil
+}
+
+// frameDirection returns the direction of a frame from the packet type of the address Recvfrom returned.
+// PACKET_OUTGOING is a frame sent from this host; anything else (PACKET_HOST, PACKET_BROADCAST...) was received.
+// Recvfromが返したアドレスのパケットタイプからフレームの方向を返します。PACKET_OUTGOINGはこのホストから送信したフレームです
+func frameDirection(from unix.Sockaddr) CaptureDirection {
+	if ll, ok := from.(*unix.SockaddrLinklayer); ok && ll.Pkttype == unix.PACKET_OUTGOING {
+		return CAPTURE_DIRECTION_EGRESS
+	}
+	return CAPTURE_DIRECTION_INGRESS
+}
+
 // getNetworkInfoPlatform returns information about the network interface
 func (nwif *NetworkInterface) getNetworkInfoPlatform() (macAddr net.HardwareAddr, ipv4Addr net.IP, ipv6Addr net.IP) {
 	ipv4 := make(net.IP, 4)
@@ -154,9 +238,51 @@ func (nwif *NetworkInterface) getNetworkInfoPlatform() (macAddr net.HardwareAddr
 	return nwif.Intf.HardwareAddr, ipv4, nwif.IPv6Addr
 }
 
+// joinMulticastPlatform adds the group's MAC address to the interface with PACKET_ADD_MEMBERSHIP.
+// The raw socket receives frames below the IP layer, so the link-layer membership is what is needed here.
+// raw socket はIP層より下でフレームを受信するため、リンク層でのメンバーシップを追加します
+func (nwif *NetworkInterface) joinMulticastPlatform(group net.IP) error {
+	mreq, err := nwif.packetMreq(group)
+	if err != nil {
+		return err
+	}
+	return unix.SetsockoptPacketMreq(nwif.Socket, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq)
+}
+
+// leaveMulticastPlatform removes the group's MAC address with PACKET_DROP_MEMBERSHIP
+func (nwif *NetworkInterface) leaveMulticastPlatform(group net.IP) error {
+	mreq, err := nwif.packetMreq(group)
+	if err != nil {
+		return err
+	}
+	return unix.SetsockoptPacketMreq(nwif.Socket, unix.SOL_PACKET, unix.PACKET_DROP_MEMBERSHIP, mreq)
+}
+
+func (nwif *NetworkInterface) packetMreq(group net.IP) (*unix.PacketMreq, error) {
+	mac, err := MulticastMAC(group)
+	if err != nil {
+		return nil, err
+	}
+	mreq := &unix.PacketMreq{
+		Ifindex: int32(nwif.Intf.Index),
+		Type:    unix.PACKET_MR_MULTICAST,
+		Alen:    uint16(len(mac)),
+	}
+	copy(mreq.Address[:], mac)
+	return mreq, nil
+}
+
 // closePlatform closes the socket
 func (nwif *NetworkInterface) closePlatform() {
 	if nwif.Socket != 0 {
 		unix.Close(nwif.Socket)
 	}
 }
+
+// ethernetLinkPlatform reports whether frames on the interface have Ethernet headers.
+// AF_PACKET gives the loopback interface a zeroed Ethernet header, while devices without a MAC address such as tun have none
+// インターフェースのフレームにEthernetヘッダーがあるかどうかを返します。
+// AF_PACKETではループバックインターフェースにもゼロのEthernetヘッダーが付くが、tunなどMACアドレスの無いデバイスには付かない
+func (nwif *NetworkInterface) ethernetLinkPlatform() bool {
+	return nwif.Intf.Flags&net.FlagLoopback != 0 || len(nwif.Intf.HardwareAddr) == 6
+}
@@ -0,0 +1,173 @@
+//go:build linux
+// +build linux
+
+package packemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestReceiveEthernetFrameReadTimeout tests that the receive loop on a quiet socket returns soon after ctx is canceled
+// 通信の無いソケットでも、ctxのキャンセル後すぐに受信ループが終わることをテストします
+func TestReceiveEthernetFrameReadTimeout(t *testing.T) {
+	// 何も届かないソケットを通信の無いインターフェースの代わりにする
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+
+	nwif := &NetworkInterface{Socket: fds[0], PassiveCh: make(chan *Passive, 1)}
+	if got := nwif.ReadTimeout(); got != DEFAULT_READ_TIMEOUT {
+		t.Errorf("ReadTimeout() = %v, want the default %v", got, DEFAULT_READ_TIMEOUT)
+	}
+	if err := nwif.SetReadTimeout(0); err == nil {
+		t.Error("SetReadTimeout(0) should return an error")
+	}
+
+	const timeout = 20 * time.Millisecond
+	if err := nwif.SetReadTimeout(timeout); err != nil {
+		t.Fatal(err)
+	}
+	tv, err := unix.GetsockoptTimeval(fds[0], unix.SOL_SOCKET, unix.SO_RCVTIMEO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(tv.Nano()); got != timeout {
+		t.Errorf("SO_RCVTIMEO = %v, want %v", got, timeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		nwif.ReceiveEthernetFrame(ctx)
+		close(done)
+	}()
+
+	time.Sleep(3 * timeout)
+	cancel()
+	canceled := time.Now()
+	select {
+	case <-done:
+		if elapsed := time.Since(canceled); elapsed > 10*timeout {
+			t.Errorf("receive loop returned %v after cancel, want within a few read timeouts of %v", elapsed, timeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("receive loop did not return after ctx was canceled")
+	}
+}
+
+// TestCaptureDirection tests that frames sent from this host are dropped in ingress-only mode and kept in egress-only mode
+// このホストから送信したフレームが、ingressのみでは捨てられ、egressのみでは残ることをテストします
+func TestCaptureDirection(t *testing.T) {
+	if d := frameDirection(&unix.SockaddrLinklayer{Pkttype: unix.PACKET_OUTGOING}); d != CAPTURE_DIRECTION_EGRESS || CAPTURE_DIRECTION_INGRESS.allows(d) {
+		t.Errorf("a PACKET_OUTGOING frame is %s, want egress and dropped in ingress-only mode", d)
+	}
+	if d := frameDirection(&unix.SockaddrLinklayer{Pkttype: unix.PACKET_HOST}); d != CAPTURE_DIRECTION_INGRESS || CAPTURE_DIRECTION_EGRESS.allows(d) {
+		t.Errorf("a PACKET_HOST frame is %s, want ingress and dropped in egress-only mode", d)
+	}
+
+	// ループバックでは送信したフレームが送信(PACKET_OUTGOING)と受信(PACKET_HOST)の2回観測される
+	nwif, err := newNetworkInterfacePlatform("lo")
+	if err != nil {
+		t.Skipf("raw socket on lo is not available: %v", err)
+	}
+	defer nwif.closePlatform()
+	sender, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err != nil {
+		t.Skipf("raw socket is not available: %v", err)
+	}
+	defer unix.Close(sender)
+
+	// 実験用の EtherType 0x88b5 で他の通信と区別する
+	frame := append(make([]byte, 12), 0x88, 0xb5)
+	frame = append(frame, []byte("packemon capture direction")...)
+	for _, tt := range []struct {
+		direction CaptureDirection
+		want      int
+	}{
+		{CAPTURE_DIRECTION_BOTH, 2},
+		{CAPTURE_DIRECTION_INGRESS, 1},
+		{CAPTURE_DIRECTION_EGRESS, 1},
+	} {
+		if err := nwif.SetCaptureDirection(tt.direction); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			nwif.ReceiveEthernetFrame(ctx)
+			close(done)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		if err := unix.Sendto(sender, frame, 0, &nwif.SocketAddr); err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		<-done
+
+		got := 0
+		for len(nwif.PassiveCh) > 0 {
+			if p := <-nwif.PassiveCh; p.EthernetFrame.Type == 0x88b5 {
+				got++
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: captured the frame %d times, want %d", tt.direction, got, tt.want)
+		}
+	}
+}
+
+// TestSnapLen tests that only snap length bytes of a longer frame are read, and that the frame is marked as truncated with its wire length
+// スナップ長より長いフレームはその長さだけ読み込まれ、回線上の長さとともに切り詰められたと記録されることをテストします
+func TestSnapLen(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+
+	nwif := &NetworkInterface{Socket: fds[0], PassiveCh: make(chan *Passive, 1)}
+	if got := nwif.SnapLen(); got != DEFAULT_SNAPLEN {
+		t.Errorf("SnapLen() = %d, want the default %d", got, DEFAULT_SNAPLEN)
+	}
+	const snapLen = 64
+	if err := nwif.SetSnapLen(snapLen); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := parseDepthTestFrame()
+	if _, err := unix.Write(fds[1], frame); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nwif.ReceiveEthernetFrame(ctx)
+
+	select {
+	case passive := <-nwif.PassiveCh:
+		if len(passive.Raw) != snapLen || passive.RawLength != snapLen || passive.WireLength != len(frame) || !passive.Truncated {
+			t.Errorf("Raw %d bytes, RawLength %d, WireLength %d, Truncated %v, want %d, %d, %d, true", len(passive.Raw), passive.RawLength, passive.WireLength, passive.Truncated, snapLen, snapLen, len(frame))
+		}
+		// Ethernet 14 + IPv4 20 + TCP 20 バイトの後ろは 10 バイトだけ残る
+		if passive.TCP == nil || len(passive.TCP.Payload) != snapLen-54 {
+			t.Errorf("TCP = %+v, want the header and %d bytes of payload", passive.TCP, snapLen-54)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the frame was not received")
+	}
+
+	// 0 以下はフレーム全体に戻す
+	if err := nwif.SetSnapLen(0); err != nil || nwif.SnapLen() != DEFAULT_SNAPLEN {
+		t.Errorf("SetSnapLen(0) = %v, SnapLen() = %d, want the default %d", err, nwif.SnapLen(), DEFAULT_SNAPLEN)
+	}
+}
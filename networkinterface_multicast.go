@@ -0,0 +1,96 @@
+package packemon
+
+import (
+	"errors"
+	"net"
+)
+
+// Well-known multicast groups that need to be joined to capture their traffic
+// キャプチャのために参加が必要な代表的なマルチキャストグループ
+var (
+	MULTICAST_ALL_SPF_ROUTERS = net.IPv4(224, 0, 0, 5) // OSPF AllSPFRouters
+	MULTICAST_ALL_D_ROUTERS   = net.IPv4(224, 0, 0, 6) // OSPF AllDRouters
+	MULTICAST_MDNS_IPv4       = net.IPv4(224, 0, 0, 251)
+	MULTICAST_MDNS_IPv6       = net.ParseIP("ff02::fb")
+)
+
+// MulticastMAC returns the Ethernet address a multicast group is mapped to.
+// IPv4 groups map to 01:00:5e + low 23 bits (RFC 1112), IPv6 groups to 33:33 + low 32 bits (RFC 2464).
+// マルチキャストグループに対応するEthernetアドレスを返します
+func MulticastMAC(group net.IP) (net.HardwareAddr, error) {
+	if !group.IsMulticast() {
+		return nil, errors.New("not a multicast address: " + group.String())
+	}
+	if group4 := group.To4(); group4 != nil {
+		return net.HardwareAddr{0x01, 0x00, 0x5e, group4[1] & 0x7f, group4[2], group4[3]}, nil
+	}
+	group16 := group.To16()
+	return net.HardwareAddr{0x33, 0x33, group16[12], group16[13], group16[14], group16[15]}, nil
+}
+
+// JoinMulticast joins the multicast group so that its traffic is delivered to this capture.
+// Joined groups are left in Close.
+// マルチキャストグループに参加し、そのトラフィックをキャプチャできるようにします。参加したグループはCloseで離脱します
+func (nwif *NetworkInterface) JoinMulticast(group net.IP) error {
+	if !group.IsMulticast() {
+		return errors.New("not a multicast address: " + group.String())
+	}
+	nwif.multicastMu.Lock()
+	defer nwif.multicastMu.Unlock()
+
+	for _, joined := range nwif.multicastGroups {
+		if joined.Equal(group) {
+			return nil
+		}
+	}
+
+	if nwif.offline {
+		return ErrOffline
+	}
+	if err := nwif.joinMulticastPlatform(group); err != nil {
+		return err
+	}
+	nwif.multicastGroups = append(nwif.multicastGroups, group)
+	return nil
+}
+
+// LeaveMulticast leaves a multicast group joined with JoinMulticast
+// JoinMulticastで参加したマルチキャストグループから離脱します
+func (nwif *NetworkInterface) LeaveMulticast(group net.IP) error {
+	nwif.multicastMu.Lock()
+	defer nwif.multicastMu.Unlock()
+
+	for i, joined := range nwif.multicastGroups {
+		if !joined.Equal(group) {
+			continue
+		}
+		if err := nwif.leaveMulticastPlatform(joined); err != nil {
+			return err
+		}
+		nwif.multicastGroups = append(nwif.multicastGroups[:i], nwif.multicastGroups[i+1:]...)
+		return nil
+	}
+	return errors.New("not joined to multicast group: " + group.String())
+}
+
+// MulticastGroups returns the multicast groups currently joined
+// 現在参加しているマルチキャストグループを返します
+func (nwif *NetworkInterface) MulticastGroups() []net.IP {
+	nwif.multicastMu.Lock()
+	defer nwif.multicastMu.Unlock()
+
+	groups := make([]net.IP, len(nwif.multicastGroups))
+	copy(groups, nwif.multicastGroups)
+	return groups
+}
+
+// Close 時に参加中のグループから抜ける. エラーは無視
+func (nwif *NetworkInterface) leaveAllMulticast() {
+	nwif.multicastMu.Lock()
+	defer nwif.multicastMu.Unlock()
+
+	for _, group := range nwif.multicastGroups {
+		_ = nwif.leaveMulticastPlatform(group)
+	}
+	nwif.multicastGroups = nil
+}
@@ -0,0 +1,88 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+)
+
+// TestMulticastMAC tests the mapping from multicast groups to Ethernet addresses
+// マルチキャストグループからEthernetアドレスへの変換をテストします
+func TestMulticastMAC(t *testing.T) {
+	tests := []struct {
+		group string
+		want  string
+	}{
+		{"224.0.0.5", "01:00:5e:00:00:05"},
+		{"224.0.0.251", "01:00:5e:00:00:fb"},
+		{"239.255.255.250", "01:00:5e:7f:ff:fa"}, // 上位1bitは落とす
+		{"ff02::fb", "33:33:00:00:00:fb"},
+		{"ff02::1:ff00:1", "33:33:ff:00:00:01"},
+	}
+
+	for _, tt := range tests {
+		got, err := MulticastMAC(net.ParseIP(tt.group))
+		if err != nil {
+			t.Errorf("MulticastMAC(%s) returned error: %v", tt.group, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("MulticastMAC(%s) = %s, want %s", tt.group, got, tt.want)
+		}
+	}
+
+	if _, err := MulticastMAC(net.ParseIP("192.168.10.110")); err == nil {
+		t.Errorf("MulticastMAC should fail for unicast address")
+	}
+}
+
+// TestJoinMulticastAllSPFRouters tests joining and leaving the all-OSPF-routers group.
+// Opening the capture needs privileges, so the test is skipped when it fails.
+// AllSPFRouters への参加と離脱をテストします。キャプチャのオープンには権限が必要なため、失敗時はスキップします
+func TestJoinMulticastAllSPFRouters(t *testing.T) {
+	intf := multicastTestInterface(t)
+	nwif, err := NewNetworkInterface(intf.Name)
+	if err != nil {
+		t.Skipf("cannot open capture on %s: %v", intf.Name, err)
+	}
+	defer nwif.Close()
+
+	if err := nwif.JoinMulticast(MULTICAST_ALL_SPF_ROUTERS); err != nil {
+		t.Fatalf("JoinMulticast returned error: %v", err)
+	}
+	// 2回目の参加は何もしない
+	if err := nwif.JoinMulticast(MULTICAST_ALL_SPF_ROUTERS); err != nil {
+		t.Fatalf("JoinMulticast returned error: %v", err)
+	}
+	if groups := nwif.MulticastGroups(); len(groups) != 1 || !groups[0].Equal(MULTICAST_ALL_SPF_ROUTERS) {
+		t.Fatalf("MulticastGroups() = %v, want [%s]", groups, MULTICAST_ALL_SPF_ROUTERS)
+	}
+
+	if err := nwif.LeaveMulticast(MULTICAST_ALL_SPF_ROUTERS); err != nil {
+		t.Fatalf("LeaveMulticast returned error: %v", err)
+	}
+	if groups := nwif.MulticastGroups(); len(groups) != 0 {
+		t.Errorf("MulticastGroups() = %v, want empty", groups)
+	}
+	if err := nwif.LeaveMulticast(MULTICAST_ALL_SPF_ROUTERS); err == nil {
+		t.Errorf("LeaveMulticast should fail for a group not joined")
+	}
+
+	if err := nwif.JoinMulticast(net.ParseIP("192.168.10.110")); err == nil {
+		t.Errorf("JoinMulticast should fail for unicast address")
+	}
+}
+
+func multicastTestInterface(t *testing.T) *net.Interface {
+	t.Helper()
+	intfs, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("cannot list interfaces: %v", err)
+	}
+	for _, intf := range intfs {
+		if intf.Flags&net.FlagUp != 0 && intf.Flags&net.FlagMulticast != 0 && intf.Flags&net.FlagLoopback == 0 {
+			return &intf
+		}
+	}
+	t.Skip("no multicast capable interface")
+	return nil
+}
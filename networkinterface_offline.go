@@ -0,0 +1,78 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrCapturePermission is returned by NewNetworkInterface when the process is not allowed to capture, e.g. not run as root
+	// rootで実行していないなど、キャプチャの権限が無い場合にNewNetworkInterfaceが返すエラーです
+	ErrCapturePermission = errors.New("no permission to capture")
+
+	// ErrOffline is returned when sending on a NetworkInterface created with NewOfflineNetworkInterface
+	// NewOfflineNetworkInterfaceで作成したNetworkInterfaceで送信した場合に返すエラーです
+	ErrOffline = errors.New("network interface is offline")
+)
+
+// capturePermissionError maps a permission error opening the capture to ErrCapturePermission with what to do about it.
+// Other errors are returned as they are.
+// キャプチャを開く際の権限エラーを、対処方法を含めたErrCapturePermissionに変換します。それ以外のエラーはそのまま返します
+func capturePermissionError(nwInterface string, err error) error {
+	if err == nil || !isPermissionError(err) {
+		return err
+	}
+	return fmt.Errorf("%w on %s (%w): %s. Frames can still be decoded and built offline without capturing", ErrCapturePermission, nwInterface, err, CAPTURE_PERMISSION_HINT)
+}
+
+// socket の EPERM / EACCES に加え、libpcap はエラーを文字列で返す
+func isPermissionError(err error) bool {
+	return errors.Is(err, os.ErrPermission) || strings.Contains(strings.ToLower(err.Error()), "permission")
+}
+
+// NewOfflineNetworkInterface creates a NetworkInterface without a live socket, for reading saved frames and building packets without root.
+// The interface's addresses are filled in if it exists, so packets can still be built with them. Sending returns ErrOffline and receiving waits until ctx is done.
+// ライブのソケットを持たないNetworkInterfaceを作成します。rootなしで保存したフレームの読み込みやパケットの作成ができます。
+// インターフェースが存在する場合はそのアドレスを設定します。送信はErrOfflineを返し、受信はctxが終了するまで待つだけです
+func NewOfflineNetworkInterface(nwInterface string) *NetworkInterface {
+	nwif := &NetworkInterface{
+		Intf:      &net.Interface{Name: nwInterface},
+		PassiveCh: make(chan *Passive, 100),
+		offline:   true,
+	}
+	intf, err := getInterface(nwInterface)
+	if err != nil {
+		return nwif
+	}
+	nwif.Intf = intf
+
+	addrs, err := intf.Addrs()
+	if err != nil {
+		return nwif
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() {
+			continue
+		}
+		if ip4 := ipnet.IP.To4(); ip4 != nil {
+			nwif.IPAddr = binary.BigEndian.Uint32(ip4)
+		} else {
+			if nwif.IPv6Addr == nil {
+				nwif.IPv6Addr = ipnet.IP
+			}
+			nwif.IPv6Addrs = append(nwif.IPv6Addrs, ipnet.IP)
+		}
+	}
+	return nwif
+}
+
+// Offline reports whether the interface was created with NewOfflineNetworkInterface
+// NewOfflineNetworkInterfaceで作成したインターフェースかどうかを返します
+func (nwif *NetworkInterface) Offline() bool {
+	return nwif.offline
+}
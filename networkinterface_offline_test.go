@@ -0,0 +1,79 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestCapturePermissionError tests that permission errors opening a capture are mapped to ErrCapturePermission with a hint
+// キャプチャを開く際の権限エラーが、対処方法を含めたErrCapturePermissionに変換されることをテストします
+func TestCapturePermissionError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantPermission bool
+	}{
+		{name: "EPERM from socket", err: syscall.EPERM, wantPermission: true},
+		{name: "EACCES", err: syscall.EACCES, wantPermission: true},
+		{name: "libpcap", err: errors.New("failed to open pcap handle: en0: You don't have permission to capture on that device"), wantPermission: true},
+		{name: "other error", err: syscall.ENODEV, wantPermission: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := capturePermissionError("eth0", tt.err)
+			if got := errors.Is(err, ErrCapturePermission); got != tt.wantPermission {
+				t.Fatalf("errors.Is(%v, ErrCapturePermission) = %v, want %v", err, got, tt.wantPermission)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("original error should be kept: %v", err)
+			}
+			if !tt.wantPermission {
+				return
+			}
+			for _, want := range []string{"eth0", "sudo", CAPTURE_PERMISSION_HINT, "offline"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q should contain %q", err, want)
+				}
+			}
+		})
+	}
+
+	if err := capturePermissionError("eth0", nil); err != nil {
+		t.Errorf("nil error should stay nil: %v", err)
+	}
+}
+
+// TestOfflineNetworkInterface tests that an offline interface refuses to send and receives until ctx is done
+// オフラインのインターフェースは送信を拒否し、受信はctxが終了するまで待つことをテストします
+func TestOfflineNetworkInterface(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("packemon-offline-test")
+	defer nwif.Close()
+
+	if !nwif.Offline() || nwif.Intf.Name != "packemon-offline-test" {
+		t.Fatalf("Offline() = %v, Intf = %+v", nwif.Offline(), nwif.Intf)
+	}
+	if err := nwif.SendEthernetFrame(context.Background(), []byte{0x00}); !errors.Is(err, ErrOffline) {
+		t.Errorf("SendEthernetFrame error = %v, want ErrOffline", err)
+	}
+	if err := nwif.SetReadTimeout(time.Second); err != nil {
+		t.Errorf("SetReadTimeout returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		nwif.ReceiveEthernetFrame(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ReceiveEthernetFrame should return when ctx is done")
+	}
+}
@@ -0,0 +1,64 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+)
+
+// TestSelectSourceAddress tests the source address selection for loopback and interface addresses
+// ループバックとインターフェースのアドレスに対する送信元アドレス選択をテストします
+func TestSelectSourceAddress(t *testing.T) {
+	nwif := &NetworkInterface{
+		IPAddr: 0xac184fcf, // 172.24.79.207
+		IPv6Addrs: []net.IP{
+			net.ParseIP("fe80::215:5dff:fefb:bf3a"),
+			net.ParseIP("fd00::10"),
+			net.ParseIP("2400:4051:1920:f800::10"),
+		},
+	}
+
+	tests := []struct {
+		dst  string
+		want string
+	}{
+		{"127.0.0.1", "127.0.0.1"},
+		{"::1", "::1"},
+		{"192.168.10.110", "172.24.79.207"},
+		{"fe80::1", "fe80::215:5dff:fefb:bf3a"},
+		{"ff02::1", "fe80::215:5dff:fefb:bf3a"},
+		{"fd00::1", "fd00::10"},
+		{"2001:db8::1", "2400:4051:1920:f800::10"},
+		{"ff0e::101", "2400:4051:1920:f800::10"},
+	}
+
+	for _, tt := range tests {
+		got, err := nwif.SelectSourceAddress(net.ParseIP(tt.dst))
+		if err != nil {
+			t.Errorf("SelectSourceAddress(%s) returned error: %v", tt.dst, err)
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("SelectSourceAddress(%s) = %s, want %s", tt.dst, got, tt.want)
+		}
+	}
+}
+
+// TestSelectSourceAddressFallback tests the fallback when no address of the same scope exists
+// 同じスコープのアドレスがない場合のフォールバックをテストします
+func TestSelectSourceAddressFallback(t *testing.T) {
+	nwif := &NetworkInterface{
+		IPv6Addr: net.ParseIP("fd00::10"),
+	}
+
+	got, err := nwif.SelectSourceAddress(net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatalf("SelectSourceAddress returned error: %v", err)
+	}
+	if !got.Equal(net.ParseIP("fd00::10")) {
+		t.Errorf("SelectSourceAddress(2001:db8::1) = %s, want %s", got, "fd00::10")
+	}
+
+	if _, err := nwif.SelectSourceAddress(net.ParseIP("192.168.10.110")); err == nil {
+		t.Errorf("SelectSourceAddress(192.168.10.110) should fail without IPv4 address")
+	}
+}
@@ -0,0 +1,191 @@
+package packemon
+
+// Coarse OS families guessed by GuessOS
+// GuessOSが推測するおおまかなOSの種類
+const (
+	OS_FAMILY_UNKNOWN        = "unknown"
+	OS_FAMILY_LINUX          = "Linux"
+	OS_FAMILY_MACOS_BSD      = "macOS/BSD"
+	OS_FAMILY_UNIX           = "Linux/Unix"
+	OS_FAMILY_WINDOWS        = "Windows"
+	OS_FAMILY_NETWORK_DEVICE = "Network device" // Cisco IOS、Solaris など
+	OS_FAMILY_LEGACY         = "Legacy"         // Windows 95/98 などの古い実装
+)
+
+// 一般的な OS が使う TTL / Hop Limit の初期値
+var initialTTLs = []uint8{32, 64, 128, 255}
+
+// OSHint is a heuristic guess of the sender's OS from its TTL and, for TCP SYNs, the window size and option order.
+// TTLs and SYN parameters are easy to change, so it is a hint, not an identification.
+// TTLと、TCP SYNの場合はウィンドウサイズとオプションの順序から送信元のOSを推測したものです。
+// これらの値は簡単に変更できるため、あくまでヒントであり特定ではありません
+type OSHint struct {
+	ObservedTTL uint8
+	InitialTTL  uint8 // 推定した送信時の TTL
+	Hops        int   // 推定したホップ数
+	Family      string
+	// FromTCPSYN reports whether a TCP SYN refined the guess
+	// TCP SYNで推測を絞り込んだかどうか
+	FromTCPSYN bool
+
+	// Signature is the p0f-style fingerprint of the TCP SYN (see TCPFingerprint), and Label and Confidence
+	// the entry of the signature database it matched. All are empty otherwise
+	// TCP SYNのp0f形式のフィンガープリント(TCPFingerprint参照)と、一致したシグネチャデータベースのエントリ。それ以外では空
+	Signature  string
+	Label      string
+	Confidence string
+}
+
+// InitialTTL returns the most likely TTL the packet was sent with: the smallest common initial TTL (32/64/128/255) not below observed
+// パケットが送信されたときの最も可能性が高いTTLを返します。observed以上で最小の一般的な初期値(32/64/128/255)です
+func InitialTTL(observed uint8) uint8 {
+	for _, ttl := range initialTTLs {
+		if observed <= ttl {
+			return ttl
+		}
+	}
+	return 255
+}
+
+// HopCount estimates how many routers the packet went through
+// パケットが経由したルーターの数を推定します
+func HopCount(observed uint8) int {
+	return int(InitialTTL(observed)) - int(observed)
+}
+
+// GuessOS returns a heuristic OS hint for the sender of an IPv4 or IPv6 packet
+// IPv4またはIPv6パケットの送信元のOSのヒントを返します
+func GuessOS(p *Passive) (OSHint, bool) {
+	var observed uint8
+	switch {
+	case p == nil:
+		return OSHint{}, false
+	case p.IPv4 != nil:
+		observed = p.IPv4.TTL
+	case p.IPv6 != nil:
+		observed = p.IPv6.HopLimit
+	default:
+		return OSHint{}, false
+	}
+
+	hint := OSHint{
+		ObservedTTL: observed,
+		InitialTTL:  InitialTTL(observed),
+		Hops:        HopCount(observed),
+	}
+	switch hint.InitialTTL {
+	case 32:
+		hint.Family = OS_FAMILY_LEGACY
+	case 64:
+		hint.Family = OS_FAMILY_UNIX
+	case 128:
+		hint.Family = OS_FAMILY_WINDOWS
+	case 255:
+		hint.Family = OS_FAMILY_NETWORK_DEVICE
+	default:
+		hint.Family = OS_FAMILY_UNKNOWN
+	}
+
+	// SYN のウィンドウサイズとオプションの並びは OS ごとに特徴がある
+	if p.TCP != nil && p.TCP.Flags&TCP_FLAGS_SYN != 0 {
+		if family := guessOSFromSYN(hint.InitialTTL, p.TCP); family != "" {
+			hint.Family = family
+			hint.FromTCPSYN = true
+		}
+	}
+	if fingerprint, ok := NewTCPFingerprint(p); ok {
+		hint.Signature = fingerprint.String()
+		if match, ok := MatchTCPFingerprint(fingerprint); ok {
+			hint.Family = match.Family
+			hint.FromTCPSYN = true
+			hint.Label = match.Label
+			hint.Confidence = match.Confidence
+		}
+	}
+	return hint, true
+}
+
+// TCP オプションの kind
+const (
+	tcpOptionEOL       = 0
+	tcpOptionNOP       = 1
+	tcpOptionMSS       = 2
+	tcpOptionWS        = 3
+	tcpOptionSACKPerm  = 4
+	tcpOptionSACK      = 5
+	tcpOptionTimestamp = 8
+)
+
+// 代表的な SYN のオプションの並び
+var (
+	synOptionsLinux   = []uint8{tcpOptionMSS, tcpOptionSACKPerm, tcpOptionTimestamp, tcpOptionNOP, tcpOptionWS}
+	synOptionsMacOS   = []uint8{tcpOptionMSS, tcpOptionNOP, tcpOptionWS, tcpOptionNOP, tcpOptionNOP, tcpOptionTimestamp, tcpOptionSACKPerm}
+	synOptionsWindows = []uint8{tcpOptionMSS, tcpOptionNOP, tcpOptionWS, tcpOptionNOP, tcpOptionNOP, tcpOptionSACKPerm}
+)
+
+func guessOSFromSYN(initialTTL uint8, tcp *TCPPacket) string {
+	kinds := tcpOptionKinds(tcp.Options)
+	switch {
+	case initialTTL == 64 && hasOptionPrefix(kinds, synOptionsLinux):
+		return OS_FAMILY_LINUX
+	case initialTTL == 64 && hasOptionPrefix(kinds, synOptionsMacOS) && tcp.Window == 65535:
+		return OS_FAMILY_MACOS_BSD
+	case initialTTL == 128 && hasOptionPrefix(kinds, synOptionsWindows):
+		return OS_FAMILY_WINDOWS
+	}
+	return ""
+}
+
+// オプションの kind を並び順のまま返す. 壊れていたらそこまで
+func tcpOptionKinds(options []byte) []uint8 {
+	kinds := []uint8{}
+	for i := 0; i < len(options); {
+		kind := options[i]
+		kinds = append(kinds, kind)
+		if kind == tcpOptionEOL {
+			break
+		}
+		if kind == tcpOptionNOP {
+			i++
+			continue
+		}
+		if i+1 >= len(options) || options[i+1] < 2 {
+			break
+		}
+		i += int(options[i+1])
+	}
+	return kinds
+}
+
+// kind のオプションのうち最初のもののデータ(kind と長さを除く). 壊れていたらそこまで
+func tcpOption(options []byte, kind uint8) ([]byte, bool) {
+	for i := 0; i < len(options); {
+		if options[i] == tcpOptionEOL {
+			break
+		}
+		if options[i] == tcpOptionNOP {
+			i++
+			continue
+		}
+		if i+1 >= len(options) || options[i+1] < 2 || i+int(options[i+1]) > len(options) {
+			break
+		}
+		if options[i] == kind {
+			return options[i+2 : i+int(options[i+1])], true
+		}
+		i += int(options[i+1])
+	}
+	return nil, false
+}
+
+func hasOptionPrefix(kinds, prefix []uint8) bool {
+	if len(kinds) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if kinds[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package packemon
+
+import "testing"
+
+func TestInitialTTLAndHopCount(t *testing.T) {
+	tests := []struct {
+		observed   uint8
+		wantTTL    uint8
+		wantHops   int
+		wantFamily string
+	}{
+		{observed: 1, wantTTL: 32, wantHops: 31, wantFamily: OS_FAMILY_LEGACY},
+		{observed: 32, wantTTL: 32, wantHops: 0, wantFamily: OS_FAMILY_LEGACY},
+		{observed: 33, wantTTL: 64, wantHops: 31, wantFamily: OS_FAMILY_UNIX},
+		{observed: 52, wantTTL: 64, wantHops: 12, wantFamily: OS_FAMILY_UNIX},
+		{observed: 64, wantTTL: 64, wantHops: 0, wantFamily: OS_FAMILY_UNIX},
+		{observed: 117, wantTTL: 128, wantHops: 11, wantFamily: OS_FAMILY_WINDOWS},
+		{observed: 128, wantTTL: 128, wantHops: 0, wantFamily: OS_FAMILY_WINDOWS},
+		{observed: 129, wantTTL: 255, wantHops: 126, wantFamily: OS_FAMILY_NETWORK_DEVICE},
+		{observed: 247, wantTTL: 255, wantHops: 8, wantFamily: OS_FAMILY_NETWORK_DEVICE},
+		{observed: 255, wantTTL: 255, wantHops: 0, wantFamily: OS_FAMILY_NETWORK_DEVICE},
+	}
+
+	for _, tt := range tests {
+		if got := InitialTTL(tt.observed); got != tt.wantTTL {
+			t.Errorf("InitialTTL(%d) = %d, want %d", tt.observed, got, tt.wantTTL)
+		}
+		if got := HopCount(tt.observed); got != tt.wantHops {
+			t.Errorf("HopCount(%d) = %d, want %d", tt.observed, got, tt.wantHops)
+		}
+		hint, ok := GuessOS(&Passive{IPv4: &IPv4Packet{TTL: tt.observed}})
+		if !ok || hint.Family != tt.wantFamily || hint.FromTCPSYN {
+			t.Errorf("GuessOS(TTL %d) = %+v, want family %s", tt.observed, hint, tt.wantFamily)
+		}
+	}
+}
+
+// TestGuessOSFromSYN tests that the SYN option order refines the TTL based guess
+// SYNのオプションの順序でTTLによる推測が絞り込まれることをテストします
+func TestGuessOSFromSYN(t *testing.T) {
+	linuxOptions := []byte{0x02, 0x04, 0x05, 0xb4, 0x04, 0x02, 0x08, 0x0a, 0, 0, 0, 1, 0, 0, 0, 0, 0x01, 0x03, 0x03, 0x07}
+	macOSOptions := []byte{0x02, 0x04, 0x05, 0xb4, 0x01, 0x03, 0x03, 0x06, 0x01, 0x01, 0x08, 0x0a, 0, 0, 0, 1, 0, 0, 0, 0, 0x04, 0x02, 0x00, 0x00}
+	windowsOptions := []byte{0x02, 0x04, 0x05, 0xb4, 0x01, 0x03, 0x03, 0x08, 0x01, 0x01, 0x04, 0x02}
+
+	tests := []struct {
+		name       string
+		ttl        uint8
+		flags      uint8
+		window     uint16
+		options    []byte
+		wantFamily string
+		wantSYN    bool
+	}{
+		{name: "linux", ttl: 58, flags: TCP_FLAGS_SYN, window: 64240, options: linuxOptions, wantFamily: OS_FAMILY_LINUX, wantSYN: true},
+		{name: "macos", ttl: 60, flags: TCP_FLAGS_SYN, window: 65535, options: macOSOptions, wantFamily: OS_FAMILY_MACOS_BSD, wantSYN: true},
+		{name: "windows", ttl: 120, flags: TCP_FLAGS_SYN, window: 64240, options: windowsOptions, wantFamily: OS_FAMILY_WINDOWS, wantSYN: true},
+		// TTL と SYN の特徴が食い違う場合は TTL のみで判断する
+		{name: "linux options with windows ttl", ttl: 120, flags: TCP_FLAGS_SYN, window: 64240, options: linuxOptions, wantFamily: OS_FAMILY_WINDOWS},
+		// SYN 以外のオプションは見ない
+		{name: "not syn", ttl: 58, flags: TCP_FLAGS_ACK, window: 502, options: linuxOptions, wantFamily: OS_FAMILY_UNIX},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hint, ok := GuessOS(&Passive{
+				IPv4: &IPv4Packet{TTL: tt.ttl},
+				TCP:  &TCPPacket{Flags: tt.flags, Window: tt.window, Options: tt.options},
+			})
+			if !ok {
+				t.Fatal("GuessOS() should return a hint for an IPv4 packet")
+			}
+			if hint.Family != tt.wantFamily || hint.FromTCPSYN != tt.wantSYN {
+				t.Errorf("GuessOS() = %+v, want family %s (from SYN: %v)", hint, tt.wantFamily, tt.wantSYN)
+			}
+		})
+	}
+
+	if _, ok := GuessOS(&Passive{ARP: &ARPPacket{}}); ok {
+		t.Error("GuessOS() should not return a hint without IP")
+	}
+}
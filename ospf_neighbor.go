@@ -0,0 +1,192 @@
+package packemon
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OSPFRouterKey identifies an OSPF router sending Hellos: its router ID, area and the interface the Hellos were captured on
+// Helloを送るOSPFルーターを識別します。ルーターID、エリア、Helloをキャプチャしたインターフェースの組です
+type OSPFRouterKey struct {
+	RouterID  uint32
+	AreaID    uint32
+	Interface string
+}
+
+// OSPFNeighborState is a neighbor listed in the Hellos of a router
+// ルーターのHelloに載っている隣接ルーターです
+type OSPFNeighborState struct {
+	Router OSPFRouterKey
+	// Source is the address the router sends its Hellos from
+	// ルーターがHelloを送信する送信元アドレス
+	Source   net.IP
+	Neighbor uint32
+	// LastSeen is when the router last listed the neighbor in a Hello
+	// ルーターが最後にHelloに隣接ルーターを載せた時刻
+	LastSeen time.Time
+	// DeadInterval is the RouterDeadInterval the router advertises
+	// ルーターが通知しているRouterDeadInterval
+	DeadInterval time.Duration
+}
+
+// OSPFNeighborDrop is a neighbor no longer listed in the Hellos of a router past the dead interval, or a router that stopped sending Hellos,
+// i.e. a lost adjacency
+// デッド間隔を過ぎてもルーターのHelloに載らなくなった隣接ルーター、またはHelloを送らなくなったルーターです。隣接関係が失われたことを表します
+type OSPFNeighborDrop struct {
+	OSPFNeighborState
+	// RouterSilent reports that the router itself sent no Hello for the dead interval
+	// ルーター自身がデッド間隔の間Helloを送らなかったことを表す
+	RouterSilent bool
+	Time         time.Time
+}
+
+type ospfRouter struct {
+	source       net.IP
+	deadInterval time.Duration
+	lastHello    time.Time
+	neighbors    map[uint32]time.Time // 隣接ルーターID -> 最後にHelloに載った時刻
+}
+
+// OSPFNeighborTracker passively follows OSPF adjacencies from Hellos, to debug adjacency flaps.
+// For each router it records the neighbors listed in its Hellos and reports those not listed again within the dead interval the router advertises
+// Helloから受動的にOSPFの隣接関係を追跡し、隣接関係のフラップの調査に使います。
+// ルーターごとにHelloに載っている隣接ルーターを記録し、ルーターが通知するデッド間隔のうちに再び載らなかったものを報告します
+type OSPFNeighborTracker struct {
+	mu      sync.Mutex
+	routers map[OSPFRouterKey]*ospfRouter
+	// now は観測した最新の時刻. キャプチャファイルの再生でも期限切れを判定できるよう、現在時刻ではなくこれを使う
+	now time.Time
+}
+
+// NewOSPFNeighborTracker creates an empty tracker
+// 空のトラッカーを作成します
+func NewOSPFNeighborTracker() *OSPFNeighborTracker {
+	return &OSPFNeighborTracker{
+		routers: make(map[OSPFRouterKey]*ospfRouter),
+	}
+}
+
+// Update records the OSPF Hello in a packet and returns the neighbors dropped since the last update. Other packets only advance the time
+// パケットのOSPF Helloを記録し、前回の更新以降に失われた隣接ルーターを返します。それ以外のパケットは時刻を進めるだけです
+func (t *OSPFNeighborTracker) Update(p *Passive) []OSPFNeighborDrop {
+	if p == nil {
+		return nil
+	}
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var hello *OSPFHello
+	var ospf *OSPF
+	if p.IPv4 != nil && p.IPv4.Protocol == IPv4_PROTO_OSPF {
+		ospf = ParsedOSPF(p.IPv4.Payload)
+		hello = ParsedOSPFHello(ospf)
+	}
+	if hello == nil {
+		return t.Expire(now)
+	}
+	key := OSPFRouterKey{RouterID: ospf.RouterID, AreaID: ospf.AreaID, Interface: p.Interface}
+	return t.Observe(key, net.IP(p.IPv4.SrcIP), hello, now)
+}
+
+// Observe records a Hello sent by a router from source and returns the neighbors dropped up to now
+// sourceからルーターが送ったHelloを記録し、nowまでに失われた隣接ルーターを返します
+func (t *OSPFNeighborTracker) Observe(key OSPFRouterKey, source net.IP, hello *OSPFHello, now time.Time) []OSPFNeighborDrop {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	router, ok := t.routers[key]
+	if !ok {
+		router = &ospfRouter{neighbors: make(map[uint32]time.Time)}
+		t.routers[key] = router
+	}
+	// 受信バッファは再利用されることがあるため、保持するアドレスはコピーする
+	router.source = append(net.IP(nil), source...)
+	router.deadInterval = time.Duration(hello.RouterDeadInterval) * time.Second
+	router.lastHello = now
+	for _, neighbor := range hello.Neighbors {
+		router.neighbors[neighbor] = now
+	}
+	return t.expire(now)
+}
+
+// Expire returns the neighbors dropped up to now without a new Hello, e.g. called periodically on a quiet link
+// 新しいHelloが無くても、nowまでに失われた隣接ルーターを返します。通信の少ないリンクで定期的に呼び出すなどに使います
+func (t *OSPFNeighborTracker) Expire(now time.Time) []OSPFNeighborDrop {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.expire(now)
+}
+
+func (t *OSPFNeighborTracker) expire(now time.Time) []OSPFNeighborDrop {
+	if now.After(t.now) {
+		t.now = now
+	}
+	var drops []OSPFNeighborDrop
+	for key, router := range t.routers {
+		silent := t.now.Sub(router.lastHello) > router.deadInterval
+		for neighbor, lastSeen := range router.neighbors {
+			if !silent && t.now.Sub(lastSeen) <= router.deadInterval {
+				continue
+			}
+			drops = append(drops, OSPFNeighborDrop{
+				OSPFNeighborState: router.state(key, neighbor, lastSeen),
+				RouterSilent:      silent,
+				Time:              t.now,
+			})
+			delete(router.neighbors, neighbor)
+		}
+		if silent {
+			delete(t.routers, key)
+		}
+	}
+	sortOSPFNeighbors(drops, func(i int) OSPFNeighborState { return drops[i].OSPFNeighborState })
+	for _, drop := range drops {
+		logOSPFNeighborDrop(drop)
+	}
+	return drops
+}
+
+func (r *ospfRouter) state(key OSPFRouterKey, neighbor uint32, lastSeen time.Time) OSPFNeighborState {
+	return OSPFNeighborState{
+		Router:       key,
+		Source:       r.source,
+		Neighbor:     neighbor,
+		LastSeen:     lastSeen,
+		DeadInterval: r.deadInterval,
+	}
+}
+
+// Neighbors returns the neighbors currently listed by each router, ordered by router and neighbor ID
+// 各ルーターが現在載せている隣接ルーターを、ルーターIDと隣接ルーターIDの順に返します
+func (t *OSPFNeighborTracker) Neighbors() []OSPFNeighborState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	states := []OSPFNeighborState{}
+	for key, router := range t.routers {
+		for neighbor, lastSeen := range router.neighbors {
+			states = append(states, router.state(key, neighbor, lastSeen))
+		}
+	}
+	sortOSPFNeighbors(states, func(i int) OSPFNeighborState { return states[i] })
+	return states
+}
+
+func sortOSPFNeighbors[T any](s []T, state func(i int) OSPFNeighborState) {
+	sort.Slice(s, func(i, j int) bool {
+		a, b := state(i), state(j)
+		switch {
+		case a.Router.RouterID != b.Router.RouterID:
+			return a.Router.RouterID < b.Router.RouterID
+		case a.Router.AreaID != b.Router.AreaID:
+			return a.Router.AreaID < b.Router.AreaID
+		case a.Router.Interface != b.Router.Interface:
+			return a.Router.Interface < b.Router.Interface
+		}
+		return a.Neighbor < b.Neighbor
+	})
+}
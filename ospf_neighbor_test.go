@@ -0,0 +1,72 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func ospfHelloPassive(at time.Time, routerID uint32, deadInterval uint32, neighbors []uint32) *Passive {
+	hello := NewOSPFHello(routerID, 0, 0xffffff00, 10, 0x02, 1, deadInterval, 0, 0, neighbors)
+	return &Passive{
+		Timestamp: at,
+		Interface: "eth0",
+		IPv4: &IPv4Packet{
+			Protocol: IPv4_PROTO_OSPF,
+			SrcIP:    []byte{192, 0, 2, 1},
+			Payload:  hello.Bytes(),
+		},
+	}
+}
+
+// TestOSPFNeighborTracker tests that a neighbor missing from the Hellos of a router past the dead interval is reported as dropped
+// デッド間隔を過ぎてもルーターのHelloに載らない隣接ルーターが失われたと報告されることをテストします
+func TestOSPFNeighborTracker(t *testing.T) {
+	const (
+		router    uint32 = 0x01010101
+		neighbor1 uint32 = 0x02020202
+		neighbor2 uint32 = 0x03030303
+	)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewOSPFNeighborTracker()
+
+	if drops := tracker.Update(ospfHelloPassive(start, router, 40, []uint32{neighbor1, neighbor2})); len(drops) != 0 {
+		t.Fatalf("first Hello: drops = %+v", drops)
+	}
+	got := tracker.Neighbors()
+	if len(got) != 2 || got[0].Neighbor != neighbor1 || got[1].Neighbor != neighbor2 {
+		t.Fatalf("Neighbors() = %+v", got)
+	}
+	if !got[0].Source.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("Source = %s", got[0].Source)
+	}
+
+	// neighbor2 はデッド間隔内はまだ失われていない
+	if drops := tracker.Update(ospfHelloPassive(start.Add(30*time.Second), router, 40, []uint32{neighbor1})); len(drops) != 0 {
+		t.Fatalf("Hello within the dead interval: drops = %+v", drops)
+	}
+
+	drops := tracker.Update(ospfHelloPassive(start.Add(50*time.Second), router, 40, []uint32{neighbor1}))
+	if len(drops) != 1 {
+		t.Fatalf("drops = %+v, want neighbor2", drops)
+	}
+	drop := drops[0]
+	if drop.Neighbor != neighbor2 || drop.Router.RouterID != router || drop.Router.Interface != "eth0" || drop.RouterSilent {
+		t.Errorf("drop = %+v", drop)
+	}
+	if !drop.LastSeen.Equal(start) || drop.DeadInterval != 40*time.Second {
+		t.Errorf("LastSeen = %s, DeadInterval = %s", drop.LastSeen, drop.DeadInterval)
+	}
+	if got := tracker.Neighbors(); len(got) != 1 || got[0].Neighbor != neighbor1 {
+		t.Errorf("Neighbors() = %+v, want only neighbor1", got)
+	}
+
+	// ルーター自身がHelloを送らなくなると、残りの隣接ルーターも失われる
+	drops = tracker.Expire(start.Add(100 * time.Second))
+	if len(drops) != 1 || drops[0].Neighbor != neighbor1 || !drops[0].RouterSilent {
+		t.Errorf("Expire() = %+v, want neighbor1 with a silent router", drops)
+	}
+	if got := tracker.Neighbors(); len(got) != 0 {
+		t.Errorf("Neighbors() = %+v, want none", got)
+	}
+}
@@ -0,0 +1,186 @@
+// Package packemontest provides helpers for tests that build packets with packemon.
+// It is a separate package so that the testing package is not linked into programs using packemon.
+// packemonでパケットを作成するテストのためのヘルパーです。
+// packemonを使うプログラムにtestingパッケージがリンクされないよう、別のパッケージにしています
+package packemontest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/ddddddO/packemon"
+)
+
+// PacketTemplate is the packet a test expects
+// テストが期待するパケットです
+type PacketTemplate struct {
+	// Frame is the expected Ethernet frame
+	// 期待するEthernetフレーム
+	Frame []byte
+
+	// IgnoreChecksums skips checksum fields, e.g. for a template written by hand without computing them
+	// チェックサムのフィールドを比較しない。チェックサムを計算せずに手で書いたテンプレートなどで使う
+	IgnoreChecksums bool
+
+	// IgnoreFields are fields not compared, named as in the differences reported, e.g. "IPv4.ID" or "TCP.SeqNum"
+	// 比較しないフィールド。報告される差分と同じ名前で指定する(例: "IPv4.ID"、"TCP.SeqNum")
+	IgnoreFields []string
+}
+
+// layers are the fields of packemon.Passive holding a decoded layer, from the lowest
+// 解析したレイヤを保持するpackemon.Passiveのフィールド(下位のレイヤから順)
+var layers = []string{"EthernetFrame", "IEEE80211", "ARP", "IPv4", "IPv6", "ICMP", "ICMPv6", "TCP", "UDP", "TLS", "DNS", "HTTP", "HTTPRes", "RTP", "GENEVE", "SMB"}
+
+// Diff decodes got and the template frame and returns their field-level differences, e.g. "IPv4.TTL: got 63, want 64".
+// Payloads are compared only at the highest layer decoded, so a difference is reported once at the field it is in.
+// gotとテンプレートのフレームを解析し、フィールド単位の差分を返します(例: "IPv4.TTL: got 63, want 64")。
+// ペイロードは解析できた最も上位のレイヤでのみ比較するため、差分はそれを含むフィールドで1回だけ報告されます
+func Diff(got []byte, expected PacketTemplate) ([]string, error) {
+	gotPassive, err := packemon.DecodeFrame(got)
+	if err != nil {
+		return nil, fmt.Errorf("decode got: %w", err)
+	}
+	wantPassive, err := packemon.DecodeFrame(expected.Frame)
+	if err != nil {
+		return nil, fmt.Errorf("decode template: %w", err)
+	}
+
+	d := &differ{template: expected}
+	d.passive("", reflect.ValueOf(gotPassive).Elem(), reflect.ValueOf(wantPassive).Elem())
+	return d.diffs, nil
+}
+
+// AssertPacket reports each field-level difference between got and expected as a test error
+// gotとexpectedのフィールド単位の差分をそれぞれテストのエラーとして報告します
+func AssertPacket(t testing.TB, got []byte, expected PacketTemplate) {
+	t.Helper()
+
+	diffs, err := Diff(got, expected)
+	if err != nil {
+		t.Errorf("AssertPacket: %v", err)
+		return
+	}
+	for _, diff := range diffs {
+		t.Errorf("packet differs: %s", diff)
+	}
+}
+
+type differ struct {
+	template PacketTemplate
+	diffs    []string
+}
+
+func (d *differ) report(path string, format string, args ...any) {
+	d.diffs = append(d.diffs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// passive compares the layers of two packemon.Passive
+func (d *differ) passive(prefix string, got, want reflect.Value) {
+	// どちらかで解析できた最も上位のレイヤ
+	highest := ""
+	for _, layer := range layers {
+		if !got.FieldByName(layer).IsNil() || !want.FieldByName(layer).IsNil() {
+			highest = layer
+		}
+	}
+
+	for _, layer := range layers {
+		d.value(prefix+layer, got.FieldByName(layer), want.FieldByName(layer), layer != highest)
+	}
+	// IP-in-IP などのトンネルの内側
+	d.value(prefix+"Inner", got.FieldByName("Inner"), want.FieldByName("Inner"), false)
+}
+
+func (d *differ) value(path string, got, want reflect.Value, skipPayload bool) {
+	if slices.Contains(d.template.IgnoreFields, path) {
+		return
+	}
+
+	switch got.Kind() {
+	case reflect.Pointer:
+		switch {
+		case got.IsNil() && want.IsNil():
+		case got.IsNil():
+			d.report(path, "got none, want %s", want.Elem().Type().Name())
+		case want.IsNil():
+			d.report(path, "got %s, want none", got.Elem().Type().Name())
+		case got.Elem().Type() == reflect.TypeOf(packemon.Passive{}):
+			d.passive(path+".", got.Elem(), want.Elem())
+		default:
+			d.value(path, got.Elem(), want.Elem(), skipPayload)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < got.NumField(); i++ {
+			field := got.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if skipPayload && (field.Name == "Payload" || field.Name == "Data") {
+				continue
+			}
+			if d.template.IgnoreChecksums && field.Name == "Checksum" {
+				continue
+			}
+			d.value(path+"."+field.Name, got.Field(i), want.Field(i), skipPayload)
+		}
+
+	case reflect.Slice:
+		if got.Type().Elem().Kind() == reflect.Uint8 {
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				d.report(path, "got %x, want %x", got.Bytes(), want.Bytes())
+			}
+			return
+		}
+		if got.Len() != want.Len() {
+			d.report(path, "got %d elements, want %d", got.Len(), want.Len())
+			return
+		}
+		for i := 0; i < got.Len(); i++ {
+			d.value(fmt.Sprintf("%s[%d]", path, i), got.Index(i), want.Index(i), skipPayload)
+		}
+
+	default:
+		if !reflect.DeepEqual(got.Interface(), want.Interface()) {
+			d.report(path, "got %v, want %v", got.Interface(), want.Interface())
+		}
+	}
+}
+
+// AssertRoundTrip serializes the stack with packemon.SerializeStack, decodes the result and checks that every layer of the stack was decoded with valid checksums.
+// It returns the decoded packet for further checks, or nil if the stack could not be serialized or decoded
+// スタックをpackemon.SerializeStackでバイト列にして解析し直し、スタックの全レイヤが正しいチェックサムで解析されたかを確認します。
+// 続けて確認できるよう解析したパケットを返します。バイト列にできなかったり解析できなかった場合はnilを返します
+func AssertRoundTrip(t testing.TB, stack []packemon.Layer) *packemon.Passive {
+	t.Helper()
+
+	frame, err := packemon.SerializeStack(stack)
+	if err != nil {
+		t.Errorf("SerializeStack: %v", err)
+		return nil
+	}
+	passive, err := packemon.DecodeFrame(frame)
+	if err != nil {
+		t.Errorf("DecodeFrame: %v", err)
+		return nil
+	}
+
+	v := reflect.ValueOf(passive).Elem()
+	for i, layer := range stack {
+		// 各レイヤはそれより上位のレイヤをすべて含んだ、フレームの末尾になる
+		if !bytes.HasSuffix(frame, layer.Bytes()) {
+			t.Errorf("layer %d (%T) is not the end of the frame", i, layer)
+		}
+		name := reflect.Indirect(reflect.ValueOf(layer)).Type().Name()
+		if slices.Contains(layers, name) && v.FieldByName(name).IsNil() {
+			t.Errorf("layer %d (%T) was not decoded", i, layer)
+		}
+	}
+	if report := packemon.ValidatePacket(passive); !report.Valid() {
+		t.Errorf("invalid checksums:\n%s", report)
+	}
+	return passive
+}
@@ -0,0 +1,118 @@
+package packemontest
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/ddddddO/packemon"
+)
+
+// ICMP Echo Request のフレーム。ttl と IPv4 ヘッダのチェックサムを指定する
+func assertTestFrame(ttl byte, checksum uint16) []byte {
+	return []byte{
+		// Ethernet
+		0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x08, 0x00,
+		// IPv4
+		0x45, 0x00, 0x00, 0x20, 0x12, 0x34, 0x00, 0x00, ttl, 0x01, byte(checksum >> 8), byte(checksum),
+		192, 168, 10, 1, 192, 168, 10, 2,
+		// ICMP
+		0x08, 0x00, 0x73, 0x74, 0x12, 0x34, 0x00, 0x01, 'p', 'i', 'n', 'g',
+	}
+}
+
+// errorRecorder records the errors reported instead of failing the test
+type errorRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *errorRecorder) Helper() {}
+
+func (r *errorRecorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDiff(t *testing.T) {
+	template := assertTestFrame(64, 0xd1b9)
+	got := assertTestFrame(63, 0xd2b9)
+
+	tests := []struct {
+		name     string
+		got      []byte
+		expected PacketTemplate
+		want     []string
+	}{
+		{
+			name:     "identical",
+			got:      template,
+			expected: PacketTemplate{Frame: template},
+			want:     nil,
+		},
+		{
+			name:     "ttl and checksum differ",
+			got:      got,
+			expected: PacketTemplate{Frame: template},
+			want: []string{
+				"IPv4.TTL: got 63, want 64",
+				"IPv4.Checksum: got 53945, want 53689",
+			},
+		},
+		{
+			name:     "ignore checksums",
+			got:      got,
+			expected: PacketTemplate{Frame: template, IgnoreChecksums: true},
+			want:     []string{"IPv4.TTL: got 63, want 64"},
+		},
+		{
+			name:     "ignore fields",
+			got:      got,
+			expected: PacketTemplate{Frame: template, IgnoreChecksums: true, IgnoreFields: []string{"IPv4.TTL"}},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Diff(tt.got, tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(diffs, tt.want) {
+				t.Errorf("got %q, want %q", diffs, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssertPacket(t *testing.T) {
+	r := &errorRecorder{TB: t}
+	AssertPacket(r, assertTestFrame(63, 0xd2b9), PacketTemplate{Frame: assertTestFrame(64, 0xd1b9), IgnoreChecksums: true})
+
+	want := []string{"packet differs: IPv4.TTL: got 63, want 64"}
+	if !slices.Equal(r.errors, want) {
+		t.Errorf("got %q, want %q", r.errors, want)
+	}
+}
+
+func TestAssertRoundTrip(t *testing.T) {
+	src := packemon.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b}
+	dst := packemon.HardwareAddr{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a}
+	stack := []packemon.Layer{
+		packemon.NewEthernetFrame(dst, src, packemon.ETHER_TYPE_IPv6, nil),
+		packemon.NewIPv6(packemon.IPv6_NEXT_HEADER_UDP, net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::1")),
+		&packemon.UDP{SrcPort: 50000, DstPort: packemon.PORT_DNS},
+		packemon.NewDNSQuery("go.dev", packemon.DNS_QUERY_TYPE_AAAA),
+	}
+	passive := AssertRoundTrip(t, stack)
+	if passive == nil || passive.DNS == nil {
+		t.Fatalf("AssertRoundTrip() = %+v", passive)
+	}
+
+	// ペイロードを持たないレイヤの上にレイヤを積むとエラーになる
+	r := &errorRecorder{TB: t}
+	if passive := AssertRoundTrip(r, []packemon.Layer{packemon.NewDNSQuery("go.dev", packemon.DNS_QUERY_TYPE_A), packemon.Payload("x")}); passive != nil || len(r.errors) != 1 {
+		t.Errorf("got %v, %q", passive, r.errors)
+	}
+}
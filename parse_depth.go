@@ -0,0 +1,53 @@
+package packemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseDepth limits how far received frames are decoded. Stopping early trades detail for throughput at high packet rates.
+// The zero value PARSE_DEPTH_FULL decodes everything.
+// 受信したフレームをどのレイヤまで解析するかを表します。浅くするほど詳細は減りますが処理が軽くなります
+type ParseDepth int32
+
+const (
+	PARSE_DEPTH_FULL        ParseDepth = iota // すべて解析する(デフォルト)
+	PARSE_DEPTH_ETHERNET                      // Ethernet ヘッダーのみ
+	PARSE_DEPTH_NETWORK                       // ARP / IPv4 / IPv6 まで
+	PARSE_DEPTH_TRANSPORT                     // ICMP / ICMPv6 / TCP / UDP まで
+	PARSE_DEPTH_APPLICATION                   // DNS / HTTP / TLS / RTP まで
+)
+
+var parseDepthNames = map[ParseDepth]string{
+	PARSE_DEPTH_FULL:        "full",
+	PARSE_DEPTH_ETHERNET:    "ethernet",
+	PARSE_DEPTH_NETWORK:     "network",
+	PARSE_DEPTH_TRANSPORT:   "transport",
+	PARSE_DEPTH_APPLICATION: "application",
+}
+
+func (d ParseDepth) String() string {
+	if name, ok := parseDepthNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("ParseDepth(%d)", int32(d))
+}
+
+// includes reports whether layer should be decoded at this depth
+func (d ParseDepth) includes(layer ParseDepth) bool {
+	return d == PARSE_DEPTH_FULL || layer <= d
+}
+
+// ParseParseDepth parses a depth name such as "network" or "transport". An empty name is PARSE_DEPTH_FULL.
+// "network" や "transport" などの名前を解析します。空文字はPARSE_DEPTH_FULLになります
+func ParseParseDepth(name string) (ParseDepth, error) {
+	if name == "" {
+		return PARSE_DEPTH_FULL, nil
+	}
+	for depth, depthName := range parseDepthNames {
+		if strings.EqualFold(name, depthName) {
+			return depth, nil
+		}
+	}
+	return PARSE_DEPTH_FULL, fmt.Errorf("unsupported parse depth: %s", name)
+}
@@ -0,0 +1,89 @@
+package packemon
+
+import "testing"
+
+// IPv4 / TCP 80 の HTTP GET リクエストのフレーム
+func parseDepthTestFrame() []byte {
+	return testTCPFrame(80, []byte("GET / HTTP/1.1\r\nHost: example.com\r\nUser-Agent: packemon\r\nAccept: */*\r\n\r\n"))
+}
+
+func parseAtDepth(frame []byte, depth ParseDepth) *Passive {
+	passive := &Passive{
+		EthernetFrame: &EthernetFrame{
+			DstAddr: frame[0:6],
+			SrcAddr: frame[6:12],
+			Type:    ETHER_TYPE_IPv4,
+			Payload: frame[14:],
+		},
+		RawLength: len(frame),
+	}
+	parseEthernetPayload(passive, nil, depth)
+	return passive
+}
+
+// TestParseDepth tests that layers deeper than the parse depth are left nil
+// 解析の深さより上位のレイヤがnilのままであることをテストします
+func TestParseDepth(t *testing.T) {
+	frame := parseDepthTestFrame()
+
+	tests := []struct {
+		depth    ParseDepth
+		wantIPv4 bool
+		wantTCP  bool
+		wantHTTP bool
+	}{
+		{PARSE_DEPTH_ETHERNET, false, false, false},
+		{PARSE_DEPTH_NETWORK, true, false, false},
+		{PARSE_DEPTH_TRANSPORT, true, true, false},
+		{PARSE_DEPTH_APPLICATION, true, true, true},
+		{PARSE_DEPTH_FULL, true, true, true},
+	}
+
+	for _, tt := range tests {
+		passive := parseAtDepth(frame, tt.depth)
+		if (passive.IPv4 != nil) != tt.wantIPv4 || (passive.TCP != nil) != tt.wantTCP || (passive.HTTP != nil) != tt.wantHTTP {
+			t.Errorf("%s: IPv4 = %v, TCP = %v, HTTP = %v, want %v, %v, %v", tt.depth,
+				passive.IPv4 != nil, passive.TCP != nil, passive.HTTP != nil, tt.wantIPv4, tt.wantTCP, tt.wantHTTP)
+		}
+	}
+
+	// Transport では上位レイヤはすべて nil
+	passive := parseAtDepth(frame, PARSE_DEPTH_TRANSPORT)
+	if passive.HTTP != nil || passive.HTTPRes != nil || passive.TLS != nil || passive.DNS != nil || passive.RTP != nil {
+		t.Errorf("application layers should be nil at transport depth: %+v", passive)
+	}
+}
+
+// TestParseParseDepth tests parsing of depth names
+// 深さの名前の解析をテストします
+func TestParseParseDepth(t *testing.T) {
+	for _, depth := range []ParseDepth{PARSE_DEPTH_FULL, PARSE_DEPTH_ETHERNET, PARSE_DEPTH_NETWORK, PARSE_DEPTH_TRANSPORT, PARSE_DEPTH_APPLICATION} {
+		got, err := ParseParseDepth(depth.String())
+		if err != nil || got != depth {
+			t.Errorf("ParseParseDepth(%q) = %v, %v, want %v", depth.String(), got, err, depth)
+		}
+	}
+	if got, err := ParseParseDepth("Transport"); err != nil || got != PARSE_DEPTH_TRANSPORT {
+		t.Errorf("ParseParseDepth(Transport) = %v, %v", got, err)
+	}
+	if got, err := ParseParseDepth(""); err != nil || got != PARSE_DEPTH_FULL {
+		t.Errorf("ParseParseDepth(\"\") = %v, %v", got, err)
+	}
+	if _, err := ParseParseDepth("session"); err == nil {
+		t.Errorf("ParseParseDepth(session) should fail")
+	}
+}
+
+// BenchmarkParseDepth compares the cost of decoding an HTTP frame at each depth
+// HTTPのフレームを各深さで解析するコストを比較します
+func BenchmarkParseDepth(b *testing.B) {
+	frame := parseDepthTestFrame()
+	for _, depth := range []ParseDepth{PARSE_DEPTH_NETWORK, PARSE_DEPTH_TRANSPORT, PARSE_DEPTH_FULL} {
+		b.Run(depth.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				parseAtDepth(frame, depth)
+			}
+		})
+	}
+}
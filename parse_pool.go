@@ -0,0 +1,178 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// PARSE_WORKER_QUEUE_SIZE is how many captured frames a parse worker holds. Frames for a full worker are dropped like those for a full PassiveCh
+// 解析ワーカーが保持するキャプチャしたフレーム数です。いっぱいのワーカー宛てのフレームは、いっぱいのPassiveCh宛てと同様に捨てます
+const PARSE_WORKER_QUEUE_SIZE = 256
+
+// 受信ループが読み込んだだけの、解析前のフレーム
+type capturedFrame struct {
+	data       []byte
+	wireLength int
+	timestamp  time.Time
+}
+
+// parsePool parses captured frames on several goroutines. Frames of the same flow always go to the same worker,
+// so they are parsed and delivered in the order they were captured
+// キャプチャしたフレームを複数のgoroutineで解析します。同じフローのフレームは常に同じワーカーに渡すため、
+// キャプチャした順に解析され、届けられます
+type parsePool struct {
+	queues []chan capturedFrame
+	wg     sync.WaitGroup
+}
+
+func newParsePool(workers int, parse func(capturedFrame)) *parsePool {
+	p := &parsePool{queues: make([]chan capturedFrame, workers)}
+	for i := range p.queues {
+		queue := make(chan capturedFrame, PARSE_WORKER_QUEUE_SIZE)
+		p.queues[i] = queue
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for frame := range queue {
+				parse(frame)
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch passes a frame to the worker of its flow without blocking the receive loop
+// 受信ループを止めないよう、フレームをそのフローのワーカーに渡します
+func (p *parsePool) dispatch(frame capturedFrame) {
+	queue := p.queues[flowHash(frame.data)%uint32(len(p.queues))]
+	select {
+	case queue <- frame:
+	default:
+		logChannelDrop("parse worker", cap(queue))
+	}
+}
+
+// close waits for the workers to parse the frames already dispatched
+// 渡し済みのフレームをワーカーが解析し終えるまで待ちます
+func (p *parsePool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// flowHash hashes the 5-tuple of a raw Ethernet frame, or its MAC addresses if it is not TCP/UDP over IP.
+// Both directions of a flow get the same hash. Fragments are hashed without ports, which only the first fragment has.
+// The offsets are those of Ethernet, so frames of other link types must not be hashed
+// 生のEthernetフレームの5タプルのハッシュを返します。IP上のTCP/UDPでない場合はMACアドレスのハッシュです。
+// フローの両方向は同じハッシュになります。ポートは最初のフラグメントにしか無いため、フラグメントはポート無しでハッシュします。
+// オフセットはEthernetのものなので、他のリンクタイプのフレームをハッシュしてはいけません
+func flowHash(data []byte) uint32 {
+	if len(data) < 14 {
+		return 0
+	}
+
+	var proto byte
+	var src, dst, srcPort, dstPort []byte
+	switch binary.BigEndian.Uint16(data[12:14]) {
+	case ETHER_TYPE_IPv4:
+		if len(data) < 34 {
+			return 0
+		}
+		proto, src, dst = data[23], data[26:30], data[30:34]
+		fragmented := binary.BigEndian.Uint16(data[20:22])&0x3fff != 0 // MF フラグ または Fragment Offset
+		transport := 14 + int(data[14]&0x0f)*4
+		if !fragmented && (proto == IPv4_PROTO_TCP || proto == IPv4_PROTO_UDP) && len(data) >= transport+4 {
+			srcPort, dstPort = data[transport:transport+2], data[transport+2:transport+4]
+		}
+	case ETHER_TYPE_IPv6:
+		if len(data) < 54 {
+			return 0
+		}
+		proto, src, dst = data[20], data[22:38], data[38:54]
+		if (proto == IPv6_NEXT_HEADER_TCP || proto == IPv6_NEXT_HEADER_UDP) && len(data) >= 58 {
+			srcPort, dstPort = data[54:56], data[56:58]
+		}
+	default:
+		src, dst = data[6:12], data[0:6]
+	}
+
+	// 両方向で同じになるよう、小さい方の端点を先にする
+	if c := bytes.Compare(src, dst); c > 0 || (c == 0 && bytes.Compare(srcPort, dstPort) > 0) {
+		src, dst, srcPort, dstPort = dst, src, dstPort, srcPort
+	}
+	h := uint32(fnvOffset32)
+	for _, b := range [][]byte{{proto}, src, srcPort, dst, dstPort} {
+		for _, c := range b {
+			h ^= uint32(c)
+			h *= fnvPrime32
+		}
+	}
+	return h
+}
+
+// SetParseWorkers sets how many goroutines parse received frames. With 1, the default, the receive loop parses each frame itself.
+// With more, the receive loop only reads frames and the workers parse them in parallel. Packets of a flow keep their order,
+// but packets of different flows may arrive on PassiveCh out of capture order. Only Ethernet captures are split into flows,
+// so frames of other link types are always parsed on the receive loop. Takes effect when receiving starts.
+// 受信したフレームを解析するgoroutineの数を設定します。デフォルトの1では受信ループ自身が各フレームを解析します。
+// 2以上では受信ループはフレームを読み込むだけで、ワーカーが並列に解析します。フローごとのパケットの順序は保たれますが、
+// 異なるフローのパケットはキャプチャ順とは前後してPassiveChに届くことがあります。
+// フローに分けられるのはEthernetのキャプチャのみのため、他のリンクタイプのフレームは常に受信ループで解析します。受信開始時に反映されます
+func (nwif *NetworkInterface) SetParseWorkers(n int) {
+	nwif.parseWorkers.Store(int32(max(n, 1)))
+}
+
+// ParseWorkers returns the number of goroutines parsing received frames
+// 受信したフレームを解析するgoroutineの数を返します
+func (nwif *NetworkInterface) ParseWorkers() int {
+	return max(int(nwif.parseWorkers.Load()), 1)
+}
+
+// captureHandler returns the function the receive loop passes captured frames to, and stop to call when the loop ends
+// 受信ループがキャプチャしたフレームを渡す関数と、ループの終了時に呼ぶstopを返します
+func (nwif *NetworkInterface) captureHandler() (handle func(capturedFrame), stop func()) {
+	parse := func(frame capturedFrame) {
+		passive, err := nwif.decodeCapturedFrame(frame.data, frame.wireLength, frame.timestamp)
+		if err != nil {
+			return
+		}
+
+		select {
+		case nwif.PassiveCh <- passive:
+		default:
+			// Channel is full, discard packet
+			logChannelDrop("PassiveCh", cap(nwif.PassiveCh))
+		}
+	}
+
+	handle, stop = parse, func() {}
+	// flowHash は Ethernet のフレームしか扱えない
+	if workers := nwif.ParseWorkers(); workers > 1 && nwif.LinkType() == PCAP_LINKTYPE_ETHERNET {
+		pool := newParsePool(workers, parse)
+		handle, stop = pool.dispatch, pool.close
+	}
+
+	// 重複は解析する前に捨てる. dedupKey も Ethernet のフレームしか扱えない
+	if dedup := nwif.deduplicator.Load(); dedup != nil {
+		key := dedupKey
+		if nwif.LinkType() != PCAP_LINKTYPE_ETHERNET {
+			key = rawDedupKey
+		}
+		next := handle
+		handle = func(frame capturedFrame) {
+			if dedup.duplicate(key(frame.data), frame.timestamp) {
+				return
+			}
+			next(frame)
+		}
+	}
+	return handle, stop
+}
@@ -0,0 +1,138 @@
+package packemon
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParseWorkersFlowOrder tests that parse workers deliver the packets of each flow in capture order,
+// and that both directions of a flow go to the same worker
+// 解析ワーカーが各フローのパケットをキャプチャ順に届けること、およびフローの両方向が同じワーカーに渡ることをテストします
+func TestParseWorkersFlowOrder(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("packemon-test0")
+	nwif.SetParseWorkers(4)
+	handle, stop := nwif.captureHandler()
+
+	// 4 つのフローのフレームを交互に渡す. ペイロードはフロー内の順番
+	const flows, perFlow = 4, 20
+	now := time.Now()
+	for seq := 0; seq < perFlow; seq++ {
+		for flow := 0; flow < flows; flow++ {
+			frame := decodeStatsTestUDPFrame(uint16(40000+flow), 9999, []byte{byte(seq)})
+			handle(capturedFrame{data: frame, wireLength: len(frame), timestamp: now})
+		}
+	}
+	stop()
+
+	if got := len(nwif.PassiveCh); got != flows*perFlow {
+		t.Fatalf("%d packets delivered, want %d", got, flows*perFlow)
+	}
+	next := map[uint16]byte{}
+	for i := 0; i < flows*perFlow; i++ {
+		passive := <-nwif.PassiveCh
+		port := passive.UDP.SrcPort
+		if seq := passive.UDP.Payload[0]; seq != next[port] {
+			t.Fatalf("flow %d: got packet %d, want %d", port, seq, next[port])
+		}
+		next[port]++
+	}
+
+	// 送信元と宛先を入れ替えた応答も同じフロー
+	request := decodeStatsTestUDPFrame(40000, 9999, []byte("ping"))
+	reply := decodeStatsTestUDPFrame(9999, 40000, []byte("pong"))
+	copy(reply[26:30], request[30:34])
+	copy(reply[30:34], request[26:30])
+	if flowHash(request) != flowHash(reply) {
+		t.Error("the request and the reply of a flow have different hashes")
+	}
+	if flowHash(request) == flowHash(decodeStatsTestUDPFrame(40001, 9999, []byte("ping"))) {
+		t.Error("different flows have the same hash")
+	}
+}
+
+// TestParseWorkersLinkType tests that frames of a link type other than Ethernet are parsed on the receive loop in capture order,
+// as the flow hash only understands Ethernet
+// フローのハッシュはEthernetしか扱えないため、Ethernet以外のリンクタイプのフレームは受信ループでキャプチャ順に解析されることをテストします
+func TestParseWorkersLinkType(t *testing.T) {
+	nwif := NewOfflineNetworkInterface("packemon-test0")
+	nwif.SetParseWorkers(4)
+	if err := nwif.SetLinkType(PCAP_LINKTYPE_IEEE802_11_RADIOTAP); err != nil {
+		t.Fatal(err)
+	}
+	handle, stop := nwif.captureHandler()
+	defer stop()
+
+	now := time.Now()
+	for seq := 0; seq < 20; seq++ {
+		// radiotap 16 バイトの後の Sequence Control
+		frame := ieee80211TestBeacon()
+		frame[16+22], frame[16+23] = byte(seq<<4), byte(seq>>4)
+		handle(capturedFrame{data: frame, wireLength: len(frame), timestamp: now})
+
+		// ワーカーに渡さず、その場で解析して届ける
+		if got := len(nwif.PassiveCh); got != 1 {
+			t.Fatalf("frame %d: %d packets delivered by the handler, want 1", seq, got)
+		}
+		passive := <-nwif.PassiveCh
+		if passive.IEEE80211 == nil || passive.IEEE80211.SequenceNumber != uint16(seq) {
+			t.Fatalf("frame %d: IEEE80211 = %+v", seq, passive.IEEE80211)
+		}
+	}
+}
+
+// BenchmarkParseWorkers compares parsing received frames on the receive loop with parsing them on a worker per CPU
+// 受信したフレームを受信ループで解析する場合と、CPUごとのワーカーで解析する場合を比較します
+func BenchmarkParseWorkers(b *testing.B) {
+	frames := make([][]byte, 64)
+	for i := range frames {
+		frames[i] = decodeStatsTestUDPFrame(uint16(40000+i), PORT_DNS, dnsTestMessage(uint16(i), false, "example.com", nil, nil))
+	}
+
+	for _, bm := range []struct {
+		name    string
+		workers int
+	}{
+		{name: "single", workers: 1},
+		{name: "pooled", workers: runtime.NumCPU()},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			nwif := NewOfflineNetworkInterface("packemon-test0")
+			nwif.SetParseWorkers(bm.workers)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var consumer sync.WaitGroup
+			consumer.Add(1)
+			go func() {
+				defer consumer.Done()
+				for {
+					select {
+					case <-ctx.Done():
+						// 解析し終えて残っている分も受け取る
+						for len(nwif.PassiveCh) > 0 {
+							<-nwif.PassiveCh
+						}
+						return
+					case <-nwif.PassiveCh:
+					}
+				}
+			}()
+
+			handle, stop := nwif.captureHandler()
+			now := time.Now()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				frame := frames[i%len(frames)]
+				handle(capturedFrame{data: frame, wireLength: len(frame), timestamp: now})
+			}
+			// 全てのフレームが解析され、受け取られるまでを計測する
+			stop()
+			cancel()
+			consumer.Wait()
+			b.StopTimer()
+		})
+	}
+}
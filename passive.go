@@ -3,9 +3,13 @@
 package packemon
 
 import (
+	"crypto/x509"
 	"encoding/binary"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Passive represents a parsed packet with all layers
@@ -22,6 +26,65 @@ type Passive struct {
 	DNS           *DNSPacket
 	HTTP          *HTTPRequest
 	HTTPRes       *HTTPResponse
+	RTP           *RTP
+	GENEVE        *GENEVE
+	SMB           *SMB
+
+	// IEEE80211 is the 802.11 frame of a Wi-Fi capture (see SetLinkType). For a data frame carrying a packet,
+	// EthernetFrame holds its addresses and payload as if the packet were wired
+	// Wi-Fiのキャプチャ(SetLinkType参照)の802.11フレーム。パケットを運ぶデータフレームでは、
+	// 有線で運ばれたものとしてEthernetFrameにそのアドレスとペイロードが入る
+	IEEE80211 *IEEE80211Frame
+
+	// Inner is the packet carried in an IP-in-IP tunnel (IPv4 protocol 4 or 41)
+	// IP-in-IPトンネル(IPv4のプロトコル4または41)で運ばれるパケット
+	Inner *Passive
+
+	// RawLength is the length of the captured frame. 0 for synthetic packets
+	// キャプチャしたフレームの長さ。生成したパケットの場合は0
+	RawLength int
+	// WireLength is the length of the frame on the wire. It exceeds RawLength when the capture was cut by the snap length. 0 if unknown
+	// 回線上でのフレームの長さ。スナップ長で切り詰められた場合はRawLengthより大きくなる。不明な場合は0
+	WireLength int
+
+	// Raw is the captured frame as received, so it can be exported or re-injected without re-serialization. The layers slice into it.
+	// It is owned by the Passive: the capture loop copies each frame out of its receive buffer, so Raw stays valid after later frames
+	// are received. Do not modify it or return it to a BytesPool while the Passive is in use.
+	// For captures without a link layer and packets carried in a tunnel, it starts at the IP header or the inner Ethernet header
+	// 受信したままのキャプチャしたフレーム。再シリアライズせずにエクスポートや再送信ができる。各レイヤはこれを参照している。
+	// Passiveが所有する。受信ループは受信バッファから各フレームをコピーするため、以降のフレームを受信した後も有効。
+	// Passiveを使っている間は変更したりBytesPoolに戻したりしないこと。
+	// リンク層の無いキャプチャやトンネルで運ばれたパケットでは、IPヘッダまたは内側のEthernetヘッダから始まる
+	Raw []byte
+
+	// Timestamp is when the frame was captured and Interface the name of the interface it was captured on. Both are zero for decoded byte slices
+	// フレームをキャプチャした時刻とインターフェース名。バイト列からデコードした場合はゼロ値
+	Timestamp time.Time
+	Interface string
+
+	// Truncated reports that the captured data ends before the packet does. PartialLayers lists the layers that were cut off
+	// キャプチャしたデータがパケットの途中で終わっていることを表す。PartialLayersは途中で切れていたレイヤ
+	Truncated     bool
+	PartialLayers []string
+
+	// DNSMessages lists every DNS message of a DNS over TCP segment carrying more than one, DNS being the first.
+	// DNSIncomplete reports that the segment ends inside a DNS message continuing in the next segments (see SetDNSOverTCPReassembly)
+	// 複数のDNSメッセージを運ぶDNS over TCPのセグメントのすべてのメッセージ。DNSは最初のメッセージ。
+	// DNSIncompleteはセグメントが次のセグメントに続くDNSメッセージの途中で終わっていることを表す(SetDNSOverTCPReassembly参照)
+	DNSMessages   []*DNSPacket
+	DNSIncomplete bool
+
+	// FCS is the Ethernet FCS stripped from the end of the frame when the capture includes it (see FCSMode), and FCSStatus whether it was valid.
+	// Raw and RawLength don't include it
+	// キャプチャにEthernetのFCSが含まれる場合(FCSMode参照)にフレーム末尾から取り除いたFCSと、それが正しかったかどうか。RawとRawLengthには含まれない
+	FCS       []byte
+	FCSStatus FCSStatus
+
+	// Errors lists the layers that failed to parse in recovery mode (see SetDecodeRecovery). Layers after an error are decoded from a guess
+	// 復旧モード(SetDecodeRecovery参照)で解析に失敗したレイヤ。エラーより上位のレイヤは推測から解析したもの
+	Errors []LayerError
+
+	tunnelNesting int // トンネル(IP-in-IP、GENEVE)の入れ子の深さ
 }
 
 // EthernetFrame represents an Ethernet frame
@@ -121,6 +184,11 @@ type ICMPPacket struct {
 	ID       uint16
 	Sequence uint16
 	Payload  []byte
+
+	// Bodies of the message types not laid out like an echo message, whose ID and Sequence are meaningless. nil for other types
+	// エコーメッセージと形式が異なるメッセージタイプの本体。IDとSequenceは意味を持たない。その他のタイプではnil
+	Redirect            *ICMPRedirect
+	RouterAdvertisement *ICMPRouterAdvertisement
 }
 
 // String returns a string representation of the ICMP packet
@@ -195,6 +263,20 @@ type TLSRecord struct {
 	Version uint16
 	Length  uint16
 	Data    []byte
+
+	// JA3 is the JA3 fingerprint of a ClientHello record and JA3S that of a ServerHello record. The hashes are their MD5 in hex
+	// ClientHelloのレコードのJA3とServerHelloのレコードのJA3S。HashはそのMD5(16進数)
+	JA3      string
+	JA3Hash  string
+	JA3S     string
+	JA3SHash string
+
+	// Certificates is the DER certificate chain of a Certificate handshake message in the packet, leaf first, and Certificate the parsed leaf (nil if it did not parse).
+	// Only TLS 1.2 and earlier send it in the clear; TLS 1.3 encrypts it
+	// パケット内のCertificateハンドシェイクメッセージの証明書チェーン(DER、リーフが先頭)と、解析したリーフ証明書(解析できなければnil)。
+	// 平文で送られるのはTLS 1.2以前のみで、TLS 1.3では暗号化される
+	Certificates [][]byte
+	Certificate  *x509.Certificate
 }
 
 // String returns a string representation of the TLS record
@@ -232,6 +314,10 @@ type HTTPRequest struct {
 	Version string
 	Headers map[string]string
 	Body    []byte
+
+	// Truncated reports that Body is incomplete: cut at MaxHTTPBodySize or continued in later segments
+	// Bodyが不完全であることを表す。MaxHTTPBodySizeで切り詰めたか、後のセグメントに続いている
+	Truncated bool
 }
 
 // String returns a string representation of the HTTP request
@@ -249,6 +335,10 @@ type HTTPResponse struct {
 	Status     string
 	Headers    map[string]string
 	Body       []byte
+
+	// Truncated reports that Body is incomplete: cut at MaxHTTPBodySize or continued in later segments
+	// Bodyが不完全であることを表す。MaxHTTPBodySizeで切り詰めたか、後のセグメントに続いている
+	Truncated bool
 }
 
 // String returns a string representation of the HTTP response
@@ -287,9 +377,11 @@ func ParseIPv4Packet(data []byte) *IPv4Packet {
 	}
 	
 	ihl := (data[0] & 0x0F) * 4
-	if len(data) < int(ihl) {
+	if ihl < 20 {
 		return nil
 	}
+	// オプションの途中で切れている場合は、取れる分だけ解析する
+	headerEnd := min(int(ihl), len(data))
 	
 	return &IPv4Packet{
 		Version:     (data[0] >> 4) & 0x0F,
@@ -304,8 +396,8 @@ func ParseIPv4Packet(data []byte) *IPv4Packet {
 		Checksum:    binary.BigEndian.Uint16(data[10:12]),
 		SrcIP:       data[12:16],
 		DstIP:       data[16:20],
-		Options:     data[20:ihl],
-		Payload:     data[ihl:],
+		Options:     data[20:headerEnd],
+		Payload:     data[headerEnd:],
 	}
 }
 
@@ -334,7 +426,7 @@ func ParseICMPPacket(data []byte) *ICMPPacket {
 		return nil
 	}
 	
-	return &ICMPPacket{
+	icmp := &ICMPPacket{
 		Type:     data[0],
 		Code:     data[1],
 		Checksum: binary.BigEndian.Uint16(data[2:4]),
@@ -342,6 +434,8 @@ func ParseICMPPacket(data []byte) *ICMPPacket {
 		Sequence: binary.BigEndian.Uint16(data[6:8]),
 		Payload:  data[8:],
 	}
+	parseICMPMessage(icmp, data)
+	return icmp
 }
 
 // ParseICMPv6Packet parses ICMPv6 packet data
@@ -365,9 +459,11 @@ func ParseTCPPacket(data []byte) *TCPPacket {
 	}
 	
 	dataOffset := (data[12] >> 4) * 4
-	if len(data) < int(dataOffset) {
+	if dataOffset < 20 {
 		return nil
 	}
+	// オプションの途中で切れている場合は、取れる分だけ解析する
+	headerEnd := min(int(dataOffset), len(data))
 	
 	return &TCPPacket{
 		SrcPort:    binary.BigEndian.Uint16(data[0:2]),
@@ -379,8 +475,8 @@ func ParseTCPPacket(data []byte) *TCPPacket {
 		Window:     binary.BigEndian.Uint16(data[14:16]),
 		Checksum:   binary.BigEndian.Uint16(data[16:18]),
 		UrgPtr:     binary.BigEndian.Uint16(data[18:20]),
-		Options:    data[20:dataOffset],
-		Payload:    data[dataOffset:],
+		Options:    data[20:headerEnd],
+		Payload:    data[headerEnd:],
 	}
 }
 
@@ -421,28 +517,62 @@ func ParseDNSResponse(data []byte) *DNSPacket {
 	return ParseDNSRequest(data) // Same structure
 }
 
-// ParseHTTPRequest parses HTTP request data
+// ParseHTTPRequest parses HTTP request data. The body is kept up to MaxHTTPBodySize
 func ParseHTTPRequest(data []byte) *HTTPRequest {
-	// Simplified implementation
+	startLine, headers, rest, ok := splitHTTPMessage(data)
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(startLine)
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], "HTTP/") {
+		logParseFailure("HTTP", data)
+		return nil
+	}
+	
+	body, truncated := decodeHTTPBody(headers, rest, false)
 	return &HTTPRequest{
-		Method:  "GET", // Placeholder
-		URI:     "/",   // Placeholder
-		Version: "HTTP/1.1",
-		Headers: make(map[string]string),
-		Body:    []byte{},
+		Method:    fields[0],
+		URI:       fields[1],
+		Version:   fields[2],
+		Headers:   headers,
+		Body:      body,
+		Truncated: truncated,
 	}
 }
 
-// ParseHTTPResponse parses HTTP response data
+// ParseHTTPResponse parses HTTP response data. The body is kept up to MaxHTTPBodySize
 func ParseHTTPResponse(data []byte) *HTTPResponse {
-	// Simplified implementation
-	return &HTTPResponse{
-		Version:    "HTTP/1.1",
-		StatusCode: 200,
-		Status:     "OK",
-		Headers:    make(map[string]string),
-		Body:       []byte{},
+	startLine, headers, rest, ok := splitHTTPMessage(data)
+	if !ok {
+		return nil
 	}
+	// "HTTP/1.1 200 OK". The reason phrase may be empty or contain spaces
+	// 理由句は空の場合や空白を含む場合がある
+	fields := strings.SplitN(startLine, " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+		logParseFailure("HTTP", data)
+		return nil
+	}
+	statusCode, err := strconv.Atoi(fields[1])
+	if err != nil {
+		logParseFailure("HTTP", data)
+		return nil
+	}
+	
+	res := &HTTPResponse{
+		Version:    fields[0],
+		StatusCode: statusCode,
+		Headers:    headers,
+	}
+	if len(fields) == 3 {
+		res.Status = fields[2]
+	}
+	// 1xx, 204 and 304 responses have no body
+	// 1xx、204、304のレスポンスにはボディが無い
+	if statusCode >= 200 && statusCode != 204 && statusCode != 304 {
+		res.Body, res.Truncated = decodeHTTPBody(headers, rest, true)
+	}
+	return res
 }
 
 // ParseTLSData parses TLS data
@@ -450,6 +580,12 @@ func ParseTLSData(data []byte, passive *Passive) {
 	if len(data) < 5 {
 		return
 	}
+	// Only record types 20-24 of SSL 3.0 / TLS 1.x are TLS. Anything else on the port is not TLS
+	// SSL 3.0 / TLS 1.x のレコードタイプ20〜24以外はTLSではない
+	if data[0] < 20 || data[0] > 24 || data[1] != 0x03 {
+		logParseFailure("TLS", data)
+		return
+	}
 	
 	passive.TLS = &TLSRecord{
 		Type:    data[0],
@@ -457,4 +593,14 @@ func ParseTLSData(data []byte, passive *Passive) {
 		Length:  binary.BigEndian.Uint16(data[3:5]),
 		Data:    data[5:],
 	}
+	// Fingerprint a ClientHello or ServerHello
+	// ClientHelloまたはServerHelloのフィンガープリントを求める
+	if err := passive.TLS.fingerprint(); err != nil {
+		logParseFailure("TLS Hello", data)
+	}
+	// Extract the server's certificate sent in the clear
+	// 平文で送られたサーバー証明書を取り出す
+	if err := passive.TLS.parseCertificates(data); err != nil {
+		logParseFailure("TLS Certificate", data)
+	}
 }
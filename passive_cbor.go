@@ -0,0 +1,474 @@
+package packemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CBOR major types (RFC 8949)
+// ref: https://datatracker.ietf.org/doc/html/rfc8949#section-3.1
+const (
+	CBOR_MAJOR_TYPE_UNSIGNED = 0
+	CBOR_MAJOR_TYPE_NEGATIVE = 1
+	CBOR_MAJOR_TYPE_BYTES    = 2
+	CBOR_MAJOR_TYPE_TEXT     = 3
+	CBOR_MAJOR_TYPE_ARRAY    = 4
+	CBOR_MAJOR_TYPE_MAP      = 5
+	CBOR_MAJOR_TYPE_TAG      = 6
+	CBOR_MAJOR_TYPE_SIMPLE   = 7
+)
+
+const (
+	cborFalse = 20
+	cborTrue  = 21
+	cborNull  = 22
+)
+
+// CBOR_MAX_LENGTH is the longest byte string, text string, array or map CBORDecoder accepts, so that a broken stream cannot make it allocate huge buffers
+// CBORDecoderが受け付けるバイト列・文字列・配列・マップの最大の長さです。壊れたストリームで巨大なバッファを確保しないようにします
+const CBOR_MAX_LENGTH = 1 << 20
+
+// CBOR_MAX_NESTING is how deep unknown data items skipped by CBORDecoder may nest
+// CBORDecoderが読み飛ばす未知のデータ項目の入れ子の深さの上限です
+const CBOR_MAX_NESTING = 32
+
+// MarshalCBOR encodes the packet as CBOR (RFC 8949) with the same fields and keys as MarshalJSON.
+// Byte fields are CBOR byte strings instead of hex, so the output is smaller than JSON and faster to produce.
+// パケットをMarshalJSONと同じフィールド・キーでCBORにエンコードします。
+// バイト列は16進数ではなくCBORのバイト列になるため、JSONより小さく高速です
+func (p *Passive) MarshalCBOR() ([]byte, error) {
+	return marshalCBOR(NewPassiveJSON(p))
+}
+
+func marshalCBOR(pj *PassiveJSON) ([]byte, error) {
+	var e cborEncoder
+	if err := e.encode(reflect.ValueOf(pj)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// UnmarshalCBOR decodes the output of MarshalCBOR into the PassiveJSON schema
+// MarshalCBORの出力をPassiveJSONのスキーマにデコードします
+func UnmarshalCBOR(data []byte, pj *PassiveJSON) error {
+	d := NewCBORDecoder(bytes.NewReader(data))
+	if err := d.Decode(pj); err != nil {
+		return err
+	}
+	if _, err := d.r.Peek(1); err != io.EOF {
+		return errors.New("cbor: trailing data after packet")
+	}
+	return nil
+}
+
+// CBOREncoder writes packets to a stream as a CBOR sequence (RFC 8742), one data item per packet without separators.
+// Encode can be passed to NetworkInterface.Capture to stream captured packets.
+// パケットをCBORシーケンス(区切りなしで1パケットずつのデータ項目)としてストリームに書き込みます。
+// EncodeをNetworkInterface.Captureに渡すと、キャプチャしたパケットをストリームに書き込めます
+type CBOREncoder struct {
+	w         io.Writer
+	redaction Redaction
+}
+
+// NewCBOREncoder returns an encoder writing to w
+// wに書き込むエンコーダーを返します
+func NewCBOREncoder(w io.Writer) *CBOREncoder {
+	return &CBOREncoder{w: w}
+}
+
+// SetRedaction sets the layers and fields left out of the packets encoded after it
+// これ以降にエンコードするパケットから除くレイヤとフィールドを設定します
+func (enc *CBOREncoder) SetRedaction(r Redaction) {
+	enc.redaction = r
+}
+
+// Encode writes the CBOR encoding of p
+// pのCBORエンコーディングを書き込みます
+func (enc *CBOREncoder) Encode(p *Passive) error {
+	b, err := marshalCBOR(enc.redaction.PassiveJSON(p))
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(b)
+	return err
+}
+
+// CBORDecoder reads packets written by CBOREncoder
+// CBOREncoderが書き込んだパケットを読み込みます
+type CBORDecoder struct {
+	r *bufio.Reader
+}
+
+// NewCBORDecoder returns a decoder reading from r
+// rから読み込むデコーダーを返します
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next packet into pj. It returns io.EOF at the end of the stream
+// 次のパケットをpjに読み込みます。ストリームの終わりではio.EOFを返します
+func (dec *CBORDecoder) Decode(pj *PassiveJSON) error {
+	if _, err := dec.r.Peek(1); err != nil {
+		return err
+	}
+	*pj = PassiveJSON{}
+	if err := dec.decode(reflect.ValueOf(pj).Elem()); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("cbor: %w", err)
+	}
+	return nil
+}
+
+type cborEncoder struct {
+	buf []byte
+}
+
+// 先頭バイト(major type + additional information)と引数を書く
+func (e *cborEncoder) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major<<5|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major<<5|27), n)
+	}
+}
+
+func (e *cborEncoder) encode(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			e.buf = append(e.buf, CBOR_MAJOR_TYPE_SIMPLE<<5|cborNull)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, CBOR_MAJOR_TYPE_SIMPLE<<5|cborTrue)
+		} else {
+			e.buf = append(e.buf, CBOR_MAJOR_TYPE_SIMPLE<<5|cborFalse)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.head(CBOR_MAJOR_TYPE_UNSIGNED, v.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i >= 0 {
+			e.head(CBOR_MAJOR_TYPE_UNSIGNED, uint64(i))
+		} else {
+			// 負の数 n は -1-n として表す
+			e.head(CBOR_MAJOR_TYPE_NEGATIVE, uint64(-1-i))
+		}
+	case reflect.String:
+		e.head(CBOR_MAJOR_TYPE_TEXT, uint64(v.Len()))
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.head(CBOR_MAJOR_TYPE_BYTES, uint64(v.Len()))
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		e.head(CBOR_MAJOR_TYPE_ARRAY, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		e.head(CBOR_MAJOR_TYPE_MAP, uint64(len(keys)))
+		for _, key := range keys {
+			if err := e.encode(key); err != nil {
+				return err
+			}
+			if err := e.encode(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		// JSON と同じキーを使い、omitempty のフィールドも同じように省略する
+		fields := cborFields(v.Type())
+		present := make([]cborField, 0, len(fields))
+		for _, f := range fields {
+			if f.omitEmpty && cborEmpty(v.Field(f.index)) {
+				continue
+			}
+			present = append(present, f)
+		}
+		e.head(CBOR_MAJOR_TYPE_MAP, uint64(len(present)))
+		for _, f := range present {
+			e.head(CBOR_MAJOR_TYPE_TEXT, uint64(len(f.key)))
+			e.buf = append(e.buf, f.key...)
+			if err := e.encode(v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+type cborField struct {
+	key       string
+	index     int
+	omitEmpty bool
+}
+
+// encoding/json の omitempty と同じく、ゼロ値と空のスライス・マップを空とみなす
+func cborEmpty(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// json タグからキーを決める
+func cborFields(t reflect.Type) []cborField {
+	fields := make([]cborField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if tag == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		key, options, _ := strings.Cut(tag, ",")
+		if key == "" {
+			key = t.Field(i).Name
+		}
+		fields = append(fields, cborField{key: key, index: i, omitEmpty: options == "omitempty"})
+	}
+	return fields
+}
+
+// 先頭バイトと引数を読む
+func (dec *CBORDecoder) head() (byte, uint64, error) {
+	initial, err := dec.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	major, info := initial>>5, initial&0x1f
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		// 長さ不定のデータ項目は CBOREncoder が出力しない
+		return 0, 0, fmt.Errorf("unsupported additional information %d", info)
+	}
+	var b [8]byte
+	if _, err := io.ReadFull(dec.r, b[8-size:]); err != nil {
+		return 0, 0, err
+	}
+	return major, binary.BigEndian.Uint64(b[:]), nil
+}
+
+func (dec *CBORDecoder) length(n uint64) (int, error) {
+	if n > CBOR_MAX_LENGTH {
+		return 0, fmt.Errorf("length %d exceeds %d", n, CBOR_MAX_LENGTH)
+	}
+	return int(n), nil
+}
+
+func (dec *CBORDecoder) decode(v reflect.Value) error {
+	major, n, err := dec.head()
+	if err != nil {
+		return err
+	}
+	if major == CBOR_MAJOR_TYPE_SIMPLE && n == cborNull {
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		return dec.decodeItem(v.Elem(), major, n)
+	}
+	return dec.decodeItem(v, major, n)
+}
+
+func (dec *CBORDecoder) decodeItem(v reflect.Value, major byte, n uint64) error {
+	mismatch := func() error {
+		return fmt.Errorf("cannot decode major type %d into %s", major, v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if major != CBOR_MAJOR_TYPE_SIMPLE || (n != cborTrue && n != cborFalse) {
+			return mismatch()
+		}
+		v.SetBool(n == cborTrue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if major != CBOR_MAJOR_TYPE_UNSIGNED {
+			return mismatch()
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if (major != CBOR_MAJOR_TYPE_UNSIGNED && major != CBOR_MAJOR_TYPE_NEGATIVE) || n > math.MaxInt64 {
+			return mismatch()
+		}
+		i := int64(n)
+		if major == CBOR_MAJOR_TYPE_NEGATIVE {
+			i = -1 - i
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.String:
+		if major != CBOR_MAJOR_TYPE_TEXT {
+			return mismatch()
+		}
+		b, err := dec.bytes(n)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if major != CBOR_MAJOR_TYPE_BYTES {
+				return mismatch()
+			}
+			b, err := dec.bytes(n)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		if major != CBOR_MAJOR_TYPE_ARRAY {
+			return mismatch()
+		}
+		length, err := dec.length(n)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), length, length))
+		for i := 0; i < length; i++ {
+			if err := dec.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if major != CBOR_MAJOR_TYPE_MAP {
+			return mismatch()
+		}
+		length, err := dec.length(n)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), length))
+		for i := 0; i < length; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := dec.decode(key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := dec.decode(value); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		if major != CBOR_MAJOR_TYPE_MAP {
+			return mismatch()
+		}
+		length, err := dec.length(n)
+		if err != nil {
+			return err
+		}
+		fields := make(map[string]int)
+		for _, f := range cborFields(v.Type()) {
+			fields[f.key] = f.index
+		}
+		for i := 0; i < length; i++ {
+			var key string
+			if err := dec.decode(reflect.ValueOf(&key).Elem()); err != nil {
+				return err
+			}
+			index, ok := fields[key]
+			if !ok {
+				// JSON と同様に、知らないキーは読み飛ばす
+				if err := dec.skip(0); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := dec.decode(v.Field(index)); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (dec *CBORDecoder) bytes(n uint64) ([]byte, error) {
+	length, err := dec.length(n)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(dec.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// skip reads and discards one data item, nested at most CBOR_MAX_NESTING deep
+func (dec *CBORDecoder) skip(depth int) error {
+	if depth > CBOR_MAX_NESTING {
+		return fmt.Errorf("nested deeper than %d", CBOR_MAX_NESTING)
+	}
+	major, n, err := dec.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case CBOR_MAJOR_TYPE_TAG:
+		return dec.skip(depth + 1)
+	case CBOR_MAJOR_TYPE_BYTES, CBOR_MAJOR_TYPE_TEXT:
+		_, err := dec.bytes(n)
+		return err
+	case CBOR_MAJOR_TYPE_ARRAY, CBOR_MAJOR_TYPE_MAP:
+		length, err := dec.length(n)
+		if err != nil {
+			return err
+		}
+		if major == CBOR_MAJOR_TYPE_MAP {
+			length *= 2
+		}
+		for i := 0; i < length; i++ {
+			if err := dec.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
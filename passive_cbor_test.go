@@ -0,0 +1,112 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestPassiveCBOR tests that the CBOR output decodes back into the same PassiveJSON as the JSON output
+// CBOR出力がJSON出力と同じPassiveJSONに読み戻せることをテストします
+func TestPassiveCBOR(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "http request", frame: parseDepthTestFrame()},
+		{name: "dns query", frame: decodeStatsTestUDPFrame(0xd4c0, PORT_DNS, dnsTestMessage(0x1234, false, "example.com", nil, nil))},
+		{name: "arp", frame: frameReaderTestARP()},
+		{name: "smb2 negotiate", frame: testTCPFrame(PORT_SMB, smbTestNegotiateRequest(0x0202, 0x0311))},
+		{name: "icmpv6", frame: checksumReportTestICMPv6Frame()},
+		{name: "6in4", frame: ipTunnelTestFrame(IPv4_PROTO_IPv6, checksumReportTestICMPv6Frame()[14:])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passive, err := DecodeFrame(tt.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := passive.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := &PassiveJSON{}
+			if err := UnmarshalCBOR(b, got); err != nil {
+				t.Fatal(err)
+			}
+			if want := NewPassiveJSON(passive); !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip mismatch\ngot:  %+v\nwant: %+v\ncbor: %x", got, want, b)
+			}
+
+			j, err := json.Marshal(passive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b) >= len(j) {
+				t.Errorf("CBOR (%d bytes) should be smaller than JSON (%d bytes)", len(b), len(j))
+			}
+		})
+	}
+}
+
+// TestCBOREncoderStream tests that packets written by CBOREncoder are read back in order by CBORDecoder
+// CBOREncoderで書き込んだパケットをCBORDecoderで順に読み戻せることをテストします
+func TestCBOREncoderStream(t *testing.T) {
+	var want []*PassiveJSON
+	var buf bytes.Buffer
+	enc := NewCBOREncoder(&buf)
+	for _, frame := range [][]byte{parseDepthTestFrame(), frameReaderTestARP(), checksumReportTestICMPv6Frame()} {
+		passive, err := DecodeFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(passive); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, NewPassiveJSON(passive))
+	}
+
+	dec := NewCBORDecoder(&buf)
+	for i := range want {
+		got := &PassiveJSON{}
+		if err := dec.Decode(got); err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("packet %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+	if err := dec.Decode(&PassiveJSON{}); err != io.EOF {
+		t.Errorf("Decode at the end = %v, want io.EOF", err)
+	}
+}
+
+// TestUnmarshalCBORInvalid tests unknown keys and broken input
+// 未知のキーと壊れた入力をテストします
+func TestUnmarshalCBORInvalid(t *testing.T) {
+	// {"_schema": 1, "unknown": [1, {"a": h'00'}], "length": 3}
+	data := []byte{0xa3, 0x67, '_', 's', 'c', 'h', 'e', 'm', 'a', 0x01, 0x67, 'u', 'n', 'k', 'n', 'o', 'w', 'n', 0x82, 0x01, 0xa1, 0x61, 'a', 0x41, 0x00, 0x66, 'l', 'e', 'n', 'g', 't', 'h', 0x03}
+	got := &PassiveJSON{}
+	if err := UnmarshalCBOR(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Schema != 1 || got.Length != 3 {
+		t.Errorf("got %+v, want schema 1 and length 3", got)
+	}
+
+	if err := UnmarshalCBOR(data[:len(data)-1], &PassiveJSON{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated input: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if err := UnmarshalCBOR(append(data, 0x00), &PassiveJSON{}); err == nil {
+		t.Errorf("trailing data should be an error")
+	}
+	// "length" に文字列
+	if err := UnmarshalCBOR([]byte{0xa1, 0x66, 'l', 'e', 'n', 'g', 't', 'h', 0x61, 'x'}, &PassiveJSON{}); err == nil {
+		t.Errorf("type mismatch should be an error")
+	}
+}
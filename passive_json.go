@@ -0,0 +1,515 @@
+package packemon
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"time"
+)
+
+// PASSIVE_JSON_SCHEMA_VERSION is the version of the JSON representation of Passive, emitted as the "_schema" field.
+// It is bumped when a field is removed, renamed or changes its type. Adding a layer or a field keeps the version,
+// so consumers should ignore keys they do not know. The fields of each version are listed in json_schema.md.
+// PassiveのJSON表現のバージョンで、"_schema"フィールドとして出力されます。
+// フィールドの削除・名前の変更・型の変更でのみ上げ、レイヤやフィールドの追加では上げません
+const PASSIVE_JSON_SCHEMA_VERSION = 1
+
+// HexBytes is a byte slice encoded as a hex string in JSON
+// JSONで16進文字列としてエンコードされるバイト列です
+type HexBytes []byte
+
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// PassiveJSON is the current schema of the JSON emitted for a Passive. Unmarshal the output into it to read it back.
+// Layers that were not decoded are omitted.
+// Passiveに対して出力されるJSONの現在のスキーマです。出力をこの構造体にUnmarshalして読み戻せます。
+// 解析されなかったレイヤは出力されません
+type PassiveJSON struct {
+	// Schema is PASSIVE_JSON_SCHEMA_VERSION. It is omitted in the inner packet of a tunnel
+	// PASSIVE_JSON_SCHEMA_VERSION。トンネルの内側のパケットでは省略される
+	Schema int `json:"_schema,omitempty"`
+
+	Timestamp     string   `json:"timestamp,omitempty"` // RFC 3339
+	Interface     string   `json:"interface,omitempty"`
+	Length        int      `json:"length"`
+	WireLength    int      `json:"wire_length,omitempty"`
+	Truncated     bool     `json:"truncated,omitempty"`
+	PartialLayers []string `json:"partial_layers,omitempty"`
+	FCS           string   `json:"fcs,omitempty"`    // "valid"、"invalid"、"unchecked"、"missing"
+	Errors        []string `json:"errors,omitempty"` // 復旧モードで解析に失敗したレイヤ
+
+	Ethernet *EthernetJSON `json:"eth,omitempty"`
+	ARP      *ARPJSON      `json:"arp,omitempty"`
+	IPv4     *IPv4JSON     `json:"ipv4,omitempty"`
+	IPv6     *IPv6JSON     `json:"ipv6,omitempty"`
+	ICMP     *ICMPJSON     `json:"icmp,omitempty"`
+	ICMPv6   *ICMPv6JSON   `json:"icmpv6,omitempty"`
+	TCP      *TCPJSON      `json:"tcp,omitempty"`
+	UDP      *UDPJSON      `json:"udp,omitempty"`
+	TLS      *TLSJSON      `json:"tls,omitempty"`
+	DNS      *DNSJSON      `json:"dns,omitempty"`
+	HTTP     *HTTPJSON     `json:"http,omitempty"`
+	HTTPRes  *HTTPResJSON  `json:"http_response,omitempty"`
+	RTP      *RTPJSON      `json:"rtp,omitempty"`
+	GENEVE   *GENEVEJSON   `json:"geneve,omitempty"`
+	SMB      *SMBJSON      `json:"smb,omitempty"`
+	WLAN     *WLANJSON     `json:"wlan,omitempty"`
+
+	Inner *PassiveJSON `json:"inner,omitempty"` // IP-in-IP の内側のパケット
+}
+
+type EthernetJSON struct {
+	Dst  string `json:"dst,omitempty"` // raw IP のリンクタイプでは空
+	Src  string `json:"src,omitempty"`
+	Type uint16 `json:"type"`
+}
+
+type ARPJSON struct {
+	Operation uint16 `json:"op"`
+	SenderMAC string `json:"sender_mac"`
+	SenderIP  string `json:"sender_ip"`
+	TargetMAC string `json:"target_mac"`
+	TargetIP  string `json:"target_ip"`
+}
+
+type IPv4JSON struct {
+	IHL         uint8    `json:"ihl"` // bytes / バイト単位
+	TOS         uint8    `json:"tos"`
+	TotalLength uint16   `json:"total_length"`
+	ID          uint16   `json:"id"`
+	Flags       uint8    `json:"flags"`
+	FragOffset  uint16   `json:"frag_offset"`
+	TTL         uint8    `json:"ttl"`
+	Protocol    uint8    `json:"protocol"`
+	Checksum    uint16   `json:"checksum"`
+	Src         string   `json:"src"`
+	Dst         string   `json:"dst"`
+	Options     HexBytes `json:"options,omitempty"`
+}
+
+type IPv6JSON struct {
+	TrafficClass uint8  `json:"traffic_class"`
+	FlowLabel    uint32 `json:"flow_label"`
+	PayloadLen   uint16 `json:"payload_length"`
+	NextHeader   uint8  `json:"next_header"`
+	HopLimit     uint8  `json:"hop_limit"`
+	Src          string `json:"src"`
+	Dst          string `json:"dst"`
+}
+
+type ICMPJSON struct {
+	Type     uint8    `json:"type"`
+	Code     uint8    `json:"code"`
+	Checksum uint16   `json:"checksum"`
+	ID       uint16   `json:"id"`
+	Sequence uint16   `json:"seq"`
+	Payload  HexBytes `json:"payload,omitempty"`
+}
+
+type ICMPv6JSON struct {
+	Type     uint8    `json:"type"`
+	Code     uint8    `json:"code"`
+	Checksum uint16   `json:"checksum"`
+	Payload  HexBytes `json:"payload,omitempty"`
+}
+
+type TCPJSON struct {
+	SrcPort    uint16   `json:"src_port"`
+	DstPort    uint16   `json:"dst_port"`
+	Seq        uint32   `json:"seq"`
+	Ack        uint32   `json:"ack"`
+	DataOffset uint8    `json:"data_offset"`
+	Flags      uint8    `json:"flags"`
+	Window     uint16   `json:"window"`
+	Checksum   uint16   `json:"checksum"`
+	UrgPtr     uint16   `json:"urg_ptr"`
+	Options    HexBytes `json:"options,omitempty"`
+	Payload    HexBytes `json:"payload,omitempty"`
+}
+
+type UDPJSON struct {
+	SrcPort  uint16   `json:"src_port"`
+	DstPort  uint16   `json:"dst_port"`
+	Length   uint16   `json:"length"`
+	Checksum uint16   `json:"checksum"`
+	Payload  HexBytes `json:"payload,omitempty"`
+}
+
+type TLSJSON struct {
+	Type     uint8    `json:"type"`
+	Version  uint16   `json:"version"`
+	Length   uint16   `json:"length"`
+	Data     HexBytes `json:"data,omitempty"`
+	JA3      string   `json:"ja3,omitempty"`
+	JA3Hash  string   `json:"ja3_hash,omitempty"`
+	JA3S     string   `json:"ja3s,omitempty"`
+	JA3SHash string   `json:"ja3s_hash,omitempty"`
+
+	Certificate *TLSCertificateJSON `json:"certificate,omitempty"`
+}
+
+// TLSCertificateJSON is the leaf certificate of a Certificate message
+// Certificateメッセージのリーフ証明書
+type TLSCertificateJSON struct {
+	Subject   string   `json:"subject"`
+	Issuer    string   `json:"issuer"`
+	NotBefore string   `json:"not_before"` // RFC 3339
+	NotAfter  string   `json:"not_after"`  // RFC 3339
+	DER       HexBytes `json:"der"`
+}
+
+type DNSJSON struct {
+	ID            uint16   `json:"id"`
+	Flags         uint16   `json:"flags"`
+	Questions     uint16   `json:"questions"`
+	AnswerRRs     uint16   `json:"answer_rrs"`
+	AuthorityRRs  uint16   `json:"authority_rrs"`
+	AdditionalRRs uint16   `json:"additional_rrs"`
+	Payload       HexBytes `json:"payload,omitempty"` // ヘッダーより後ろ
+}
+
+type HTTPJSON struct {
+	Method    string            `json:"method"`
+	URI       string            `json:"uri"`
+	Version   string            `json:"version"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      HexBytes          `json:"body,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+type HTTPResJSON struct {
+	Version    string            `json:"version"`
+	StatusCode int               `json:"status_code"`
+	Status     string            `json:"status"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       HexBytes          `json:"body,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+type RTPJSON struct {
+	Version        uint8    `json:"version"`
+	Marker         bool     `json:"marker"`
+	PayloadType    uint8    `json:"payload_type"`
+	SequenceNumber uint16   `json:"seq"`
+	Timestamp      uint32   `json:"timestamp"`
+	SSRC           uint32   `json:"ssrc"`
+	CSRC           []uint32 `json:"csrc,omitempty"`
+	Payload        HexBytes `json:"payload,omitempty"`
+}
+
+type GENEVEJSON struct {
+	VNI          uint32       `json:"vni"`
+	ProtocolType uint16       `json:"protocol_type"`
+	OAM          bool         `json:"oam"`
+	Critical     bool         `json:"critical"`
+	Inner        *PassiveJSON `json:"inner,omitempty"` // 内側のパケット
+}
+
+type WLANJSON struct {
+	Type      uint8  `json:"type"`
+	Subtype   uint8  `json:"subtype"`
+	Flags     uint8  `json:"flags"`
+	Addr1     string `json:"addr1"`
+	Addr2     string `json:"addr2,omitempty"`
+	Addr3     string `json:"addr3,omitempty"`
+	Addr4     string `json:"addr4,omitempty"`
+	Seq       uint16 `json:"seq"`
+	SSID      string `json:"ssid,omitempty"`
+	Frequency uint16 `json:"frequency,omitempty"` // MHz. radiotap に含まれる場合のみ
+	Signal    *int8  `json:"signal,omitempty"`    // dBm. radiotap に含まれる場合のみ
+}
+
+type SMBJSON struct {
+	SessionType uint8    `json:"session_type"`
+	Version     uint8    `json:"version"`
+	Encrypted   bool     `json:"encrypted,omitempty"`
+	Command     uint16   `json:"command"`
+	Status      uint32   `json:"status"`
+	Response    bool     `json:"response"`
+	MessageID   uint64   `json:"message_id"`
+	TreeID      uint32   `json:"tree_id"`
+	SessionID   uint64   `json:"session_id"`
+	Dialects    []uint16 `json:"dialects,omitempty"`
+	Tree        string   `json:"tree,omitempty"`
+}
+
+// MarshalJSON encodes the packet in the current PassiveJSON schema
+// パケットを現在のPassiveJSONのスキーマでエンコードします
+func (p *Passive) MarshalJSON() ([]byte, error) {
+	return json.Marshal(NewPassiveJSON(p))
+}
+
+// NewPassiveJSON converts a Passive into the current JSON schema
+// Passiveを現在のJSONのスキーマに変換します
+func NewPassiveJSON(p *Passive) *PassiveJSON {
+	pj := newPassiveJSON(p)
+	pj.Schema = PASSIVE_JSON_SCHEMA_VERSION
+	return pj
+}
+
+func newPassiveJSON(p *Passive) *PassiveJSON {
+	pj := &PassiveJSON{
+		Interface:  p.Interface,
+		Length:     p.RawLength,
+		WireLength: p.WireLength,
+		Truncated:  p.Truncated,
+		FCS:        p.FCSStatus.String(),
+	}
+	if len(p.PartialLayers) > 0 {
+		pj.PartialLayers = p.PartialLayers
+	}
+	for _, err := range p.Errors {
+		pj.Errors = append(pj.Errors, err.Error())
+	}
+	if !p.Timestamp.IsZero() {
+		pj.Timestamp = p.Timestamp.Format(time.RFC3339Nano)
+	}
+
+	if e := p.EthernetFrame; e != nil {
+		pj.Ethernet = &EthernetJSON{
+			Dst:  jsonMAC(e.DstAddr),
+			Src:  jsonMAC(e.SrcAddr),
+			Type: e.Type,
+		}
+	}
+	if a := p.ARP; a != nil {
+		pj.ARP = &ARPJSON{
+			Operation: a.Operation,
+			SenderMAC: jsonMAC(a.SenderMAC),
+			SenderIP:  jsonIP(a.SenderIP),
+			TargetMAC: jsonMAC(a.TargetMAC),
+			TargetIP:  jsonIP(a.TargetIP),
+		}
+	}
+	if ip := p.IPv4; ip != nil {
+		pj.IPv4 = &IPv4JSON{
+			IHL:         ip.IHL,
+			TOS:         ip.TOS,
+			TotalLength: ip.TotalLength,
+			ID:          ip.ID,
+			Flags:       ip.Flags,
+			FragOffset:  ip.FragOffset,
+			TTL:         ip.TTL,
+			Protocol:    ip.Protocol,
+			Checksum:    ip.Checksum,
+			Src:         jsonIP(ip.SrcIP),
+			Dst:         jsonIP(ip.DstIP),
+			Options:     jsonBytes(ip.Options),
+		}
+	}
+	if ip := p.IPv6; ip != nil {
+		pj.IPv6 = &IPv6JSON{
+			TrafficClass: ip.TrafficClass,
+			FlowLabel:    ip.FlowLabel,
+			PayloadLen:   ip.PayloadLen,
+			NextHeader:   ip.NextHeader,
+			HopLimit:     ip.HopLimit,
+			Src:          jsonIP(ip.SrcIP),
+			Dst:          jsonIP(ip.DstIP),
+		}
+	}
+	if icmp := p.ICMP; icmp != nil {
+		pj.ICMP = &ICMPJSON{
+			Type:     icmp.Type,
+			Code:     icmp.Code,
+			Checksum: icmp.Checksum,
+			ID:       icmp.ID,
+			Sequence: icmp.Sequence,
+			Payload:  jsonBytes(icmp.Payload),
+		}
+	}
+	if icmpv6 := p.ICMPv6; icmpv6 != nil {
+		pj.ICMPv6 = &ICMPv6JSON{
+			Type:     icmpv6.Type,
+			Code:     icmpv6.Code,
+			Checksum: icmpv6.Checksum,
+			Payload:  jsonBytes(icmpv6.Payload),
+		}
+	}
+	if tcp := p.TCP; tcp != nil {
+		pj.TCP = &TCPJSON{
+			SrcPort:    tcp.SrcPort,
+			DstPort:    tcp.DstPort,
+			Seq:        tcp.SeqNum,
+			Ack:        tcp.AckNum,
+			DataOffset: tcp.DataOffset,
+			Flags:      tcp.Flags,
+			Window:     tcp.Window,
+			Checksum:   tcp.Checksum,
+			UrgPtr:     tcp.UrgPtr,
+			Options:    jsonBytes(tcp.Options),
+			Payload:    jsonBytes(tcp.Payload),
+		}
+	}
+	if udp := p.UDP; udp != nil {
+		pj.UDP = &UDPJSON{
+			SrcPort:  udp.SrcPort,
+			DstPort:  udp.DstPort,
+			Length:   udp.Length,
+			Checksum: udp.Checksum,
+			Payload:  jsonBytes(udp.Payload),
+		}
+	}
+	if tls := p.TLS; tls != nil {
+		pj.TLS = &TLSJSON{
+			Type:     tls.Type,
+			Version:  tls.Version,
+			Length:   tls.Length,
+			Data:     jsonBytes(tls.Data),
+			JA3:      tls.JA3,
+			JA3Hash:  tls.JA3Hash,
+			JA3S:     tls.JA3S,
+			JA3SHash: tls.JA3SHash,
+		}
+		if cert := tls.Certificate; cert != nil {
+			pj.TLS.Certificate = &TLSCertificateJSON{
+				Subject:   cert.Subject.String(),
+				Issuer:    cert.Issuer.String(),
+				NotBefore: cert.NotBefore.UTC().Format(time.RFC3339),
+				NotAfter:  cert.NotAfter.UTC().Format(time.RFC3339),
+				DER:       cert.Raw,
+			}
+		}
+	}
+	if dns := p.DNS; dns != nil {
+		pj.DNS = &DNSJSON{
+			ID:            dns.ID,
+			Flags:         dns.Flags,
+			Questions:     dns.Questions,
+			AnswerRRs:     dns.AnswerRRs,
+			AuthorityRRs:  dns.AuthorityRRs,
+			AdditionalRRs: dns.AdditionalRRs,
+			Payload:       jsonBytes(dns.Payload),
+		}
+	}
+	if http := p.HTTP; http != nil {
+		pj.HTTP = &HTTPJSON{
+			Method:    http.Method,
+			URI:       http.URI,
+			Version:   http.Version,
+			Headers:   jsonHeaders(http.Headers),
+			Body:      jsonBytes(http.Body),
+			Truncated: http.Truncated,
+		}
+	}
+	if http := p.HTTPRes; http != nil {
+		pj.HTTPRes = &HTTPResJSON{
+			Version:    http.Version,
+			StatusCode: http.StatusCode,
+			Status:     http.Status,
+			Headers:    jsonHeaders(http.Headers),
+			Body:       jsonBytes(http.Body),
+			Truncated:  http.Truncated,
+		}
+	}
+	if rtp := p.RTP; rtp != nil {
+		pj.RTP = &RTPJSON{
+			Version:        rtp.Version,
+			Marker:         rtp.Marker,
+			PayloadType:    rtp.PayloadType,
+			SequenceNumber: rtp.SequenceNumber,
+			Timestamp:      rtp.Timestamp,
+			SSRC:           rtp.SSRC,
+			Payload:        jsonBytes(rtp.Payload),
+		}
+		if len(rtp.CSRC) > 0 {
+			pj.RTP.CSRC = rtp.CSRC
+		}
+	}
+	if geneve := p.GENEVE; geneve != nil {
+		pj.GENEVE = &GENEVEJSON{
+			VNI:          geneve.VNI,
+			ProtocolType: geneve.ProtocolType,
+			OAM:          geneve.OAM,
+			Critical:     geneve.Critical,
+		}
+		if geneve.Inner != nil {
+			pj.GENEVE.Inner = newPassiveJSON(geneve.Inner)
+		}
+	}
+	if wlan := p.IEEE80211; wlan != nil {
+		pj.WLAN = &WLANJSON{
+			Type:    wlan.Type,
+			Subtype: wlan.Subtype,
+			Flags:   wlan.Flags,
+			Addr1:   jsonMAC(wlan.Addr1),
+			Addr2:   jsonMAC(wlan.Addr2),
+			Addr3:   jsonMAC(wlan.Addr3),
+			Addr4:   jsonMAC(wlan.Addr4),
+			Seq:     wlan.SequenceNumber,
+			SSID:    wlan.SSID,
+		}
+		if radiotap := wlan.Radiotap; radiotap != nil {
+			pj.WLAN.Frequency = radiotap.Frequency
+			if radiotap.HasField(RADIOTAP_DBM_ANTSIGNAL) {
+				signal := radiotap.Signal
+				pj.WLAN.Signal = &signal
+			}
+		}
+	}
+	if smb := p.SMB; smb != nil {
+		pj.SMB = &SMBJSON{
+			SessionType: smb.SessionType,
+			Version:     smb.Version,
+			Encrypted:   smb.Encrypted,
+			Command:     smb.Command,
+			Status:      smb.Status,
+			Response:    smb.Response,
+			MessageID:   smb.MessageID,
+			TreeID:      smb.TreeID,
+			SessionID:   smb.SessionID,
+			Tree:        smb.Tree,
+		}
+		if len(smb.Dialects) > 0 {
+			pj.SMB.Dialects = smb.Dialects
+		}
+	}
+	if p.Inner != nil {
+		pj.Inner = newPassiveJSON(p.Inner)
+	}
+	return pj
+}
+
+// 空の値は省略されるので、読み戻したときと同じになるように nil にそろえる
+func jsonBytes(b []byte) HexBytes {
+	if len(b) == 0 {
+		return nil
+	}
+	return HexBytes(b)
+}
+
+func jsonHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+func jsonMAC(addr []byte) string {
+	if len(addr) == 0 {
+		return ""
+	}
+	return net.HardwareAddr(addr).String()
+}
+
+func jsonIP(addr []byte) string {
+	if len(addr) == 0 {
+		return ""
+	}
+	return net.IP(addr).String()
+}
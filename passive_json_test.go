@@ -0,0 +1,82 @@
+package packemon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestPassiveJSON tests that the JSON output carries the schema version and reads back into PassiveJSON
+// JSON出力にスキーマのバージョンが含まれ、PassiveJSONに読み戻せることをテストします
+func TestPassiveJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "http request", frame: parseDepthTestFrame()},
+		{name: "dns query", frame: decodeStatsTestUDPFrame(0xd4c0, PORT_DNS, dnsTestMessage(0x1234, false, "example.com", nil, nil))},
+		{name: "arp", frame: frameReaderTestARP()},
+		{name: "smb2 negotiate", frame: testTCPFrame(PORT_SMB, smbTestNegotiateRequest(0x0202, 0x0311))},
+		{name: "icmpv6", frame: checksumReportTestICMPv6Frame()},
+		{name: "6in4", frame: ipTunnelTestFrame(IPv4_PROTO_IPv6, checksumReportTestICMPv6Frame()[14:])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passive, err := DecodeFrame(tt.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(passive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]any
+			if err := json.Unmarshal(b, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if fields["_schema"] != float64(PASSIVE_JSON_SCHEMA_VERSION) {
+				t.Errorf("_schema = %v, want %d", fields["_schema"], PASSIVE_JSON_SCHEMA_VERSION)
+			}
+
+			got := &PassiveJSON{}
+			if err := json.Unmarshal(b, got); err != nil {
+				t.Fatal(err)
+			}
+			if want := NewPassiveJSON(passive); !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip mismatch\ngot:  %+v\nwant: %+v\njson: %s", got, want, b)
+			}
+		})
+	}
+}
+
+// TestPassiveJSONSchemaV1 tests the field names of schema version 1 and that unknown keys are ignored
+// スキーマバージョン1のフィールド名と、未知のキーが無視されることをテストします
+func TestPassiveJSONSchemaV1(t *testing.T) {
+	// 後のバージョンで追加されるレイヤやフィールドを含んでいても読める
+	input := `{
+		"_schema": 1,
+		"length": 74,
+		"eth": {"dst": "00:15:5d:fb:bf:3a", "src": "00:15:5d:fb:bf:3b", "type": 2048},
+		"ipv4": {"ihl": 20, "total_length": 60, "ttl": 64, "protocol": 6, "src": "192.168.10.1", "dst": "192.168.10.2", "dscp": 0},
+		"tcp": {"src_port": 50000, "dst_port": 80, "seq": 1, "flags": 2, "options": "020405b4"},
+		"quic": {"version": 1}
+	}`
+	got := &PassiveJSON{}
+	if err := json.Unmarshal([]byte(input), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Schema != 1 || got.Length != 74 {
+		t.Errorf("Schema, Length = %d, %d, want 1, 74", got.Schema, got.Length)
+	}
+	if got.Ethernet == nil || got.Ethernet.Src != "00:15:5d:fb:bf:3b" || got.Ethernet.Type != ETHER_TYPE_IPv4 {
+		t.Errorf("Ethernet = %+v", got.Ethernet)
+	}
+	if got.IPv4 == nil || got.IPv4.Src != "192.168.10.1" || got.IPv4.TTL != 64 {
+		t.Errorf("IPv4 = %+v", got.IPv4)
+	}
+	if got.TCP == nil || got.TCP.DstPort != 80 || got.TCP.Flags != TCP_FLAGS_SYN || !reflect.DeepEqual(got.TCP.Options, HexBytes{0x02, 0x04, 0x05, 0xb4}) {
+		t.Errorf("TCP = %+v", got.TCP)
+	}
+}
@@ -0,0 +1,169 @@
+package packemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// REDACT_PAYLOAD redacts the payload, data and body fields of every layer and the DER of certificates, keeping the headers
+// すべてのレイヤのpayload、data、bodyフィールドと証明書のDERを除き、ヘッダーを残します
+const REDACT_PAYLOAD = "payload"
+
+// Redaction leaves layers or fields out of the JSON and CBOR exports, for privacy or size. The zero value keeps everything.
+// An entry is REDACT_PAYLOAD, the key of a layer ("tls") or a top-level field ("interface"), or a layer key and one of its
+// field keys ("http.body", "ipv4.src"), as in PassiveJSON. Redacted fields that are always emitted, such as addresses,
+// are emitted with their zero value. Inner packets of tunnels are redacted the same way
+// プライバシーやサイズのために、JSONとCBORの出力からレイヤやフィールドを除きます。ゼロ値はすべてを残します。
+// 指定はREDACT_PAYLOAD、レイヤのキー("tls")またはトップレベルのフィールドのキー("interface")、
+// レイヤのキーとそのフィールドのキー("http.body"、"ipv4.src")で、PassiveJSONのキーと同じです。
+// アドレスなど常に出力されるフィールドはゼロ値で出力されます。トンネルの内側のパケットも同じように除きます
+type Redaction struct {
+	payloads bool
+	keys     map[string]struct{}
+}
+
+// ParseRedaction parses a comma separated list of entries, e.g. "payload,tls,http.headers"
+// カンマ区切りの指定を解析します。例えば"payload,tls,http.headers"
+func ParseRedaction(s string) (Redaction, error) {
+	r := Redaction{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == REDACT_PAYLOAD:
+			r.payloads = true
+		case validRedactionKey(entry):
+			if r.keys == nil {
+				r.keys = map[string]struct{}{}
+			}
+			r.keys[entry] = struct{}{}
+		default:
+			return Redaction{}, fmt.Errorf("unknown layer or field to redact: %s", entry)
+		}
+	}
+	return r, nil
+}
+
+// Empty reports whether nothing is redacted
+// 何も除かないかどうかを返します
+func (r Redaction) Empty() bool {
+	return !r.payloads && len(r.keys) == 0
+}
+
+// PassiveJSON converts p into the current JSON schema with the redacted layers and fields left out
+// pを現在のJSONのスキーマに変換し、指定したレイヤとフィールドを除きます
+func (r Redaction) PassiveJSON(p *Passive) *PassiveJSON {
+	pj := NewPassiveJSON(p)
+	r.apply(reflect.ValueOf(pj).Elem())
+	return pj
+}
+
+// PassiveJSON の構造体を直接書き換える. レイヤは 1 段目の構造体へのポインタ
+func (r Redaction) apply(v reflect.Value) {
+	if r.Empty() {
+		return
+	}
+	for _, f := range cborFields(v.Type()) {
+		field := v.Field(f.index)
+		if r.redacted(f.key) {
+			field.SetZero()
+			continue
+		}
+		if field.Kind() != reflect.Pointer || field.IsNil() || field.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if field.Type() == reflect.TypeFor[*PassiveJSON]() {
+			r.apply(field.Elem())
+			continue
+		}
+
+		layer := field.Elem()
+		for _, lf := range cborFields(layer.Type()) {
+			value := layer.Field(lf.index)
+			switch {
+			case r.redacted(f.key + "." + lf.key), r.payloads && redactionPayloadKey(lf.key):
+				value.SetZero()
+			case value.Kind() != reflect.Pointer || value.IsNil():
+			case value.Type() == reflect.TypeFor[*PassiveJSON]():
+				// GENEVE の内側のパケット
+				r.apply(value.Elem())
+			case r.payloads && value.Elem().Kind() == reflect.Struct:
+				// TLS の証明書など、レイヤの中の構造体
+				for _, nf := range cborFields(value.Elem().Type()) {
+					if redactionPayloadKey(nf.key) {
+						value.Elem().Field(nf.index).SetZero()
+					}
+				}
+			}
+		}
+	}
+}
+
+func (r Redaction) redacted(key string) bool {
+	_, ok := r.keys[key]
+	return ok
+}
+
+// ヘッダーではなく中身を持つフィールド
+func redactionPayloadKey(key string) bool {
+	switch key {
+	case "payload", "data", "body", "der":
+		return true
+	}
+	return false
+}
+
+func validRedactionKey(key string) bool {
+	layerKey, fieldKey, nested := strings.Cut(key, ".")
+	pjType := reflect.TypeFor[PassiveJSON]()
+	for _, f := range cborFields(pjType) {
+		if f.key != layerKey || f.key == "_schema" {
+			continue
+		}
+		if !nested {
+			return true
+		}
+		t := pjType.Field(f.index).Type
+		if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+			return false
+		}
+		for _, lf := range cborFields(t.Elem()) {
+			if lf.key == fieldKey {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// JSONEncoder writes packets to a stream as JSON lines, one packet per line, like MarshalJSON with a Redaction applied.
+// Encode can be passed to NetworkInterface.Capture to stream captured packets.
+// パケットを1行1パケットのJSONとしてストリームに書き込みます。MarshalJSONにRedactionを適用したものと同じです。
+// EncodeをNetworkInterface.Captureに渡すと、キャプチャしたパケットをストリームに書き込めます
+type JSONEncoder struct {
+	enc       *json.Encoder
+	redaction Redaction
+}
+
+// NewJSONEncoder returns an encoder writing to w
+// wに書き込むエンコーダーを返します
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{enc: json.NewEncoder(w)}
+}
+
+// SetRedaction sets the layers and fields left out of the packets encoded after it
+// これ以降にエンコードするパケットから除くレイヤとフィールドを設定します
+func (enc *JSONEncoder) SetRedaction(r Redaction) {
+	enc.redaction = r
+}
+
+// Encode writes the JSON encoding of p followed by a newline
+// pのJSONエンコーディングと改行を書き込みます
+func (enc *JSONEncoder) Encode(p *Passive) error {
+	return enc.enc.Encode(enc.redaction.PassiveJSON(p))
+}
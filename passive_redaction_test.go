@@ -0,0 +1,82 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRedaction tests that a redacted JSON or CBOR export omits the payloads and the chosen layers and fields but keeps the headers
+// JSONやCBORの出力から、ペイロードと指定したレイヤ・フィールドが除かれ、ヘッダーが残ることをテストします
+func TestRedaction(t *testing.T) {
+	passive, err := DecodeFrame(parseDepthTestFrame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.TCP == nil || len(passive.TCP.Payload) == 0 || passive.HTTP == nil {
+		t.Fatalf("TCP = %+v, HTTP = %+v", passive.TCP, passive.HTTP)
+	}
+
+	redaction, err := ParseRedaction("payload, http.headers,ipv4.src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonOut, cborOut := &bytes.Buffer{}, &bytes.Buffer{}
+	jsonEnc, cborEnc := NewJSONEncoder(jsonOut), NewCBOREncoder(cborOut)
+	jsonEnc.SetRedaction(redaction)
+	cborEnc.SetRedaction(redaction)
+	if err := jsonEnc.Encode(passive); err != nil {
+		t.Fatal(err)
+	}
+	if err := cborEnc.Encode(passive); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(jsonOut.String(), `"payload"`) || strings.Contains(jsonOut.String(), `"headers"`) {
+		t.Errorf("the payload and the HTTP headers should be omitted: %s", jsonOut)
+	}
+	got := &PassiveJSON{}
+	if err := json.Unmarshal(jsonOut.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TCP == nil || got.TCP.SrcPort != 50000 || got.TCP.Flags != TCP_FLAGS_PSH_ACK || got.TCP.Payload != nil {
+		t.Errorf("TCP = %+v, want the header without the payload", got.TCP)
+	}
+	if got.HTTP == nil || got.HTTP.Method != "GET" || got.HTTP.Headers != nil {
+		t.Errorf("HTTP = %+v, want the request line without the headers", got.HTTP)
+	}
+	if got.IPv4 == nil || got.IPv4.Src != "" || got.IPv4.Dst == "" {
+		t.Errorf("IPv4 = %+v, want only the source address redacted", got.IPv4)
+	}
+
+	// CBOR も同じものを除く
+	fromCBOR := &PassiveJSON{}
+	if err := UnmarshalCBOR(cborOut.Bytes(), fromCBOR); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromCBOR, redaction.PassiveJSON(passive)) {
+		t.Errorf("CBOR = %+v\nwant %+v", fromCBOR, redaction.PassiveJSON(passive))
+	}
+
+	// レイヤごと除く
+	redaction, err = ParseRedaction("http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pj := redaction.PassiveJSON(passive); pj.HTTP != nil || pj.TCP == nil || pj.TCP.Payload == nil {
+		t.Errorf("HTTP = %+v, TCP = %+v, want only HTTP omitted", pj.HTTP, pj.TCP)
+	}
+
+	// デフォルトではすべて残す
+	if pj := (Redaction{}).PassiveJSON(passive); !reflect.DeepEqual(pj, NewPassiveJSON(passive)) {
+		t.Errorf("the zero Redaction should keep everything: %+v", pj)
+	}
+
+	for _, invalid := range []string{"nope", "tcp.nope", "interface.name", "_schema"} {
+		if _, err := ParseRedaction(invalid); err == nil {
+			t.Errorf("ParseRedaction(%q) succeeded", invalid)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 名前を結合する順. "[SYN,ACK]" のように SYN と FIN を先にする
+var tcpFlagNames = []struct {
+	flag uint8
+	name string
+}{
+	{TCP_FLAGS_SYN, "SYN"},
+	{TCP_FLAGS_FIN, "FIN"},
+	{TCP_FLAGS_RST, "RST"},
+	{0x08, "PSH"},
+	{TCP_FLAGS_ACK, "ACK"},
+	{0x20, "URG"},
+	{0x40, "ECE"},
+	{0x80, "CWR"},
+}
+
+// String returns a one-line summary of the packet, the most meaningful fields of each decoded layer from the outermost to the innermost,
+// e.g. "IPv4 10.0.0.1→10.0.0.2 TCP 443→51000 [SYN,ACK] TLS". The link layer is only shown for a frame without a network layer
+// パケットの1行の要約を返します。デコードした各レイヤの主な値を外側から内側の順に並べます
+// (例: "IPv4 10.0.0.1→10.0.0.2 TCP 443→51000 [SYN,ACK] TLS")。リンク層はネットワーク層の無いフレームの場合のみ表示します
+func (p *Passive) String() string {
+	parts := p.summary(nil)
+	if len(parts) == 0 {
+		return fmt.Sprintf("Unknown (%d bytes)", p.RawLength)
+	}
+	if p.Truncated {
+		parts = append(parts, "[truncated]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p *Passive) summary(parts []string) []string {
+	switch {
+	case p.IPv4 != nil, p.IPv6 != nil, p.ARP != nil:
+	case p.EthernetFrame != nil:
+		parts = append(parts, fmt.Sprintf("Ethernet %s→%s %s",
+			net.HardwareAddr(p.EthernetFrame.SrcAddr), net.HardwareAddr(p.EthernetFrame.DstAddr), EtherTypeName(p.EthernetFrame.Type)))
+	case p.IEEE80211 != nil:
+		parts = append(parts, "IEEE 802.11 "+p.IEEE80211.Name())
+	}
+
+	if p.ARP != nil {
+		switch p.ARP.Operation {
+		case ARP_OPERATION_CODE_REQUEST:
+			parts = append(parts, fmt.Sprintf("ARP who-has %s tell %s", net.IP(p.ARP.TargetIP), net.IP(p.ARP.SenderIP)))
+		case ARP_OPERATION_CODE_REPLY:
+			parts = append(parts, fmt.Sprintf("ARP %s is-at %s", net.IP(p.ARP.SenderIP), net.HardwareAddr(p.ARP.SenderMAC)))
+		default:
+			parts = append(parts, fmt.Sprintf("ARP op=%d", p.ARP.Operation))
+		}
+	}
+	if p.IPv4 != nil {
+		parts = append(parts, fmt.Sprintf("IPv4 %s→%s", net.IP(p.IPv4.SrcIP), net.IP(p.IPv4.DstIP)))
+	}
+	if p.IPv6 != nil {
+		parts = append(parts, fmt.Sprintf("IPv6 %s→%s", net.IP(p.IPv6.SrcIP), net.IP(p.IPv6.DstIP)))
+	}
+	// IP-in-IP トンネルの内側のパケットはそのまま続ける
+	if p.Inner != nil {
+		return p.Inner.summary(parts)
+	}
+
+	if p.ICMP != nil {
+		parts = append(parts, icmpSummary("ICMP", p.ICMP.Type, p.ICMP.Code, ICMP_TYPE_REQUEST, ICMP_TYPE_REPLY, p.ICMP.ID, p.ICMP.Sequence))
+	}
+	if p.ICMPv6 != nil {
+		var id, seq uint16
+		if len(p.ICMPv6.Payload) >= 4 {
+			id, seq = binary.BigEndian.Uint16(p.ICMPv6.Payload[0:2]), binary.BigEndian.Uint16(p.ICMPv6.Payload[2:4])
+		}
+		parts = append(parts, icmpSummary("ICMPv6", p.ICMPv6.Type, p.ICMPv6.Code, ICMPv6_TYPE_ECHO_REQUEST, ICMPv6_TYPE_ECHO_REPLY, id, seq))
+	}
+	if p.TCP != nil {
+		parts = append(parts, fmt.Sprintf("TCP %d→%d [%s]", p.TCP.SrcPort, p.TCP.DstPort, tcpFlagsString(p.TCP.Flags)))
+	}
+	if p.UDP != nil {
+		parts = append(parts, fmt.Sprintf("UDP %d→%d", p.UDP.SrcPort, p.UDP.DstPort))
+	}
+
+	switch {
+	case p.GENEVE != nil:
+		parts = append(parts, fmt.Sprintf("GENEVE vni=%d", p.GENEVE.VNI))
+		if p.GENEVE.Inner != nil {
+			return p.GENEVE.Inner.summary(parts)
+		}
+	case p.TLS != nil:
+		parts = append(parts, "TLS")
+	case p.DNS != nil:
+		if IsDNSResponse(p.DNS.Flags) {
+			parts = append(parts, fmt.Sprintf("DNS response id=0x%04x %s", p.DNS.ID, DNSRcodeName(DNSRcode(p.DNS.Flags))))
+		} else {
+			parts = append(parts, fmt.Sprintf("DNS query id=0x%04x", p.DNS.ID))
+		}
+	case p.HTTP != nil:
+		parts = append(parts, fmt.Sprintf("HTTP %s %s", p.HTTP.Method, p.HTTP.URI))
+	case p.HTTPRes != nil:
+		parts = append(parts, fmt.Sprintf("HTTP %d %s", p.HTTPRes.StatusCode, p.HTTPRes.Status))
+	case p.RTP != nil:
+		parts = append(parts, fmt.Sprintf("RTP pt=%d seq=%d", p.RTP.PayloadType, p.RTP.SequenceNumber))
+	case p.SMB != nil:
+		if name := p.SMB.CommandName(); name != "" {
+			parts = append(parts, "SMB2 "+name)
+		} else {
+			parts = append(parts, "SMB")
+		}
+	}
+	return parts
+}
+
+// echo request と echo reply は ID とシーケンス番号も表示する
+func icmpSummary(protocol string, typ, code, echoRequest, echoReply uint8, id, seq uint16) string {
+	switch typ {
+	case echoRequest:
+		return fmt.Sprintf("%s echo request id=%d seq=%d", protocol, id, seq)
+	case echoReply:
+		return fmt.Sprintf("%s echo reply id=%d seq=%d", protocol, id, seq)
+	}
+	return fmt.Sprintf("%s type=%d code=%d", protocol, typ, code)
+}
+
+func tcpFlagsString(flags uint8) string {
+	names := []string{}
+	for _, f := range tcpFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
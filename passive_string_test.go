@@ -0,0 +1,91 @@
+package packemon
+
+import "testing"
+
+func TestPassiveString(t *testing.T) {
+	tests := []struct {
+		name    string
+		passive *Passive
+		want    string
+	}{
+		{
+			name: "tls over tcp",
+			passive: &Passive{
+				EthernetFrame: &EthernetFrame{Type: ETHER_TYPE_IPv4},
+				IPv4:          &IPv4Packet{SrcIP: []byte{10, 0, 0, 1}, DstIP: []byte{10, 0, 0, 2}},
+				TCP:           &TCPPacket{SrcPort: 443, DstPort: 51000, Flags: TCP_FLAGS_SYN_ACK},
+				TLS:           &TLSRecord{Type: 22},
+			},
+			want: "IPv4 10.0.0.1→10.0.0.2 TCP 443→51000 [SYN,ACK] TLS",
+		},
+		{
+			name: "dns response over ipv6",
+			passive: &Passive{
+				IPv6: &IPv6Packet{SrcIP: []byte{0x20, 0x01, 0x0d, 0xb8, 15: 0x01}, DstIP: []byte{0x20, 0x01, 0x0d, 0xb8, 15: 0x02}},
+				UDP:  &UDPPacket{SrcPort: 53, DstPort: 40000},
+				DNS:  &DNSPacket{ID: 0x1a2b, Flags: 0x8183},
+			},
+			want: "IPv6 2001:db8::1→2001:db8::2 UDP 53→40000 DNS response id=0x1a2b NXDOMAIN",
+		},
+		{
+			name: "http request",
+			passive: &Passive{
+				IPv4: &IPv4Packet{SrcIP: []byte{192, 168, 0, 10}, DstIP: []byte{192, 168, 0, 1}},
+				TCP:  &TCPPacket{SrcPort: 51000, DstPort: 80, Flags: TCP_FLAGS_PSH_ACK},
+				HTTP: &HTTPRequest{Method: "GET", URI: "/index.html", Version: "HTTP/1.1"},
+			},
+			want: "IPv4 192.168.0.10→192.168.0.1 TCP 51000→80 [PSH,ACK] HTTP GET /index.html",
+		},
+		{
+			name: "icmpv6 echo request",
+			passive: &Passive{
+				IPv6:   &IPv6Packet{SrcIP: []byte{0xfe, 0x80, 15: 0x01}, DstIP: []byte{0xfe, 0x80, 15: 0x02}},
+				ICMPv6: &ICMPv6Packet{Type: 128, Payload: []byte{0x00, 0x07, 0x00, 0x01}},
+			},
+			want: "IPv6 fe80::1→fe80::2 ICMPv6 echo request id=7 seq=1",
+		},
+		{
+			name: "ip in ip",
+			passive: &Passive{
+				IPv4: &IPv4Packet{SrcIP: []byte{203, 0, 113, 1}, DstIP: []byte{203, 0, 113, 2}},
+				Inner: &Passive{
+					IPv4: &IPv4Packet{SrcIP: []byte{10, 0, 0, 1}, DstIP: []byte{10, 0, 0, 2}},
+					ICMP: &ICMPPacket{Type: 3, Code: 1},
+				},
+			},
+			want: "IPv4 203.0.113.1→203.0.113.2 IPv4 10.0.0.1→10.0.0.2 ICMP type=3 code=1",
+		},
+		{
+			name: "arp request",
+			passive: &Passive{
+				EthernetFrame: &EthernetFrame{Type: ETHER_TYPE_ARP},
+				ARP:           &ARPPacket{Operation: 1, SenderIP: []byte{192, 168, 0, 10}, TargetIP: []byte{192, 168, 0, 1}},
+			},
+			want: "ARP who-has 192.168.0.1 tell 192.168.0.10",
+		},
+		{
+			name: "truncated ethernet frame",
+			passive: &Passive{
+				EthernetFrame: &EthernetFrame{
+					SrcAddr: []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+					DstAddr: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+					Type:    0x88b5,
+				},
+				Truncated: true,
+			},
+			want: "Ethernet 02:00:00:00:00:01→ff:ff:ff:ff:ff:ff Unknown (0x88b5) [truncated]",
+		},
+		{
+			name:    "raw only",
+			passive: &Passive{Raw: make([]byte, 60), RawLength: 60},
+			want:    "Unknown (60 bytes)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.passive.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
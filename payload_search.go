@@ -0,0 +1,229 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// レイヤ名と、パケットからそのレイヤのペイロードを取り出す関数. レイヤが無ければ nil を返す
+type payloadLayer struct {
+	name    string
+	payload func(p *Passive) []byte
+}
+
+// 上位のレイヤから順に並べる. レイヤを指定しない検索では最初に見つかったレイヤを返す
+var payloadLayers = []payloadLayer{
+	{"http", httpBody},
+	{"tls", func(p *Passive) []byte {
+		if p.TLS != nil {
+			return p.TLS.Data
+		}
+		return nil
+	}},
+	{"tcp", func(p *Passive) []byte {
+		if p.TCP != nil {
+			return p.TCP.Payload
+		}
+		return nil
+	}},
+	{"udp", func(p *Passive) []byte {
+		if p.UDP != nil {
+			return p.UDP.Payload
+		}
+		return nil
+	}},
+	{"icmp", func(p *Passive) []byte {
+		if p.ICMP != nil {
+			return p.ICMP.Payload
+		}
+		return nil
+	}},
+	{"ipv4", func(p *Passive) []byte {
+		if p.IPv4 != nil {
+			return p.IPv4.Payload
+		}
+		return nil
+	}},
+	{"ipv6", func(p *Passive) []byte {
+		if p.IPv6 != nil {
+			return p.IPv6.Payload
+		}
+		return nil
+	}},
+	{"eth", func(p *Passive) []byte {
+		if p.EthernetFrame != nil {
+			return p.EthernetFrame.Payload
+		}
+		return nil
+	}},
+}
+
+// HTTP のボディ. Content-Length の無いリクエストなど解析したボディが空の場合は、TCP のペイロードのヘッダーの後ろを使う
+func httpBody(p *Passive) []byte {
+	switch {
+	case p.HTTP != nil && len(p.HTTP.Body) > 0:
+		return p.HTTP.Body
+	case p.HTTPRes != nil && len(p.HTTPRes.Body) > 0:
+		return p.HTTPRes.Body
+	case (p.HTTP != nil || p.HTTPRes != nil) && p.TCP != nil:
+		if i := bytes.Index(p.TCP.Payload, []byte("\r\n\r\n")); i >= 0 {
+			return p.TCP.Payload[i+4:]
+		}
+	}
+	return nil
+}
+
+// PayloadMatch is a packet whose payload contains the pattern. Offset is where it starts in the payload of Layer
+// ペイロードにパターンを含むパケットです。OffsetはLayerのペイロード内でパターンが始まる位置です
+type PayloadMatch struct {
+	Packet *Passive
+	Layer  string
+	Offset int
+}
+
+// PayloadSearch looks for a byte pattern in the payload of one layer of packets, e.g. a string in HTTP bodies.
+// ASCII letters are compared case-insensitively when IgnoreCase is set.
+// パケットの1つのレイヤのペイロードからバイト列のパターンを探します(HTTPのボディ内の文字列など)。
+// IgnoreCaseの場合、ASCIIの英字の大文字と小文字を区別しません
+type PayloadSearch struct {
+	pattern    []byte
+	layers     []payloadLayer
+	IgnoreCase bool
+}
+
+// NewPayloadSearch creates a search for pattern in the payload of layer: "http" (the body), "tls", "tcp", "udp", "icmp", "ipv4", "ipv6" or "eth".
+// An empty layer searches the layers from the top and reports the first one the pattern is found in.
+// layer("http"(ボディ)、"tls"、"tcp"、"udp"、"icmp"、"ipv4"、"ipv6"、"eth")のペイロードからpatternを探す検索を作成します。
+// layerが空の場合は上位のレイヤから順に探し、最初に見つかったレイヤを返します
+func NewPayloadSearch(pattern []byte, layer string) (*PayloadSearch, error) {
+	if len(pattern) == 0 {
+		return nil, errors.New("empty search pattern")
+	}
+	s := &PayloadSearch{pattern: pattern}
+	if layer == "" {
+		s.layers = payloadLayers
+		return s, nil
+	}
+	for _, l := range payloadLayers {
+		if strings.EqualFold(layer, l.name) {
+			s.layers = []payloadLayer{l}
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported payload layer: %s", layer)
+}
+
+// ParseHexPattern parses a hex pattern such as "deadbeef", "0xdeadbeef" or "de:ad:be:ef". Spaces, colons and dashes between bytes are ignored
+// "deadbeef"、"0xdeadbeef"、"de:ad:be:ef"のような16進のパターンを解析します。バイト間の空白、コロン、ハイフンは無視します
+func ParseHexPattern(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+	s = strings.Map(func(r rune) rune {
+		if r == ':' || r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	pattern, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex pattern: %w", err)
+	}
+	return pattern, nil
+}
+
+// Match reports whether the pattern is in the packet, and where
+// パケットにパターンが含まれるかと、その位置を返します
+func (s *PayloadSearch) Match(passive *Passive) (PayloadMatch, bool) {
+	if passive == nil {
+		return PayloadMatch{}, false
+	}
+	for _, l := range s.layers {
+		payload := l.payload(passive)
+		if len(payload) == 0 {
+			continue
+		}
+		if offset := s.index(payload); offset >= 0 {
+			return PayloadMatch{Packet: passive, Layer: l.name, Offset: offset}, true
+		}
+	}
+	return PayloadMatch{}, false
+}
+
+func (s *PayloadSearch) index(payload []byte) int {
+	if !s.IgnoreCase {
+		return bytes.Index(payload, s.pattern)
+	}
+	// bytes.EqualFold は UTF-8 として比較するので、バイナリのペイロードでもずれないよう ASCII だけを畳み込む
+	for i := 0; i+len(s.pattern) <= len(payload); i++ {
+		if equalFoldASCII(payload[i:i+len(s.pattern)], s.pattern) {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalFoldASCII(a, b []byte) bool {
+	for i := range a {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// Search returns the packets containing the pattern, in order
+// パターンを含むパケットを順に返します
+func (s *PayloadSearch) Search(packets []*Passive) []PayloadMatch {
+	matches := []PayloadMatch{}
+	for _, passive := range packets {
+		if match, ok := s.Match(passive); ok {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// SearchReader reads pr to the end and returns the packets containing the pattern, e.g. to search a capture piped from tcpdump
+// prを最後まで読み込み、パターンを含むパケットを返します(tcpdumpからパイプしたキャプチャの検索など)
+func (s *PayloadSearch) SearchReader(pr PacketReader) ([]PayloadMatch, error) {
+	matches := []PayloadMatch{}
+	for i := 1; ; i++ {
+		passive, err := pr.Next()
+		if errors.Is(err, io.EOF) {
+			return matches, nil
+		}
+		if err != nil {
+			return matches, fmt.Errorf("frame %d: %w", i, err)
+		}
+		if match, ok := s.Match(passive); ok {
+			matches = append(matches, match)
+		}
+	}
+}
+
+// SearchPayload returns the packets whose payload of layer contains pattern. See NewPayloadSearch for the layers
+// layerのペイロードにpatternを含むパケットを返します。レイヤはNewPayloadSearchを参照してください
+func SearchPayload(packets []*Passive, pattern []byte, layer string) ([]PayloadMatch, error) {
+	s, err := NewPayloadSearch(pattern, layer)
+	if err != nil {
+		return nil, err
+	}
+	return s.Search(packets), nil
+}
+
+// SearchPayload returns the packets kept whose payload of layer contains pattern, oldest first. Use PayloadSearch.Search with Packets for a case-insensitive search
+// layerのペイロードにpatternを含む保持中のパケットを古い順に返します。大文字と小文字を区別しない場合はPacketsとPayloadSearch.Searchを使ってください
+func (r *RingCapture) SearchPayload(pattern []byte, layer string) ([]PayloadMatch, error) {
+	return SearchPayload(r.Packets(), pattern, layer)
+}
@@ -0,0 +1,98 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// TCP 80 の HTTP POST リクエストのフレーム
+func payloadSearchTestHTTPFrame(body string) []byte {
+	return testTCPFrame(80, []byte("POST /login HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\n"+body))
+}
+
+// TestSearchPayload tests finding a string in an HTTP body, limited to a layer and case-insensitively
+// HTTPのボディ内の文字列を、レイヤを限定した検索と大文字小文字を区別しない検索で見つけることをテストします
+func TestSearchPayload(t *testing.T) {
+	ring := NewRingCapture(0, 0)
+	for _, frame := range [][]byte{
+		payloadSearchTestHTTPFrame("user=alice&password=hunter2"),
+		decodeStatsTestUDPFrame(40000, 9999, []byte("password=hunter2")),
+		payloadSearchTestHTTPFrame("user=bob&PASSWORD=letmein"),
+	} {
+		passive, err := DecodeFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ring.Add(passive)
+	}
+	packets := ring.Packets()
+
+	matches, err := ring.SearchPayload([]byte("password="), "http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Packet != packets[0] || matches[0].Layer != "http" || matches[0].Offset != len("user=alice&") {
+		t.Errorf("http matches = %+v, want the first packet at offset %d of the body", matches, len("user=alice&"))
+	}
+
+	search, err := NewPayloadSearch([]byte("password="), "HTTP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	search.IgnoreCase = true
+	if matches := search.Search(packets); len(matches) != 2 || matches[1].Packet != packets[2] || matches[1].Offset != len("user=bob&") {
+		t.Errorf("case-insensitive http matches = %+v, want the first and the last packet", matches)
+	}
+
+	// レイヤを指定しなければ UDP のペイロードからも見つかり、最も上位のレイヤを返す
+	matches, err = SearchPayload(packets, []byte("hunter2"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].Layer != "http" || matches[1].Layer != "udp" || matches[1].Offset != len("password=") {
+		t.Errorf("matches in any layer = %+v", matches)
+	}
+
+	// "POST" の 16 進
+	pattern, err := ParseHexPattern("0x50:4f:53 54")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := SearchPayload(packets, pattern, "tcp"); len(matches) != 2 || matches[0].Offset != 0 {
+		t.Errorf("hex pattern matches = %+v, want both HTTP packets at offset 0", matches)
+	}
+	if _, err := ParseHexPattern("50 4"); err == nil {
+		t.Error("an odd number of hex digits should be an error")
+	}
+	if _, err := SearchPayload(packets, pattern, "smtp"); err == nil {
+		t.Error("an unsupported layer should be an error")
+	}
+	if _, err := SearchPayload(packets, nil, "tcp"); err == nil {
+		t.Error("an empty pattern should be an error")
+	}
+
+	fr, err := OpenReader(bytes.NewReader(framedStream(packets[0].Raw, packets[1].Raw)), PCAP_LINKTYPE_ETHERNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpSearch, err := NewPayloadSearch([]byte("hunter2"), "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := udpSearch.SearchReader(fr); err != nil || len(matches) != 1 || matches[0].Packet.UDP == nil {
+		t.Errorf("SearchReader = %+v, %v, want the UDP packet", matches, err)
+	}
+
+	// pcap ファイルも同じように検索できる
+	file := pcapReaderTestFile(binary.LittleEndian, PCAP_MAGIC_MICROSECONDS, PCAP_LINKTYPE_ETHERNET,
+		pcapReaderTestRecord{data: packets[0].Raw, wireLength: len(packets[0].Raw)},
+		pcapReaderTestRecord{data: packets[1].Raw, wireLength: len(packets[1].Raw)})
+	pr, err := NewPcapReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := udpSearch.SearchReader(pr); err != nil || len(matches) != 1 || matches[0].Packet.UDP == nil {
+		t.Errorf("SearchReader of a pcap file = %+v, %v, want the UDP packet", matches, err)
+	}
+}
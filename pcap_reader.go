@@ -0,0 +1,235 @@
+package packemon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// PCAP_MAGIC_NANOSECONDS is the magic number of pcap files whose timestamps are in nanoseconds
+	// タイムスタンプがナノ秒のpcapファイルのマジックナンバーです
+	PCAP_MAGIC_NANOSECONDS = 0xa1b23c4d
+
+	// PCAP_LINKTYPE_LINUX_SLL is the Linux "cooked" capture of the any interface, with a 16 byte header instead of the link layer
+	// Linuxのanyインターフェースの"cooked"キャプチャです。リンク層の代わりに16バイトのヘッダーが付きます
+	PCAP_LINKTYPE_LINUX_SLL = 113
+	// PCAP_LINKTYPE_LINUX_SLL2 is the newer Linux "cooked" capture, with a 20 byte header including the interface index
+	// より新しいLinuxの"cooked"キャプチャです。インターフェースのインデックスを含む20バイトのヘッダーが付きます
+	PCAP_LINKTYPE_LINUX_SLL2 = 276
+	// PCAP_LINKTYPE_IPV4 and PCAP_LINKTYPE_IPV6 are raw IP packets of one version
+	// 1つのバージョンのIPパケットのみのリンクタイプです
+	PCAP_LINKTYPE_IPV4 = 228
+	PCAP_LINKTYPE_IPV6 = 229
+)
+
+const (
+	linuxSLLHeaderLength  = 16
+	linuxSLL2HeaderLength = 20
+)
+
+// LinkTypeSupported reports whether frames of the pcap link type can be decoded
+// pcapのリンクタイプのフレームをデコードできるかを返します
+func LinkTypeSupported(linkType int) bool {
+	switch linkType {
+	case PCAP_LINKTYPE_ETHERNET, PCAP_LINKTYPE_RAW, PCAP_LINKTYPE_IPV4, PCAP_LINKTYPE_IPV6,
+		PCAP_LINKTYPE_LINUX_SLL, PCAP_LINKTYPE_LINUX_SLL2,
+		PCAP_LINKTYPE_IEEE802_11, PCAP_LINKTYPE_IEEE802_11_RADIOTAP:
+		return true
+	}
+	return false
+}
+
+// IsPcapMagic reports whether the first 4 bytes of a stream are the magic number of a pcap file, in either byte order
+// ストリームの先頭4バイトがpcapファイルのマジックナンバーかを、どちらのバイトオーダーでも判定します
+func IsPcapMagic(b []byte) bool {
+	if len(b) < 4 {
+		return false
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		if magic := order.Uint32(b); magic == PCAP_MAGIC_MICROSECONDS || magic == PCAP_MAGIC_NANOSECONDS {
+			return true
+		}
+	}
+	return false
+}
+
+// PcapReader reads the packets of a pcap (libpcap) file and decodes them by the link type in the file header,
+// e.g. Linux cooked captures of the any interface or raw IP captures of a tunnel interface.
+// Packets of a link type that cannot be decoded are returned with only their raw bytes
+// pcap(libpcap)ファイルのパケットを読み込み、ファイルヘッダーのリンクタイプに従ってデコードします。
+// 例えばanyインターフェースのLinux cookedキャプチャや、トンネルインターフェースのIPのみのキャプチャを読めます。
+// デコードできないリンクタイプのパケットは生のバイト列のみで返します
+type PcapReader struct {
+	r           *bufio.Reader
+	order       binary.ByteOrder
+	nanoseconds bool
+	linkType    int
+	snapLen     uint32
+	fcsMode     FCSMode
+	decodeAs    DecodeAsTable
+	header      [PCAP_RECORD_HEADER_LENGTH]byte
+}
+
+// NewPcapReader reads the file header from r and returns a reader for the packet records
+// rからファイルヘッダーを読み込み、パケットのレコードを読み込むreaderを返します
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	pr := &PcapReader{r: bufio.NewReader(r)}
+	header := make([]byte, PCAP_FILE_HEADER_LENGTH)
+	if _, err := io.ReadFull(pr.r, header); err != nil {
+		return nil, fmt.Errorf("failed to read pcap file header: %w", err)
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(header[0:4]) == PCAP_MAGIC_MICROSECONDS:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[0:4]) == PCAP_MAGIC_MICROSECONDS:
+		pr.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header[0:4]) == PCAP_MAGIC_NANOSECONDS:
+		pr.order, pr.nanoseconds = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header[0:4]) == PCAP_MAGIC_NANOSECONDS:
+		pr.order, pr.nanoseconds = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap file: magic 0x%08x", binary.BigEndian.Uint32(header[0:4]))
+	}
+	pr.snapLen = pr.order.Uint32(header[16:20])
+	// 上位ビットは FCS の有無などに使われるので、下位 16 ビットがリンクタイプ
+	pr.linkType = int(pr.order.Uint32(header[20:24]) & 0xffff)
+	return pr, nil
+}
+
+// LinkType returns the link type in the file header
+// ファイルヘッダーのリンクタイプを返します
+func (pr *PcapReader) LinkType() int {
+	return pr.linkType
+}
+
+// SetFCSMode tells whether the Ethernet frames in the file end with the FCS, so that Next strips (and checks) it
+// ファイルのEthernetフレームの末尾にFCSが含まれるかを設定します。含まれる場合はNextが取り除き、検証します
+func (pr *PcapReader) SetFCSMode(mode FCSMode) {
+	pr.fcsMode = mode
+}
+
+// DecodeAs decodes the payload on port as proto in the packets read with Next, like NetworkInterface.DecodeAs
+// NetworkInterface.DecodeAsと同様に、Nextで読み込むパケットのportのペイロードをprotoとして解析します
+func (pr *PcapReader) DecodeAs(port uint16, proto string) error {
+	return pr.decodeAs.Set(port, proto)
+}
+
+// ReadPacket returns the next packet as is, with its capture time and its length on the wire.
+// It returns io.EOF at the end of the file and io.ErrUnexpectedEOF if the file ends in the middle of a record
+// 次のパケットを、キャプチャした時刻と回線上の長さとともにそのまま返します。
+// ファイルの終わりではio.EOF、レコードの途中で終わった場合はio.ErrUnexpectedEOFを返します
+func (pr *PcapReader) ReadPacket() ([]byte, time.Time, int, error) {
+	if _, err := io.ReadFull(pr.r, pr.header[:]); err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	timestamp := pcapTimeToGo(pr.header[0:8], pr.order, pr.nanoseconds)
+	capLen := pr.order.Uint32(pr.header[8:12])
+	wireLength := int(pr.order.Uint32(pr.header[12:16]))
+	if capLen > max(pr.snapLen, PCAP_DEFAULT_SNAPLEN) {
+		return nil, time.Time{}, 0, fmt.Errorf("pcap record length %d exceeds the snap length", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(pr.r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, time.Time{}, 0, io.ErrUnexpectedEOF
+		}
+		return nil, time.Time{}, 0, err
+	}
+	return data, timestamp, wireLength, nil
+}
+
+// Next reads and decodes the next packet. Errors are the same as ReadPacket, or a decode error for a malformed packet
+// 次のパケットを読み込んでデコードします
+func (pr *PcapReader) Next() (*Passive, error) {
+	data, timestamp, wireLength, err := pr.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	passive, err := decodeLinkLayer(data, wireLength, pr.linkType, pr.fcsMode, &pr.decodeAs)
+	if err != nil {
+		return nil, err
+	}
+	passive.Timestamp = timestamp
+	return passive, nil
+}
+
+// リンクタイプに応じて最上位のパーサーを選ぶ. デコードできないリンクタイプは生のバイト列のみにする
+func decodeLinkLayer(data []byte, wireLength int, linkType int, fcsMode FCSMode, decodeAs *DecodeAsTable) (*Passive, error) {
+	if wireLength < len(data) {
+		wireLength = len(data)
+	}
+	var passive *Passive
+	var err error
+	switch linkType {
+	case PCAP_LINKTYPE_ETHERNET:
+		var fcs []byte
+		var fcsStatus FCSStatus
+		data, wireLength, fcs, fcsStatus = stripFCS(data, wireLength, fcsMode)
+		passive, err = decodeFrame(data, wireLength, decodeAs, PARSE_DEPTH_FULL)
+		if err == nil {
+			passive.FCS, passive.FCSStatus = fcs, fcsStatus
+		}
+		return passive, err
+	case PCAP_LINKTYPE_IEEE802_11, PCAP_LINKTYPE_IEEE802_11_RADIOTAP:
+		return decodeIEEE80211Frame(data, wireLength, linkType, decodeAs, PARSE_DEPTH_FULL)
+	case PCAP_LINKTYPE_RAW, PCAP_LINKTYPE_IPV4, PCAP_LINKTYPE_IPV6:
+		passive, err = decodeRawIPPacket(data, decodeAs)
+	case PCAP_LINKTYPE_LINUX_SLL, PCAP_LINKTYPE_LINUX_SLL2:
+		passive, err = decodeLinuxSLLPacket(data, linkType, decodeAs)
+	default:
+		passive = &Passive{Raw: data, RawLength: len(data)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	passive.WireLength = wireLength
+	passive.Truncated = passive.Truncated || wireLength > len(data)
+	return passive, nil
+}
+
+// Linux cooked キャプチャのヘッダーの後ろのパケットを、プロトコルを EtherType として、
+// 送信元のリンク層アドレスを持つ EthernetFrame に入れて解析する. 宛先のアドレスはヘッダーに無い
+// ref: https://www.tcpdump.org/linktypes/LINKTYPE_LINUX_SLL.html
+func decodeLinuxSLLPacket(data []byte, linkType int, decodeAs *DecodeAsTable) (*Passive, error) {
+	var etherType uint16
+	var address []byte
+	var payload []byte
+	switch linkType {
+	case PCAP_LINKTYPE_LINUX_SLL:
+		if len(data) < linuxSLLHeaderLength {
+			return nil, errors.New("too short for a linux cooked capture header")
+		}
+		addressLength := min(int(binary.BigEndian.Uint16(data[4:6])), 8)
+		address = data[6 : 6+addressLength]
+		etherType = binary.BigEndian.Uint16(data[14:16])
+		payload = data[linuxSLLHeaderLength:]
+	default:
+		if len(data) < linuxSLL2HeaderLength {
+			return nil, errors.New("too short for a linux cooked capture v2 header")
+		}
+		etherType = binary.BigEndian.Uint16(data[0:2])
+		addressLength := min(int(data[11]), 8)
+		address = data[12 : 12+addressLength]
+		payload = data[linuxSLL2HeaderLength:]
+	}
+
+	passive := &Passive{
+		EthernetFrame: &EthernetFrame{
+			Type:    etherType,
+			Payload: payload,
+		},
+		Raw:       data,
+		RawLength: len(data),
+	}
+	if len(address) == 6 {
+		passive.EthernetFrame.SrcAddr = address
+	}
+	parseEthernetPayload(passive, decodeAs, PARSE_DEPTH_FULL)
+	return passive, nil
+}
@@ -0,0 +1,173 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+type pcapReaderTestRecord struct {
+	ts         time.Time
+	data       []byte
+	wireLength int
+}
+
+// order のバイトオーダーで linkType の pcap ファイルを組み立てる
+func pcapReaderTestFile(order binary.ByteOrder, magic uint32, linkType uint32, records ...pcapReaderTestRecord) []byte {
+	buf := make([]byte, PCAP_FILE_HEADER_LENGTH)
+	order.PutUint32(buf[0:4], magic)
+	order.PutUint16(buf[4:6], 2)
+	order.PutUint16(buf[6:8], 4)
+	order.PutUint32(buf[16:20], 65535)
+	order.PutUint32(buf[20:24], linkType)
+	for _, r := range records {
+		fraction := uint32(r.ts.Nanosecond() / 1000)
+		if magic == PCAP_MAGIC_NANOSECONDS {
+			fraction = uint32(r.ts.Nanosecond())
+		}
+		header := make([]byte, PCAP_RECORD_HEADER_LENGTH)
+		order.PutUint32(header[0:4], uint32(r.ts.Unix()))
+		order.PutUint32(header[4:8], fraction)
+		order.PutUint32(header[8:12], uint32(len(r.data)))
+		order.PutUint32(header[12:16], uint32(r.wireLength))
+		buf = append(append(buf, header...), r.data...)
+	}
+	return buf
+}
+
+// TestPcapReaderRawIP tests that the packets of a raw IP capture are decoded from the IP header, in both byte orders
+// and timestamp resolutions, with their timestamps and lengths on the wire
+// IPのみのキャプチャのパケットがIPヘッダーからデコードされ、タイムスタンプと回線上の長さが付くことを、
+// 両方のバイトオーダーとタイムスタンプの精度でテストします
+func TestPcapReaderRawIP(t *testing.T) {
+	ipv4 := parseDepthTestFrame()[14:]
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 123456000, time.UTC)
+
+	tests := []struct {
+		name     string
+		order    binary.ByteOrder
+		magic    uint32
+		linkType uint32
+	}{
+		{name: "little endian", order: binary.LittleEndian, magic: PCAP_MAGIC_MICROSECONDS, linkType: PCAP_LINKTYPE_RAW},
+		{name: "big endian nanoseconds", order: binary.BigEndian, magic: PCAP_MAGIC_NANOSECONDS, linkType: PCAP_LINKTYPE_RAW},
+		{name: "ipv4 link type", order: binary.LittleEndian, magic: PCAP_MAGIC_MICROSECONDS, linkType: PCAP_LINKTYPE_IPV4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := pcapReaderTestFile(tt.order, tt.magic, tt.linkType,
+				pcapReaderTestRecord{ts: ts, data: ipv4, wireLength: len(ipv4)},
+				pcapReaderTestRecord{ts: ts.Add(time.Second), data: ipv4[:40], wireLength: len(ipv4)},
+			)
+			if !IsPcapMagic(file) {
+				t.Errorf("IsPcapMagic = false")
+			}
+			pr, err := NewPcapReader(bytes.NewReader(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pr.LinkType() != int(tt.linkType) {
+				t.Errorf("LinkType() = %d, want %d", pr.LinkType(), tt.linkType)
+			}
+
+			first, err := pr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.IPv4 == nil || first.TCP == nil || first.HTTP == nil {
+				t.Errorf("raw ip packet should be decoded up to HTTP: %+v", first)
+			}
+			if !first.Timestamp.Equal(ts) || first.WireLength != len(ipv4) || first.Truncated {
+				t.Errorf("Timestamp, WireLength, Truncated = %v, %d, %t", first.Timestamp, first.WireLength, first.Truncated)
+			}
+
+			// スナップ長で切り詰められたパケット
+			second, err := pr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second.TCP == nil || !second.Truncated || second.WireLength != len(ipv4) || second.RawLength != 40 {
+				t.Errorf("second packet should be a truncated TCP segment: %+v", second)
+			}
+
+			if _, err := pr.Next(); err != io.EOF {
+				t.Errorf("err = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+// TestPcapReaderLinkTypes tests the Linux cooked captures and the fallback for a link type that cannot be decoded
+// Linuxのcookedキャプチャと、デコードできないリンクタイプの扱いをテストします
+func TestPcapReaderLinkTypes(t *testing.T) {
+	frame := parseDepthTestFrame()
+	ipv4 := frame[14:]
+	mac := frame[6:12]
+
+	sll := []byte{0x00, 0x04, 0x00, 0x01, 0x00, 0x06}
+	sll = append(append(sll, mac...), 0x00, 0x00, 0x08, 0x00)
+	sll2 := []byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x04, 0x06}
+	sll2 = append(append(sll2, mac...), 0x00, 0x00)
+
+	tests := []struct {
+		name     string
+		linkType uint32
+		data     []byte
+	}{
+		{name: "ethernet", linkType: PCAP_LINKTYPE_ETHERNET, data: frame},
+		{name: "linux cooked", linkType: PCAP_LINKTYPE_LINUX_SLL, data: append(sll, ipv4...)},
+		{name: "linux cooked v2", linkType: PCAP_LINKTYPE_LINUX_SLL2, data: append(sll2, ipv4...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := pcapReaderTestFile(binary.LittleEndian, PCAP_MAGIC_MICROSECONDS, tt.linkType, pcapReaderTestRecord{data: tt.data, wireLength: len(tt.data)})
+			pr, err := NewPcapReader(bytes.NewReader(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := pr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.TCP == nil || p.HTTP == nil || !bytes.Equal(p.EthernetFrame.SrcAddr, mac) || p.RawLength != len(tt.data) {
+				t.Errorf("packet should be decoded up to HTTP with the source MAC address: %+v", p)
+			}
+		})
+	}
+
+	// デコードできないリンクタイプのパケットは生のバイト列のみ
+	file := pcapReaderTestFile(binary.LittleEndian, PCAP_MAGIC_MICROSECONDS, 147, pcapReaderTestRecord{data: ipv4, wireLength: len(ipv4)})
+	pr, err := NewPcapReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if LinkTypeSupported(pr.LinkType()) {
+		t.Errorf("LinkTypeSupported(%d) = true", pr.LinkType())
+	}
+	p, err := pr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.IPv4 != nil || !bytes.Equal(p.Raw, ipv4) || p.WireLength != len(ipv4) {
+		t.Errorf("packet of an unsupported link type should only have its raw bytes: %+v", p)
+	}
+}
+
+func TestPcapReaderInvalid(t *testing.T) {
+	if _, err := NewPcapReader(bytes.NewReader(framedStream(frameReaderTestARP()))); err == nil {
+		t.Error("a stream without the pcap magic should be an error")
+	}
+
+	file := pcapReaderTestFile(binary.LittleEndian, PCAP_MAGIC_MICROSECONDS, PCAP_LINKTYPE_ETHERNET, pcapReaderTestRecord{data: frameReaderTestARP()})
+	pr, err := NewPcapReader(bytes.NewReader(file[:len(file)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pr.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,239 @@
+package packemon
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+// pcap (libpcap) file format
+// ref: https://datatracker.ietf.org/doc/draft-ietf-opsawg-pcap/
+// pcapファイルのフォーマット
+const (
+	PCAP_MAGIC_MICROSECONDS = 0xa1b2c3d4
+	PCAP_LINKTYPE_ETHERNET  = 1
+	PCAP_DEFAULT_SNAPLEN    = 262144
+
+	PCAP_FILE_HEADER_LENGTH   = 24
+	PCAP_RECORD_HEADER_LENGTH = 16
+)
+
+// PcapWriter writes Ethernet frames in the libpcap format with pcapgo.Writer
+// Ethernetフレームをpcapgo.Writerでpcap形式で書き込みます
+type PcapWriter struct {
+	w       *pcapgo.Writer
+	snapLen uint32
+}
+
+// NewPcapWriter writes the file header to w and returns a writer for the packet records.
+// A zero snapLen uses PCAP_DEFAULT_SNAPLEN.
+// wにファイルヘッダーを書き込み、パケットを書き込むためのwriterを返します
+func NewPcapWriter(w io.Writer, snapLen uint32) (*PcapWriter, error) {
+	if snapLen == 0 {
+		snapLen = PCAP_DEFAULT_SNAPLEN
+	}
+
+	pw := pcapgo.NewWriter(w)
+	if err := pw.WriteFileHeader(snapLen, layers.LinkTypeEthernet); err != nil {
+		return nil, err
+	}
+
+	return &PcapWriter{
+		w:       pw,
+		snapLen: snapLen,
+	}, nil
+}
+
+// WritePacket writes a frame captured at ts. Frames longer than the snap length are truncated.
+// tsにキャプチャしたフレームを書き込みます。スナップ長を超える部分は切り捨てます
+func (pw *PcapWriter) WritePacket(ts time.Time, frame []byte) error {
+	return pw.WriteTruncatedPacket(ts, frame, len(frame))
+}
+
+// WriteTruncatedPacket writes a frame captured at ts that was wireLength bytes long on the wire, e.g. one cut by the snap length
+// of the capture (see Passive.WireLength), so that the record keeps the original length. A wireLength below len(frame) is ignored
+// 回線上でwireLengthバイトだった、tsにキャプチャしたフレームを書き込みます。キャプチャのスナップ長で切り詰められたフレーム(Passive.WireLength参照)でも
+// 元の長さがレコードに残ります。len(frame)より小さいwireLengthは無視します
+func (pw *PcapWriter) WriteTruncatedPacket(ts time.Time, frame []byte, wireLength int) error {
+	captured := pw.captured(frame)
+	ci := gopacket.CaptureInfo{
+		Timestamp:     ts,
+		CaptureLength: len(captured),
+		Length:        max(len(frame), wireLength),
+	}
+	return pw.w.WritePacket(ci, captured)
+}
+
+// スナップ長で切り捨てた、レコードに書き込む部分
+func (pw *PcapWriter) captured(frame []byte) []byte {
+	if uint32(len(frame)) > pw.snapLen {
+		return frame[:pw.snapLen]
+	}
+	return frame
+}
+
+// RotateOptions configures RotatingPcapWriter, like tcpdump's -C, -G and -W
+// RotatingPcapWriterの設定です(tcpdumpの -C、-G、-W に相当)
+type RotateOptions struct {
+	// MaxFileSize rolls to a new file before a file would exceed this many bytes. Zero means no size limit.
+	// ファイルがこのバイト数を超える前に次のファイルに切り替えます。0なら制限なし
+	MaxFileSize int64
+	// MaxDuration rolls to a new file once the packet timestamps span this long. Zero means no time limit.
+	// パケットのタイムスタンプがこの時間を超えたら次のファイルに切り替えます。0なら制限なし
+	MaxDuration time.Duration
+	// MaxFiles keeps only the newest files, removing older ones. Zero keeps all files.
+	// 新しい順にこのファイル数だけ残し、古いものは削除します。0ならすべて残します
+	MaxFiles int
+	// SnapLen is passed to NewPcapWriter
+	SnapLen uint32
+}
+
+// RotatingPcapWriter writes packets to a series of pcap files named "<prefix>_<timestamp>_<index>.pcap" in dir.
+// Each file is flushed and fsynced when it is rotated out, so a crash loses at most the current file's buffered packets.
+// dir内の "<prefix>_<timestamp>_<index>.pcap" という一連のpcapファイルにパケットを書き込みます
+type RotatingPcapWriter struct {
+	dir    string
+	prefix string
+	opts   RotateOptions
+
+	index     int
+	file      *os.File
+	buf       *bufio.Writer
+	pw        *PcapWriter
+	size      int64
+	packets   int
+	firstSeen time.Time
+	files     []string
+}
+
+// NewRotatingPcapWriter creates dir if needed. The first file is opened on the first packet.
+// 必要であればdirを作成します。最初のファイルは最初のパケットで開きます
+func NewRotatingPcapWriter(dir string, prefix string, opts RotateOptions) (*RotatingPcapWriter, error) {
+	if opts.MaxFileSize < 0 || opts.MaxDuration < 0 || opts.MaxFiles < 0 {
+		return nil, errors.New("rotate options must not be negative")
+	}
+	if opts.SnapLen == 0 {
+		opts.SnapLen = PCAP_DEFAULT_SNAPLEN
+	}
+	if opts.MaxFileSize > 0 && opts.MaxFileSize < PCAP_FILE_HEADER_LENGTH+PCAP_RECORD_HEADER_LENGTH {
+		return nil, fmt.Errorf("max file size %d is too small for a pcap file", opts.MaxFileSize)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &RotatingPcapWriter{
+		dir:    dir,
+		prefix: prefix,
+		opts:   opts,
+	}, nil
+}
+
+// WritePacket writes a frame captured at ts, rolling to a new file first if a limit would be exceeded
+// tsにキャプチャしたフレームを書き込みます。制限を超える場合は先に次のファイルに切り替えます
+func (rw *RotatingPcapWriter) WritePacket(ts time.Time, frame []byte) error {
+	recordSize := int64(PCAP_RECORD_HEADER_LENGTH + min(len(frame), int(rw.opts.SnapLen)))
+
+	if rw.file != nil && rw.packets > 0 && rw.needsRotation(ts, recordSize) {
+		if err := rw.closeFile(); err != nil {
+			return err
+		}
+	}
+	if rw.file == nil {
+		if err := rw.openFile(ts); err != nil {
+			return err
+		}
+	}
+
+	if err := rw.pw.WritePacket(ts, frame); err != nil {
+		return err
+	}
+	rw.size += recordSize
+	rw.packets++
+	return nil
+}
+
+func (rw *RotatingPcapWriter) needsRotation(ts time.Time, recordSize int64) bool {
+	if rw.opts.MaxFileSize > 0 && rw.size+recordSize > rw.opts.MaxFileSize {
+		return true
+	}
+	if rw.opts.MaxDuration > 0 && ts.Sub(rw.firstSeen) >= rw.opts.MaxDuration {
+		return true
+	}
+	return false
+}
+
+func (rw *RotatingPcapWriter) openFile(ts time.Time) error {
+	rw.index++
+	name := filepath.Join(rw.dir, fmt.Sprintf("%s_%s_%04d.pcap", rw.prefix, ts.Format("20060102150405"), rw.index))
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(f)
+	pw, err := NewPcapWriter(buf, rw.opts.SnapLen)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rw.file = f
+	rw.buf = buf
+	rw.pw = pw
+	rw.size = PCAP_FILE_HEADER_LENGTH
+	rw.packets = 0
+	rw.firstSeen = ts
+	rw.files = append(rw.files, name)
+
+	// 上限を超えた古いファイルを削除する
+	if rw.opts.MaxFiles > 0 {
+		for len(rw.files) > rw.opts.MaxFiles {
+			if err := os.Remove(rw.files[0]); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			rw.files = rw.files[1:]
+		}
+	}
+	return nil
+}
+
+// 取りこぼさないよう、閉じる前に flush と fsync する
+func (rw *RotatingPcapWriter) closeFile() error {
+	f := rw.file
+	rw.file = nil
+	if err := rw.buf.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Files returns the paths of the files currently kept, oldest first
+// 現在残っているファイルのパスを古い順に返します
+func (rw *RotatingPcapWriter) Files() []string {
+	files := make([]string, len(rw.files))
+	copy(files, rw.files)
+	return files
+}
+
+// Close flushes and closes the current file
+// 現在のファイルをflushして閉じます
+func (rw *RotatingPcapWriter) Close() error {
+	if rw.file == nil {
+		return nil
+	}
+	return rw.closeFile()
+}
@@ -0,0 +1,115 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestPcapWriter tests the file header and the record of a written frame
+// 書き込んだファイルヘッダーとフレームのレコードをテストします
+func TestPcapWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	pw, err := NewPcapWriter(buf, 0)
+	if err != nil {
+		t.Fatalf("NewPcapWriter returned error: %v", err)
+	}
+	ts := time.Unix(1700000000, 123456000)
+	frame := GenerateRandomPacket(1)
+	if err := pw.WritePacket(ts, frame); err != nil {
+		t.Fatalf("WritePacket returned error: %v", err)
+	}
+
+	b := buf.Bytes()
+	if len(b) != PCAP_FILE_HEADER_LENGTH+PCAP_RECORD_HEADER_LENGTH+len(frame) {
+		t.Fatalf("len = %d, want %d", len(b), PCAP_FILE_HEADER_LENGTH+PCAP_RECORD_HEADER_LENGTH+len(frame))
+	}
+	if binary.LittleEndian.Uint32(b[0:4]) != PCAP_MAGIC_MICROSECONDS || binary.LittleEndian.Uint32(b[20:24]) != PCAP_LINKTYPE_ETHERNET {
+		t.Errorf("file header = %x", b[:PCAP_FILE_HEADER_LENGTH])
+	}
+	record := b[PCAP_FILE_HEADER_LENGTH:]
+	if binary.LittleEndian.Uint32(record[0:4]) != 1700000000 || binary.LittleEndian.Uint32(record[4:8]) != 123456 {
+		t.Errorf("timestamp = %x", record[0:8])
+	}
+	if binary.LittleEndian.Uint32(record[8:12]) != uint32(len(frame)) || binary.LittleEndian.Uint32(record[12:16]) != uint32(len(frame)) {
+		t.Errorf("lengths = %x", record[8:16])
+	}
+	if !bytes.Equal(record[PCAP_RECORD_HEADER_LENGTH:], frame) {
+		t.Errorf("frame = %x, want %x", record[PCAP_RECORD_HEADER_LENGTH:], frame)
+	}
+}
+
+// TestRotatingPcapWriterBySize tests that files roll over by size and only the last MaxFiles are kept
+// サイズでファイルが切り替わり、最後のMaxFiles個だけが残ることをテストします
+func TestRotatingPcapWriterBySize(t *testing.T) {
+	dir := t.TempDir()
+	frame := make([]byte, 100)
+	recordSize := int64(PCAP_RECORD_HEADER_LENGTH + len(frame))
+	// 1ファイルに2パケットまで
+	rw, err := NewRotatingPcapWriter(dir, "capture", RotateOptions{
+		MaxFileSize: PCAP_FILE_HEADER_LENGTH + 2*recordSize,
+		MaxFiles:    3,
+	})
+	if err != nil {
+		t.Fatalf("NewRotatingPcapWriter returned error: %v", err)
+	}
+
+	ts := time.Unix(1700000000, 0)
+	for i := 0; i < 11; i++ {
+		if err := rw.WritePacket(ts.Add(time.Duration(i)*time.Millisecond), frame); err != nil {
+			t.Fatalf("WritePacket returned error: %v", err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// 11パケット -> 6ファイル作られ、古い3ファイルは削除される
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("%d files in dir, want 3", len(entries))
+	}
+	files := rw.Files()
+	if len(files) != 3 {
+		t.Fatalf("Files() = %v, want 3 files", files)
+	}
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Stat(%s) returned error: %v", file, err)
+		}
+		// 最後のファイルは1パケットのみ
+		want := PCAP_FILE_HEADER_LENGTH + 2*recordSize
+		if i == len(files)-1 {
+			want = PCAP_FILE_HEADER_LENGTH + recordSize
+		}
+		if info.Size() != want {
+			t.Errorf("size of %s = %d, want %d", file, info.Size(), want)
+		}
+	}
+}
+
+// TestRotatingPcapWriterByDuration tests that files roll over when the packet timestamps span MaxDuration
+// パケットのタイムスタンプがMaxDurationを超えるとファイルが切り替わることをテストします
+func TestRotatingPcapWriterByDuration(t *testing.T) {
+	rw, err := NewRotatingPcapWriter(t.TempDir(), "capture", RotateOptions{MaxDuration: time.Minute})
+	if err != nil {
+		t.Fatalf("NewRotatingPcapWriter returned error: %v", err)
+	}
+	defer rw.Close()
+
+	ts := time.Unix(1700000000, 0)
+	for _, offset := range []time.Duration{0, 30 * time.Second, time.Minute, 90 * time.Second, 3 * time.Minute} {
+		if err := rw.WritePacket(ts.Add(offset), []byte{0x00}); err != nil {
+			t.Fatalf("WritePacket returned error: %v", err)
+		}
+	}
+	if files := rw.Files(); len(files) != 3 {
+		t.Errorf("Files() = %v, want 3 files", files)
+	}
+}
@@ -0,0 +1,128 @@
+package packemon
+
+import "strings"
+
+// Layers used by ProtocolInfo. They match the layer keys of the Generator.
+// ProtocolInfoで使うレイヤです。Generatorのレイヤのキーと同じです
+const (
+	LAYER_L2   = "L2"
+	LAYER_L3   = "L3"
+	LAYER_L4   = "L4"
+	LAYER_L5_6 = "L5/6"
+	LAYER_L7   = "L7"
+)
+
+// Categories used by ProtocolInfo, to group protocols in front-ends
+// ProtocolInfoで使う分類です。フロントエンドでプロトコルをまとめるために使います
+const (
+	PROTOCOL_CATEGORY_LINK        = "link"
+	PROTOCOL_CATEGORY_NETWORK     = "network"
+	PROTOCOL_CATEGORY_CONTROL     = "control" // ICMP / ICMPv6
+	PROTOCOL_CATEGORY_TRANSPORT   = "transport"
+	PROTOCOL_CATEGORY_ROUTING     = "routing"
+	PROTOCOL_CATEGORY_TUNNEL      = "tunnel"
+	PROTOCOL_CATEGORY_SECURITY    = "security"
+	PROTOCOL_CATEGORY_APPLICATION = "application"
+	PROTOCOL_CATEGORY_MEDIA       = "media"
+)
+
+// ProtocolInfo describes a protocol supported by packemon.
+// Zero EtherType, IPProtocol and Ports mean the protocol is not identified by them.
+// packemonがサポートするプロトコルの情報です。EtherType、IPProtocol、Portsがゼロ値の場合はそれで判別しません
+type ProtocolInfo struct {
+	Name       string
+	Layer      string
+	EtherType  uint16
+	IPProtocol uint8
+	Ports      []uint16
+	// DecodeAs is the name accepted by DecodeAsTable, if any
+	// DecodeAsTableで指定できる名前です
+	DecodeAs string
+	Parse    bool
+	Generate bool
+
+	// Label is a short name for narrow columns, Color a color hint for dark backgrounds (a color name or hex code like ColoringRule),
+	// and Category one of PROTOCOL_CATEGORY_*. Use Theme.ProtocolColor for the color, so that themes can override it
+	// 狭い列向けの短い名前、暗い背景向けの色のヒント(ColoringRuleと同様に色名または16進コード)、PROTOCOL_CATEGORY_*のいずれかの分類です。
+	// テーマで上書きできるよう、色はTheme.ProtocolColorで取得してください
+	Label    string
+	Color    string
+	Category string
+}
+
+// 下位レイヤから順に並べる。Generator のプルダウンもこの順になる
+var supportedProtocols = []ProtocolInfo{
+	{Name: "Ethernet", Layer: LAYER_L2, Parse: true, Generate: true,
+		Label: "ETH", Color: "gray", Category: PROTOCOL_CATEGORY_LINK},
+	{Name: "ARP", Layer: LAYER_L3, EtherType: ETHER_TYPE_ARP, Parse: true, Generate: true,
+		Label: "ARP", Color: "gold", Category: PROTOCOL_CATEGORY_LINK},
+	{Name: "IPv4", Layer: LAYER_L3, EtherType: ETHER_TYPE_IPv4, Parse: true, Generate: true,
+		Label: "IPv4", Color: "steelblue", Category: PROTOCOL_CATEGORY_NETWORK},
+	{Name: "IPv6", Layer: LAYER_L3, EtherType: ETHER_TYPE_IPv6, Parse: true, Generate: true,
+		Label: "IPv6", Color: "skyblue", Category: PROTOCOL_CATEGORY_NETWORK},
+	{Name: "ICMP", Layer: LAYER_L4, IPProtocol: IPv4_PROTO_ICMP, Parse: true, Generate: true,
+		Label: "ICMP", Color: "orchid", Category: PROTOCOL_CATEGORY_CONTROL},
+	{Name: "ICMPv6", Layer: LAYER_L4, IPProtocol: IPv6_NEXT_HEADER_ICMPv6, Parse: true, Generate: true,
+		Label: "ICMP6", Color: "plum", Category: PROTOCOL_CATEGORY_CONTROL},
+	{Name: "TCP", Layer: LAYER_L4, IPProtocol: IPv4_PROTO_TCP, Parse: true, Generate: true,
+		Label: "TCP", Color: "turquoise", Category: PROTOCOL_CATEGORY_TRANSPORT},
+	{Name: "UDP", Layer: LAYER_L4, IPProtocol: IPv4_PROTO_UDP, Parse: true, Generate: true,
+		Label: "UDP", Color: "lightgreen", Category: PROTOCOL_CATEGORY_TRANSPORT},
+	// OSPF と BGP はパケットの解析器が無く、統計で数えて色付けするためだけに載せる
+	{Name: "OSPF", Layer: LAYER_L4, IPProtocol: IPv4_PROTO_OSPF,
+		Label: "OSPF", Color: "orange", Category: PROTOCOL_CATEGORY_ROUTING},
+	{Name: "IP-in-IP", Layer: LAYER_L4, IPProtocol: IPv4_PROTO_IPIP, Parse: true,
+		Label: "IPIP", Color: "tan", Category: PROTOCOL_CATEGORY_TUNNEL},
+	{Name: "6in4", Layer: LAYER_L4, IPProtocol: IPv4_PROTO_IPv6, Parse: true,
+		Label: "6in4", Color: "tan", Category: PROTOCOL_CATEGORY_TUNNEL},
+	{Name: "TLSv1.2", Layer: LAYER_L5_6, Ports: []uint16{PORT_HTTPS}, DecodeAs: DECODE_AS_TLS, Parse: true, Generate: true,
+		Label: "TLS1.2", Color: "mediumpurple", Category: PROTOCOL_CATEGORY_SECURITY},
+	{Name: "TLSv1.3", Layer: LAYER_L5_6, Ports: []uint16{PORT_HTTPS}, DecodeAs: DECODE_AS_TLS, Parse: true, Generate: true,
+		Label: "TLS1.3", Color: "mediumpurple", Category: PROTOCOL_CATEGORY_SECURITY},
+	{Name: "DNS", Layer: LAYER_L7, Ports: []uint16{PORT_DNS}, DecodeAs: DECODE_AS_DNS, Parse: true, Generate: true,
+		Label: "DNS", Color: "yellow", Category: PROTOCOL_CATEGORY_APPLICATION},
+	{Name: "HTTP", Layer: LAYER_L7, Ports: []uint16{PORT_HTTP}, DecodeAs: DECODE_AS_HTTP, Parse: true, Generate: true,
+		Label: "HTTP", Color: "lime", Category: PROTOCOL_CATEGORY_APPLICATION},
+	{Name: "BGP", Layer: LAYER_L7, Ports: []uint16{179},
+		Label: "BGP", Color: "darkorange", Category: PROTOCOL_CATEGORY_ROUTING},
+	{Name: "GENEVE", Layer: LAYER_L7, Ports: []uint16{PORT_GENEVE}, Parse: true,
+		Label: "GENEVE", Color: "wheat", Category: PROTOCOL_CATEGORY_TUNNEL},
+	{Name: "SMB", Layer: LAYER_L7, Ports: []uint16{PORT_SMB, PORT_NETBIOS_SSN}, Parse: true,
+		Label: "SMB", Color: "salmon", Category: PROTOCOL_CATEGORY_APPLICATION},
+	// RTP は決まったポートがないため Decode As でのみ解析する
+	{Name: "RTP", Layer: LAYER_L7, DecodeAs: DECODE_AS_RTP, Parse: true,
+		Label: "RTP", Color: "pink", Category: PROTOCOL_CATEGORY_MEDIA},
+}
+
+// SupportedProtocols returns all protocols packemon knows, ordered from the lower layers. Parse and Generate tell
+// whether packets of the protocol are decoded and can be built; protocols with neither are only recognized, e.g. counted in statistics
+// packemonが扱うプロトコルの一覧を下位レイヤから順に返します。ParseとGenerateはそのプロトコルのパケットを解析できるか、生成できるかを表します。
+// どちらでもないプロトコルは、統計で数えるなど識別のみ行います
+func SupportedProtocols() []ProtocolInfo {
+	protocols := make([]ProtocolInfo, len(supportedProtocols))
+	copy(protocols, supportedProtocols)
+	return protocols
+}
+
+// LookupProtocol returns the protocol of the name or label, ignoring case
+// 名前またはラベルのプロトコルを、大文字と小文字を区別せずに返します
+func LookupProtocol(name string) (ProtocolInfo, bool) {
+	for _, p := range supportedProtocols {
+		if strings.EqualFold(p.Name, name) || strings.EqualFold(p.Label, name) {
+			return p, true
+		}
+	}
+	return ProtocolInfo{}, false
+}
+
+// GeneratableProtocols returns the names of the protocols in the layer that can be generated
+// 指定レイヤで生成できるプロトコル名を返します
+func GeneratableProtocols(layer string) []string {
+	names := []string{}
+	for _, p := range supportedProtocols {
+		if p.Layer == layer && p.Generate {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
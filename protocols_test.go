@@ -0,0 +1,153 @@
+package packemon
+
+import "testing"
+
+// TestSupportedProtocols tests that the core protocols are listed with their identifiers
+// 主要なプロトコルが識別子とともに一覧に含まれることをテストします
+func TestSupportedProtocols(t *testing.T) {
+	protocols := map[string]ProtocolInfo{}
+	for _, p := range SupportedProtocols() {
+		if _, ok := protocols[p.Name]; ok {
+			t.Errorf("%s is listed twice", p.Name)
+		}
+		protocols[p.Name] = p
+	}
+
+	// 解析器も Generator のページも無いものは識別のみ
+	for _, name := range []string{"OSPF", "BGP"} {
+		if p := protocols[name]; p.Parse || p.Generate {
+			t.Errorf("%s = %+v, want neither parse nor generate", name, p)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		layer      string
+		etherType  uint16
+		ipProtocol uint8
+		port       uint16
+	}{
+		{"Ethernet", LAYER_L2, 0, 0, 0},
+		{"ARP", LAYER_L3, ETHER_TYPE_ARP, 0, 0},
+		{"IPv4", LAYER_L3, ETHER_TYPE_IPv4, 0, 0},
+		{"IPv6", LAYER_L3, ETHER_TYPE_IPv6, 0, 0},
+		{"ICMP", LAYER_L4, 0, IPv4_PROTO_ICMP, 0},
+		{"ICMPv6", LAYER_L4, 0, IPv6_NEXT_HEADER_ICMPv6, 0},
+		{"TCP", LAYER_L4, 0, IPv4_PROTO_TCP, 0},
+		{"UDP", LAYER_L4, 0, IPv4_PROTO_UDP, 0},
+		{"DNS", LAYER_L7, 0, 0, PORT_DNS},
+		{"HTTP", LAYER_L7, 0, 0, PORT_HTTP},
+	}
+	for _, tt := range tests {
+		p, ok := protocols[tt.name]
+		if !ok {
+			t.Errorf("%s should be supported", tt.name)
+			continue
+		}
+		if p.Layer != tt.layer || p.EtherType != tt.etherType || p.IPProtocol != tt.ipProtocol {
+			t.Errorf("%s = %+v, want layer %s, ether type 0x%04x, ip protocol %d", tt.name, p, tt.layer, tt.etherType, tt.ipProtocol)
+		}
+		if tt.port != 0 && (len(p.Ports) == 0 || p.Ports[0] != tt.port) {
+			t.Errorf("%s ports = %v, want %d", tt.name, p.Ports, tt.port)
+		}
+		if !p.Parse || !p.Generate {
+			t.Errorf("%s should support both parse and generate", tt.name)
+		}
+	}
+}
+
+// TestSupportedProtocolsDecodeAs tests that every listed decode as name is accepted by DecodeAsTable
+// 一覧のDecodeAsの名前がすべてDecodeAsTableで受け付けられることをテストします
+func TestSupportedProtocolsDecodeAs(t *testing.T) {
+	table := &DecodeAsTable{}
+	for _, p := range SupportedProtocols() {
+		if p.DecodeAs == "" {
+			continue
+		}
+		if err := table.Set(8000, p.DecodeAs); err != nil {
+			t.Errorf("%s: %v", p.Name, err)
+		}
+	}
+}
+
+// TestGeneratableProtocols tests the generator menu order of each layer
+// 各レイヤのGeneratorのメニューの順番をテストします
+func TestGeneratableProtocols(t *testing.T) {
+	got := GeneratableProtocols(LAYER_L3)
+	want := []string{"ARP", "IPv4", "IPv6"}
+	if len(got) != len(want) {
+		t.Fatalf("GeneratableProtocols(L3) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GeneratableProtocols(L3) = %v, want %v", got, want)
+		}
+	}
+	for _, name := range GeneratableProtocols(LAYER_L7) {
+		if name == "RTP" {
+			t.Errorf("RTP cannot be generated")
+		}
+	}
+}
+
+// TestProtocolMetadata tests that every protocol has its presentation metadata, that labels are unique,
+// and that a theme overrides the color hints
+// すべてのプロトコルが表示用のメタデータを持つこと、ラベルが重複しないこと、およびテーマで色のヒントを上書きできることをテストします
+func TestProtocolMetadata(t *testing.T) {
+	labels := map[string]string{}
+	for _, p := range SupportedProtocols() {
+		if p.Label == "" || p.Color == "" || p.Category == "" {
+			t.Errorf("%s has no label, color or category: %+v", p.Name, p)
+		}
+		if other, ok := labels[p.Label]; ok {
+			t.Errorf("%s and %s share the label %s", p.Name, other, p.Label)
+		}
+		labels[p.Label] = p.Name
+	}
+
+	tests := []struct {
+		name     string
+		label    string
+		category string
+	}{
+		{"Ethernet", "ETH", PROTOCOL_CATEGORY_LINK},
+		{"ipv4", "IPv4", PROTOCOL_CATEGORY_NETWORK},
+		{"ICMP6", "ICMP6", PROTOCOL_CATEGORY_CONTROL}, // ラベルでも引ける
+		{"TCP", "TCP", PROTOCOL_CATEGORY_TRANSPORT},
+		{"UDP", "UDP", PROTOCOL_CATEGORY_TRANSPORT},
+		{"TLSv1.3", "TLS1.3", PROTOCOL_CATEGORY_SECURITY},
+		{"DNS", "DNS", PROTOCOL_CATEGORY_APPLICATION},
+	}
+	for _, tt := range tests {
+		p, ok := LookupProtocol(tt.name)
+		if !ok || p.Label != tt.label || p.Category != tt.category {
+			t.Errorf("LookupProtocol(%q) = %+v, %v, want label %s, category %s", tt.name, p, ok, tt.label, tt.category)
+		}
+	}
+	if _, ok := LookupProtocol("nope"); ok {
+		t.Error("LookupProtocol() found an unknown protocol")
+	}
+
+	theme, err := NewTheme(Theme{Name: "custom", Base: THEME_LIGHT, Protocols: map[string]string{"dns": "#ff0000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := theme.ProtocolColor("DNS"); got != "#ff0000" {
+		t.Errorf("ProtocolColor(DNS) = %q, want the theme's override", got)
+	}
+	if got := theme.ProtocolColor("UDP"); got != "green" {
+		t.Errorf("ProtocolColor(UDP) = %q, want the light theme's color", got)
+	}
+	if got := theme.ProtocolColor("ICMP"); got != "orchid" {
+		t.Errorf("ProtocolColor(ICMP) = %q, want the protocol's hint", got)
+	}
+	if got := (*Theme)(nil).ProtocolColor("TCP"); got != "turquoise" {
+		t.Errorf("nil theme: ProtocolColor(TCP) = %q, want turquoise", got)
+	}
+	if got := theme.ProtocolColor("nope"); got != "black" {
+		t.Errorf("ProtocolColor(nope) = %q, want the text color", got)
+	}
+	if _, err := NewTheme(Theme{Name: "typo", Protocols: map[string]string{"dsn": "red"}}); err == nil {
+		t.Error("unknown protocol: NewTheme() succeeded")
+	}
+}
@@ -0,0 +1,130 @@
+package packemon
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QuietEvent reports that an interface received no frames for the idle duration of a QuietWatchdog, or that frames arrive again
+// インターフェースがQuietWatchdogの待機時間の間フレームを受信しなかったこと、または再び受信したことを表します
+type QuietEvent struct {
+	Interface string
+	// Quiet is true when the traffic stopped and false when it resumed
+	// 通信が止まった場合はtrue、再開した場合はfalse
+	Quiet bool
+	// LastFrame is when the last frame before the quiet period was received, or when watching started if none was
+	// 静かになる前に最後にフレームを受信した時刻。受信していない場合は監視を始めた時刻
+	LastFrame time.Time
+	// Time is when the quiet period was noticed, or when the frame ending it was received
+	// 静かになったことに気付いた時刻、またはそれを終わらせたフレームを受信した時刻
+	Time time.Time
+}
+
+// QuietFor returns how long the interface had been quiet at the event
+// イベントの時点でインターフェースが静かだった時間を返します
+func (e QuietEvent) QuietFor() time.Duration {
+	return e.Time.Sub(e.LastFrame)
+}
+
+// QuietWatchdog calls notify when an interface receives no frames for the idle duration, e.g. because the link went down
+// or the capture died silently, and again when frames arrive. The receive loop reports each frame it reads,
+// and checks the idle duration whenever its read timeout expires, so it is noticed within the read timeout
+// インターフェースが待機時間の間フレームを受信しない場合(リンクダウンやキャプチャの停止など)と、再び受信した場合にnotifyを呼びます。
+// 受信ループが読み込んだフレームを報告し、読み込みタイムアウトのたびに待機時間を確認するため、読み込みタイムアウト以内に気付きます
+type QuietWatchdog struct {
+	idle   time.Duration
+	notify func(QuietEvent)
+
+	// 受信ループからフレームごとに呼ばれるため、最後の受信時刻と状態は atomic で持つ
+	lastFrame atomic.Int64 // UnixNano. 0 は監視前
+	quiet     atomic.Bool
+
+	mu        sync.Mutex // 状態の遷移と通知
+	intf      string
+	quietFrom time.Time
+}
+
+// NewQuietWatchdog creates a watchdog calling notify after idle without frames. Attach it with NetworkInterface.SetQuietWatchdog
+// フレームの無いままidleが経過するとnotifyを呼ぶウォッチドッグを作成します。NetworkInterface.SetQuietWatchdogで設定します
+func NewQuietWatchdog(idle time.Duration, notify func(QuietEvent)) *QuietWatchdog {
+	return &QuietWatchdog{
+		idle:   idle,
+		notify: notify,
+	}
+}
+
+// Frame records a frame received at t. If the interface was quiet, notify is called with the event of the traffic resuming
+// tに受信したフレームを記録します。インターフェースが静かだった場合は、通信が再開したイベントでnotifyを呼びます
+func (w *QuietWatchdog) Frame(t time.Time) {
+	w.lastFrame.Store(t.UnixNano())
+	if !w.quiet.Load() {
+		return
+	}
+
+	w.mu.Lock()
+	if !w.quiet.Load() {
+		w.mu.Unlock()
+		return
+	}
+	w.quiet.Store(false)
+	event := QuietEvent{Interface: w.intf, Quiet: false, LastFrame: w.quietFrom, Time: t}
+	w.mu.Unlock()
+	w.notify(event)
+}
+
+// Check calls notify if no frame was received for the idle duration until now. The first call starts watching
+// 現在までの待機時間の間フレームを受信していない場合にnotifyを呼びます。最初の呼び出しで監視を始めます
+func (w *QuietWatchdog) Check(now time.Time) {
+	if w.lastFrame.CompareAndSwap(0, now.UnixNano()) || w.quiet.Load() {
+		return
+	}
+	last := time.Unix(0, w.lastFrame.Load())
+	if now.Sub(last) < w.idle {
+		return
+	}
+
+	w.mu.Lock()
+	if w.quiet.Load() {
+		w.mu.Unlock()
+		return
+	}
+	w.quiet.Store(true)
+	w.quietFrom = last
+	event := QuietEvent{Interface: w.intf, Quiet: true, LastFrame: last, Time: now}
+	w.mu.Unlock()
+	w.notify(event)
+}
+
+// Quiet reports whether the interface is quiet now
+// インターフェースが現在静かかどうかを返します
+func (w *QuietWatchdog) Quiet() bool {
+	return w.quiet.Load()
+}
+
+// SetQuietWatchdog makes the receive loop report frames to w and check it whenever the read timeout expires. nil removes it.
+// Frames are reported before any filter, so traffic dropped by CaptureFilter still counts
+// 受信ループがwにフレームを報告し、読み込みタイムアウトのたびに確認するよう設定します。nilで解除します。
+// フレームはフィルタの前に報告するため、CaptureFilterで捨てる通信も数えます
+func (nwif *NetworkInterface) SetQuietWatchdog(w *QuietWatchdog) {
+	if w != nil && nwif.Intf != nil {
+		w.mu.Lock()
+		w.intf = nwif.Intf.Name
+		w.mu.Unlock()
+	}
+	nwif.quietWatchdog.Store(w)
+}
+
+// 受信ループがフレームを読み込んだ時に呼ぶ
+func (nwif *NetworkInterface) watchFrame(t time.Time) {
+	if w := nwif.quietWatchdog.Load(); w != nil {
+		w.Frame(t)
+	}
+}
+
+// 受信ループの読み込みタイムアウト時に呼ぶ
+func (nwif *NetworkInterface) watchQuiet(now time.Time) {
+	if w := nwif.quietWatchdog.Load(); w != nil {
+		w.Check(now)
+	}
+}
@@ -0,0 +1,58 @@
+package packemon
+
+import (
+	"testing"
+	"time"
+)
+
+// LastFrame は受信ループの時刻から作り直すため、Location を除いて比較する
+func sameQuietEvent(got, want QuietEvent) bool {
+	return got.Interface == want.Interface && got.Quiet == want.Quiet && got.LastFrame.Equal(want.LastFrame) && got.Time.Equal(want.Time)
+}
+
+// TestQuietWatchdog tests that the watchdog fires once after an idle period without frames, and again when frames resume
+// フレームの無い待機時間の後に一度だけ通知し、フレームが再開した時に再び通知することをテストします
+func TestQuietWatchdog(t *testing.T) {
+	events := []QuietEvent{}
+	w := NewQuietWatchdog(time.Second, func(e QuietEvent) {
+		events = append(events, e)
+	})
+	nwif := NewOfflineNetworkInterface("packemon-test0")
+	nwif.SetQuietWatchdog(w)
+
+	// 受信ループの代わりに、フレームの受信と読み込みタイムアウトを再現する
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	nwif.watchQuiet(base)
+	nwif.watchFrame(base.Add(100 * time.Millisecond))
+	nwif.watchQuiet(base.Add(600 * time.Millisecond))
+	if len(events) != 0 || w.Quiet() {
+		t.Fatalf("notified before the idle period: %+v", events)
+	}
+
+	nwif.watchQuiet(base.Add(1200 * time.Millisecond))
+	nwif.watchQuiet(base.Add(1300 * time.Millisecond))
+	want := QuietEvent{Interface: "packemon-test0", Quiet: true, LastFrame: base.Add(100 * time.Millisecond), Time: base.Add(1200 * time.Millisecond)}
+	if len(events) != 1 || !sameQuietEvent(events[0], want) || !w.Quiet() {
+		t.Fatalf("events = %+v, want only %+v", events, want)
+	}
+
+	nwif.watchFrame(base.Add(3 * time.Second))
+	nwif.watchFrame(base.Add(3100 * time.Millisecond))
+	want = QuietEvent{Interface: "packemon-test0", Quiet: false, LastFrame: base.Add(100 * time.Millisecond), Time: base.Add(3 * time.Second)}
+	if len(events) != 2 || !sameQuietEvent(events[1], want) || w.Quiet() {
+		t.Fatalf("events = %+v, want %+v last", events, want)
+	}
+	if got := events[1].QuietFor(); got != 2900*time.Millisecond {
+		t.Errorf("QuietFor() = %v, want 2.9s", got)
+	}
+
+	// 監視を始めてからフレームが一度も無い場合も通知する
+	silent := NewQuietWatchdog(time.Second, func(e QuietEvent) {
+		events = append(events, e)
+	})
+	silent.Check(base)
+	silent.Check(base.Add(time.Second))
+	if len(events) != 3 || !events[2].Quiet || !events[2].LastFrame.Equal(base) {
+		t.Errorf("events = %+v, want a quiet event since %v", events, base)
+	}
+}
@@ -0,0 +1,192 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+)
+
+// GenerateRandomPacket returns a structurally valid Ethernet frame built from seed.
+// The same seed always yields the same frame, so it can be used for reproducible property tests, fuzz corpora and demos.
+// seedから構造的に正しいEthernetフレームを生成します。同じseedからは常に同じフレームが生成されます
+func GenerateRandomPacket(seed int64) []byte {
+	r := rand.New(rand.NewSource(seed))
+
+	dstMAC, srcMAC := randomMAC(r), randomMAC(r)
+	var etherType uint16
+	var payload []byte
+	switch r.Intn(7) {
+	case 0:
+		etherType, payload = ETHER_TYPE_ARP, randomARP(r, srcMAC)
+	case 1:
+		etherType, payload = ETHER_TYPE_IPv4, randomIPv4(r, IPv4_PROTO_ICMP, randomICMPEcho(r))
+	case 2:
+		etherType, payload = ETHER_TYPE_IPv4, randomIPv4(r, IPv4_PROTO_TCP, randomTCP(r))
+	case 3:
+		etherType, payload = ETHER_TYPE_IPv4, randomIPv4(r, IPv4_PROTO_UDP, randomUDP(r))
+	case 4:
+		etherType, payload = ETHER_TYPE_IPv6, randomIPv6(r, IPv6_NEXT_HEADER_ICMPv6, randomICMPv6Echo(r))
+	case 5:
+		etherType, payload = ETHER_TYPE_IPv6, randomIPv6(r, IPv6_NEXT_HEADER_TCP, randomTCP(r))
+	default:
+		etherType, payload = ETHER_TYPE_IPv6, randomIPv6(r, IPv6_NEXT_HEADER_UDP, randomUDP(r))
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(dstMAC)
+	buf.Write(srcMAC)
+	WriteUint16(buf, etherType)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// ローカル管理のユニキャストアドレス
+func randomMAC(r *rand.Rand) []byte {
+	mac := make([]byte, 6)
+	r.Read(mac)
+	mac[0] = (mac[0] | 0x02) &^ 0x01
+	return mac
+}
+
+// 192.168.0.0/16 の範囲
+func randomIPv4Addr(r *rand.Rand) []byte {
+	return []byte{192, 168, byte(r.Intn(256)), byte(1 + r.Intn(254))}
+}
+
+// fd00::/8 (ULA) の範囲
+func randomIPv6Addr(r *rand.Rand) []byte {
+	addr := make([]byte, 16)
+	r.Read(addr)
+	addr[0] = 0xfd
+	return addr
+}
+
+func randomARP(r *rand.Rand, senderMAC []byte) []byte {
+	buf := &bytes.Buffer{}
+	WriteUint16(buf, 0x0001) // Ethernet
+	WriteUint16(buf, ETHER_TYPE_IPv4)
+	buf.WriteByte(6)
+	buf.WriteByte(4)
+	WriteUint16(buf, uint16(1+r.Intn(2))) // request or reply
+	buf.Write(senderMAC)
+	buf.Write(randomIPv4Addr(r))
+	buf.Write(make([]byte, 6))
+	buf.Write(randomIPv4Addr(r))
+	return buf.Bytes()
+}
+
+func randomIPv4(r *rand.Rand, protocol uint8, payload []byte) []byte {
+	header := make([]byte, 20)
+	header[0] = 0x45
+	header[1] = byte(r.Intn(64)) << 2 // DSCP
+	binary.BigEndian.PutUint16(header[2:4], uint16(20+len(payload)))
+	binary.BigEndian.PutUint16(header[4:6], uint16(r.Intn(0x10000)))
+	header[6] = 0x40 // don't fragment
+	header[8] = byte(1 + r.Intn(255))
+	header[9] = protocol
+	copy(header[12:16], randomIPv4Addr(r))
+	copy(header[16:20], randomIPv4Addr(r))
+	binary.BigEndian.PutUint16(header[10:12], calculateInternetChecksum(header))
+	return append(header, payload...)
+}
+
+func randomIPv6(r *rand.Rand, nextHeader uint8, payload []byte) []byte {
+	header := make([]byte, 40)
+	binary.BigEndian.PutUint32(header[0:4], 6<<28|uint32(r.Intn(0x100000)))
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(payload)))
+	header[6] = nextHeader
+	header[7] = byte(1 + r.Intn(255))
+	copy(header[8:24], randomIPv6Addr(r))
+	copy(header[24:40], randomIPv6Addr(r))
+	return append(header, payload...)
+}
+
+func randomICMPEcho(r *rand.Rand) []byte {
+	icmp := make([]byte, 8+r.Intn(57))
+	icmp[0] = ICMP_TYPE_REQUEST
+	binary.BigEndian.PutUint16(icmp[4:6], uint16(r.Intn(0x10000)))
+	binary.BigEndian.PutUint16(icmp[6:8], uint16(r.Intn(0x10000)))
+	r.Read(icmp[8:])
+	binary.BigEndian.PutUint16(icmp[2:4], calculateInternetChecksum(icmp))
+	return icmp
+}
+
+// チェックサムは擬似ヘッダーが必要なため0のまま
+func randomICMPv6Echo(r *rand.Rand) []byte {
+	icmpv6 := make([]byte, 8+r.Intn(57))
+	icmpv6[0] = ICMPv6_TYPE_ECHO_REQUEST
+	binary.BigEndian.PutUint16(icmpv6[4:6], uint16(r.Intn(0x10000)))
+	binary.BigEndian.PutUint16(icmpv6[6:8], uint16(r.Intn(0x10000)))
+	r.Read(icmpv6[8:])
+	return icmpv6
+}
+
+var randomWellKnownPorts = []uint16{53, 80, 443, 8080}
+
+func randomPort(r *rand.Rand) uint16 {
+	return uint16(49152 + r.Intn(16384))
+}
+
+func randomTCP(r *rand.Rand) []byte {
+	// オプション無し or MSS オプション付き
+	optionLength := r.Intn(2) * 4
+	tcp := make([]byte, 20+optionLength)
+	binary.BigEndian.PutUint16(tcp[0:2], randomPort(r))
+	binary.BigEndian.PutUint16(tcp[2:4], randomWellKnownPorts[r.Intn(len(randomWellKnownPorts))])
+	binary.BigEndian.PutUint32(tcp[4:8], r.Uint32())
+	binary.BigEndian.PutUint32(tcp[8:12], r.Uint32())
+	tcp[12] = byte((20+optionLength)/4) << 4
+	tcp[13] = []byte{TCP_FLAGS_SYN, TCP_FLAGS_SYN_ACK, TCP_FLAGS_ACK, TCP_FLAGS_PSH_ACK, TCP_FLAGS_FIN_ACK}[r.Intn(5)]
+	binary.BigEndian.PutUint16(tcp[14:16], uint16(r.Intn(0x10000)))
+	if optionLength > 0 {
+		copy(tcp[20:24], []byte{0x02, 0x04, 0x05, 0xb4}) // MSS 1460
+	}
+	if tcp[13] == TCP_FLAGS_PSH_ACK {
+		data := make([]byte, 1+r.Intn(64))
+		r.Read(data)
+		tcp = append(tcp, data...)
+	}
+	return tcp
+}
+
+func randomUDP(r *rand.Rand) []byte {
+	var payload []byte
+	dstPort := randomPort(r)
+	if r.Intn(2) == 0 {
+		dstPort = PORT_DNS
+		payload = randomDNSQuery(r)
+	} else {
+		payload = make([]byte, r.Intn(64))
+		r.Read(payload)
+	}
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], randomPort(r))
+	binary.BigEndian.PutUint16(udp[2:4], dstPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	return append(udp, payload...)
+}
+
+var randomDomains = []string{"github.com", "go.dev", "example.com", "packemon.test"}
+
+func randomDNSQuery(r *rand.Rand) []byte {
+	buf := &bytes.Buffer{}
+	WriteUint16(buf, uint16(r.Intn(0x10000))) // transaction ID
+	WriteUint16(buf, 0x0100)                  // standard query, recursion desired
+	WriteUint16(buf, 1)
+	WriteUint16(buf, 0)
+	WriteUint16(buf, 0)
+	WriteUint16(buf, 0)
+	for _, label := range bytes.Split([]byte(randomDomains[r.Intn(len(randomDomains))]), []byte(".")) {
+		buf.WriteByte(byte(len(label)))
+		buf.Write(label)
+	}
+	buf.WriteByte(0x00)
+	if r.Intn(2) == 0 {
+		WriteUint16(buf, DNS_QUERY_TYPE_A)
+	} else {
+		WriteUint16(buf, DNS_QUERY_TYPE_AAAA)
+	}
+	WriteUint16(buf, 0x0001) // IN
+	return buf.Bytes()
+}
@@ -0,0 +1,93 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestGenerateRandomPacketDeterministic tests that the same seed yields the same frame
+// 同じseedから同じフレームが生成されることをテストします
+func TestGenerateRandomPacketDeterministic(t *testing.T) {
+	for seed := int64(0); seed < 32; seed++ {
+		if !bytes.Equal(GenerateRandomPacket(seed), GenerateRandomPacket(seed)) {
+			t.Errorf("GenerateRandomPacket(%d) is not deterministic", seed)
+		}
+	}
+	if bytes.Equal(GenerateRandomPacket(1), GenerateRandomPacket(2)) {
+		t.Errorf("GenerateRandomPacket(1) and GenerateRandomPacket(2) should differ")
+	}
+}
+
+// TestGenerateRandomPacketParses tests that generated frames decode without panic and round-trip through a hex dump
+// 生成したフレームがpanicせずにデコードでき、16進ダンプを経由して元に戻ることをテストします
+func TestGenerateRandomPacketParses(t *testing.T) {
+	for seed := int64(0); seed < 500; seed++ {
+		frame := GenerateRandomPacket(seed)
+		passive, err := DecodeFrame(frame)
+		if err != nil {
+			t.Fatalf("seed %d: DecodeFrame returned error: %v", seed, err)
+		}
+
+		switch passive.EthernetFrame.Type {
+		case ETHER_TYPE_ARP:
+			if passive.ARP == nil {
+				t.Errorf("seed %d: ARP should be decoded", seed)
+			}
+		case ETHER_TYPE_IPv4:
+			if passive.IPv4 == nil {
+				t.Fatalf("seed %d: IPv4 should be decoded", seed)
+			}
+			if int(passive.IPv4.TotalLength) != len(passive.EthernetFrame.Payload) {
+				t.Errorf("seed %d: IPv4 total length = %d, want %d", seed, passive.IPv4.TotalLength, len(passive.EthernetFrame.Payload))
+			}
+			if calculateInternetChecksum(passive.EthernetFrame.Payload[:20]) != 0 {
+				t.Errorf("seed %d: IPv4 header checksum is invalid", seed)
+			}
+			if passive.ICMP == nil && passive.TCP == nil && passive.UDP == nil {
+				t.Errorf("seed %d: upper layer of IPv4 should be decoded", seed)
+			}
+			if passive.ICMP != nil && calculateInternetChecksum(passive.IPv4.Payload) != 0 {
+				t.Errorf("seed %d: ICMP checksum is invalid", seed)
+			}
+		case ETHER_TYPE_IPv6:
+			if passive.IPv6 == nil {
+				t.Fatalf("seed %d: IPv6 should be decoded", seed)
+			}
+			if int(passive.IPv6.PayloadLen) != len(passive.IPv6.Payload) {
+				t.Errorf("seed %d: IPv6 payload length = %d, want %d", seed, passive.IPv6.PayloadLen, len(passive.IPv6.Payload))
+			}
+			if passive.ICMPv6 == nil && passive.TCP == nil && passive.UDP == nil {
+				t.Errorf("seed %d: upper layer of IPv6 should be decoded", seed)
+			}
+		default:
+			t.Errorf("seed %d: unexpected ether type 0x%04x", seed, passive.EthernetFrame.Type)
+		}
+		if passive.UDP != nil && passive.UDP.DstPort == PORT_DNS && passive.DNS == nil {
+			t.Errorf("seed %d: DNS should be decoded", seed)
+		}
+
+		dumped, err := ParseHexDump(hex.Dump(frame))
+		if err != nil {
+			t.Fatalf("seed %d: ParseHexDump returned error: %v", seed, err)
+		}
+		if !bytes.Equal(dumped, frame) {
+			t.Errorf("seed %d: hex dump round trip = %x, want %x", seed, dumped, frame)
+		}
+	}
+}
+
+// FuzzDecodeFrame checks that the parsers never panic, seeded with generated frames
+// 生成したフレームをシードとして、パーサーがpanicしないことを確認します
+func FuzzDecodeFrame(f *testing.F) {
+	for seed := int64(0); seed < 64; seed++ {
+		f.Add(GenerateRandomPacket(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		DecodeFrame(data)
+		ParsedDNSResourceRecords(data)
+		ParsedRTP(data)
+		ParseHexDump(string(data))
+	})
+}
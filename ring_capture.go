@@ -0,0 +1,256 @@
+package packemon
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// RING_CAPTURE_DEFAULT_MAX_FRAMES is the number of frames kept by default
+	// デフォルトで保持するフレーム数です
+	RING_CAPTURE_DEFAULT_MAX_FRAMES = 10000
+	// RING_CAPTURE_DEFAULT_MAX_BYTES is the total size of the frames kept by default
+	// デフォルトで保持するフレームの合計サイズです
+	RING_CAPTURE_DEFAULT_MAX_BYTES = 64 << 20
+)
+
+// RingCapture keeps the most recent captured packets in memory, bounded by a number of frames and their total size,
+// so that after something happened they can be queried with a display filter and exported without capturing again.
+// 直近にキャプチャしたパケットを、フレーム数と合計サイズの上限内でメモリに保持します。
+// 何か起きた後に、キャプチャし直さずにディスプレイフィルタで絞り込んで書き出せます。
+// Packets are numbered from 1 in the order added, and can be annotated by number
+// パケットには追加した順に1から番号が付き、番号で注釈を付けられます
+type RingCapture struct {
+	maxFrames int
+	maxBytes  int
+
+	mu sync.Mutex
+	// 古い順. 先頭から捨てる
+	packets []*Passive
+	bytes   int
+	// packets の先頭のパケットの番号
+	first uint64
+
+	annotations *Annotations
+}
+
+// NewRingCapture creates a buffer keeping at most maxFrames frames of at most maxBytes in total. Zero or less uses the defaults.
+// 最大maxFrames個、合計最大maxBytesのフレームを保持するバッファを作成します。0以下の場合はデフォルト値を使います
+func NewRingCapture(maxFrames int, maxBytes int) *RingCapture {
+	if maxFrames <= 0 {
+		maxFrames = RING_CAPTURE_DEFAULT_MAX_FRAMES
+	}
+	if maxBytes <= 0 {
+		maxBytes = RING_CAPTURE_DEFAULT_MAX_BYTES
+	}
+	return &RingCapture{
+		maxFrames:   maxFrames,
+		maxBytes:    maxBytes,
+		first:       1,
+		annotations: NewAnnotations(),
+	}
+}
+
+// Add stores a captured packet, dropping the oldest ones beyond the limits. Packets without a raw frame, e.g. synthetic ones, are ignored.
+// The packet is kept as decoded, so queries don't decode the frames again.
+// キャプチャしたパケットを保持し、上限を超えた分は古いものから捨てます。生成したパケットなど生のフレームが無いものは無視します。
+// 解析済みのまま保持するため、検索時にフレームを解析し直しません
+func (r *RingCapture) Add(passive *Passive) {
+	if passive == nil || len(passive.Raw) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.packets = append(r.packets, passive)
+	r.bytes += len(passive.Raw)
+	// 最新のパケットは 1 つで maxBytes を超えていても残す
+	drop := 0
+	for len(r.packets)-drop > r.maxFrames || (r.bytes > r.maxBytes && len(r.packets)-drop > 1) {
+		r.bytes -= len(r.packets[drop].Raw)
+		r.packets[drop] = nil
+		drop++
+	}
+	r.packets = r.packets[drop:]
+	r.first += uint64(drop)
+}
+
+// Len returns the number of packets kept
+// 保持しているパケット数を返します
+func (r *RingCapture) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.packets)
+}
+
+// Bytes returns the total size of the frames kept
+// 保持しているフレームの合計サイズを返します
+func (r *RingCapture) Bytes() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bytes
+}
+
+// Packets returns the packets kept, oldest first
+// 保持しているパケットを古い順に返します
+func (r *RingCapture) Packets() []*Passive {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Passive{}, r.packets...)
+}
+
+// Query returns the packets kept that match the display filter expr (see CompileDisplayFilter), oldest first. An empty expr returns all packets
+// ディスプレイフィルタexpr(CompileDisplayFilter参照)に一致する保持中のパケットを古い順に返します。exprが空の場合は全てのパケットを返します
+func (r *RingCapture) Query(expr string) ([]*Passive, error) {
+	matched, _, err := r.query(expr)
+	return matched, err
+}
+
+// query はパケットと合わせてその番号も返す
+func (r *RingCapture) query(expr string) ([]*Passive, []uint64, error) {
+	r.mu.Lock()
+	packets := append([]*Passive{}, r.packets...)
+	first := r.first
+	r.mu.Unlock()
+
+	var filter *DisplayFilter
+	if expr != "" {
+		var err error
+		if filter, err = CompileDisplayFilter(expr); err != nil {
+			return nil, nil, err
+		}
+	}
+	matched := []*Passive{}
+	numbers := []uint64{}
+	for i, passive := range packets {
+		if filter == nil || filter.Match(passive) {
+			matched = append(matched, passive)
+			numbers = append(numbers, first+uint64(i))
+		}
+	}
+	return matched, numbers, nil
+}
+
+// WritePcap writes the packets matching expr to w in the pcap format and returns how many were written
+// exprに一致するパケットをpcap形式でwに書き込み、書き込んだ数を返します
+func (r *RingCapture) WritePcap(w io.Writer, expr string) (int, error) {
+	packets, err := r.Query(expr)
+	if err != nil {
+		return 0, err
+	}
+	return writeRingCapturePcap(w, packets)
+}
+
+// WritePcapWithAnnotations writes the packets matching expr to w like WritePcap, and their notes to sidecar (see Annotations.Save).
+// The notes are renumbered to the positions of the packets in the written pcap
+// exprに一致するパケットをWritePcapと同様にwに書き込み、その注釈をsidecarに書き込みます(Annotations.Save参照)。
+// 注釈の番号は書き込んだpcap内のパケットの位置に振り直します
+func (r *RingCapture) WritePcapWithAnnotations(w io.Writer, sidecar io.Writer, expr string) (int, error) {
+	packets, numbers, err := r.query(expr)
+	if err != nil {
+		return 0, err
+	}
+	n, err := writeRingCapturePcap(w, packets)
+	if err != nil {
+		return n, err
+	}
+	exported := NewAnnotations()
+	for i, number := range numbers {
+		if note, ok := r.Note(number); ok {
+			exported.Annotate(uint64(i+1), note)
+		}
+	}
+	return n, exported.Save(sidecar)
+}
+
+func writeRingCapturePcap(w io.Writer, packets []*Passive) (int, error) {
+	pw, err := NewPcapWriter(w, 0)
+	if err != nil {
+		return 0, err
+	}
+	for i, passive := range packets {
+		// スナップ長で切り詰められたフレームは回線上の長さを残す
+		if err := pw.WriteTruncatedPacket(passive.Timestamp, passive.Raw, passive.WireLength); err != nil {
+			return i, err
+		}
+	}
+	return len(packets), nil
+}
+
+// Reset drops all packets kept
+// 保持している全てのパケットを捨てます
+func (r *RingCapture) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.first += uint64(len(r.packets))
+	r.packets = nil
+	r.bytes = 0
+	r.annotations = NewAnnotations()
+}
+
+// Packet returns the packet of the number if it is still kept
+// 番号のパケットがまだ保持されていればそれを返します
+func (r *RingCapture) Packet(number uint64) (*Passive, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.kept(number) {
+		return nil, false
+	}
+	return r.packets[number-r.first], true
+}
+
+// FirstNumber returns the number of the oldest packet kept. Packets()[i] is the packet of FirstNumber()+i unless packets are added in between
+// 保持している最も古いパケットの番号を返します。間にパケットが追加されなければPackets()[i]はFirstNumber()+iのパケットです
+func (r *RingCapture) FirstNumber() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.first
+}
+
+// Annotate sets the note of a packet still kept, e.g. "this is where it broke". An empty note removes it
+// まだ保持しているパケットに注釈を設定します(例: 「ここで壊れた」)。空の注釈は削除します
+func (r *RingCapture) Annotate(number uint64, note string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.kept(number) {
+		return fmt.Errorf("packet %d is not kept", number)
+	}
+	// 捨てたパケットの注釈は Add では消さず、ここでまとめて消す
+	for _, annotation := range r.annotations.List() {
+		if annotation.Packet < r.first {
+			r.annotations.Annotate(annotation.Packet, "")
+		}
+	}
+	r.annotations.Annotate(number, note)
+	return nil
+}
+
+// Note returns the note of a packet still kept
+// まだ保持しているパケットの注釈を返します
+func (r *RingCapture) Note(number uint64) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.kept(number) {
+		return "", false
+	}
+	return r.annotations.Note(number)
+}
+
+// Annotations returns the notes on the packets still kept, in packet number order
+// まだ保持しているパケットの注釈をパケット番号順に返します
+func (r *RingCapture) Annotations() []Annotation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	annotations := []Annotation{}
+	for _, annotation := range r.annotations.List() {
+		if r.kept(annotation.Packet) {
+			annotations = append(annotations, annotation)
+		}
+	}
+	return annotations
+}
+
+func (r *RingCapture) kept(number uint64) bool {
+	return number >= r.first && number < r.first+uint64(len(r.packets))
+}
@@ -0,0 +1,134 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func ringCaptureTestPassive(t *testing.T, frame []byte, timestamp time.Time) *Passive {
+	t.Helper()
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passive.Timestamp = timestamp
+	return passive
+}
+
+// TestRingCaptureQuery tests storing frames of several protocols and querying them by protocol, and exporting a query as pcap
+// 複数のプロトコルのフレームを保持してプロトコルで検索し、検索結果をpcapで書き出すことをテストします
+func TestRingCaptureQuery(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ring := NewRingCapture(0, 0)
+	frames := [][]byte{
+		parseDepthTestFrame(), // HTTP over TCP
+		decodeStatsTestUDPFrame(40000, 9999, []byte("udp")),
+		ipTunnelTestFrame(IPv4_PROTO_ICMP, []byte{ICMP_TYPE_REQUEST, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x01}),
+		parseDepthTestFrame(),
+	}
+	for i, frame := range frames {
+		ring.Add(ringCaptureTestPassive(t, frame, base.Add(time.Duration(i)*time.Second)))
+	}
+	// 生のフレームが無いパケットは保持しない
+	ring.Add(&Passive{TCP: &TCPPacket{}})
+	if ring.Len() != len(frames) {
+		t.Fatalf("Len() = %d, want %d", ring.Len(), len(frames))
+	}
+
+	tests := []struct {
+		expr string
+		want []int // frames の添字
+	}{
+		{"tcp", []int{0, 3}},
+		{"http", []int{0, 3}},
+		{"udp", []int{1}},
+		{"icmp || udp", []int{1, 2}},
+		{"!tcp", []int{1, 2}},
+		{"", []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		got, err := ring.Query(tt.expr)
+		if err != nil {
+			t.Fatalf("Query(%q): %v", tt.expr, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Query(%q) returned %d packets, want %d", tt.expr, len(got), len(tt.want))
+			continue
+		}
+		for i, passive := range got {
+			if want := base.Add(time.Duration(tt.want[i]) * time.Second); !passive.Timestamp.Equal(want) {
+				t.Errorf("Query(%q)[%d] is the frame at %s, want %s", tt.expr, i, passive.Timestamp, want)
+			}
+		}
+	}
+	if _, err := ring.Query("tcp =="); err == nil {
+		t.Error("an invalid filter should be an error")
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := ring.WritePcap(buf, "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := PCAP_FILE_HEADER_LENGTH + 2*(PCAP_RECORD_HEADER_LENGTH+len(frames[0])); n != 2 || buf.Len() != want {
+		t.Errorf("WritePcap wrote %d packets in %d bytes, want 2 in %d", n, buf.Len(), want)
+	}
+}
+
+// TestRingCaptureWritePcapTruncated tests that a frame cut by the snap length is exported with its length on the wire
+// スナップ長で切り詰められたフレームが回線上の長さとともに書き出されることをテストします
+func TestRingCaptureWritePcapTruncated(t *testing.T) {
+	frame := parseDepthTestFrame()
+	passive := ringCaptureTestPassive(t, frame[:40], time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	passive.WireLength = len(frame)
+	ring := NewRingCapture(0, 0)
+	ring.Add(passive)
+
+	buf := &bytes.Buffer{}
+	if _, err := ring.WritePcap(buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	record := buf.Bytes()[PCAP_FILE_HEADER_LENGTH:]
+	capLen, origLen := binary.LittleEndian.Uint32(record[8:12]), binary.LittleEndian.Uint32(record[12:16])
+	if capLen != 40 || origLen != uint32(len(frame)) {
+		t.Errorf("record lengths = captured %d, original %d, want 40, %d", capLen, origLen, len(frame))
+	}
+}
+
+// TestRingCaptureLimits tests that the oldest frames are dropped beyond the frame count and total size
+// フレーム数と合計サイズの上限を超えると古いフレームから捨てられることをテストします
+func TestRingCaptureLimits(t *testing.T) {
+	frame := parseDepthTestFrame()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ring := NewRingCapture(3, 0)
+	for i := 0; i < 5; i++ {
+		ring.Add(ringCaptureTestPassive(t, frame, base.Add(time.Duration(i)*time.Second)))
+	}
+	packets := ring.Packets()
+	if len(packets) != 3 || !packets[0].Timestamp.Equal(base.Add(2*time.Second)) || ring.Bytes() != 3*len(frame) {
+		t.Errorf("kept %d packets from %s in %d bytes, want the last 3", len(packets), packets[0].Timestamp, ring.Bytes())
+	}
+
+	ring = NewRingCapture(0, 2*len(frame)+1)
+	for i := 0; i < 5; i++ {
+		ring.Add(ringCaptureTestPassive(t, frame, base.Add(time.Duration(i)*time.Second)))
+	}
+	if ring.Len() != 2 || ring.Bytes() != 2*len(frame) {
+		t.Errorf("kept %d packets in %d bytes, want 2 within %d bytes", ring.Len(), ring.Bytes(), 2*len(frame)+1)
+	}
+
+	// 上限より大きくても最新の 1 つは残す
+	ring = NewRingCapture(0, 10)
+	ring.Add(ringCaptureTestPassive(t, frame, base))
+	if ring.Len() != 1 {
+		t.Errorf("Len() = %d, want the latest frame kept even above the byte limit", ring.Len())
+	}
+
+	ring.Reset()
+	if ring.Len() != 0 || ring.Bytes() != 0 {
+		t.Errorf("after Reset: %d packets in %d bytes", ring.Len(), ring.Bytes())
+	}
+}
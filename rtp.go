@@ -0,0 +1,211 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RTP represents an RTP header
+// ref: https://datatracker.ietf.org/doc/html/rfc3550#section-5.1
+// RTPはRTPヘッダーを表します
+type RTP struct {
+	Version        uint8
+	Padding        bool
+	Extension      bool
+	CSRCCount      uint8
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+	Payload        []byte
+}
+
+// ParsedRTP parses an RTP packet carried in a UDP payload
+// UDPペイロードに含まれるRTPパケットを解析します
+func ParsedRTP(payload []byte) (*RTP, error) {
+	r := NewFieldReader(payload, binary.BigEndian)
+	flags, err := r.ReadBytes(2)
+	if err != nil {
+		return nil, fmt.Errorf("rtp packet too short: %w", err)
+	}
+	rtp := &RTP{
+		Version:     flags[0] >> 6,
+		Padding:     flags[0]&0x20 != 0,
+		Extension:   flags[0]&0x10 != 0,
+		CSRCCount:   flags[0] & 0x0f,
+		Marker:      flags[1]&0x80 != 0,
+		PayloadType: flags[1] & 0x7f,
+	}
+	if rtp.SequenceNumber, err = r.Read16(); err != nil {
+		return nil, fmt.Errorf("rtp packet too short: %w", err)
+	}
+	if rtp.Timestamp, err = r.Read32(); err != nil {
+		return nil, fmt.Errorf("rtp packet too short: %w", err)
+	}
+	if rtp.SSRC, err = r.Read32(); err != nil {
+		return nil, fmt.Errorf("rtp packet too short: %w", err)
+	}
+	if rtp.Version != 2 {
+		return nil, errors.New("unsupported rtp version")
+	}
+
+	for i := 0; i < int(rtp.CSRCCount); i++ {
+		csrc, err := r.Read32()
+		if err != nil {
+			return nil, fmt.Errorf("rtp csrc list too short: %w", err)
+		}
+		rtp.CSRC = append(rtp.CSRC, csrc)
+	}
+
+	// 拡張ヘッダーは読み飛ばす (profile 16bit + length 16bit(32bit単位) + 拡張)
+	if rtp.Extension {
+		if err := r.Skip(2); err != nil {
+			return nil, fmt.Errorf("rtp extension header too short: %w", err)
+		}
+		length, err := r.Read16()
+		if err != nil {
+			return nil, fmt.Errorf("rtp extension header too short: %w", err)
+		}
+		if err := r.Skip(int(length) * 4); err != nil {
+			return nil, fmt.Errorf("rtp extension header too short: %w", err)
+		}
+	}
+
+	rtp.Payload = r.Rest()
+	if rtp.Padding {
+		// 最後のオクテットがパディング長
+		if len(rtp.Payload) == 0 || int(rtp.Payload[len(rtp.Payload)-1]) > len(rtp.Payload) {
+			return nil, errors.New("invalid rtp padding")
+		}
+		rtp.Payload = rtp.Payload[:len(rtp.Payload)-int(rtp.Payload[len(rtp.Payload)-1])]
+	}
+	return rtp, nil
+}
+
+// RTPClockRate returns the timestamp clock rate of static payload types (RFC 3551). Dynamic payload types default to 8000 Hz.
+// 静的ペイロードタイプのタイムスタンプのクロックレートを返します。動的ペイロードタイプは8000Hzとみなします
+func RTPClockRate(payloadType uint8) uint32 {
+	switch payloadType {
+	case 6: // DVI4
+		return 16000
+	case 10, 11: // L16
+		return 44100
+	case 16: // DVI4
+		return 11025
+	case 17: // DVI4
+		return 22050
+	case 14, 25, 26, 28, 31, 32, 33, 34: // MPA and video
+		return 90000
+	default:
+		return 8000
+	}
+}
+
+// RTPStreamStat is the per-SSRC statistics of an RTP stream
+// RTPStreamStatはSSRCごとのRTPストリーム統計です
+type RTPStreamStat struct {
+	SSRC        uint32
+	PayloadType uint8
+	Packets     int
+	Lost        int     // expected - received (RFC 3550 A.3). 重複があると負になり得る
+	Jitter      float64 // interarrival jitter in timestamp units (RFC 3550 A.8) / タイムスタンプ単位の到着間隔ジッター
+}
+
+// JitterDuration returns the jitter converted to a duration using the clock rate of the payload type
+// ペイロードタイプのクロックレートを用いてジッターを時間に変換します
+func (s RTPStreamStat) JitterDuration() time.Duration {
+	return time.Duration(s.Jitter / float64(RTPClockRate(s.PayloadType)) * float64(time.Second))
+}
+
+type rtpStream struct {
+	stat RTPStreamStat
+
+	baseSeq uint16
+	maxSeq  uint16
+	cycles  uint32 // シーケンス番号の周回数 << 16
+	// 前回のパケットの到着時刻(タイムスタンプと同じ単位)とタイムスタンプ
+	lastArrival   float64
+	lastTimestamp uint32
+}
+
+// RTPStreams tracks packet count, loss and jitter of RTP streams keyed by SSRC
+// SSRCごとにRTPストリームのパケット数、損失、ジッターを追跡します
+type RTPStreams struct {
+	mu      sync.Mutex
+	streams map[uint32]*rtpStream
+}
+
+// NewRTPStreams creates an empty RTP stream tracker
+// 空のRTPストリームトラッカーを作成します
+func NewRTPStreams() *RTPStreams {
+	return &RTPStreams{
+		streams: make(map[uint32]*rtpStream),
+	}
+}
+
+// Update accounts an RTP packet received at arrival
+// arrivalに受信したRTPパケットを集計します
+func (r *RTPStreams) Update(rtp *RTP, arrival time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 到着時刻をタイムスタンプと同じ単位に変換する
+	arrivalUnits := float64(arrival.UnixNano()) * float64(RTPClockRate(rtp.PayloadType)) / float64(time.Second)
+
+	s, ok := r.streams[rtp.SSRC]
+	if !ok {
+		r.streams[rtp.SSRC] = &rtpStream{
+			stat: RTPStreamStat{
+				SSRC:        rtp.SSRC,
+				PayloadType: rtp.PayloadType,
+				Packets:     1,
+			},
+			baseSeq:       rtp.SequenceNumber,
+			maxSeq:        rtp.SequenceNumber,
+			lastArrival:   arrivalUnits,
+			lastTimestamp: rtp.Timestamp,
+		}
+		return
+	}
+
+	s.stat.Packets++
+	// 前方へのジャンプ(周回含む)のみ maxSeq を進める. 遅延到着したパケットは受信数のみ数える
+	if delta := rtp.SequenceNumber - s.maxSeq; delta != 0 && delta < 0x8000 {
+		if rtp.SequenceNumber < s.maxSeq {
+			s.cycles += 1 << 16
+		}
+		s.maxSeq = rtp.SequenceNumber
+	}
+	expected := int(s.cycles+uint32(s.maxSeq)) - int(s.baseSeq) + 1
+	s.stat.Lost = expected - s.stat.Packets
+
+	// transit time の差. タイムスタンプの差は符号付き32ビットで取り、周回しても連続させる
+	d := (arrivalUnits - s.lastArrival) - float64(int32(rtp.Timestamp-s.lastTimestamp))
+	if d < 0 {
+		d = -d
+	}
+	s.stat.Jitter += (d - s.stat.Jitter) / 16
+	s.lastArrival, s.lastTimestamp = arrivalUnits, rtp.Timestamp
+}
+
+// Stats returns the per-SSRC statistics sorted by SSRC
+// SSRCでソートしたSSRCごとの統計を返します
+func (r *RTPStreams) Stats() []RTPStreamStat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]RTPStreamStat, 0, len(r.streams))
+	for _, s := range r.streams {
+		stats = append(stats, s.stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].SSRC < stats[j].SSRC
+	})
+	return stats
+}
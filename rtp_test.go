@@ -0,0 +1,100 @@
+package packemon
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRTPPacket(seq uint16, timestamp uint32, ssrc uint32) []byte {
+	b := []byte{
+		0x81, 0x00, // V=2, CC=1, PT=0 (PCMU)
+		byte(seq >> 8), byte(seq),
+		byte(timestamp >> 24), byte(timestamp >> 16), byte(timestamp >> 8), byte(timestamp),
+		byte(ssrc >> 24), byte(ssrc >> 16), byte(ssrc >> 8), byte(ssrc),
+		0x00, 0x00, 0x00, 0x2a, // CSRC
+	}
+	return append(b, 0xff, 0xff, 0xff, 0xff)
+}
+
+// TestParsedRTP tests RTP header decoding including the CSRC list
+// CSRCリストを含むRTPヘッダーの解析をテストします
+func TestParsedRTP(t *testing.T) {
+	rtp, err := ParsedRTP(newTestRTPPacket(100, 16000, 0x11223344))
+	if err != nil {
+		t.Fatalf("ParsedRTP returned error: %v", err)
+	}
+	if rtp.Version != 2 || rtp.PayloadType != 0 || rtp.SequenceNumber != 100 || rtp.Timestamp != 16000 || rtp.SSRC != 0x11223344 {
+		t.Errorf("ParsedRTP = %+v", rtp)
+	}
+	if len(rtp.CSRC) != 1 || rtp.CSRC[0] != 42 {
+		t.Errorf("CSRC = %v, want [42]", rtp.CSRC)
+	}
+	if len(rtp.Payload) != 4 {
+		t.Errorf("len(Payload) = %d, want 4", len(rtp.Payload))
+	}
+
+	if _, err := ParsedRTP([]byte{0x40, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("ParsedRTP should fail for version 1")
+	}
+}
+
+// TestRTPStreamsSequenceGap tests that a sequence gap between two packets is counted as loss
+// 2つのパケット間のシーケンス番号の欠落が損失として数えられることをテストします
+func TestRTPStreamsSequenceGap(t *testing.T) {
+	streams := NewRTPStreams()
+	arrival := time.Unix(1700000000, 0)
+
+	first, _ := ParsedRTP(newTestRTPPacket(100, 16000, 0x11223344))
+	streams.Update(first, arrival)
+	// 3パケット後 (101, 102 が欠落). 20ms 間隔 = 160 タイムスタンプ
+	second, _ := ParsedRTP(newTestRTPPacket(103, 16000+3*160, 0x11223344))
+	streams.Update(second, arrival.Add(60*time.Millisecond))
+
+	stats := streams.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("len(Stats()) = %d, want 1", len(stats))
+	}
+	if stats[0].Packets != 2 || stats[0].Lost != 2 {
+		t.Errorf("Packets = %d, Lost = %d, want 2, 2", stats[0].Packets, stats[0].Lost)
+	}
+	if stats[0].Jitter > 0.01 {
+		t.Errorf("Jitter = %f, want 0 for packets arriving on time", stats[0].Jitter)
+	}
+
+	// 10ms (80 タイムスタンプ) 遅れて到着した場合、ジッターは 80/16 = 5
+	third, _ := ParsedRTP(newTestRTPPacket(104, 16000+4*160, 0x11223344))
+	streams.Update(third, arrival.Add(90*time.Millisecond))
+	stats = streams.Stats()
+	if stats[0].Lost != 2 {
+		t.Errorf("Lost = %d, want 2", stats[0].Lost)
+	}
+	if stats[0].Jitter < 4.99 || stats[0].Jitter > 5.01 {
+		t.Errorf("Jitter = %f, want 5", stats[0].Jitter)
+	}
+	if got := stats[0].JitterDuration(); got < 624*time.Microsecond || got > 626*time.Microsecond {
+		t.Errorf("JitterDuration() = %s, want 625µs", got)
+	}
+}
+
+// TestRTPStreamsTimestampWraparound tests that jitter stays 0 for packets arriving on time across the 32-bit timestamp wraparound,
+// and that the DVI4 payload types use their own clock rates
+// 32ビットのタイムスタンプが周回しても時間どおりに届くパケットのジッターが0のままであること、
+// およびDVI4のペイロードタイプがそれぞれのクロックレートを使うことをテストします
+func TestRTPStreamsTimestampWraparound(t *testing.T) {
+	streams := NewRTPStreams()
+	arrival := time.Unix(1700000000, 0)
+	timestamp := uint32(0xffffffff - 2*160 + 1)
+	for i := 0; i < 5; i++ {
+		rtp, _ := ParsedRTP(newTestRTPPacket(uint16(100+i), timestamp+uint32(i)*160, 0x11223344))
+		streams.Update(rtp, arrival.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	if stats := streams.Stats(); stats[0].Jitter > 0.01 {
+		t.Errorf("Jitter = %f across the timestamp wraparound, want 0", stats[0].Jitter)
+	}
+
+	for payloadType, want := range map[uint8]uint32{0: 8000, 5: 8000, 6: 16000, 16: 11025, 17: 22050, 26: 90000} {
+		if got := RTPClockRate(payloadType); got != want {
+			t.Errorf("RTPClockRate(%d) = %d, want %d", payloadType, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package packemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ScenarioStep is a step of a Scenario: wait Delay, then send Frame, or the frame Build returns if Frame is nil
+// Scenarioの1ステップです。Delayだけ待ってから、Frame(nilの場合はBuildが返すフレーム)を送信します
+type ScenarioStep struct {
+	Delay time.Duration
+	Frame []byte
+	// Build builds the frame when the step runs, from the responses collected so far, e.g. to acknowledge a SYN-ACK
+	// ステップの実行時に、それまでに集めた応答からフレームを作成します(SYN-ACKへの応答など)
+	Build func(responses []*Passive) ([]byte, error)
+}
+
+// Scenario is a scripted sequence of timed frames, such as "send SYN, wait 100ms, send data, wait, send FIN", and the responses to collect while it runs.
+// Scenarioは「SYNを送信し、100ms待ってデータを送信し、待ってFINを送信する」のような時間指定のフレームの並びと、実行中に集める応答です
+type Scenario struct {
+	Steps []ScenarioStep
+	// Repeat runs the steps this many times in a row, e.g. for load. Zero runs them once
+	// ステップを続けて実行する回数(負荷をかける場合など)。0の場合は1回
+	Repeat int
+	// Match selects the captured packets to collect, e.g. DisplayFilter.Match. Nil collects every packet
+	// 集めるパケットを選びます(DisplayFilter.Matchなど)。nilの場合は全てのパケットを集めます
+	Match func(*Passive) bool
+	// Wait is how long to keep collecting responses after the last step
+	// 最後のステップの後に応答を集め続ける時間
+	Wait time.Duration
+}
+
+// Run executes the steps in order on nwif and returns the matching packets captured from the start until Wait after the last step.
+// Like the Capture functions it reads PassiveCh itself, so don't run it together with another receiver such as the Monitor.
+// If a step fails or ctx is canceled, the responses collected so far are returned with the error.
+// nwifでステップを順に実行し、開始から最後のステップのWait後までにキャプチャした、一致するパケットを返します。
+// Capture関数と同様にPassiveChを自身で読むため、Monitorなどの他の受信処理と同時に実行しないでください
+func (s *Scenario) Run(ctx context.Context, nwif *NetworkInterface) ([]*Passive, error) {
+	if s.Repeat < 0 || s.Wait < 0 {
+		return nil, errors.New("scenario repeat and wait must not be negative")
+	}
+	for i, step := range s.Steps {
+		if step.Delay < 0 || (step.Frame == nil && step.Build == nil) {
+			return nil, fmt.Errorf("scenario step %d needs a frame or a builder and a non-negative delay", i)
+		}
+	}
+
+	var mu sync.Mutex
+	responses := []*Passive{}
+	collected := func() []*Passive {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*Passive{}, responses...)
+	}
+
+	captureCtx, stopCapture := context.WithCancel(ctx)
+	captureErr := make(chan error, 1)
+	go func() {
+		captureErr <- nwif.Capture(captureCtx, 0, 0, func(passive *Passive) error {
+			if s.Match == nil || s.Match(passive) {
+				mu.Lock()
+				responses = append(responses, passive)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}()
+	// 受信ループを止めてから返す
+	stop := func(err error) ([]*Passive, error) {
+		stopCapture()
+		if capErr := <-captureErr; err == nil && capErr != nil && !errors.Is(capErr, context.Canceled) {
+			err = capErr
+		}
+		return collected(), err
+	}
+
+	for round := 0; round < max(1, s.Repeat); round++ {
+		for i, step := range s.Steps {
+			if err := sleepContext(ctx, step.Delay); err != nil {
+				return stop(err)
+			}
+			frame := step.Frame
+			if frame == nil {
+				var err error
+				if frame, err = step.Build(collected()); err != nil {
+					return stop(fmt.Errorf("scenario step %d: %w", i, err))
+				}
+			}
+			if err := nwif.SendEthernetFrame(ctx, frame); err != nil {
+				return stop(fmt.Errorf("scenario step %d: %w", i, err))
+			}
+		}
+	}
+	return stop(sleepContext(ctx, s.Wait))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
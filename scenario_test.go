@@ -0,0 +1,98 @@
+package packemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 実験用の EtherType 0x88b5 で他の通信と区別する
+func scenarioTestFrame(payload string) []byte {
+	frame := append(make([]byte, 12), 0x88, 0xb5)
+	return append(frame, []byte(payload)...)
+}
+
+func scenarioTestMatch(passive *Passive) bool {
+	return passive.EthernetFrame != nil && passive.EthernetFrame.Type == 0x88b5
+}
+
+// TestScenarioLoopback tests that the steps of a repeated scenario are sent in order with their delays on the loopback interface,
+// and that a builder sees the responses collected before its step
+// 繰り返すシナリオのステップが、ループバックインターフェースで遅延を守って順に送信され、ビルダーがそのステップまでに集めた応答を受け取ることをテストします
+func TestScenarioLoopback(t *testing.T) {
+	nwif := loopbackTestInterface(t)
+	defer nwif.Close()
+	// ループバックでは送信したフレームが送信と受信の 2 回観測されるので、受信のみにする
+	if err := nwif.SetCaptureDirection(CAPTURE_DIRECTION_INGRESS); err != nil {
+		t.Skipf("capture direction is not available: %v", err)
+	}
+
+	var seen []int
+	scenario := &Scenario{
+		Steps: []ScenarioStep{
+			{Frame: scenarioTestFrame("syn")},
+			{Delay: 50 * time.Millisecond, Build: func(responses []*Passive) ([]byte, error) {
+				seen = append(seen, len(responses))
+				return scenarioTestFrame("data"), nil
+			}},
+			{Delay: 50 * time.Millisecond, Frame: scenarioTestFrame("fin")},
+		},
+		Repeat: 2,
+		Match:  scenarioTestMatch,
+		Wait:   100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	responses, err := scenario.Run(context.Background(), nwif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Run took %s, want at least the 300ms of delays and wait", elapsed)
+	}
+
+	want := []string{"syn", "data", "fin", "syn", "data", "fin"}
+	if len(responses) != len(want) {
+		t.Fatalf("collected %d responses, want %d", len(responses), len(want))
+	}
+	for i, passive := range responses {
+		if got := passive.EthernetFrame.Payload; !bytes.HasPrefix(got, []byte(want[i])) {
+			t.Errorf("responses[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+	// 1 回目の "data" の時点では "syn" のみ、2 回目では 1 回目の 3 つと "syn"
+	if len(seen) != 2 || seen[0] != 1 || seen[1] != 4 {
+		t.Errorf("the builder saw %v responses, want [1 4]", seen)
+	}
+}
+
+// TestScenarioErrors tests invalid steps, a failing step and cancellation
+// 不正なステップ、失敗するステップ、キャンセルをテストします
+func TestScenarioErrors(t *testing.T) {
+	offline := NewOfflineNetworkInterface("lo")
+
+	if _, err := (&Scenario{Steps: []ScenarioStep{{Delay: time.Millisecond}}}).Run(context.Background(), offline); err == nil {
+		t.Error("a step without a frame or a builder should be an error")
+	}
+
+	// オフラインでは送信できない
+	if _, err := (&Scenario{Steps: []ScenarioStep{{Frame: scenarioTestFrame("syn")}}}).Run(context.Background(), offline); !errors.Is(err, ErrOffline) {
+		t.Errorf("offline: err = %v, want ErrOffline", err)
+	}
+
+	errBuild := errors.New("no SYN-ACK")
+	build := func([]*Passive) ([]byte, error) { return nil, errBuild }
+	if _, err := (&Scenario{Steps: []ScenarioStep{{Build: build}}}).Run(context.Background(), offline); !errors.Is(err, errBuild) {
+		t.Errorf("failing builder: err = %v, want %v", err, errBuild)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := (&Scenario{Steps: []ScenarioStep{{Delay: time.Minute, Frame: scenarioTestFrame("syn")}}}).Run(ctx, offline)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("canceled: err = %v after %s, want %v right after the deadline", err, time.Since(start), context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,194 @@
+package packemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// SELF_TEST_TIMEOUT is how long SelfTest waits to capture its own echo
+	// SelfTestが自身のエコーのキャプチャを待つ時間です
+	SELF_TEST_TIMEOUT = 3 * time.Second
+
+	// SELF_TEST_RESEND_INTERVAL is how often the echo is sent again until it is captured, in case the receive loop was not ready yet
+	// 受信ループの準備ができていなかった場合に備え、キャプチャされるまでエコーを再送する間隔です
+	SELF_TEST_RESEND_INTERVAL = 500 * time.Millisecond
+)
+
+// SelfTestResult is the pass/fail diagnostic of SelfTest
+// SelfTestの成否の診断結果です
+type SelfTestResult struct {
+	Passed    bool
+	Interface string
+	// Protocol is the echo sent, "ICMP" or "ICMPv6". Empty if none could be built
+	// 送信したエコー("ICMP"または"ICMPv6")。作成できなかった場合は空
+	Protocol string
+	Addr     net.IP
+	// Sent is how many times the echo was sent, and Elapsed the time from the first send until it was captured or the test gave up
+	// エコーを送信した回数と、最初の送信からキャプチャされるか諦めるまでの時間
+	Sent    int
+	Elapsed time.Duration
+	// Diagnostic explains the result and, on failure, what to check
+	// 結果の説明。失敗した場合は確認すべきこと
+	Diagnostic string
+}
+
+func (r SelfTestResult) String() string {
+	status := "FAIL"
+	if r.Passed {
+		status = "PASS"
+	}
+	return fmt.Sprintf("self-test %s on %s: %s", status, r.Interface, r.Diagnostic)
+}
+
+var errSelfTestCaptured = errors.New("self-test echo captured")
+
+// SelfTest sends a crafted ICMP echo request (ICMPv6 without an IPv4 address) from the interface to itself and checks that the capture path receives and decodes it.
+// It is a one-call sanity check that sending, capturing and parsing work in the environment, e.g. permissions, the capture direction and the link type.
+// Like Capture it reads PassiveCh itself, so don't run it together with another receiver such as the Monitor.
+// 細工したICMPエコー要求(IPv4アドレスが無い場合はICMPv6)をインターフェースから自身宛に送り、キャプチャ経路で受信して解析できることを確認します。
+// 権限、キャプチャの方向、リンク種別など、その環境で送信、キャプチャ、解析が動くかを一度の呼び出しで確認できます。
+// Captureと同様にPassiveChを自身で読むため、Monitorなど他の受信処理と同時に実行しないでください
+func (nwif *NetworkInterface) SelfTest(ctx context.Context) SelfTestResult {
+	result := SelfTestResult{}
+	if nwif.Intf != nil {
+		result.Interface = nwif.Intf.Name
+	}
+	if nwif.offline {
+		result.Diagnostic = "the interface is offline, so nothing can be sent"
+		return result
+	}
+	// BSD のループバック(DLT_NULL)やトンネルなどは Ethernet フレームを送受信できない
+	if !nwif.ethernetLinkPlatform() {
+		result.Diagnostic = "the link layer is not Ethernet (e.g. the BSD loopback or a tunnel), and packemon sends and decodes Ethernet frames only"
+		return result
+	}
+
+	// 他の通信と区別するための印
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		result.Diagnostic = fmt.Sprintf("failed to generate the echo payload: %v", err)
+		return result
+	}
+	payload := append([]byte("packemon self-test "), nonce...)
+	frame, err := nwif.selfTestFrame(&result, payload)
+	if err != nil {
+		result.Diagnostic = err.Error()
+		return result
+	}
+
+	captureCtx, cancel := context.WithTimeout(ctx, SELF_TEST_TIMEOUT)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- nwif.Capture(captureCtx, 0, 0, func(passive *Passive) error {
+			if selfTestCaptured(passive, payload) {
+				return errSelfTestCaptured
+			}
+			return nil
+		})
+	}()
+
+	ticker := time.NewTicker(SELF_TEST_RESEND_INTERVAL)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		result.Sent++
+		if err := nwif.SendEthernetFrame(captureCtx, frame); err != nil {
+			cancel()
+			<-done
+			result.Elapsed = time.Since(start)
+			result.Diagnostic = fmt.Sprintf("failed to send the %s echo: %v", result.Protocol, err)
+			if errors.Is(err, os.ErrPermission) {
+				result.Diagnostic += ". To send, " + CAPTURE_PERMISSION_HINT
+			}
+			return result
+		}
+
+		select {
+		case err := <-done:
+			result.Elapsed = time.Since(start)
+			switch {
+			case errors.Is(err, errSelfTestCaptured):
+				result.Passed = true
+				result.Diagnostic = fmt.Sprintf("the %s echo to %s was captured and decoded", result.Protocol, result.Addr)
+			case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+				result.Diagnostic = fmt.Sprintf("the %s echo to %s was sent %d times but not captured within %s. Check the capture direction (a non-loopback interface only captures its own frames as egress) and the capture filter",
+					result.Protocol, result.Addr, result.Sent, SELF_TEST_TIMEOUT)
+			default:
+				result.Diagnostic = fmt.Sprintf("the capture stopped: %v", err)
+			}
+			return result
+		case <-ticker.C:
+		}
+	}
+}
+
+// selfTestFrame builds the Ethernet frame of an echo request from the interface's own address to itself.
+// A loopback interface without an address of its own uses 127.0.0.1 or ::1
+// インターフェース自身のアドレスから自身宛のエコー要求のEthernetフレームを作成します。
+// 自身のアドレスが無いループバックインターフェースは127.0.0.1または::1を使います
+func (nwif *NetworkInterface) selfTestFrame(result *SelfTestResult, payload []byte) ([]byte, error) {
+	loopback := nwif.Intf != nil && nwif.Intf.Flags&net.FlagLoopback != 0
+	// ループバックなど MAC アドレスの無いインターフェースではゼロのまま
+	var mac HardwareAddr
+	if nwif.Intf != nil {
+		copy(mac[:], nwif.Intf.HardwareAddr)
+	}
+
+	identifier := ICMPIdentifiers.Next()
+
+	switch {
+	case nwif.IPAddr != 0 || (loopback && nwif.IPv6Addr == nil):
+		addr := nwif.IPAddr
+		if addr == 0 {
+			addr = binary.BigEndian.Uint32(net.IPv4(127, 0, 0, 1).To4())
+		}
+		result.Protocol = "ICMP"
+		result.Addr = net.IP(binary.BigEndian.AppendUint32(nil, addr))
+
+		return SerializeStack([]Layer{
+			NewEthernetFrame(mac, mac, ETHER_TYPE_IPv4, nil),
+			NewIPv4(IPv4_PROTO_ICMP, addr, addr),
+			&ICMP{Typ: ICMP_TYPE_REQUEST, Identifier: identifier, Sequence: 0x0001},
+			Payload(payload),
+		})
+	case nwif.IPv6Addr != nil || loopback:
+		addr := nwif.IPv6Addr.To16()
+		if addr == nil {
+			addr = net.IPv6loopback
+		}
+		result.Protocol = "ICMPv6"
+		result.Addr = addr
+
+		// ICMPv6 の MessageBody は識別子とシーケンス番号から始まる
+		body := binary.BigEndian.AppendUint16(nil, identifier)
+		body = binary.BigEndian.AppendUint16(body, 0x0001)
+		return SerializeStack([]Layer{
+			NewEthernetFrame(mac, mac, ETHER_TYPE_IPv6, nil),
+			NewIPv6(IPv6_NEXT_HEADER_ICMPv6, addr, addr),
+			&ICMPv6{Type: ICMPv6_TYPE_ECHO_REQUEST, MessageBody: append(body, payload...)},
+		})
+	}
+	return nil, errors.New("the interface has no IPv4 or IPv6 address to send the echo to")
+}
+
+// selfTestCaptured reports whether passive is the echo request carrying payload, decoded up to ICMP or ICMPv6
+// passiveがpayloadを運ぶエコー要求で、ICMPまたはICMPv6まで解析されているかどうかを返します
+func selfTestCaptured(passive *Passive, payload []byte) bool {
+	switch {
+	case passive.ICMP != nil:
+		return passive.ICMP.Type == ICMP_TYPE_REQUEST && bytes.HasPrefix(passive.ICMP.Payload, payload)
+	case passive.ICMPv6 != nil:
+		// ICMPv6Packet の Payload は識別子とシーケンス番号から始まる
+		return passive.ICMPv6.Type == ICMPv6_TYPE_ECHO_REQUEST && bytes.HasSuffix(passive.ICMPv6.Payload, payload)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package packemon
+
+import (
+	"context"
+	"testing"
+)
+
+// TestSelfTest tests that an offline interface fails with a diagnostic, and that the echo is captured and decoded on the loopback interface where capture is available
+// オフラインのインターフェースは診断付きで失敗し、キャプチャできる環境ではループバックインターフェースでエコーがキャプチャされ解析されることをテストします
+func TestSelfTest(t *testing.T) {
+	offline := NewOfflineNetworkInterface("lo")
+	if result := offline.SelfTest(context.Background()); result.Passed || result.Diagnostic == "" {
+		t.Errorf("offline: %+v, want a failure with a diagnostic", result)
+	}
+
+	lo, err := NewNetworkInterface("lo")
+	if err != nil {
+		t.Skipf("capture on lo is not available: %v", err)
+	}
+	defer lo.Close()
+
+	result := lo.SelfTest(context.Background())
+	if !lo.ethernetLinkPlatform() {
+		// BSD のループバックは Ethernet ではないので、失敗の理由が返る
+		if result.Passed || result.Sent != 0 {
+			t.Errorf("non-Ethernet loopback: %+v, want a failure before sending", result)
+		}
+		t.Skipf("loopback is not Ethernet: %s", result)
+	}
+	if !result.Passed {
+		t.Fatalf("SelfTest() = %s", result)
+	}
+	if result.Protocol != "ICMP" && result.Protocol != "ICMPv6" || result.Addr == nil || result.Sent < 1 {
+		t.Errorf("result = %+v", result)
+	}
+}
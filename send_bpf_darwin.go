@@ -0,0 +1,38 @@
+//go:build darwin
+// +build darwin
+
+package packemon
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openBPFWriter opens a free /dev/bpf device bound to the interface, to inject frames without pcap
+// pcapを使わずにフレームを送信するため、空いている/dev/bpfデバイスを開いてインターフェースに割り当てます
+func openBPFWriter(name string) (int, error) {
+	fd, err := -1, error(unix.EBUSY)
+	for i := 0; i < 256 && errors.Is(err, unix.EBUSY); i++ {
+		fd, err = unix.Open(fmt.Sprintf("/dev/bpf%d", i), unix.O_WRONLY, 0)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to open a bpf device: %w", err)
+	}
+
+	// BIOCSETIF は struct ifreq を受け取る. 先頭がインターフェース名
+	var ifreq [unix.IFNAMSIZ + 16]byte
+	copy(ifreq[:unix.IFNAMSIZ-1], name)
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.BIOCSETIF), uintptr(unsafe.Pointer(&ifreq[0]))); errno != 0 {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to bind the bpf device to %s: %w", name, errno)
+	}
+	// 送信元 MAC アドレスをカーネルに書き換えさせない
+	if err := unix.IoctlSetPointerInt(fd, unix.BIOCSHDRCMPLT, 1); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to set BIOCSHDRCMPLT: %w", err)
+	}
+	return fd, nil
+}
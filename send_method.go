@@ -0,0 +1,66 @@
+package packemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SendMethod selects how frames are injected. The default is a raw socket on Linux and pcap on macOS.
+// pcap may not inject at layer 2 on every macOS version, so SEND_METHOD_BPF writes frames to a /dev/bpf device directly instead
+// フレームの送信方法を表します。デフォルトはLinuxではraw socket、macOSではpcapです。
+// macOSのバージョンによってはpcapでレイヤ2から送信できないことがあるため、SEND_METHOD_BPFで/dev/bpfデバイスに直接書き込めます
+type SendMethod int32
+
+const (
+	SEND_METHOD_DEFAULT SendMethod = iota // Linux は raw socket、macOS は pcap
+	SEND_METHOD_BPF                       // /dev/bpf への書き込み. macOS のみ
+)
+
+var sendMethodNames = map[SendMethod]string{
+	SEND_METHOD_DEFAULT: "default",
+	SEND_METHOD_BPF:     "bpf",
+}
+
+func (m SendMethod) String() string {
+	if name, ok := sendMethodNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("SendMethod(%d)", int32(m))
+}
+
+// ParseSendMethod parses "default" or "bpf". An empty name is SEND_METHOD_DEFAULT.
+// "default"、"bpf"を解析します。空文字はSEND_METHOD_DEFAULTになります
+func ParseSendMethod(name string) (SendMethod, error) {
+	if name == "" {
+		return SEND_METHOD_DEFAULT, nil
+	}
+	for method, methodName := range sendMethodNames {
+		if strings.EqualFold(name, methodName) {
+			return method, nil
+		}
+	}
+	return SEND_METHOD_DEFAULT, fmt.Errorf("unsupported send method: %s", name)
+}
+
+// SetSendMethod selects how SendEthernetFrame injects frames. Only macOS supports a method other than SEND_METHOD_DEFAULT.
+// Don't call it while sending
+// SendEthernetFrameがフレームを送信する方法を設定します。SEND_METHOD_DEFAULT以外はmacOSのみ対応しています。
+// 送信中には呼ばないでください
+func (nwif *NetworkInterface) SetSendMethod(method SendMethod) error {
+	if _, ok := sendMethodNames[method]; !ok {
+		return fmt.Errorf("unsupported send method: %s", method)
+	}
+	if !nwif.offline {
+		if err := nwif.setSendMethodPlatform(method); err != nil {
+			return err
+		}
+	}
+	nwif.sendMethod.Store(int32(method))
+	return nil
+}
+
+// SendMethod returns the current send method
+// 現在の送信方法を返します
+func (nwif *NetworkInterface) SendMethod() SendMethod {
+	return SendMethod(nwif.sendMethod.Load())
+}
@@ -0,0 +1,249 @@
+package packemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SESSIONS_DIR is the directory under the config directory where sessions are saved, one JSON file per session
+// セッションを保存する設定ディレクトリ配下のディレクトリです。1セッションを1つのJSONファイルに保存します
+const SESSIONS_DIR = "sessions"
+
+// Session is an analysis session saved to be re-opened later with the same view of the same traffic: the interface,
+// the display filter, the Decode As overrides, the coloring rules and the exported capture, whose annotations are in its sidecar file
+// 同じ通信を同じ見え方で後から開き直すために保存する解析のセッションです。インターフェース、表示フィルター、Decode Asの設定、
+// 色付けルール、書き出したキャプチャを保持します。注釈はキャプチャのサイドカーファイルにあります
+type Session struct {
+	Name          string            `json:"name"`
+	Interface     string            `json:"interface,omitempty"`
+	Filter        string            `json:"filter,omitempty"`        // Display filter expression / 表示フィルターの式
+	DecodeAs      map[uint16]string `json:"decodeAs,omitempty"`      // Port -> protocol / ポート -> プロトコル
+	ColoringRules []ColoringRule    `json:"coloringRules,omitempty"` // Empty uses the config's rules / 空の場合は設定のルール
+	CaptureFile   string            `json:"captureFile,omitempty"`   // Absolute path of the exported capture / 書き出したキャプチャの絶対パス
+	SavedAt       time.Time         `json:"savedAt"`
+}
+
+// Validate checks the name, the filter expression, the Decode As overrides and the coloring rules
+// 名前、フィルターの式、Decode Asの設定、色付けルールを検証します
+func (s *Session) Validate() error {
+	if s.Name == "" || s.Name == "." || s.Name == ".." || strings.ContainsAny(s.Name, `/\`) {
+		return fmt.Errorf("invalid session name: %q", s.Name)
+	}
+	if s.Filter != "" {
+		if _, err := CompileDisplayFilter(s.Filter); err != nil {
+			return fmt.Errorf("session %s: %w", s.Name, err)
+		}
+	}
+	table := &DecodeAsTable{}
+	for port, proto := range s.DecodeAs {
+		if err := table.Set(port, proto); err != nil {
+			return fmt.Errorf("session %s: %w", s.Name, err)
+		}
+	}
+	if len(s.ColoringRules) > 0 {
+		if _, err := NewColoringRules(s.ColoringRules); err != nil {
+			return fmt.Errorf("session %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// AnnotationsFile returns the path of the annotation sidecar file of the capture, empty without a capture
+// キャプチャの注釈のサイドカーファイルのパスを返します。キャプチャが無い場合は空です
+func (s *Session) AnnotationsFile() string {
+	if s.CaptureFile == "" {
+		return ""
+	}
+	return AnnotationsPath(s.CaptureFile)
+}
+
+// OpenCapture reads the packets of the capture file, decoded with the Decode As overrides of the session, and the annotations of its sidecar file.
+// A session without a capture file has no packets, and a capture without a sidecar file has no annotations
+// キャプチャファイルのパケットをセッションのDecode Asの設定で解析して読み込み、サイドカーファイルの注釈とともに返します。
+// キャプチャファイルの無いセッションにはパケットが無く、サイドカーファイルの無いキャプチャには注釈がありません
+func (s *Session) OpenCapture() ([]*Passive, *Annotations, error) {
+	if s.CaptureFile == "" {
+		return nil, NewAnnotations(), nil
+	}
+
+	f, err := os.Open(s.CaptureFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open capture file: %v", err)
+	}
+	defer f.Close()
+	pr, err := NewPcapReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", s.CaptureFile, err)
+	}
+	for port, proto := range s.DecodeAs {
+		if err := pr.DecodeAs(port, proto); err != nil {
+			return nil, nil, err
+		}
+	}
+	packets := []*Passive{}
+	for i := 1; ; i++ {
+		passive, err := pr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: packet %d: %w", s.CaptureFile, i, err)
+		}
+		packets = append(packets, passive)
+	}
+
+	sidecar, err := os.Open(s.AnnotationsFile())
+	if os.IsNotExist(err) {
+		return packets, NewAnnotations(), nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open annotations file: %v", err)
+	}
+	defer sidecar.Close()
+	annotations, err := LoadAnnotations(sidecar)
+	if err != nil {
+		return nil, nil, err
+	}
+	return packets, annotations, nil
+}
+
+// GetColoringRules compiles the coloring rules of the session, falling back to the config's rules when the session has none
+// セッションの色付けルールをコンパイルします。セッションにルールが無い場合は設定のルールを使います
+func (s *Session) GetColoringRules(c *Config) (*ColoringRules, error) {
+	if len(s.ColoringRules) == 0 {
+		return c.GetColoringRules()
+	}
+	return NewColoringRules(s.ColoringRules)
+}
+
+// ApplyDecodeAs applies the Decode As overrides of the session to a capture
+// セッションのDecode Asの設定をキャプチャに適用します
+func (s *Session) ApplyDecodeAs(nwif *NetworkInterface) error {
+	for port, proto := range s.DecodeAs {
+		if err := nwif.DecodeAs(port, proto); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSession validates and saves a session under the config directory, replacing one of the same name.
+// The capture file is saved as an absolute path, so the session can be loaded from any directory
+// セッションを検証して設定ディレクトリ配下に保存します。同じ名前のセッションは置き換えます。
+// どのディレクトリからでも読み込めるよう、キャプチャファイルは絶対パスで保存します
+func (c *Config) SaveSession(session Session) error {
+	if err := session.Validate(); err != nil {
+		return err
+	}
+	if session.CaptureFile != "" {
+		abs, err := filepath.Abs(session.CaptureFile)
+		if err != nil {
+			return fmt.Errorf("failed to resolve capture file: %v", err)
+		}
+		session.CaptureFile = abs
+	}
+	if session.SavedAt.IsZero() {
+		session.SavedAt = time.Now()
+	}
+
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, session.Name+".json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write session file: %v", err)
+	}
+	return nil
+}
+
+// LoadSession loads a saved session by name
+// 保存したセッションを名前で読み込みます
+func (c *Config) LoadSession(name string) (*Session, error) {
+	if err := (&Session{Name: name}).Validate(); err != nil {
+		return nil, err
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("session not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %v", err)
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to parse session file: %v", err)
+	}
+	// 手で編集された場合に備えて検証する
+	if err := session.Validate(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ListSessions returns the names of the saved sessions in alphabetical order
+// 保存したセッションの名前をアルファベット順に返します
+func (c *Config) ListSessions() ([]string, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions directory: %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// DeleteSession deletes a saved session by name. The capture file and its annotations are kept
+// 保存したセッションを名前で削除します。キャプチャファイルとその注釈は残します
+func (c *Config) DeleteSession(name string) error {
+	if err := (&Session{Name: name}).Validate(); err != nil {
+		return err
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name+".json")); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session not found: %s", name)
+		}
+		return fmt.Errorf("failed to delete session file: %v", err)
+	}
+	return nil
+}
+
+func sessionsDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, SESSIONS_DIR)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create sessions directory: %v", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,144 @@
+package packemon
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSessionRoundTrip tests that a session with a filter and Decode As overrides is saved under the config directory
+// and loaded back unchanged, and that invalid sessions are rejected
+// フィルターとDecode Asの設定を持つセッションが設定ディレクトリ配下に保存され、そのまま読み込めること、
+// および不正なセッションが拒否されることをテストします
+func TestSessionRoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+
+	config := DefaultConfig()
+	session := Session{
+		Name:          "broken-handshake",
+		Interface:     "eth0",
+		Filter:        "tcp.flags.reset == 1",
+		DecodeAs:      map[uint16]string{8443: "tls", 5353: "dns"},
+		ColoringRules: []ColoringRule{{Name: "resets", Filter: "tcp.flags.reset == 1", Color: "red"}},
+		CaptureFile:   "capture.pcap",
+	}
+	if err := config.SaveSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".packemon", SESSIONS_DIR, "broken-handshake.json")); err != nil {
+		t.Errorf("session file: %v", err)
+	}
+
+	loaded, err := config.LoadSession("broken-handshake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := session
+	want.CaptureFile = filepath.Join(home, "capture.pcap")
+	want.SavedAt = loaded.SavedAt
+	if loaded.SavedAt.IsZero() || !reflect.DeepEqual(*loaded, want) {
+		t.Errorf("LoadSession() = %+v\nwant %+v", *loaded, want)
+	}
+	if got := loaded.AnnotationsFile(); got != want.CaptureFile+ANNOTATIONS_FILE_SUFFIX {
+		t.Errorf("AnnotationsFile() = %s", got)
+	}
+
+	if names, err := config.ListSessions(); err != nil || !reflect.DeepEqual(names, []string{"broken-handshake"}) {
+		t.Errorf("ListSessions() = %v, %v", names, err)
+	}
+	if err := config.DeleteSession("broken-handshake"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadSession("broken-handshake"); err == nil {
+		t.Error("a deleted session should not be loaded")
+	}
+
+	// ルールの無いセッションは設定のルールで色付けする
+	config.ColoringRules = []ColoringRule{{Name: "dns", Filter: "udp.port == 53", Color: "blue"}}
+	if rules, err := (&Session{Name: "plain"}).GetColoringRules(config); err != nil || !reflect.DeepEqual(rules.Rules(), config.ColoringRules) {
+		t.Errorf("GetColoringRules() without rules = %+v, %v, want the config's", rules, err)
+	}
+	if rules, err := session.GetColoringRules(config); err != nil || !reflect.DeepEqual(rules.Rules(), session.ColoringRules) {
+		t.Errorf("GetColoringRules() = %+v, %v, want the session's", rules, err)
+	}
+
+	for _, invalid := range []Session{
+		{Name: ""},
+		{Name: "../escape"},
+		{Name: "bad-filter", Filter: "tcp.nope =="},
+		{Name: "bad-decode-as", DecodeAs: map[uint16]string{8080: "nope"}},
+		{Name: "bad-coloring", ColoringRules: []ColoringRule{{Name: "x", Filter: "(", Color: "red"}}},
+	} {
+		if err := config.SaveSession(invalid); err == nil {
+			t.Errorf("SaveSession(%+v) succeeded", invalid)
+		}
+	}
+}
+
+// TestSessionOpenCapture tests that the capture of a session is read back decoded with its Decode As overrides,
+// together with the annotations of its sidecar file
+// セッションのキャプチャがDecode Asの設定で解析されて、サイドカーファイルの注釈とともに読み込めることをテストします
+func TestSessionOpenCapture(t *testing.T) {
+	dir := t.TempDir()
+	capture := filepath.Join(dir, "capture.pcap")
+	f, err := os.Create(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw, err := NewPcapWriter(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, frame := range [][]byte{
+		decodeStatsTestUDPFrame(40000, 9999, dnsTestMessage(0x1234, false, "example.com", nil, nil)),
+		parseDepthTestFrame(),
+	} {
+		if err := pw.WritePacket(now.Add(time.Duration(i)*time.Millisecond), frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	session := &Session{Name: "reopen", DecodeAs: map[uint16]string{9999: "dns"}, CaptureFile: capture}
+	packets, annotations, err := session.OpenCapture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 2 || packets[0].DNS == nil || packets[1].HTTP == nil {
+		t.Fatalf("OpenCapture() = %+v, want the DNS packet on port 9999 and the HTTP request", packets)
+	}
+	if !packets[1].Timestamp.Equal(now.Add(time.Millisecond)) {
+		t.Errorf("Timestamp = %s, want %s", packets[1].Timestamp, now.Add(time.Millisecond))
+	}
+	if annotations.Len() != 0 {
+		t.Errorf("annotations without a sidecar file = %+v, want none", annotations.List())
+	}
+
+	notes := NewAnnotations()
+	notes.Annotate(2, "this is where it broke")
+	sidecar, err := os.Create(session.AnnotationsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.Save(sidecar); err != nil {
+		t.Fatal(err)
+	}
+	sidecar.Close()
+	if _, annotations, err = session.OpenCapture(); err != nil {
+		t.Fatal(err)
+	}
+	if note, ok := annotations.Note(2); !ok || note != "this is where it broke" {
+		t.Errorf("Note(2) = %q, %t", note, ok)
+	}
+
+	if _, _, err := (&Session{Name: "missing", CaptureFile: filepath.Join(dir, "missing.pcap")}).OpenCapture(); err == nil {
+		t.Error("a missing capture file should be an error")
+	}
+}
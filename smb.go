@@ -0,0 +1,281 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+const (
+	PORT_NETBIOS_SSN = 0x008b // 139
+	PORT_SMB         = 0x01bd // 445
+)
+
+// NetBIOS session service message types
+// ref: https://datatracker.ietf.org/doc/html/rfc1002#section-4.3.1
+// NetBIOSセッションサービスのメッセージタイプ
+const (
+	NETBIOS_SESSION_MESSAGE           = 0x00
+	NETBIOS_SESSION_REQUEST           = 0x81
+	NETBIOS_SESSION_POSITIVE_RESPONSE = 0x82
+	NETBIOS_SESSION_NEGATIVE_RESPONSE = 0x83
+	NETBIOS_SESSION_RETARGET_RESPONSE = 0x84
+	NETBIOS_SESSION_KEEP_ALIVE        = 0x85
+)
+
+// SMB2 commands
+// ref: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/fb188936-5050-48d3-b350-dc43059638a4
+// SMB2のコマンド
+const (
+	SMB2_NEGOTIATE       = 0x0000
+	SMB2_SESSION_SETUP   = 0x0001
+	SMB2_LOGOFF          = 0x0002
+	SMB2_TREE_CONNECT    = 0x0003
+	SMB2_TREE_DISCONNECT = 0x0004
+	SMB2_CREATE          = 0x0005
+	SMB2_CLOSE           = 0x0006
+	SMB2_FLUSH           = 0x0007
+	SMB2_READ            = 0x0008
+	SMB2_WRITE           = 0x0009
+	SMB2_LOCK            = 0x000a
+	SMB2_IOCTL           = 0x000b
+	SMB2_CANCEL          = 0x000c
+	SMB2_ECHO            = 0x000d
+	SMB2_QUERY_DIRECTORY = 0x000e
+	SMB2_CHANGE_NOTIFY   = 0x000f
+	SMB2_QUERY_INFO      = 0x0010
+	SMB2_SET_INFO        = 0x0011
+	SMB2_OPLOCK_BREAK    = 0x0012
+)
+
+var smb2CommandNames = map[uint16]string{
+	SMB2_NEGOTIATE:       "Negotiate",
+	SMB2_SESSION_SETUP:   "Session Setup",
+	SMB2_LOGOFF:          "Logoff",
+	SMB2_TREE_CONNECT:    "Tree Connect",
+	SMB2_TREE_DISCONNECT: "Tree Disconnect",
+	SMB2_CREATE:          "Create",
+	SMB2_CLOSE:           "Close",
+	SMB2_FLUSH:           "Flush",
+	SMB2_READ:            "Read",
+	SMB2_WRITE:           "Write",
+	SMB2_LOCK:            "Lock",
+	SMB2_IOCTL:           "Ioctl",
+	SMB2_CANCEL:          "Cancel",
+	SMB2_ECHO:            "Echo",
+	SMB2_QUERY_DIRECTORY: "Query Directory",
+	SMB2_CHANGE_NOTIFY:   "Change Notify",
+	SMB2_QUERY_INFO:      "Query Info",
+	SMB2_SET_INFO:        "Set Info",
+	SMB2_OPLOCK_BREAK:    "Oplock Break",
+}
+
+// SMB protocol IDs at the start of an SMB message
+// SMBメッセージの先頭のプロトコルID
+var (
+	smb1ProtocolID          = [4]byte{0xff, 'S', 'M', 'B'}
+	smb2ProtocolID          = [4]byte{0xfe, 'S', 'M', 'B'}
+	smb2TransformProtocolID = [4]byte{0xfd, 'S', 'M', 'B'} // SMB3 の暗号化メッセージ
+)
+
+const (
+	smb1HeaderLength          = 32
+	smb2HeaderLength          = 64
+	smb2TransformHeaderLength = 52
+
+	smb1FlagsReply    = 0x80
+	smb2FlagsResponse = 0x00000001
+	smb2FlagsAsync    = 0x00000002
+)
+
+// SMB is the NetBIOS session header and the header of the first SMB message it carries.
+// Only the headers (and the dialects and tree path of SMB2 negotiate and tree connect requests) are decoded.
+// NetBIOSセッションヘッダーと、それに含まれる最初のSMBメッセージのヘッダーです。
+// ヘッダー(とSMB2のNegotiate/Tree Connectのダイアレクトとツリーのパス)のみ解析します
+type SMB struct {
+	SessionType   uint8
+	SessionLength uint32
+
+	// Version is 1 for SMB1 and 2 for SMB2/SMB3. 0 if the session message carries no SMB message (e.g. keep-alive)
+	// SMB1は1、SMB2/SMB3は2。SMBメッセージを含まないセッションメッセージ(keep-aliveなど)の場合は0
+	Version uint8
+	// Encrypted reports an SMB3 transform header. The command and the rest of the header are encrypted
+	// SMB3の暗号化メッセージ。コマンドなどのヘッダーは暗号化されている
+	Encrypted bool
+	Command   uint16 // SMB1 では1バイト
+	Status    uint32 // NTSTATUS
+	Response  bool
+	MessageID uint64 // SMB1 では MID
+	TreeID    uint32 // SMB2 の非同期メッセージでは 0
+	SessionID uint64 // SMB1 では UID
+
+	// Dialects are the dialects offered by an SMB2 negotiate request, or the one chosen by the response
+	// SMB2のNegotiateリクエストで提示されたダイアレクト、またはレスポンスで選ばれたダイアレクト
+	Dialects []uint16
+	// Tree is the share path of an SMB2 tree connect request, e.g. \\server\share
+	// SMB2のTree Connectリクエストの共有パス
+	Tree string
+
+	Payload []byte // SMB ヘッダーより後ろ
+}
+
+// CommandName returns the name of an SMB2 command
+// SMB2のコマンド名を返します
+func (s *SMB) CommandName() string {
+	if s.Version != 2 || s.Encrypted {
+		return ""
+	}
+	if name, ok := smb2CommandNames[s.Command]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", s.Command)
+}
+
+func (s *SMB) String() string {
+	switch {
+	case s.Version == 0:
+		return fmt.Sprintf("NBSS: Type=0x%02x, Len=%d", s.SessionType, s.SessionLength)
+	case s.Version == 1:
+		return fmt.Sprintf("SMB: Command=0x%02x, Response=%v", s.Command, s.Response)
+	case s.Encrypted:
+		return fmt.Sprintf("SMB2: Encrypted, SessionID=0x%016x", s.SessionID)
+	}
+	return fmt.Sprintf("SMB2: Command=%s, Response=%v, TreeID=0x%08x", s.CommandName(), s.Response, s.TreeID)
+}
+
+func isSMBPort(srcPort, dstPort uint16) bool {
+	return srcPort == PORT_SMB || dstPort == PORT_SMB || srcPort == PORT_NETBIOS_SSN || dstPort == PORT_NETBIOS_SSN
+}
+
+// ParsedSMB parses the NetBIOS session header and the SMB header at the start of a TCP payload on port 445 or 139.
+// Segments continuing an earlier message do not start with a session header and are rejected.
+// ポート445または139のTCPペイロードの先頭にあるNetBIOSセッションヘッダーとSMBヘッダーを解析します。
+// 前のメッセージの続きのセグメントはセッションヘッダーで始まらないためエラーになります
+func ParsedSMB(payload []byte) (*SMB, error) {
+	if len(payload) < 4 {
+		return nil, errors.New("netbios session header too short")
+	}
+
+	smb := &SMB{
+		SessionType: payload[0],
+		// 445 では 24 ビットの長さ (MS-SMB2 2.1). 139 の 17 ビットの長さ (RFC 1002 の E ビット付き) も、
+		// フラグの残りの 7 ビットは 0 なので 24 ビットとして読んで同じ値になる
+		SessionLength: uint32(payload[1])<<16 | uint32(payload[2])<<8 | uint32(payload[3]),
+	}
+	switch smb.SessionType {
+	case NETBIOS_SESSION_MESSAGE:
+	case NETBIOS_SESSION_REQUEST, NETBIOS_SESSION_POSITIVE_RESPONSE, NETBIOS_SESSION_NEGATIVE_RESPONSE,
+		NETBIOS_SESSION_RETARGET_RESPONSE, NETBIOS_SESSION_KEEP_ALIVE:
+		smb.Payload = payload[4:]
+		return smb, nil
+	default:
+		return nil, fmt.Errorf("unknown netbios session type: 0x%02x", smb.SessionType)
+	}
+
+	message := payload[4:]
+	if len(message) < 4 {
+		return nil, errors.New("smb protocol id too short")
+	}
+	var err error
+	switch [4]byte(message[:4]) {
+	case smb1ProtocolID:
+		err = smb.parseSMB1(message)
+	case smb2ProtocolID:
+		err = smb.parseSMB2(message)
+	case smb2TransformProtocolID:
+		if len(message) < smb2TransformHeaderLength {
+			return nil, errors.New("smb2 transform header too short")
+		}
+		smb.Version = 2
+		smb.Encrypted = true
+		smb.SessionID = binary.LittleEndian.Uint64(message[44:52])
+		smb.Payload = message[smb2TransformHeaderLength:]
+	default:
+		return nil, fmt.Errorf("unknown smb protocol id: % x", message[:4])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return smb, nil
+}
+
+// SMB1 のヘッダーはリトルエンディアン
+// ref: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-cifs/69a29f73-de0c-45a6-a1aa-8ceeea42217f
+func (s *SMB) parseSMB1(message []byte) error {
+	if len(message) < smb1HeaderLength {
+		return errors.New("smb1 header too short")
+	}
+	s.Version = 1
+	s.Command = uint16(message[4])
+	s.Status = binary.LittleEndian.Uint32(message[5:9])
+	s.Response = message[9]&smb1FlagsReply != 0
+	s.TreeID = uint32(binary.LittleEndian.Uint16(message[24:26]))
+	s.SessionID = uint64(binary.LittleEndian.Uint16(message[28:30]))
+	s.MessageID = uint64(binary.LittleEndian.Uint16(message[30:32]))
+	s.Payload = message[smb1HeaderLength:]
+	return nil
+}
+
+// ref: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/5cd64522-60b3-4f3e-a157-fe66f1228052
+func (s *SMB) parseSMB2(message []byte) error {
+	if len(message) < smb2HeaderLength {
+		return errors.New("smb2 header too short")
+	}
+	if size := binary.LittleEndian.Uint16(message[4:6]); size != smb2HeaderLength {
+		return fmt.Errorf("invalid smb2 header structure size: %d", size)
+	}
+	flags := binary.LittleEndian.Uint32(message[16:20])
+
+	s.Version = 2
+	s.Status = binary.LittleEndian.Uint32(message[8:12])
+	s.Command = binary.LittleEndian.Uint16(message[12:14])
+	s.Response = flags&smb2FlagsResponse != 0
+	s.MessageID = binary.LittleEndian.Uint64(message[24:32])
+	// 非同期メッセージではこの位置は AsyncId になる
+	if flags&smb2FlagsAsync == 0 {
+		s.TreeID = binary.LittleEndian.Uint32(message[36:40])
+	}
+	s.SessionID = binary.LittleEndian.Uint64(message[40:48])
+	s.Payload = message[smb2HeaderLength:]
+
+	// 本文が壊れていてもヘッダーまでは返す
+	switch {
+	case s.Command == SMB2_NEGOTIATE && !s.Response:
+		s.Dialects = smb2NegotiateRequestDialects(s.Payload)
+	case s.Command == SMB2_NEGOTIATE && s.Response && len(s.Payload) >= 6:
+		s.Dialects = []uint16{binary.LittleEndian.Uint16(s.Payload[4:6])}
+	case s.Command == SMB2_TREE_CONNECT && !s.Response:
+		s.Tree = smb2TreeConnectPath(message)
+	}
+	return nil
+}
+
+func smb2NegotiateRequestDialects(body []byte) []uint16 {
+	if len(body) < 36 {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint16(body[2:4]))
+	dialects := make([]uint16, 0, count)
+	for i := 0; i < count && 36+i*2+2 <= len(body); i++ {
+		dialects = append(dialects, binary.LittleEndian.Uint16(body[36+i*2:]))
+	}
+	return dialects
+}
+
+// パスの位置は SMB2 ヘッダーの先頭からのオフセットで、UTF-16LE で書かれている
+func smb2TreeConnectPath(message []byte) string {
+	if len(message) < smb2HeaderLength+8 {
+		return ""
+	}
+	offset := int(binary.LittleEndian.Uint16(message[smb2HeaderLength+4:]))
+	length := int(binary.LittleEndian.Uint16(message[smb2HeaderLength+6:]))
+	if offset < smb2HeaderLength+8 || offset+length > len(message) || length%2 != 0 {
+		return ""
+	}
+	path := make([]uint16, length/2)
+	for i := range path {
+		path[i] = binary.LittleEndian.Uint16(message[offset+i*2:])
+	}
+	return string(utf16.Decode(path))
+}
@@ -0,0 +1,166 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// NetBIOS セッションヘッダーを付けた SMB2 メッセージ
+func smbTestMessage(command uint16, flags uint32, treeID uint32, body []byte) []byte {
+	header := make([]byte, 64)
+	copy(header, []byte{0xfe, 'S', 'M', 'B'})
+	binary.LittleEndian.PutUint16(header[4:6], 64)
+	binary.LittleEndian.PutUint16(header[12:14], command)
+	binary.LittleEndian.PutUint32(header[16:20], flags)
+	binary.LittleEndian.PutUint64(header[24:32], 1)
+	binary.LittleEndian.PutUint32(header[36:40], treeID)
+	binary.LittleEndian.PutUint64(header[40:48], 0x0000040000000005)
+	message := append(header, body...)
+
+	session := []byte{NETBIOS_SESSION_MESSAGE, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(session[2:4], uint16(len(message)))
+	return append(session, message...)
+}
+
+func smbTestNegotiateRequest(dialects ...uint16) []byte {
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:2], 36)
+	binary.LittleEndian.PutUint16(body[2:4], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(body[4:6], 0x0001) // signing enabled
+	for _, dialect := range dialects {
+		body = binary.LittleEndian.AppendUint16(body, dialect)
+	}
+	return smbTestMessage(SMB2_NEGOTIATE, 0, 0, body)
+}
+
+// TestParsedSMBNegotiate tests that an SMB2 negotiate request on port 445 is recognized with its dialects
+// ポート445のSMB2 Negotiateリクエストがダイアレクトとともに認識されることをテストします
+func TestParsedSMBNegotiate(t *testing.T) {
+	passive, err := DecodeFrame(testTCPFrame(PORT_SMB, smbTestNegotiateRequest(0x0202, 0x0210, 0x0300, 0x0302, 0x0311)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	smb := passive.SMB
+	if smb == nil {
+		t.Fatal("Passive.SMB should be set for an SMB2 negotiate request")
+	}
+	if smb.SessionType != NETBIOS_SESSION_MESSAGE || smb.SessionLength != 64+36+10 {
+		t.Errorf("session header = type 0x%02x, length %d, want 0x00, %d", smb.SessionType, smb.SessionLength, 64+36+10)
+	}
+	if smb.Version != 2 || smb.Command != SMB2_NEGOTIATE || smb.Response || smb.Encrypted {
+		t.Errorf("ParsedSMB() = %+v, want an SMB2 negotiate request", smb)
+	}
+	if got := smb.CommandName(); got != "Negotiate" {
+		t.Errorf("CommandName() = %q, want %q", got, "Negotiate")
+	}
+	want := []uint16{0x0202, 0x0210, 0x0300, 0x0302, 0x0311}
+	if len(smb.Dialects) != len(want) {
+		t.Fatalf("Dialects = %04x, want %04x", smb.Dialects, want)
+	}
+	for i := range want {
+		if smb.Dialects[i] != want[i] {
+			t.Errorf("Dialects[%d] = 0x%04x, want 0x%04x", i, smb.Dialects[i], want[i])
+		}
+	}
+}
+
+// TestParsedSMB tests the SMB header fields of other messages and the payloads that are not SMB
+// その他のメッセージのSMBヘッダーと、SMBではないペイロードをテストします
+func TestParsedSMB(t *testing.T) {
+	negotiateResponse := make([]byte, 65)
+	binary.LittleEndian.PutUint16(negotiateResponse[0:2], 65)
+	binary.LittleEndian.PutUint16(negotiateResponse[4:6], 0x0311)
+
+	path := `\\fileserver\share`
+	treeConnect := []byte{0x09, 0x00, 0x00, 0x00, 64 + 8, 0x00, byte(len(path) * 2), 0x00}
+	for _, c := range utf16.Encode([]rune(path)) {
+		treeConnect = binary.LittleEndian.AppendUint16(treeConnect, c)
+	}
+
+	smb1 := make([]byte, 32)
+	copy(smb1, []byte{0xff, 'S', 'M', 'B', 0x72}) // SMB_COM_NEGOTIATE
+	binary.LittleEndian.PutUint16(smb1[24:26], 0xffff)
+	smb1 = append([]byte{NETBIOS_SESSION_MESSAGE, 0x00, 0x00, 0x20}, smb1...)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		want    SMB
+		wantErr bool
+	}{
+		{
+			name:    "negotiate response",
+			payload: smbTestMessage(SMB2_NEGOTIATE, smb2FlagsResponse, 0, negotiateResponse),
+			want:    SMB{Version: 2, Command: SMB2_NEGOTIATE, Response: true, Dialects: []uint16{0x0311}},
+		},
+		{
+			name:    "tree connect",
+			payload: smbTestMessage(SMB2_TREE_CONNECT, 0, 0, treeConnect),
+			want:    SMB{Version: 2, Command: SMB2_TREE_CONNECT, Tree: path},
+		},
+		{
+			name:    "create on a tree",
+			payload: smbTestMessage(SMB2_CREATE, 0, 0x00000007, make([]byte, 57)),
+			want:    SMB{Version: 2, Command: SMB2_CREATE, TreeID: 0x00000007},
+		},
+		{
+			// 非同期メッセージでは TreeID の位置に AsyncId が入る
+			name:    "async response",
+			payload: smbTestMessage(SMB2_CHANGE_NOTIFY, smb2FlagsResponse|smb2FlagsAsync, 0x00000007, nil),
+			want:    SMB{Version: 2, Command: SMB2_CHANGE_NOTIFY, Response: true},
+		},
+		{
+			name:    "smb1",
+			payload: smb1,
+			want:    SMB{Version: 1, Command: 0x72, TreeID: 0xffff},
+		},
+		{
+			name:    "netbios keep-alive",
+			payload: []byte{NETBIOS_SESSION_KEEP_ALIVE, 0x00, 0x00, 0x00},
+			want:    SMB{SessionType: NETBIOS_SESSION_KEEP_ALIVE},
+		},
+		{
+			// 前のメッセージの続き
+			name:    "continuation segment",
+			payload: []byte("continued data of a large read response"),
+			wantErr: true,
+		},
+		{
+			name:    "truncated smb2 header",
+			payload: smbTestNegotiateRequest(0x0311)[:40],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsedSMB(tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParsedSMB() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Version != tt.want.Version || got.Command != tt.want.Command || got.Response != tt.want.Response ||
+				got.TreeID != tt.want.TreeID || got.Tree != tt.want.Tree || got.SessionType != tt.want.SessionType {
+				t.Errorf("ParsedSMB() = %+v, want %+v", got, tt.want)
+			}
+			if len(got.Dialects) != len(tt.want.Dialects) || (len(got.Dialects) > 0 && got.Dialects[0] != tt.want.Dialects[0]) {
+				t.Errorf("Dialects = %04x, want %04x", got.Dialects, tt.want.Dialects)
+			}
+		})
+	}
+
+	// SMB のポートでなければ解析しない
+	passive, err := DecodeFrame(testTCPFrame(8445, smbTestNegotiateRequest(0x0311)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.SMB != nil {
+		t.Errorf("Passive.SMB = %+v, want nil on a port other than 445 and 139", passive.SMB)
+	}
+}
@@ -16,6 +16,9 @@ const (
 	TCP_FLAGS_FIN_ACK     = 0x11
 	TCP_FLAGS_PSH_ACK     = 0x18 // データを上位層へ渡してという信号
 	TCP_FLAGS_FIN_PSH_ACK = 0x19
+	TCP_FLAGS_FIN         = 0x01
+	TCP_FLAGS_RST         = 0x04
+	TCP_FLAGS_RST_ACK     = 0x14
 )
 
 type TCP struct {
@@ -0,0 +1,265 @@
+package packemon
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Confidence of a TCPFingerprintMatch
+// TCPFingerprintMatchの確からしさ
+const (
+	// TCP_FINGERPRINT_CONFIDENCE_HIGH means every field of a signature matched
+	// シグネチャの全てのフィールドが一致した
+	TCP_FINGERPRINT_CONFIDENCE_HIGH = "high"
+	// TCP_FINGERPRINT_CONFIDENCE_LOW means only the initial TTL and the option layout matched, not the window
+	// 初期TTLとオプションの並びのみが一致し、ウィンドウは一致しなかった
+	TCP_FINGERPRINT_CONFIDENCE_LOW = "low"
+)
+
+//go:embed tcp_fingerprints.txt
+var tcpFingerprintDB []byte
+
+// TCPFingerprint is the passive OS fingerprint of a TCP SYN, in the spirit of p0f: the initial TTL, MSS, window size, window scale and option layout.
+// A sender can change any of them, so a match is a best-effort guess.
+// p0fと同様の、TCP SYNによるOSのパッシブフィンガープリントです(初期TTL、MSS、ウィンドウサイズ、ウィンドウスケール、オプションの並び)。
+// 送信元はどれも変更できるため、一致しても推測にすぎません
+type TCPFingerprint struct {
+	InitialTTL  uint8
+	MSS         uint16 // MSS オプションが無ければ 0
+	Window      uint16
+	WindowScale uint8 // ウィンドウスケールオプションが無ければ 0
+	// Options is the option layout, e.g. "mss,sok,ts,nop,ws"
+	// オプションの並び(例: "mss,sok,ts,nop,ws")
+	Options string
+}
+
+// String returns the signature as "initial TTL:MSS:window,window scale:option layout", e.g. "64:1460:mss*44,7:mss,sok,ts,nop,ws".
+// The window is written as a multiple of the MSS when it is one, since many stacks size it that way.
+// シグネチャを"初期TTL:MSS:ウィンドウ,ウィンドウスケール:オプションの並び"の形式で返します。
+// 多くの実装はウィンドウをMSSの倍数にするため、その場合はMSSの倍数で表します
+func (f TCPFingerprint) String() string {
+	window := strconv.Itoa(int(f.Window))
+	if f.MSS > 0 && f.Window%f.MSS == 0 {
+		window = fmt.Sprintf("mss*%d", f.Window/f.MSS)
+	}
+	return fmt.Sprintf("%d:%d:%s,%d:%s", f.InitialTTL, f.MSS, window, f.WindowScale, f.Options)
+}
+
+// NewTCPFingerprint returns the fingerprint of a TCP SYN (without ACK) over IPv4 or IPv6
+// IPv4またはIPv6上のTCP SYN(ACKなし)のフィンガープリントを返します
+func NewTCPFingerprint(p *Passive) (TCPFingerprint, bool) {
+	if p == nil || p.TCP == nil || p.TCP.Flags&(TCP_FLAGS_SYN|TCP_FLAGS_ACK) != TCP_FLAGS_SYN {
+		return TCPFingerprint{}, false
+	}
+	var observed uint8
+	switch {
+	case p.IPv4 != nil:
+		observed = p.IPv4.TTL
+	case p.IPv6 != nil:
+		observed = p.IPv6.HopLimit
+	default:
+		return TCPFingerprint{}, false
+	}
+
+	f := TCPFingerprint{
+		InitialTTL: InitialTTL(observed),
+		Window:     p.TCP.Window,
+	}
+	options := p.TCP.Options
+	names := []string{}
+	for _, kind := range tcpOptionKinds(options) {
+		names = append(names, tcpOptionName(kind))
+	}
+	f.Options = strings.Join(names, ",")
+
+	// MSS と ウィンドウスケールの値を取り出す
+	if data, ok := tcpOption(options, tcpOptionMSS); ok && len(data) == 2 {
+		f.MSS = binary.BigEndian.Uint16(data)
+	}
+	if data, ok := tcpOption(options, tcpOptionWS); ok && len(data) == 1 {
+		f.WindowScale = data[0]
+	}
+	return f, true
+}
+
+func tcpOptionName(kind uint8) string {
+	switch kind {
+	case tcpOptionEOL:
+		return "eol"
+	case tcpOptionNOP:
+		return "nop"
+	case tcpOptionMSS:
+		return "mss"
+	case tcpOptionWS:
+		return "ws"
+	case tcpOptionSACKPerm:
+		return "sok"
+	case tcpOptionSACK:
+		return "sack"
+	case tcpOptionTimestamp:
+		return "ts"
+	}
+	return fmt.Sprintf("?%d", kind)
+}
+
+// TCPFingerprintMatch is the entry of the embedded signature database a fingerprint matched
+// フィンガープリントが一致した、組み込みのシグネチャデータベースのエントリです
+type TCPFingerprintMatch struct {
+	Label      string // 例: "Linux 3.11+"
+	Family     string // OS_FAMILY_*
+	Signature  string // 一致したデータベースのシグネチャ
+	Confidence string // TCP_FINGERPRINT_CONFIDENCE_*
+}
+
+// tcpSignature is a line of tcp_fingerprints.txt. Zero mss, window and scale with the any flag set match any value
+type tcpSignature struct {
+	label      string
+	family     string
+	signature  string
+	initialTTL uint8
+	mss        uint16
+	anyMSS     bool
+	window     uint16
+	windowMSS  uint16 // mss*N の N
+	anyWindow  bool
+	scale      uint8
+	anyScale   bool
+	options    string
+}
+
+var tcpSignatures = sync.OnceValue(func() []tcpSignature {
+	signatures, err := parseTCPSignatures(bytes.NewReader(tcpFingerprintDB))
+	if err != nil {
+		panic("packemon: broken embedded tcp_fingerprints.txt: " + err.Error())
+	}
+	return signatures
+})
+
+func parseTCPSignatures(r io.Reader) ([]tcpSignature, error) {
+	signatures := []tcpSignature{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sig, err := parseTCPSignature(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		signatures = append(signatures, sig)
+	}
+	return signatures, scanner.Err()
+}
+
+func parseTCPSignature(text string) (tcpSignature, error) {
+	columns := strings.Split(text, "|")
+	if len(columns) != 3 {
+		return tcpSignature{}, fmt.Errorf("want label | family | signature: %q", text)
+	}
+	sig := tcpSignature{
+		label:     strings.TrimSpace(columns[0]),
+		family:    strings.TrimSpace(columns[1]),
+		signature: strings.TrimSpace(columns[2]),
+	}
+
+	// オプションの並びにも ":" は含まれないので 4 つに分ける
+	fields := strings.SplitN(sig.signature, ":", 4)
+	if len(fields) != 4 {
+		return tcpSignature{}, fmt.Errorf("want ttl:mss:window,scale:options: %q", sig.signature)
+	}
+	ttl, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return tcpSignature{}, fmt.Errorf("initial ttl: %w", err)
+	}
+	sig.initialTTL = uint8(ttl)
+
+	if sig.anyMSS = fields[1] == "*"; !sig.anyMSS {
+		mss, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil {
+			return tcpSignature{}, fmt.Errorf("mss: %w", err)
+		}
+		sig.mss = uint16(mss)
+	}
+
+	window, scale, ok := strings.Cut(fields[2], ",")
+	if !ok {
+		return tcpSignature{}, fmt.Errorf("want window,scale: %q", fields[2])
+	}
+	switch {
+	case window == "*":
+		sig.anyWindow = true
+	case strings.HasPrefix(window, "mss*"):
+		n, err := strconv.ParseUint(strings.TrimPrefix(window, "mss*"), 10, 16)
+		if err != nil || n == 0 {
+			return tcpSignature{}, fmt.Errorf("window: %q", window)
+		}
+		sig.windowMSS = uint16(n)
+	default:
+		n, err := strconv.ParseUint(window, 10, 16)
+		if err != nil {
+			return tcpSignature{}, fmt.Errorf("window: %w", err)
+		}
+		sig.window = uint16(n)
+	}
+	if sig.anyScale = scale == "*"; !sig.anyScale {
+		n, err := strconv.ParseUint(scale, 10, 8)
+		if err != nil {
+			return tcpSignature{}, fmt.Errorf("window scale: %w", err)
+		}
+		sig.scale = uint8(n)
+	}
+
+	sig.options = fields[3]
+	return sig, nil
+}
+
+// windowMatches reports whether the window size, window scale and MSS match
+func (sig tcpSignature) windowMatches(f TCPFingerprint) bool {
+	if !sig.anyMSS && sig.mss != f.MSS {
+		return false
+	}
+	if !sig.anyScale && sig.scale != f.WindowScale {
+		return false
+	}
+	switch {
+	case sig.anyWindow:
+		return true
+	case sig.windowMSS > 0:
+		return f.MSS > 0 && uint32(f.Window) == uint32(f.MSS)*uint32(sig.windowMSS)
+	default:
+		return sig.window == f.Window
+	}
+}
+
+// MatchTCPFingerprint looks the fingerprint up in the embedded signature database (tcp_fingerprints.txt).
+// A signature matching in every field is TCP_FINGERPRINT_CONFIDENCE_HIGH. Failing that, one with the same initial TTL and option layout
+// is TCP_FINGERPRINT_CONFIDENCE_LOW, e.g. the same OS with a tuned window.
+// 組み込みのシグネチャデータベース(tcp_fingerprints.txt)からフィンガープリントを探します。
+// 全てのフィールドが一致すればTCP_FINGERPRINT_CONFIDENCE_HIGH、初期TTLとオプションの並びのみが一致すればTCP_FINGERPRINT_CONFIDENCE_LOWです
+func MatchTCPFingerprint(f TCPFingerprint) (TCPFingerprintMatch, bool) {
+	var low *tcpSignature
+	signatures := tcpSignatures()
+	for i, sig := range signatures {
+		if sig.initialTTL != f.InitialTTL || sig.options != f.Options {
+			continue
+		}
+		if sig.windowMatches(f) {
+			return TCPFingerprintMatch{Label: sig.label, Family: sig.family, Signature: sig.signature, Confidence: TCP_FINGERPRINT_CONFIDENCE_HIGH}, true
+		}
+		if low == nil {
+			low = &signatures[i]
+		}
+	}
+	if low != nil {
+		return TCPFingerprintMatch{Label: low.label, Family: low.family, Signature: low.signature, Confidence: TCP_FINGERPRINT_CONFIDENCE_LOW}, true
+	}
+	return TCPFingerprintMatch{}, false
+}
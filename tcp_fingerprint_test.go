@@ -0,0 +1,105 @@
+package packemon
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestTCPFingerprint tests that Linux-like and Windows-like SYNs match their signatures, and a tuned window only with low confidence
+// LinuxらしいSYNとWindowsらしいSYNがそれぞれのシグネチャに一致し、ウィンドウを変更したものは低い確からしさでのみ一致することをテストします
+func TestTCPFingerprint(t *testing.T) {
+	// MSS 1460, SACK permitted, Timestamps, NOP, Window scale 7
+	linuxOptions := []byte{0x02, 0x04, 0x05, 0xb4, 0x04, 0x02, 0x08, 0x0a, 0, 0, 0, 1, 0, 0, 0, 0, 0x01, 0x03, 0x03, 0x07}
+	// MSS 1460, NOP, Window scale 8, NOP, NOP, SACK permitted
+	windowsOptions := []byte{0x02, 0x04, 0x05, 0xb4, 0x01, 0x03, 0x03, 0x08, 0x01, 0x01, 0x04, 0x02}
+
+	tests := []struct {
+		name           string
+		ttl            uint8
+		flags          uint8
+		window         uint16
+		options        []byte
+		wantSignature  string
+		wantLabel      string
+		wantFamily     string
+		wantConfidence string
+	}{
+		{
+			name: "linux", ttl: 58, flags: TCP_FLAGS_SYN, window: 64240, options: linuxOptions,
+			wantSignature: "64:1460:mss*44,7:mss,sok,ts,nop,ws",
+			wantLabel:     "Linux 3.11+", wantFamily: OS_FAMILY_LINUX, wantConfidence: TCP_FINGERPRINT_CONFIDENCE_HIGH,
+		},
+		{
+			name: "windows", ttl: 118, flags: TCP_FLAGS_SYN, window: 64240, options: windowsOptions,
+			wantSignature: "128:1460:mss*44,8:mss,nop,ws,nop,nop,sok",
+			wantLabel:     "Windows 10/11", wantFamily: OS_FAMILY_WINDOWS, wantConfidence: TCP_FINGERPRINT_CONFIDENCE_HIGH,
+		},
+		{
+			name: "linux with a tuned window", ttl: 64, flags: TCP_FLAGS_SYN, window: 12345, options: linuxOptions,
+			wantSignature: "64:1460:12345,7:mss,sok,ts,nop,ws",
+			wantLabel:     "Linux 3.11+", wantFamily: OS_FAMILY_LINUX, wantConfidence: TCP_FINGERPRINT_CONFIDENCE_LOW,
+		},
+		{
+			name: "unknown layout", ttl: 64, flags: TCP_FLAGS_SYN, window: 1024, options: []byte{0x02, 0x04, 0x05, 0xb4},
+			wantSignature: "64:1460:1024,0:mss",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Passive{
+				IPv4: &IPv4Packet{TTL: tt.ttl},
+				TCP:  &TCPPacket{Flags: tt.flags, Window: tt.window, Options: tt.options},
+			}
+			fingerprint, ok := NewTCPFingerprint(p)
+			if !ok {
+				t.Fatal("NewTCPFingerprint() should return a fingerprint for a SYN")
+			}
+			if got := fingerprint.String(); got != tt.wantSignature {
+				t.Errorf("signature = %s, want %s", got, tt.wantSignature)
+			}
+
+			match, ok := MatchTCPFingerprint(fingerprint)
+			if ok != (tt.wantLabel != "") || match.Label != tt.wantLabel || match.Family != tt.wantFamily || match.Confidence != tt.wantConfidence {
+				t.Errorf("MatchTCPFingerprint() = %+v, %v, want %s (%s, %s)", match, ok, tt.wantLabel, tt.wantFamily, tt.wantConfidence)
+			}
+
+			// GuessOS にも反映される
+			hint, _ := GuessOS(p)
+			if hint.Signature != tt.wantSignature || hint.Label != tt.wantLabel || hint.Confidence != tt.wantConfidence {
+				t.Errorf("GuessOS() = %+v", hint)
+			}
+		})
+	}
+
+	// SYN-ACK と SYN 以外はフィンガープリントを取らない
+	for _, flags := range []uint8{TCP_FLAGS_SYN | TCP_FLAGS_ACK, TCP_FLAGS_ACK} {
+		if _, ok := NewTCPFingerprint(&Passive{IPv4: &IPv4Packet{TTL: 64}, TCP: &TCPPacket{Flags: flags, Options: linuxOptions}}); ok {
+			t.Errorf("NewTCPFingerprint() should not return a fingerprint for flags 0x%02x", flags)
+		}
+	}
+}
+
+// TestParseTCPSignatures tests the embedded database and that broken lines are reported
+// 組み込みのデータベースと、壊れた行がエラーになることをテストします
+func TestParseTCPSignatures(t *testing.T) {
+	signatures, err := parseTCPSignatures(bytes.NewReader(tcpFingerprintDB))
+	if err != nil {
+		t.Fatalf("embedded database: %v", err)
+	}
+	if len(signatures) == 0 {
+		t.Fatal("embedded database should not be empty")
+	}
+
+	for _, broken := range []string{
+		"Linux | Linux",
+		"Linux | Linux | 64:*:mss*44:mss",
+		"Linux | Linux | 300:*:mss*44,7:mss",
+		"Linux | Linux | 64:*:mss*x,7:mss",
+	} {
+		if _, err := parseTCPSignatures(strings.NewReader(broken)); err == nil {
+			t.Errorf("%q should be an error", broken)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package packemon
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// TCP_FLOWS_IDLE_TIMEOUT is how long a connection without packets is tracked. Connections closed without a captured FIN or RST expire after it
+	// パケットの無いコネクションを追跡し続ける時間です。FINやRSTを観測せずに終わったコネクションはこの時間で破棄します
+	TCP_FLOWS_IDLE_TIMEOUT = 5 * time.Minute
+	// TCP_FLOWS_MAX is the number of connections tracked at once. The one idle for the longest is dropped beyond it
+	// 同時に追跡するコネクション数です。超えた場合は最も長くパケットの無いものから破棄します
+	TCP_FLOWS_MAX = 4096
+)
+
+// TCPFlowStat is the health of a TCP connection seen on the wire
+// 回線上で観測したTCPコネクションの状態です
+type TCPFlowStat struct {
+	// Src is the endpoint that sent the first segment seen, Dst the other one ("ip:port")
+	// 最初に観測したセグメントの送信元と宛先("ip:port")
+	Src string
+	Dst string
+
+	Packets         int
+	Retransmissions int // 既に送られたシーケンス番号のデータを含むセグメント
+	DuplicateACKs   int // 同じ確認応答番号を繰り返すデータなしのACK
+
+	// SrcWindow and DstWindow are the receive windows last advertised by Src and Dst in bytes, scaled by the window scale
+	// negotiated in the SYNs. Without the handshake captured, they are the unscaled Window fields
+	// SrcとDstが最後に通知した受信ウィンドウのバイト数。SYNで合意したウィンドウスケールを適用します。
+	// ハンドシェイクを観測していない場合はスケールを適用しないWindowフィールドの値です
+	SrcWindow uint32
+	DstWindow uint32
+	// SrcThroughput and DstThroughput estimate the goodput of the data sent by Src and Dst in bytes per second,
+	// from how far the other side's ACKs progressed over time. They are 0 until ACKs of captured packets at different times are seen
+	// SrcとDstが送ったデータのグッドプット(バイト毎秒)の推定値。相手のACKが時間とともにどれだけ進んだかから求めます。
+	// 時刻の異なるキャプチャしたパケットのACKを観測するまでは0です
+	SrcThroughput float64
+	DstThroughput float64
+
+	// SrcSACKed and DstSACKed count the segments sent by Src and Dst that the other side acknowledged selectively with SACK blocks:
+	// segments received past a hole, so a lossy path shows up as a growing count. Only segments captured before the SACK are counted
+	// SrcとDstが送ったセグメントのうち、相手がSACKブロックで選択的に確認応答したものの数。穴より後に受信されたセグメントのため、
+	// 損失の多い経路では増え続けます。SACKより前にキャプチャしたセグメントのみ数えます
+	SrcSACKed int
+	DstSACKed int
+
+	// JA3Hash and JA3SHash fingerprint the client and server of a TLS connection, from the ClientHello and ServerHello seen
+	// 観測したClientHelloとServerHelloから求めた、TLSコネクションのクライアントとサーバーのフィンガープリント
+	JA3Hash  string
+	JA3SHash string
+}
+
+// 片方向の状態
+type tcpFlowDirection struct {
+	seen    bool
+	nextSeq uint32 // この方向で観測した最も先のシーケンス番号 + 長さ
+
+	ackSeen bool
+	lastAck uint32
+	lastWin uint16
+
+	// SYN で通知したウィンドウスケール
+	windowScale     uint8
+	windowScaleSeen bool
+
+	// この方向のデータに対する相手の ACK の進み. スループットの推定に使う
+	acked        bool
+	firstAck     uint32
+	firstAckTime time.Time
+	highestAck   uint32
+	lastAckTime  time.Time
+
+	// 相手の SACK ブロックと照らし合わせる、この方向の確認応答されていない直近のセグメント
+	segments []tcpSegment
+}
+
+// 送信したセグメントのシーケンス番号の範囲 [seq, end)
+type tcpSegment struct {
+	seq    uint32
+	end    uint32
+	sacked bool
+}
+
+// 方向ごとに SACK と照らし合わせるために保持するセグメントの数. 超えたら古いものから捨てる
+const tcpFlowMaxSegments = 64
+
+type tcpFlow struct {
+	stat     TCPFlowStat
+	lastSeen time.Time
+	// directions[0] は Src から Dst への方向
+	directions [2]tcpFlowDirection
+	// 両方向の SYN がウィンドウスケールを通知した
+	windowScaling bool
+}
+
+// TCPFlows detects retransmissions and duplicate ACKs per TCP connection, like Wireshark's TCP analysis,
+// and tracks the advertised windows and the throughput of each direction to spot window-limited transfers
+// TCPコネクションごとに再送と重複ACKを検出します(Wireshark のTCP解析相当)。
+// ウィンドウに律速された転送を見つけられるよう、各方向の通知ウィンドウとスループットも追跡します
+type TCPFlows struct {
+	mu       sync.Mutex
+	flows    map[[2]string]*tcpFlow
+	maxFlows int
+	// 最後にアイドルのフローを調べたパケットの時刻
+	lastExpire time.Time
+}
+
+// NewTCPFlows creates an empty TCP flow tracker
+// 空のTCPフロートラッカーを作成します
+func NewTCPFlows() *TCPFlows {
+	return &TCPFlows{
+		flows:    make(map[[2]string]*tcpFlow),
+		maxFlows: TCP_FLOWS_MAX,
+	}
+}
+
+// Update accounts a received packet. Packets without TCP are ignored.
+// 受信したパケットを集計します。TCPを含まないパケットは無視します
+func (f *TCPFlows) Update(p *Passive) {
+	if p == nil || p.TCP == nil {
+		return
+	}
+	var srcIP, dstIP net.IP
+	switch {
+	case p.IPv4 != nil:
+		srcIP, dstIP = p.IPv4.SrcIP, p.IPv4.DstIP
+	case p.IPv6 != nil:
+		srcIP, dstIP = p.IPv6.SrcIP, p.IPv6.DstIP
+	default:
+		return
+	}
+	src := net.JoinHostPort(srcIP.String(), strconv.Itoa(int(p.TCP.SrcPort)))
+	dst := net.JoinHostPort(dstIP.String(), strconv.Itoa(int(p.TCP.DstPort)))
+
+	// 両方向を同じフローとして扱うため、キーは並べ替えておく
+	key := [2]string{src, dst}
+	if dst < src {
+		key = [2]string{dst, src}
+	}
+
+	now := p.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 毎パケット全フローを調べないよう、パケットの時刻で 1 秒ごとにアイドルのフローを捨てる
+	if now.Sub(f.lastExpire) >= time.Second {
+		f.expire(now)
+	}
+	flow, ok := f.flows[key]
+	if !ok {
+		if len(f.flows) >= f.maxFlows {
+			f.evictIdlest()
+		}
+		flow = &tcpFlow{stat: TCPFlowStat{Src: src, Dst: dst}}
+		f.flows[key] = flow
+	}
+	flow.lastSeen = now
+	flow.stat.Packets++
+
+	dir, peer := &flow.directions[0], &flow.directions[1]
+	window := &flow.stat.SrcWindow
+	if src != flow.stat.Src {
+		dir, peer = peer, dir
+		window = &flow.stat.DstWindow
+	}
+	*window = flow.window(dir, p.TCP)
+	// ACK は相手の方向のデータの到達を示す
+	if p.TCP.Flags&TCP_FLAGS_ACK != 0 && p.TCP.Flags&TCP_FLAGS_RST == 0 && !p.Timestamp.IsZero() {
+		peer.updateAcked(p.TCP.AckNum, p.Timestamp)
+	}
+	if p.TCP.Flags&TCP_FLAGS_ACK != 0 && p.TCP.Flags&TCP_FLAGS_RST == 0 {
+		sacked := peer.sack(p.TCP.AckNum, p.TCP.SACKBlocks())
+		if peer == &flow.directions[0] {
+			flow.stat.SrcSACKed += sacked
+		} else {
+			flow.stat.DstSACKed += sacked
+		}
+	}
+	dir.addSegment(p.TCP)
+	if dir.isRetransmission(p.TCP) {
+		flow.stat.Retransmissions++
+	}
+	if dir.isDuplicateACK(p.TCP) {
+		flow.stat.DuplicateACKs++
+	}
+	if tls := p.TLS; tls != nil {
+		if tls.JA3Hash != "" {
+			flow.stat.JA3Hash = tls.JA3Hash
+		}
+		if tls.JA3SHash != "" {
+			flow.stat.JA3SHash = tls.JA3SHash
+		}
+	}
+}
+
+// Stats returns the per-connection statistics sorted by endpoints
+// 送信元と宛先でソートしたコネクションごとの統計を返します
+func (f *TCPFlows) Stats() []TCPFlowStat {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]TCPFlowStat, 0, len(f.flows))
+	for _, flow := range f.flows {
+		stat := flow.stat
+		stat.SrcThroughput = flow.directions[0].throughput()
+		stat.DstThroughput = flow.directions[1].throughput()
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Src != stats[j].Src {
+			return stats[i].Src < stats[j].Src
+		}
+		return stats[i].Dst < stats[j].Dst
+	})
+	return stats
+}
+
+func (f *TCPFlows) expire(now time.Time) {
+	f.lastExpire = now
+	for key, flow := range f.flows {
+		if now.Sub(flow.lastSeen) >= TCP_FLOWS_IDLE_TIMEOUT {
+			delete(f.flows, key)
+		}
+	}
+}
+
+func (f *TCPFlows) evictIdlest() {
+	var idlest [2]string
+	var idlestSeen time.Time
+	first := true
+	for key, flow := range f.flows {
+		if first || flow.lastSeen.Before(idlestSeen) {
+			idlest, idlestSeen, first = key, flow.lastSeen, false
+		}
+	}
+	delete(f.flows, idlest)
+}
+
+// 通知されたウィンドウのバイト数. SYN で通知されたウィンドウスケールを記録し、両方向が通知していれば以降のセグメントに適用する
+func (f *tcpFlow) window(dir *tcpFlowDirection, tcp *TCPPacket) uint32 {
+	if tcp.Flags&TCP_FLAGS_SYN != 0 {
+		dir.windowScale, dir.windowScaleSeen = tcpWindowScale(tcp.Options)
+		f.windowScaling = f.directions[0].windowScaleSeen && f.directions[1].windowScaleSeen
+		// SYN のウィンドウはスケールしない (RFC 7323 2.2)
+		return uint32(tcp.Window)
+	}
+	if !f.windowScaling {
+		return uint32(tcp.Window)
+	}
+	return uint32(tcp.Window) << dir.windowScale
+}
+
+func (d *tcpFlowDirection) updateAcked(ack uint32, at time.Time) {
+	if !d.acked {
+		d.acked = true
+		d.firstAck, d.highestAck = ack, ack
+		d.firstAckTime, d.lastAckTime = at, at
+		return
+	}
+	if seqLess(d.highestAck, ack) {
+		d.highestAck = ack
+		d.lastAckTime = at
+	}
+}
+
+// 最初の ACK から最も進んだ ACK までに確認応答されたバイト数 / 経過時間
+func (d *tcpFlowDirection) throughput() float64 {
+	elapsed := d.lastAckTime.Sub(d.firstAckTime).Seconds()
+	if !d.acked || elapsed <= 0 {
+		return 0
+	}
+	return float64(d.highestAck-d.firstAck) / elapsed
+}
+
+// ウィンドウスケールオプションのシフト数. RFC 7323 の上限 14 に丸める
+func tcpWindowScale(options []byte) (uint8, bool) {
+	data, ok := tcpOption(options, tcpOptionWS)
+	if !ok || len(data) != 1 {
+		return 0, false
+	}
+	return min(data[0], 14), true
+}
+
+// データを含むセグメントを記録する. 再送は記録済みのものと同じ範囲なら追加しない
+func (d *tcpFlowDirection) addSegment(tcp *TCPPacket) {
+	if len(tcp.Payload) == 0 {
+		return
+	}
+	segment := tcpSegment{seq: tcp.SeqNum, end: tcp.SeqNum + uint32(len(tcp.Payload))}
+	for _, s := range d.segments {
+		if s.seq == segment.seq && s.end == segment.end {
+			return
+		}
+	}
+	if len(d.segments) >= tcpFlowMaxSegments {
+		d.segments = d.segments[1:]
+	}
+	d.segments = append(d.segments, segment)
+}
+
+// 相手の ACK で確認応答されたセグメントを捨て、SACK ブロックに含まれるセグメントに印を付ける. 新たに印を付けた数を返す
+func (d *tcpFlowDirection) sack(ack uint32, blocks []SACKBlock) int {
+	kept := d.segments[:0]
+	for _, s := range d.segments {
+		if seqLess(ack, s.end) {
+			kept = append(kept, s)
+		}
+	}
+	d.segments = kept
+
+	sacked := 0
+	for i := range d.segments {
+		s := &d.segments[i]
+		if s.sacked {
+			continue
+		}
+		for _, block := range blocks {
+			if block.Covers(s.seq, s.end-s.seq) {
+				s.sacked = true
+				sacked++
+				break
+			}
+		}
+	}
+	return sacked
+}
+
+func (d *tcpFlowDirection) isRetransmission(tcp *TCPPacket) bool {
+	// SYN と FIN はシーケンス番号を1つ消費する
+	length := uint32(len(tcp.Payload))
+	if tcp.Flags&(TCP_FLAGS_SYN|TCP_FLAGS_FIN) != 0 {
+		length++
+	}
+	end := tcp.SeqNum + length
+
+	if !d.seen {
+		d.seen = true
+		d.nextSeq = end
+		return false
+	}
+	if length == 0 {
+		return false
+	}
+
+	retransmission := seqLess(tcp.SeqNum, d.nextSeq)
+	// keep-alive は送信済みの1バイトを送り直すが、再送ではない
+	if len(tcp.Payload) <= 1 && tcp.SeqNum == d.nextSeq-1 && tcp.Flags&(TCP_FLAGS_SYN|TCP_FLAGS_FIN) == 0 {
+		retransmission = false
+	}
+	if seqLess(d.nextSeq, end) {
+		d.nextSeq = end
+	}
+	return retransmission
+}
+
+func (d *tcpFlowDirection) isDuplicateACK(tcp *TCPPacket) bool {
+	// データや SYN/FIN/RST を含むセグメントは重複ACKとみなさない
+	if tcp.Flags&TCP_FLAGS_ACK == 0 || tcp.Flags&(TCP_FLAGS_SYN|TCP_FLAGS_FIN|TCP_FLAGS_RST) != 0 || len(tcp.Payload) > 0 {
+		d.ackSeen = false
+		return false
+	}
+
+	duplicate := d.ackSeen && tcp.AckNum == d.lastAck && tcp.Window == d.lastWin
+	d.ackSeen = true
+	d.lastAck = tcp.AckNum
+	d.lastWin = tcp.Window
+	return duplicate
+}
+
+// seqLess compares 32-bit sequence numbers taking wraparound into account (RFC 1982)
+// 周回を考慮してシーケンス番号を比較します
+func seqLess(a, b uint32) bool {
+	return int32(a-b) < 0
+}
@@ -0,0 +1,221 @@
+package packemon
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+const (
+	tcpFlowTestClient = "192.168.10.1:50000"
+	tcpFlowTestServer = "192.168.10.2:80"
+)
+
+func tcpFlowTestSegment(fromClient bool, seq, ack uint32, flags uint8, payload []byte) *Passive {
+	client, server := net.ParseIP("192.168.10.1").To4(), net.ParseIP("192.168.10.2").To4()
+	tcp := &TCPPacket{SeqNum: seq, AckNum: ack, Flags: flags, Window: 0xffff, Payload: payload}
+	ipv4 := &IPv4Packet{SrcIP: client, DstIP: server}
+	tcp.SrcPort, tcp.DstPort = 50000, 80
+	if !fromClient {
+		ipv4.SrcIP, ipv4.DstIP = server, client
+		tcp.SrcPort, tcp.DstPort = 80, 50000
+	}
+	return &Passive{IPv4: ipv4, TCP: tcp}
+}
+
+// TestTCPFlows tests that a retransmitted segment and three duplicate ACKs are counted on one flow
+// 再送されたセグメントと3つの重複ACKが1つのフローで数えられることをテストします
+func TestTCPFlows(t *testing.T) {
+	data := []byte("0123456789")
+	segments := []*Passive{
+		tcpFlowTestSegment(true, 1000, 0, TCP_FLAGS_SYN, nil),
+		tcpFlowTestSegment(false, 5000, 1001, TCP_FLAGS_SYN_ACK, nil),
+		tcpFlowTestSegment(true, 1001, 5001, TCP_FLAGS_ACK, nil),
+		tcpFlowTestSegment(true, 1001, 5001, TCP_FLAGS_PSH_ACK, data), // 1001-1010
+		tcpFlowTestSegment(true, 1011, 5001, TCP_FLAGS_PSH_ACK, data), // 1011-1020 (失われる)
+		tcpFlowTestSegment(true, 1021, 5001, TCP_FLAGS_PSH_ACK, data), // 1021-1030
+		tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil),
+		// 1011 が届いていないので同じ ACK が3回繰り返される
+		tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil),
+		tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil),
+		tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil),
+		// 高速再送
+		tcpFlowTestSegment(true, 1011, 5001, TCP_FLAGS_PSH_ACK, data),
+		tcpFlowTestSegment(false, 5001, 1031, TCP_FLAGS_ACK, nil),
+	}
+
+	flows := NewTCPFlows()
+	for _, s := range segments {
+		flows.Update(s)
+	}
+
+	stats := flows.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("len(Stats()) = %d, want 1: %+v", len(stats), stats)
+	}
+	want := TCPFlowStat{
+		Src:             tcpFlowTestClient,
+		Dst:             tcpFlowTestServer,
+		Packets:         len(segments),
+		Retransmissions: 1,
+		DuplicateACKs:   3,
+		SrcWindow:       0xffff,
+		DstWindow:       0xffff,
+	}
+	if stats[0] != want {
+		t.Errorf("Stats()[0] = %+v, want %+v", stats[0], want)
+	}
+}
+
+// TestTCPFlowsWindowAndThroughput tests that the windows are scaled as negotiated in the SYNs and that the throughput
+// of a transfer is computed from the ACKs over time
+// SYNで合意したとおりにウィンドウがスケールされ、転送のスループットが時間とともに進むACKから求められることをテストします
+func TestTCPFlowsWindowAndThroughput(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	segment := func(fromClient bool, seq, ack uint32, flags uint8, window uint16, options []byte, at time.Duration) *Passive {
+		p := tcpFlowTestSegment(fromClient, seq, ack, flags, nil)
+		p.TCP.Window, p.TCP.Options = window, options
+		p.Timestamp = start.Add(at)
+		return p
+	}
+	windowScale := func(shift uint8) []byte {
+		return []byte{tcpOptionNOP, tcpOptionWS, 3, shift}
+	}
+
+	flows := NewTCPFlows()
+	flows.Update(segment(true, 1000, 0, TCP_FLAGS_SYN, 64240, windowScale(7), 0))
+	flows.Update(segment(false, 5000, 1001, TCP_FLAGS_SYN_ACK, 65160, windowScale(2), 0))
+	if got := flows.Stats()[0]; got.SrcWindow != 64240 || got.DstWindow != 65160 {
+		t.Errorf("windows of the SYNs = %d, %d, want them unscaled", got.SrcWindow, got.DstWindow)
+	}
+	// サーバーが 1 秒あたり 100000 バイトを送り、クライアントが ACK する
+	for i := range 5 {
+		seq := 5001 + uint32(i)*100000
+		flows.Update(&Passive{
+			IPv4:      &IPv4Packet{SrcIP: net.ParseIP("192.168.10.2").To4(), DstIP: net.ParseIP("192.168.10.1").To4()},
+			TCP:       &TCPPacket{SrcPort: 80, DstPort: 50000, SeqNum: seq, AckNum: 1001, Flags: TCP_FLAGS_ACK, Window: 509, Payload: make([]byte, 100000)},
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		})
+		flows.Update(segment(true, 1001, seq+100000, TCP_FLAGS_ACK, 501, nil, time.Duration(i+1)*time.Second))
+	}
+
+	got := flows.Stats()[0]
+	if got.SrcWindow != 501<<7 || got.DstWindow != 509<<2 {
+		t.Errorf("SrcWindow, DstWindow = %d, %d, want %d, %d", got.SrcWindow, got.DstWindow, 501<<7, 509<<2)
+	}
+	// クライアントの最初の ACK から 4 秒で 400000 バイト
+	if got.DstThroughput != 100000 {
+		t.Errorf("DstThroughput = %f, want 100000", got.DstThroughput)
+	}
+	if got.SrcThroughput != 0 {
+		t.Errorf("SrcThroughput = %f, want 0 without data from the client", got.SrcThroughput)
+	}
+
+	// 片方の SYN しかウィンドウスケールを通知しなければスケールしない
+	flows = NewTCPFlows()
+	flows.Update(segment(true, 1000, 0, TCP_FLAGS_SYN, 64240, windowScale(7), 0))
+	flows.Update(segment(false, 5000, 1001, TCP_FLAGS_SYN_ACK, 65160, nil, 0))
+	flows.Update(segment(true, 1001, 5001, TCP_FLAGS_ACK, 501, nil, time.Second))
+	if got := flows.Stats()[0]; got.SrcWindow != 501 || got.SrcThroughput != 0 || got.DstThroughput != 0 {
+		t.Errorf("Stats()[0] = %+v, want the window unscaled and no throughput", got)
+	}
+}
+
+// TestTCPFlowsNotRetransmission tests segments that must not be counted as retransmissions or duplicate ACKs
+// 再送や重複ACKとして数えてはいけないセグメントをテストします
+func TestTCPFlowsNotRetransmission(t *testing.T) {
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name     string
+		segments []*Passive
+	}{
+		{
+			// シーケンス番号が 2^32 を跨いでも前進として扱う
+			name: "sequence wraparound",
+			segments: []*Passive{
+				tcpFlowTestSegment(true, 0xfffffff6, 1, TCP_FLAGS_PSH_ACK, data),
+				tcpFlowTestSegment(true, 0x00000000, 1, TCP_FLAGS_PSH_ACK, data),
+				tcpFlowTestSegment(true, 0x0000000a, 1, TCP_FLAGS_PSH_ACK, data),
+			},
+		},
+		{
+			name: "keep-alive",
+			segments: []*Passive{
+				tcpFlowTestSegment(true, 1001, 1, TCP_FLAGS_PSH_ACK, data),
+				tcpFlowTestSegment(true, 1010, 1, TCP_FLAGS_ACK, []byte{0x00}),
+			},
+		},
+		{
+			// データ付きのセグメントは ACK 番号が同じでも重複ACKではない
+			name: "data with the same ack",
+			segments: []*Passive{
+				tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil),
+				tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_PSH_ACK, data),
+				tcpFlowTestSegment(false, 5011, 1011, TCP_FLAGS_PSH_ACK, data),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flows := NewTCPFlows()
+			for _, s := range tt.segments {
+				flows.Update(s)
+			}
+			stats := flows.Stats()
+			if len(stats) != 1 || stats[0].Retransmissions != 0 || stats[0].DuplicateACKs != 0 {
+				t.Errorf("Stats() = %+v, want no retransmissions and duplicate ACKs", stats)
+			}
+		})
+	}
+
+	// 周回後に古いシーケンス番号を送り直した場合は再送
+	flows := NewTCPFlows()
+	flows.Update(tcpFlowTestSegment(true, 0xfffffff6, 1, TCP_FLAGS_PSH_ACK, data))
+	flows.Update(tcpFlowTestSegment(true, 0x00000000, 1, TCP_FLAGS_PSH_ACK, data))
+	flows.Update(tcpFlowTestSegment(true, 0xfffffff6, 1, TCP_FLAGS_PSH_ACK, data))
+	if got := flows.Stats()[0].Retransmissions; got != 1 {
+		t.Errorf("Retransmissions across wraparound = %d, want 1", got)
+	}
+}
+
+// TestTCPFlowsExpiry tests that idle connections expire and that the connection idle for the longest is dropped beyond the limit
+// パケットの無いコネクションが破棄され、上限を超えると最も長くパケットの無いコネクションが破棄されることをテストします
+func TestTCPFlowsExpiry(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	segment := func(srcPort uint16, at time.Duration) *Passive {
+		p := tcpFlowTestSegment(true, 1000, 0, TCP_FLAGS_SYN, nil)
+		p.TCP.SrcPort = srcPort
+		p.Timestamp = base.Add(at)
+		return p
+	}
+
+	flows := NewTCPFlows()
+	flows.Update(segment(50000, 0))
+	flows.Update(segment(50001, TCP_FLOWS_IDLE_TIMEOUT/2))
+	if got := len(flows.Stats()); got != 2 {
+		t.Fatalf("len(Stats()) = %d, want 2", got)
+	}
+	// 50000 だけがアイドルのまま期限を過ぎる
+	flows.Update(segment(50002, TCP_FLOWS_IDLE_TIMEOUT))
+	stats := flows.Stats()
+	if len(stats) != 2 || stats[0].Src != "192.168.10.1:50001" || stats[1].Src != "192.168.10.1:50002" {
+		t.Errorf("Stats() = %+v, want the flows from 50001 and 50002", stats)
+	}
+
+	flows = NewTCPFlows()
+	flows.maxFlows = 2
+	flows.Update(segment(50000, 0))
+	flows.Update(segment(50001, time.Millisecond))
+	flows.Update(segment(50000, 2*time.Millisecond))
+	// 最も長くパケットの無い 50001 が捨てられる
+	flows.Update(segment(50002, 3*time.Millisecond))
+	stats = flows.Stats()
+	if len(stats) != 2 || stats[0].Src != "192.168.10.1:50000" || stats[1].Src != "192.168.10.1:50002" {
+		t.Errorf("Stats() = %+v, want the flows from 50000 and 50002", stats)
+	}
+	if stats[0].Packets != 2 {
+		t.Errorf("Packets of the flow from 50000 = %d, want 2", stats[0].Packets)
+	}
+}
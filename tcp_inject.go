@@ -0,0 +1,112 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// TCPInjection is a segment to inject into a TCP flow observed passively, with the IP header carrying it
+// 受信で観測したTCPフローに挿入するセグメントと、それを運ぶIPヘッダーです
+type TCPInjection struct {
+	IPv4 *IPv4 // IPv4 のフローの場合
+	IPv6 *IPv6 // IPv6 のフローの場合
+	TCP  *TCP
+}
+
+// EtherType returns the EtherType of the Ethernet frame to send Bytes in
+// Bytesを送信するEthernetフレームのEtherTypeを返します
+func (i *TCPInjection) EtherType() uint16 {
+	if i.IPv6 != nil {
+		return ETHER_TYPE_IPv6
+	}
+	return ETHER_TYPE_IPv4
+}
+
+// Bytes returns the IP packet
+// IPパケットを返します
+func (i *TCPInjection) Bytes() []byte {
+	if i.IPv6 != nil {
+		return i.IPv6.Bytes()
+	}
+	return i.IPv4.Bytes()
+}
+
+// NewTCPInjection builds a segment with flags and data that follows observed, the last segment seen in a flow,
+// with the sequence and acknowledgment numbers the receiver expects and the checksums recomputed.
+// If reply is false the segment continues from the sender of observed, otherwise it answers as its receiver.
+// フローで最後に観測したセグメントobservedに続く、flagsとdataを持つセグメントを作成します。
+// シーケンス番号と確認応答番号は受信側が期待する値にし、チェックサムは計算し直します。
+// replyがfalseの場合はobservedの送信元から、trueの場合はobservedの宛先から送る向きになります
+func NewTCPInjection(observed *Passive, reply bool, flags uint8, data []byte) (*TCPInjection, error) {
+	if observed == nil || observed.TCP == nil || (observed.IPv4 == nil && observed.IPv6 == nil) {
+		return nil, errors.New("observed packet is not TCP over IP")
+	}
+	seg := observed.TCP
+	// SYN と FIN はシーケンス番号を 1 つ消費する
+	next := seg.SeqNum + uint32(len(seg.Payload))
+	if seg.Flags&TCP_FLAGS_SYN != 0 {
+		next++
+	}
+	if seg.Flags&TCP_FLAGS_FIN != 0 {
+		next++
+	}
+	acked := seg.Flags&TCP_FLAGS_ACK != 0
+
+	var tcp *TCP
+	if reply {
+		// 受信側が送る次のシーケンス番号は observed の確認応答番号. ACK のない SYN への応答では分からないので RST だけ送れる
+		if !acked && flags&TCP_FLAGS_RST == 0 {
+			return nil, errors.New("the sequence number of the receiver is unknown before it acknowledges")
+		}
+		tcp = newTCP(flags, seg.DstPort, seg.SrcPort, seg.AckNum, next, data)
+		if !acked {
+			tcp.Sequence = 0
+		}
+	} else {
+		if !acked && flags&TCP_FLAGS_ACK != 0 {
+			return nil, errors.New("the acknowledgment number of the sender is unknown before it acknowledges")
+		}
+		tcp = newTCP(flags, seg.SrcPort, seg.DstPort, next, seg.AckNum, data)
+		tcp.Window = seg.Window
+	}
+	if flags&TCP_FLAGS_ACK == 0 {
+		tcp.Acknowledgment = 0
+	}
+
+	injection := &TCPInjection{TCP: tcp}
+	if observed.IPv4 != nil {
+		src, dst := binary.BigEndian.Uint32(observed.IPv4.SrcIP), binary.BigEndian.Uint32(observed.IPv4.DstIP)
+		if reply {
+			src, dst = dst, src
+		}
+		ipv4 := NewIPv4(IPv4_PROTO_TCP, src, dst)
+		tcp.CalculateChecksum(ipv4)
+		ipv4.Data = tcp.Bytes()
+		ipv4.CalculateTotalLength()
+		ipv4.CalculateChecksum()
+		injection.IPv4 = ipv4
+		return injection, nil
+	}
+
+	src, dst := observed.IPv6.SrcIP, observed.IPv6.DstIP
+	if reply {
+		src, dst = dst, src
+	}
+	ipv6 := NewIPv6(IPv6_NEXT_HEADER_TCP, append([]byte{}, src...), append([]byte{}, dst...))
+	tcp.CalculateChecksumForIPv6(ipv6)
+	ipv6.Data = tcp.Bytes()
+	ipv6.PayloadLength = uint16(len(ipv6.Data))
+	injection.IPv6 = ipv6
+	return injection, nil
+}
+
+// NewTCPReset builds a RST that resets the flow of observed. Its sequence number is exactly the one the receiver expects,
+// so it is accepted even by stacks that challenge in-window RSTs (RFC 5961).
+// observedのフローをリセットするRSTを作成します。シーケンス番号は受信側が期待する値そのものなので、
+// ウィンドウ内のRSTにチャレンジACKを返すスタック(RFC 5961)でも受け入れられます
+func NewTCPReset(observed *Passive, reply bool) (*TCPInjection, error) {
+	if observed != nil && observed.TCP != nil && observed.TCP.Flags&TCP_FLAGS_ACK == 0 && !reply {
+		return NewTCPInjection(observed, reply, TCP_FLAGS_RST, nil)
+	}
+	return NewTCPInjection(observed, reply, TCP_FLAGS_RST_ACK, nil)
+}
@@ -0,0 +1,111 @@
+package packemon
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func tcpInjectTestPassive(ipv6 bool, flags uint8, payload []byte) *Passive {
+	passive := &Passive{
+		TCP: &TCPPacket{SrcPort: 40000, DstPort: 80, SeqNum: 1000, AckNum: 5000, Flags: flags, Window: 0x1000, Payload: payload},
+	}
+	if ipv6 {
+		passive.IPv6 = &IPv6Packet{SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")}
+	} else {
+		passive.IPv4 = &IPv4Packet{SrcIP: net.ParseIP("192.168.10.1").To4(), DstIP: net.ParseIP("192.168.10.2").To4()}
+	}
+	return passive
+}
+
+// 送信できる形にしてから解析し直す
+func tcpInjectDecode(t *testing.T, injection *TCPInjection) *Passive {
+	t.Helper()
+	frame := append(make([]byte, 12), byte(injection.EtherType()>>8), byte(injection.EtherType()))
+	passive, err := DecodeFrame(append(frame, injection.Bytes()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passive.TCP == nil {
+		t.Fatal("the injected segment does not decode as TCP")
+	}
+	if report := ValidatePacket(passive); len(report) == 0 || !report.Valid() {
+		t.Errorf("checksums: %s", report)
+	}
+	return passive
+}
+
+// TestNewTCPReset tests deriving a RST in both directions from an observed ACK carrying data, over IPv4 and IPv6
+// データを運ぶ観測したACKから、IPv4とIPv6で両方向のRSTを作成できることをテストします
+func TestNewTCPReset(t *testing.T) {
+	for _, ipv6 := range []bool{false, true} {
+		observed := tcpInjectTestPassive(ipv6, TCP_FLAGS_PSH_ACK, []byte("hello"))
+
+		injection, err := NewTCPReset(observed, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rst := tcpInjectDecode(t, injection)
+		// 応答側からは、観測した確認応答番号をシーケンス番号にし、データの後ろまでを確認応答する
+		if got := rst.TCP; got.SrcPort != 80 || got.DstPort != 40000 || got.SeqNum != 5000 || got.AckNum != 1005 || got.Flags != TCP_FLAGS_RST_ACK {
+			t.Errorf("ipv6=%v reply: %s", ipv6, got)
+		}
+		if src, dst := tcpInjectAddrs(rst); src != "192.168.10.2" && src != "2001:db8::2" || dst != "192.168.10.1" && dst != "2001:db8::1" {
+			t.Errorf("ipv6=%v reply: %s -> %s, want the addresses swapped", ipv6, src, dst)
+		}
+
+		injection, err = NewTCPReset(observed, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rst = tcpInjectDecode(t, injection)
+		if got := rst.TCP; got.SrcPort != 40000 || got.DstPort != 80 || got.SeqNum != 1005 || got.AckNum != 5000 || got.Flags != TCP_FLAGS_RST_ACK {
+			t.Errorf("ipv6=%v same direction: %s", ipv6, got)
+		}
+	}
+}
+
+func tcpInjectAddrs(passive *Passive) (string, string) {
+	if passive.IPv6 != nil {
+		return net.IP(passive.IPv6.SrcIP).String(), net.IP(passive.IPv6.DstIP).String()
+	}
+	return net.IP(passive.IPv4.SrcIP).String(), net.IP(passive.IPv4.DstIP).String()
+}
+
+// TestNewTCPInjection tests a payload following a segment and the numbers derivable from a SYN
+// セグメントに続くデータと、SYNから求められる番号をテストします
+func TestNewTCPInjection(t *testing.T) {
+	observed := tcpInjectTestPassive(false, TCP_FLAGS_FIN_ACK, nil)
+	injection, err := NewTCPInjection(observed, true, TCP_FLAGS_PSH_ACK, []byte("bye"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := tcpInjectDecode(t, injection)
+	// FIN は 1 つ消費する
+	if got := data.TCP; got.SeqNum != 5000 || got.AckNum != 1001 || !bytes.Equal(got.Payload, []byte("bye")) {
+		t.Errorf("data after FIN: %s %q", got, got.Payload)
+	}
+
+	syn := tcpInjectTestPassive(false, TCP_FLAGS_SYN, nil)
+	syn.TCP.AckNum = 0
+	injection, err = NewTCPReset(syn, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tcpInjectDecode(t, injection).TCP; got.SeqNum != 0 || got.AckNum != 1001 || got.Flags != TCP_FLAGS_RST_ACK {
+		t.Errorf("RST to a SYN: %s", got)
+	}
+	injection, err = NewTCPReset(syn, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tcpInjectDecode(t, injection).TCP; got.SeqNum != 1001 || got.AckNum != 0 || got.Flags != TCP_FLAGS_RST {
+		t.Errorf("RST after a SYN: %s", got)
+	}
+	if _, err := NewTCPInjection(syn, true, TCP_FLAGS_ACK, nil); err == nil {
+		t.Error("an ACK answering a SYN has no known sequence number")
+	}
+	if _, err := NewTCPInjection(&Passive{IPv4: observed.IPv4}, true, TCP_FLAGS_RST, nil); err == nil {
+		t.Error("a packet without TCP should be an error")
+	}
+}
@@ -0,0 +1,47 @@
+package packemon
+
+import "encoding/binary"
+
+// TCP_SACK_MAX_BLOCKS is the number of blocks a SACK option can carry in the 40 bytes of TCP options (RFC 2018)
+// TCPオプションの40バイトにSACKオプションが入れられるブロックの数です(RFC 2018)
+const TCP_SACK_MAX_BLOCKS = 4
+
+// SACKBlock is a range of sequence numbers [Left, Right) that the receiver holds beyond the acknowledgment number,
+// i.e. data received after a hole left by a lost segment (RFC 2018)
+// 受信側が確認応答番号より先に保持しているシーケンス番号の範囲 [Left, Right) です。
+// 失われたセグメントの穴より後に受信したデータを表します(RFC 2018)
+type SACKBlock struct {
+	Left  uint32
+	Right uint32
+}
+
+// Len returns the number of bytes in the block
+// ブロックのバイト数を返します
+func (b SACKBlock) Len() uint32 {
+	return b.Right - b.Left
+}
+
+// Covers reports whether the block covers the whole segment [seq, seq+length), taking wraparound into account
+// セグメント [seq, seq+length) 全体がブロックに含まれるかを、シーケンス番号の周回を考慮して返します
+func (b SACKBlock) Covers(seq uint32, length uint32) bool {
+	return !seqLess(seq, b.Left) && !seqLess(b.Right, seq+length)
+}
+
+// SACKBlocks returns the blocks of the SACK option in their order, the block of the most recently received segment first.
+// It returns nil without a SACK option, or with one that is not 1 to TCP_SACK_MAX_BLOCKS blocks long
+// SACKオプションのブロックを並び順のまま返します。先頭は最も最近受信したセグメントのブロックです。
+// SACKオプションが無いか、ブロック数が1からTCP_SACK_MAX_BLOCKSでない場合はnilを返します
+func (t *TCPPacket) SACKBlocks() []SACKBlock {
+	data, ok := tcpOption(t.Options, tcpOptionSACK)
+	if !ok || len(data) == 0 || len(data)%8 != 0 || len(data)/8 > TCP_SACK_MAX_BLOCKS {
+		return nil
+	}
+	blocks := make([]SACKBlock, len(data)/8)
+	for i := range blocks {
+		blocks[i] = SACKBlock{
+			Left:  binary.BigEndian.Uint32(data[i*8 : i*8+4]),
+			Right: binary.BigEndian.Uint32(data[i*8+4 : i*8+8]),
+		}
+	}
+	return blocks
+}
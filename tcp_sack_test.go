@@ -0,0 +1,87 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// SACK オプション(2 つの NOP で 4 バイト境界に揃える)
+func sackOption(blocks ...SACKBlock) []byte {
+	option := []byte{tcpOptionNOP, tcpOptionNOP, tcpOptionSACK, byte(2 + 8*len(blocks))}
+	for _, b := range blocks {
+		option = binary.BigEndian.AppendUint32(option, b.Left)
+		option = binary.BigEndian.AppendUint32(option, b.Right)
+	}
+	return option
+}
+
+func TestSACKBlocks(t *testing.T) {
+	// Linux のように Timestamp オプションの後に 2 つのブロック
+	timestamp := []byte{tcpOptionNOP, tcpOptionNOP, tcpOptionTimestamp, 10, 0, 0, 0, 1, 0, 0, 0, 2}
+	tcp := &TCPPacket{Options: append(timestamp, sackOption(SACKBlock{3001, 4001}, SACKBlock{1001, 2001})...)}
+
+	want := []SACKBlock{{Left: 3001, Right: 4001}, {Left: 1001, Right: 2001}}
+	if got := tcp.SACKBlocks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SACKBlocks() = %v, want %v", got, want)
+	}
+	if got := want[0].Len(); got != 1000 {
+		t.Errorf("Len() = %d, want 1000", got)
+	}
+	if !want[0].Covers(3001, 500) || !want[0].Covers(3501, 500) || want[0].Covers(3501, 501) || want[0].Covers(2901, 200) {
+		t.Errorf("Covers() of %v is wrong", want[0])
+	}
+	// シーケンス番号の周回をまたぐブロック
+	if wrapped := (SACKBlock{Left: 0xfffffc18, Right: 1000}); !wrapped.Covers(0xffffff00, 500) || wrapped.Len() != 2000 {
+		t.Errorf("block across the wraparound = %v, Len() = %d", wrapped, wrapped.Len())
+	}
+
+	for _, options := range [][]byte{
+		nil,
+		timestamp,
+		sackOption(),
+		sackOption(SACKBlock{1, 2}, SACKBlock{3, 4}, SACKBlock{5, 6}, SACKBlock{7, 8}, SACKBlock{9, 10}),
+		{tcpOptionSACK, 9, 0, 0, 0, 1, 0, 0, 0},
+		sackOption(SACKBlock{1, 2})[:8], // 長さよりデータが短い
+	} {
+		if got := (&TCPPacket{Options: options}).SACKBlocks(); got != nil {
+			t.Errorf("SACKBlocks() of options %x = %v, want nil", options, got)
+		}
+	}
+}
+
+// TestTCPFlowsSACK tests that the segments received past a lost one are counted once as selectively acknowledged
+// 失われたセグメントより後に受信されたセグメントが、選択的に確認応答されたものとして1回ずつ数えられることをテストします
+func TestTCPFlowsSACK(t *testing.T) {
+	data := []byte("0123456789")
+	sack := func(s *Passive, blocks ...SACKBlock) *Passive {
+		s.TCP.Options = sackOption(blocks...)
+		return s
+	}
+	segments := []*Passive{
+		tcpFlowTestSegment(true, 1001, 5001, TCP_FLAGS_PSH_ACK, data), // 1001-1010
+		tcpFlowTestSegment(true, 1011, 5001, TCP_FLAGS_PSH_ACK, data), // 1011-1020 (失われる)
+		tcpFlowTestSegment(true, 1021, 5001, TCP_FLAGS_PSH_ACK, data), // 1021-1030
+		sack(tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil), SACKBlock{1021, 1031}),
+		tcpFlowTestSegment(true, 1031, 5001, TCP_FLAGS_PSH_ACK, data), // 1031-1040
+		// 既に印を付けた 1021-1030 は数え直さない
+		sack(tcpFlowTestSegment(false, 5001, 1011, TCP_FLAGS_ACK, nil), SACKBlock{1021, 1041}),
+		tcpFlowTestSegment(true, 1011, 5001, TCP_FLAGS_PSH_ACK, data), // 再送
+		tcpFlowTestSegment(false, 5001, 1041, TCP_FLAGS_ACK, nil),
+	}
+
+	flows := NewTCPFlows()
+	for _, s := range segments {
+		flows.Update(s)
+	}
+	stats := flows.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("len(Stats()) = %d, want 1: %+v", len(stats), stats)
+	}
+	if stats[0].SrcSACKed != 2 || stats[0].DstSACKed != 0 {
+		t.Errorf("SrcSACKed = %d, DstSACKed = %d, want 2, 0", stats[0].SrcSACKed, stats[0].DstSACKed)
+	}
+	if stats[0].Retransmissions != 1 {
+		t.Errorf("Retransmissions = %d, want 1", stats[0].Retransmissions)
+	}
+}
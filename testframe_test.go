@@ -0,0 +1,53 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// 192.168.10.1 -> 192.168.10.2 の IPv4 パケットの Ethernet フレーム。
+// offset と more でフラグメントを表し、ヘッダーのチェックサムは正しい値で埋める
+func testIPv4Frame(protocol uint8, identification uint16, offset int, more bool, payload []byte) []byte {
+	ipv4 := []byte{0x45, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, protocol, 0x00, 0x00, 192, 168, 10, 1, 192, 168, 10, 2}
+	binary.BigEndian.PutUint16(ipv4[2:4], uint16(len(ipv4)+len(payload)))
+	binary.BigEndian.PutUint16(ipv4[4:6], identification)
+	flagsOffset := uint16(offset / 8)
+	if more {
+		flagsOffset |= 0x2000
+	}
+	binary.BigEndian.PutUint16(ipv4[6:8], flagsOffset)
+	binary.BigEndian.PutUint16(ipv4[10:12], calculateInternetChecksum(ipv4))
+
+	frame := []byte{0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3b, 0x08, 0x00}
+	return append(append(frame, ipv4...), payload...)
+}
+
+// 192.168.10.1:50000 -> 192.168.10.2:dstPort の PSH, ACK の TCP フレーム。TCP のチェックサムは0
+func testTCPFrame(dstPort uint16, payload []byte) []byte {
+	tcp := make([]byte, 20)
+	tcp[0], tcp[1] = 0xc3, 0x50 // src 50000
+	binary.BigEndian.PutUint16(tcp[2:4], dstPort)
+	tcp[12] = 0x50 // data offset 5
+	tcp[13] = TCP_FLAGS_PSH_ACK
+	return testIPv4Frame(IPv4_PROTO_TCP, 0, 0, false, append(tcp, payload...))
+}
+
+// フラグメントに分割して使う、ポート53宛の UDP データグラム
+func testFragmentedUDP() []byte {
+	payload := []byte("fragmented dns over ipv6")
+	udp := []byte{0xc3, 0x50, 0x00, 0x35, 0x00, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	return append(udp, payload...)
+}
+
+// frame を解析し、timestamp にキャプチャしたパケットとして返す
+func testDecodeFrameAt(t *testing.T, frame []byte, timestamp time.Time) *Passive {
+	t.Helper()
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passive.Timestamp = timestamp
+	return passive
+}
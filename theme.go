@@ -0,0 +1,197 @@
+package packemon
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Built-in theme names
+// 組み込みのテーマ名
+const (
+	THEME_DARK          = "dark"
+	THEME_LIGHT         = "light"
+	THEME_HIGH_CONTRAST = "high-contrast"
+)
+
+// Semantic roles a theme assigns colors to
+// テーマが色を割り当てる役割
+const (
+	THEME_ROLE_BACKGROUND = "background" // Background of the views / ビューの背景
+	THEME_ROLE_TEXT       = "text"       // Plain text / 通常のテキスト
+	THEME_ROLE_BORDER     = "border"     // Borders of the views / ビューの枠線
+	THEME_ROLE_TITLE      = "title"      // Titles and labels / タイトルとラベル
+	THEME_ROLE_HIGHLIGHT  = "highlight"  // Emphasized values such as addresses / アドレスなど強調する値
+	THEME_ROLE_ACCENT     = "accent"     // Secondary values such as percentages / 割合など補助的な値
+	THEME_ROLE_MUTED      = "muted"      // Less important text / 重要度の低いテキスト
+	THEME_ROLE_ALERT      = "alert"      // Errors and suspicious packets / エラーや疑わしいパケット
+	THEME_ROLE_CHART_BAR  = "chart-bar"  // Bars of the charts / チャートのバー
+	THEME_ROLE_SELECTION  = "selection"  // Background of selected rows / 選択した行の背景
+	THEME_ROLE_CURSOR     = "cursor"     // Background of the row under the cursor / カーソル行の背景
+)
+
+// Colors are color names such as "yellow" or hex codes such as "#ffff00", which TUIs resolve themselves like ColoringRule
+// 色はColoringRuleと同様に色名または16進コードで、TUIがそれぞれ解決します
+var builtinThemes = map[string]map[string]string{
+	THEME_DARK: {
+		THEME_ROLE_BACKGROUND: "black",
+		THEME_ROLE_TEXT:       "white",
+		THEME_ROLE_BORDER:     "white",
+		THEME_ROLE_TITLE:      "yellow",
+		THEME_ROLE_HIGHLIGHT:  "green",
+		THEME_ROLE_ACCENT:     "blue",
+		THEME_ROLE_MUTED:      "gray",
+		THEME_ROLE_ALERT:      "red",
+		THEME_ROLE_CHART_BAR:  "green",
+		THEME_ROLE_SELECTION:  "gray",
+		THEME_ROLE_CURSOR:     "red",
+	},
+	THEME_LIGHT: {
+		THEME_ROLE_BACKGROUND: "white",
+		THEME_ROLE_TEXT:       "black",
+		THEME_ROLE_BORDER:     "gray",
+		THEME_ROLE_TITLE:      "navy",
+		THEME_ROLE_HIGHLIGHT:  "darkgreen",
+		THEME_ROLE_ACCENT:     "blue",
+		THEME_ROLE_MUTED:      "gray",
+		THEME_ROLE_ALERT:      "red",
+		THEME_ROLE_CHART_BAR:  "teal",
+		THEME_ROLE_SELECTION:  "silver",
+		THEME_ROLE_CURSOR:     "lightcoral",
+	},
+	THEME_HIGH_CONTRAST: {
+		THEME_ROLE_BACKGROUND: "black",
+		THEME_ROLE_TEXT:       "white",
+		THEME_ROLE_BORDER:     "white",
+		THEME_ROLE_TITLE:      "yellow",
+		THEME_ROLE_HIGHLIGHT:  "aqua",
+		THEME_ROLE_ACCENT:     "fuchsia",
+		THEME_ROLE_MUTED:      "silver",
+		THEME_ROLE_ALERT:      "red",
+		THEME_ROLE_CHART_BAR:  "lime",
+		THEME_ROLE_SELECTION:  "blue",
+		THEME_ROLE_CURSOR:     "fuchsia",
+	},
+}
+
+// Protocol colors of the built-in themes overriding ProtocolInfo.Color, whose hints are meant for dark backgrounds
+// 組み込みのテーマでProtocolInfo.Colorを上書きするプロトコルの色です。ProtocolInfo.Colorは暗い背景向けのためです
+var builtinThemeProtocols = map[string]map[string]string{
+	THEME_LIGHT: {
+		"ARP":      "darkgoldenrod",
+		"IPv6":     "teal",
+		"ICMPv6":   "purple",
+		"TCP":      "darkcyan",
+		"UDP":      "green",
+		"IP-in-IP": "sienna",
+		"6in4":     "sienna",
+		"DNS":      "olive",
+		"HTTP":     "darkgreen",
+		"GENEVE":   "sienna",
+		"RTP":      "deeppink",
+	},
+}
+
+// Theme maps the semantic roles to colors. A custom theme starts from its Base theme and overrides some of the roles
+// 役割を色に対応付けます。独自のテーマはBaseのテーマから始め、一部の役割の色を上書きします
+type Theme struct {
+	Name   string            `json:"name"`           // Theme name / テーマ名
+	Base   string            `json:"base,omitempty"` // Built-in theme to start from, dark if empty / 元にする組み込みのテーマ。空の場合はdark
+	Colors map[string]string `json:"colors"`         // Role -> color name or hex code / 役割 -> 色名または16進コード
+	// Protocol name -> color name or hex code, overriding ProtocolInfo.Color / プロトコル名 -> 色名または16進コード。ProtocolInfo.Colorを上書きします
+	Protocols map[string]string `json:"protocols,omitempty"`
+}
+
+// ThemeRoles returns the roles a theme assigns colors to
+// テーマが色を割り当てる役割を返します
+func ThemeRoles() []string {
+	return slices.Sorted(maps.Keys(builtinThemes[THEME_DARK]))
+}
+
+// BuiltinThemeNames returns the names of the built-in themes
+// 組み込みのテーマ名を返します
+func BuiltinThemeNames() []string {
+	return slices.Sorted(maps.Keys(builtinThemes))
+}
+
+// BuiltinTheme returns the built-in theme of the name, or dark if there is no such theme
+// 名前の組み込みのテーマを返します。存在しない場合はdarkを返します
+func BuiltinTheme(name string) *Theme {
+	name = strings.ToLower(name)
+	colors, ok := builtinThemes[name]
+	if !ok {
+		name, colors = THEME_DARK, builtinThemes[THEME_DARK]
+	}
+	return &Theme{Name: name, Colors: maps.Clone(colors), Protocols: maps.Clone(builtinThemeProtocols[name])}
+}
+
+// NewTheme resolves a custom theme: roles missing from theme.Colors take the colors of its Base theme
+// 独自のテーマを解決します。theme.Colorsに無い役割はBaseのテーマの色になります
+func NewTheme(theme Theme) (*Theme, error) {
+	if theme.Name == "" {
+		return nil, fmt.Errorf("theme has no name")
+	}
+	base := THEME_DARK
+	if theme.Base != "" {
+		base = strings.ToLower(theme.Base)
+		if _, ok := builtinThemes[base]; !ok {
+			return nil, fmt.Errorf("theme %q: unknown base theme %q", theme.Name, theme.Base)
+		}
+	}
+
+	resolved := BuiltinTheme(base)
+	resolved.Name, resolved.Base = theme.Name, base
+	for role, color := range theme.Colors {
+		if _, ok := resolved.Colors[role]; !ok {
+			return nil, fmt.Errorf("theme %q: unknown role %q", theme.Name, role)
+		}
+		if color == "" {
+			return nil, fmt.Errorf("theme %q: role %q has no color", theme.Name, role)
+		}
+		resolved.Colors[role] = color
+	}
+	for name, color := range theme.Protocols {
+		info, ok := LookupProtocol(name)
+		if !ok {
+			return nil, fmt.Errorf("theme %q: unknown protocol %q", theme.Name, name)
+		}
+		if color == "" {
+			return nil, fmt.Errorf("theme %q: protocol %q has no color", theme.Name, name)
+		}
+		if resolved.Protocols == nil {
+			resolved.Protocols = map[string]string{}
+		}
+		resolved.Protocols[info.Name] = color
+	}
+	return resolved, nil
+}
+
+// Color returns the color of the role. A nil theme uses the dark theme
+// 役割の色を返します。nilのテーマはdarkのテーマを使います
+func (t *Theme) Color(role string) string {
+	if t == nil {
+		return builtinThemes[THEME_DARK][role]
+	}
+	if color, ok := t.Colors[role]; ok {
+		return color
+	}
+	return builtinThemes[THEME_DARK][role]
+}
+
+// ProtocolColor returns the color of the protocol of the name or label: the theme's override, else ProtocolInfo.Color.
+// Unknown protocols take the text color. A nil theme uses the dark theme
+// 名前またはラベルのプロトコルの色を返します。テーマで上書きした色、無ければProtocolInfo.Colorです。
+// 未知のプロトコルはテキストの色になります。nilのテーマはdarkのテーマを使います
+func (t *Theme) ProtocolColor(name string) string {
+	info, ok := LookupProtocol(name)
+	if !ok || info.Color == "" {
+		return t.Color(THEME_ROLE_TEXT)
+	}
+	if t != nil {
+		if color, ok := t.Protocols[info.Name]; ok {
+			return color
+		}
+	}
+	return info.Color
+}
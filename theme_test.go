@@ -0,0 +1,52 @@
+package packemon
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestConfigGetTheme tests loading a custom theme from a config and resolving its role colors, and the fallbacks to built-in themes
+// 設定から独自のテーマを読み込んで役割の色を解決すること、および組み込みのテーマへのフォールバックをテストします
+func TestConfigGetTheme(t *testing.T) {
+	config := &Config{}
+	data := `{"ui": {"theme": "Solarized", "themes": [{"name": "solarized", "base": "light", "colors": {"title": "#b58900", "chart-bar": "#2aa198"}}]}}`
+	if err := json.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	theme, err := config.GetTheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role string
+		want string
+	}{
+		{role: THEME_ROLE_TITLE, want: "#b58900"},
+		{role: THEME_ROLE_CHART_BAR, want: "#2aa198"},
+		{role: THEME_ROLE_BACKGROUND, want: "white"}, // base の light から
+	}
+	for _, tt := range tests {
+		if got := theme.Color(tt.role); got != tt.want {
+			t.Errorf("Color(%q) = %q, want %q", tt.role, got, tt.want)
+		}
+	}
+
+	config.UI.Theme = THEME_HIGH_CONTRAST
+	if theme, err := config.GetTheme(); err != nil || theme.Name != THEME_HIGH_CONTRAST || theme.Color(THEME_ROLE_HIGHLIGHT) != "aqua" {
+		t.Errorf("built-in theme: %+v, %v", theme, err)
+	}
+	config.UI.Theme = "unknown"
+	if theme, err := config.GetTheme(); err != nil || theme.Name != THEME_DARK || theme.Color(THEME_ROLE_TITLE) != "yellow" {
+		t.Errorf("unknown theme: %+v, %v", theme, err)
+	}
+	if got := (*Theme)(nil).Color(THEME_ROLE_ALERT); got != "red" {
+		t.Errorf("nil theme: Color() = %q, want red", got)
+	}
+
+	config.UI.Theme = "typo"
+	config.UI.Themes = []Theme{{Name: "typo", Colors: map[string]string{"titel": "red"}}}
+	if _, err := config.GetTheme(); err == nil {
+		t.Error("unknown role: GetTheme() succeeded")
+	}
+}
@@ -0,0 +1,80 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Timestamps on the wire differ in epoch, resolution and byte order:
+//   - NTP (RFC 5905) counts seconds since 1900-01-01 with a 32 bit binary fraction, in network byte order (big endian)
+//   - pcap records count seconds since 1970-01-01 with micro or nanoseconds, in the byte order of the writing host
+//   - ping echo data holds the sender's struct timeval as is, little endian from x86 and ARM hosts
+//
+// ワイヤー上のタイムスタンプはエポック、分解能、バイトオーダーがそれぞれ異なります:
+//   - NTP(RFC 5905)は1900-01-01からの秒と32ビットの2進小数を、ネットワークバイトオーダー(ビッグエンディアン)で持ちます
+//   - pcapのレコードは1970-01-01からの秒とマイクロ秒またはナノ秒を、書き込んだホストのバイトオーダーで持ちます
+//   - pingのechoのデータは送信元のstruct timevalをそのまま持ち、x86やARMのホストからはリトルエンディアンです
+
+// NTP_UNIX_EPOCH_OFFSET is the number of seconds from the NTP epoch (1900-01-01) to the Unix epoch (1970-01-01)
+// NTPのエポック(1900-01-01)からUnixのエポック(1970-01-01)までの秒数です
+const NTP_UNIX_EPOCH_OFFSET = 2208988800
+
+// ECHO_TIMESTAMP_LENGTH is the length of the timestamp at the start of the echo data
+// echoのデータの先頭のタイムスタンプの長さです
+const ECHO_TIMESTAMP_LENGTH = 8
+
+// NTPTimeToGo converts a 64 bit NTP timestamp, the seconds in the upper 32 bits and the fraction of a second in the lower 32.
+// The seconds wrap in 2036, so values with the top bit clear are taken as the next era (2036-2104), as RFC 4330 suggests
+// 上位32ビットが秒、下位32ビットが秒の小数部の64ビットのNTPタイムスタンプを変換します。
+// 秒は2036年に一周するため、RFC 4330にならい最上位ビットが0の値は次の時代(2036-2104年)とみなします
+func NTPTimeToGo(ntp uint64) time.Time {
+	seconds := int64(ntp >> 32)
+	if seconds&0x80000000 == 0 {
+		seconds += 1 << 32
+	}
+	// 1ns より細かい NTP の分解能を最も近いナノ秒に丸める
+	nanoseconds := ((ntp&0xffffffff)*uint64(time.Second) + 1<<31) >> 32
+	return time.Unix(seconds-NTP_UNIX_EPOCH_OFFSET, int64(nanoseconds)).UTC()
+}
+
+// GoToNTPTime converts t to a 64 bit NTP timestamp, to be written big endian
+// tをビッグエンディアンで書き込む64ビットのNTPタイムスタンプに変換します
+func GoToNTPTime(t time.Time) uint64 {
+	seconds := uint32(t.Unix() + NTP_UNIX_EPOCH_OFFSET)
+	fraction := (uint64(t.Nanosecond())<<32 + uint64(time.Second)/2) / uint64(time.Second)
+	return uint64(seconds)<<32 | fraction
+}
+
+// EchoTimestamp returns the timestamp put at the start of the ICMP and ICMPv6 echo data, like ping:
+// the seconds of t as a little endian 64 bit integer, the tv_sec of a struct timeval on an x86-64 host
+// pingと同じようにICMPとICMPv6のechoのデータの先頭に置くタイムスタンプを返します。
+// tの秒を、x86-64のホストのstruct timevalのtv_secと同じリトルエンディアンの64ビット整数で表します
+func EchoTimestamp(t time.Time) []byte {
+	return binary.LittleEndian.AppendUint64(make([]byte, 0, ECHO_TIMESTAMP_LENGTH), uint64(t.Unix()))
+}
+
+// EchoTimestampToGo reads the timestamp written by EchoTimestamp from the start of echo data.
+// ok is false if the data is too short
+// EchoTimestampで書き込んだタイムスタンプをechoのデータの先頭から読み込みます。データが短すぎる場合okはfalseです
+func EchoTimestampToGo(data []byte) (t time.Time, ok bool) {
+	if len(data) < ECHO_TIMESTAMP_LENGTH {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.LittleEndian.Uint64(data)), 0), true
+}
+
+// pcap のレコードヘッダー先頭の秒と小数部を読む. 小数部はファイルのマジックナンバーによりマイクロ秒かナノ秒
+func pcapTimeToGo(b []byte, order binary.ByteOrder, nanoseconds bool) time.Time {
+	seconds := int64(order.Uint32(b[0:4]))
+	fraction := int64(order.Uint32(b[4:8]))
+	if !nanoseconds {
+		fraction *= int64(time.Microsecond)
+	}
+	return time.Unix(seconds, fraction)
+}
+
+// pcap のレコードヘッダー先頭に秒とマイクロ秒を書く
+func putPcapTime(b []byte, order binary.ByteOrder, t time.Time) {
+	order.PutUint32(b[0:4], uint32(t.Unix()))
+	order.PutUint32(b[4:8], uint32(t.Nanosecond()/int(time.Microsecond)))
+}
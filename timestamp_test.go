@@ -0,0 +1,94 @@
+package packemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestNTPTime(t *testing.T) {
+	tests := []struct {
+		name string
+		ntp  uint64
+		want time.Time
+	}{
+		{"unix epoch", NTP_UNIX_EPOCH_OFFSET << 32, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"half second", 0xe93c7f00_80000000, time.Date(2024, 1, 1, 0, 0, 0, 500000000, time.UTC)},
+		{"quarter second", 0xe93c7f00_40000000, time.Date(2024, 1, 1, 0, 0, 0, 250000000, time.UTC)},
+		// 2036 年に秒が一周した次の時代
+		{"era 1", 0x00000000_00000000, time.Date(2036, 2, 7, 6, 28, 16, 0, time.UTC)},
+		{"last of era 0", 0xffffffff_00000000, time.Date(2036, 2, 7, 6, 28, 15, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NTPTimeToGo(tt.ntp); !got.Equal(tt.want) {
+				t.Errorf("NTPTimeToGo(%#x) = %v, want %v", tt.ntp, got, tt.want)
+			}
+			if got := GoToNTPTime(tt.want); got != tt.ntp {
+				t.Errorf("GoToNTPTime(%v) = %#x, want %#x", tt.want, got, tt.ntp)
+			}
+		})
+	}
+
+	// NTP の分解能は 1ns より細かいので、ナノ秒は往復で変わらない
+	for _, ns := range []int{1, 123456789, 999999999} {
+		want := time.Date(2024, 1, 1, 0, 0, 0, ns, time.UTC)
+		if got := NTPTimeToGo(GoToNTPTime(want)); !got.Equal(want) {
+			t.Errorf("NTPTimeToGo(GoToNTPTime(%v)) = %v", want, got)
+		}
+	}
+
+	// ワイヤー上はビッグエンディアン
+	wire := binary.BigEndian.AppendUint64(nil, GoToNTPTime(time.Date(2024, 1, 1, 0, 0, 0, 500000000, time.UTC)))
+	if want := []byte{0xe9, 0x3c, 0x7f, 0x00, 0x80, 0x00, 0x00, 0x00}; !bytes.Equal(wire, want) {
+		t.Errorf("NTP timestamp on the wire = %x, want %x", wire, want)
+	}
+}
+
+func TestEchoTimestamp(t *testing.T) {
+	now := time.Unix(1704067200, 0)
+	got := EchoTimestamp(now)
+	// リトルエンディアンの tv_sec
+	want := []byte{0x80, 0x00, 0x92, 0x65, 0x00, 0x00, 0x00, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EchoTimestamp() = %x, want %x", got, want)
+	}
+
+	if parsed, ok := EchoTimestampToGo(append(got, 0xde, 0xad)); !ok || !parsed.Equal(now) {
+		t.Errorf("EchoTimestampToGo() = %v, %t, want %v", parsed, ok, now)
+	}
+	if _, ok := EchoTimestampToGo(got[:4]); ok {
+		t.Errorf("EchoTimestampToGo() of 4 bytes is ok, want not")
+	}
+}
+
+func TestPcapTime(t *testing.T) {
+	want := time.Unix(1704067200, 123456000)
+	tests := []struct {
+		name        string
+		order       binary.ByteOrder
+		nanoseconds bool
+		b           []byte
+		want        time.Time
+	}{
+		{"little endian", binary.LittleEndian, false, []byte{0x80, 0x00, 0x92, 0x65, 0x40, 0xe2, 0x01, 0x00}, want},
+		{"big endian", binary.BigEndian, false, []byte{0x65, 0x92, 0x00, 0x80, 0x00, 0x01, 0xe2, 0x40}, want},
+		{"nanoseconds", binary.LittleEndian, true, []byte{0x80, 0x00, 0x92, 0x65, 0x15, 0xcd, 0x5b, 0x07}, time.Unix(1704067200, 123456789)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pcapTimeToGo(tt.b, tt.order, tt.nanoseconds); !got.Equal(tt.want) {
+				t.Errorf("pcapTimeToGo() = %v, want %v", got, tt.want)
+			}
+			if tt.nanoseconds {
+				return
+			}
+			b := make([]byte, 8)
+			putPcapTime(b, tt.order, tt.want)
+			if !bytes.Equal(b, tt.b) {
+				t.Errorf("putPcapTime() = %x, want %x", b, tt.b)
+			}
+		})
+	}
+}
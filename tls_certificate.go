@@ -0,0 +1,93 @@
+package packemon
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+)
+
+const TLS_HANDSHAKE_TYPE_CERTIFICATE = 0x0b
+
+// parseCertificates looks for a Certificate handshake message in the TLS records of data, a TCP payload, and sets Certificates and Certificate.
+// The message may follow the ServerHello in the same record or come in a later record, but must fit in data; a message continued in the next segment is skipped.
+// TLS 1.3 encrypts the message, so only TLS 1.2 and earlier are seen.
+// TCPペイロードdataのTLSレコードからCertificateハンドシェイクメッセージを探し、CertificatesとCertificateを設定します。
+// ServerHelloと同じレコードでも後続のレコードでも構いませんが、data内に収まっている必要があります(次のセグメントに続くものは飛ばします)。
+// TLS 1.3ではメッセージが暗号化されるため、TLS 1.2以前のみが対象です
+func (t *TLSRecord) parseCertificates(data []byte) error {
+	records := NewFieldReader(data, binary.BigEndian)
+	for records.Remaining() >= 5 {
+		typ, _ := records.Read8()
+		version, _ := records.Read16()
+		length, _ := records.Read16()
+		record, err := records.ReadBytes(int(length))
+		if err != nil {
+			// 次のセグメントに続くレコード
+			return nil
+		}
+		if typ != TLS_CONTENT_TYPE_HANDSHAKE || version>>8 != 0x03 {
+			continue
+		}
+
+		// 1 つのレコードに複数のハンドシェイクメッセージが入ることがある
+		messages := NewFieldReader(record, binary.BigEndian)
+		for messages.Remaining() >= 4 {
+			messageType, _ := messages.Read8()
+			messageLength, _ := readTLSUint24(messages)
+			message, err := messages.ReadBytes(messageLength)
+			if err != nil {
+				break
+			}
+			if messageType == TLS_HANDSHAKE_TYPE_CERTIFICATE {
+				return t.setCertificates(message)
+			}
+		}
+	}
+	return nil
+}
+
+// setCertificates parses the body of a Certificate message: a 24-bit length of the chain, then each certificate as a 24-bit length and DER (RFC 5246 section 7.4.2)
+// Certificateメッセージの本体を解析します。証明書チェーンの24ビットの長さに続き、各証明書の24ビットの長さとDERが並びます(RFC 5246 7.4.2章)
+func (t *TLSRecord) setCertificates(message []byte) error {
+	r := NewFieldReader(message, binary.BigEndian)
+	chainLength, err := readTLSUint24(r)
+	if err != nil {
+		return fmt.Errorf("tls certificate message too short: %w", err)
+	}
+	if chainLength != r.Remaining() {
+		return fmt.Errorf("tls certificate chain length %d does not match the %d bytes of the message", chainLength, r.Remaining())
+	}
+
+	certificates := [][]byte{}
+	for r.Remaining() > 0 {
+		length, err := readTLSUint24(r)
+		if err != nil {
+			return fmt.Errorf("tls certificate length: %w", err)
+		}
+		der, err := r.ReadBytes(length)
+		if err != nil {
+			return fmt.Errorf("tls certificate %d exceeds the chain: %w", len(certificates), err)
+		}
+		certificates = append(certificates, der)
+	}
+	// 証明書を持たないクライアントは空のチェーンを送る
+	if len(certificates) == 0 {
+		return nil
+	}
+
+	t.Certificates = certificates
+	leaf, err := x509.ParseCertificate(certificates[0])
+	if err != nil {
+		return fmt.Errorf("tls leaf certificate: %w", err)
+	}
+	t.Certificate = leaf
+	return nil
+}
+
+func readTLSUint24(r *FieldReader) (int, error) {
+	b, err := r.ReadBytes(3)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2]), nil
+}
@@ -0,0 +1,107 @@
+package packemon
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func tlsCertificateTestDER(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		Issuer:       pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// 24 ビットの長さを前に付ける
+func tlsCertificateTestUint24(b []byte) []byte {
+	return append([]byte{byte(len(b) >> 16), byte(len(b) >> 8), byte(len(b))}, b...)
+}
+
+// TestTLSCertificate tests that the leaf certificate is extracted and parsed from a Certificate message following a ServerHello in the same record
+// 同じレコードでServerHelloに続くCertificateメッセージから、リーフ証明書が取り出され解析されることをテストします
+func TestTLSCertificate(t *testing.T) {
+	der := tlsCertificateTestDER(t)
+
+	serverHello := []byte{0x03, 0x03}
+	serverHello = append(serverHello, make([]byte, 32)...)
+	serverHello = append(serverHello, 0x00, 0x00, 47, COMPRESSION_METHOD_NULL)
+	serverHello = append(serverHello, ja3TestExtensions(ja3TestExtension(65281, []byte{0x00}))...)
+	certificate := tlsCertificateTestUint24(tlsCertificateTestUint24(der))
+
+	// ServerHello の後ろに Certificate メッセージを続けて 1 つのレコードにする
+	record := ja3TestRecord(TLS_HANDSHAKE_TYPE_SERVER_HELLO, serverHello)
+	record = append(record, TLS_HANDSHAKE_TYPE_CERTIFICATE)
+	record = append(record, tlsCertificateTestUint24(certificate)...)
+	binary.BigEndian.PutUint16(record[3:5], uint16(len(record)-5))
+
+	tcp := []byte{0x01, 0xbb, 0xc3, 0x50, 0, 0, 0, 1, 0, 0, 0, 0, 0x50, TCP_FLAGS_PSH_ACK, 0xff, 0xff, 0, 0, 0, 0}
+	passive, err := DecodeFrame(ipTunnelTestFrame(IPv4_PROTO_TCP, append(tcp, record...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls := passive.TLS
+	if tls == nil {
+		t.Fatal("TLS should be decoded")
+	}
+	if tls.JA3S == "" {
+		t.Error("the ServerHello should still be fingerprinted")
+	}
+	if len(tls.Certificates) != 1 || !bytes.Equal(tls.Certificates[0], der) {
+		t.Fatalf("Certificates = %d certificates, want the one in the message", len(tls.Certificates))
+	}
+	if tls.Certificate == nil {
+		t.Fatal("the leaf certificate should be parsed")
+	}
+	if tls.Certificate.Subject.CommonName != "example.com" || !tls.Certificate.NotAfter.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Certificate subject %s, not after %s", tls.Certificate.Subject, tls.Certificate.NotAfter)
+	}
+	if got := NewPassiveJSON(passive).TLS.Certificate; got == nil || got.Subject != "CN=example.com" || got.NotAfter != "2026-01-01T00:00:00Z" {
+		t.Errorf("TLSJSON.Certificate = %+v", got)
+	}
+}
+
+// TestTLSCertificateBrokenChain tests that chain lengths disagreeing with the message are rejected instead of read past
+// メッセージと食い違う証明書チェーンの長さは、範囲外を読まずにエラーになることをテストします
+func TestTLSCertificateBrokenChain(t *testing.T) {
+	der := tlsCertificateTestDER(t)
+	entry := tlsCertificateTestUint24(der)
+
+	for name, message := range map[string][]byte{
+		"chain longer than the message":     append([]byte{0x00, byte(len(entry)>>8) + 1, byte(len(entry))}, entry...),
+		"certificate longer than the chain": tlsCertificateTestUint24(append([]byte{0x00, 0xff, 0xff}, der...)),
+		"chain length cut off":              {0x00, 0x01},
+		"certificate length cut off":        tlsCertificateTestUint24([]byte{0x00, 0x01}),
+	} {
+		tls := &TLSRecord{}
+		if err := tls.setCertificates(message); err == nil || tls.Certificates != nil {
+			t.Errorf("%s: err = %v, %d certificates, want an error", name, err, len(tls.Certificates))
+		}
+	}
+
+	// 空のチェーンはエラーではない
+	tls := &TLSRecord{}
+	if err := tls.setCertificates([]byte{0x00, 0x00, 0x00}); err != nil || tls.Certificates != nil {
+		t.Errorf("empty chain: err = %v, Certificates = %v", err, tls.Certificates)
+	}
+}
@@ -0,0 +1,130 @@
+package packemon
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Traffic classes of the default profiles
+// デフォルトのプロファイルのトラフィッククラス
+const (
+	TRAFFIC_CLASS_VOIP        = "voip"
+	TRAFFIC_CLASS_VIDEO       = "video"
+	TRAFFIC_CLASS_INTERACTIVE = "interactive"
+	TRAFFIC_CLASS_BULK        = "bulk"
+
+	// TRAFFIC_CLASS_BEST_EFFORT is the class of packets matching no profile
+	// どのプロファイルにも一致しないパケットのクラスです
+	TRAFFIC_CLASS_BEST_EFFORT = "best-effort"
+)
+
+// TrafficClassProfile maps packets to a traffic class by DSCP, port and protocol.
+// Every criterion set must match, and an empty one matches any packet. Ports match either the source or the destination port of TCP or UDP
+// DSCP、ポート、プロトコルでパケットをトラフィッククラスに対応付けます。
+// 設定したすべての条件に一致する必要があり、空の条件はどのパケットにも一致します。PortsはTCPまたはUDPの送信元と宛先のどちらかのポートに一致します
+type TrafficClassProfile struct {
+	Class    string   `json:"class"`              // Traffic class, e.g. "voip" / トラフィッククラス
+	DSCP     []DSCP   `json:"dscp,omitempty"`     // DSCP names or values, e.g. ["EF"] / DSCPの名前または値
+	Ports    []uint16 `json:"ports,omitempty"`    // TCP/UDP ports / TCP/UDPのポート
+	Protocol string   `json:"protocol,omitempty"` // Protocol name of the display filter, e.g. "rtp" / ディスプレイフィルタのプロトコル名
+}
+
+// DefaultTrafficClassProfiles returns the built-in profiles. DSCP markings follow RFC 4594, and unmarked traffic falls back to well-known ports
+// 組み込みのプロファイルを返します。DSCPのマークはRFC 4594に従い、マークの無いトラフィックはウェルノウンポートで分類します
+func DefaultTrafficClassProfiles() []TrafficClassProfile {
+	return []TrafficClassProfile{
+		{Class: TRAFFIC_CLASS_VOIP, DSCP: []DSCP{DSCP_EF, DSCP_VOICE_ADMIT, DSCP_CS5}},
+		{Class: TRAFFIC_CLASS_VIDEO, DSCP: []DSCP{DSCP_CS3, DSCP_CS4, DSCP_AF31, DSCP_AF32, DSCP_AF33, DSCP_AF41, DSCP_AF42, DSCP_AF43}},
+		{Class: TRAFFIC_CLASS_INTERACTIVE, DSCP: []DSCP{DSCP_CS2, DSCP_AF21, DSCP_AF22, DSCP_AF23}},
+		{Class: TRAFFIC_CLASS_BULK, DSCP: []DSCP{DSCP_LE, DSCP_CS1, DSCP_AF11, DSCP_AF12, DSCP_AF13}},
+
+		{Class: TRAFFIC_CLASS_VOIP, Protocol: "rtp"},
+		{Class: TRAFFIC_CLASS_VOIP, Ports: []uint16{5060, 5061}},                // SIP
+		{Class: TRAFFIC_CLASS_VIDEO, Ports: []uint16{554, 1935}},                // RTSP, RTMP
+		{Class: TRAFFIC_CLASS_INTERACTIVE, Ports: []uint16{22, 23, 3389, 5900}}, // SSH, Telnet, RDP, VNC
+		{Class: TRAFFIC_CLASS_BULK, Ports: []uint16{20, 21, 445, 873}},          // FTP, SMB, rsync
+	}
+}
+
+// TrafficClassifier is an ordered list of traffic class profiles. The first matching profile wins
+// トラフィッククラスのプロファイルの一覧です。最初に一致したプロファイルが使われます
+type TrafficClassifier struct {
+	profiles []TrafficClassProfile
+}
+
+// NewTrafficClassifier validates the profiles and keeps them in order
+// プロファイルを検証し、順番に保持します
+func NewTrafficClassifier(profiles []TrafficClassProfile) (*TrafficClassifier, error) {
+	c := &TrafficClassifier{
+		profiles: make([]TrafficClassProfile, 0, len(profiles)),
+	}
+	for i, profile := range profiles {
+		if profile.Class == "" {
+			return nil, fmt.Errorf("traffic class profile %d has no class", i)
+		}
+		profile.Protocol = strings.ToLower(profile.Protocol)
+		if _, ok := displayFilterProtocols[profile.Protocol]; profile.Protocol != "" && !ok {
+			return nil, fmt.Errorf("traffic class profile %q: unknown protocol %q", profile.Class, profile.Protocol)
+		}
+		c.profiles = append(c.profiles, profile)
+	}
+	return c, nil
+}
+
+// Classify returns the class of the first profile matching the packet, or TRAFFIC_CLASS_BEST_EFFORT if none matches
+// パケットに最初に一致したプロファイルのクラスを返します。一致しなければTRAFFIC_CLASS_BEST_EFFORTを返します
+func (c *TrafficClassifier) Classify(passive *Passive) string {
+	if c == nil {
+		return TRAFFIC_CLASS_BEST_EFFORT
+	}
+	for _, profile := range c.profiles {
+		if profile.match(passive) {
+			return profile.Class
+		}
+	}
+	return TRAFFIC_CLASS_BEST_EFFORT
+}
+
+// Profiles returns the profiles in evaluation order
+// 評価順のプロファイルを返します
+func (c *TrafficClassifier) Profiles() []TrafficClassProfile {
+	return slices.Clone(c.profiles)
+}
+
+func (profile TrafficClassProfile) match(passive *Passive) bool {
+	if len(profile.DSCP) > 0 {
+		var dscp DSCP
+		switch {
+		case passive.IPv4 != nil:
+			dscp = passive.IPv4.DSCP()
+		case passive.IPv6 != nil:
+			dscp = passive.IPv6.DSCP()
+		default:
+			return false
+		}
+		if !slices.Contains(profile.DSCP, dscp) {
+			return false
+		}
+	}
+
+	if len(profile.Ports) > 0 {
+		var src, dst uint16
+		switch {
+		case passive.TCP != nil:
+			src, dst = passive.TCP.SrcPort, passive.TCP.DstPort
+		case passive.UDP != nil:
+			src, dst = passive.UDP.SrcPort, passive.UDP.DstPort
+		default:
+			return false
+		}
+		if !slices.Contains(profile.Ports, src) && !slices.Contains(profile.Ports, dst) {
+			return false
+		}
+	}
+
+	if profile.Protocol != "" && !displayFilterProtocols[profile.Protocol](passive) {
+		return false
+	}
+	return true
+}
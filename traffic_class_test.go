@@ -0,0 +1,77 @@
+package packemon
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestTrafficClassifier tests classifying an RTP-like UDP flow as VoIP with and without a DSCP marking, and profiles loaded from a config
+// DSCPのマークの有無にかかわらずRTPのようなUDPフローがVoIPに分類されること、および設定から読み込んだプロファイルをテストします
+func TestTrafficClassifier(t *testing.T) {
+	classifier, err := NewTrafficClassifier(DefaultTrafficClassProfiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		passive *Passive
+		want    string
+	}{
+		{
+			name:    "EF-marked RTP-like UDP flow",
+			passive: &Passive{IPv4: &IPv4Packet{TOS: 0xb8}, UDP: &UDPPacket{SrcPort: 16384, DstPort: 16386}},
+			want:    TRAFFIC_CLASS_VOIP,
+		},
+		{
+			name:    "unmarked UDP flow decoded as RTP",
+			passive: &Passive{IPv6: &IPv6Packet{}, UDP: &UDPPacket{SrcPort: 16384, DstPort: 16386}, RTP: &RTP{}},
+			want:    TRAFFIC_CLASS_VOIP,
+		},
+		{
+			name:    "SSH",
+			passive: &Passive{IPv4: &IPv4Packet{}, TCP: &TCPPacket{SrcPort: 51000, DstPort: 22}},
+			want:    TRAFFIC_CLASS_INTERACTIVE,
+		},
+		{
+			name:    "AF11 marking wins over the port",
+			passive: &Passive{IPv4: &IPv4Packet{TOS: 0x28}, TCP: &TCPPacket{SrcPort: 51000, DstPort: 22}},
+			want:    TRAFFIC_CLASS_BULK,
+		},
+		{
+			name:    "unmarked HTTPS",
+			passive: &Passive{IPv4: &IPv4Packet{}, TCP: &TCPPacket{SrcPort: 51000, DstPort: 443}},
+			want:    TRAFFIC_CLASS_BEST_EFFORT,
+		},
+		{
+			name:    "ARP",
+			passive: &Passive{ARP: &ARPPacket{}},
+			want:    TRAFFIC_CLASS_BEST_EFFORT,
+		},
+	}
+	for _, tt := range tests {
+		if got := classifier.Classify(tt.passive); got != tt.want {
+			t.Errorf("%s: Classify() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	config := &Config{}
+	if err := json.Unmarshal([]byte(`{"trafficClasses": [{"class": "gaming", "dscp": ["cs4", "40"], "ports": [3074], "protocol": "UDP"}]}`), config); err != nil {
+		t.Fatal(err)
+	}
+	classifier, err = config.GetTrafficClassifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gaming := &Passive{IPv4: &IPv4Packet{TOS: 0xa0}, UDP: &UDPPacket{SrcPort: 3074, DstPort: 3074}}
+	if got := classifier.Classify(gaming); got != "gaming" {
+		t.Errorf("configured profile: Classify() = %s, want gaming", got)
+	}
+	if data, err := json.Marshal(classifier.Profiles()[0].DSCP); err != nil || string(data) != `["CS4","CS5"]` {
+		t.Errorf("json.Marshal(DSCP) = %s, %v", data, err)
+	}
+
+	if _, err := NewTrafficClassifier([]TrafficClassProfile{{Class: "other", Protocol: "quic"}}); err == nil {
+		t.Error("unknown protocol: NewTrafficClassifier() succeeded")
+	}
+}
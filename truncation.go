@@ -0,0 +1,106 @@
+package packemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// DEFAULT_SNAPLEN is the largest frame the capture reads. Frames longer than the snap length are truncated and marked as such
+// キャプチャで読み込むフレームの最大長です。スナップ長を超えるフレームは切り詰められ、その旨が記録されます
+const DEFAULT_SNAPLEN = 65535
+
+// DecodeFrameWithWireLength decodes a captured frame whose length on the wire was wireLength, e.g. a pcap record with its original length.
+// Layers cut off by the capture are parsed as far as possible and listed in Passive.PartialLayers.
+// 回線上の長さがwireLengthだったキャプチャ済みフレームをデコードします(pcapレコードの元の長さなど)
+func DecodeFrameWithWireLength(data []byte, wireLength int) (*Passive, error) {
+	return decodeFrame(data, wireLength, nil, PARSE_DEPTH_FULL)
+}
+
+func decodeFrame(data []byte, wireLength int, decodeAs *DecodeAsTable, depth ParseDepth) (*Passive, error) {
+	if len(data) <= 14 {
+		return nil, errors.New("too short for an ethernet frame")
+	}
+	if wireLength < len(data) {
+		wireLength = len(data)
+	}
+
+	passive := &Passive{
+		EthernetFrame: &EthernetFrame{
+			DstAddr: data[0:6],
+			SrcAddr: data[6:12],
+			Type:    binary.BigEndian.Uint16(data[12:14]),
+			Payload: data[14:],
+		},
+		Raw:        data,
+		RawLength:  len(data),
+		WireLength: wireLength,
+		Truncated:  wireLength > len(data),
+	}
+	parseEthernetPayload(passive, decodeAs, depth)
+	return passive, nil
+}
+
+// 宣言された長さよりデータが短いレイヤを記録する
+func (p *Passive) markPartial(layer string) {
+	p.Truncated = true
+	p.PartialLayers = append(p.PartialLayers, layer)
+}
+
+// SetSnapLen limits how many bytes of each frame are captured, like tcpdump -s, to save memory or keep payloads out of the capture.
+// Frames longer than it are marked as Truncated. Zero or less uses DEFAULT_SNAPLEN, i.e. whole frames.
+// On Linux only that many bytes are read from the socket. On macOS it is the pcap snap length, which can only be changed while not receiving.
+// 各フレームの何バイトまでをキャプチャするかを設定します(tcpdump -s 相当)。超えたフレームはTruncatedになります。0以下の場合はDEFAULT_SNAPLEN(フレーム全体)です。
+// Linuxではソケットからその長さだけ読み込み、macOSではpcapのスナップ長で、macOSでは受信中は変更できません
+func (nwif *NetworkInterface) SetSnapLen(snapLen int) error {
+	if snapLen <= 0 || snapLen > DEFAULT_SNAPLEN {
+		snapLen = DEFAULT_SNAPLEN
+	}
+	if !nwif.offline {
+		if err := nwif.setSnapLenPlatform(snapLen); err != nil {
+			return err
+		}
+	}
+	nwif.snapLen.Store(int32(snapLen))
+	return nil
+}
+
+// SnapLen returns the current snap length
+// 現在のスナップ長を返します
+func (nwif *NetworkInterface) SnapLen() int {
+	if snapLen := nwif.snapLen.Load(); snapLen > 0 {
+		return int(snapLen)
+	}
+	return DEFAULT_SNAPLEN
+}
+
+// 受信したフレームをスナップ長で切り詰め、リンクタイプに応じて FCS を取り除いてデコードし、受信時刻とインターフェース名を記録する.
+// キャプチャ側でも切り詰めているが、受信中に短くした場合に備える
+func (nwif *NetworkInterface) decodeCapturedFrame(data []byte, wireLength int, timestamp time.Time) (*Passive, error) {
+	if snapLen := nwif.SnapLen(); len(data) > snapLen {
+		data = data[:snapLen]
+	}
+	var passive *Passive
+	var err error
+	switch linkType := nwif.LinkType(); linkType {
+	case PCAP_LINKTYPE_IEEE802_11, PCAP_LINKTYPE_IEEE802_11_RADIOTAP:
+		// 802.11 の FCS は radiotap のフラグで分かる
+		passive, err = decodeIEEE80211Frame(data, wireLength, linkType, &nwif.decodeAs, nwif.ParseDepth())
+	default:
+		var fcs []byte
+		var fcsStatus FCSStatus
+		data, wireLength, fcs, fcsStatus = stripFCS(data, wireLength, nwif.FCSMode())
+		passive, err = decodeFrame(data, wireLength, &nwif.decodeAs, nwif.ParseDepth())
+		if err == nil {
+			passive.FCS, passive.FCSStatus = fcs, fcsStatus
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	passive.Timestamp = timestamp
+	if nwif.Intf != nil {
+		passive.Interface = nwif.Intf.Name
+	}
+	return passive, nil
+}
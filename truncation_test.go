@@ -0,0 +1,120 @@
+package packemon
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestDecodeFrameWithWireLength tests that a frame cut by the snap length is parsed as far as possible and marked truncated
+// スナップ長で切り詰められたフレームが可能な範囲で解析され、切り詰めが記録されることをテストします
+func TestDecodeFrameWithWireLength(t *testing.T) {
+	frame := parseDepthTestFrame()
+	wireLength := len(frame)
+
+	// Ethernet(14) + IPv4(20) + TCP の途中(10) まで
+	passive, err := DecodeFrameWithWireLength(frame[:44], wireLength)
+	if err != nil {
+		t.Fatalf("DecodeFrameWithWireLength returned error: %v", err)
+	}
+	if !passive.Truncated || passive.RawLength != 44 || passive.WireLength != wireLength {
+		t.Errorf("Truncated = %v, RawLength = %d, WireLength = %d, want true, 44, %d", passive.Truncated, passive.RawLength, passive.WireLength, wireLength)
+	}
+	if passive.IPv4 == nil {
+		t.Fatalf("IPv4 header should be parsed")
+	}
+	if passive.TCP != nil {
+		t.Errorf("TCP should not be parsed from 10 bytes")
+	}
+	if want := []string{"IPv4", "TCP"}; !reflect.DeepEqual(passive.PartialLayers, want) {
+		t.Errorf("PartialLayers = %v, want %v", passive.PartialLayers, want)
+	}
+
+	// TCP ヘッダーまでは取れているがペイロードが途中で切れている
+	passive, err = DecodeFrameWithWireLength(frame[:60], wireLength)
+	if err != nil {
+		t.Fatalf("DecodeFrameWithWireLength returned error: %v", err)
+	}
+	if passive.TCP == nil || passive.TCP.DstPort != 80 {
+		t.Fatalf("TCP should be parsed: %+v", passive.TCP)
+	}
+	if want := []string{"IPv4"}; !reflect.DeepEqual(passive.PartialLayers, want) {
+		t.Errorf("PartialLayers = %v, want %v", passive.PartialLayers, want)
+	}
+
+	// 切り詰められていなければ何も記録しない
+	passive, err = DecodeFrame(frame)
+	if err != nil {
+		t.Fatalf("DecodeFrame returned error: %v", err)
+	}
+	if passive.Truncated || len(passive.PartialLayers) != 0 || passive.WireLength != len(frame) {
+		t.Errorf("complete frame: Truncated = %v, PartialLayers = %v, WireLength = %d", passive.Truncated, passive.PartialLayers, passive.WireLength)
+	}
+}
+
+// TestDecodeFrameShorterThanDeclared tests payloads shorter than their declared length without a known wire length
+// 回線上の長さが不明でも、宣言された長さより短いペイロードが検出されることをテストします
+func TestDecodeFrameShorterThanDeclared(t *testing.T) {
+	// UDP の Length は 100 だが 8 バイトのペイロードしかない
+	udp := []byte{0xc3, 0x50, 0x00, 0x35, 0x00, 0x64, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	ipv6 := make([]byte, 40)
+	ipv6[0] = 0x60
+	ipv6[4], ipv6[5] = 0x00, 0x64 // payload length 100
+	ipv6[6] = IPv6_NEXT_HEADER_UDP
+	ipv6[7] = 0x40
+	frame := append([]byte{0x33, 0x33, 0x00, 0x00, 0x00, 0x01, 0x00, 0x15, 0x5d, 0xfb, 0xbf, 0x3a, 0x86, 0xdd}, ipv6...)
+	frame = append(frame, udp...)
+
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatalf("DecodeFrame returned error: %v", err)
+	}
+	if passive.IPv6 == nil || passive.UDP == nil {
+		t.Fatalf("IPv6 and UDP headers should be parsed")
+	}
+	if want := []string{"IPv6", "UDP"}; !passive.Truncated || !reflect.DeepEqual(passive.PartialLayers, want) {
+		t.Errorf("Truncated = %v, PartialLayers = %v, want true, %v", passive.Truncated, passive.PartialLayers, want)
+	}
+}
+
+// TestParseTCPPacketTruncatedOptions tests that a TCP header cut off in its options is still parsed
+// オプションの途中で切れたTCPヘッダーも解析できることをテストします
+func TestParseTCPPacketTruncatedOptions(t *testing.T) {
+	data := make([]byte, 24)
+	data[0], data[1] = 0xc3, 0x50
+	data[2], data[3] = 0x01, 0xbb
+	data[12] = 0x80 // data offset 32
+	data[13] = TCP_FLAGS_SYN
+	tcp := ParseTCPPacket(data)
+	if tcp == nil {
+		t.Fatalf("ParseTCPPacket should parse a header with truncated options")
+	}
+	if tcp.DataOffset != 32 || len(tcp.Options) != 4 || len(tcp.Payload) != 0 {
+		t.Errorf("DataOffset = %d, len(Options) = %d, len(Payload) = %d, want 32, 4, 0", tcp.DataOffset, len(tcp.Options), len(tcp.Payload))
+	}
+}
+
+// TestDecodeFrameRaw tests that Raw is the input frame and the layers slice into it
+// Rawが入力したフレームと等しく、各レイヤがそれを参照していることをテストします
+func TestDecodeFrameRaw(t *testing.T) {
+	frame := parseDepthTestFrame()
+	passive, err := DecodeFrame(frame)
+	if err != nil {
+		t.Fatalf("DecodeFrame returned error: %v", err)
+	}
+	if !bytes.Equal(passive.Raw, frame) {
+		t.Errorf("Raw = %x, want %x", passive.Raw, frame)
+	}
+	if &passive.EthernetFrame.Payload[0] != &passive.Raw[14] || &passive.IPv4.Payload[0] != &passive.Raw[34] {
+		t.Errorf("layers should slice into Raw")
+	}
+
+	// スナップ長で切り詰めた場合は、保持した分だけ
+	passive, err = DecodeFrameWithWireLength(frame[:44], len(frame))
+	if err != nil {
+		t.Fatalf("DecodeFrameWithWireLength returned error: %v", err)
+	}
+	if !bytes.Equal(passive.Raw, frame[:44]) {
+		t.Errorf("truncated Raw = %x, want %x", passive.Raw, frame[:44])
+	}
+}
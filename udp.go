@@ -45,6 +45,26 @@ func (u *UDP) Len() {
 	u.Length = uint16(length)
 }
 
+// IPv4 の疑似ヘッダと合わせて計算する. 計算結果が 0 のときは未使用の 0 と区別するため 0xffff を入れる
+// ref: https://datatracker.ietf.org/doc/html/rfc768
+func (u *UDP) CalculateChecksum(ipv4 *IPv4) {
+	forUDPChecksum := &bytes.Buffer{}
+	WriteUint32(forUDPChecksum, ipv4.SrcAddr)
+	WriteUint32(forUDPChecksum, ipv4.DstAddr)
+	forUDPChecksum.WriteByte(0x00)
+	forUDPChecksum.WriteByte(IPv4_PROTO_UDP)
+	WriteUint16(forUDPChecksum, u.Length)
+	forUDPChecksum.Write(u.Bytes())
+	if len(u.Data)%2 != 0 {
+		forUDPChecksum.WriteByte(0x00)
+	}
+
+	u.Checksum = binary.BigEndian.Uint16(calculateChecksum(forUDPChecksum.Bytes()))
+	if u.Checksum == 0 {
+		u.Checksum = 0xffff
+	}
+}
+
 // IPv6 ではチェックサムがないため、上のレイヤでチェックサムが必要なため
 func (u *UDP) CalculateChecksumForIPv6(ipv6 *IPv6) {
 	pseudoHeader := ipv6.PseudoHeader(uint32(u.Length))